@@ -2,9 +2,12 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -20,45 +23,73 @@ type cmdItem struct {
 }
 
 type app struct {
-	ui             *ui
-	nav            *nav
-	ticker         *time.Ticker
-	quitChan       chan struct{}
-	cmd            *exec.Cmd
-	cmdIn          io.WriteCloser
-	cmdOutBuf      []byte
-	cmdHistory     []cmdItem
-	cmdHistoryBeg  int
-	cmdHistoryInd  int
-	menuCompActive bool
-	menuComps      []string
-	menuCompInd    int
-	selectionOut   []string
-	watch          *watch
-	quitting       bool
+	ui              *ui
+	nav             *nav
+	ticker          *time.Ticker
+	autosaveTicker  *time.Ticker
+	segmentTicker   *time.Ticker
+	gitStatusTicker *time.Ticker
+	refreshTicker   *time.Ticker
+	quitChan        chan struct{}
+	termChan        chan struct{}
+	cmd             *exec.Cmd
+	cmdIn           io.WriteCloser
+	cmdOutBuf       []byte
+	cmdHistory      []cmdItem
+	cmdHistoryBeg   int
+	cmdHistoryInd   int
+	menuCompActive  bool
+	menuComps       []string
+	menuCompInd     int
+	selectionOut    []string
+	recent          []string
+	jumpDirs        []string
+	fuzzyIdx        *fuzzyIndex
+	fuzzyMatches    []string
+	fuzzyInd        int
+	searchRes       *searchResults
+	searchPager     *pager
+	watch           *watch
+	jobChan         chan os.Signal
+	setup           *setup
+	tutor           *tutor
+	progress        *headlessProgress
+	quitting        bool
+	quitShell       bool
+	terminated      bool
+	keyEval         bool
+	lfenvSeen       map[string]string
+	polled          map[string]time.Time
+	lfenvPending    *trustedFile
+	sourcePending   *trustedFile
 }
 
 func newApp(ui *ui, nav *nav) *app {
 	quitChan := make(chan struct{}, 1)
 
 	app := &app{
-		ui:       ui,
-		nav:      nav,
-		ticker:   new(time.Ticker),
-		quitChan: quitChan,
-		watch:    newWatch(nav.dirChan, nav.fileChan, nav.delChan),
-	}
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM)
+		ui:              ui,
+		nav:             nav,
+		ticker:          new(time.Ticker),
+		autosaveTicker:  new(time.Ticker),
+		segmentTicker:   new(time.Ticker),
+		gitStatusTicker: new(time.Ticker),
+		refreshTicker:   new(time.Ticker),
+		quitChan:        quitChan,
+		termChan:        make(chan struct{}, 1),
+		lfenvSeen:       make(map[string]string),
+		polled:          make(map[string]time.Time),
+		watch:           newWatch(nav.dirChan, nav.fileChan, nav.delChan),
+	}
+
+	app.jobChan = make(chan os.Signal, 1)
+	notifyJobControl(app.jobChan)
 	go func() {
-		for {
-			switch <-sigChan {
-			case os.Interrupt:
-			case syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM:
-				app.quit()
-				os.Exit(3)
-				return
+		for sig := range app.jobChan {
+			if isSuspendSignal(sig) {
+				app.ui.exprChan <- &callExpr{"suspend", nil, 1}
+			} else {
+				app.ui.exprChan <- &callExpr{"redraw", nil, 1}
 			}
 		}
 	}()
@@ -75,7 +106,11 @@ func (app *app) quit() {
 	}
 	app.quitting = true
 
+	selection := app.writeSelectionFile()
 	onQuit(app)
+	closeRemotes()
+
+	app.autoSaveSession()
 
 	if gOpts.history {
 		if err := app.writeHistory(); err != nil {
@@ -86,6 +121,16 @@ func (app *app) quit() {
 		if err := remote(fmt.Sprintf("drop %d", gClientID)); err != nil {
 			log.Printf("dropping connection: %s", err)
 		}
+		if selection != nil {
+			// sent after dropping the connection so that only other clients run it
+			escaped := make([]string, len(selection))
+			for i, s := range selection {
+				escaped[i] = escape(s)
+			}
+			if err := remote("send selection-written " + strings.Join(escaped, " ")); err != nil {
+				log.Printf("sending written selection: %s", err)
+			}
+		}
 		if gOpts.autoquit {
 			if err := remote("quit"); err != nil {
 				log.Printf("auto quitting server: %s", err)
@@ -94,6 +139,25 @@ func (app *app) quit() {
 	}
 }
 
+// This function forwards the termination signals to the main loop, so that
+// lf is quit there without racing with the drawing and previewing. The usual
+// exit procedure is followed as much as possible so that the last directory is
+// still written for shell integrations (e.g. when the terminal is closed).
+func (app *app) notifyTermination() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM)
+	go func() {
+		for {
+			switch <-sigChan {
+			case os.Interrupt:
+			case syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM:
+				app.termChan <- struct{}{}
+				return
+			}
+		}
+	}()
+}
+
 func (app *app) readFile(path string) {
 	log.Printf("reading file: %s", path)
 
@@ -183,34 +247,25 @@ func saveFiles(list []string, cp bool) error {
 }
 
 func (app *app) readHistory() error {
-	f, err := os.Open(gHistoryPath)
-	if os.IsNotExist(err) {
-		return nil
-	}
-	if err != nil {
-		return fmt.Errorf("opening history file: %s", err)
-	}
-	defer f.Close()
+	return withDataLock(gHistoryPath, app.loadHistory)
+}
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		toks := strings.SplitN(scanner.Text(), " ", 2)
-		if toks[0] != ":" && toks[0] != "$" && toks[0] != "%" && toks[0] != "!" && toks[0] != "&" {
-			continue
-		}
-		if len(toks) < 2 {
-			continue
-		}
-		app.cmdHistory = append(app.cmdHistory, cmdItem{toks[0], toks[1]})
+// This function reads the history file, which should be done while holding
+// the lock of the file.
+func (app *app) loadHistory() error {
+	lines, err := readDataLines(gHistoryPath, func(line string) bool {
+		prefix, _, found := strings.Cut(line, " ")
+		return found && (prefix == ":" || prefix == "$" || prefix == "%" || prefix == "!" || prefix == "&")
+	})
+
+	for _, line := range lines {
+		prefix, value, _ := strings.Cut(line, " ")
+		app.cmdHistory = append(app.cmdHistory, cmdItem{prefix, value})
 	}
 
 	app.cmdHistoryBeg = len(app.cmdHistory)
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("reading history file: %s", err)
-	}
-
-	return nil
+	return err
 }
 
 func (app *app) writeHistory() error {
@@ -220,36 +275,55 @@ func (app *app) writeHistory() error {
 
 	local := make([]cmdItem, len(app.cmdHistory)-app.cmdHistoryBeg)
 	copy(local, app.cmdHistory[app.cmdHistoryBeg:])
-	app.cmdHistory = nil
 
-	if err := app.readHistory(); err != nil {
-		return fmt.Errorf("reading history file: %s", err)
-	}
+	// the history is read again under the lock to merge with other clients
+	return withDataLock(gHistoryPath, func() error {
+		app.cmdHistory = nil
 
-	app.cmdHistory = append(app.cmdHistory, local...)
+		// a corrupted history file is replaced with the merged history below
+		if err := app.loadHistory(); errors.Is(err, errDataCorrupt) {
+			log.Printf("reading history file: %s", err)
+		} else if err != nil {
+			return fmt.Errorf("reading history file: %s", err)
+		}
 
-	if err := os.MkdirAll(filepath.Dir(gHistoryPath), os.ModePerm); err != nil {
-		return fmt.Errorf("creating data directory: %s", err)
-	}
+		app.cmdHistory = append(app.cmdHistory, local...)
 
-	f, err := os.Create(gHistoryPath)
-	if err != nil {
-		return fmt.Errorf("creating history file: %s", err)
-	}
-	defer f.Close()
+		if len(app.cmdHistory) > 1000 {
+			app.cmdHistory = app.cmdHistory[len(app.cmdHistory)-1000:]
+		}
 
-	if len(app.cmdHistory) > 1000 {
-		app.cmdHistory = app.cmdHistory[len(app.cmdHistory)-1000:]
-	}
+		var b bytes.Buffer
+		for _, cmd := range app.cmdHistory {
+			fmt.Fprintf(&b, "%s %s\n", cmd.prefix, cmd.value)
+		}
 
-	for _, cmd := range app.cmdHistory {
-		_, err = fmt.Fprintf(f, "%s %s\n", cmd.prefix, cmd.value)
-		if err != nil {
+		if err := writeDataFile(gHistoryPath, b.Bytes()); err != nil {
 			return fmt.Errorf("writing history file: %s", err)
 		}
+
+		// entries are written only once when the history is saved again
+		app.cmdHistoryBeg = len(app.cmdHistory)
+
+		return nil
+	})
+}
+
+func (app *app) readConfig() {
+	if gConfigPath != "" {
+		if _, err := os.Stat(gConfigPath); !os.IsNotExist(err) {
+			app.readFile(gConfigPath)
+		} else {
+			log.Printf("config file does not exist: %s", err)
+		}
+		return
 	}
 
-	return nil
+	for _, path := range gConfigPaths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			app.readFile(path)
+		}
+	}
 }
 
 // This is the main event loop of the application. Expressions are read from
@@ -259,6 +333,8 @@ func (app *app) writeHistory() error {
 func (app *app) loop() {
 	go app.nav.previewLoop(app.ui)
 
+	app.notifyTermination()
+
 	var serverChan <-chan expr
 	if !gSingleMode {
 		serverChan = readExpr()
@@ -266,18 +342,16 @@ func (app *app) loop() {
 
 	app.ui.readExpr()
 
-	if gConfigPath != "" {
-		if _, err := os.Stat(gConfigPath); !os.IsNotExist(err) {
-			app.readFile(gConfigPath)
-		} else {
-			log.Printf("config file does not exist: %s", err)
-		}
+	if gTutorDir != "" {
+		// the tutorial relies on the default settings and keybindings
+		app.tutor = newTutor(gTutorDir)
 	} else {
-		for _, path := range gConfigPaths {
-			if _, err := os.Stat(path); !os.IsNotExist(err) {
-				app.readFile(path)
-			}
-		}
+		app.readConfig()
+		app.readSortLocal()
+	}
+
+	if isFirstRun() {
+		app.startSetup()
 	}
 
 	for _, cmd := range gCommands {
@@ -300,6 +374,18 @@ func (app *app) loop() {
 	app.nav.getDirs(wd)
 	app.nav.addJumpList()
 	app.nav.init = true
+	app.exportDir()
+	app.reportDir()
+
+	app.updateTutor()
+
+	if gOpts.livecd {
+		app.writeLiveCd()
+	}
+
+	if gOpts.autosavesession && gSelect == "" && gAttachSession == "" && gTutorDir == "" {
+		app.loadSession(nil, true)
+	}
 
 	if gSelect != "" {
 		go func() {
@@ -314,21 +400,28 @@ func (app *app) loop() {
 		}()
 	}
 
+	if gAttachSession != "" {
+		go app.attach(gAttachSession)
+	}
+
 	for {
 		select {
 		case <-app.quitChan:
 			if app.nav.copyTotal > 0 {
 				app.ui.echoerr("quit: copy operation in progress")
+				app.quitShell = false
 				continue
 			}
 
 			if app.nav.moveTotal > 0 {
 				app.ui.echoerr("quit: move operation in progress")
+				app.quitShell = false
 				continue
 			}
 
 			if app.nav.deleteTotal > 0 {
 				app.ui.echoerr("quit: delete operation in progress")
+				app.quitShell = false
 				continue
 			}
 
@@ -338,12 +431,24 @@ func (app *app) loop() {
 
 			log.Print("bye!")
 
+			return
+		case <-app.termChan:
+			// operations in progress are not waited for as lf is killed
+			app.terminated = true
+
+			app.quit()
+
+			app.nav.previewChan <- ""
+
+			log.Print("terminated")
+
 			return
 		case n := <-app.nav.copyBytesChan:
 			app.nav.copyBytes += n
 			// n is usually 32*1024B (default io.Copy() buffer) so update roughly per 32KB x 128 = 4MB copied
 			if app.nav.copyUpdate++; app.nav.copyUpdate >= 128 {
 				app.nav.copyUpdate = 0
+				app.updateJobs()
 				app.ui.draw(app.nav)
 			}
 		case n := <-app.nav.copyTotalChan:
@@ -354,11 +459,13 @@ func (app *app) loop() {
 			if app.nav.copyTotal == 0 {
 				app.nav.copyUpdate = 0
 			}
+			app.updateJobs()
 			app.ui.draw(app.nav)
 		case n := <-app.nav.moveCountChan:
 			app.nav.moveCount += n
 			if app.nav.moveUpdate++; app.nav.moveUpdate >= 1000 {
 				app.nav.moveUpdate = 0
+				app.updateJobs()
 				app.ui.draw(app.nav)
 			}
 		case n := <-app.nav.moveTotalChan:
@@ -369,6 +476,7 @@ func (app *app) loop() {
 			if app.nav.moveTotal == 0 {
 				app.nav.moveUpdate = 0
 			}
+			app.updateJobs()
 			app.ui.draw(app.nav)
 		case n := <-app.nav.deleteCountChan:
 			app.nav.deleteCount += n
@@ -377,14 +485,21 @@ func (app *app) loop() {
 				app.ui.draw(app.nav)
 			}
 		case n := <-app.nav.deleteTotalChan:
+			// found entries are sent for each directory read, so the redraw
+			// is throttled like the removed entries except when the progress
+			// is shown or hidden
+			prev := app.nav.deleteTotal
 			app.nav.deleteTotal += n
 			if n < 0 {
 				app.nav.deleteCount += n
 			}
 			if app.nav.deleteTotal == 0 {
 				app.nav.deleteUpdate = 0
+				app.ui.draw(app.nav)
+			} else if app.nav.deleteUpdate++; prev == 0 || n < 0 || app.nav.deleteUpdate >= 1000 {
+				app.nav.deleteUpdate = 0
+				app.ui.draw(app.nav)
 			}
-			app.ui.draw(app.nav)
 		case d := <-app.nav.dirChan:
 			if gOpts.dircache {
 				prev, ok := app.nav.dirCache[d.path]
@@ -398,7 +513,7 @@ func (app *app) loop() {
 					d.sel(prev.name(), app.nav.height)
 				}
 
-				app.nav.dirCache[d.path] = d
+				app.nav.cacheDir(d)
 			} else {
 				d.sort()
 			}
@@ -413,6 +528,9 @@ func (app *app) loop() {
 					app.nav.dirs[i] = d
 				}
 			}
+			if app.nav.pane != nil && app.nav.pane.path == d.path {
+				app.nav.pane = d
+			}
 
 			app.nav.position()
 
@@ -430,16 +548,18 @@ func (app *app) loop() {
 			}
 
 			app.watchDir(d)
+			app.refreshColumns()
 			onLoad(app, d.fileNames())
+			app.updateTutor()
 			app.ui.draw(app.nav)
 		case r := <-app.nav.regChan:
-			app.nav.regCache[r.path] = r
+			app.nav.cacheReg(r)
 
 			curr, err := app.nav.currFile()
 			if err == nil {
 				if r.path == curr.path {
 					app.ui.regPrev = r
-					if gOpts.sixel {
+					if hasGraphics() {
 						app.ui.sxScreen.forceClear = true
 					}
 				}
@@ -447,21 +567,16 @@ func (app *app) loop() {
 
 			app.ui.draw(app.nav)
 		case f := <-app.nav.fileChan:
-			for _, dir := range app.nav.dirCache {
-				if dir.path != filepath.Dir(f.path) {
-					continue
-				}
-
-				for i := range dir.allFiles {
-					if dir.allFiles[i].path == f.path {
-						dir.allFiles[i] = f
-						break
-					}
+			// updates sent together by the watcher are drawn at once
+			paths := []string{app.updateFile(f)}
+		files:
+			for {
+				select {
+				case f := <-app.nav.fileChan:
+					paths = append(paths, app.updateFile(f))
+				default:
+					break files
 				}
-
-				name := dir.name()
-				dir.sort()
-				dir.sel(name, app.nav.height)
 			}
 
 			app.ui.loadFile(app, false)
@@ -469,8 +584,30 @@ func (app *app) loop() {
 				app.ui.loadFileInfo(app.nav)
 			}
 
-			onLoad(app, []string{f.path})
+			onLoad(app, paths)
 			app.ui.draw(app.nav)
+		case u := <-app.nav.dirSizeChan:
+			app.updateDirSize(u)
+		case u := <-app.nav.hoverSizeChan:
+			app.updateHoverSize(u)
+		case r := <-app.nav.segmentChan:
+			if app.nav.segments.update(r) && r.dir == app.nav.currDir().path {
+				app.ui.draw(app.nav)
+			}
+		case r := <-app.nav.columnChan:
+			if app.nav.columns.update(r) {
+				app.ui.draw(app.nav)
+			}
+			// directories loaded again while the command was running
+			app.refreshColumns()
+		case <-app.segmentTicker.C:
+			app.refreshSegments()
+		case r := <-app.nav.gitStatusChan:
+			if app.nav.gitStatus.update(r) && gOpts.gitstatus {
+				app.ui.draw(app.nav)
+			}
+		case <-app.gitStatusTicker.C:
+			app.refreshGitStatus()
 		case path := <-app.nav.delChan:
 			deletePathRecursive(app.nav.selections, path)
 			if len(app.nav.selections) == 0 {
@@ -480,6 +617,7 @@ func (app *app) loop() {
 			deletePathRecursive(app.nav.regCache, path)
 
 			deletePathRecursive(app.nav.dirCache, path)
+			app.nav.invalidateDir(filepath.Dir(path))
 			currPath := app.nav.currDir().path
 			if currPath == path || strings.HasPrefix(currPath, path+string(filepath.Separator)) {
 				if wd, err := os.Getwd(); err == nil {
@@ -491,6 +629,10 @@ func (app *app) loop() {
 			if e == nil {
 				continue
 			}
+			app.nav.boundHit = ""
+			// commands run from keys (i.e. mappings and the command line)
+			// are not throttled like commands sent from elsewhere
+			app.keyEval = true
 			e.eval(app, nil)
 		loop:
 			for {
@@ -505,6 +647,9 @@ func (app *app) loop() {
 					break loop
 				}
 			}
+			app.keyEval = false
+			app.boundFeedback()
+			app.updateTutor()
 			app.ui.draw(app.nav)
 		case e := <-app.ui.exprChan:
 			e.eval(app, nil)
@@ -513,8 +658,28 @@ func (app *app) loop() {
 			e.eval(app, nil)
 			app.ui.draw(app.nav)
 		case <-app.ticker.C:
-			app.nav.renew()
+			app.pollDirs(true)
 			app.ui.loadFile(app, false)
+		case <-app.refreshTicker.C:
+			app.pollDirs(false)
+			app.ui.loadFile(app, false)
+		case <-app.autosaveTicker.C:
+			if err := app.writeHistory(); err != nil {
+				app.ui.echoerrf("autosave: %s", err)
+			}
+			if err := app.writeRecovery(); err != nil {
+				app.ui.echoerrf("autosave: %s", err)
+			}
+		case <-app.ui.flashTimer.C:
+			app.ui.flash(false)
+		case <-app.ui.hoverTimer.C:
+			if curr, err := app.nav.currFile(); err == nil && curr.path == app.ui.hoverPath {
+				app.ui.dirPrev = app.nav.loadDir(curr.path)
+				app.ui.draw(app.nav)
+			}
+		case <-app.nav.reloadTimer.C:
+			(&callExpr{"reload", nil, 1}).eval(app, nil)
+			app.ui.draw(app.nav)
 		case <-app.nav.previewTimer.C:
 			app.nav.previewLoading = true
 			app.ui.draw(app.nav)
@@ -522,7 +687,38 @@ func (app *app) loop() {
 	}
 }
 
-func (app *app) runCmdSync(cmd *exec.Cmd, pause_after bool) {
+// This function keeps the current directory in the state for the 'dir' query,
+// which is also used by the server for the 'sync-cwd' command. Unlike other
+// queries, it is updated on each directory change instead of shell commands.
+func (app *app) exportDir() {
+	gState.mutex.Lock()
+	gState.data["dir"] = app.nav.currDir().path + "\n"
+	gState.data["json dir"] = jsonDir(app.nav.currDir().path)
+	gState.mutex.Unlock()
+}
+
+func (app *app) updateFile(f *file) string {
+	for _, dir := range app.nav.dirCache {
+		if dir.path != filepath.Dir(f.path) {
+			continue
+		}
+
+		for i := range dir.allFiles {
+			if dir.allFiles[i].path == f.path {
+				dir.allFiles[i] = f
+				break
+			}
+		}
+
+		name := dir.name()
+		dir.sort()
+		dir.sel(name, app.nav.height)
+	}
+
+	return f.path
+}
+
+func (app *app) runCmdSync(cmd *exec.Cmd, pause_after bool) error {
 	app.nav.previewChan <- ""
 
 	if err := app.ui.suspend(); err != nil {
@@ -535,7 +731,13 @@ func (app *app) runCmdSync(cmd *exec.Cmd, pause_after bool) {
 		}
 	}()
 
-	if err := cmd.Run(); err != nil {
+	// Job control signals are restored to their default behavior while the
+	// command is running so that lf is stopped together with the command.
+	resetJobControl()
+	defer notifyJobControl(app.jobChan)
+
+	err := cmd.Run()
+	if err != nil {
 		app.ui.echoerrf("running shell: %s", err)
 	}
 	if pause_after {
@@ -544,6 +746,32 @@ func (app *app) runCmdSync(cmd *exec.Cmd, pause_after bool) {
 
 	app.ui.loadFile(app, true)
 	app.nav.renew()
+
+	return err
+}
+
+// This function is used to stop lf with job control, which restores the
+// terminal before stopping and redraws the screen once lf is continued.
+func (app *app) suspend() {
+	app.nav.previewChan <- ""
+
+	if err := app.ui.suspend(); err != nil {
+		log.Printf("suspend: %s", err)
+	}
+
+	err := suspendProcess()
+
+	if err := app.ui.resume(); err != nil {
+		app.quit()
+		os.Exit(3)
+	}
+
+	if err != nil {
+		app.ui.echoerrf("suspend: %s", err)
+	}
+
+	app.ui.screen.Sync()
+	app.nav.renew()
 }
 
 // This function is used to run a shell command. Modes are as follows:
@@ -574,10 +802,11 @@ func (app *app) runShell(s string, args []string, prefix string) {
 	gState.data["cmaps"] = listBinds(map[string]map[string]expr{
 		"c": gOpts.cmdkeys,
 	})
-	gState.data["cmds"] = listCmds(gOpts.cmds)
+	gState.data["cmds"] = listCmds(activeCmds())
 	gState.data["jumps"] = listJumps(app.nav.jumpList, app.nav.jumpListInd)
 	gState.data["history"] = listHistory(app.cmdHistory)
 	gState.data["files"] = listFilesInCurrDir(app.nav)
+	maps.Copy(gState.data, app.jsonState())
 	gState.mutex.Unlock()
 
 	cmd := shellCommand(s, args)
@@ -590,6 +819,18 @@ func (app *app) runShell(s string, args []string, prefix string) {
 		cmd.Stdout = os.Stderr
 		cmd.Stderr = os.Stderr
 
+		if prefix == "!" && gOpts.shellpager {
+			var buf bytes.Buffer
+			cmd.Stdout = io.MultiWriter(os.Stderr, &buf)
+			cmd.Stderr = cmd.Stdout
+
+			app.runCmdSync(cmd, false)
+			if buf.Len() != 0 {
+				app.ui.pager = newPager(s, buf.String())
+			}
+			return
+		}
+
 		app.runCmdSync(cmd, prefix == "!")
 		return
 	}
@@ -665,7 +906,7 @@ func (app *app) runShell(s string, args []string, prefix string) {
 }
 
 func (app *app) watchDir(dir *dir) {
-	if !gOpts.watch {
+	if !isWatched(dir.path) {
 		return
 	}
 
@@ -694,6 +935,8 @@ func (app *app) exportMode() {
 			return "filter"
 		case "find: ", "find-back: ":
 			return "find"
+		case "hop: ", "hop-toggle: ":
+			return "hop"
 		case "mark-save: ", "mark-load: ", "mark-remove: ":
 			return "mark"
 		case "rename: ":
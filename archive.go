@@ -0,0 +1,318 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The 'archive' command creates an archive of the current file or selected
+// files in the current directory, with the format in the 'archiveformat'
+// option and the compression level in the 'compresslevel' option. Formats
+// supported by the standard library are written natively, whereas 'tar.zst'
+// and 'tar.xz' archives are compressed with the 'zstd' and 'xz' commands. The
+// compression ratio is shown when the archive is finished, and it is kept in
+// the report of the operation (see 'last-report').
+
+// Supported formats and their compression levels, where zero is used for the
+// default level of the format.
+var gArchiveFormats = map[string][2]int{
+	"zip":     {1, 9},
+	"tar":     {0, 0},
+	"tar.gz":  {1, 9},
+	"tar.zst": {1, 19},
+	"tar.xz":  {1, 9},
+}
+
+func checkArchiveFormat(format string, level int) error {
+	levels, ok := gArchiveFormats[format]
+	if !ok {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	if level != 0 && (level < levels[0] || level > levels[1]) {
+		if levels[1] == 0 {
+			return fmt.Errorf("compression level is not supported for %s", format)
+		}
+		return fmt.Errorf("compression level should be between %d and %d for %s", levels[0], levels[1], format)
+	}
+	return nil
+}
+
+// This function returns the default name of an archive of the given files
+// without the extension, which is the name of the file for a single file and
+// the name of the directory for multiple files.
+func archiveName(list []string, dir string) string {
+	if len(list) == 1 {
+		return filepath.Base(list[0])
+	}
+	return filepath.Base(dir)
+}
+
+// This type writes files to an archive, either a zip archive or a tar archive
+// compressed with a compressor which is closed after the tar writer.
+type archiveWriter struct {
+	zw    *zip.Writer
+	tw    *tar.Writer
+	comp  io.WriteCloser
+	cmd   *exec.Cmd
+	bytes int64
+}
+
+func newArchiveWriter(w io.Writer, format string, level int) (*archiveWriter, error) {
+	switch format {
+	case "zip":
+		zw := zip.NewWriter(w)
+		if level != 0 {
+			zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(out, level)
+			})
+		}
+		return &archiveWriter{zw: zw}, nil
+	case "tar":
+		return &archiveWriter{tw: tar.NewWriter(w)}, nil
+	case "tar.gz":
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		return &archiveWriter{tw: tar.NewWriter(gw), comp: gw}, nil
+	case "tar.zst", "tar.xz":
+		name := "zstd"
+		if format == "tar.xz" {
+			name = "xz"
+		}
+		args := []string{"-q", "-c"}
+		if level != 0 {
+			args = append(args, "-"+strconv.Itoa(level))
+		}
+		cmd := exec.Command(name, args...)
+		cmd.Stdout = w
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("running %s: %s", name, err)
+		}
+		return &archiveWriter{tw: tar.NewWriter(stdin), comp: stdin, cmd: cmd}, nil
+	}
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+func (aw *archiveWriter) writeHeader(info os.FileInfo, name, link string) (io.Writer, error) {
+	if aw.zw != nil {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return nil, err
+		}
+		hdr.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			hdr.Name += "/"
+		} else {
+			hdr.Method = zip.Deflate
+		}
+		return aw.zw.CreateHeader(hdr)
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	return aw.tw, aw.tw.WriteHeader(hdr)
+}
+
+// This function adds the given file with its contents to the archive, with
+// names relative to the parent directory of the file.
+func (aw *archiveWriter) add(src string) error {
+	base := filepath.Dir(src)
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("walk: %s", err)
+		}
+
+		name, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		w, err := aw.writeHeader(info, name, link)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			// zip archives keep the target of the link as the contents
+			if aw.zw != nil {
+				_, err = io.WriteString(w, link)
+			}
+			return err
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			n, err := io.Copy(w, f)
+			aw.bytes += n
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (aw *archiveWriter) close() error {
+	if aw.zw != nil {
+		return aw.zw.Close()
+	}
+
+	err := aw.tw.Close()
+	if aw.comp != nil {
+		if cerr := aw.comp.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if aw.cmd != nil {
+		if werr := aw.cmd.Wait(); err == nil && werr != nil {
+			err = fmt.Errorf("running %s: %s", filepath.Base(aw.cmd.Path), werr)
+		}
+	}
+	return err
+}
+
+// This function returns the compression ratio of the archive with the given
+// sizes of the contents and the archive.
+func archiveRatio(contents, archive int64) string {
+	if contents == 0 {
+		return fmt.Sprintf("%s to %s", humanize(contents), humanize(archive))
+	}
+	return fmt.Sprintf("%s to %s, %d%%", humanize(contents), humanize(archive), archive*100/contents)
+}
+
+func (app *app) archive(name string, dryRun bool) {
+	format, level := gOpts.archiveformat, gOpts.compresslevel
+	if err := checkArchiveFormat(format, level); err != nil {
+		app.ui.echoerrf("archive: %s", err)
+		return
+	}
+
+	list, err := app.nav.currFileOrSelections()
+	if err != nil {
+		app.ui.echoerrf("archive: %s", err)
+		return
+	}
+
+	dir := app.nav.currDir().path
+	if name == "" {
+		name = archiveName(list, dir)
+	}
+	if !strings.HasSuffix(name, "."+format) {
+		name += "." + format
+	}
+
+	path := filepath.Join(dir, name)
+	if lstat, err := os.Lstat(path); err == nil {
+		path = dupPath(dir, lstat)
+	}
+
+	if dryRun {
+		var plan []string
+		for _, src := range list {
+			plan = append(plan, fmt.Sprintf("archive '%s' into '%s'", src, path))
+		}
+		app.showDryRun("archive", plan)
+		return
+	}
+
+	runAsync(func() { app.archiveAsync(list, path, format, level) })
+}
+
+func (app *app) archiveAsync(list []string, path, format string, level int) {
+	echo := &callExpr{"echoerr", []string{""}, 1}
+	start := time.Now()
+	r := newReport("archive", filepath.Dir(path))
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		echo.args[0] = "archive: " + err.Error()
+		app.ui.exprChan <- echo
+		return
+	}
+
+	aw, err := newArchiveWriter(f, format, level)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		echo.args[0] = "archive: " + err.Error()
+		app.ui.exprChan <- echo
+		return
+	}
+
+	errCount := 0
+	for _, src := range list {
+		var reasons []string
+		if err := aw.add(src); err != nil {
+			errCount++
+			echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
+			app.ui.exprChan <- echo
+			reasons = append(reasons, err.Error())
+		}
+		r.add(src, reasons)
+	}
+
+	err = aw.close()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		echo.args[0] = "archive: " + err.Error()
+		app.ui.exprChan <- echo
+		return
+	}
+
+	if stat, err := os.Stat(path); err == nil {
+		r.detail = archiveRatio(aw.bytes, stat.Size())
+	}
+
+	app.audit("archive", list, path, start, errCount)
+	app.finishReport(r)
+
+	if gSingleMode {
+		app.nav.renew()
+		app.ui.loadFile(app, true)
+	} else if err := remote("send load"); err != nil {
+		echo.args[0] = "archive: " + err.Error()
+		app.ui.exprChan <- echo
+	}
+
+	if errCount == 0 {
+		msg := fmt.Sprintf("\033[0;32mArchived to '%s' (%s)\033[0m", filepath.Base(path), r.detail)
+		app.ui.exprChan <- &callExpr{"echo", []string{msg}, 1}
+	}
+}
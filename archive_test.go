@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckArchiveFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		level  int
+		ok     bool
+	}{
+		{"zip", 0, true},
+		{"zip", 9, true},
+		{"zip", 10, false},
+		{"tar", 0, true},
+		{"tar", 1, false},
+		{"tar.zst", 19, true},
+		{"tar.rar", 0, false},
+	}
+
+	for _, test := range tests {
+		if err := checkArchiveFormat(test.format, test.level); (err == nil) != test.ok {
+			t.Errorf("at input '%s' and '%d' expected '%t' but got error '%v'", test.format, test.level, test.ok, err)
+		}
+	}
+}
+
+func TestArchiveWriter(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "foo")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "bar"), bytes.Repeat([]byte("a"), 1000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"zip", "tar", "tar.gz"} {
+		path := filepath.Join(dir, "foo."+format)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aw, err := newArchiveWriter(f, format, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := aw.add(src); err != nil {
+			t.Errorf("at input '%s' unexpected error: %s", format, err)
+		}
+		if err := aw.close(); err != nil {
+			t.Errorf("at input '%s' unexpected error: %s", format, err)
+		}
+		f.Close()
+
+		if aw.bytes != 1000 {
+			t.Errorf("at input '%s' expected '1000' bytes but got '%d'", format, aw.bytes)
+		}
+
+		out := filepath.Join(dir, "out."+format)
+		if _, err := extractArchive(path, out); err != nil {
+			t.Errorf("at input '%s' unexpected error: %s", format, err)
+			continue
+		}
+		if b, err := os.ReadFile(filepath.Join(out, "foo", "sub", "bar")); err != nil || len(b) != 1000 {
+			t.Errorf("at input '%s' expected extracted file but got error '%v'", format, err)
+		}
+	}
+}
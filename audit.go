@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The audit log records the file operations run by lf (i.e. copy, move,
+// delete and rename) when the 'auditlog' option is enabled, for traceability
+// on shared systems. Each operation is appended to the audit file as a single
+// line of JSON after it is finished. Operations run by custom commands (e.g.
+// a custom 'paste' command) are not recorded since they are unknown to lf.
+
+type auditEntry struct {
+	Time        string   `json:"time"`
+	Client      int      `json:"client"`
+	Op          string   `json:"op"`
+	Sources     []string `json:"sources"`
+	Destination string   `json:"destination,omitempty"`
+	Result      string   `json:"result"`
+	Duration    string   `json:"duration"`
+}
+
+func newAuditEntry(op string, srcs []string, dst string, start time.Time, errCount int) auditEntry {
+	result := "ok"
+	if errCount > 0 {
+		result = fmt.Sprintf("%d errors", errCount)
+	}
+
+	return auditEntry{
+		Time:        start.Format(time.RFC3339),
+		Client:      gClientID,
+		Op:          op,
+		Sources:     srcs,
+		Destination: dst,
+		Result:      result,
+		Duration:    time.Since(start).Round(time.Millisecond).String(),
+	}
+}
+
+func writeAuditEntry(path string, e auditEntry) error {
+	return appendJSONLine(path, e, "audit file")
+}
+
+// This function appends the given value to the file as a single line of JSON.
+// The name of the file is used in error messages.
+func appendJSONLine(path string, v any, name string) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("creating directory: %s", err)
+	}
+
+	// a single write in append mode keeps lines from different clients intact
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening %s: %s", name, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("writing %s: %s", name, err)
+	}
+
+	return nil
+}
+
+// This function records a finished file operation in the audit file if the
+// 'auditlog' option is enabled. It can be called from the goroutines running
+// file operations, so errors are sent to be shown in the main loop.
+func (app *app) audit(op string, srcs []string, dst string, start time.Time, errCount int) {
+	if !gOpts.auditlog {
+		return
+	}
+
+	if err := writeAuditEntry(gAuditPath, newAuditEntry(op, srcs, dst, start, errCount)); err != nil {
+		app.ui.exprChan <- &callExpr{"echoerr", []string{"auditlog: " + err.Error()}, 1}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteAuditEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lf", "audit")
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	entries := []auditEntry{
+		newAuditEntry("copy", []string{"/a/x", "/a/y"}, "/b", start, 0),
+		newAuditEntry("delete", []string{"/b/x"}, "", start, 2),
+	}
+	for _, e := range entries {
+		if err := writeAuditEntry(path, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != len(entries) {
+		t.Fatalf("expected %d lines but got %d", len(entries), len(lines))
+	}
+
+	for i, line := range lines {
+		var got auditEntry
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("at line '%s' unexpected error: %s", line, err)
+		}
+		if got.Op != entries[i].Op || got.Result != entries[i].Result || got.Destination != entries[i].Destination {
+			t.Errorf("at line '%s' expected '%+v' but got '%+v'", line, entries[i], got)
+		}
+	}
+
+	if exp := "2 errors"; entries[1].Result != exp {
+		t.Errorf("expected result '%s' but got '%s'", exp, entries[1].Result)
+	}
+	if exp := "2024-01-02T03:04:05Z"; entries[0].Time != exp {
+		t.Errorf("expected time '%s' but got '%s'", exp, entries[0].Time)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// The benchmark mode started with 'lf -bench <dir>' measures the time spent
+// loading and sorting the directories of the given tree, drawing the user
+// interface and dispatching previews of files, and prints a JSON report to
+// the standard output, so that performance data can be attached to issue
+// reports in a standard way. A synthetic tree is generated in a temporary
+// directory instead with 'synthetic' (or 'synthetic:<files>') when there is
+// no such directory. The configuration files are loaded as usual so that the
+// options affecting performance (e.g. 'dircounts' or 'previewer') are used.
+// The screen is simulated so that no terminal is needed.
+
+const (
+	gBenchMaxDirs     = 200
+	gBenchDraws       = 50
+	gBenchPreviews    = 20
+	gBenchSynthFiles  = 10000
+	gBenchSynthSubdir = 10
+)
+
+type benchStat struct {
+	Count  int     `json:"count"`
+	Total  float64 `json:"total_ms"`
+	Mean   float64 `json:"mean_ms"`
+	Min    float64 `json:"min_ms"`
+	Max    float64 `json:"max_ms"`
+	Median float64 `json:"median_ms"`
+}
+
+type benchReport struct {
+	Path      string    `json:"path"`
+	Synthetic bool      `json:"synthetic"`
+	Dirs      int       `json:"dirs"`
+	Files     int       `json:"files"`
+	Load      benchStat `json:"load"`
+	Sort      benchStat `json:"sort"`
+	Draw      benchStat `json:"draw"`
+	Preview   benchStat `json:"preview"`
+	Previewer bool      `json:"previewer"`
+	Version   string    `json:"version"`
+	GoVersion string    `json:"go_version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	CPUs      int       `json:"cpus"`
+}
+
+// This function returns the statistics of the given durations in
+// milliseconds.
+func newBenchStat(durations []time.Duration) benchStat {
+	if len(durations) == 0 {
+		return benchStat{}
+	}
+
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	ms := func(d time.Duration) float64 {
+		return float64(d.Microseconds()) / 1000
+	}
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+
+	return benchStat{
+		Count:  len(sorted),
+		Total:  ms(total),
+		Mean:   ms(total / time.Duration(len(sorted))),
+		Min:    ms(sorted[0]),
+		Max:    ms(sorted[len(sorted)-1]),
+		Median: ms(sorted[len(sorted)/2]),
+	}
+}
+
+// This function parses the number of files of a synthetic tree, and reports
+// whether the given argument asks for a synthetic tree.
+func parseBenchSynthetic(arg string) (int, bool, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return 0, false, nil
+	}
+
+	name, num, found := strings.Cut(arg, ":")
+	if name != "synthetic" {
+		return 0, false, nil
+	}
+	if !found {
+		return gBenchSynthFiles, true, nil
+	}
+
+	n, err := strconv.Atoi(num)
+	if err != nil || n <= 0 {
+		return 0, true, fmt.Errorf("invalid number of files: %s", num)
+	}
+	return n, true, nil
+}
+
+// This function generates a tree with the given number of files, which are
+// spread over a number of subdirectories with various extensions and sizes.
+func genBenchTree(root string, files int) error {
+	exts := []string{".txt", ".go", ".md", ".jpg", ".tar.gz", ""}
+	for i := range files {
+		dir := root
+		if i%2 == 1 {
+			dir = filepath.Join(root, fmt.Sprintf("dir%02d", i/2%gBenchSynthSubdir))
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		name := filepath.Join(dir, fmt.Sprintf("file%d%s", i, exts[i%len(exts)]))
+		data := strings.Repeat(fmt.Sprintf("line %d\n", i), i%64)
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// This function returns the directories of the given tree in breadth first
+// order up to the given number of directories.
+func benchDirs(root string, limit int) []string {
+	dirs := []string{root}
+	for i := 0; i < len(dirs) && len(dirs) < limit; i++ {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.Type()&fs.ModeSymlink == 0 && e.IsDir() && len(dirs) < limit {
+				dirs = append(dirs, filepath.Join(dirs[i], e.Name()))
+			}
+		}
+	}
+	return dirs
+}
+
+// This function runs the benchmark on the given directory and writes the
+// report to the given writer.
+func runBench(arg string, w io.Writer) error {
+	if gLogPath != "" {
+		f, err := os.OpenFile(gLogPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			log.Fatalf("failed to open log file: %s", err)
+		}
+		defer f.Close()
+		log.SetOutput(f)
+	} else {
+		log.SetOutput(io.Discard)
+	}
+
+	report := benchReport{
+		Version:   gVersion,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		CPUs:      runtime.NumCPU(),
+	}
+
+	files, synthetic, err := parseBenchSynthetic(arg)
+	if err != nil {
+		return err
+	}
+
+	root := arg
+	if synthetic {
+		tmp, err := os.MkdirTemp("", "lf-bench-")
+		if err != nil {
+			return fmt.Errorf("creating synthetic tree: %s", err)
+		}
+		defer os.RemoveAll(tmp)
+		if err := genBenchTree(tmp, files); err != nil {
+			return fmt.Errorf("creating synthetic tree: %s", err)
+		}
+		root = tmp
+	}
+
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	if s, err := os.Stat(root); err != nil {
+		return err
+	} else if !s.IsDir() {
+		return fmt.Errorf("not a directory: %s", root)
+	}
+
+	report.Path = root
+	report.Synthetic = synthetic
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		return fmt.Errorf("initializing screen: %s", err)
+	}
+	defer screen.Fini()
+	screen.SetSize(160, 48)
+
+	ui := newUI(screen)
+	nav := newNav(ui.wins[0].h)
+	app := newApp(ui, nav)
+
+	app.readConfig()
+
+	// directory loads and sorts
+	var loads, sorts []time.Duration
+	for _, path := range benchDirs(root, gBenchMaxDirs) {
+		t := time.Now()
+		d := newDir(path)
+		loads = append(loads, time.Since(t))
+
+		t = time.Now()
+		d.sort()
+		sorts = append(sorts, time.Since(t))
+
+		report.Dirs++
+		report.Files += len(d.allFiles)
+	}
+	report.Load = newBenchStat(loads)
+	report.Sort = newBenchStat(sorts)
+
+	// drawing while moving down the current directory
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	nav.getDirs(root)
+	nav.init = true
+
+	var draws []time.Duration
+	for range gBenchDraws {
+		t := time.Now()
+		ui.draw(nav)
+		draws = append(draws, time.Since(t))
+		nav.down(1)
+	}
+	report.Draw = newBenchStat(draws)
+
+	// previews of regular files, with the previewer script when it is set
+	report.Previewer = gOpts.previewer != ""
+	win := ui.wins[len(ui.wins)-1]
+	var previews []time.Duration
+	for _, f := range nav.currDir().allFiles {
+		if len(previews) == gBenchPreviews {
+			break
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		t := time.Now()
+		go nav.preview(f.path, win, screen)
+		<-nav.regChan
+		previews = append(previews, time.Since(t))
+	}
+	report.Preview = newBenchStat(previews)
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, string(b))
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBenchStat(t *testing.T) {
+	durations := []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond, 6 * time.Millisecond}
+	exp := benchStat{Count: 4, Total: 12, Mean: 3, Min: 1, Max: 6, Median: 3}
+	if got := newBenchStat(durations); got != exp {
+		t.Errorf("expected '%+v' but got '%+v'", exp, got)
+	}
+
+	if got := newBenchStat(nil); got != (benchStat{}) {
+		t.Errorf("expected empty statistics but got '%+v'", got)
+	}
+}
+
+func TestBenchSynthetic(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		arg       string
+		files     int
+		synthetic bool
+		err       bool
+	}{
+		{"synthetic", gBenchSynthFiles, true, false},
+		{"synthetic:500", 500, true, false},
+		{"synthetic:x", 0, true, true},
+		{"synthetic:0", 0, true, true},
+		{dir, 0, false, false},
+		{"other", 0, false, false},
+	}
+
+	for _, test := range tests {
+		files, synthetic, err := parseBenchSynthetic(test.arg)
+		if files != test.files || synthetic != test.synthetic || (err != nil) != test.err {
+			t.Errorf("at input '%s' expected '%d', '%t' and error '%t' but got '%d', '%t' and '%v'",
+				test.arg, test.files, test.synthetic, test.err, files, synthetic, err)
+		}
+	}
+
+	if err := genBenchTree(dir, 40); err != nil {
+		t.Fatalf("generating tree: %s", err)
+	}
+	dirs := benchDirs(dir, gBenchMaxDirs)
+	if len(dirs) != gBenchSynthSubdir+1 || dirs[0] != dir {
+		t.Errorf("expected root and %d subdirectories but got '%v'", gBenchSynthSubdir, dirs)
+	}
+	if got := benchDirs(dir, 3); len(got) != 3 {
+		t.Errorf("expected directories to be limited to 3 but got '%v'", got)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "dir*", "file*"))
+	if len(matches) != 20 {
+		t.Errorf("expected 20 files in subdirectories but got %d", len(matches))
+	}
+}
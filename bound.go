@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"time"
+)
+
+// Motions hitting the boundaries of the file list can be signalled with the
+// terminal bell or a flash of the screen, which is configured separately for
+// each motion with the 'boundbell' and 'boundflash' options. A motion hits a
+// boundary when it stops at the first or the last entry, or when it wraps
+// around the list with the 'wrapscroll' or 'wrapscan' options. Boundaries are
+// only checked after key presses, so that commands sent from scripts or other
+// clients do not cause any feedback.
+
+var gBoundMotions = []string{"up", "down", "search", "find"}
+
+const gFlashDuration = 100 * time.Millisecond
+
+// This function signals the boundary hit by the last motion, if any.
+func (app *app) boundFeedback() {
+	motion := app.nav.boundHit
+	app.nav.boundHit = ""
+	if motion == "" || gHeadless {
+		return
+	}
+
+	if slices.Contains(gOpts.boundbell, motion) {
+		app.ui.screen.Beep()
+	}
+	if slices.Contains(gOpts.boundflash, motion) {
+		app.ui.flash(true)
+	}
+}
+
+// This function turns the reverse video mode of the terminal on or off, which
+// is turned off again after a short while when it is turned on.
+func (ui *ui) flash(on bool) {
+	tty, ok := ui.screen.Tty()
+	if !ok {
+		log.Printf("flash: failed to get tty")
+		return
+	}
+
+	if on {
+		tty.Write([]byte("\033[?5h"))
+		ui.flashTimer.Reset(gFlashDuration)
+	} else {
+		tty.Write([]byte("\033[?5l"))
+	}
+}
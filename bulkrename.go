@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Multiple files can be renamed at once with the 'bulkrename' command, which
+// writes the names of the files to a temporary file, one per line, and opens
+// it with the editor. Files are renamed to the names in the same lines once the
+// editor is closed. The edited names are checked before any file is renamed,
+// and renames are done in two steps using temporary names, so that names can
+// also be swapped. Files already renamed are renamed back when a rename fails,
+// so either all files are renamed or none of them.
+
+type bulkRename struct {
+	src string
+	dst string
+}
+
+// This function returns the names of the given files to be edited, which are
+// relative to the given directory when they are inside it.
+func bulkRenameNames(dir string, paths []string) ([]string, error) {
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		if strings.ContainsAny(path, "\r\n") {
+			return nil, fmt.Errorf("file name contains a newline: %q", path)
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			name = path
+		}
+		names[i] = name
+	}
+	return names, nil
+}
+
+// This function returns the renames for the given edited names of the given
+// files. Files with unchanged names are not renamed. An error is returned when
+// the number of names does not match, a name is empty, multiple files have the
+// same name, or a file would be overwritten.
+func parseBulkRename(dir string, paths []string, data string) ([]bulkRename, error) {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	names := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	if data == "" {
+		names = nil
+	}
+	if len(names) != len(paths) {
+		return nil, fmt.Errorf("expected %d names but got %d", len(paths), len(names))
+	}
+
+	sources := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		sources[path] = true
+	}
+
+	var renames []bulkRename
+	targets := make(map[string]int, len(paths))
+	for i, name := range names {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("empty name at line %d", i+1)
+		}
+
+		dst := name
+		if !filepath.IsAbs(dst) {
+			dst = filepath.Join(dir, dst)
+		}
+		dst = filepath.Clean(dst)
+
+		if j, ok := targets[dst]; ok {
+			return nil, fmt.Errorf("duplicate name at lines %d and %d: %s", j+1, i+1, name)
+		}
+		targets[dst] = i
+
+		src := paths[i]
+		if dst == src {
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Dir(dst)); err != nil {
+			return nil, fmt.Errorf("no such directory at line %d: %s", i+1, filepath.Dir(dst))
+		}
+
+		// files renamed away are not overwritten, nor the same file with a name
+		// differing in case on case-insensitive file systems
+		if lstat, err := os.Lstat(dst); err == nil && !sources[dst] {
+			if slstat, err := os.Lstat(src); err != nil || !os.SameFile(lstat, slstat) {
+				return nil, fmt.Errorf("file exists at line %d: %s", i+1, name)
+			}
+		}
+
+		renames = append(renames, bulkRename{src, dst})
+	}
+
+	return renames, nil
+}
+
+// This function renames the files to temporary names in their directories
+// first, and then to their new names. Renamed files are renamed back when a
+// rename fails.
+func applyBulkRename(renames []bulkRename) error {
+	stamp := time.Now().UnixNano()
+	tmps := make([]string, len(renames))
+	for i, r := range renames {
+		tmps[i] = filepath.Join(filepath.Dir(r.src), fmt.Sprintf(".lf-bulkrename-%d-%d", stamp, i))
+	}
+
+	var done []func() error
+	rollback := func(err error) error {
+		var errs []error
+		for i := len(done) - 1; i >= 0; i-- {
+			if err := done[i](); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) != 0 {
+			return fmt.Errorf("%s (renaming back failed: %s)", err, errors.Join(errs...))
+		}
+		return err
+	}
+
+	for i, r := range renames {
+		if err := os.Rename(r.src, tmps[i]); err != nil {
+			return rollback(err)
+		}
+		done = append(done, func() error { return os.Rename(tmps[i], r.src) })
+	}
+
+	for i, r := range renames {
+		if _, err := os.Lstat(r.dst); err == nil {
+			return rollback(fmt.Errorf("file exists: %s", r.dst))
+		}
+		if err := os.Rename(tmps[i], r.dst); err != nil {
+			return rollback(err)
+		}
+		done = append(done, func() error { return os.Rename(r.dst, tmps[i]) })
+	}
+
+	return nil
+}
+
+// This function returns the selected files, or the files shown in the current
+// directory when there is no selection.
+func (nav *nav) bulkRenamePaths() []string {
+	if paths := nav.currSelections(); len(paths) != 0 {
+		return paths
+	}
+
+	var paths []string
+	for _, f := range nav.currDir().files {
+		paths = append(paths, f.path)
+	}
+	return paths
+}
+
+func (app *app) bulkRename(dryRun bool) {
+	if gHeadless {
+		app.ui.echoerr("bulkrename: not supported in this mode")
+		return
+	}
+
+	paths := app.nav.bulkRenamePaths()
+	if len(paths) == 0 {
+		app.ui.echoerr("bulkrename: no files to rename")
+		return
+	}
+
+	if err := checkWritable(paths...); err != nil {
+		app.ui.echoerrf("bulkrename: %s", err)
+		return
+	}
+
+	dir := app.nav.currDir().path
+	names, err := bulkRenameNames(dir, paths)
+	if err != nil {
+		app.ui.echoerrf("bulkrename: %s", err)
+		return
+	}
+
+	editor := strings.Fields(envEditor)
+	if len(editor) == 0 {
+		app.ui.echoerr("bulkrename: no editor set")
+		return
+	}
+
+	f, err := os.CreateTemp("", "lf-bulkrename-*.txt")
+	if err != nil {
+		app.ui.echoerrf("bulkrename: %s", err)
+		return
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(strings.Join(names, "\n") + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		app.ui.echoerrf("bulkrename: %s", err)
+		return
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := app.runCmdSync(cmd, false); err != nil {
+		return
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		app.ui.echoerrf("bulkrename: %s", err)
+		return
+	}
+
+	renames, err := parseBulkRename(dir, paths, string(data))
+	if err != nil {
+		app.ui.echoerrf("bulkrename: %s", err)
+		return
+	}
+
+	if len(renames) == 0 {
+		app.ui.echo("bulkrename: no files renamed")
+		return
+	}
+
+	if dryRun {
+		plan := make([]string, len(renames))
+		for i, r := range renames {
+			plan[i] = fmt.Sprintf("rename '%s' to '%s'", r.src, r.dst)
+		}
+		app.showDryRun("bulkrename", plan)
+		return
+	}
+
+	srcs := make([]string, len(renames))
+	bytes := make([]int64, len(renames))
+	for i, r := range renames {
+		srcs[i] = r.src
+		bytes[i] = opLogSize(r.src)
+	}
+
+	start := time.Now()
+	err = applyBulkRename(renames)
+
+	errCount := 0
+	var reasons []string
+	if err != nil {
+		errCount = len(renames)
+		reasons = []string{err.Error()}
+	}
+	for i, r := range renames {
+		app.opLog("rename", r.src, r.dst, bytes[i], start, false, reasons)
+	}
+	app.audit("bulkrename", srcs, dir, start, errCount)
+
+	app.nav.unselect()
+	app.reloadAfter("bulkrename")
+
+	if err != nil {
+		app.ui.echoerrf("bulkrename: %s", err)
+		return
+	}
+	app.ui.echo(fmt.Sprintf("\033[0;32mRenamed %d files\033[0m", len(renames)))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseBulkRename(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "x"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")}
+
+	tests := []struct {
+		data string
+		exp  string
+		err  string
+	}{
+		{"a\nb\nc\n", "", ""},
+		{"a\nd\nc\n", "b>d", ""},
+		{"b\na\nc", "a>b b>a", ""},
+		{"a\r\nb\r\ne\r\n", "c>e", ""},
+		{"a\nb\n", "", "expected 3 names but got 2"},
+		{"", "", "expected 3 names but got 0"},
+		{"a\n\nc\n", "", "empty name at line 2"},
+		{"a\nd\nd\n", "", "duplicate name at lines 2 and 3"},
+		{"a\nx\nc\n", "", "file exists at line 2"},
+		{"a\nb\nno/c\n", "", "no such directory at line 3"},
+	}
+
+	for _, test := range tests {
+		renames, err := parseBulkRename(dir, paths, test.data)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("at input '%q' expected error '%s' but got '%v'", test.data, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("at input '%q' expected no error but got '%s'", test.data, err)
+			continue
+		}
+		var got []string
+		for _, r := range renames {
+			got = append(got, filepath.Base(r.src)+">"+filepath.Base(r.dst))
+		}
+		if strings.Join(got, " ") != test.exp {
+			t.Errorf("at input '%q' expected '%s' but got '%s'", test.data, test.exp, strings.Join(got, " "))
+		}
+	}
+}
+
+func TestApplyBulkRename(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(path(name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(exp map[string]string) {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(exp) {
+			t.Errorf("expected %d files but got %d", len(exp), len(entries))
+		}
+		for name, data := range exp {
+			if got, err := os.ReadFile(path(name)); err != nil || string(got) != data {
+				t.Errorf("expected '%s' in '%s' but got '%s' with error '%v'", data, name, got, err)
+			}
+		}
+	}
+
+	// names are swapped through temporary names
+	if err := applyBulkRename([]bulkRename{{path("a"), path("b")}, {path("b"), path("a")}}); err != nil {
+		t.Fatalf("renaming: %s", err)
+	}
+	check(map[string]string{"a": "b", "b": "a", "c": "c"})
+
+	// renames are reverted when a file appears at a destination
+	if err := os.WriteFile(path("e"), []byte("e"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyBulkRename([]bulkRename{{path("a"), path("d")}, {path("c"), path("e")}}); err == nil {
+		t.Error("expected an error for an existing destination")
+	}
+	check(map[string]string{"a": "b", "b": "a", "c": "c", "e": "e"})
+}
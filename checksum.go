@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Checksums of the current or selected files can be computed with the
+// 'checksum' command using MD5, SHA-1 or SHA-256, e.g. to publish them with
+// release files. They are shown in the pager in the format of 'sha256sum' and
+// similar tools, so that they can be yanked from there. Files are hashed in
+// parallel by a pool of workers, and selected directories are included
+// recursively as in 'hashdir'. With '--check', the files are verified against
+// a checksum file (e.g. 'lf-linux.sha256sums') instead, and each file is shown
+// as passed or failed in color as with 'sha256sum -c'.
+
+var gChecksumAlgos = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+type checksum struct {
+	name string
+	sum  []byte
+	err  error
+}
+
+// This function returns the checksums of the given files relative to the given
+// directory in the same order, which are computed by a pool of workers. Read
+// bytes are sent to the given channel for progress.
+func hashFiles(dir string, names []string, newHash func() hash.Hash, nums chan<- int64) []checksum {
+	sums := make([]checksum, len(names))
+	inds := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(runtime.NumCPU(), len(names)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range inds {
+				sum, err := hashFile(filepath.Join(dir, filepath.FromSlash(names[i])), newHash(), nums)
+				sums[i] = checksum{names[i], sum, err}
+			}
+		}()
+	}
+
+	for i := range names {
+		inds <- i
+	}
+	close(inds)
+	wg.Wait()
+
+	return sums
+}
+
+// This function reports whether the given name is a checksum file of the given
+// algorithm, which is either named after the algorithm (e.g. 'SHA256SUMS') or
+// has it in the extension (e.g. 'release.sha256sums').
+func isChecksumFile(name, algo string) bool {
+	name = strings.ToLower(name)
+	return name == algo+"sums" || strings.HasSuffix(name, "."+algo+"sums")
+}
+
+// This function returns the lines shown for the given checksums, and adds the
+// result for each file to the given report. Files are verified against the
+// given checksums read from a checksum file when they are not nil.
+func checksumLines(dir string, sums []checksum, want map[string]string, r *report) []string {
+	lines := make([]string, 0, len(sums))
+	for _, s := range sums {
+		path := filepath.Join(dir, filepath.FromSlash(s.name))
+
+		if want == nil {
+			if s.err != nil {
+				r.add(path, []string{s.err.Error()})
+				lines = append(lines, fmt.Sprintf("\033[31m%s: %s\033[0m", s.name, s.err))
+				continue
+			}
+			r.add(path, nil)
+			lines = append(lines, fmt.Sprintf("%x  %s", s.sum, s.name))
+			continue
+		}
+
+		sum, ok := want[s.name]
+		switch {
+		case !ok:
+			r.skip(path, "not listed in the checksum file")
+			lines = append(lines, fmt.Sprintf("%s: \033[33mNOT LISTED\033[0m", s.name))
+		case s.err != nil:
+			r.add(path, []string{s.err.Error()})
+			lines = append(lines, fmt.Sprintf("%s: \033[31mFAILED open or read\033[0m (%s)", s.name, s.err))
+		case hex.EncodeToString(s.sum) != sum:
+			r.add(path, []string{"checksum mismatch"})
+			lines = append(lines, fmt.Sprintf("%s: \033[31mFAILED\033[0m", s.name))
+		default:
+			r.add(path, nil)
+			lines = append(lines, fmt.Sprintf("%s: \033[32mOK\033[0m", s.name))
+		}
+	}
+	return lines
+}
+
+// This function returns the checksum file of the given algorithm in the given
+// directory, which must be the only one.
+func (app *app) findChecksumFile(algo string) (string, error) {
+	dir := app.nav.currDir()
+
+	var found []string
+	for _, f := range dir.allFiles {
+		if !f.IsDir() && isChecksumFile(f.Name(), algo) {
+			found = append(found, f.path)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no %s checksum file in the current directory", algo)
+	case 1:
+		return found[0], nil
+	}
+	return "", errors.New("multiple checksum files in the current directory, give one as an argument")
+}
+
+// This function computes the checksums of the current or selected files, or
+// verifies them against the given checksum file with '--check'. All files in
+// the checksum file are verified when there is no selection.
+func (app *app) checksum(algo string, check bool, sumsPath string) {
+	newHash, ok := gChecksumAlgos[algo]
+	if !ok {
+		app.ui.echoerrf("checksum: unknown algorithm '%s', expected 'md5', 'sha1' or 'sha256'", algo)
+		return
+	}
+
+	dir := app.nav.currDir().path
+
+	var paths []string
+	if check {
+		if sumsPath == "" {
+			var err error
+			if sumsPath, err = app.findChecksumFile(algo); err != nil {
+				app.ui.echoerrf("checksum: %s", err)
+				return
+			}
+		} else if !filepath.IsAbs(sumsPath) {
+			sumsPath = filepath.Join(dir, sumsPath)
+		}
+		dir = filepath.Dir(sumsPath)
+		paths = app.nav.currSelections()
+	} else {
+		var err error
+		if paths, err = app.nav.currFileOrSelections(); err != nil {
+			app.ui.echoerrf("checksum: %s", err)
+			return
+		}
+	}
+
+	queued := slices.Clone(paths)
+	if check {
+		queued = append(queued, sumsPath)
+	}
+
+	app.runQueued(queued, func() {
+		start := time.Now()
+		op := "hash"
+		if check {
+			op = "verify"
+		}
+		r := newReport(op, dir)
+		r.detail = algo
+
+		var want map[string]string
+		var names []string
+		var total int64
+		var err error
+		if check {
+			want, err = readChecksums(sumsPath)
+		}
+		switch {
+		case err != nil:
+		case len(paths) == 0:
+			for name := range want {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			total = manifestSize(dir, want)
+		default:
+			names, total, err = hashList(dir, paths)
+			names = slices.DeleteFunc(names, func(name string) bool {
+				return filepath.Join(dir, filepath.FromSlash(name)) == sumsPath
+			})
+		}
+
+		if err != nil {
+			app.audit(op, paths, dir, start, 1)
+			app.ui.exprChan <- &callExpr{"echoerr", []string{"checksum: " + err.Error()}, 1}
+			return
+		}
+
+		app.nav.copyTotalChan <- total
+		sums := hashFiles(dir, names, newHash, app.nav.copyBytesChan)
+		app.nav.copyTotalChan <- -total
+
+		lines := checksumLines(dir, sums, want, r)
+		app.audit(op, paths, dir, start, len(r.failed))
+		app.nav.reports.set(r)
+		app.nav.checksums.set("checksum: "+r.summary(), lines)
+		app.ui.exprChan <- &callExpr{"checksum-results", nil, 1}
+
+		if check && !r.ok() {
+			msg := fmt.Sprintf("checksum: %d of %d files failed verification", len(r.failed)+len(r.skipped), len(names))
+			app.ui.exprChan <- &callExpr{"echoerr", []string{msg}, 1}
+		}
+	})
+}
+
+func (app *app) checksumResults() {
+	title, lines := app.nav.checksums.get()
+	if lines == nil {
+		app.ui.echoerr("checksum-results: no checksums computed yet")
+		return
+	}
+
+	if gHeadless {
+		for _, line := range lines {
+			app.ui.echomsg(line)
+		}
+		return
+	}
+
+	app.ui.pager = newPager(title, strings.Join(lines, "\n"))
+}
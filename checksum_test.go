@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsChecksumFile(t *testing.T) {
+	tests := []struct {
+		name string
+		algo string
+		exp  bool
+	}{
+		{"SHA256SUMS", "sha256", true},
+		{"lf-linux.sha256sums", "sha256", true},
+		{"lf-linux.SHA1SUMS", "sha1", true},
+		{"MD5SUMS", "sha256", false},
+		{"sha256sums.txt", "sha256", false},
+		{"xsha256sums", "sha256", false},
+	}
+
+	for _, test := range tests {
+		if got := isChecksumFile(test.name, test.algo); got != test.exp {
+			t.Errorf("at input '%s' with '%s' expected '%t' but got '%t'", test.name, test.algo, test.exp, got)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"a": "foo", "b": "bar", "c": "baz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := []string{"a", "b", "missing"}
+	exp := map[string][]string{
+		"md5":    {"acbd18db4cc2f85cedef654fccc4a4d8", "37b51d194a7513e45b56f6524f2d51f2"},
+		"sha1":   {"0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33", "62cdb7020ff920e5aa642c3d4066950dd1f01f4d"},
+		"sha256": {"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"},
+	}
+	for algo, want := range exp {
+		nums, wait := drainNums()
+		sums := hashFiles(dir, names, gChecksumAlgos[algo], nums)
+		if read := wait(); read != 6 {
+			t.Errorf("with '%s' expected 6 bytes read but got %d", algo, read)
+		}
+
+		r := newReport("hash", dir)
+		lines := checksumLines(dir, sums, nil, r)
+		for i, sum := range want {
+			if line := sum + "  " + names[i]; lines[i] != line {
+				t.Errorf("with '%s' expected '%s' but got '%s'", algo, line, lines[i])
+			}
+		}
+		if r.done != 2 || len(r.failed) != 1 || !strings.Contains(lines[2], "missing") {
+			t.Errorf("with '%s' expected the missing file to fail but got '%s'", algo, r.summary())
+		}
+	}
+
+	nums, wait := drainNums()
+	sums := hashFiles(dir, []string{"a", "b", "c", "missing"}, gChecksumAlgos["sha256"], nums)
+	wait()
+
+	want := map[string]string{
+		"a":       exp["sha256"][0],
+		"b":       exp["sha256"][0],
+		"missing": exp["sha256"][0],
+	}
+	r := newReport("verify", dir)
+	lines := checksumLines(dir, sums, want, r)
+	results := []string{"OK", "FAILED", "NOT LISTED", "FAILED open or read"}
+	for i, res := range results {
+		if !strings.Contains(lines[i], res) {
+			t.Errorf("at line %d expected '%s' but got '%s'", i, res, lines[i])
+		}
+	}
+	if r.done != 1 || len(r.failed) != 2 || len(r.skipped) != 1 {
+		t.Errorf("expected 1 verified, 1 skipped and 2 failed but got '%s'", r.summary())
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The 'cleanup' command is a guided workflow to clean up directories filling
+// up over time such as downloads. The files in the tree of the current
+// directory are grouped into buckets by their ages, which are based on their
+// modification times, or their access times with '--atime'. Files in some
+// buckets and/or matching some patterns are then staged with 'cleanup-stage',
+// which shows the plan in the pager to be reviewed, and the staged files are
+// moved to the trash with 'cleanup-trash', so that they can be restored with
+// 'restore' or 'undo' afterwards. Files changed after the scan are left out.
+
+const gDay = 24 * time.Hour
+
+type cleanupBucket struct {
+	name string
+	desc string
+	max  time.Duration
+}
+
+// Buckets are sorted by their maximum ages, and the last one has no maximum.
+var gCleanupBuckets = []cleanupBucket{
+	{"week", "less than a week", 7 * gDay},
+	{"month", "a week to a month", 30 * gDay},
+	{"quarter", "1 to 3 months", 90 * gDay},
+	{"year", "3 months to a year", 365 * gDay},
+	{"older", "more than a year", 0},
+}
+
+type cleanupFile struct {
+	path  string
+	size  int64
+	mtime time.Time
+	time  time.Time
+}
+
+type cleanupScan struct {
+	dir     string
+	atime   bool
+	now     time.Time
+	files   []cleanupFile
+	skipped int
+}
+
+// This function returns the index of the bucket of the given age.
+func cleanupBucketOf(age time.Duration) int {
+	for i, b := range gCleanupBuckets {
+		if b.max == 0 || age < b.max {
+			return i
+		}
+	}
+	return len(gCleanupBuckets) - 1
+}
+
+// This function walks the given directory recursively and returns its files
+// with their ages.
+func scanCleanup(path string, atime bool, cancel <-chan struct{}) (*cleanupScan, error) {
+	scan := &cleanupScan{dir: path, atime: atime, now: time.Now()}
+
+	var err error
+	_, scan.skipped, err = walkFiles(path, cancel, func(p string, info os.FileInfo) {
+		f := cleanupFile{p, info.Size(), info.ModTime(), info.ModTime()}
+		if atime {
+			f.time = fileAccessTime(info)
+		}
+		scan.files = append(scan.files, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return scan, nil
+}
+
+func (scan *cleanupScan) title() string {
+	basis := "modification"
+	if scan.atime {
+		basis = "access"
+	}
+	title := fmt.Sprintf("cleanup: %s (%d files by %s time)", scan.dir, len(scan.files), basis)
+	if scan.skipped > 0 {
+		title += fmt.Sprintf(", %d unreadable", scan.skipped)
+	}
+	return title
+}
+
+// This function returns the lines shown for the buckets of the scan.
+func (scan *cleanupScan) lines() []string {
+	counts := make([]int, len(gCleanupBuckets))
+	sizes := make([]int64, len(gCleanupBuckets))
+	for _, f := range scan.files {
+		i := cleanupBucketOf(scan.now.Sub(f.time))
+		counts[i]++
+		sizes[i] += f.size
+	}
+
+	lines := []string{fmt.Sprintf("%-8s %-20s %8s %8s", "bucket", "age", "files", "size")}
+	for i, b := range gCleanupBuckets {
+		lines = append(lines, fmt.Sprintf("%-8s %-20s %8d %8s", b.name, b.desc, counts[i], humanize(sizes[i])))
+	}
+	lines = append(lines, "", "Stage files with 'cleanup-stage', e.g. 'cleanup-stage year older *.iso'.")
+	return lines
+}
+
+// This function returns the files of the scan in the given buckets and
+// matching the given patterns, from the oldest. Arguments naming buckets are
+// buckets and others are patterns, which are matched against the names of the
+// files, or their paths relative to the scanned directory when they contain a
+// path separator. All buckets are used when no bucket is given, and all files
+// match when no pattern is given.
+func (scan *cleanupScan) stage(args []string) ([]cleanupFile, error) {
+	buckets := make(map[int]bool)
+	var patterns []string
+	for _, arg := range args {
+		i := slices.IndexFunc(gCleanupBuckets, func(b cleanupBucket) bool { return b.name == arg })
+		if i >= 0 {
+			buckets[i] = true
+			continue
+		}
+		if _, err := filepath.Match(arg, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern: %q", arg)
+		}
+		patterns = append(patterns, arg)
+	}
+
+	var staged []cleanupFile
+	for _, f := range scan.files {
+		if len(buckets) > 0 && !buckets[cleanupBucketOf(scan.now.Sub(f.time))] {
+			continue
+		}
+		if len(patterns) > 0 && !slices.ContainsFunc(patterns, func(pattern string) bool {
+			name := filepath.Base(f.path)
+			if strings.ContainsRune(pattern, filepath.Separator) {
+				name, _ = filepath.Rel(scan.dir, f.path)
+			}
+			ok, _ := filepath.Match(pattern, name)
+			return ok
+		}) {
+			continue
+		}
+		staged = append(staged, f)
+	}
+
+	slices.SortStableFunc(staged, func(a, b cleanupFile) int {
+		return a.time.Compare(b.time)
+	})
+	return staged, nil
+}
+
+// This function returns the lines shown for the given staged files.
+func (scan *cleanupScan) planLines(staged []cleanupFile) []string {
+	lines := make([]string, 0, len(staged)+2)
+	for _, f := range staged {
+		rel, err := filepath.Rel(scan.dir, f.path)
+		if err != nil {
+			rel = f.path
+		}
+		lines = append(lines, fmt.Sprintf("%s %8s  %s", f.time.Format(time.DateOnly), humanize(f.size), rel))
+	}
+	return append(lines, "", "Move them to the trash with 'cleanup-trash'.")
+}
+
+func cleanupTotal(files []cleanupFile) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	return total
+}
+
+// The last scan is written by the walk running in the background, so it is
+// kept behind a lock.
+type cleanupLog struct {
+	mutex sync.Mutex
+	scan  *cleanupScan
+}
+
+func (l *cleanupLog) set(scan *cleanupScan) {
+	l.mutex.Lock()
+	l.scan = scan
+	l.mutex.Unlock()
+}
+
+func (l *cleanupLog) get() *cleanupScan {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.scan
+}
+
+func (app *app) cleanup(atime bool) {
+	path := app.nav.currDir().path
+	app.nav.cleanupPlan = nil
+
+	if gHeadless {
+		scan, err := scanCleanup(path, atime, nil)
+		if err != nil {
+			app.ui.echoerrf("cleanup: %s", err)
+			return
+		}
+		app.nav.cleanupScan.set(scan)
+		app.cleanupStage(nil)
+		return
+	}
+
+	if app.nav.cleanupCancel != nil {
+		close(app.nav.cleanupCancel)
+	}
+	cancel := make(chan struct{})
+	app.nav.cleanupCancel = cancel
+
+	go func() {
+		scan, err := scanCleanup(path, atime, cancel)
+		if err == errDirSizeCancelled {
+			return
+		}
+		if err != nil {
+			app.ui.exprChan <- &callExpr{"echoerr", []string{"cleanup: " + err.Error()}, 1}
+			return
+		}
+		app.nav.cleanupScan.set(scan)
+		app.ui.exprChan <- &callExpr{"cleanup-stage", nil, 1}
+	}()
+
+	app.ui.echomsg("cleanup: walking " + path)
+}
+
+func (app *app) cancelCleanup() {
+	if app.nav.cleanupCancel == nil {
+		app.ui.echoerr("cleanup: no directory is being walked")
+		return
+	}
+	close(app.nav.cleanupCancel)
+	app.nav.cleanupCancel = nil
+}
+
+// This function stages the files of the last scan in the given buckets and
+// matching the given patterns, or shows the buckets again when there is no
+// argument.
+func (app *app) cleanupStage(args []string) {
+	scan := app.nav.cleanupScan.get()
+	if scan == nil {
+		app.ui.echoerr("cleanup-stage: no directory scanned yet, run 'cleanup' first")
+		return
+	}
+
+	title, lines := scan.title(), scan.lines()
+	if len(args) > 0 {
+		staged, err := scan.stage(args)
+		if err != nil {
+			app.ui.echoerrf("cleanup-stage: %s", err)
+			return
+		}
+		if len(staged) == 0 {
+			app.nav.cleanupPlan = nil
+			app.ui.echoerr("cleanup-stage: no files found")
+			return
+		}
+		app.nav.cleanupPlan = staged
+		title = fmt.Sprintf("cleanup-stage: %d files (%s) staged for trash", len(staged), humanize(cleanupTotal(staged)))
+		lines = scan.planLines(staged)
+	}
+
+	if gHeadless {
+		app.ui.echomsg(title)
+		for _, line := range lines {
+			app.ui.echomsg(line)
+		}
+		return
+	}
+
+	app.ui.pager = newPager(title, strings.Join(lines, "\n"))
+}
+
+// This function moves the staged files to the trash, leaving out the files
+// changed or removed after the scan.
+func (app *app) cleanupTrash(dryRun bool) {
+	if len(app.nav.cleanupPlan) == 0 {
+		app.ui.echoerr("cleanup-trash: no files staged, run 'cleanup-stage' first")
+		return
+	}
+
+	var list []string
+	changed := 0
+	for _, f := range app.nav.cleanupPlan {
+		lstat, err := os.Lstat(f.path)
+		if err != nil || lstat.Size() != f.size || !lstat.ModTime().Equal(f.mtime) {
+			changed++
+			continue
+		}
+		list = append(list, f.path)
+	}
+
+	if dryRun {
+		plan := trashPlan(list)
+		if changed > 0 {
+			plan = append(plan, fmt.Sprintf("leave out %d files changed after the scan", changed))
+		}
+		app.showDryRun("cleanup-trash", plan)
+		return
+	}
+
+	if err := checkWritable(list...); err != nil {
+		app.ui.echoerrf("cleanup-trash: %s", err)
+		return
+	}
+
+	app.nav.cleanupPlan = nil
+	if len(list) > 0 {
+		app.trashFiles(list)
+	}
+
+	if changed > 0 {
+		app.ui.echoerrf("cleanup-trash: %d files changed after the scan are left out", changed)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanupBucketOf(t *testing.T) {
+	tests := []struct {
+		age time.Duration
+		exp string
+	}{
+		{0, "week"},
+		{6 * gDay, "week"},
+		{7 * gDay, "month"},
+		{45 * gDay, "quarter"},
+		{200 * gDay, "year"},
+		{365 * gDay, "older"},
+		{3650 * gDay, "older"},
+	}
+
+	for _, test := range tests {
+		if got := gCleanupBuckets[cleanupBucketOf(test.age)].name; got != test.exp {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.age, test.exp, got)
+		}
+	}
+}
+
+func TestCleanupStage(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	ages := map[string]time.Duration{
+		"new.iso":       gDay,
+		"old.iso":       400 * gDay,
+		"old.txt":       500 * gDay,
+		"sub/mid.iso":   100 * gDay,
+		"sub/older.zip": 600 * gDay,
+	}
+	for name, age := range ages {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan, err := scanCleanup(dir, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scan.files) != len(ages) {
+		t.Fatalf("expected %d files but got %d", len(ages), len(scan.files))
+	}
+
+	tests := []struct {
+		args []string
+		exp  []string
+	}{
+		{[]string{"older"}, []string{"sub/older.zip", "old.txt", "old.iso"}},
+		{[]string{"*.iso"}, []string{"old.iso", "sub/mid.iso", "new.iso"}},
+		{[]string{"year", "older", "*.iso"}, []string{"old.iso", "sub/mid.iso"}},
+		{[]string{"sub/*"}, []string{"sub/older.zip", "sub/mid.iso"}},
+		{[]string{"week", "*.txt"}, nil},
+	}
+
+	for _, test := range tests {
+		staged, err := scan.stage(test.args)
+		if err != nil {
+			t.Errorf("at input '%v' expected no error but got '%s'", test.args, err)
+			continue
+		}
+		var got []string
+		for _, f := range staged {
+			rel, _ := filepath.Rel(dir, f.path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if len(got) != len(test.exp) {
+			t.Errorf("at input '%v' expected '%v' but got '%v'", test.args, test.exp, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.exp[i] {
+				t.Errorf("at input '%v' expected '%v' but got '%v'", test.args, test.exp, got)
+				break
+			}
+		}
+	}
+
+	if _, err := scan.stage([]string{"[a-"}); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
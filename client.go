@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -69,14 +70,34 @@ func run() {
 
 	app.ui.screen.Fini()
 
+	if app.terminated {
+		if app.nav.init {
+			app.writeExitFiles()
+		}
+		os.Exit(3)
+	}
+
+	if app.quitShell {
+		app.writeExitFiles()
+		if err := app.quitIntoShell(); err != nil {
+			fmt.Fprintf(os.Stderr, "cd-and-quit-into-shell: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if gAutocd {
-		targetPath := app.nav.currDir().path
-		
+		targetPath := app.lastDir()
+
 		// If current path is a file, use parent directory
 		if info, err := os.Stat(targetPath); err == nil && !info.IsDir() {
 			targetPath = filepath.Dir(targetPath)
 		}
-		
+
+		// Selected files are written before changing the directory so that
+		// a file can be picked and its directory entered at the same time
+		app.writeSelectionFiles()
+
 		autocd.ExitWithDirectoryOrFallback(targetPath, func() {
 			// Fallback to normal lf exit behavior if autocd fails
 			os.Exit(0)
@@ -85,19 +106,67 @@ func run() {
 		return
 	}
 
-	if gLastDirPath != "" {
-		writeLastDir(gLastDirPath, app.nav.currDir().path)
+	app.writeExitFiles()
+}
+
+// This function replaces lf with the shell in '$SHELL' started in the current
+// directory, so that the shell is not nested in lf and its exit status is
+// returned to the terminal as if it was started instead of lf.
+func (app *app) quitIntoShell() error {
+	if err := os.Chdir(app.nav.currDir().path); err != nil {
+		log.Printf("quit into shell: %s", err)
+	}
+
+	if envLevel == "0" {
+		os.Unsetenv("LF_LEVEL")
+	} else {
+		os.Setenv("LF_LEVEL", envLevel)
+	}
+
+	return execShell(envShell)
+}
+
+// This function returns the directory to report on exit, which is the
+// current directory unless '-autocd-mode file-dir' is given. In that mode,
+// when a file is selected with '-print-selection' or '-selection-path', this
+// is the directory containing the (first) selected file so that the shell can
+// be moved next to it, and otherwise it is the directory containing the most
+// recently selected file when there is a selection.
+func (app *app) lastDir() string {
+	if gAutocdMode != "file-dir" {
+		return app.nav.currDir().path
+	}
+	if len(app.selectionOut) > 0 {
+		return filepath.Dir(app.selectionOut[0])
 	}
+	if path := app.nav.lastSelection(); path != "" {
+		return filepath.Dir(path)
+	}
+	return app.nav.currDir().path
+}
 
-	if gSelectionPath != "" && len(app.selectionOut) > 0 {
-		writeSelection(gSelectionPath, app.selectionOut)
+// This function is used to write the last directory and the selection to the
+// files and the standard output as requested on the command line.
+func (app *app) writeExitFiles() {
+	if gLastDirPath != "" {
+		writeLastDir(gLastDirPath, app.lastDir())
 	}
 
 	if gPrintLastDir {
-		fmt.Println(app.nav.currDir().path)
+		fmt.Println(formatLastDir(app.lastDir(), gLastDirFormat))
 	}
 
-	if gPrintSelection && len(app.selectionOut) > 0 {
+	app.writeSelectionFiles()
+}
+
+// This function prints the selection with '-print-selection' after the UI
+// is closed. The selection file is already written on quit.
+func (app *app) writeSelectionFiles() {
+	if len(app.selectionOut) == 0 {
+		return
+	}
+
+	if gPrintSelection {
 		for _, file := range app.selectionOut {
 			fmt.Println(file)
 		}
@@ -112,24 +181,83 @@ func writeLastDir(filename string, lastDir string) {
 	}
 	defer f.Close()
 
-	_, err = f.WriteString(lastDir)
+	_, err = f.WriteString(formatLastDir(lastDir, gLastDirFormat))
 	if err != nil {
 		log.Printf("writing last dir file: %s", err)
 	}
 }
 
-func writeSelection(filename string, selection []string) {
+// This function is used to report the current directory on each directory
+// change when the 'livecd' option is enabled, both by writing the last dir
+// file and by sending an OSC 7 escape sequence to the terminal.
+func (app *app) writeLiveCd() {
+	path := app.nav.currDir().path
+
+	if gLastDirPath != "" {
+		writeLastDir(gLastDirPath, path)
+	}
+
+	if tty, ok := app.ui.screen.Tty(); ok {
+		io.WriteString(tty, osc7(gHostname, path))
+	}
+}
+
+func writeSelection(filename string, selection []string) error {
 	f, err := os.Create(filename)
 	if err != nil {
-		log.Printf("opening selection file: %s", err)
-		return
+		return fmt.Errorf("opening selection file: %s", err)
 	}
 	defer f.Close()
 
-	_, err = f.WriteString(strings.Join(selection, "\n"))
-	if err != nil {
-		log.Printf("writing selection file: %s", err)
+	if _, err := f.WriteString(strings.Join(selection, "\n")); err != nil {
+		return fmt.Errorf("writing selection file: %s", err)
 	}
+
+	return nil
+}
+
+// This function returns the size of the selection in bytes as it is written
+// to the selection file, or printed with '-print-selection' otherwise.
+func selectionSize(selection []string) int {
+	size := 0
+	for _, path := range selection {
+		size += len(path) + 1
+	}
+	if gSelectionPath != "" {
+		size--
+	}
+	return size
+}
+
+// This function writes the selection file on quit while the UI is still
+// running, and then runs the 'selection-written' command with the id of the
+// client, the number of selected files, the size of the selection in bytes
+// and the selection file (if any), so that the 'on-selection-written' command
+// can check or change the selection before lf exits and the caller reads it.
+// The arguments are returned to be sent to other clients as well.
+func (app *app) writeSelectionFile() []string {
+	if len(app.selectionOut) == 0 || (gSelectionPath == "" && !gPrintSelection) {
+		return nil
+	}
+
+	if gSelectionPath != "" {
+		if err := writeSelection(gSelectionPath, app.selectionOut); err != nil {
+			log.Print(err)
+			return nil
+		}
+	}
+
+	args := []string{
+		strconv.Itoa(gClientID),
+		strconv.Itoa(len(app.selectionOut)),
+		strconv.Itoa(selectionSize(app.selectionOut)),
+	}
+	if gSelectionPath != "" {
+		args = append(args, gSelectionPath)
+	}
+
+	(&callExpr{"selection-written", args, 1}).eval(app, nil)
+	return args
 }
 
 func readExpr() <-chan expr {
@@ -160,6 +288,9 @@ func readExpr() <-chan expr {
 			// This is important since `query` is often the result of the user
 			// running `$lf -remote "query $id <something>"`.
 			if word, rest := splitWord(s.Text()); word == "query" {
+				if word2, rest2 := splitWord(rest); word2 == "json" {
+					rest = "json " + rest2
+				}
 				gState.mutex.Lock()
 				state, ok := gState.data[rest]
 				gState.mutex.Unlock()
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelectionSize(t *testing.T) {
+	defer func(path string) { gSelectionPath = path }(gSelectionPath)
+
+	selection := []string{"/tmp/foo", "/tmp/bar baz"}
+
+	gSelectionPath = filepath.Join(t.TempDir(), "selection")
+	if err := writeSelection(gSelectionPath, selection); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(gSelectionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := selectionSize(selection); int64(got) != stat.Size() {
+		t.Errorf("expected size of the selection file '%d' but got '%d'", stat.Size(), got)
+	}
+
+	// printed files are each followed by a newline
+	gSelectionPath = ""
+	if got := selectionSize(selection); got != 22 {
+		t.Errorf("expected size of the printed selection '22' but got '%d'", got)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The copy/cut buffer is recorded in a history on the server each time files
+// are copied or cut, so that an earlier buffer can be activated again with the
+// 'clipboard-history' command after it is replaced by accident. The history
+// is shared between clients and only kept in memory while the server is
+// running, and the number of kept buffers is given by the 'clipboardhistory'
+// option of the client recording them. In single mode, the history is kept in
+// the client instead.
+//
+// Buffers are sent to and from the server with a header line with the
+// operation, the time and the number of files, followed by the paths.
+
+type clipboardEntry struct {
+	cp    bool
+	time  time.Time
+	paths []string
+}
+
+func (e clipboardEntry) op() string {
+	if e.cp {
+		return "copy"
+	}
+	return "move"
+}
+
+// This function returns the name of the command of the buffer.
+func (e clipboardEntry) cmd() string {
+	if e.cp {
+		return "copy"
+	}
+	return "cut"
+}
+
+type clipboardHistory struct {
+	mutex   sync.Mutex
+	entries []clipboardEntry
+}
+
+var gClipboard clipboardHistory
+
+// This function adds the given buffer to the top of the history, keeping at
+// most the given number of buffers. An equal buffer already in the history is
+// moved to the top instead of being added again.
+func (h *clipboardHistory) push(e clipboardEntry, size int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.entries = slices.DeleteFunc(h.entries, func(old clipboardEntry) bool {
+		return old.cp == e.cp && slices.Equal(old.paths, e.paths)
+	})
+	h.entries = slices.Insert(h.entries, 0, e)
+	if len(h.entries) > size {
+		h.entries = h.entries[:size]
+	}
+}
+
+// This function returns the buffers from the most recent one.
+func (h *clipboardHistory) list() []clipboardEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return slices.Clone(h.entries)
+}
+
+func writeClipboardEntry(w io.Writer, e clipboardEntry) {
+	fmt.Fprintf(w, "%s %d %d\n", e.op(), e.time.Unix(), len(e.paths))
+	for _, path := range e.paths {
+		fmt.Fprintln(w, path)
+	}
+}
+
+// This function reads the buffer with the given header line, reading its
+// paths with the given scanner.
+func readClipboardEntry(header string, s *bufio.Scanner) (clipboardEntry, error) {
+	toks := strings.Fields(header)
+	if len(toks) != 3 || (toks[0] != "copy" && toks[0] != "move") {
+		return clipboardEntry{}, fmt.Errorf("invalid header: %q", header)
+	}
+	sec, err := strconv.ParseInt(toks[1], 10, 64)
+	if err != nil {
+		return clipboardEntry{}, fmt.Errorf("invalid time: %q", toks[1])
+	}
+	n, err := strconv.Atoi(toks[2])
+	if err != nil || n < 0 {
+		return clipboardEntry{}, fmt.Errorf("invalid number of files: %q", toks[2])
+	}
+
+	e := clipboardEntry{cp: toks[0] == "copy", time: time.Unix(sec, 0)}
+	for range n {
+		if !s.Scan() {
+			return clipboardEntry{}, fmt.Errorf("missing files: %v", s.Err())
+		}
+		e.paths = append(e.paths, s.Text())
+	}
+
+	return e, nil
+}
+
+// This function records the given buffer in the history. Buffers with names
+// containing newlines are not recorded as they can not be sent to the server.
+func recordClipboard(list []string, cp bool) {
+	if gOpts.clipboardhistory == 0 || len(list) == 0 {
+		return
+	}
+	if slices.ContainsFunc(list, func(path string) bool { return strings.ContainsAny(path, "\r\n") }) {
+		log.Printf("clipboard: not recording file names with newlines")
+		return
+	}
+
+	e := clipboardEntry{cp, time.Now(), list}
+	if gSingleMode {
+		gClipboard.push(e, gOpts.clipboardhistory)
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "clipboard-push %d\n", gOpts.clipboardhistory)
+	writeClipboardEntry(&b, e)
+	if err := remote(strings.TrimSuffix(b.String(), "\n")); err != nil {
+		log.Printf("clipboard: %s", err)
+	}
+}
+
+// This function returns the history from the server, or the history of the
+// client in single mode.
+func readClipboard() ([]clipboardEntry, error) {
+	if gSingleMode {
+		return gClipboard.list(), nil
+	}
+
+	c, err := net.Dial(gSocketProt, gSocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("dialing to read clipboard history: %s", err)
+	}
+	defer c.Close()
+
+	fmt.Fprintln(c, "clipboard")
+	if v, ok := c.(interface {
+		CloseWrite() error
+	}); ok {
+		v.CloseWrite()
+	}
+
+	var entries []clipboardEntry
+	s := bufio.NewScanner(c)
+	for s.Scan() {
+		e, err := readClipboardEntry(s.Text(), s)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// This function returns the lines shown for the history, with the number of
+// each buffer to activate it and the files missing since they are recorded.
+func clipboardLines(entries []clipboardEntry) []string {
+	var lines []string
+	for i, e := range entries {
+		line := fmt.Sprintf("%d  %-4s  %s  %d files", i+1, e.cmd(), e.time.Format(time.DateTime), len(e.paths))
+		var paths []string
+		missing := 0
+		for _, path := range e.paths {
+			if _, err := os.Lstat(path); err != nil {
+				missing++
+				path += " (missing)"
+			}
+			paths = append(paths, "    "+path)
+		}
+		if missing > 0 {
+			line += fmt.Sprintf(" (%d missing)", missing)
+		}
+		lines = append(lines, line)
+		lines = append(lines, paths...)
+	}
+	return lines
+}
+
+// This function shows the history, or activates the buffer with the given
+// number in the history again.
+func (app *app) clipboardHistory(args []string) {
+	if len(args) > 1 {
+		app.ui.echoerr("clipboard-history: usage: clipboard-history [number]")
+		return
+	}
+
+	entries, err := readClipboard()
+	if err != nil {
+		app.ui.echoerrf("clipboard-history: %s", err)
+		return
+	}
+	if len(entries) == 0 {
+		app.ui.echoerr("clipboard-history: no files copied or cut yet")
+		return
+	}
+
+	if len(args) == 0 {
+		lines := clipboardLines(entries)
+		if gHeadless {
+			for _, line := range lines {
+				app.ui.echomsg(line)
+			}
+			return
+		}
+		title := fmt.Sprintf("clipboard-history (%d buffers, activate with 'clipboard-history <number>')", len(entries))
+		app.ui.pager = newPager(title, strings.Join(lines, "\n"))
+		return
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > len(entries) {
+		app.ui.echoerrf("clipboard-history: number should be between 1 and %d", len(entries))
+		return
+	}
+	e := entries[n-1]
+
+	if err := saveFiles(e.paths, e.cp); err != nil {
+		app.ui.echoerrf("clipboard-history: %s", err)
+		return
+	}
+	recordClipboard(e.paths, e.cp)
+
+	if gSingleMode {
+		if err := app.nav.sync(); err != nil {
+			app.ui.echoerrf("clipboard-history: %s", err)
+			return
+		}
+	} else {
+		if err := remote("send sync"); err != nil {
+			app.ui.echoerrf("clipboard-history: %s", err)
+			return
+		}
+	}
+	app.ui.loadFileInfo(app.nav)
+	app.ui.echo(fmt.Sprintf("clipboard-history: %d files to %s", len(e.paths), e.cmd()))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClipboardHistory(t *testing.T) {
+	var h clipboardHistory
+	now := time.Now()
+
+	h.push(clipboardEntry{true, now, []string{"/a"}}, 3)
+	h.push(clipboardEntry{false, now, []string{"/b", "/c"}}, 3)
+	h.push(clipboardEntry{true, now, []string{"/d"}}, 3)
+	h.push(clipboardEntry{true, now, []string{"/a"}}, 3)
+
+	var got []string
+	for _, e := range h.list() {
+		got = append(got, fmt.Sprintf("%s %v", e.cmd(), e.paths))
+	}
+	if exp := []string{"copy [/a]", "copy [/d]", "cut [/b /c]"}; !slices.Equal(got, exp) {
+		t.Errorf("expected '%v' but got '%v'", exp, got)
+	}
+
+	h.push(clipboardEntry{false, now, []string{"/e"}}, 2)
+	if entries := h.list(); len(entries) != 2 || entries[0].paths[0] != "/e" || entries[1].paths[0] != "/a" {
+		t.Errorf("expected the oldest buffers to be dropped but got '%v'", entries)
+	}
+}
+
+func TestClipboardServer(t *testing.T) {
+	defer func(entries []clipboardEntry) {
+		gClipboard.entries = entries
+	}(gClipboard.entries)
+	gClipboard.entries = nil
+
+	c1, c2 := net.Pipe()
+	go handleConn(c2)
+
+	sec := time.Now().Unix()
+	fmt.Fprintln(c1, "clipboard-push 10")
+	fmt.Fprintf(c1, "move %d 2\n", sec)
+	fmt.Fprintln(c1, "/tmp/foo bar")
+	fmt.Fprintln(c1, "/tmp/baz")
+	fmt.Fprintln(c1, "clipboard")
+
+	s := bufio.NewScanner(c1)
+	if !s.Scan() {
+		t.Fatalf("reading clipboard history: %v", s.Err())
+	}
+	e, err := readClipboardEntry(s.Text(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.cp || e.time.Unix() != sec || !slices.Equal(e.paths, []string{"/tmp/foo bar", "/tmp/baz"}) {
+		t.Errorf("expected the pushed buffer but got '%v'", e)
+	}
+
+	c1.Close()
+}
@@ -11,14 +11,23 @@ import (
 	"github.com/gdamore/tcell/v2"
 )
 
+// Origins of the entries in colors and icons are kept to be able to explain
+// matches with the 'colortest' command.
+type ruleOrigin struct {
+	source string // e.g. '$LS_COLORS' or '~/.config/lf/colors:3'
+	value  string
+}
+
 type styleMap struct {
 	styles        map[string]tcell.Style
+	origins       map[string]ruleOrigin
 	useLinkTarget bool
 }
 
 func parseStyles() styleMap {
 	sm := styleMap{
 		styles:        make(map[string]tcell.Style),
+		origins:       make(map[string]ruleOrigin),
 		useLinkTarget: false,
 	}
 
@@ -65,18 +74,18 @@ func parseStyles() styleMap {
 		"ex=01;32",
 	}
 
-	sm.parseGNU(strings.Join(defaultColors, ":"))
+	sm.parseGNU(strings.Join(defaultColors, ":"), "default")
 
 	if env := os.Getenv("LSCOLORS"); env != "" {
 		sm.parseBSD(env)
 	}
 
 	if env := os.Getenv("LS_COLORS"); env != "" {
-		sm.parseGNU(env)
+		sm.parseGNU(env, "$LS_COLORS")
 	}
 
 	if env := os.Getenv("LF_COLORS"); env != "" {
-		sm.parseGNU(env)
+		sm.parseGNU(env, "$LF_COLORS")
 	}
 
 	for _, path := range gColorsPaths {
@@ -227,19 +236,19 @@ func (sm styleMap) parseFile(path string) {
 	}
 	defer f.Close()
 
-	pairs, err := readPairs(f)
+	pairs, lines, err := readArrayLines(f, 2, 2)
 	if err != nil {
 		log.Printf("reading colors file: %s", err)
 		return
 	}
 
-	for _, pair := range pairs {
-		sm.parsePair(pair)
+	for i, pair := range pairs {
+		sm.parsePair(pair, fmt.Sprintf("%s:%d", path, lines[i]))
 	}
 }
 
 // This function parses $LS_COLORS environment variable.
-func (sm *styleMap) parseGNU(env string) {
+func (sm *styleMap) parseGNU(env, source string) {
 	for _, entry := range strings.Split(env, ":") {
 		if entry == "" {
 			continue
@@ -252,11 +261,11 @@ func (sm *styleMap) parseGNU(env string) {
 			return
 		}
 
-		sm.parsePair(pair)
+		sm.parsePair(pair, source)
 	}
 }
 
-func (sm *styleMap) parsePair(pair []string) {
+func (sm *styleMap) parsePair(pair []string, source string) {
 	key, val := pair[0], pair[1]
 
 	key = replaceTilde(key)
@@ -270,6 +279,7 @@ func (sm *styleMap) parsePair(pair []string) {
 	}
 
 	sm.styles[key] = applyAnsiCodes(val, tcell.StyleDefault)
+	sm.origins[key] = ruleOrigin{source, val}
 }
 
 // This function parses $LSCOLORS environment variable.
@@ -311,24 +321,30 @@ func (sm styleMap) parseBSD(env string) {
 
 	for i, key := range colorNames {
 		sm.styles[key] = getStyle(env[i*2], env[i*2+1])
+		sm.origins[key] = ruleOrigin{"$LSCOLORS", env[i*2 : i*2+2]}
 	}
 }
 
-func (sm styleMap) get(f *file) tcell.Style {
-	if val, ok := sm.styles[f.path]; ok {
-		return val
-	}
+type ruleKey struct {
+	kind string
+	key  string
+}
+
+// This function returns the keys of colors and icons entries matching the
+// given file in the order of precedence, where the first one defined is used.
+// Keys that are not applicable to the file (e.g. dir names for files) are not
+// included.
+func ruleKeys(f *file, useLinkTarget bool) []ruleKey {
+	keys := []ruleKey{{"full path", f.path}}
 
 	if f.IsDir() {
-		if val, ok := sm.styles[f.Name()+"/"]; ok {
-			return val
-		}
+		keys = append(keys, ruleKey{"dir name", f.Name() + "/"})
 	}
 
 	var key string
 
 	switch {
-	case f.linkState == working && !sm.useLinkTarget:
+	case f.linkState == working && !useLinkTarget:
 		key = "ln"
 	case f.linkState == broken:
 		key = "or"
@@ -356,28 +372,23 @@ func (sm styleMap) get(f *file) tcell.Style {
 		key = "ex"
 	}
 
-	if val, ok := sm.styles[key]; ok {
-		return val
-	}
-
-	if val, ok := sm.styles[f.Name()+"*"]; ok {
-		return val
+	if key != "" {
+		keys = append(keys, ruleKey{"file type", key})
 	}
 
-	if val, ok := sm.styles["*"+f.Name()]; ok {
-		return val
-	}
-
-	if val, ok := sm.styles[filepath.Base(f.Name())+".*"]; ok {
-		return val
-	}
-
-	if val, ok := sm.styles["*"+strings.ToLower(f.ext)]; ok {
-		return val
-	}
+	return append(keys,
+		ruleKey{"file name", f.Name() + "*"},
+		ruleKey{"file name", "*" + f.Name()},
+		ruleKey{"base name", filepath.Base(f.Name()) + ".*"},
+		ruleKey{"extension", "*" + strings.ToLower(f.ext)},
+		ruleKey{"default", "fi"})
+}
 
-	if val, ok := sm.styles["fi"]; ok {
-		return val
+func (sm styleMap) get(f *file) tcell.Style {
+	for _, k := range ruleKeys(f, sm.useLinkTarget) {
+		if val, ok := sm.styles[k.key]; ok {
+			return val
+		}
 	}
 
 	return tcell.StyleDefault
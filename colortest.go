@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// The 'colortest' command explains which entries in colors and icons match a
+// given file, since finding out why a file is shown with a certain color or
+// icon is otherwise difficult when entries from different sources overlap.
+// All applicable entries are listed in the order of precedence with their
+// sources, where the first one defined is used and the rest are shadowed.
+
+// This function returns the explanation lines for the given entries using the
+// origins of the defined ones.
+func explainRules(name string, keys []ruleKey, origins map[string]ruleOrigin) []string {
+	lines := []string{name + ":"}
+
+	matched := false
+	for i, k := range keys {
+		line := fmt.Sprintf("  %d. %-9s  %s", i+1, k.kind, k.key)
+
+		origin, ok := origins[k.key]
+		switch {
+		case !ok:
+			line += "  (not defined)"
+		case !matched:
+			line += fmt.Sprintf("  = %s  matched, from %s", origin.value, origin.source)
+			matched = true
+		default:
+			line += fmt.Sprintf("  = %s  shadowed, from %s", origin.value, origin.source)
+		}
+
+		lines = append(lines, line)
+	}
+
+	if !matched {
+		lines = append(lines, "  no entry matched, using the default")
+	}
+
+	return lines
+}
+
+func (app *app) colorTest(path string) {
+	path = replaceTilde(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(app.nav.currDir().path, path)
+	}
+	path = filepath.Clean(path)
+
+	f := newFile(path)
+	if f.err != nil {
+		app.ui.echoerrf("colortest: %s", f.err)
+		return
+	}
+
+	lines := []string{"file: " + path}
+
+	sm := app.ui.styles
+	lines = append(lines, explainRules("colors", ruleKeys(f, sm.useLinkTarget), sm.origins)...)
+	if sm.useLinkTarget {
+		lines = append(lines, "  links are matched using their targets ('ln=target')")
+	}
+
+	im := app.ui.icons
+	lines = append(lines, explainRules("icons", ruleKeys(f, im.useLinkTarget), im.origins)...)
+	if im.useLinkTarget {
+		lines = append(lines, "  links are matched using their targets ('ln target')")
+	}
+	if !gOpts.icons {
+		lines = append(lines, "  icons are not shown since the 'icons' option is disabled")
+	}
+
+	if gHeadless {
+		for _, line := range lines {
+			app.ui.echomsg(line)
+		}
+		return
+	}
+
+	app.ui.pager = newPager("colortest: "+filepath.Base(path), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestColorTest(t *testing.T) {
+	dir := t.TempDir()
+
+	colors := filepath.Join(dir, "colors")
+	if err := os.WriteFile(colors, []byte("# comment\n*.txt 32\n\nfi 00\n"), 0o644); err != nil {
+		t.Fatalf("writing colors file: %s", err)
+	}
+
+	path := filepath.Join(dir, "foo.txt")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("writing file: %s", err)
+	}
+
+	sm := styleMap{
+		styles:  make(map[string]tcell.Style),
+		origins: make(map[string]ruleOrigin),
+	}
+	sm.parseGNU("fi=00:*.txt=31", "$LS_COLORS")
+	sm.parseFile(colors)
+
+	exp := []string{
+		"colors:",
+		"  1. full path  " + path + "  (not defined)",
+		"  2. file name  foo.txt*  (not defined)",
+		"  3. file name  *foo.txt  (not defined)",
+		"  4. base name  foo.txt.*  (not defined)",
+		"  5. extension  *.txt  = 32  matched, from " + colors + ":2",
+		"  6. default    fi  = 00  shadowed, from " + colors + ":4",
+	}
+
+	f := newFile(path)
+	if got := explainRules("colors", ruleKeys(f, sm.useLinkTarget), sm.origins); !reflect.DeepEqual(got, exp) {
+		t.Errorf("at input '%s' expected '%q' but got '%q'", path, exp, got)
+	}
+
+	if got := sm.get(f); got != sm.styles["*.txt"] {
+		t.Errorf("at input '%s' expected the style of '*.txt' but got '%v'", path, got)
+	}
+
+	exp = []string{
+		"colors:",
+		"  1. full path  " + dir + "  (not defined)",
+		"  2. dir name   " + filepath.Base(dir) + "/  (not defined)",
+		"  3. file type  di  (not defined)",
+		"  4. file name  " + filepath.Base(dir) + "*  (not defined)",
+		"  5. file name  *" + filepath.Base(dir) + "  (not defined)",
+		"  6. base name  " + filepath.Base(dir) + ".*  (not defined)",
+		"  7. extension  *  (not defined)",
+		"  8. default    fi  = 00  matched, from " + colors + ":4",
+	}
+
+	f = newFile(dir)
+	if got := explainRules("colors", ruleKeys(f, sm.useLinkTarget), sm.origins); !reflect.DeepEqual(got, exp) {
+		t.Errorf("at input '%s' expected '%q' but got '%q'", dir, exp, got)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Extra columns are shown after the file name and the 'info' fields with the
+// 'columns' option, which is a colon-separated list of fields. Each field can
+// be followed by '<' or '>' to align it to the left or right, and by a width
+// (e.g. 'user<8' or 'link>30'). Columns without a width are as wide as the
+// widest value of the visible files, and longer values are truncated. The
+// built-in fields are 'perm', 'user', 'group', 'link' (the target of symbolic
+// links) and 'git' (the git status when the 'gitstatus' option is enabled).
+// Other fields are defined with the 'column' command as shell commands, which
+// are run in the background in each shown directory with the names of its
+// files as arguments, and print a line for each file in the same order. Their
+// outputs are cached until the directory is loaded again, and values are empty
+// while the commands are running. Values are shown without escape sequences.
+
+const (
+	gColumnTimeout  = 5 * time.Second
+	gColumnChunk    = 1000
+	gColumnCacheMax = 256
+)
+
+var gColumnBuiltins = []string{"perm", "user", "group", "link", "git"}
+
+var reColumn = regexp.MustCompile(`^([A-Za-z0-9_-]+)([<>]?)([0-9]*)$`)
+
+type columnDef struct {
+	name  string
+	width int
+	right bool
+}
+
+// The parsed value of the 'columns' option.
+var gColumns []columnDef
+
+// This function parses the value of the 'columns' option.
+func parseColumns(val string) ([]columnDef, error) {
+	if val == "" {
+		return nil, nil
+	}
+
+	var defs []columnDef
+	for _, s := range strings.Split(val, ":") {
+		m := reColumn.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf("invalid column: %q", s)
+		}
+		def := columnDef{name: m[1], right: m[2] == ">"}
+		if m[3] != "" {
+			def.width, _ = strconv.Atoi(m[3])
+		}
+		defs = append(defs, def)
+	}
+
+	return defs, nil
+}
+
+type columnValues struct {
+	loadTime time.Time
+	values   map[string]string
+	running  bool
+}
+
+type columnResult struct {
+	name     string
+	dir      string
+	loadTime time.Time
+	values   map[string]string
+	err      error
+}
+
+type shellColumns struct {
+	defs  map[string]string
+	cache map[string]*columnValues
+}
+
+// This function runs the given command in the given directory with the given
+// names as arguments, and returns the lines of the output by name. The command
+// is run several times for directories with many files.
+func runColumn(cmd, dir string, names []string) (map[string]string, error) {
+	values := make(map[string]string, len(names))
+
+	for chunk := range slices.Chunk(names, gColumnChunk) {
+		var out bytes.Buffer
+
+		c := shellCommand(cmd, chunk)
+		c.Dir = dir
+		c.Stdout = &out
+		c.WaitDelay = gColumnTimeout
+
+		if err := c.Start(); err != nil {
+			return nil, err
+		}
+
+		timer := time.AfterFunc(gColumnTimeout, func() { c.Process.Kill() })
+		err := c.Wait()
+		if !timer.Stop() {
+			return nil, fmt.Errorf("timed out after %s", gColumnTimeout)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s := bufio.NewScanner(&out)
+		for i := 0; i < len(chunk) && s.Scan(); i++ {
+			values[chunk[i]] = stripAnsi(strings.TrimRight(s.Text(), "\r"))
+		}
+	}
+
+	return values, nil
+}
+
+// This function defines a column, or removes it when the command is empty.
+func (c *shellColumns) define(name, cmd string) {
+	for key := range c.cache {
+		if strings.HasPrefix(key, name+"\x00") {
+			delete(c.cache, key)
+		}
+	}
+	if cmd == "" {
+		delete(c.defs, name)
+		return
+	}
+	if c.defs == nil {
+		c.defs = make(map[string]string)
+	}
+	c.defs[name] = cmd
+}
+
+// This function returns the cached value of the given column for the given
+// file in the given directory.
+func (c *shellColumns) value(name, dir, file string) string {
+	if v, ok := c.cache[segmentKey(name, dir)]; ok {
+		return v.values[file]
+	}
+	return ""
+}
+
+// This function starts the commands of the columns in use for the given
+// directories, unless they are already cached since the directories are
+// loaded.
+func (c *shellColumns) refresh(dirs []*dir, ch chan<- columnResult) {
+	if len(c.defs) == 0 {
+		return
+	}
+
+	if c.cache == nil {
+		c.cache = make(map[string]*columnValues)
+	}
+
+	for _, def := range gColumns {
+		cmd, ok := c.defs[def.name]
+		if !ok {
+			continue
+		}
+		for _, d := range dirs {
+			if d.loading || len(d.allFiles) == 0 {
+				continue
+			}
+
+			key := segmentKey(def.name, d.path)
+			v, ok := c.cache[key]
+			if ok && (v.running || v.loadTime.Equal(d.loadTime)) {
+				continue
+			}
+			if !ok {
+				v = &columnValues{}
+				c.cache[key] = v
+			}
+			v.running = true
+
+			names := make([]string, len(d.allFiles))
+			for i, f := range d.allFiles {
+				names[i] = f.Name()
+			}
+
+			go func(name, path string, loadTime time.Time) {
+				values, err := runColumn(cmd, path, names)
+				ch <- columnResult{name, path, loadTime, values, err}
+			}(def.name, d.path, d.loadTime)
+		}
+	}
+
+	// values of other directories are dropped as they are computed again
+	// when the directories are loaded
+	if len(c.cache) > gColumnCacheMax {
+		shown := make(map[string]bool)
+		for _, d := range dirs {
+			shown[d.path] = true
+		}
+		for key, v := range c.cache {
+			_, dir, _ := strings.Cut(key, "\x00")
+			if !v.running && !shown[dir] {
+				delete(c.cache, key)
+			}
+		}
+	}
+}
+
+// This function stores the outputs of a column, and reports whether they are
+// stored.
+func (c *shellColumns) update(r columnResult) bool {
+	v, ok := c.cache[segmentKey(r.name, r.dir)]
+	if !ok {
+		return false
+	}
+
+	v.running = false
+	v.loadTime = r.loadTime
+	if r.err != nil {
+		log.Printf("column %s: %s", r.name, r.err)
+		return false
+	}
+
+	v.values = r.values
+	return true
+}
+
+// This function returns the value of the given column for the given file.
+func columnValue(name string, f *file, d *dir, context *dirContext) string {
+	switch name {
+	case "perm":
+		return f.Mode().String()
+	case "user":
+		return userName(f.FileInfo)
+	case "group":
+		return groupName(f.FileInfo)
+	case "link":
+		return f.linkTarget
+	case "git":
+		if !gOpts.gitstatus || context.git == nil {
+			return ""
+		}
+		return stripAnsi(gGitStatusFmts[context.git.get(d.path, f.Name())])
+	}
+
+	if context.columns == nil {
+		return ""
+	}
+	return context.columns.value(name, d.path, f.Name())
+}
+
+// This function returns the widths of the columns, which are the widths of the
+// widest values of the visible files for columns without a width.
+func columnWidths(d *dir, beg, end int, context *dirContext) []int {
+	widths := make([]int, len(gColumns))
+	for i, def := range gColumns {
+		if def.width > 0 {
+			widths[i] = def.width
+			continue
+		}
+		for _, f := range d.files[beg:end] {
+			widths[i] = max(widths[i], runeSliceWidth([]rune(columnValue(def.name, f, d, context))))
+		}
+	}
+	return widths
+}
+
+// This function returns the columns of the given file with the given widths.
+// Columns with no width are left out.
+func fileColumns(f *file, d *dir, widths []int, context *dirContext) string {
+	var b strings.Builder
+
+	for i, def := range gColumns {
+		w := widths[i]
+		if w == 0 {
+			continue
+		}
+
+		val := []rune(columnValue(def.name, f, d, context))
+		if runeSliceWidth(val) > w {
+			val = append(runeSliceWidthRange(val, 0, w-1), []rune(gOpts.truncatechar)...)
+		}
+		pad := strings.Repeat(" ", max(w-runeSliceWidth(val), 0))
+
+		b.WriteByte(' ')
+		if def.right {
+			b.WriteString(pad)
+		}
+		b.WriteString(string(val))
+		if !def.right {
+			b.WriteString(pad)
+		}
+	}
+
+	return b.String()
+}
+
+// This function parses the arguments of the 'column' command, which are the
+// name and the optional command of the column.
+func parseColumnCmd(args []string) (string, string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", "", errors.New("requires a name and an optional command")
+	}
+	if m := reColumn.FindStringSubmatch(args[0]); m == nil || m[2] != "" || m[3] != "" {
+		return "", "", fmt.Errorf("invalid name: %q", args[0])
+	}
+	if slices.Contains(gColumnBuiltins, args[0]) {
+		return "", "", fmt.Errorf("built-in column: %s", args[0])
+	}
+
+	cmd := ""
+	if len(args) == 2 {
+		cmd = args[1]
+	}
+	return args[0], cmd, nil
+}
+
+func (app *app) defineColumn(args []string) {
+	name, cmd, err := parseColumnCmd(args)
+	if err != nil {
+		app.ui.echoerrf("column: %s", err)
+		return
+	}
+
+	app.nav.columns.define(name, cmd)
+	app.refreshColumns()
+	app.ui.draw(app.nav)
+}
+
+// This function returns the directories shown in the panes.
+func (app *app) shownDirs() []*dir {
+	dirs := slices.Clone(app.nav.dirs)
+	if app.nav.pane != nil {
+		dirs = append(dirs, app.nav.pane)
+	}
+	if app.ui.dirPrev != nil {
+		dirs = append(dirs, app.ui.dirPrev)
+	}
+	return dirs
+}
+
+func (app *app) refreshColumns() {
+	if len(gColumns) == 0 || !app.nav.init {
+		return
+	}
+	app.nav.columns.refresh(app.shownDirs(), app.nav.columnChan)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		val string
+		exp []columnDef
+		err bool
+	}{
+		{"", nil, false},
+		{"perm", []columnDef{{name: "perm"}}, false},
+		{"user<8:link>30:inode>", []columnDef{{"user", 8, false}, {"link", 30, true}, {"inode", 0, true}}, false},
+		{"perm:", nil, true},
+		{"user=8", nil, true},
+		{"user<-8", nil, true},
+	}
+
+	for _, test := range tests {
+		defs, err := parseColumns(test.val)
+		if (err != nil) != test.err {
+			t.Errorf("at input '%s' expected error '%t' but got '%v'", test.val, test.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(defs, test.exp) {
+			t.Errorf("at input '%s' expected '%v' but got '%v'", test.val, test.exp, defs)
+		}
+	}
+
+	for _, args := range [][]string{{}, {"perm", "echo"}, {"a<3", "echo"}, {"a", "b", "c"}} {
+		if _, _, err := parseColumnCmd(args); err == nil {
+			t.Errorf("at input '%v' expected an error", args)
+		}
+	}
+}
+
+func TestFileColumns(t *testing.T) {
+	defer func(defs []columnDef) { gColumns = defs }(gColumns)
+
+	d := &dir{path: "/dir", files: []*file{
+		{FileInfo: fakeFileInfo{"a", true}, linkTarget: "target"},
+		{FileInfo: fakeFileInfo{"b", false}, linkTarget: "a-very-long-target"},
+	}}
+	context := &dirContext{columns: &shellColumns{cache: map[string]*columnValues{
+		segmentKey("inode", "/dir"): {values: map[string]string{"a": "12", "b": "345"}},
+	}}}
+
+	tests := []struct {
+		val string
+		exp []string
+	}{
+		{"inode", []string{" 12 ", " 345"}},
+		{"inode>", []string{"  12", " 345"}},
+		{"link<8:inode>4", []string{" target     12", " a-very-~  345"}},
+		{"undefined:inode", []string{" 12 ", " 345"}},
+	}
+
+	for _, test := range tests {
+		gColumns, _ = parseColumns(test.val)
+		widths := columnWidths(d, 0, len(d.files), context)
+		for i, f := range d.files {
+			if got := fileColumns(f, d, widths, context); got != test.exp[i] {
+				t.Errorf("at input '%s' expected '%s' but got '%s'", test.val, test.exp[i], got)
+			}
+		}
+	}
+}
+
+func TestShellColumns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("columns are tested with a POSIX shell")
+	}
+
+	defer func(defs []columnDef) { gColumns = defs }(gColumns)
+	gColumns, _ = parseColumns("perm:len")
+
+	path := t.TempDir()
+	for _, name := range []string{"a", "bb"} {
+		if err := os.WriteFile(filepath.Join(path, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := &dir{path: path, loadTime: time.Now(), allFiles: []*file{newFile(filepath.Join(path, "a")), newFile(filepath.Join(path, "bb"))}}
+
+	var c shellColumns
+	c.define("len", `for f; do printf '%s\n' "${#f}"; done`)
+
+	ch := make(chan columnResult, 1)
+	c.refresh([]*dir{d}, ch)
+	c.refresh([]*dir{d}, ch)
+	if !c.update(<-ch) {
+		t.Fatal("expected the values to be stored")
+	}
+	if got := c.value("len", path, "bb"); got != "2" {
+		t.Errorf("expected '2' but got '%s'", got)
+	}
+
+	// values are kept until the directory is loaded again
+	c.refresh([]*dir{d}, ch)
+	if len(ch) != 0 {
+		t.Errorf("expected no command to be run for a cached directory")
+	}
+	d.loadTime = d.loadTime.Add(time.Second)
+	c.refresh([]*dir{d}, ch)
+	if r := <-ch; r.err != nil || r.values["a"] != "1" {
+		t.Errorf("expected the command to be run again but got '%v' (%v)", r.values, r.err)
+	}
+
+	c.define("len", "")
+	if got := c.value("len", path, "bb"); got != "" {
+		t.Errorf("expected the values to be removed but got '%s'", got)
+	}
+}
@@ -20,6 +20,7 @@ var (
 		"cmap",
 		"cmd",
 		"quit",
+		"cd-and-quit-into-shell",
 		"up",
 		"half-up",
 		"page-up",
@@ -28,6 +29,8 @@ var (
 		"half-down",
 		"page-down",
 		"scroll-down",
+		"column-left",
+		"column-right",
 		"updir",
 		"open",
 		"jump-next",
@@ -37,29 +40,81 @@ var (
 		"high",
 		"middle",
 		"low",
+		"hop",
 		"toggle",
 		"invert",
 		"unselect",
 		"glob-select",
 		"glob-unselect",
 		"calcdirsize",
+		"dirsize",
 		"clearmaps",
 		"copy",
 		"cut",
 		"paste",
 		"clear",
+		"restore-selection",
+		"last-report",
+		"archive",
+		"split-file",
+		"join-files",
+		"hashdir",
+		"checksum",
+		"checksum-results",
+		"stats",
+		"stats-results",
+		"cleanup",
+		"cleanup-stage",
+		"cleanup-trash",
+		"sortlocal",
+		"clipboard-history",
+		"session-save",
+		"session-load",
+		"jump",
+		"jump-import",
+		"trash",
+		"restore",
+		"trash-list",
+		"undo",
+		"redo",
+		"select-failed",
+		"retry-failed",
+		"recent-files",
+		"fuzzy",
+		"search-name",
+		"search-content",
+		"search-results",
+		"jobs",
+		"prompt-segment",
+		"column",
+		"pane-switch",
+		"copy-to-other",
+		"move-to-other",
 		"sync",
 		"draw",
 		"redraw",
+		"help",
+		"colortest",
+		"setup",
+		"suspend",
 		"load",
 		"reload",
+		"reload-entry",
+		"clear-cache",
+		"refresh-git-status",
+		"recent-add",
 		"echo",
 		"echomsg",
 		"echoerr",
 		"cd",
+		"cd-physical",
+		"cd-logical",
+		"connect",
 		"select",
 		"delete",
+		"dry-run",
 		"rename",
+		"bulkrename",
 		"source",
 		"push",
 		"read",
@@ -80,10 +135,25 @@ var (
 		"mark-save",
 		"mark-load",
 		"mark-remove",
+		"mark-export",
+		"mark-import",
 		"tag",
 		"tag-toggle",
 		"addcustominfo",
 		"tty-write",
+		"edit-in-server",
+		"detach",
+		"tab-new",
+		"tab-close",
+		"tab-next",
+		"tab-prev",
+		"tab-move",
+		"refreshrule",
+		"lfenv-allow",
+		"lfenv-deny",
+		"trust",
+		"untrust",
+		"terminal",
 		"cmd-escape",
 		"cmd-complete",
 		"cmd-menu-complete",
@@ -112,6 +182,8 @@ var (
 		"cmd-capitalize-word",
 		"cmd-uppercase-word",
 		"cmd-lowercase-word",
+		"cmd-digraph",
+		"cmd-literal",
 		"visual",
 		"visual-accept",
 		"visual-unselect",
@@ -190,6 +262,19 @@ func matchWord(s string, words []string) (matches []string, longest []rune) {
 	return
 }
 
+var gSortKeyWords = []string{"natural", "name", "size", "time", "atime", "btime", "ctime", "ext", "custom", "lastopened"}
+
+// This function matches the last key of the given sort method, so that keys
+// separated with commas are completed one by one.
+func matchSortBy(s string) (matches []string, longest []rune) {
+	prefix := ""
+	if i := strings.LastIndex(s, ","); i >= 0 {
+		prefix, s = s[:i+1], s[i+1:]
+	}
+	matches, longest = matchWord(s, gSortKeyWords)
+	return matches, append([]rune(prefix), longest...)
+}
+
 func matchExec(s string) (matches []string, longest []rune) {
 	var words []string
 
@@ -303,9 +388,10 @@ func matchFile(s string) (matches []string, longest []rune) {
 }
 
 func matchCmd(s string) (matches []string, longest []rune) {
-	words := make([]string, 0, len(gCmdWords)+len(gOpts.cmds))
+	cmds := activeCmds()
+	words := make([]string, 0, len(gCmdWords)+len(cmds))
 	words = append(words, gCmdWords...)
-	for c := range gOpts.cmds {
+	for c := range cmds {
 		words = append(words, c)
 	}
 	sort.Strings(words)
@@ -343,15 +429,21 @@ func completeCmd(acc []rune) (matches []string, longestAcc []rune) {
 			break
 		}
 		switch f[1] {
+		case "imagepreview":
+			matches, longest = matchWord(f[2], []string{"auto", "iterm2", "kitty", "none", "sixel"})
 		case "selmode":
 			matches, longest = matchWord(f[2], []string{"all", "dir"})
 		case "sortby":
-			matches, longest = matchWord(f[2], []string{"natural", "name", "size", "time", "atime", "btime", "ctime", "ext", "custom"})
+			matches, longest = matchSortBy(f[2])
 		default:
 			if slices.Contains(gOptWords, f[1]+"!") {
 				matches, longest = matchWord(f[2], []string{"true", "false"})
 			}
 		}
+	case "sortlocal":
+		if len(f) == 2 {
+			matches, longest = matchSortBy(f[1])
+		}
 	case "setlocal":
 		if len(f) == 2 {
 			matches, longest = matchFile(f[1])
@@ -366,7 +458,7 @@ func completeCmd(acc []rune) (matches []string, longestAcc []rune) {
 		}
 		switch f[2] {
 		case "sortby":
-			matches, longest = matchWord(f[3], []string{"natural", "name", "size", "time", "atime", "btime", "ctime", "ext", "custom"})
+			matches, longest = matchSortBy(f[3])
 		default:
 			if slices.Contains(gLocalOptWords, f[2]+"!") {
 				matches, longest = matchWord(f[3], []string{"true", "false"})
@@ -377,9 +469,50 @@ func completeCmd(acc []rune) (matches []string, longestAcc []rune) {
 			matches, longest = matchCmd(f[2])
 		}
 	case "cmd":
-	case "toggle":
+	case "toggle", "reload-entry":
+		matches, longest = matchFile(f[len(f)-1])
+	case "paste", "delete", "bulkrename", "trash", "restore", "copy-to-other", "move-to-other", "cleanup-trash", "undo", "redo":
+		if len(f) == 2 {
+			matches, longest = matchWord(f[1], []string{"--dry-run"})
+		}
+	case "hashdir":
+		if len(f) == 2 {
+			matches, longest = matchWord(f[1], []string{"--check", "--dry-run"})
+		}
+	case "retry-failed":
+		if len(f) == 2 {
+			matches, longest = matchWord(f[1], []string{"--dry-run", "overwrite", "rename", "sudo"})
+		}
+	case "dirsize":
+		if len(f) == 2 {
+			matches, longest = matchWord(f[1], []string{"--cancel"})
+		}
+	case "dry-run":
+		if len(f) == 2 {
+			matches, longest = matchWord(f[1], []string{"off", "on"})
+		}
+	case "help":
+		if len(f) == 2 {
+			matches, longest = matchWord(f[1], helpTopicWords())
+		}
+	case "mark-export":
+		if len(f) == 2 {
+			matches, longest = matchFile(f[1])
+		}
+	case "mark-import":
+		switch {
+		case len(f) == 2 && strings.HasPrefix(f[1], "-"):
+			matches, longest = matchWord(f[1], []string{"--from"})
+		case len(f) == 2:
+			matches, longest = matchFile(f[1])
+		case len(f) == 3 && f[1] == "--from":
+			matches, longest = matchWord(f[2], []string{"ranger", "vifm"})
+		case len(f) == 4 && f[1] == "--from":
+			matches, longest = matchFile(f[3])
+		}
+	case "trust", "untrust":
 		matches, longest = matchFile(f[len(f)-1])
-	case "cd", "select", "source":
+	case "cd", "select", "source", "colortest":
 		if len(f) == 2 {
 			matches, longest = matchFile(f[1])
 		}
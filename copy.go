@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/djherbis/times"
 )
@@ -34,12 +36,12 @@ func copySize(srcs []string) (int64, error) {
 	var total int64
 
 	for _, src := range srcs {
-		_, err := os.Lstat(src)
+		_, err := lstatPath(src)
 		if os.IsNotExist(err) {
 			return total, fmt.Errorf("src does not exist: %q", src)
 		}
 
-		err = filepath.Walk(src, func(_ string, info os.FileInfo, err error) error {
+		err = walkPath(src, func(_ string, info os.FileInfo, err error) error {
 			if err != nil {
 				return fmt.Errorf("walk: %s", err)
 			}
@@ -54,8 +56,17 @@ func copySize(srcs []string) (int64, error) {
 	return total, nil
 }
 
-func copyFile(src, dst string, preserve []string, info os.FileInfo, nums chan int64) error {
-	r, err := os.Open(src)
+// This function returns the access time of the given file, which is the
+// modification time for files inside disk images.
+func fileAccessTime(info os.FileInfo) time.Time {
+	if info.Sys() == nil {
+		return info.ModTime()
+	}
+	return times.Get(info).AccessTime()
+}
+
+func copyFile(src, dst string, preserve []string, info os.FileInfo, nums chan int64, ctl *jobControl) error {
+	r, err := openPath(src)
 	if err != nil {
 		return err
 	}
@@ -65,27 +76,32 @@ func copyFile(src, dst string, preserve []string, info os.FileInfo, nums chan in
 	if slices.Contains(preserve, "mode") {
 		dst_mode = info.Mode()
 	}
-	w, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, dst_mode)
+	w, err := createPath(dst, dst_mode)
 	if err != nil {
 		return err
 	}
 
-	if _, err := io.Copy(NewProgressWriter(w, nums), r); err != nil {
+	var out io.Writer = w
+	if ctl != nil {
+		out = &jobWriter{w, ctl}
+	}
+
+	if _, err := io.Copy(NewProgressWriter(out, nums), r); err != nil {
 		w.Close()
-		os.Remove(dst)
+		removePath(dst)
 		return err
 	}
 
 	if err := w.Close(); err != nil {
-		os.Remove(dst)
+		removePath(dst)
 		return err
 	}
 
 	if slices.Contains(preserve, "timestamps") {
-		atime := times.Get(info).AccessTime()
+		atime := fileAccessTime(info)
 		mtime := info.ModTime()
-		if err := os.Chtimes(dst, atime, mtime); err != nil {
-			os.Remove(dst)
+		if err := chtimesPath(dst, atime, mtime); err != nil {
+			removePath(dst)
 			return err
 		}
 	}
@@ -93,7 +109,40 @@ func copyFile(src, dst string, preserve []string, info os.FileInfo, nums chan in
 	return nil
 }
 
-func copyAll(srcs []string, dstDir string, preserve []string) (nums chan int64, errs chan error) {
+// This function returns a path in the destination directory for the given
+// existing file, which is renamed using 'dupfilefmt' until it does not exist.
+func dupPath(dstDir string, lstat os.FileInfo) string {
+	file := lstat.Name()
+	ext := getFileExtension(lstat)
+	basename := file[:len(file)-len(ext)]
+
+	var newPath string
+	for i := 1; ; i++ {
+		file = strings.ReplaceAll(gOpts.dupfilefmt, "%f", basename+ext)
+		file = strings.ReplaceAll(file, "%b", basename)
+		file = strings.ReplaceAll(file, "%e", ext)
+		file = strings.ReplaceAll(file, "%n", strconv.Itoa(i))
+		newPath = filepath.Join(dstDir, file)
+		if _, err := lstatPath(newPath); os.IsNotExist(err) {
+			return newPath
+		}
+	}
+}
+
+// This function returns the path where the given file is copied in the
+// destination directory.
+func copyDest(src, dstDir string) string {
+	dst := filepath.Join(dstDir, filepath.Base(src))
+	if lstat, err := lstatPath(dst); err == nil {
+		return dupPath(dstDir, lstat)
+	}
+	return dst
+}
+
+// This function copies the given files to the destination directory. Copying
+// is paused and cancelled with the given job, which is nil when the copy is
+// not run as a job.
+func copyAll(srcs []string, dstDir string, preserve []string, ctl *jobControl) (nums chan int64, errs chan error) {
 	nums = make(chan int64, 1024)
 	errs = make(chan error, 1024)
 
@@ -101,25 +150,12 @@ func copyAll(srcs []string, dstDir string, preserve []string) (nums chan int64,
 		dirInfos := make(map[string]os.FileInfo)
 
 		for _, src := range srcs {
-			file := filepath.Base(src)
-			dst := filepath.Join(dstDir, file)
-
-			if lstat, err := os.Lstat(dst); err == nil {
-				ext := getFileExtension(lstat)
-				basename := file[:len(file)-len(ext)]
-				var newPath string
-				for i := 1; !os.IsNotExist(err); i++ {
-					file = strings.ReplaceAll(gOpts.dupfilefmt, "%f", basename+ext)
-					file = strings.ReplaceAll(file, "%b", basename)
-					file = strings.ReplaceAll(file, "%e", ext)
-					file = strings.ReplaceAll(file, "%n", strconv.Itoa(i))
-					newPath = filepath.Join(dstDir, file)
-					_, err = os.Lstat(newPath)
-				}
-				dst = newPath
-			}
+			dst := copyDest(src, dstDir)
 
-			filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			err := walkPath(src, func(path string, info os.FileInfo, err error) error {
+				if err := ctl.wait(); err != nil {
+					return err
+				}
 				if err != nil {
 					errs <- fmt.Errorf("walk: %s", err)
 					return nil
@@ -135,8 +171,13 @@ func copyAll(srcs []string, dstDir string, preserve []string) (nums chan int64,
 					var dst_mode = os.ModePerm
 					if slices.Contains(preserve, "mode") {
 						dst_mode = info.Mode()
+						// directories in disk images are usually read-only,
+						// which would prevent copying their contents
+						if info.Sys() == nil {
+							dst_mode |= 0o200
+						}
 					}
-					if err := os.MkdirAll(newPath, dst_mode); err != nil {
+					if err := mkdirAllPath(newPath, dst_mode); err != nil {
 						errs <- fmt.Errorf("mkdir: %s", err)
 					}
 					if slices.Contains(preserve, "timestamps") {
@@ -144,27 +185,34 @@ func copyAll(srcs []string, dstDir string, preserve []string) (nums chan int64,
 					}
 					nums <- info.Size()
 				case info.Mode()&os.ModeSymlink != 0:
-					if rlink, err := os.Readlink(path); err != nil {
+					if rlink, err := readlinkPath(path); err != nil {
 						errs <- fmt.Errorf("symlink: %s", err)
 					} else {
-						if err := os.Symlink(rlink, newPath); err != nil {
+						if err := symlinkPath(rlink, newPath); err != nil {
 							errs <- fmt.Errorf("symlink: %s", err)
 						}
 					}
 					nums <- info.Size()
 				default:
-					if err := copyFile(path, newPath, preserve, info, nums); err != nil {
+					if err := copyFile(path, newPath, preserve, info, nums, ctl); err != nil {
+						if errors.Is(err, errJobCancelled) {
+							return err
+						}
 						errs <- fmt.Errorf("copy: %s", err)
 					}
 				}
 				return nil
 			})
+			if errors.Is(err, errJobCancelled) {
+				errs <- err
+				break
+			}
 		}
 
 		for path, info := range dirInfos {
-			atime := times.Get(info).AccessTime()
+			atime := fileAccessTime(info)
 			mtime := info.ModTime()
-			if err := os.Chtimes(path, atime, mtime); err != nil {
+			if err := chtimesPath(path, atime, mtime); err != nil {
 				errs <- fmt.Errorf("chtimes: %s", err)
 			}
 		}
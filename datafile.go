@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The data files (i.e. marks, tags and history) are shared between clients,
+// and possibly between servers of different users on shared home directories.
+// They are updated while holding a lock on a separate lock file so that
+// concurrent updates are serialized, and written to a temporary file which is
+// then renamed over the original so that readers never see a partially
+// written file. Previous versions are kept as backups (e.g. 'marks.1' for the
+// latest one) when the 'databackups' option is set.
+//
+// Files can still be corrupted by other programs or by a crash of the system,
+// so they are checked when they are read. Invalid lines are skipped, and the
+// file is also corrupted when it is truncated (i.e. the last line is missing
+// its newline) or has null bytes. The valid lines are kept, and the corrupted
+// file is moved aside with a timestamp (e.g. 'marks.corrupt-20060102-150405')
+// so that lost entries can be recovered manually, and it is replaced with the
+// valid lines so that the problem is only reported once.
+
+// Record locks are owned by processes, so goroutines are serialized as well.
+var gDataMutex sync.Mutex
+
+// This function runs the given function while holding the lock of the given
+// data file.
+func withDataLock(path string, f func() error) error {
+	gDataMutex.Lock()
+	defer gDataMutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("creating data directory: %s", err)
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening lock file: %s", err)
+	}
+	defer lock.Close()
+
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("locking %s: %s", path, err)
+	}
+	defer unlockFile(lock)
+
+	return f()
+}
+
+// This function replaces the contents of the given data file atomically. It
+// should be called while holding the lock of the file. The file is left as it
+// is when the contents are not changed, so that backups are only rotated for
+// actual changes.
+func writeDataFile(path string, data []byte) error {
+	old, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	exists := err == nil
+
+	// the mode of the existing file is kept, and new files are created with
+	// the default mode of the user (i.e. 0o666 without the umask)
+	var mode os.FileMode
+	if exists {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		mode = fi.Mode().Perm()
+	}
+
+	// the lock of the file is held, so a temporary file with a fixed name
+	// can only be left over from a crash
+	name := fmt.Sprintf("%s.tmp%d", path, os.Getpid())
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	tmp, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(name)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if exists {
+		if err := os.Chmod(name, mode); err != nil {
+			return err
+		}
+	}
+
+	if exists && gOpts.databackups > 0 {
+		if err := rotateBackups(path, old, gOpts.databackups); err != nil {
+			return fmt.Errorf("writing backup: %s", err)
+		}
+	}
+
+	return os.Rename(name, path)
+}
+
+// This function shifts the numbered backups of the given file by one,
+// dropping the oldest one, and writes the given data as the latest backup.
+func rotateBackups(path string, data []byte, n int) error {
+	backup := func(i int) string {
+		return fmt.Sprintf("%s.%d", path, i)
+	}
+
+	if err := os.Remove(backup(n)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := n - 1; i >= 1; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.WriteFile(backup(1), data, 0o644)
+}
+
+var errDataCorrupt = errors.New("corrupted")
+
+// This function reads the lines of the given data file, skipping lines that
+// are not valid. An error is returned along with the valid lines when the file
+// is corrupted. It should be called while holding the lock of the file.
+func readDataLines(path string, valid func(string) bool) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var problems []string
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		problems = append(problems, "truncated")
+	}
+
+	var lines []string
+	invalid := 0
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.ContainsRune(line, 0) || !valid(line) {
+			invalid++
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if invalid > 0 {
+		problems = append(problems, fmt.Sprintf("%d invalid line(s)", invalid))
+	}
+
+	if len(problems) == 0 {
+		return lines, nil
+	}
+
+	corrupt, err := quarantineDataFile(path, lines)
+	if err != nil {
+		return lines, fmt.Errorf("%w (%s), keeping a copy failed: %s", errDataCorrupt, strings.Join(problems, ", "), err)
+	}
+
+	return lines, fmt.Errorf("%w (%s), recovered %d line(s) and kept a copy at %s",
+		errDataCorrupt, strings.Join(problems, ", "), len(lines), corrupt)
+}
+
+// This function moves the given corrupted data file aside and replaces it
+// with the given valid lines. It returns the new path of the corrupted file.
+func quarantineDataFile(path string, lines []string) (string, error) {
+	corrupt := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(path, corrupt); err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	// the corrupted file is not rotated as a backup since it is moved already
+	return corrupt, writeDataFile(path, b.Bytes())
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWriteDataFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marks")
+
+	defer func(n int) { gOpts.databackups = n }(gOpts.databackups)
+	gOpts.databackups = 2
+
+	for _, data := range []string{"a:/a\n", "b:/b\n", "b:/b\n", "c:/c\n", "d:/d\n"} {
+		if err := withDataLock(path, func() error { return writeDataFile(path, []byte(data)) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// unchanged contents do not rotate the backups
+	tests := []struct {
+		path string
+		exp  string
+	}{
+		{path, "d:/d\n"},
+		{path + ".1", "c:/c\n"},
+		{path + ".2", "b:/b\n"},
+	}
+
+	for _, test := range tests {
+		got, err := os.ReadFile(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.exp {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", filepath.Base(test.path), test.exp, got)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups to be kept")
+	}
+
+	matches, _ := filepath.Glob(path + ".tmp*")
+	if len(matches) > 0 {
+		t.Errorf("expected temporary files to be removed but got '%v'", matches)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	// the mode of the existing file is kept
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := withDataLock(path, func() error { return writeDataFile(path, []byte("e:/e\n")) }); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode '%v' to be kept but got '%v'", os.FileMode(0o600), fi.Mode().Perm())
+	}
+}
+
+func TestReadDataLines(t *testing.T) {
+	validMark := func(line string) bool {
+		mark, path, found := strings.Cut(line, ":")
+		return found && mark != "" && path != ""
+	}
+
+	tests := []struct {
+		data    string
+		exp     []string
+		corrupt bool
+	}{
+		{"", nil, false},
+		{"a:/a\nb:/b\n", []string{"a:/a", "b:/b"}, false},
+		{"a:/a\r\nb:/b\r\n", []string{"a:/a", "b:/b"}, false},
+		{"a:/a\ngarbage\nb:/b\n", []string{"a:/a", "b:/b"}, true},
+		{"a:/a\n\x00\x00\x00\n", []string{"a:/a"}, true},
+		{"a:/a\nb:/b", []string{"a:/a", "b:/b"}, true},
+		{"a:/a\nb", []string{"a:/a"}, true},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "marks")
+		if err := os.WriteFile(path, []byte(test.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		lines, err := readDataLines(path, validMark)
+		if !slices.Equal(lines, test.exp) {
+			t.Errorf("at input '%q' expected '%v' but got '%v'", test.data, test.exp, lines)
+		}
+		if errors.Is(err, errDataCorrupt) != test.corrupt {
+			t.Errorf("at input '%q' expected corrupted to be %t but got error '%v'", test.data, test.corrupt, err)
+		}
+
+		copies, _ := filepath.Glob(path + ".corrupt-*")
+		if !test.corrupt {
+			if len(copies) != 0 {
+				t.Errorf("at input '%q' expected no copies but got '%v'", test.data, copies)
+			}
+			continue
+		}
+
+		if len(copies) != 1 {
+			t.Errorf("at input '%q' expected a copy of the corrupted file but got '%v'", test.data, copies)
+		} else if got, _ := os.ReadFile(copies[0]); string(got) != test.data {
+			t.Errorf("at input '%q' expected the copy to be unchanged but got '%q'", test.data, got)
+		}
+
+		// the file is replaced with the valid lines and read again without errors
+		if lines, err := readDataLines(path, validMark); err != nil || !slices.Equal(lines, test.exp) {
+			t.Errorf("at input '%q' expected repaired file with '%v' but got '%v' (%v)", test.data, test.exp, lines, err)
+		}
+	}
+}
+
+func TestUpdateMarks(t *testing.T) {
+	defer func(path, tempmarks string) { gMarksPath, gOpts.tempmarks = path, tempmarks }(gMarksPath, gOpts.tempmarks)
+	gMarksPath = filepath.Join(t.TempDir(), "marks")
+	gOpts.tempmarks = "'"
+
+	nav1 := &nav{marks: map[string]string{"'": "/tmp"}}
+	nav2 := &nav{marks: map[string]string{}}
+
+	// marks saved by other clients in the meantime are kept
+	set := func(nav *nav, mark, path string) {
+		if err := nav.updateMarks(func() error {
+			nav.marks[mark] = path
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set(nav1, "a", "/a")
+	set(nav2, "b", "/b")
+	if err := nav1.updateMarks(func() error { return nav1.removeMark("b") }); err != nil {
+		t.Fatal(err)
+	}
+	set(nav2, "c", "/c")
+
+	got, err := os.ReadFile(gMarksPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "a:/a\nc:/c\n"; string(got) != exp {
+		t.Errorf("expected marks file '%q' but got '%q'", exp, got)
+	}
+	if nav1.marks["'"] != "/tmp" {
+		t.Errorf("expected temporary mark to be kept but got '%v'", nav1.marks)
+	}
+}
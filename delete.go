@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// Files are deleted natively instead of removing each path with a single call,
+// so that deleting large trees (e.g. 'node_modules') shows its progress and can
+// be cancelled. Directories are read one at a time, and their entries are
+// removed in parallel by a limited number of goroutines before the directories
+// themselves are removed. Entries that can not be removed are skipped and
+// their errors are collected, along with the directories containing them, so
+// that the rest of the tree is still removed. Delete operations are queued
+// with paste operations, so they are listed with the 'jobs' command where they
+// can be paused, resumed or cancelled.
+
+const gDeleteWorkers = 16
+
+type remover struct {
+	ctl       *jobControl
+	sem       chan struct{}
+	dev       uint64
+	checkDev  bool
+	progress  func(found, done int)
+	mutex     sync.Mutex
+	errs      []error
+	cancelled atomic.Bool
+}
+
+// This function removes the given path with its contents and returns the
+// errors of the entries that could not be removed, which are skipped along
+// with their parent directories. Directories on other filesystems than the
+// parent directory of the path are skipped when the 'onefilesystem' option is
+// enabled. The given function is called with the number of entries found and
+// removed or skipped so far as the tree is walked unless it is nil, and the
+// numbers add up to the same total when the function returns. An error for
+// the cancellation is returned last when the job is cancelled.
+func removeTree(path string, ctl *jobControl, progress func(found, done int)) []error {
+	lstat, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return []error{err}
+	}
+
+	r := &remover{
+		ctl:      ctl,
+		sem:      make(chan struct{}, gDeleteWorkers),
+		progress: progress,
+	}
+	if gOpts.onefilesystem {
+		if parent, err := os.Stat(filepath.Dir(path)); err == nil {
+			r.dev, r.checkDev = deviceID(parent)
+		}
+	}
+
+	r.update(1, 0)
+	r.remove(path, lstat.IsDir())
+
+	if r.cancelled.Load() {
+		r.errs = append(r.errs, errJobCancelled)
+	}
+	return r.errs
+}
+
+func (r *remover) update(found, done int) {
+	if r.progress != nil {
+		r.progress(found, done)
+	}
+}
+
+func (r *remover) fail(err error) {
+	if errors.Is(err, errJobCancelled) {
+		r.cancelled.Store(true)
+		return
+	}
+
+	r.mutex.Lock()
+	r.errs = append(r.errs, err)
+	r.mutex.Unlock()
+}
+
+// This function removes the given entry and reports whether it is removed.
+func (r *remover) remove(path string, isDir bool) bool {
+	defer r.update(0, 1)
+
+	if err := r.ctl.wait(); err != nil {
+		r.fail(err)
+		return false
+	}
+
+	if !isDir {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			r.fail(err)
+			return false
+		}
+		return true
+	}
+
+	if r.checkDev {
+		lstat, err := os.Lstat(path)
+		if err != nil {
+			r.fail(err)
+			return false
+		}
+		if d, ok := deviceID(lstat); ok && d != r.dev {
+			r.fail(fmt.Errorf("skipping mountpoint: %s", path))
+			return false
+		}
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil {
+		r.fail(err)
+		return false
+	}
+	entries, err := f.ReadDir(-1)
+	f.Close()
+
+	var failed atomic.Bool
+	if err != nil {
+		r.fail(err)
+		failed.Store(true)
+	}
+
+	// entries are removed in new goroutines while there are free workers and
+	// in the current goroutine otherwise, so that nested directories do not
+	// wait for workers held by their parents
+	r.update(len(entries), 0)
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		p := filepath.Join(path, e.Name())
+		select {
+		case r.sem <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-r.sem
+					wg.Done()
+				}()
+				if !r.remove(p, e.IsDir()) {
+					failed.Store(true)
+				}
+			}()
+		default:
+			if !r.remove(p, e.IsDir()) {
+				failed.Store(true)
+			}
+		}
+	}
+	wg.Wait()
+
+	if failed.Load() {
+		return false
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		r.fail(err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRemoveTree(t *testing.T) {
+	mkTree := func() string {
+		dir := filepath.Join(t.TempDir(), "tree")
+		for i := range 10 {
+			sub := filepath.Join(dir, fmt.Sprintf("dir%d", i), "nested")
+			if err := os.MkdirAll(sub, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for j := range 10 {
+				if err := os.WriteFile(filepath.Join(sub, fmt.Sprintf("file%d", j)), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.Symlink("nested", filepath.Join(dir, fmt.Sprintf("dir%d", i), "link")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	var mutex sync.Mutex
+	var found, done int
+	progress := func(f, d int) {
+		mutex.Lock()
+		found += f
+		done += d
+		mutex.Unlock()
+	}
+
+	dir := mkTree()
+	if errs := removeTree(dir, nil, progress); len(errs) != 0 {
+		t.Errorf("at input '%s' expected no errors but got '%v'", dir, errs)
+	}
+	if _, err := os.Lstat(dir); !os.IsNotExist(err) {
+		t.Errorf("at input '%s' expected tree to be removed", dir)
+	}
+	// root, 10 directories with a nested directory and a link, 100 files
+	if found != 131 || done != found {
+		t.Errorf("at input '%s' expected '131/131' entries but got '%d/%d'", dir, done, found)
+	}
+
+	if errs := removeTree(dir, nil, nil); len(errs) != 0 {
+		t.Errorf("at input '%s' expected no errors for missing path but got '%v'", dir, errs)
+	}
+
+	dir = mkTree()
+	ctl := &jobControl{}
+	ctl.cancel()
+	errs := removeTree(dir, ctl, nil)
+	if len(errs) != 1 || errs[0] != errJobCancelled {
+		t.Errorf("at input '%s' expected cancellation but got '%v'", dir, errs)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "dir0", "nested", "file0")); err != nil {
+		t.Errorf("at input '%s' expected cancelled tree to be kept", dir)
+	}
+
+	if os.Getuid() == 0 {
+		t.Skip("permissions are not checked for root")
+	}
+
+	locked := filepath.Join(dir, "dir3", "nested")
+	if err := os.Chmod(locked, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0o755)
+
+	errs = removeTree(dir, nil, nil)
+	if len(errs) != 10 {
+		t.Errorf("at input '%s' expected '10' errors but got '%v'", dir, errs)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "dir3" {
+		t.Errorf("at input '%s' expected only the locked directory to be kept but got '%v'", dir, entries)
+	}
+}
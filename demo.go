@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The demo mode started with 'lf -demo <shape>' generates a tree of fake files
+// in a temporary directory and starts lf inside it, so that screenshots,
+// tutorials and reports of performance issues can be made without touching
+// real data. The shape is either one of the presets 'small', 'medium' and
+// 'large', or a comma separated list of 'files=<n>', 'depth=<n>' and
+// 'width=<n>' settings overriding the values of the 'small' preset (e.g.
+// 'files=5000,depth=3'), which can also follow a preset (e.g.
+// 'large,width=2'). Files are spread evenly over the directories of the tree,
+// and they have various names, extensions, sizes and modification times so
+// that sorting and previews look realistic. Configuration files are read as
+// usual, whereas the data files (e.g. marks and history) are kept in the
+// temporary directory, which is removed on exit as in the tutorial. Files are
+// written to the disk, where each of them takes a block (usually 4 KiB) at
+// least, so the number of files and directories is limited and the tree is
+// not generated when the disk does not have enough space for it.
+
+type demoShape struct {
+	files int
+	depth int
+	width int
+}
+
+var gDemoPresets = map[string]demoShape{
+	"small":  {files: 100, depth: 2, width: 3},
+	"medium": {files: 2000, depth: 3, width: 5},
+	"large":  {files: 50000, depth: 4, width: 8},
+}
+
+const (
+	gDemoMaxFiles = 100000
+	gDemoMaxDirs  = 10000
+
+	// estimated disk usage of each file and directory
+	gDemoBlockSize = 4096
+)
+
+var (
+	gDemoDirNames  = []string{"documents", "pictures", "music", "projects", "videos", "archive", "notes", "backup"}
+	gDemoFileNames = []string{"report", "photo", "notes", "song", "draft", "invoice", "holiday", "main", "readme", "budget", "letter", "clip"}
+	gDemoFileExts  = []string{".txt", ".jpg", ".md", ".mp3", ".pdf", ".go", ".tar.gz", ".png", ".csv", ".mp4", ""}
+)
+
+// This function parses the given shape of the generated tree.
+func parseDemoShape(arg string) (demoShape, error) {
+	shape := gDemoPresets["small"]
+
+	for _, s := range strings.Split(arg, ",") {
+		if preset, ok := gDemoPresets[s]; ok {
+			shape = preset
+			continue
+		}
+
+		key, val, found := strings.Cut(s, "=")
+		if !found {
+			return shape, fmt.Errorf("invalid shape: %s", s)
+		}
+
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return shape, fmt.Errorf("invalid value of %s: %s", key, val)
+		}
+
+		switch key {
+		case "files":
+			if n < 0 || n > gDemoMaxFiles {
+				return shape, fmt.Errorf("files should be between 0 and %d", gDemoMaxFiles)
+			}
+			shape.files = n
+		case "depth":
+			if n < 0 {
+				return shape, fmt.Errorf("depth should be a non-negative number")
+			}
+			shape.depth = n
+		case "width":
+			if n <= 0 {
+				return shape, fmt.Errorf("width should be a positive number")
+			}
+			shape.width = n
+		default:
+			return shape, fmt.Errorf("unknown setting: %s", key)
+		}
+	}
+
+	if demoDirCount(shape) > gDemoMaxDirs {
+		return shape, fmt.Errorf("depth and width should give at most %d directories", gDemoMaxDirs)
+	}
+
+	return shape, nil
+}
+
+// This function returns the number of directories of a tree with the given
+// shape, or a number larger than the limit when it is too large to count.
+func demoDirCount(shape demoShape) int {
+	count, level := 1, 1
+	for range shape.depth {
+		level *= shape.width
+		count += level
+		if count > gDemoMaxDirs {
+			return gDemoMaxDirs + 1
+		}
+	}
+	return count
+}
+
+// This function returns the estimated disk usage of a tree with the given
+// shape in bytes.
+func demoDiskUse(shape demoShape) int64 {
+	return int64(shape.files+demoDirCount(shape)) * gDemoBlockSize
+}
+
+// This function returns the directories of a tree with the given shape in
+// breadth first order, starting with the given root.
+func demoDirs(root string, shape demoShape) []string {
+	dirs := []string{root}
+	level := []string{root}
+	for range shape.depth {
+		var next []string
+		for _, dir := range level {
+			for i := range shape.width {
+				name := gDemoDirNames[i%len(gDemoDirNames)]
+				if i >= len(gDemoDirNames) {
+					name += strconv.Itoa(i / len(gDemoDirNames))
+				}
+				next = append(next, filepath.Join(dir, name))
+			}
+		}
+		dirs = append(dirs, next...)
+		level = next
+	}
+	return dirs
+}
+
+// This function generates a tree with the given shape in the given directory.
+// The same tree is generated for the same shape.
+func genDemoTree(root string, shape demoShape) error {
+	dirs := demoDirs(root, shape)
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for i := range shape.files {
+		name := gDemoFileNames[i%len(gDemoFileNames)]
+		ext := gDemoFileExts[i%len(gDemoFileExts)]
+		path := filepath.Join(dirs[i%len(dirs)], fmt.Sprintf("%s-%d%s", name, i/len(dirs)+1, ext))
+
+		data := strings.Repeat(fmt.Sprintf("%s %d\n", name, i), i%97)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			return err
+		}
+
+		// modification times are spread over the last year
+		mtime := now.Add(-time.Duration(i*7919%8760) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// This function creates the demo tree in a temporary directory and redirects
+// the data files into it. It returns the temporary directory, which should be
+// removed on exit, and changes the working directory to the tree.
+func setupDemo(arg string) (string, error) {
+	shape, err := parseDemoShape(arg)
+	if err != nil {
+		return "", err
+	}
+
+	if avail, err := diskAvail(os.TempDir()); err == nil && avail < demoDiskUse(shape) {
+		return "", fmt.Errorf("not enough space in %s: about %s needed but %s available",
+			os.TempDir(), humanize(demoDiskUse(shape)), humanize(avail))
+	}
+
+	root, err := os.MkdirTemp("", "lf-demo-")
+	if err != nil {
+		return "", fmt.Errorf("creating temporary directory: %s", err)
+	}
+
+	tree := filepath.Join(root, "demo")
+	if err := genDemoTree(tree, shape); err != nil {
+		return root, fmt.Errorf("creating tree: %s", err)
+	}
+
+	data := filepath.Join(root, "data")
+	gFilesPath = filepath.Join(data, "files")
+	gMarksPath = filepath.Join(data, "marks")
+	gTagsPath = filepath.Join(data, "tags")
+	gHistoryPath = filepath.Join(data, "history")
+	gAuditPath = filepath.Join(data, "audit")
+	gRecoveryPath = filepath.Join(data, "recovery")
+	gTrustPath = filepath.Join(data, "trust")
+	gSortsPath = filepath.Join(data, "sorts")
+	gSessionPath = filepath.Join(data, "session")
+	gJumpPath = filepath.Join(data, "dirs")
+
+	if err := os.Chdir(tree); err != nil {
+		return root, fmt.Errorf("changing directory: %s", err)
+	}
+
+	gDemoDir = tree
+
+	return root, nil
+}
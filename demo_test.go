@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDemoShape(t *testing.T) {
+	tests := []struct {
+		arg string
+		exp demoShape
+		err bool
+	}{
+		{"small", demoShape{100, 2, 3}, false},
+		{"large", demoShape{50000, 4, 8}, false},
+		{"files=500", demoShape{500, 2, 3}, false},
+		{"files=500,depth=0", demoShape{500, 0, 3}, false},
+		{"medium,width=2", demoShape{2000, 3, 2}, false},
+		{"huge", demoShape{}, true},
+		{"files=abc", demoShape{}, true},
+		{"width=0", demoShape{}, true},
+		{"size=10", demoShape{}, true},
+		{"files=100000", demoShape{100000, 2, 3}, false},
+		{"files=100001", demoShape{}, true},
+		{"depth=3,width=10", demoShape{100, 3, 10}, false},
+		{"depth=4,width=10", demoShape{}, true},
+		{"depth=100,width=100", demoShape{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseDemoShape(test.arg)
+		if (err != nil) != test.err {
+			t.Errorf("at input '%s' expected error '%t' but got '%v'", test.arg, test.err, err)
+			continue
+		}
+		if !test.err && got != test.exp {
+			t.Errorf("at input '%s' expected '%v' but got '%v'", test.arg, test.exp, got)
+		}
+	}
+}
+
+func TestDemoDiskUse(t *testing.T) {
+	tests := []struct {
+		shape demoShape
+		exp   int64
+	}{
+		{demoShape{files: 0, depth: 0, width: 1}, 4096},
+		{demoShape{files: 50, depth: 2, width: 2}, 57 * 4096},
+		{gDemoPresets["large"], (50000 + 4681) * 4096},
+	}
+
+	for _, test := range tests {
+		if got := demoDiskUse(test.shape); got != test.exp {
+			t.Errorf("at input '%v' expected '%d' but got '%d'", test.shape, test.exp, got)
+		}
+	}
+}
+
+func TestGenDemoTree(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "demo")
+	shape := demoShape{files: 50, depth: 2, width: 2}
+
+	if err := genDemoTree(root, shape); err != nil {
+		t.Fatalf("generating tree: %s", err)
+	}
+
+	dirs, files := 0, 0
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.IsDir() {
+			dirs++
+		} else {
+			files++
+		}
+		return nil
+	})
+
+	if dirs != 7 || files != shape.files {
+		t.Errorf("at shape '%v' expected '7' directories and '%d' files but got '%d' and '%d'", shape, shape.files, dirs, files)
+	}
+}
@@ -3,19 +3,16 @@
 package main
 
 import (
-	"log"
-
 	"golang.org/x/sys/unix"
 )
 
-func diskFree(wd string) string {
+func diskAvail(wd string) (int64, error) {
 	var stat unix.Statfs_t
 
 	if err := unix.Statfs(wd, &stat); err != nil {
-		log.Printf("diskfree: %s", err)
-		return ""
+		return 0, err
 	}
 
 	// Available blocks * size per block = available space in bytes
-	return "df: " + humanize(int64(uint64(stat.Bavail)*uint64(stat.Bsize)))
+	return int64(uint64(stat.Bavail) * uint64(stat.Bsize)), nil
 }
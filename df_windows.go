@@ -1,23 +1,19 @@
 package main
 
 import (
-	"log"
-
 	"golang.org/x/sys/windows"
 )
 
-func diskFree(wd string) string {
+func diskAvail(wd string) (int64, error) {
 	var free uint64
 
 	pathPtr, err := windows.UTF16PtrFromString(wd)
 	if err != nil {
-		log.Printf("diskfree: %s", err)
-		return ""
+		return 0, err
 	}
 	err = windows.GetDiskFreeSpaceEx(pathPtr, &free, nil, nil) // cwd, free, total, available
 	if err != nil {
-		log.Printf("diskfree: %s", err)
-		return ""
+		return 0, err
 	}
-	return "df: " + humanize(int64(free))
+	return int64(free), nil
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// Digraphs are two character sequences used to insert characters that are not
+// available on the keyboard with the 'cmd-digraph' command. The sequences are
+// taken from RFC 1345 as in Vim, where the first character is usually the base
+// letter and the second one is the accent or the modifier (e.g. "e'" for 'é').
+var gDigraphs = map[string]rune{
+	// lowercase accented letters
+	"a!": 'à', "a'": 'á', "a>": 'â', "a?": 'ã', "a:": 'ä', "aa": 'å', "ae": 'æ',
+	"c,": 'ç', "e!": 'è', "e'": 'é', "e>": 'ê', "e:": 'ë', "i!": 'ì', "i'": 'í',
+	"i>": 'î', "i:": 'ï', "d-": 'ð', "n?": 'ñ', "o!": 'ò', "o'": 'ó', "o>": 'ô',
+	"o?": 'õ', "o:": 'ö', "o/": 'ø', "u!": 'ù', "u'": 'ú', "u>": 'û', "u:": 'ü',
+	"y'": 'ý', "th": 'þ', "y:": 'ÿ', "ss": 'ß', "a-": 'ā', "a(": 'ă', "a;": 'ą',
+	"c'": 'ć', "c<": 'č', "d<": 'ď', "d/": 'đ', "e-": 'ē', "e.": 'ė', "e;": 'ę',
+	"e<": 'ě', "g(": 'ğ', "i-": 'ī', "i;": 'į', "l/": 'ł', "l<": 'ľ', "n'": 'ń',
+	"n<": 'ň', "o-": 'ō', "o\"": 'ő', "oe": 'œ', "r<": 'ř', "s'": 'ś', "s,": 'ş',
+	"s<": 'š', "t,": 'ţ', "t<": 'ť', "u-": 'ū', "u0": 'ů', "u\"": 'ű', "u;": 'ų',
+	"z'": 'ź', "z.": 'ż', "z<": 'ž',
+
+	// uppercase accented letters
+	"A!": 'À', "A'": 'Á', "A>": 'Â', "A?": 'Ã', "A:": 'Ä', "AA": 'Å', "AE": 'Æ',
+	"C,": 'Ç', "E!": 'È', "E'": 'É', "E>": 'Ê', "E:": 'Ë', "I!": 'Ì', "I'": 'Í',
+	"I>": 'Î', "I:": 'Ï', "D-": 'Ð', "N?": 'Ñ', "O!": 'Ò', "O'": 'Ó', "O>": 'Ô',
+	"O?": 'Õ', "O:": 'Ö', "O/": 'Ø', "U!": 'Ù', "U'": 'Ú', "U>": 'Û', "U:": 'Ü',
+	"Y'": 'Ý', "TH": 'Þ', "Y:": 'Ÿ', "A-": 'Ā', "A(": 'Ă', "A;": 'Ą', "C'": 'Ć',
+	"C<": 'Č', "D<": 'Ď', "D/": 'Đ', "E-": 'Ē', "E.": 'Ė', "E;": 'Ę', "E<": 'Ě',
+	"G(": 'Ğ', "I-": 'Ī', "I;": 'Į', "L/": 'Ł', "L<": 'Ľ', "N'": 'Ń', "N<": 'Ň',
+	"O-": 'Ō', "O\"": 'Ő', "OE": 'Œ', "R<": 'Ř', "S'": 'Ś', "S,": 'Ş', "S<": 'Š',
+	"T,": 'Ţ', "T<": 'Ť', "U-": 'Ū', "U0": 'Ů', "U\"": 'Ű', "U;": 'Ų', "Z'": 'Ź',
+	"Z.": 'Ż', "Z<": 'Ž',
+
+	// greek letters
+	"a*": 'α', "b*": 'β', "g*": 'γ', "d*": 'δ', "e*": 'ε', "z*": 'ζ', "y*": 'η',
+	"h*": 'θ', "i*": 'ι', "k*": 'κ', "l*": 'λ', "m*": 'μ', "n*": 'ν', "c*": 'ξ',
+	"o*": 'ο', "p*": 'π', "r*": 'ρ', "s*": 'σ', "*s": 'ς', "t*": 'τ', "u*": 'υ',
+	"f*": 'φ', "x*": 'χ', "q*": 'ψ', "w*": 'ω', "A*": 'Α', "B*": 'Β', "G*": 'Γ',
+	"D*": 'Δ', "E*": 'Ε', "Z*": 'Ζ', "Y*": 'Η', "H*": 'Θ', "I*": 'Ι', "K*": 'Κ',
+	"L*": 'Λ', "M*": 'Μ', "N*": 'Ν', "C*": 'Ξ', "O*": 'Ο', "P*": 'Π', "R*": 'Ρ',
+	"S*": 'Σ', "T*": 'Τ', "U*": 'Υ', "F*": 'Φ', "X*": 'Χ', "Q*": 'Ψ', "W*": 'Ω',
+
+	// punctuation and symbols
+	"NS": ' ', "!I": '¡', "?I": '¿', "SE": '§', "PI": '¶', "Co": '©',
+	"Rg": '®', "TM": '™', "DG": '°', "+-": '±', "*X": '×', "-:": '÷', ".M": '·',
+	"<<": '«', ">>": '»', "'6": '‘', "'9": '’', "\"6": '“', "\"9": '”', ".9": '‚',
+	":9": '„', "-N": '–', "-M": '—', ",.": '…', "/-": '†', "/=": '‡', "oo": '•',
+	"Ct": '¢', "Pd": '£', "Ye": '¥', "Eu": '€', "=R": '₽', "12": '½', "14": '¼',
+	"34": '¾', "1S": '¹', "2S": '²', "3S": '³', "%0": '‰', "OK": '✓', "XX": '✗',
+
+	// arrows and mathematical symbols
+	"<-": '←', "-!": '↑', "->": '→', "-v": '↓', "<>": '↔', "UD": '↕', "<=": '⇐',
+	"=>": '⇒', "==": '⇔', "FA": '∀', "dP": '∂', "TE": '∃', "/0": '∅', "DE": '∆',
+	"(-": '∈', "-)": '∋', "*P": '∏', "+Z": '∑', "RT": '√', "00": '∞', "AN": '∧',
+	"OR": '∨', "(U": '∩', ")U": '∪', "In": '∫', "?=": '≅', "?2": '≈', "!=": '≠',
+	"=3": '≡', "=<": '≤', ">=": '≥', "(C": '⊂', ")C": '⊃', "(_": '⊆', ")_": '⊇',
+}
+
+// This function returns the character for the given digraph. Characters can
+// also be given in the reverse order (e.g. "'e" for 'é') as long as the
+// reverse is not a digraph itself.
+func digraph(a, b rune) (rune, bool) {
+	if r, ok := gDigraphs[string([]rune{a, b})]; ok {
+		return r, true
+	}
+	r, ok := gDigraphs[string([]rune{b, a})]
+	return r, ok
+}
+
+// This function returns the maximum number of digits for the given codepoint
+// prefix of the 'cmd-literal' command, which is 'x' for two hexadecimal
+// digits, 'u' for four and 'U' for eight as in Vim.
+func codepointDigits(prefix rune) int {
+	switch prefix {
+	case 'x', 'X':
+		return 2
+	case 'u':
+		return 4
+	case 'U':
+		return 8
+	}
+	return 0
+}
+
+// This function returns the character for the given codepoint with a prefix
+// (e.g. "u2192" for '→').
+func codepoint(s string) (rune, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		return 0, false
+	}
+	return rune(n), true
+}
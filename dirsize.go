@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sizes of directories can be computed in the background with the 'dirsize'
+// command, unlike 'calcdirsize' which blocks the user interface until it is
+// finished. Directories are walked by a pool of workers, and the size column
+// is updated with running totals as they are walked. Directories added by
+// later 'dirsize' commands are walked along with the earlier ones, and all of
+// them are stopped with 'dirsize --cancel'.
+//
+// When the 'hoverdirsize' option is enabled, the size of the hovered directory
+// is also computed in the background in the same way, and the walk is
+// cancelled as soon as the cursor moves to another file, so that moving past
+// large directories does not keep walking them. Running totals are exported
+// to previewers and shell commands in '$lf_hoverdir_size_partial', and the
+// final size in '$lf_hoverdir_size' once the walk is finished, after which
+// the preview is loaded again when the directory is previewed with the
+// previewer.
+
+// Running totals are sent at most once in this interval for each directory.
+const gDirSizeInterval = 100 * time.Millisecond
+
+var errDirSizeCancelled = errors.New("cancelled")
+
+type dirSizeUpdate struct {
+	path string
+	size int64
+	done bool
+	err  error
+}
+
+// Updates of the hovered directory are sent with the number of the walk, so
+// that updates of earlier walks of the same directory are ignored.
+type hoverSizeUpdate struct {
+	dirSizeUpdate
+	id int
+}
+
+// This function computes the sizes of the given directories with a pool of
+// workers and sends the running totals and the results to the given channel.
+// A single result is sent for each directory including the cancelled ones.
+func calcDirSizes(paths []string, cancel <-chan struct{}, out chan<- dirSizeUpdate) {
+	jobs := make(chan string)
+
+	var wg sync.WaitGroup
+	for range min(runtime.NumCPU(), len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				size, err := walkSize(path, cancel, func(n int64) {
+					out <- dirSizeUpdate{path: path, size: n}
+				})
+				out <- dirSizeUpdate{path, size, true, err}
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		jobs <- path
+	}
+	close(jobs)
+
+	wg.Wait()
+}
+
+// This function sets the size of the given directory in the loaded directories.
+// Negative sizes are shown as unknown.
+func (nav *nav) setDirSize(path string, size int64) {
+	set := func(d *dir) {
+		if d.path != filepath.Dir(path) {
+			return
+		}
+		for _, f := range d.allFiles {
+			if f.path == path {
+				f.dirSize = size
+			}
+		}
+	}
+
+	for _, d := range nav.dirs {
+		set(d)
+	}
+	for _, d := range nav.dirCache {
+		set(d)
+	}
+}
+
+// This function returns the selected directories, or the directories shown in
+// the current directory when there is no selection.
+func (nav *nav) dirSizePaths() []string {
+	var paths []string
+	if len(nav.selections) == 0 {
+		for _, f := range nav.currDir().files {
+			if f.IsDir() {
+				paths = append(paths, f.path)
+			}
+		}
+		return paths
+	}
+
+	for _, sel := range nav.currSelections() {
+		if lstat, err := lstatPath(sel); err == nil && lstat.IsDir() {
+			paths = append(paths, sel)
+		}
+	}
+	return paths
+}
+
+func (app *app) dirSize() {
+	paths := app.nav.dirSizePaths()
+	if len(paths) == 0 {
+		app.ui.echoerr("dirsize: no directories found")
+		return
+	}
+
+	if gHeadless {
+		out := make(chan dirSizeUpdate, 1024)
+		go func() {
+			calcDirSizes(paths, nil, out)
+			close(out)
+		}()
+		for u := range out {
+			if !u.done {
+				continue
+			}
+			if u.err != nil {
+				app.ui.echoerrf("dirsize: %s", u.err)
+				continue
+			}
+			app.nav.setDirSize(u.path, u.size)
+			app.ui.echomsg(fmt.Sprintf("%s\t%s", humanize(u.size), u.path))
+		}
+		return
+	}
+
+	if app.nav.dirSizeCancel == nil {
+		app.nav.dirSizeCancel = make(chan struct{})
+	}
+	app.nav.dirSizeTotal += len(paths)
+	for _, path := range paths {
+		app.nav.setDirSize(path, -1)
+	}
+
+	go calcDirSizes(paths, app.nav.dirSizeCancel, app.nav.dirSizeChan)
+
+	app.ui.draw(app.nav)
+}
+
+func (app *app) cancelDirSize() {
+	if app.nav.dirSizeCancel == nil {
+		app.ui.echoerr("dirsize: no sizes are being computed")
+		return
+	}
+	close(app.nav.dirSizeCancel)
+	app.nav.dirSizeCancel = nil
+}
+
+// This function applies an update sent by the workers, which is called from
+// the main loop.
+func (app *app) updateDirSize(u dirSizeUpdate) {
+	if !u.done {
+		app.nav.setDirSize(u.path, u.size)
+		app.ui.draw(app.nav)
+		return
+	}
+
+	if u.err != nil {
+		app.nav.setDirSize(u.path, -1)
+		if !errors.Is(u.err, errDirSizeCancelled) {
+			app.ui.echoerrf("dirsize: %s", u.err)
+		}
+	} else {
+		app.nav.setDirSize(u.path, u.size)
+	}
+
+	if app.nav.dirSizeCount++; app.nav.dirSizeCount >= app.nav.dirSizeTotal {
+		app.nav.dirSizeCount = 0
+		app.nav.dirSizeTotal = 0
+		app.nav.dirSizeCancel = nil
+
+		// files are only sorted at the end so that the cursor does not jump
+		// around while the sizes are updated
+		app.ui.loadFileInfo(app.nav)
+		app.nav.sort()
+		app.ui.sort()
+	}
+
+	app.ui.draw(app.nav)
+}
+
+// This function starts computing the size of the given hovered file when it is
+// a directory, and cancels the walk of the previously hovered directory.
+func (app *app) hoverDirSize(curr *file) {
+	if app.nav.hoverSizeCancel != nil {
+		close(app.nav.hoverSizeCancel)
+		app.nav.hoverSizeCancel = nil
+		app.nav.setDirSize(app.nav.hoverSizePath, -1)
+	}
+	app.nav.hoverSizeID++
+	app.nav.hoverSizePath = ""
+	os.Setenv("lf_hoverdir_size_partial", "")
+	os.Setenv("lf_hoverdir_size", "")
+
+	if !gOpts.hoverdirsize || gHeadless || !curr.IsDir() || inImage(curr.path) || inRemote(curr.path) {
+		return
+	}
+
+	// sizes computed before (e.g. with 'dirsize') are used as they are
+	if curr.dirSize >= 0 {
+		size := strconv.FormatInt(curr.dirSize, 10)
+		os.Setenv("lf_hoverdir_size_partial", size)
+		os.Setenv("lf_hoverdir_size", size)
+		return
+	}
+
+	cancel := make(chan struct{})
+	app.nav.hoverSizeCancel = cancel
+	app.nav.hoverSizePath = curr.path
+
+	path, id, out := curr.path, app.nav.hoverSizeID, app.nav.hoverSizeChan
+	go func() {
+		size, err := walkSize(path, cancel, func(n int64) {
+			out <- hoverSizeUpdate{dirSizeUpdate{path: path, size: n}, id}
+		})
+		out <- hoverSizeUpdate{dirSizeUpdate{path, size, true, err}, id}
+	}()
+}
+
+// This function applies an update for the hovered directory, which is called
+// from the main loop. Updates of directories that are no longer hovered are
+// ignored.
+func (app *app) updateHoverSize(u hoverSizeUpdate) {
+	if u.id != app.nav.hoverSizeID {
+		return
+	}
+
+	if u.err != nil {
+		app.nav.hoverSizeCancel = nil
+		app.nav.setDirSize(u.path, -1)
+		log.Printf("hoverdirsize: %s", u.err)
+		return
+	}
+
+	size := strconv.FormatInt(u.size, 10)
+	os.Setenv("lf_hoverdir_size_partial", size)
+	app.nav.setDirSize(u.path, u.size)
+
+	if u.done {
+		app.nav.hoverSizeCancel = nil
+		os.Setenv("lf_hoverdir_size", size)
+		if curr, err := app.nav.currFile(); err == nil && curr.path == u.path && isRegPreview(curr) {
+			delete(app.nav.regCache, u.path)
+			app.ui.loadFile(app, false)
+		}
+	}
+
+	if app.ui.msgIsStat {
+		app.ui.loadFileInfo(app.nav)
+	}
+	app.ui.draw(app.nav)
+}
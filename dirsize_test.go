@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestCalcDirSizes(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]int{
+		"a/x":   3,
+		"a/b/y": 5,
+		"c/z":   7,
+	}
+	for name, size := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := []string{filepath.Join(dir, "a"), filepath.Join(dir, "c")}
+
+	results := func(cancel chan struct{}) map[string]dirSizeUpdate {
+		out := make(chan dirSizeUpdate, 1024)
+		go func() {
+			calcDirSizes(paths, cancel, out)
+			close(out)
+		}()
+		got := make(map[string]dirSizeUpdate)
+		for u := range out {
+			if !u.done {
+				continue
+			}
+			if _, ok := got[u.path]; ok {
+				t.Errorf("expected a single result for '%s'", u.path)
+			}
+			got[u.path] = u
+		}
+		return got
+	}
+
+	got := results(nil)
+	for _, path := range paths {
+		exp, err := dirSize(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u := got[path]; u.err != nil || u.size != exp {
+			t.Errorf("at input '%s' expected '%d' but got '%d' with error '%v'", path, exp, u.size, u.err)
+		}
+	}
+
+	cancel := make(chan struct{})
+	close(cancel)
+	got = results(cancel)
+	if len(got) != len(paths) {
+		t.Fatalf("expected %d results but got %d", len(paths), len(got))
+	}
+	for path, u := range got {
+		if u.err != errDirSizeCancelled {
+			t.Errorf("at input '%s' expected cancelled but got error '%v'", path, u.err)
+		}
+	}
+}
+
+func TestHoverDirSize(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "b", "x"), make([]byte, 5), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "y"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(hoverdirsize bool) { gOpts.hoverdirsize = hoverdirsize }(gOpts.hoverdirsize)
+	gOpts.hoverdirsize = true
+	t.Setenv("lf_hoverdir_size_partial", "")
+	t.Setenv("lf_hoverdir_size", "")
+
+	a := newFile(filepath.Join(root, "a"))
+	y := newFile(filepath.Join(root, "y"))
+	app := &app{nav: &nav{
+		dirs:          []*dir{{path: root, allFiles: []*file{a, y}}},
+		hoverSizeChan: make(chan hoverSizeUpdate, 1024),
+	}}
+
+	app.hoverDirSize(a)
+	var u hoverSizeUpdate
+	for u = range app.nav.hoverSizeChan {
+		if u.done {
+			break
+		}
+	}
+	exp, err := dirSize(a.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.err != nil || u.size != exp || u.id != app.nav.hoverSizeID {
+		t.Errorf("at input '%s' expected '%d' but got '%d' with error '%v'", a.path, exp, u.size, u.err)
+	}
+
+	// finished walks are cleared by the main loop
+	app.nav.hoverSizeCancel = nil
+	a.dirSize = exp
+	app.hoverDirSize(a)
+	if got := os.Getenv("lf_hoverdir_size"); got != strconv.FormatInt(exp, 10) {
+		t.Errorf("at input '%s' expected the known size '%d' to be exported but got '%s'", a.path, exp, got)
+	}
+	if app.nav.hoverSizeCancel != nil {
+		t.Errorf("at input '%s' expected no walk for a known size", a.path)
+	}
+
+	a.dirSize = -1
+	app.hoverDirSize(a)
+	cancel := app.nav.hoverSizeCancel
+	app.hoverDirSize(y)
+	select {
+	case <-cancel:
+	default:
+		t.Errorf("at input '%s' expected the walk to be cancelled when the cursor moves", a.path)
+	}
+	if os.Getenv("lf_hoverdir_size_partial") != "" || a.dirSize != -1 {
+		t.Errorf("at input '%s' expected the partial size to be cleared", a.path)
+	}
+	for u := range app.nav.hoverSizeChan {
+		if u.id == app.nav.hoverSizeID {
+			t.Errorf("expected updates of the cancelled walk to be ignored")
+		}
+		if u.done {
+			break
+		}
+	}
+}
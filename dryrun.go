@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Dry-run mode shows what the commands modifying the filesystem (i.e. 'paste',
+// 'delete', 'rename', 'bulkrename', 'trash', 'restore', 'copy-to-other',
+// 'move-to-other', 'cleanup-trash', 'archive', 'split-file', 'join-files',
+// 'hashdir', 'undo', 'redo', 'retry-failed' and extracting archives when
+// opened with 'autoextract') would do instead of running them, to verify large
+// operations beforehand. It is enabled globally with the 'dryrun' option (or
+// the 'dry-run' command), or for a single command with the '--dry-run' flag.
+
+func isDryRun(args []string) bool {
+	return gOpts.dryrun || slices.Contains(args, "--dry-run")
+}
+
+// This function returns the operations that 'paste' would run. Existing
+// destination files are not overwritten but renamed using 'dupfilefmt'.
+func (nav *nav) pastePlan() ([]string, error) {
+	srcs, cp, err := loadFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(srcs) == 0 {
+		return nil, fmt.Errorf("no file in copy/cut buffer")
+	}
+
+	return copyPlan(srcs, nav.currDir().path, cp, false), nil
+}
+
+// This function returns the operations to copy or move the given files to the
+// given directory, as used by 'paste', 'copy-to-other', 'move-to-other' and
+// 'retry-failed'. Existing destination files are renamed using 'dupfilefmt',
+// unless overwrite is set.
+func copyPlan(srcs []string, dstDir string, cp, overwrite bool) []string {
+	op := "move"
+	if cp {
+		op = "copy"
+	}
+
+	var plan []string
+	for _, src := range srcs {
+		dst := filepath.Join(dstDir, filepath.Base(src))
+		line := fmt.Sprintf("%s '%s' to '%s'", op, src, dst)
+		if _, err := os.Lstat(dst); err == nil {
+			if overwrite {
+				line += " (exists, overwritten)"
+			} else {
+				line += " (exists, renamed using dupfilefmt)"
+			}
+		}
+		plan = append(plan, line)
+	}
+
+	return plan
+}
+
+// This function returns the operations that 'delete' would run for the given
+// files.
+func deletePlan(list []string) []string {
+	var plan []string
+	for _, path := range list {
+		line := fmt.Sprintf("delete '%s'", path)
+		if stat, err := os.Lstat(path); err == nil && stat.IsDir() {
+			line += " (directory and its contents)"
+		}
+		plan = append(plan, line)
+	}
+	return plan
+}
+
+// This function returns the operations that 'trash' would run for the given
+// files.
+func trashPlan(list []string) []string {
+	var plan []string
+	for _, path := range list {
+		line := fmt.Sprintf("trash '%s'", path)
+		if stat, err := os.Lstat(path); err == nil && stat.IsDir() {
+			line += " (directory and its contents)"
+		}
+		plan = append(plan, line)
+	}
+	return plan
+}
+
+// This function returns the operations that 'restore' would run for the given
+// trashed files. Existing files are not overwritten.
+func restorePlan(list []*trashItem) []string {
+	var plan []string
+	for _, item := range list {
+		line := fmt.Sprintf("restore '%s' to '%s'", item.file, item.path)
+		if _, err := os.Lstat(item.path); err == nil {
+			line += " (exists, not restored)"
+		}
+		plan = append(plan, line)
+	}
+	return plan
+}
+
+// This function returns the operations that 'undo' would run for the given
+// journal entry, or 'redo' when redo is set. Files are listed in the order they
+// are reversed.
+func undoPlan(e *journalEntry, redo bool) []string {
+	var plan []string
+	for i := len(e.files) - 1; i >= 0; i-- {
+		f := e.files[i]
+		switch {
+		case e.op == "copy" && !redo:
+			plan = append(plan, trashPlan([]string{f.dst})...)
+		case e.op == "copy" && redo, e.op == "trash" && !redo:
+			plan = append(plan, restorePlan([]*trashItem{f.item})...)
+		case e.op == "trash" && redo:
+			plan = append(plan, trashPlan([]string{f.src})...)
+		case e.op == "move" || e.op == "rename":
+			src, dst := f.dst, f.src
+			if redo {
+				src, dst = f.src, f.dst
+			}
+			line := fmt.Sprintf("move '%s' to '%s'", src, dst)
+			if _, err := os.Lstat(dst); err == nil {
+				line += " (exists, not moved)"
+			}
+			plan = append(plan, line)
+		}
+	}
+	return plan
+}
+
+// This function returns the given arguments without the '--dry-run' flag.
+func dryRunArgs(args []string) []string {
+	return slices.DeleteFunc(slices.Clone(args), func(arg string) bool { return arg == "--dry-run" })
+}
+
+// This function shows the operations of a dry run. They are also written to
+// the log file. A single operation is shown in the message line, while more
+// are shown in the pager, except in headless mode where all of them are
+// printed as messages.
+func (app *app) showDryRun(name string, plan []string) {
+	for _, line := range plan {
+		log.Printf("dry-run: %s", line)
+	}
+
+	if len(plan) == 1 || gHeadless {
+		for _, line := range plan {
+			app.ui.echomsg("dry-run: " + line)
+		}
+		return
+	}
+
+	app.ui.pager = newPager(fmt.Sprintf("dry-run: %s (%d operations)", name, len(plan)), strings.Join(plan, "\n"))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPastePlan(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	for _, path := range []string{filepath.Join(src, "a"), filepath.Join(src, "b"), filepath.Join(dst, "b")} {
+		if err := os.MkdirAll(path, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(path string) { gFilesPath = path }(gFilesPath)
+	gFilesPath = filepath.Join(tmp, "files")
+	if err := saveFiles([]string{filepath.Join(src, "a"), filepath.Join(src, "b")}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	nav := newNav(10)
+	nav.getDirs(dst)
+
+	plan, err := nav.pastePlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"move '" + filepath.Join(src, "a") + "' to '" + filepath.Join(dst, "a") + "'",
+		"move '" + filepath.Join(src, "b") + "' to '" + filepath.Join(dst, "b") + "' (exists, renamed using dupfilefmt)",
+	}
+	if !reflect.DeepEqual(plan, exp) {
+		t.Errorf("expected plan '%v' but got '%v'", exp, plan)
+	}
+
+	for _, path := range []string{filepath.Join(src, "a"), filepath.Join(src, "b")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected '%s' to be left in place but got '%s'", path, err)
+		}
+	}
+}
+
+func TestTrashPlan(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "dir")
+	file := filepath.Join(tmp, "file")
+	if err := os.Mkdir(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"trash '" + dir + "' (directory and its contents)", "trash '" + file + "'"}
+	if plan := trashPlan([]string{dir, file}); !reflect.DeepEqual(plan, exp) {
+		t.Errorf("expected plan '%v' but got '%v'", exp, plan)
+	}
+
+	items := []*trashItem{
+		{path: file, file: filepath.Join(tmp, "trash", "file")},
+		{path: filepath.Join(tmp, "gone"), file: filepath.Join(tmp, "trash", "gone")},
+	}
+	exp = []string{
+		"restore '" + items[0].file + "' to '" + file + "' (exists, not restored)",
+		"restore '" + items[1].file + "' to '" + items[1].path + "'",
+	}
+	if plan := restorePlan(items); !reflect.DeepEqual(plan, exp) {
+		t.Errorf("expected plan '%v' but got '%v'", exp, plan)
+	}
+
+	if args := dryRunArgs([]string{"a", "--dry-run", "b"}); !reflect.DeepEqual(args, []string{"a", "b"}) {
+		t.Errorf("expected arguments without the flag but got '%v'", args)
+	}
+}
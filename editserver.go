@@ -0,0 +1,89 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Files can be opened in an editor server that is already running with the
+// 'edit-in-server' command, instead of starting a nested editor inside lf
+// (e.g. when lf is running in a terminal of the editor). Neovim is used when
+// its server address is set in the environment, which is the case in its
+// terminals, and Emacs is used when 'emacsclient' can connect to a server.
+// The editor is started in the foreground as usual when there is no server.
+
+// An editor server is tried with a command that fails when the server is not
+// available, so that the next one can be tried.
+type editServer struct {
+	name string
+	cmd  func(paths []string) *exec.Cmd
+}
+
+var gEditServers = []editServer{
+	{"nvim", func(paths []string) *exec.Cmd {
+		addr := cmp.Or(os.Getenv("NVIM"), os.Getenv("NVIM_LISTEN_ADDRESS"))
+		if addr == "" {
+			return nil
+		}
+		return exec.Command("nvim", append([]string{"--server", addr, "--remote"}, paths...)...)
+	}},
+	{"emacsclient", func(paths []string) *exec.Cmd {
+		// an alternate editor that fails is used to detect that there is no
+		// server instead of starting a new emacs
+		return exec.Command("emacsclient", append([]string{"--no-wait", "--alternate-editor=false", "--"}, paths...)...)
+	}},
+}
+
+// This function opens the given files in the first available editor server
+// and returns its name, or an empty string when there is none.
+func openInServer(paths []string) string {
+	for _, srv := range gEditServers {
+		if _, err := exec.LookPath(srv.name); err != nil {
+			continue
+		}
+		cmd := srv.cmd(paths)
+		if cmd == nil {
+			continue
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("edit-in-server: %s: %s: %s", srv.name, err, strings.TrimSpace(string(out)))
+			continue
+		}
+		return srv.name
+	}
+	return ""
+}
+
+func (app *app) editInServer() {
+	list, err := app.nav.currFileOrSelections()
+	if err != nil {
+		app.ui.echoerrf("edit-in-server: %s", err)
+		return
+	}
+
+	if name := openInServer(list); name != "" {
+		app.ui.echo(fmt.Sprintf("edit-in-server: opened %d files in %s", len(list), name))
+		return
+	}
+
+	if gHeadless {
+		app.ui.echoerr("edit-in-server: no running editor server found")
+		return
+	}
+
+	editor := strings.Fields(envEditor)
+	if len(editor) == 0 {
+		app.ui.echoerr("edit-in-server: no editor set")
+		return
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], list...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	app.runCmdSync(cmd, false)
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenInServer(t *testing.T) {
+	bin := t.TempDir()
+	out := filepath.Join(bin, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + out + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "nvim"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "emacsclient"), []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	t.Setenv("NVIM_LISTEN_ADDRESS", "")
+
+	t.Setenv("NVIM", "")
+	if got := openInServer([]string{"/a"}); got != "" {
+		t.Errorf("expected no server but got '%s'", got)
+	}
+
+	t.Setenv("NVIM", "/tmp/nvim.sock")
+	if got := openInServer([]string{"/a", "/b c"}); got != "nvim" {
+		t.Fatalf("expected 'nvim' but got '%s'", got)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "--server\n/tmp/nvim.sock\n--remote\n/a\n/b c\n"
+	if string(data) != exp {
+		t.Errorf("expected arguments '%s' but got '%s'", strings.ReplaceAll(exp, "\n", " "), strings.ReplaceAll(string(data), "\n", " "))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
@@ -57,13 +58,42 @@ func applyLocalBoolOpt(localOpt map[string]bool, globalOpt bool, e *setLocalExpr
 	return nil
 }
 
+// This function shows the sort order of the current directory after it is
+// changed, since files can move around a lot in large directories.
+func (app *app) showSort() {
+	if !app.nav.init || gHeadless {
+		return
+	}
+
+	dir := app.nav.currDir()
+
+	var flags []string
+	if dir.reverse {
+		flags = append(flags, "reverse")
+	}
+	if dir.hidden {
+		flags = append(flags, "hidden files shown")
+	}
+
+	msg := "sorted by " + string(dir.sortby)
+	if len(flags) != 0 {
+		msg += " (" + strings.Join(flags, ", ") + ")"
+	}
+	app.ui.echo(msg)
+}
+
 func (e *setExpr) eval(app *app, args []string) {
 	var err error
+	sorted := false
 	switch e.opt {
 	case "anchorfind", "noanchorfind", "anchorfind!":
 		err = applyBoolOpt(&gOpts.anchorfind, e)
+	case "auditlog", "noauditlog", "auditlog!":
+		err = applyBoolOpt(&gOpts.auditlog, e)
 	case "autoquit", "noautoquit", "autoquit!":
 		err = applyBoolOpt(&gOpts.autoquit, e)
+	case "autosavesession", "noautosavesession", "autosavesession!":
+		err = applyBoolOpt(&gOpts.autosavesession, e)
 	case "dircache", "nodircache", "dircache!":
 		err = applyBoolOpt(&gOpts.dircache, e)
 	case "dircounts", "nodircounts", "dircounts!":
@@ -88,6 +118,17 @@ func (e *setExpr) eval(app *app, args []string) {
 		}
 	case "dirpreviews", "nodirpreviews", "dirpreviews!":
 		err = applyBoolOpt(&gOpts.dirpreviews, e)
+	case "dryrun", "nodryrun", "dryrun!":
+		err = applyBoolOpt(&gOpts.dryrun, e)
+	case "dualpane", "nodualpane", "dualpane!":
+		err = applyBoolOpt(&gOpts.dualpane, e)
+		if err == nil {
+			if hasGraphics() {
+				app.ui.sxScreen.forceClear = true
+			}
+			app.ui.renew()
+			app.ui.loadFile(app, true)
+		}
 	case "drawbox", "nodrawbox", "drawbox!":
 		err = applyBoolOpt(&gOpts.drawbox, e)
 		if err == nil {
@@ -115,9 +156,33 @@ func (e *setExpr) eval(app *app, args []string) {
 			app.nav.position()
 			app.ui.sort()
 			app.ui.loadFile(app, true)
+			sorted = true
+		}
+	case "gitstatus", "nogitstatus", "gitstatus!":
+		err = applyBoolOpt(&gOpts.gitstatus, e)
+		if err == nil {
+			app.refreshGitStatus()
 		}
 	case "history", "nohistory", "history!":
 		err = applyBoolOpt(&gOpts.history, e)
+	case "hoverdirsize", "nohoverdirsize", "hoverdirsize!":
+		err = applyBoolOpt(&gOpts.hoverdirsize, e)
+		if err == nil && app.nav.init {
+			if curr, err := app.nav.currFile(); err == nil {
+				app.hoverDirSize(curr)
+			}
+		}
+	case "hoverpreviewdir", "nohoverpreviewdir", "hoverpreviewdir!":
+		err = applyBoolOpt(&gOpts.hoverpreviewdir, e)
+	case "linkedpane", "nolinkedpane", "linkedpane!":
+		err = applyBoolOpt(&gOpts.linkedpane, e)
+		if err == nil {
+			app.nav.linkedFocus = false
+			app.ui.loadFile(app, true)
+		}
+		if err == nil {
+			app.ui.loadFile(app, true)
+		}
 	case "icons", "noicons", "icons!":
 		err = applyBoolOpt(&gOpts.icons, e)
 	case "ignorecase", "noignorecase", "ignorecase!":
@@ -136,10 +201,22 @@ func (e *setExpr) eval(app *app, args []string) {
 			app.ui.sort()
 			app.ui.loadFile(app, true)
 		}
+	case "archivedirs", "noarchivedirs", "archivedirs!":
+		err = applyBoolOpt(&gOpts.archivedirs, e)
+	case "imagedirs", "noimagedirs", "imagedirs!":
+		err = applyBoolOpt(&gOpts.imagedirs, e)
 	case "incfilter", "noincfilter", "incfilter!":
 		err = applyBoolOpt(&gOpts.incfilter, e)
+	case "incfind", "noincfind", "incfind!":
+		err = applyBoolOpt(&gOpts.incfind, e)
 	case "incsearch", "noincsearch", "incsearch!":
 		err = applyBoolOpt(&gOpts.incsearch, e)
+	case "jumptrack", "nojumptrack", "jumptrack!":
+		err = applyBoolOpt(&gOpts.jumptrack, e)
+	case "lfenv", "nolfenv", "lfenv!":
+		err = applyBoolOpt(&gOpts.lfenv, e)
+	case "livecd", "nolivecd", "livecd!":
+		err = applyBoolOpt(&gOpts.livecd, e)
 	case "mouse", "nomouse", "mouse!":
 		err = applyBoolOpt(&gOpts.mouse, e)
 		if err == nil {
@@ -151,6 +228,12 @@ func (e *setExpr) eval(app *app, args []string) {
 		}
 	case "number", "nonumber", "number!":
 		err = applyBoolOpt(&gOpts.number, e)
+	case "onefilesystem", "noonefilesystem", "onefilesystem!":
+		err = applyBoolOpt(&gOpts.onefilesystem, e)
+	case "opengroups", "noopengroups", "opengroups!":
+		err = applyBoolOpt(&gOpts.opengroups, e)
+	case "pastequote", "nopastequote", "pastequote!":
+		err = applyBoolOpt(&gOpts.pastequote, e)
 	case "preview", "nopreview", "preview!":
 		preview := gOpts.preview
 		err = applyBoolOpt(&preview, e)
@@ -158,12 +241,25 @@ func (e *setExpr) eval(app *app, args []string) {
 			err = errors.New("preview: 'ratios' should consist of at least two numbers before enabling 'preview'")
 		}
 		if err == nil {
-			if gOpts.sixel {
+			if hasGraphics() {
 				app.ui.sxScreen.forceClear = true
 			}
 			gOpts.preview = preview
 			app.ui.loadFile(app, true)
 		}
+	case "previewsandbox", "nopreviewsandbox", "previewsandbox!":
+		sandbox := gOpts.previewsandbox
+		err = applyBoolOpt(&sandbox, e)
+		if err == nil && sandbox {
+			if _, serr := previewSandbox(); serr != nil {
+				err = fmt.Errorf("previewsandbox: %s", serr)
+			}
+		}
+		if err == nil {
+			gOpts.previewsandbox = sandbox
+			clear(app.nav.regCache)
+			app.ui.loadFile(app, true)
+		}
 	case "relativenumber", "norelativenumber", "relativenumber!":
 		err = applyBoolOpt(&gOpts.relativenumber, e)
 	case "reverse", "noreverse", "reverse!":
@@ -171,11 +267,18 @@ func (e *setExpr) eval(app *app, args []string) {
 		if err == nil {
 			app.nav.sort()
 			app.ui.sort()
+			sorted = true
 		}
 	case "roundbox", "noroundbox", "roundbox!":
 		err = applyBoolOpt(&gOpts.roundbox, e)
+	case "searchgitignore", "nosearchgitignore", "searchgitignore!":
+		err = applyBoolOpt(&gOpts.searchgitignore, e)
+	case "shellpager", "noshellpager", "shellpager!":
+		err = applyBoolOpt(&gOpts.shellpager, e)
 	case "showbinds", "noshowbinds", "showbinds!":
 		err = applyBoolOpt(&gOpts.showbinds, e)
+	case "singlecolumn", "nosinglecolumn", "singlecolumn!":
+		err = applyBoolOpt(&gOpts.singlecolumn, e)
 	case "sixel", "nosixel", "sixel!":
 		err = applyBoolOpt(&gOpts.sixel, e)
 		clear(app.nav.regCache)
@@ -197,6 +300,8 @@ func (e *setExpr) eval(app *app, args []string) {
 			app.ui.sort()
 			app.ui.loadFile(app, true)
 		}
+	case "trustsource", "notrustsource", "trustsource!":
+		err = applyBoolOpt(&gOpts.trustsource, e)
 	case "watch", "nowatch", "watch!":
 		err = applyBoolOpt(&gOpts.watch, e)
 		if err == nil {
@@ -207,16 +312,75 @@ func (e *setExpr) eval(app *app, args []string) {
 				}
 			} else {
 				app.watch.stop()
+				// directories with 'watch' refresh rules are still watched
+				if hasWatchRules() {
+					app.watch.start()
+					for _, dir := range app.nav.dirCache {
+						app.watchDir(dir)
+					}
+				}
 			}
 		}
 	case "wrapscan", "nowrapscan", "wrapscan!":
 		err = applyBoolOpt(&gOpts.wrapscan, e)
 	case "wrapscroll", "nowrapscroll", "wrapscroll!":
 		err = applyBoolOpt(&gOpts.wrapscroll, e)
+	case "archiveformat":
+		if _, ok := gArchiveFormats[e.val]; !ok {
+			app.ui.echoerr("archiveformat: value should either be 'zip', 'tar', 'tar.gz', 'tar.zst' or 'tar.xz'")
+			return
+		}
+		gOpts.archiveformat = e.val
+	case "autoextract":
+		switch e.val {
+		case "ask", "same-dir", "subdir", "never":
+			gOpts.autoextract = e.val
+		default:
+			app.ui.echoerr("autoextract: value should either be 'ask', 'same-dir', 'subdir' or 'never'")
+			return
+		}
+	case "autosave":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("autosave: %s", err)
+			return
+		}
+		if n < 0 {
+			app.ui.echoerr("autosave: value should be a non-negative number")
+			return
+		}
+		gOpts.autosave = n
+		app.autosaveTicker.Stop()
+		if n != 0 {
+			app.autosaveTicker = time.NewTicker(time.Duration(gOpts.autosave) * time.Second)
+		}
 	case "borderfmt":
 		gOpts.borderfmt = e.val
 	case "cleaner":
 		gOpts.cleaner = replaceTilde(e.val)
+	case "columns":
+		defs, err := parseColumns(e.val)
+		if err != nil {
+			app.ui.echoerrf("columns: %s", err)
+			return
+		}
+		gOpts.columns = nil
+		if e.val != "" {
+			gOpts.columns = strings.Split(e.val, ":")
+		}
+		gColumns = defs
+		app.refreshColumns()
+	case "compresslevel":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("compresslevel: %s", err)
+			return
+		}
+		if n < 0 {
+			app.ui.echoerr("compresslevel: value should be a non-negative number")
+			return
+		}
+		gOpts.compresslevel = n
 	case "copyfmt":
 		gOpts.copyfmt = e.val
 	case "cursoractivefmt":
@@ -227,12 +391,81 @@ func (e *setExpr) eval(app *app, args []string) {
 		gOpts.cursorpreviewfmt = e.val
 	case "cutfmt":
 		gOpts.cutfmt = e.val
+	case "databackups":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("databackups: %s", err)
+			return
+		}
+		if n < 0 {
+			app.ui.echoerr("databackups: value should be a non-negative number")
+			return
+		}
+		gOpts.databackups = n
 	case "dupfilefmt":
 		gOpts.dupfilefmt = e.val
 	case "errorfmt":
 		gOpts.errorfmt = e.val
 	case "filesep":
 		gOpts.filesep = e.val
+	case "clipboardhistory":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("clipboardhistory: %s", err)
+			return
+		}
+		if n < 0 {
+			app.ui.echoerr("clipboardhistory: value should be a non-negative number")
+			return
+		}
+		gOpts.clipboardhistory = n
+	case "dircachefiles":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("dircachefiles: %s", err)
+			return
+		}
+		if n < 0 {
+			app.ui.echoerr("dircachefiles: value should be a non-negative number")
+			return
+		}
+		gOpts.dircachefiles = n
+		app.nav.trimDirCache()
+	case "dircachesize":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("dircachesize: %s", err)
+			return
+		}
+		if n < 0 {
+			app.ui.echoerr("dircachesize: value should be a non-negative number")
+			return
+		}
+		gOpts.dircachesize = n
+		app.nav.trimDirCache()
+	case "dircachettl":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("dircachettl: %s", err)
+			return
+		}
+		if n < 0 {
+			app.ui.echoerr("dircachettl: value should be a non-negative number")
+			return
+		}
+		gOpts.dircachettl = n
+		app.nav.trimDirCache()
+	case "fuzzydepth":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("fuzzydepth: %s", err)
+			return
+		}
+		if n <= 0 {
+			app.ui.echoerr("fuzzydepth: value should be a positive number")
+			return
+		}
+		gOpts.fuzzydepth = n
 	case "findlen":
 		n, err := strconv.Atoi(e.val)
 		if err != nil {
@@ -244,6 +477,23 @@ func (e *setExpr) eval(app *app, args []string) {
 			return
 		}
 		gOpts.findlen = n
+	case "followsymlinkdirs":
+		switch e.val {
+		case "ask", "always", "never":
+			gOpts.followsymlinkdirs = e.val
+		default:
+			app.ui.echoerr("followsymlinkdirs: value should either be 'ask', 'always' or 'never'")
+			return
+		}
+	case "gitstatusfmt":
+		fmts, width, err := parseGitStatusFmt(e.val)
+		if err != nil {
+			app.ui.echoerrf("gitstatusfmt: %s", err)
+			return
+		}
+		gOpts.gitstatusfmt = e.val
+		gGitStatusFmts = fmts
+		gGitStatusWidth = width
 	case "hiddenfiles":
 		toks := strings.Split(e.val, ":")
 		for _, s := range toks {
@@ -262,8 +512,23 @@ func (e *setExpr) eval(app *app, args []string) {
 		app.nav.position()
 		app.ui.sort()
 		app.ui.loadFile(app, true)
+	case "hopchars":
+		gOpts.hopchars = e.val
+	case "hopfmt":
+		gOpts.hopfmt = e.val
 	case "ifs":
 		gOpts.ifs = e.val
+	case "imagepreview":
+		switch e.val {
+		case "none", "auto", "sixel", "kitty", "iterm2":
+			gOpts.imagepreview = e.val
+		default:
+			app.ui.echoerr("imagepreview: value should either be 'none', 'auto', 'sixel', 'kitty' or 'iterm2'")
+			return
+		}
+		clear(app.nav.regCache)
+		app.ui.sxScreen.forceClear = true
+		app.ui.loadFile(app, true)
 	case "info":
 		if e.val == "" {
 			gOpts.info = nil
@@ -279,6 +544,14 @@ func (e *setExpr) eval(app *app, args []string) {
 			}
 		}
 		gOpts.info = toks
+	case "keytranslate":
+		table, err := parseKeyTranslate(e.val)
+		if err != nil {
+			app.ui.echoerrf("keytranslate: %s", err)
+			return
+		}
+		gOpts.keytranslate = e.val
+		gKeyTranslate = table
 	case "locale":
 		localeStr := e.val
 		if localeStr != localeStrDisable {
@@ -307,12 +580,48 @@ func (e *setExpr) eval(app *app, args []string) {
 			}
 		}
 		gOpts.preserve = toks
+	case "boundbell", "boundflash":
+		var toks []string
+		if e.val != "" {
+			toks = strings.Split(e.val, ":")
+		}
+		for _, s := range toks {
+			if !slices.Contains(gBoundMotions, s) {
+				app.ui.echoerrf("%s: should consist of 'up', 'down', 'search' or 'find' separated with colon", e.opt)
+				return
+			}
+		}
+		if e.opt == "boundbell" {
+			gOpts.boundbell = toks
+		} else {
+			gOpts.boundflash = toks
+		}
 	case "infotimefmtnew":
 		gOpts.infotimefmtnew = e.val
 	case "infotimefmtold":
 		gOpts.infotimefmtold = e.val
+	case "mountmarker":
+		if e.val != "" && printLength(e.val) != 1 {
+			app.ui.echoerr("mountmarker: value should be empty or a single width character")
+			return
+		}
+		gOpts.mountmarker = e.val
 	case "numberfmt":
 		gOpts.numberfmt = e.val
+	case "oplogjson":
+		gOpts.oplogjson = e.val
+	case "previewcachesize":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("previewcachesize: %s", err)
+			return
+		}
+		if n <= 0 {
+			app.ui.echoerr("previewcachesize: value should be a positive number")
+			return
+		}
+		gOpts.previewcachesize = n
+		app.nav.trimRegCache()
 	case "period":
 		n, err := strconv.Atoi(e.val)
 		if err != nil {
@@ -354,11 +663,22 @@ func (e *setExpr) eval(app *app, args []string) {
 			return
 		}
 		gOpts.ratios = rats
-		app.ui.wins = getWins(app.ui.screen)
-		if gOpts.sixel {
+		app.ui.wins = getWins(app.ui.screen, app.ui.tabRow)
+		if hasGraphics() {
 			clear(app.nav.regCache)
 		}
 		app.ui.loadFile(app, true)
+	case "reloadrate":
+		n, err := strconv.Atoi(e.val)
+		if err != nil {
+			app.ui.echoerrf("reloadrate: %s", err)
+			return
+		}
+		if n < 0 {
+			app.ui.echoerr("reloadrate: value should be a non-negative number")
+			return
+		}
+		gOpts.reloadrate = n
 	case "scrolloff":
 		n, err := strconv.Atoi(e.val)
 		if err != nil {
@@ -381,7 +701,9 @@ func (e *setExpr) eval(app *app, args []string) {
 			return
 		}
 	case "shell":
+		prev := getShellProfile(gOpts.shell)
 		gOpts.shell = e.val
+		updateShellDefaults(prev, getShellProfile(e.val))
 	case "shellflag":
 		gOpts.shellflag = e.val
 	case "shellopts":
@@ -399,6 +721,7 @@ func (e *setExpr) eval(app *app, args []string) {
 		gOpts.sortby = method
 		app.nav.sort()
 		app.ui.sort()
+		sorted = true
 	case "statfmt":
 		gOpts.statfmt = e.val
 	case "tabstop":
@@ -416,6 +739,27 @@ func (e *setExpr) eval(app *app, args []string) {
 		gOpts.tagfmt = e.val
 	case "tempmarks":
 		gOpts.tempmarks = "'" + e.val
+	case "jumpfile":
+		gOpts.jumpfile = e.val
+	case "jumpranking":
+		switch e.val {
+		case "frecency", "frequency", "recency":
+			gOpts.jumpranking = e.val
+		default:
+			app.ui.echoerr("jumpranking: value should either be 'frecency', 'frequency' or 'recency'")
+			return
+		}
+	case "tabbar":
+		switch e.val {
+		case "row", "status":
+			gOpts.tabbar = e.val
+		default:
+			app.ui.echoerr("tabbar: value should either be 'row' or 'status'")
+			return
+		}
+		app.updateTabRow()
+	case "terminalcmd":
+		gOpts.terminalcmd = e.val
 	case "timefmt":
 		gOpts.timefmt = e.val
 	case "truncatechar":
@@ -440,6 +784,13 @@ func (e *setExpr) eval(app *app, args []string) {
 		gOpts.visualfmt = e.val
 	case "waitmsg":
 		gOpts.waitmsg = e.val
+	case "warnsize":
+		n, err := parseSize(e.val)
+		if err != nil {
+			app.ui.echoerrf("warnsize: %s", err)
+			return
+		}
+		gOpts.warnsize = n
 	default:
 		// any key with the prefix user_ is accepted as a user defined option
 		if strings.HasPrefix(e.opt, "user_") {
@@ -459,16 +810,40 @@ func (e *setExpr) eval(app *app, args []string) {
 	}
 
 	app.ui.loadFileInfo(app.nav)
+
+	if sorted {
+		app.showSort()
+	}
 }
 
 func (e *setLocalExpr) eval(app *app, args []string) {
 	e.path = replaceTilde(e.path)
+	if e.path == "." || e.path == "."+string(filepath.Separator) {
+		// the expression is copied as it can be mapped to a key
+		if !app.nav.init {
+			app.ui.echoerr("setlocal: current directory is not loaded yet")
+			return
+		}
+		path := app.nav.currDir().path
+		if e.path != "." {
+			path = strings.TrimSuffix(path, string(filepath.Separator)) + string(filepath.Separator)
+		}
+		e = &setLocalExpr{path, e.opt, e.val}
+	}
 	if !filepath.IsAbs(e.path) {
 		app.ui.echoerr("setlocal: path should be absolute")
 		return
 	}
+	if isLocalPattern(e.path) {
+		if _, err := filepath.Match(e.path, ""); err != nil {
+			app.ui.echoerrf("setlocal: invalid pattern: %s", e.path)
+			return
+		}
+		addLocalPattern(e.path)
+	}
 
 	var err error
+	sorted := false
 	switch e.opt {
 	case "dircounts", "nodircounts", "dircounts!":
 		err = applyLocalBoolOpt(gLocalOpts.dircounts, gOpts.dircounts, e)
@@ -493,12 +868,19 @@ func (e *setLocalExpr) eval(app *app, args []string) {
 			app.nav.position()
 			app.ui.sort()
 			app.ui.loadFile(app, true)
+			sorted = true
+		}
+	case "preview", "nopreview", "preview!":
+		err = applyLocalBoolOpt(gLocalOpts.preview, gOpts.preview, e)
+		if err == nil && app.nav.init {
+			app.ui.loadFile(app, true)
 		}
 	case "reverse", "noreverse", "reverse!":
 		err = applyLocalBoolOpt(gLocalOpts.reverse, gOpts.reverse, e)
 		if err == nil {
 			app.nav.sort()
 			app.ui.sort()
+			sorted = true
 		}
 	case "info":
 		if e.val == "" {
@@ -524,6 +906,7 @@ func (e *setLocalExpr) eval(app *app, args []string) {
 		gLocalOpts.sortby[e.path] = method
 		app.nav.sort()
 		app.ui.sort()
+		sorted = true
 	case "locale":
 		localeStr := e.val
 		if localeStr != localeStrDisable {
@@ -545,6 +928,10 @@ func (e *setLocalExpr) eval(app *app, args []string) {
 	}
 
 	app.ui.loadFileInfo(app.nav)
+
+	if sorted {
+		app.showSort()
+	}
 }
 
 func (e *mapExpr) eval(app *app, args []string) {
@@ -586,9 +973,16 @@ func (e *cmapExpr) eval(app *app, args []string) {
 }
 
 func (e *cmdExpr) eval(app *app, args []string) {
-	if e.expr == nil {
+	switch {
+	case e.scope != "":
+		if !filepath.IsAbs(replaceTilde(e.scope)) {
+			app.ui.echoerrf("cmd: scope should be an absolute path: %s", e.scope)
+			return
+		}
+		setScopedCmd(e.name, e.scope, e.expr)
+	case e.expr == nil:
 		delete(gOpts.cmds, e.name)
-	} else {
+	default:
 		gOpts.cmds[e.name] = e.expr
 	}
 
@@ -596,6 +990,10 @@ func (e *cmdExpr) eval(app *app, args []string) {
 	if e.name == "on-focus-gained" || e.name == "on-focus-lost" {
 		_, onFocusGainedExists := gOpts.cmds["on-focus-gained"]
 		_, onFocusLostExists := gOpts.cmds["on-focus-lost"]
+		_, onFocusGainedScoped := gScopedCmds["on-focus-gained"]
+		_, onFocusLostScoped := gScopedCmds["on-focus-lost"]
+		onFocusGainedExists = onFocusGainedExists || onFocusGainedScoped
+		onFocusLostExists = onFocusLostExists || onFocusLostScoped
 		if onFocusGainedExists || onFocusLostExists {
 			app.ui.screen.EnableFocus()
 		} else {
@@ -607,14 +1005,28 @@ func (e *cmdExpr) eval(app *app, args []string) {
 }
 
 func preChdir(app *app) {
-	if cmd, ok := gOpts.cmds["pre-cd"]; ok {
+	// the other pane keeps the directory shown before the first change
+	if gOpts.dualpane && app.nav.pane == nil {
+		app.nav.pane = app.nav.currDir()
+	}
+	if cmd, ok := findCmd("pre-cd"); ok {
 		cmd.eval(app, nil)
 	}
 }
 
 func onChdir(app *app) {
 	app.nav.addJumpList()
-	if cmd, ok := gOpts.cmds["on-cd"]; ok {
+	recordJump(app.nav.currDir().path)
+	app.exportDir()
+	app.reportDir()
+	if gOpts.livecd {
+		app.writeLiveCd()
+	}
+	app.checkLfenv()
+	app.refreshSegments()
+	app.refreshGitStatus()
+	app.refreshColumns()
+	if cmd, ok := findCmd("on-cd"); ok {
 		cmd.eval(app, nil)
 	}
 }
@@ -625,43 +1037,59 @@ func onLoad(app *app, files []string) {
 		return
 	}
 
-	if cmd, ok := gOpts.cmds["on-load"]; ok {
+	if cmd, ok := findCmd("on-load"); ok {
 		cmd.eval(app, files)
 	}
 }
 
 func onFocusGained(app *app) {
-	if cmd, ok := gOpts.cmds["on-focus-gained"]; ok {
+	if cmd, ok := findCmd("on-focus-gained"); ok {
 		cmd.eval(app, nil)
 	}
 }
 
 func onFocusLost(app *app) {
-	if cmd, ok := gOpts.cmds["on-focus-lost"]; ok {
+	if cmd, ok := findCmd("on-focus-lost"); ok {
 		cmd.eval(app, nil)
 	}
 }
 
 func onInit(app *app) {
-	if cmd, ok := gOpts.cmds["on-init"]; ok {
+	app.checkLfenv()
+	app.refreshSegments()
+	app.refreshGitStatus()
+	app.refreshColumns()
+	if cmd, ok := findCmd("on-init"); ok {
 		cmd.eval(app, nil)
 	}
 }
 
 func onRedraw(app *app) {
-	if cmd, ok := gOpts.cmds["on-redraw"]; ok {
+	if cmd, ok := findCmd("on-redraw"); ok {
 		cmd.eval(app, nil)
 	}
 }
 
 func onSelect(app *app) {
-	if cmd, ok := gOpts.cmds["on-select"]; ok {
+	if cmd, ok := findCmd("on-select"); ok {
 		cmd.eval(app, nil)
 	}
 }
 
+func onPasteComplete(app *app, args []string) {
+	if cmd, ok := findCmd("on-paste-complete"); ok {
+		cmd.eval(app, args)
+	}
+}
+
+func onSelectionWritten(app *app, args []string) {
+	if cmd, ok := findCmd("on-selection-written"); ok {
+		cmd.eval(app, args)
+	}
+}
+
 func onQuit(app *app) {
-	if cmd, ok := gOpts.cmds["on-quit"]; ok {
+	if cmd, ok := findCmd("on-quit"); ok {
 		cmd.eval(app, nil)
 	}
 }
@@ -772,7 +1200,10 @@ func update(app *app) {
 			app.ui.loadFile(app, true)
 			app.ui.loadFileInfo(app.nav)
 		}
-	case gOpts.incfilter && app.ui.cmdPrefix == "filter: ":
+	case app.ui.cmdPrefix == "fuzzy: ":
+		app.fuzzyInd = 0
+		app.fuzzyUpdate()
+	case isIncFilter(app):
 		filter := string(app.ui.cmdAccLeft) + string(app.ui.cmdAccRight)
 		dir := app.nav.currDir()
 		old := dir.ind
@@ -786,13 +1217,19 @@ func update(app *app) {
 	}
 }
 
+// The filter is applied incrementally with either 'incfilter', or 'incfind'
+// which starts filtering with unmapped keys.
+func isIncFilter(app *app) bool {
+	return (gOpts.incfilter || gOpts.incfind) && app.ui.cmdPrefix == "filter: "
+}
+
 func restartIncCmd(app *app) {
 	if gOpts.incsearch && (app.ui.cmdPrefix == "/" || app.ui.cmdPrefix == "?") {
 		dir := app.nav.currDir()
 		app.nav.searchInd = dir.ind
 		app.nav.searchPos = dir.pos
 		update(app)
-	} else if gOpts.incfilter && app.ui.cmdPrefix == "filter: " {
+	} else if isIncFilter(app) {
 		dir := app.nav.currDir()
 		app.nav.prevFilter = dir.filter
 		update(app)
@@ -808,7 +1245,7 @@ func resetIncCmd(app *app) {
 			app.ui.loadFile(app, true)
 			app.ui.loadFileInfo(app.nav)
 		}
-	} else if gOpts.incfilter && app.ui.cmdPrefix == "filter: " {
+	} else if isIncFilter(app) {
 		dir := app.nav.currDir()
 		old := dir.ind
 		app.nav.setFilter(app.nav.prevFilter)
@@ -821,6 +1258,7 @@ func resetIncCmd(app *app) {
 
 func normal(app *app) {
 	resetIncCmd(app)
+	app.fuzzyStop()
 
 	app.cmdHistoryInd = 0
 	app.menuCompActive = false
@@ -829,11 +1267,47 @@ func normal(app *app) {
 	app.ui.cmdAccLeft = nil
 	app.ui.cmdAccRight = nil
 	app.ui.cmdPrefix = ""
+	app.ui.cmdPending = ""
+	app.ui.hopLabels = nil
 
 	// ensure the mode indicator in `statfmt` is updated properly
 	app.ui.loadFileInfo(app.nav)
 }
 
+func deleteFiles(app *app) {
+	if err := app.nav.del(app); err != nil {
+		app.ui.echoerrf("delete: %s", err)
+		return
+	}
+	app.nav.unselect()
+	app.ui.loadFile(app, true)
+	app.ui.loadFileInfo(app.nav)
+}
+
+// This function pastes the files in the copy buffer, or asks to confirm the
+// given warning first when it is not empty.
+func pasteFiles(app *app, warning string) {
+	if warning != "" {
+		if gHeadless {
+			app.ui.echoerrf("paste: %s", warning)
+			return
+		}
+		if app.ui.cmdPrefix == ">" {
+			return
+		}
+		normal(app)
+		app.ui.cmdPrefix = "paste: " + warning + ", continue? [y/N] "
+		return
+	}
+
+	if err := app.nav.paste(app); err != nil {
+		app.ui.echoerrf("paste: %s", err)
+		return
+	}
+	app.ui.loadFile(app, true)
+	app.ui.loadFileInfo(app.nav)
+}
+
 func visual(app *app) {
 	dir := app.nav.currDir()
 	dir.visualAnchor = dir.ind
@@ -842,12 +1316,44 @@ func visual(app *app) {
 	app.ui.loadFileInfo(app.nav)
 }
 
+func openDir(app *app, follow bool) {
+	resetIncCmd(app)
+	preChdir(app)
+	if err := app.nav.open(follow); err != nil {
+		app.ui.echoerrf("opening directory: %s", err)
+		return
+	}
+	app.ui.loadFile(app, true)
+	app.ui.loadFileInfo(app.nav)
+	restartIncCmd(app)
+	onChdir(app)
+}
+
+func openFile(app *app, curr *file, args []string) {
+	// the history is exported before the current opening is recorded
+	exportOpenHistory(curr.path)
+
+	// the current file is recorded last as the most recent one
+	if list, err := app.nav.currFileOrSelections(); err == nil {
+		list = append(slices.DeleteFunc(list, func(p string) bool { return p == curr.path }), curr.path)
+		app.recordRecent(list)
+		app.recordOpen(list)
+	}
+
+	if cmd, ok := findCmd("open"); ok {
+		app.openGroups(cmd, args)
+	}
+}
+
 func insert(app *app, arg string) {
 	switch {
 	case gOpts.incsearch && (app.ui.cmdPrefix == "/" || app.ui.cmdPrefix == "?"):
 		app.ui.cmdAccLeft = append(app.ui.cmdAccLeft, []rune(arg)...)
 		update(app)
-	case gOpts.incfilter && app.ui.cmdPrefix == "filter: ":
+	case isIncFilter(app):
+		app.ui.cmdAccLeft = append(app.ui.cmdAccLeft, []rune(arg)...)
+		update(app)
+	case app.ui.cmdPrefix == "fuzzy: ":
 		app.ui.cmdAccLeft = append(app.ui.cmdAccLeft, []rune(arg)...)
 		update(app)
 	case app.ui.cmdPrefix == "find: ":
@@ -912,19 +1418,48 @@ func insert(app *app, arg string) {
 		normal(app)
 
 		if arg == "y" {
-			if err := app.nav.del(app); err != nil {
-				app.ui.echoerrf("delete: %s", err)
-				return
-			}
-			app.nav.unselect()
-			app.ui.loadFile(app, true)
-			app.ui.loadFileInfo(app.nav)
+			deleteFiles(app)
+		}
+	case strings.HasPrefix(app.ui.cmdPrefix, "extract '"):
+		normal(app)
+
+		curr, err := app.nav.currFile()
+		if err != nil {
+			app.ui.echoerrf("extract: %s", err)
+			return
+		}
+
+		switch arg {
+		case "d":
+			app.extract(curr.path, true)
+		case "s":
+			app.extract(curr.path, false)
+		default:
+			openFile(app, curr, nil)
+		}
+	case strings.HasPrefix(app.ui.cmdPrefix, "lfenv: load"):
+		normal(app)
+
+		app.answerLfenv(arg)
+	case strings.HasPrefix(app.ui.cmdPrefix, "source: read"):
+		normal(app)
+
+		app.answerSource(arg)
+	case strings.HasPrefix(app.ui.cmdPrefix, "follow symlink"):
+		normal(app)
+
+		openDir(app, arg == "y")
+	case strings.HasPrefix(app.ui.cmdPrefix, "paste: "):
+		normal(app)
+
+		if arg == "y" {
+			pasteFiles(app, "")
 		}
 	case strings.HasPrefix(app.ui.cmdPrefix, "replace"):
 		normal(app)
 
 		if arg == "y" {
-			if err := app.nav.rename(); err != nil {
+			if err := app.nav.rename(app); err != nil {
 				app.ui.echoerrf("rename: %s", err)
 				return
 			}
@@ -932,7 +1467,7 @@ func insert(app *app, arg string) {
 				app.nav.renew()
 				app.ui.loadFile(app, true)
 			} else {
-				if err := remote("send load"); err != nil {
+				if err := remote("send reload-entry " + escape(app.nav.renameOldPath) + " " + escape(app.nav.renameNewPath)); err != nil {
 					app.ui.echoerrf("rename: %s", err)
 					return
 				}
@@ -948,7 +1483,7 @@ func insert(app *app, arg string) {
 				app.ui.echoerrf("rename: %s", err)
 				return
 			}
-			if err := app.nav.rename(); err != nil {
+			if err := app.nav.rename(app); err != nil {
 				app.ui.echoerrf("rename: %s", err)
 				return
 			}
@@ -964,11 +1499,22 @@ func insert(app *app, arg string) {
 			app.ui.loadFile(app, true)
 			app.ui.loadFileInfo(app.nav)
 		}
+	case app.ui.cmdPrefix == "hop: " || app.ui.cmdPrefix == "hop-toggle: ":
+		app.hopInsert(arg)
+	case app.ui.cmdPrefix == "recent-files: ":
+		app.recentOpen(arg)
+	case app.ui.cmdPrefix == "jump: ":
+		app.jumpOpen(arg)
+	case app.isJobsPrompt():
+		app.jobsInsert(arg)
 	case app.ui.cmdPrefix == "mark-save: ":
 		normal(app)
 
-		app.nav.marks[arg] = app.nav.currDir().path
-		if err := app.nav.writeMarks(); err != nil {
+		path := app.nav.currDir().path
+		if err := app.nav.updateMarks(func() error {
+			app.nav.marks[arg] = path
+			return nil
+		}); err != nil {
 			app.ui.echoerrf("mark-save: %s", err)
 			return
 		}
@@ -1017,11 +1563,7 @@ func insert(app *app, arg string) {
 		}
 	case app.ui.cmdPrefix == "mark-remove: ":
 		normal(app)
-		if err := app.nav.removeMark(arg); err != nil {
-			app.ui.echoerrf("mark-remove: %s", err)
-			return
-		}
-		if err := app.nav.writeMarks(); err != nil {
+		if err := app.nav.updateMarks(func() error { return app.nav.removeMark(arg) }); err != nil {
 			app.ui.echoerrf("mark-remove: %s", err)
 			return
 		}
@@ -1111,15 +1653,31 @@ func (e *callExpr) eval(app *app, args []string) {
 			app.ui.loadFile(app, true)
 			app.ui.loadFileInfo(app.nav)
 		}
-	case "tty-write":
-		if len(e.args) != 1 {
-			app.ui.echoerr("tty-write: requires an argument")
+	case "column-left":
+		if !app.nav.init {
 			return
 		}
-
-		tty, ok := app.ui.screen.Tty()
-		if !ok {
-			log.Printf("tty-write: failed to get tty")
+		if app.nav.gridMove(-e.count) {
+			app.ui.loadFile(app, true)
+			app.ui.loadFileInfo(app.nav)
+		}
+	case "column-right":
+		if !app.nav.init {
+			return
+		}
+		if app.nav.gridMove(e.count) {
+			app.ui.loadFile(app, true)
+			app.ui.loadFileInfo(app.nav)
+		}
+	case "tty-write":
+		if len(e.args) != 1 {
+			app.ui.echoerr("tty-write: requires an argument")
+			return
+		}
+
+		tty, ok := app.ui.screen.Tty()
+		if !ok {
+			log.Printf("tty-write: failed to get tty")
 			return
 		}
 
@@ -1159,20 +1717,23 @@ func (e *callExpr) eval(app *app, args []string) {
 		}
 
 		if curr.IsDir() {
-			resetIncCmd(app)
-			preChdir(app)
-			err := app.nav.open()
-			if err != nil {
-				app.ui.echoerrf("opening directory: %s", err)
+			if curr.linkState != notLink && !app.nav.physical && gOpts.followsymlinkdirs == "ask" {
+				app.ui.cmdPrefix = "follow symlink '" + curr.linkTarget + "' ? [y/N] "
 				return
 			}
-			app.ui.loadFile(app, true)
-			app.ui.loadFileInfo(app.nav)
-			restartIncCmd(app)
-			onChdir(app)
+			openDir(app, app.nav.followLink(curr))
 			return
 		}
 
+		// images and archives without a supported file system are opened as
+		// other files
+		if curr.Mode().IsRegular() && isImageDirName(curr.path) {
+			if _, err := openImage(curr.path); err == nil {
+				openDir(app, false)
+				return
+			}
+		}
+
 		if gSelectionPath != "" || gPrintSelection {
 			app.selectionOut, _ = app.nav.currFileOrSelections()
 			app.quitChan <- struct{}{}
@@ -1181,9 +1742,16 @@ func (e *callExpr) eval(app *app, args []string) {
 
 		app.ui.loadFileInfo(app.nav)
 
-		if cmd, ok := gOpts.cmds["open"]; ok {
-			cmd.eval(app, e.args)
+		if app.shouldExtract(curr) {
+			if gOpts.autoextract == "ask" {
+				app.askExtract(curr)
+				return
+			}
+			app.extract(curr.path, gOpts.autoextract == "subdir")
+			return
 		}
+
+		openFile(app, curr, e.args)
 	case "jump-prev":
 		resetIncCmd(app)
 		preChdir(app)
@@ -1206,6 +1774,41 @@ func (e *callExpr) eval(app *app, args []string) {
 		onChdir(app)
 	case "quit":
 		app.quitChan <- struct{}{}
+	case "edit-in-server":
+		if !app.nav.init {
+			return
+		}
+		app.editInServer()
+	case "detach":
+		if !app.nav.init {
+			return
+		}
+		app.detach(e.args)
+	case "lfenv-allow":
+		app.allowLfenv(true)
+	case "lfenv-deny":
+		app.allowLfenv(false)
+	case "trust":
+		app.trust(e.name, e.args, true)
+	case "untrust":
+		app.trust(e.name, e.args, false)
+	case "terminal":
+		if gHeadless {
+			app.ui.echoerr("terminal: not supported in this mode")
+			return
+		}
+		if gOpts.terminalcmd == "" {
+			app.ui.echoerr("terminal: 'terminalcmd' option is not set")
+			return
+		}
+		app.runShell(gOpts.terminalcmd, nil, "&")
+	case "cd-and-quit-into-shell":
+		if gHeadless || app.tutor != nil {
+			app.ui.echoerr("cd-and-quit-into-shell: not supported in this mode")
+			return
+		}
+		app.quitShell = true
+		app.quitChan <- struct{}{}
 	case "top":
 		if !app.nav.init {
 			return
@@ -1290,9 +1893,7 @@ func (e *callExpr) eval(app *app, args []string) {
 			tag = e.args[0]
 		}
 
-		if err := app.nav.tagToggle(tag); err != nil {
-			app.ui.echoerrf("tag-toggle: %s", err)
-		} else if err := app.nav.writeTags(); err != nil {
+		if err := app.nav.updateTags(func() error { return app.nav.tagToggle(tag) }); err != nil {
 			app.ui.echoerrf("tag-toggle: %s", err)
 		}
 
@@ -1315,9 +1916,7 @@ func (e *callExpr) eval(app *app, args []string) {
 			tag = e.args[0]
 		}
 
-		if err := app.nav.tag(tag); err != nil {
-			app.ui.echoerrf("tag: %s", err)
-		} else if err := app.nav.writeTags(); err != nil {
+		if err := app.nav.updateTags(func() error { return app.nav.tag(tag) }); err != nil {
 			app.ui.echoerrf("tag: %s", err)
 		}
 
@@ -1349,6 +1948,18 @@ func (e *callExpr) eval(app *app, args []string) {
 		app.ui.loadFileInfo(app.nav)
 		app.nav.sort()
 		app.ui.sort()
+	case "dirsize":
+		if !app.nav.init {
+			return
+		}
+		switch {
+		case len(e.args) == 0:
+			app.dirSize()
+		case len(e.args) == 1 && e.args[0] == "--cancel":
+			app.cancelDirSize()
+		default:
+			app.ui.echoerr("dirsize: only '--cancel' is allowed as an argument")
+		}
 	case "clearmaps":
 		// leave `:` and cmaps bound so the user can still exit using `:quit`
 		clear(gOpts.nkeys)
@@ -1404,20 +2015,70 @@ func (e *callExpr) eval(app *app, args []string) {
 			return
 		}
 
-		if cmd, ok := gOpts.cmds["paste"]; ok {
+		if isDryRun(e.args) {
+			if _, ok := findCmd("paste"); ok {
+				app.ui.echoerr("paste: dry run is not supported with a custom 'paste' command")
+				return
+			}
+			plan, err := app.nav.pastePlan()
+			if err != nil {
+				app.ui.echoerrf("paste: %s", err)
+				return
+			}
+			app.showDryRun("paste", plan)
+			return
+		}
+
+		if cmd, ok := findCmd("paste"); ok {
 			cmd.eval(app, e.args)
-		} else if err := app.nav.paste(app); err != nil {
+			app.ui.loadFile(app, true)
+			app.ui.loadFileInfo(app.nav)
+			return
+		}
+
+		warning, check, err := app.nav.pasteWarning()
+		if err != nil {
 			app.ui.echoerrf("paste: %s", err)
 			return
 		}
-		app.ui.loadFile(app, true)
-		app.ui.loadFileInfo(app.nav)
+		if check != nil {
+			// the size of the copied files is calculated in the background
+			dir := app.nav.currDir().path
+			go func() {
+				app.ui.exprChan <- &callExpr{"paste-check", []string{dir, check()}, 1}
+			}()
+			return
+		}
+		pasteFiles(app, warning)
+	case "paste-check":
+		if !app.nav.init || len(e.args) != 2 {
+			return
+		}
+		if app.nav.currDir().path != e.args[0] {
+			app.ui.echoerr("paste: current directory changed while checking the files")
+			return
+		}
+		pasteFiles(app, e.args[1])
 	case "delete":
 		if !app.nav.init {
 			return
 		}
 
-		if cmd, ok := gOpts.cmds["delete"]; ok {
+		if isDryRun(e.args) {
+			if _, ok := findCmd("delete"); ok {
+				app.ui.echoerr("delete: dry run is not supported with a custom 'delete' command")
+				return
+			}
+			list, err := app.nav.currFileOrSelections()
+			if err != nil {
+				app.ui.echoerrf("delete: %s", err)
+				return
+			}
+			app.showDryRun("delete", deletePlan(list))
+			return
+		}
+
+		if cmd, ok := findCmd("delete"); ok {
 			cmd.eval(app, e.args)
 			app.nav.unselect()
 			if gSingleMode {
@@ -1440,10 +2101,19 @@ func (e *callExpr) eval(app *app, args []string) {
 				return
 			}
 			normal(app)
+			if gHeadless {
+				// there is no user to confirm, the script itself is the confirmation
+				deleteFiles(app)
+				return
+			}
+			var peers string
+			if n := app.nav.peersIn(list); n > 0 {
+				peers = " (" + peersMsg(n) + " in the directory)"
+			}
 			if len(list) == 1 {
-				app.ui.cmdPrefix = "delete '" + list[0] + "' ? [y/N] "
+				app.ui.cmdPrefix = "delete '" + list[0] + "'" + peers + " ? [y/N] "
 			} else {
-				app.ui.cmdPrefix = "delete " + strconv.Itoa(len(list)) + " items? [y/N] "
+				app.ui.cmdPrefix = "delete " + strconv.Itoa(len(list)) + " items" + peers + "? [y/N] "
 			}
 		}
 		app.ui.loadFileInfo(app.nav)
@@ -1467,7 +2137,402 @@ func (e *callExpr) eval(app *app, args []string) {
 			}
 		}
 		app.ui.loadFileInfo(app.nav)
+	case "recent-add":
+		app.addRecentFiles(e.args)
+	case "prompt-segment":
+		app.promptSegment(e.args)
+	case "column":
+		app.defineColumn(e.args)
+	case "pane-switch":
+		if !app.nav.init {
+			return
+		}
+		app.paneSwitch()
+	case "copy-to-other":
+		if !app.nav.init {
+			return
+		}
+		app.pasteToOther("copy-to-other", true, isDryRun(e.args))
+	case "move-to-other":
+		if !app.nav.init {
+			return
+		}
+		app.pasteToOther("move-to-other", false, isDryRun(e.args))
+	case "tab-new":
+		if !app.nav.init {
+			return
+		}
+		app.tabNew(e.args)
+	case "tab-close":
+		if !app.nav.init {
+			return
+		}
+		app.tabClose()
+	case "tab-next":
+		if !app.nav.init {
+			return
+		}
+		app.tabNext("tab-next", e.count)
+	case "tab-prev":
+		if !app.nav.init {
+			return
+		}
+		app.tabNext("tab-prev", -e.count)
+	case "tab-move":
+		if !app.nav.init {
+			return
+		}
+		app.tabMove(e.args)
+	case "refreshrule":
+		app.refreshRule(e.args)
+	case "open-history":
+		app.setOpenHistory(e.args)
+	case "peer-dirs":
+		app.setPeerDirs(e.args)
+	case "selection-written":
+		onSelectionWritten(app, e.args)
+	case "tab-error":
+		app.tabError(e.args)
+	case "paste-complete":
+		if len(e.args) >= 2 {
+			app.nav.invalidateDir(e.args[1])
+			if e.args[0] == "move" {
+				for _, path := range e.args[2:] {
+					app.nav.invalidateDir(filepath.Dir(path))
+				}
+			}
+		}
+		onPasteComplete(app, e.args)
+	case "recent-files":
+		if !app.nav.init || app.ui.cmdPrefix == ">" {
+			return
+		}
+		normal(app)
+		app.recentFiles()
+	case "jump":
+		if !app.nav.init || app.ui.cmdPrefix == ">" {
+			return
+		}
+		normal(app)
+		app.jump(e.args)
+	case "jump-import":
+		if !app.nav.init {
+			return
+		}
+		app.jumpImport(e.args)
+	case "fuzzy":
+		if !app.nav.init || app.ui.cmdPrefix == ">" {
+			return
+		}
+		normal(app)
+		app.fuzzy()
+	case "fuzzy-update":
+		app.fuzzyUpdate()
+	case "search-name":
+		if !app.nav.init || app.ui.cmdPrefix == ">" {
+			return
+		}
+		normal(app)
+		app.searchName(e.args)
+	case "search-content":
+		if !app.nav.init || app.ui.cmdPrefix == ">" {
+			return
+		}
+		normal(app)
+		app.searchContent(e.args)
+	case "search-results":
+		if !app.nav.init || app.ui.cmdPrefix == ">" {
+			return
+		}
+		normal(app)
+		app.showSearch()
+	case "search-update":
+		app.updateSearch()
+	case "jobs":
+		if !app.nav.init || app.ui.cmdPrefix == ">" {
+			return
+		}
+		normal(app)
+		app.showJobs()
+	case "restore-selection":
+		if !app.nav.init {
+			return
+		}
+		app.restoreSelection()
+	case "archive":
+		if !app.nav.init {
+			return
+		}
+		name := ""
+		if args := dryRunArgs(e.args); len(args) > 0 {
+			name = args[0]
+		}
+		app.archive(name, isDryRun(e.args))
+	case "split-file":
+		if !app.nav.init {
+			return
+		}
+		args := dryRunArgs(e.args)
+		if len(args) != 1 {
+			app.ui.echoerr("split-file: requires a part size (e.g. '4000M')")
+			return
+		}
+		app.splitFile(args[0], isDryRun(e.args))
+	case "join-files":
+		if !app.nav.init {
+			return
+		}
+		output := ""
+		if args := dryRunArgs(e.args); len(args) > 0 {
+			output = args[0]
+		}
+		app.joinFiles(output, isDryRun(e.args))
+	case "hashdir":
+		if !app.nav.init {
+			return
+		}
+		check := false
+		switch args := dryRunArgs(e.args); {
+		case len(args) == 0:
+		case len(args) == 1 && args[0] == "--check":
+			check = true
+		default:
+			app.ui.echoerr("hashdir: only '--check' or '--dry-run' is allowed as an argument")
+			return
+		}
+		app.hashDir(check, isDryRun(e.args))
+	case "checksum":
+		if !app.nav.init {
+			return
+		}
+		check := false
+		sumsPath := ""
+		switch {
+		case len(e.args) == 1:
+		case len(e.args) > 1 && len(e.args) <= 3 && e.args[1] == "--check":
+			check = true
+			if len(e.args) == 3 {
+				sumsPath = e.args[2]
+			}
+		default:
+			app.ui.echoerr("checksum: usage: checksum md5|sha1|sha256 [--check [file]]")
+			return
+		}
+		app.checksum(e.args[0], check, sumsPath)
+	case "checksum-results":
+		if !app.nav.init {
+			return
+		}
+		app.checksumResults()
+	case "stats":
+		if !app.nav.init {
+			return
+		}
+		switch {
+		case len(e.args) == 0:
+			app.stats()
+		case len(e.args) == 1 && e.args[0] == "--cancel":
+			app.cancelStats()
+		default:
+			app.ui.echoerr("stats: only '--cancel' is allowed as an argument")
+		}
+	case "stats-results":
+		if !app.nav.init {
+			return
+		}
+		app.statsResults()
+	case "cleanup":
+		if !app.nav.init {
+			return
+		}
+		switch {
+		case len(e.args) == 0:
+			app.cleanup(false)
+		case len(e.args) == 1 && e.args[0] == "--atime":
+			app.cleanup(true)
+		case len(e.args) == 1 && e.args[0] == "--cancel":
+			app.cancelCleanup()
+		default:
+			app.ui.echoerr("cleanup: only '--atime' or '--cancel' is allowed as an argument")
+		}
+	case "cleanup-stage":
+		if !app.nav.init {
+			return
+		}
+		app.cleanupStage(e.args)
+	case "cleanup-trash":
+		if !app.nav.init {
+			return
+		}
+		app.cleanupTrash(isDryRun(e.args))
+	case "sortlocal":
+		if !app.nav.init {
+			return
+		}
+		app.sortLocal(e.args)
+	case "clipboard-history":
+		if !app.nav.init {
+			return
+		}
+		app.clipboardHistory(e.args)
+	case "session-save":
+		if !app.nav.init {
+			return
+		}
+		app.saveSession(e.args)
+	case "session-load":
+		if !app.nav.init {
+			return
+		}
+		app.loadSession(e.args, false)
+	case "session-attach":
+		if !app.nav.init {
+			return
+		}
+		app.sessionAttach(e.args)
+	case "trash":
+		if !app.nav.init {
+			return
+		}
+		if isDryRun(e.args) {
+			if _, ok := findCmd("trash"); ok {
+				app.ui.echoerr("trash: dry run is not supported with a custom 'trash' command")
+				return
+			}
+			list, err := app.nav.currFileOrSelections()
+			if err != nil {
+				app.ui.echoerrf("trash: %s", err)
+				return
+			}
+			app.showDryRun("trash", trashPlan(list))
+			return
+		}
+		if cmd, ok := findCmd("trash"); ok {
+			cmd.eval(app, e.args)
+			return
+		}
+		app.trash()
+	case "restore":
+		if !app.nav.init {
+			return
+		}
+		if isDryRun(e.args) {
+			if _, ok := findCmd("restore"); ok {
+				app.ui.echoerr("restore: dry run is not supported with a custom 'restore' command")
+				return
+			}
+			list, err := app.restoreItems(dryRunArgs(e.args))
+			if err != nil {
+				app.ui.echoerrf("restore: %s", err)
+				return
+			}
+			app.showDryRun("restore", restorePlan(list))
+			return
+		}
+		if cmd, ok := findCmd("restore"); ok {
+			cmd.eval(app, e.args)
+			return
+		}
+		app.restore(e.args)
+	case "trash-list":
+		if !app.nav.init {
+			return
+		}
+		if cmd, ok := findCmd("trash-list"); ok {
+			cmd.eval(app, e.args)
+			return
+		}
+		app.trashList()
+	case "undo", "redo":
+		if !app.nav.init {
+			return
+		}
+		if cmd, ok := findCmd(e.name); ok {
+			if isDryRun(e.args) {
+				app.ui.echoerrf("%s: dry run is not supported with a custom '%s' command", e.name, e.name)
+				return
+			}
+			cmd.eval(app, e.args)
+			return
+		}
+		app.undo(e.name == "redo", isDryRun(e.args))
+	case "last-report":
+		if app.ui.cmdPrefix == ">" {
+			return
+		}
+		app.lastReport()
+	case "select-failed":
+		if !app.nav.init {
+			return
+		}
+		app.selectFailed()
+	case "retry-failed":
+		if !app.nav.init {
+			return
+		}
+		strategy := ""
+		if args := dryRunArgs(e.args); len(args) > 0 {
+			strategy = args[0]
+		}
+		app.retryFailed(strategy, isDryRun(e.args))
 	case "draw":
+	case "help":
+		w, _ := app.ui.screen.Size()
+		topic := strings.Join(e.args, " ")
+		p, ok := newHelpPager(topic, w)
+		if !ok {
+			app.ui.echoerrf("help: no help for '%s'", topic)
+			return
+		}
+		normal(app)
+		app.ui.pager = p
+	case "colortest":
+		var path string
+		switch len(e.args) {
+		case 0:
+			curr, err := app.nav.currFile()
+			if err != nil {
+				app.ui.echoerrf("colortest: %s", err)
+				return
+			}
+			path = curr.path
+		case 1:
+			path = e.args[0]
+		default:
+			app.ui.echoerr("colortest: usage: colortest [path]")
+			return
+		}
+		normal(app)
+		app.colorTest(path)
+	case "dry-run":
+		switch {
+		case len(e.args) == 0:
+			gOpts.dryrun = !gOpts.dryrun
+		case len(e.args) == 1 && e.args[0] == "on":
+			gOpts.dryrun = true
+		case len(e.args) == 1 && e.args[0] == "off":
+			gOpts.dryrun = false
+		default:
+			app.ui.echoerr("dry-run: usage: dry-run [on|off]")
+			return
+		}
+		if gOpts.dryrun {
+			app.ui.echomsg("dry-run: on")
+		} else {
+			app.ui.echomsg("dry-run: off")
+		}
+	case "setup":
+		if app.ui.cmdPrefix == ">" {
+			return
+		}
+		app.startSetup()
+	case "suspend":
+		if !app.nav.init {
+			return
+		}
+		app.suspend()
+		app.ui.loadFile(app, true)
+		app.ui.loadFileInfo(app.nav)
 	case "redraw":
 		if !app.nav.init {
 			return
@@ -1478,7 +2543,7 @@ func (e *callExpr) eval(app *app, args []string) {
 			app.nav.height = app.ui.wins[0].h
 			clear(app.nav.regCache)
 		}
-		if gOpts.sixel {
+		if hasGraphics() {
 			clear(app.nav.regCache)
 			app.ui.sxScreen.forceClear = true
 		}
@@ -1493,10 +2558,49 @@ func (e *callExpr) eval(app *app, args []string) {
 		}
 		app.nav.renew()
 		app.ui.loadFile(app, false)
+	case "reload-entry":
+		if !app.nav.init {
+			return
+		}
+		if len(e.args) == 0 {
+			app.ui.echoerr("reload-entry: usage: reload-entry path...")
+			return
+		}
+		for _, path := range e.args {
+			path = replaceTilde(path)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(app.nav.currDir().path, path)
+			}
+			app.nav.reloadEntry(path)
+		}
+		app.ui.loadFile(app, false)
+		app.ui.loadFileInfo(app.nav)
+	case "clear-cache":
+		if !app.nav.init {
+			return
+		}
+		clear(app.nav.regCache)
+		app.ui.loadFile(app, true)
+	case "refresh-git-status":
+		if !app.nav.init {
+			return
+		}
+		app.nav.gitStatus.invalidate()
+		app.refreshGitStatus()
 	case "reload":
 		if !app.nav.init {
 			return
 		}
+		if !gHeadless && !app.keyEval {
+			// reloads in quick succession (e.g. sent with 'lf -remote') are
+			// merged into a single one at the end of the interval, whereas
+			// reloads from mappings and the command line are run immediately
+			if delay := reloadDelay(app.nav.reloadTime); delay > 0 {
+				app.nav.reloadTimer.Reset(delay)
+				return
+			}
+		}
+		app.nav.reloadTime = time.Now()
 		if err := app.nav.reload(); err != nil {
 			app.ui.echoerrf("reload: %s", err)
 		}
@@ -1672,6 +2776,9 @@ func (e *callExpr) eval(app *app, args []string) {
 			app.ui.cmdAccLeft = []rune(strings.Join(dir.filter, " "))
 		} else {
 			app.ui.cmdAccLeft = []rune(strings.Join(e.args, " "))
+			if isIncFilter(app) {
+				update(app)
+			}
 		}
 		app.ui.loadFileInfo(app.nav)
 	case "setfilter":
@@ -1684,6 +2791,20 @@ func (e *callExpr) eval(app *app, args []string) {
 		}
 		app.ui.loadFile(app, true)
 		app.ui.loadFileInfo(app.nav)
+	case "hop":
+		if !app.nav.init || app.ui.cmdPrefix == ">" {
+			return
+		}
+		toggle := false
+		if len(e.args) != 0 {
+			if len(e.args) > 1 || e.args[0] != "toggle" {
+				app.ui.echoerr("hop: only 'toggle' is allowed as an argument")
+				return
+			}
+			toggle = true
+		}
+		normal(app)
+		app.hop(toggle)
 	case "mark-save":
 		if app.ui.cmdPrefix == ">" {
 			return
@@ -1704,11 +2825,72 @@ func (e *callExpr) eval(app *app, args []string) {
 		normal(app)
 		app.ui.menu = listMarks(app.nav.marks)
 		app.ui.cmdPrefix = "mark-remove: "
+	case "mark-export":
+		if len(e.args) != 1 {
+			app.ui.echoerr("mark-export: requires a file path")
+			return
+		}
+		if err := exportMarks(app.nav.marks, replaceTilde(e.args[0])); err != nil {
+			app.ui.echoerrf("mark-export: %s", err)
+		}
+	case "mark-import":
+		from := "lf"
+		args := e.args
+		if len(args) == 3 && args[0] == "--from" {
+			from = args[1]
+			args = args[2:]
+		}
+		if len(args) != 1 {
+			app.ui.echoerr("mark-import: requires a file path")
+			return
+		}
+		data, err := os.ReadFile(replaceTilde(args[0]))
+		if err != nil {
+			app.ui.echoerrf("mark-import: %s", err)
+			return
+		}
+		marks, err := parseMarks(data, from)
+		if err != nil {
+			app.ui.echoerrf("mark-import: %s", err)
+			return
+		}
+		if err := app.nav.updateMarks(func() error {
+			maps.Copy(app.nav.marks, marks)
+			return nil
+		}); err != nil {
+			app.ui.echoerrf("mark-import: %s", err)
+			return
+		}
+		if gSingleMode {
+			if err := app.nav.sync(); err != nil {
+				app.ui.echoerrf("mark-import: %s", err)
+				return
+			}
+		} else {
+			if err := remote("send sync"); err != nil {
+				app.ui.echoerrf("mark-import: %s", err)
+				return
+			}
+		}
+		app.ui.echomsg(fmt.Sprintf("mark-import: %d mark(s) imported", len(marks)))
+	case "bulkrename":
+		if !app.nav.init {
+			return
+		}
+		if cmd, ok := findCmd("bulkrename"); ok {
+			cmd.eval(app, e.args)
+			return
+		}
+		if app.ui.cmdPrefix == ">" {
+			return
+		}
+		normal(app)
+		app.bulkRename(isDryRun(e.args))
 	case "rename":
 		if !app.nav.init {
 			return
 		}
-		if cmd, ok := gOpts.cmds["rename"]; ok {
+		if cmd, ok := findCmd("rename"); ok {
 			cmd.eval(app, e.args)
 			if gSingleMode {
 				app.nav.renew()
@@ -1750,6 +2932,15 @@ func (e *callExpr) eval(app *app, args []string) {
 		app.ui.echomsg(strings.Join(e.args, " "))
 	case "echoerr":
 		app.ui.echoerr(strings.Join(e.args, " "))
+	case "connect":
+		if !app.nav.init {
+			return
+		}
+		if len(e.args) != 1 {
+			app.ui.echoerr("connect: requires an address (e.g. sftp://user@host/path)")
+			return
+		}
+		app.connect(e.args[0])
 	case "cd":
 		path := "~"
 		if len(e.args) > 0 {
@@ -1786,6 +2977,43 @@ func (e *callExpr) eval(app *app, args []string) {
 			restartIncCmd(app)
 			onChdir(app)
 		}
+	case "cd-physical":
+		if !app.nav.init {
+			return
+		}
+		app.nav.physical = true
+
+		dir := app.nav.currDir()
+		real, err := filepath.EvalSymlinks(dir.path)
+		if err != nil {
+			app.ui.echoerrf("cd-physical: %s", err)
+			return
+		}
+		if real == dir.path {
+			return
+		}
+
+		resetIncCmd(app)
+		preChdir(app)
+
+		// keep the cursor on the same entry in the resolved directory
+		if curr, err := app.nav.currFile(); err == nil {
+			err = app.nav.sel(filepath.Join(real, filepath.Base(curr.path)))
+		} else {
+			err = app.nav.cd(real)
+		}
+		if err != nil {
+			app.ui.echoerrf("cd-physical: %s", err)
+			return
+		}
+
+		app.ui.loadFile(app, true)
+		app.ui.loadFileInfo(app.nav)
+		app.nav.marks["'"] = dir.path
+		restartIncCmd(app)
+		onChdir(app)
+	case "cd-logical":
+		app.nav.physical = false
 	case "select":
 		if !app.nav.init {
 			return
@@ -1855,8 +3083,7 @@ func (e *callExpr) eval(app *app, args []string) {
 			app.ui.echoerr("source: requires an argument")
 			return
 		}
-		app.readFile(replaceTilde(e.args[0]))
-		app.ui.loadFileInfo(app.nav)
+		app.source(replaceTilde(e.args[0]))
 	case "push":
 		if len(e.args) != 1 {
 			app.ui.echoerr("push: requires an argument")
@@ -1877,6 +3104,12 @@ func (e *callExpr) eval(app *app, args []string) {
 			return
 		}
 		insert(app, e.args[0])
+	case "cmd-digraph":
+		app.ui.cmdPending = "digraph"
+		app.ui.cmdPendingAcc = nil
+	case "cmd-literal":
+		app.ui.cmdPending = "literal"
+		app.ui.cmdPendingAcc = nil
 	case "cmd-escape":
 		if app.ui.cmdPrefix == ">" {
 			return
@@ -1886,15 +3119,24 @@ func (e *callExpr) eval(app *app, args []string) {
 		matches := doComplete(app)
 		app.ui.menu = listMatches(app.ui.screen, matches, -1)
 	case "cmd-menu-complete":
+		if app.ui.cmdPrefix == "fuzzy: " {
+			app.fuzzyMove(1)
+			return
+		}
 		menuComplete(app, 1)
 	case "cmd-menu-complete-back":
+		if app.ui.cmdPrefix == "fuzzy: " {
+			app.fuzzyMove(-1)
+			return
+		}
 		menuComplete(app, -1)
 	case "cmd-menu-accept":
 		app.ui.menu = ""
 		app.menuCompActive = false
 	case "cmd-enter":
 		s := string(append(app.ui.cmdAccLeft, app.ui.cmdAccRight...))
-		if len(s) == 0 && app.ui.cmdPrefix != "filter: " && app.ui.cmdPrefix != ">" {
+		setup := app.setup != nil && strings.HasPrefix(app.ui.cmdPrefix, "setup: ")
+		if len(s) == 0 && app.ui.cmdPrefix != "filter: " && app.ui.cmdPrefix != ">" && !setup {
 			return
 		}
 
@@ -1904,6 +3146,11 @@ func (e *callExpr) eval(app *app, args []string) {
 		app.ui.cmdAccLeft = nil
 		app.ui.cmdAccRight = nil
 
+		if setup {
+			app.answerSetup(s)
+			return
+		}
+
 		switch app.ui.cmdPrefix {
 		case ":":
 			log.Printf("command: %s", s)
@@ -1970,6 +3217,14 @@ func (e *callExpr) eval(app *app, args []string) {
 				app.ui.loadFile(app, true)
 				app.ui.loadFileInfo(app.nav)
 			}
+		case "pager-search: ":
+			app.ui.cmdPrefix = ""
+			if p := app.ui.pager; p != nil {
+				p.search = s
+				if !p.find(true) {
+					p.msg = "pattern not found: " + s
+				}
+			}
 		case "filter: ":
 			log.Printf("filter: %s", s)
 			app.ui.cmdPrefix = ""
@@ -1978,6 +3233,8 @@ func (e *callExpr) eval(app *app, args []string) {
 			}
 			app.ui.loadFile(app, true)
 			app.ui.loadFileInfo(app.nav)
+		case "fuzzy: ":
+			app.fuzzyOpen()
 		case "find: ":
 			app.ui.cmdPrefix = ""
 			if moved, found := app.nav.findNext(); !found {
@@ -2016,6 +3273,16 @@ func (e *callExpr) eval(app *app, args []string) {
 			if oldPath == newPath {
 				return
 			}
+			if gOpts.dryrun {
+				line := fmt.Sprintf("rename '%s' to '%s'", oldPath, newPath)
+				if _, err := os.Stat(filepath.Dir(newPath)); os.IsNotExist(err) {
+					line += " (creating the directory)"
+				} else if _, err := os.Lstat(newPath); err == nil {
+					line += " (replacing the existing file)"
+				}
+				app.showDryRun("rename", []string{line})
+				return
+			}
 			app.nav.renameOldPath = oldPath
 			app.nav.renameNewPath = newPath
 
@@ -2035,7 +3302,7 @@ func (e *callExpr) eval(app *app, args []string) {
 				return
 			}
 
-			if err := app.nav.rename(); err != nil {
+			if err := app.nav.rename(app); err != nil {
 				app.ui.echoerrf("rename: %s", err)
 				return
 			}
@@ -2043,7 +3310,7 @@ func (e *callExpr) eval(app *app, args []string) {
 			if gSingleMode {
 				app.nav.renew()
 			} else {
-				if err := remote("send load"); err != nil {
+				if err := remote("send reload-entry " + escape(oldPath) + " " + escape(newPath)); err != nil {
 					app.ui.echoerrf("rename: %s", err)
 					return
 				}
@@ -2327,7 +3594,7 @@ func (e *callExpr) eval(app *app, args []string) {
 		if f.customInfo != v {
 			f.customInfo = v
 			// only sort when order changes
-			if getSortBy(dir) == customSort {
+			if getSortBy(dir).has(customSort) {
 				d.sort()
 			}
 		}
@@ -2382,7 +3649,7 @@ func (e *callExpr) eval(app *app, args []string) {
 		dir.visualWrap = -dir.visualWrap
 		dir.boundPos(app.nav.height)
 	default:
-		cmd, ok := gOpts.cmds[e.name]
+		cmd, ok := findCmd(e.name)
 		if !ok {
 			app.ui.echoerrf("command not found: %s", e.name)
 			return
@@ -315,13 +315,46 @@ var gEvalTests = []struct {
 	{
 		"cmd usage $du -h . | less",
 		[]string{"cmd", "usage", "$", "du -h . | less", "\n"},
-		[]expr{&cmdExpr{"usage", &execExpr{"$", "du -h . | less"}}},
+		[]expr{&cmdExpr{"usage", &execExpr{"$", "du -h . | less"}, ""}},
 	},
 
 	{
 		"cmd 世界 $echo 世界",
 		[]string{"cmd", "世界", "$", "echo 世界", "\n"},
-		[]expr{&cmdExpr{"世界", &execExpr{"$", "echo 世界"}}},
+		[]expr{&cmdExpr{"世界", &execExpr{"$", "echo 世界"}, ""}},
+	},
+
+	{
+		"cmd deploy &{{make deploy}} under ~/work/**",
+		[]string{"cmd", "deploy", "&", "{{", "make deploy", "}}", "under", "~/work/**", "\n"},
+		[]expr{&cmdExpr{"deploy", &execExpr{"&", "make deploy"}, "~/work/**"}},
+	},
+
+	{
+		"cmd deploy under ~/work/**",
+		[]string{"cmd", "deploy", "under", "~/work/**", "\n"},
+		[]expr{&cmdExpr{"deploy", nil, "~/work/**"}},
+	},
+
+	{
+		`cmd deploy :{{
+			cd ~/work
+			make
+		}} under ~/work/**; echo foo`,
+		[]string{
+			"cmd", "deploy", ":", "{{",
+			"cd", "~/work", "\n",
+			"make", "\n",
+			"}}", "under", "~/work/**", ";",
+			"echo", "foo", "\n",
+		},
+		[]expr{
+			&cmdExpr{"deploy", &listExpr{[]expr{
+				&callExpr{"cd", []string{"~/work"}, 1},
+				&callExpr{"make", nil, 1},
+			}, 1}, "~/work/**"},
+			&callExpr{"echo", []string{"foo"}, 1},
+		},
 	},
 
 	{
@@ -387,7 +420,7 @@ var gEvalTests = []struct {
 	{
 		"cmd usage $du -h $1 | less",
 		[]string{"cmd", "usage", "$", "du -h $1 | less", "\n"},
-		[]expr{&cmdExpr{"usage", &execExpr{"$", "du -h $1 | less"}}},
+		[]expr{&cmdExpr{"usage", &execExpr{"$", "du -h $1 | less"}, ""}},
 	},
 
 	{
@@ -423,7 +456,7 @@ var gEvalTests = []struct {
 			"gohome", &listExpr{[]expr{
 				&callExpr{"cd", []string{"~"}, 1},
 				&setExpr{"hidden", ""},
-			}, 1},
+			}, 1}, "",
 		}},
 	},
 
@@ -485,7 +518,7 @@ var gEvalTests = []struct {
 			cp $fs $1
 			tar -czvf $1.tar.gz $1
 			rm -rf $1
-		`}}},
+		`}, ""}},
 	},
 }
 
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Archives can be extracted natively when they are opened, instead of calling
+// the default opener, with the 'autoextract' option. Only the default 'open'
+// command is replaced, so custom 'open' commands are called as usual. Archives
+// are extracted either to the same directory as the archive ('same-dir') or to
+// a new directory named after the archive ('subdir'), or the choice is asked
+// each time ('ask'). Existing files are never overwritten.
+
+// Suffixes of supported archives, where longer suffixes are checked first.
+var gArchiveExts = []string{".tar.gz", ".tar.bz2", ".tgz", ".tbz", ".tbz2", ".tar", ".zip"}
+
+// This function returns the name of the given archive without its suffix, or
+// false if the file is not a supported archive.
+func archiveBase(path string) (string, bool) {
+	name := filepath.Base(path)
+	lower := strings.ToLower(name)
+	for _, ext := range gArchiveExts {
+		if strings.HasSuffix(lower, ext) && len(name) > len(ext) {
+			return name[:len(name)-len(ext)], true
+		}
+	}
+	return "", false
+}
+
+// This function returns the path in the destination directory for the given
+// entry name, and an error if the entry would be extracted outside of it.
+func extractPath(dstDir, name string) (string, error) {
+	name = filepath.Clean(filepath.FromSlash(name))
+	if name == "." {
+		return dstDir, nil
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("unsafe path in archive: %s", name)
+	}
+	return filepath.Join(dstDir, name), nil
+}
+
+// This function reports whether the symbolic link with the given name and
+// target stays inside the destination directory, so that later entries can
+// not be written outside of it through the link.
+func isLocalLink(name, target string) bool {
+	if filepath.IsAbs(target) {
+		return false
+	}
+	return filepath.IsLocal(filepath.Join(filepath.Dir(filepath.FromSlash(name)), filepath.FromSlash(target)))
+}
+
+func extractFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	// existing files are not overwritten
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm()|0o200)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func extractLink(path, target string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.Symlink(target, path)
+}
+
+func extractZipEntry(f *zip.File, path string) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return os.MkdirAll(path, os.ModePerm)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if mode&os.ModeSymlink != 0 {
+		b, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		if !isLocalLink(f.Name, string(b)) {
+			return fmt.Errorf("unsafe link in archive: %s", f.Name)
+		}
+		return extractLink(path, string(b))
+	}
+
+	return extractFile(path, rc, mode)
+}
+
+func extractZip(archive, dstDir string) (int, error) {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	count := 0
+	for _, f := range zr.File {
+		path, err := extractPath(dstDir, f.Name)
+		if err != nil {
+			return count, err
+		}
+		if err := extractZipEntry(f, path); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func extractTar(archive, dstDir string) (int, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r, err := tarStream(f, archive)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+
+		path, err := extractPath(dstDir, hdr.Name)
+		if err != nil {
+			return count, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, os.ModePerm)
+		case tar.TypeReg:
+			err = extractFile(path, tr, hdr.FileInfo().Mode())
+		case tar.TypeSymlink:
+			if !isLocalLink(hdr.Name, hdr.Linkname) {
+				return count, fmt.Errorf("unsafe link in archive: %s", hdr.Name)
+			}
+			err = extractLink(path, hdr.Linkname)
+		default:
+			// other entries such as devices and hard links are skipped
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// This function extracts the given archive to the destination directory and
+// returns the number of extracted entries.
+func extractArchive(archive, dstDir string) (int, error) {
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		return extractZip(archive, dstDir)
+	}
+	return extractTar(archive, dstDir)
+}
+
+// This function reports whether the given file should be extracted instead of
+// being opened, which is only the case when the default 'open' command is not
+// replaced with a custom one.
+func (app *app) shouldExtract(f *file) bool {
+	if gOpts.autoextract == "never" || len(app.nav.selections) != 0 {
+		return false
+	}
+
+	if _, ok := archiveBase(f.path); !ok {
+		return false
+	}
+
+	cmd, ok := findCmd("open")
+	def, hasDef := getShellProfile(gOpts.shell).cmds["open"]
+	return !ok || (hasDef && isShellDefault(cmd, def))
+}
+
+func (app *app) askExtract(f *file) {
+	base, _ := archiveBase(f.path)
+	app.ui.cmdPrefix = fmt.Sprintf("extract '%s' to [d]irectory '%s' or [s]ame directory? [d/s/N] ", filepath.Base(f.path), base)
+}
+
+// This function extracts the given archive in the background, either to a new
+// directory named after the archive or to the directory of the archive. The
+// new directory is renamed using 'dupfilefmt' when it already exists.
+func (app *app) extract(archive string, subdir bool) {
+	dstDir := filepath.Dir(archive)
+	if subdir {
+		base, _ := archiveBase(archive)
+		dstDir = filepath.Join(dstDir, base)
+		if lstat, err := os.Lstat(dstDir); err == nil {
+			dstDir = dupPath(filepath.Dir(dstDir), lstat)
+		}
+	}
+
+	if gOpts.dryrun {
+		app.showDryRun("extract", []string{fmt.Sprintf("extract '%s' to '%s'", archive, dstDir)})
+		return
+	}
+
+	runAsync(func() {
+		count, err := extractArchive(archive, dstDir)
+
+		if gSingleMode {
+			app.nav.renew()
+			app.ui.loadFile(app, true)
+		} else if err := remote("send load"); err != nil {
+			app.ui.exprChan <- &callExpr{"echoerr", []string{"extract: " + err.Error()}, 1}
+		}
+
+		if err != nil {
+			app.ui.exprChan <- &callExpr{"echoerr", []string{fmt.Sprintf("extract: %s (%d entries extracted)", err, count)}, 1}
+			return
+		}
+
+		msg := fmt.Sprintf("\033[0;32mExtracted %d entries to '%s'\033[0m", count, dstDir)
+		app.ui.exprChan <- &callExpr{"echo", []string{msg}, 1}
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveBase(t *testing.T) {
+	tests := []struct {
+		path string
+		base string
+		ok   bool
+	}{
+		{"/foo/bar.zip", "bar", true},
+		{"/foo/bar.tar.gz", "bar", true},
+		{"/foo/bar.TGZ", "bar", true},
+		{"/foo/bar.v1.tar.bz2", "bar.v1", true},
+		{"/foo/bar.gz", "", false},
+		{"/foo/.zip", "", false},
+		{"/foo/bar.txt", "", false},
+	}
+
+	for _, test := range tests {
+		if base, ok := archiveBase(test.path); base != test.base || ok != test.ok {
+			t.Errorf("at input '%s' expected '%s' and '%t' but got '%s' and '%t'", test.path, test.base, test.ok, base, ok)
+		}
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	dir := t.TempDir()
+
+	zipPath := filepath.Join(dir, "foo.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+	zw.Close()
+	f.Close()
+
+	tarPath := filepath.Join(dir, "evil.tar.gz")
+	f, err = os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "../evil.txt", Mode: 0o644, Size: 4, Typeflag: tar.TypeReg})
+	tw.Write([]byte("evil"))
+	tw.Close()
+	gw.Close()
+	f.Close()
+
+	dst := filepath.Join(dir, "out")
+	n, err := extractArchive(zipPath, dst)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 extracted files but got '%d' with error '%v'", n, err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "sub", "b.txt")); err != nil || string(b) != "sub/b.txt" {
+		t.Errorf("expected extracted file contents but got '%s' with error '%v'", b, err)
+	}
+
+	// existing files are not overwritten
+	if _, err := extractArchive(zipPath, dst); err == nil {
+		t.Errorf("expected an error when extracting over existing files")
+	}
+
+	if _, err := extractArchive(tarPath, dst); err == nil {
+		t.Errorf("expected an error for unsafe paths")
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected unsafe path not to be extracted")
+	}
+}
+
+func TestIsLocalLink(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		exp    bool
+	}{
+		{"foo", "bar", true},
+		{"sub/foo", "../bar", true},
+		{"foo", "../bar", false},
+		{"foo", "/etc/passwd", false},
+	}
+
+	for _, test := range tests {
+		if got := isLocalLink(test.name, test.target); got != test.exp {
+			t.Errorf("at input '%s' and '%s' expected '%t' but got '%t'", test.name, test.target, test.exp, got)
+		}
+	}
+}
+
+func TestExtractPath(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  string
+		ok   bool
+	}{
+		{"./", "/dst", true},
+		{"./foo/bar", "/dst/foo/bar", true},
+		{"foo/../bar", "/dst/bar", true},
+		{"../foo", "", false},
+		{"/etc/passwd", "", false},
+	}
+
+	for _, test := range tests {
+		got, err := extractPath("/dst", test.name)
+		if got != test.exp || (err == nil) != test.ok {
+			t.Errorf("at input '%s' expected '%s' but got '%s' with error '%v'", test.name, test.exp, got, err)
+		}
+	}
+}
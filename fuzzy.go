@@ -0,0 +1,324 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// The 'fuzzy' command indexes the current directory and its subdirectories up
+// to the depth in the 'fuzzydepth' option in the background, and shows the
+// entries matching the typed pattern in a menu above the prompt line with the
+// best match first. The characters of the pattern should appear in the path
+// of an entry relative to the current directory in the same order, and
+// matches are scored higher when the characters are consecutive or at the
+// start of path components. The menu is updated as entries are indexed, so
+// that large trees do not block the interface. The highlighted entry is moved
+// with 'cmd-menu-complete' and 'cmd-menu-complete-back', and confirming the
+// prompt changes to the highlighted directory or selects the highlighted file.
+
+const (
+	gFuzzyMenuMax    = 10
+	gFuzzyMaxEntries = 200000
+	gFuzzyBatch      = 1000
+)
+
+type fuzzyIndex struct {
+	root    string
+	mutex   sync.Mutex
+	paths   []string
+	dirs    map[string]bool
+	done    bool
+	stopped atomic.Bool
+}
+
+// This function walks the tree of the index up to the given depth, adding the
+// relative paths of the entries in batches, and calls the given function after
+// each batch so that matches can be updated while indexing. Hidden entries
+// are skipped unless the 'hidden' option is set, and symbolic links to
+// directories are not followed.
+func (idx *fuzzyIndex) walk(depth int, hidden bool, hiddenfiles []string, notify func()) {
+	var paths []string
+	var dirs []string
+	count := 0
+
+	flush := func(done bool) {
+		idx.mutex.Lock()
+		idx.paths = append(idx.paths, paths...)
+		for _, d := range dirs {
+			idx.dirs[d] = true
+		}
+		idx.done = done
+		idx.mutex.Unlock()
+		paths = nil
+		dirs = nil
+		notify()
+	}
+
+	err := filepath.WalkDir(idx.root, func(path string, d fs.DirEntry, err error) error {
+		if idx.stopped.Load() {
+			return filepath.SkipAll
+		}
+		if err != nil {
+			log.Printf("fuzzy: %s", err)
+			if d != nil && d.IsDir() && path != idx.root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == idx.root {
+			return nil
+		}
+
+		if !hidden {
+			if info, err := d.Info(); err == nil && isHidden(info, filepath.Dir(path), hiddenfiles) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		rel, err := filepath.Rel(idx.root, path)
+		if err != nil {
+			return nil
+		}
+
+		paths = append(paths, rel)
+		if d.IsDir() {
+			dirs = append(dirs, rel)
+		}
+
+		count++
+		if count == gFuzzyMaxEntries {
+			return filepath.SkipAll
+		}
+		if len(paths) == gFuzzyBatch {
+			flush(false)
+		}
+
+		if d.IsDir() && strings.Count(rel, string(filepath.Separator))+1 >= depth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("fuzzy: %s", err)
+	}
+
+	flush(true)
+}
+
+// This function returns the paths of the index matching the given pattern with
+// the best match first, up to the given number of paths, along with the
+// number of indexed paths and whether indexing is finished.
+func (idx *fuzzyIndex) matches(pattern string, limit int) ([]string, int, bool) {
+	idx.mutex.Lock()
+	paths := idx.paths
+	done := idx.done
+	idx.mutex.Unlock()
+
+	if pattern == "" {
+		return nil, len(paths), done
+	}
+
+	type match struct {
+		path  string
+		score int
+	}
+
+	var list []match
+	for _, path := range paths {
+		if score, ok := fuzzyScore(pattern, path); ok {
+			list = append(list, match{path, score})
+		}
+	}
+
+	slices.SortStableFunc(list, func(a, b match) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(len(a.path), len(b.path))
+	})
+
+	res := make([]string, 0, min(len(list), limit))
+	for _, m := range list[:min(len(list), limit)] {
+		res = append(res, m.path)
+	}
+
+	return res, len(paths), done
+}
+
+func (idx *fuzzyIndex) isDir(path string) bool {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	return idx.dirs[path]
+}
+
+// This function reports whether the characters of the given pattern appear in
+// the given path in the same order, and returns the score of the match. Each
+// character scores higher when it follows the previous matched character or
+// starts a path component or a word, and matches in the base name are
+// preferred. Case is ignored as in searches with the 'ignorecase' and
+// 'smartcase' options.
+func fuzzyScore(pattern, path string) (int, bool) {
+	if gOpts.ignorecase {
+		lpattern := strings.ToLower(pattern)
+		if !gOpts.smartcase || lpattern == pattern {
+			pattern = lpattern
+			path = strings.ToLower(path)
+		}
+	}
+
+	base := strings.LastIndexByte(path, filepath.Separator) + 1
+
+	score := 0
+	prev := -2
+	i := 0
+	for _, r := range pattern {
+		for i < len(path) {
+			c, w := utf8.DecodeRuneInString(path[i:])
+			if c == r {
+				break
+			}
+			i += w
+		}
+		if i == len(path) {
+			return 0, false
+		}
+
+		switch {
+		case i == prev:
+			score += 4
+		case i == 0 || strings.ContainsRune("/\\-_. ", rune(path[i-1])):
+			score += 3
+		default:
+			score++
+		}
+		if i >= base {
+			score++
+		}
+
+		_, w := utf8.DecodeRuneInString(path[i:])
+		i += w
+		prev = i
+	}
+
+	return score, true
+}
+
+// This function returns the menu of the given matches with the highlighted
+// match in reverse video.
+func listFuzzy(matches []string, ind, total int, done bool) string {
+	var b strings.Builder
+
+	status := "indexing"
+	if done {
+		status = "indexed"
+	}
+	fmt.Fprintf(&b, "fuzzy matches (%s %d entries)\n", status, total)
+
+	for i, m := range matches {
+		if i == ind {
+			fmt.Fprintf(&b, "\033[7m%s\033[0m\n", m)
+		} else {
+			fmt.Fprintln(&b, m)
+		}
+	}
+
+	return b.String()
+}
+
+func (app *app) fuzzy() {
+	idx := &fuzzyIndex{
+		root: app.nav.currDir().path,
+		dirs: make(map[string]bool),
+	}
+	app.fuzzyIdx = idx
+	app.fuzzyMatches = nil
+	app.fuzzyInd = 0
+	app.ui.cmdPrefix = "fuzzy: "
+
+	depth := gOpts.fuzzydepth
+	hidden := gOpts.hidden
+	hiddenfiles := slices.Clone(gOpts.hiddenfiles)
+
+	go idx.walk(depth, hidden, hiddenfiles, func() {
+		if !idx.stopped.Load() {
+			app.ui.exprChan <- &callExpr{"fuzzy-update", nil, 1}
+		}
+	})
+
+	app.fuzzyUpdate()
+}
+
+// This function stops indexing when the 'fuzzy' prompt is closed.
+func (app *app) fuzzyStop() {
+	if app.fuzzyIdx != nil {
+		app.fuzzyIdx.stopped.Store(true)
+		app.fuzzyIdx = nil
+	}
+	app.fuzzyMatches = nil
+}
+
+// This function updates the matches of the typed pattern in the menu.
+func (app *app) fuzzyUpdate() {
+	if app.fuzzyIdx == nil || app.ui.cmdPrefix != "fuzzy: " {
+		return
+	}
+
+	pattern := string(app.ui.cmdAccLeft) + string(app.ui.cmdAccRight)
+	limit := max(1, min(gFuzzyMenuMax, app.ui.wins[0].h-1))
+
+	matches, total, done := app.fuzzyIdx.matches(pattern, limit)
+	app.fuzzyMatches = matches
+
+	app.fuzzyInd = max(0, min(app.fuzzyInd, len(app.fuzzyMatches)-1))
+	app.ui.menu = listFuzzy(app.fuzzyMatches, app.fuzzyInd, total, done)
+}
+
+// This function moves the highlighted match by the given distance, wrapping
+// around at the ends of the menu.
+func (app *app) fuzzyMove(dist int) {
+	n := len(app.fuzzyMatches)
+	if n == 0 {
+		return
+	}
+	app.fuzzyInd = ((app.fuzzyInd+dist)%n + n) % n
+	app.fuzzyUpdate()
+}
+
+// This function changes to the highlighted directory or selects the
+// highlighted file.
+func (app *app) fuzzyOpen() {
+	idx := app.fuzzyIdx
+	matches := app.fuzzyMatches
+	ind := app.fuzzyInd
+	normal(app)
+
+	if idx == nil || len(matches) == 0 {
+		app.ui.echoerr("fuzzy: no match")
+		return
+	}
+
+	rel := matches[ind]
+	path := filepath.Join(idx.root, rel)
+	if _, err := os.Lstat(path); err != nil {
+		app.ui.echoerrf("fuzzy: %s", err)
+		return
+	}
+
+	if idx.isDir(rel) {
+		(&callExpr{"cd", []string{path}, 1}).eval(app, nil)
+	} else {
+		(&callExpr{"select", []string{path}, 1}).eval(app, nil)
+	}
+}
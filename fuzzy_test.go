@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	gOpts.ignorecase = true
+	gOpts.smartcase = true
+
+	tests := []struct {
+		pattern string
+		path    string
+		exp     bool
+	}{
+		{"abc", "abc", true},
+		{"abc", "a/b/c", true},
+		{"abc", "acb", false},
+		{"ABC", "abc", false},
+		{"abc", "ABC", true},
+		{"", "abc", true},
+		{"abcd", "abc", false},
+	}
+
+	for _, test := range tests {
+		if _, got := fuzzyScore(test.pattern, test.path); got != test.exp {
+			t.Errorf("at input '%s' with '%s' expected '%t' but got '%t'", test.pattern, test.path, test.exp, got)
+		}
+	}
+
+	better := [][3]string{
+		{"doc", "doc.md", "dir/other/c"},
+		{"main", "src/main.go", "m/a/i/n.go"},
+		{"foo", filepath.Join("a", "foo"), filepath.Join("foo", "a")},
+	}
+
+	for _, test := range better {
+		s1, _ := fuzzyScore(test[0], test[1])
+		s2, _ := fuzzyScore(test[0], test[2])
+		if s1 <= s2 {
+			t.Errorf("at input '%s' expected '%s' (%d) to score higher than '%s' (%d)", test[0], test[1], s1, test[2], s2)
+		}
+	}
+}
+
+func TestFuzzyIndex(t *testing.T) {
+	root := t.TempDir()
+	for _, path := range []string{"a/b/c/deep.txt", "a/file.txt", ".hidden/x.txt", "top.txt"} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		depth  int
+		hidden bool
+		exp    []string
+	}{
+		{1, false, []string{"a", "top.txt"}},
+		{2, false, []string{"a", "a/b", "a/file.txt", "top.txt"}},
+		{5, false, []string{"a", "a/b", "a/b/c", "a/b/c/deep.txt", "a/file.txt", "top.txt"}},
+		{1, true, []string{".hidden", "a", "top.txt"}},
+	}
+
+	for _, test := range tests {
+		idx := &fuzzyIndex{root: root, dirs: make(map[string]bool)}
+		idx.walk(test.depth, test.hidden, []string{".*"}, func() {})
+
+		var got []string
+		for _, p := range idx.paths {
+			got = append(got, filepath.ToSlash(p))
+		}
+		slices.Sort(got)
+
+		if !reflect.DeepEqual(got, test.exp) || !idx.done {
+			t.Errorf("at depth '%d' expected '%v' but got '%v'", test.depth, test.exp, got)
+		}
+	}
+
+	idx := &fuzzyIndex{root: root, dirs: make(map[string]bool)}
+	idx.walk(5, false, []string{".*"}, func() {})
+
+	matches, total, done := idx.matches("deep", 3)
+	if len(matches) != 1 || filepath.ToSlash(matches[0]) != "a/b/c/deep.txt" || total != 6 || !done {
+		t.Errorf("at pattern 'deep' got '%v' in '%d' entries", matches, total)
+	}
+	if !idx.isDir(filepath.Join("a", "b")) || idx.isDir("top.txt") {
+		t.Errorf("expected directories to be recorded in the index")
+	}
+}
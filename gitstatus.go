@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The 'gitstatus' option shows the git status of the files in the shown
+// directories in a column after the file names, with the indicators and
+// colors defined in the 'gitstatusfmt' option. Statuses are computed in the
+// background with 'git status' for each repository, so that large
+// repositories never block drawing, and they are cached for each repository.
+// Directories are shown with the most important status of their contents,
+// and the entries of untracked or ignored directories inherit the status of
+// the directory. Cached statuses are refreshed when they are older than an
+// interval, which is checked after changing directories and periodically, or
+// with the 'refresh-git-status' command.
+
+const (
+	gGitStatusInterval = 5 * time.Second
+	gGitStatusTimeout  = 10 * time.Second
+	gGitStatusCacheMax = 256
+)
+
+const (
+	gitModified  byte = 'M'
+	gitStaged    byte = 'S'
+	gitUntracked byte = '?'
+	gitIgnored   byte = '!'
+)
+
+var gGitStatusNames = map[string]byte{
+	"modified":  gitModified,
+	"staged":    gitStaged,
+	"untracked": gitUntracked,
+	"ignored":   gitIgnored,
+}
+
+type gitRepo struct {
+	status  map[string]byte
+	trees   map[string]byte
+	time    time.Time
+	running bool
+}
+
+type gitResult struct {
+	dir    string
+	root   string
+	status map[string]byte
+	trees  map[string]byte
+	err    error
+}
+
+type gitStatuses struct {
+	roots   map[string]string
+	repos   map[string]*gitRepo
+	pending map[string]bool
+}
+
+var (
+	gGitStatusFmts  map[byte]string
+	gGitStatusWidth int
+)
+
+// This function parses the value of the 'gitstatusfmt' option, which is a
+// colon separated list of '<status>=<indicator>' pairs, and returns the
+// indicators of the statuses along with the width of the widest indicator.
+func parseGitStatusFmt(val string) (map[byte]string, int, error) {
+	fmts := make(map[byte]string)
+	width := 0
+
+	if val == "" {
+		return fmts, width, nil
+	}
+
+	for _, s := range strings.Split(val, ":") {
+		name, ind, found := strings.Cut(s, "=")
+		if !found {
+			return nil, 0, fmt.Errorf("invalid pair: %s", s)
+		}
+		st, ok := gGitStatusNames[name]
+		if !ok {
+			return nil, 0, fmt.Errorf("status should be 'modified', 'staged', 'untracked' or 'ignored': %s", name)
+		}
+		fmts[st] = ind
+		width = max(width, printLength(ind))
+	}
+
+	return fmts, width, nil
+}
+
+// This function returns the priority of the given status, which is used to
+// show the most important status of the contents of directories.
+func gitPriority(st byte) int {
+	switch st {
+	case gitModified:
+		return 3
+	case gitStaged:
+		return 2
+	case gitUntracked:
+		return 1
+	}
+	return 0
+}
+
+// This function parses the output of 'git status --porcelain -z --ignored',
+// and returns the statuses of the entries and their parent directories
+// relative to the root of the repository, along with the statuses of untracked
+// and ignored directories whose entries are not listed.
+func parseGitStatus(out []byte) (map[string]byte, map[string]byte) {
+	status := make(map[string]byte)
+	trees := make(map[string]byte)
+
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i < len(fields); i++ {
+		entry := string(fields[i])
+		if len(entry) < 4 {
+			continue
+		}
+
+		x, y, rel := entry[0], entry[1], entry[3:]
+
+		// renamed and copied entries are followed by their original paths
+		if x == 'R' || x == 'C' {
+			i++
+		}
+
+		var st byte
+		switch {
+		case x == '?' && y == '?':
+			st = gitUntracked
+		case x == '!' && y == '!':
+			st = gitIgnored
+		case y != ' ':
+			st = gitModified
+		default:
+			st = gitStaged
+		}
+
+		if strings.HasSuffix(rel, "/") {
+			rel = strings.TrimSuffix(rel, "/")
+			trees[rel] = st
+		}
+		status[rel] = st
+
+		// ignored entries are not worth noticing in their parents
+		if st == gitIgnored {
+			continue
+		}
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			if gitPriority(status[dir]) >= gitPriority(st) {
+				break
+			}
+			status[dir] = st
+		}
+	}
+
+	return status, trees
+}
+
+// This function returns the root of the repository of the given directory, or
+// an empty string when the directory is not in a repository.
+func gitRoot(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--show-prefix")
+	cmd.Dir = dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	// the prefix is used instead of the toplevel directory so that the root
+	// is found with the same path when there are symbolic links on the way
+	root := dir
+	prefix := strings.TrimSuffix(strings.TrimSpace(string(out)), "/")
+	if prefix != "" {
+		for range strings.Split(prefix, "/") {
+			root = filepath.Dir(root)
+		}
+	}
+
+	return root
+}
+
+// This function returns the statuses of the entries of the repository in the
+// given root, killing 'git' when it takes longer than the timeout.
+func runGitStatus(root string) (map[string]byte, map[string]byte, error) {
+	var out bytes.Buffer
+
+	cmd := exec.Command("git", "status", "--porcelain", "-z", "--ignored")
+	cmd.Dir = root
+	cmd.Stdout = &out
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0")
+	cmd.WaitDelay = gGitStatusTimeout
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	timer := time.AfterFunc(gGitStatusTimeout, func() { cmd.Process.Kill() })
+	err := cmd.Wait()
+	if !timer.Stop() {
+		return nil, nil, fmt.Errorf("timed out after %s", gGitStatusTimeout)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status, trees := parseGitStatus(out.Bytes())
+	return status, trees, nil
+}
+
+// This function returns the root of the repository of the given directory
+// when it is known. Directories which are not looked up yet (e.g. previewed
+// directories) use the deepest known repository containing them.
+func (g *gitStatuses) rootOf(dir string) string {
+	if root, ok := g.roots[dir]; ok {
+		return root
+	}
+
+	root := ""
+	for r := range g.repos {
+		if len(r) > len(root) && (strings.HasPrefix(dir, r+string(filepath.Separator)) || r == dir) {
+			root = r
+		}
+	}
+
+	return root
+}
+
+// This function returns the status of the given file in the given directory,
+// or zero when the file is unmodified or the status is not known.
+func (g *gitStatuses) get(dir, name string) byte {
+	root := g.rootOf(dir)
+	if root == "" {
+		return 0
+	}
+
+	repo, ok := g.repos[root]
+	if !ok {
+		return 0
+	}
+
+	rel, err := filepath.Rel(root, filepath.Join(dir, name))
+	if err != nil {
+		return 0
+	}
+	rel = filepath.ToSlash(rel)
+
+	if st, ok := repo.status[rel]; ok {
+		return st
+	}
+	for p := path.Dir(rel); p != "."; p = path.Dir(p) {
+		if st, ok := repo.trees[p]; ok {
+			return st
+		}
+	}
+
+	return 0
+}
+
+// This function starts looking up the repositories of the given directories,
+// and the statuses of the repositories which are missing or older than the
+// interval.
+func (g *gitStatuses) refresh(dirs []string, ch chan<- gitResult) {
+	if g.roots == nil {
+		g.roots = make(map[string]string)
+		g.repos = make(map[string]*gitRepo)
+		g.pending = make(map[string]bool)
+	}
+
+	now := time.Now()
+	for _, dir := range dirs {
+		root, ok := g.roots[dir]
+		if !ok {
+			if g.pending[dir] {
+				continue
+			}
+			g.pending[dir] = true
+			go func() {
+				r := gitResult{dir: dir, root: gitRoot(dir)}
+				if r.root != "" {
+					r.status, r.trees, r.err = runGitStatus(r.root)
+				}
+				ch <- r
+			}()
+			continue
+		}
+		if root == "" {
+			continue
+		}
+
+		repo, ok := g.repos[root]
+		if !ok {
+			repo = &gitRepo{}
+			g.repos[root] = repo
+		}
+		if repo.running || now.Sub(repo.time) < gGitStatusInterval {
+			continue
+		}
+
+		repo.running = true
+		go func() {
+			r := gitResult{dir: dir, root: root}
+			r.status, r.trees, r.err = runGitStatus(root)
+			ch <- r
+		}()
+	}
+
+	// directories of other repositories are forgotten when there are too
+	// many of them, and they are looked up again when they are shown
+	if len(g.roots) > gGitStatusCacheMax {
+		clear(g.roots)
+		for root, repo := range g.repos {
+			if !repo.running && now.Sub(repo.time) > gGitStatusInterval {
+				delete(g.repos, root)
+			}
+		}
+	}
+}
+
+// This function stores the statuses of a repository, and reports whether they
+// changed.
+func (g *gitStatuses) update(r gitResult) bool {
+	delete(g.pending, r.dir)
+	g.roots[r.dir] = r.root
+	if r.root == "" {
+		return false
+	}
+
+	repo, ok := g.repos[r.root]
+	if !ok {
+		repo = &gitRepo{}
+		g.repos[r.root] = repo
+	}
+
+	repo.running = false
+	repo.time = time.Now()
+	if r.err != nil {
+		log.Printf("git status %s: %s", r.root, r.err)
+		return false
+	}
+
+	changed := !maps.Equal(repo.status, r.status) || !maps.Equal(repo.trees, r.trees)
+	repo.status = r.status
+	repo.trees = r.trees
+	return changed
+}
+
+// This function marks the cached statuses as outdated and forgets the
+// repositories of the directories, so that they are computed again on the next
+// refresh (e.g. after a repository is created).
+func (g *gitStatuses) invalidate() {
+	clear(g.roots)
+	for _, repo := range g.repos {
+		repo.time = time.Time{}
+	}
+}
+
+// This function returns the directories whose statuses are shown.
+func (app *app) gitStatusDirs() []string {
+	var dirs []string
+	for _, d := range app.nav.dirs {
+		dirs = append(dirs, d.path)
+	}
+	if app.nav.pane != nil {
+		dirs = append(dirs, app.nav.pane.path)
+	}
+	return dirs
+}
+
+func (app *app) refreshGitStatus() {
+	if !gOpts.gitstatus || !app.nav.init {
+		return
+	}
+	if app.gitStatusTicker.C == nil {
+		app.gitStatusTicker = time.NewTicker(time.Second)
+	}
+	app.nav.gitStatus.refresh(app.gitStatusDirs(), app.nav.gitStatusChan)
+}
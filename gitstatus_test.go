@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGitStatus(t *testing.T) {
+	out := " M src/main.go\x00M  src/util/str.go\x00MM doc.md\x00R  new.txt\x00old.txt\x00?? build/\x00!! cache/\x00!! src/tmp.o\x00"
+
+	status, trees := parseGitStatus([]byte(out))
+
+	expStatus := map[string]byte{
+		"src/main.go":     gitModified,
+		"src/util/str.go": gitStaged,
+		"src/util":        gitStaged,
+		"src":             gitModified,
+		"doc.md":          gitModified,
+		"new.txt":         gitStaged,
+		"build":           gitUntracked,
+		"cache":           gitIgnored,
+		"src/tmp.o":       gitIgnored,
+	}
+	expTrees := map[string]byte{
+		"build": gitUntracked,
+		"cache": gitIgnored,
+	}
+
+	if !reflect.DeepEqual(status, expStatus) {
+		t.Errorf("expected statuses '%v' but got '%v'", expStatus, status)
+	}
+	if !reflect.DeepEqual(trees, expTrees) {
+		t.Errorf("expected trees '%v' but got '%v'", expTrees, trees)
+	}
+}
+
+func TestParseGitStatusFmt(t *testing.T) {
+	tests := []struct {
+		s     string
+		fmts  map[byte]string
+		width int
+		err   bool
+	}{
+		{"", map[byte]string{}, 0, false},
+		{"modified=M", map[byte]string{gitModified: "M"}, 1, false},
+		{"modified=\033[33mM:ignored=\033[90mig", map[byte]string{gitModified: "\033[33mM", gitIgnored: "\033[90mig"}, 2, false},
+		{"modified", nil, 0, true},
+		{"changed=C", nil, 0, true},
+	}
+
+	for _, test := range tests {
+		fmts, width, err := parseGitStatusFmt(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("at input '%s' expected error '%t' but got '%v'", test.s, test.err, err)
+			continue
+		}
+		if !test.err && (!reflect.DeepEqual(fmts, test.fmts) || width != test.width) {
+			t.Errorf("at input '%s' expected '%v' with width '%d' but got '%v' with width '%d'", test.s, test.fmts, test.width, fmts, width)
+		}
+	}
+}
+
+func TestGitStatuses(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+
+	run("init", "-q")
+	for _, path := range []string{"sub/tracked.txt", "sub/new.txt", "other/x.txt", "ignored/y.txt", ".gitignore"} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("ignored/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", ".gitignore", "sub/tracked.txt")
+	run("-c", "user.name=lf", "-c", "user.email=lf@example.com", "commit", "-q", "-m", "init")
+	if err := os.WriteFile(filepath.Join(root, "sub", "tracked.txt"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := filepath.Join(root, "sub")
+	ch := make(chan gitResult, 1)
+
+	var g gitStatuses
+	g.refresh([]string{sub}, ch)
+	if !g.update(<-ch) {
+		t.Fatalf("expected statuses to change")
+	}
+
+	if got := g.rootOf(sub); got != root {
+		t.Errorf("expected root '%s' but got '%s'", root, got)
+	}
+
+	tests := []struct {
+		dir  string
+		name string
+		exp  byte
+	}{
+		{sub, "tracked.txt", gitModified},
+		{sub, "new.txt", gitUntracked},
+		{root, "sub", gitModified},
+		{root, "other", gitUntracked},
+		{filepath.Join(root, "other"), "x.txt", gitUntracked},
+		{root, "ignored", gitIgnored},
+		{root, ".gitignore", 0},
+	}
+
+	for _, test := range tests {
+		if got := g.get(test.dir, test.name); got != test.exp {
+			t.Errorf("at '%s' in '%s' expected '%q' but got '%q'", test.name, test.dir, test.exp, got)
+		}
+	}
+
+	outside := t.TempDir()
+	g.refresh([]string{outside}, ch)
+	g.update(<-ch)
+	if got := g.rootOf(outside); got != "" {
+		t.Errorf("expected no repository but got '%s'", got)
+	}
+}
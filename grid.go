@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// The current directory is shown in a grid of several columns similar to
+// 'ls -C' when the 'singlecolumn' option is disabled, which is useful for
+// directories with many short names. Files are laid out from top to bottom and
+// then from left to right, and the number of rows is reduced to balance the
+// columns when all files fit in the window. All columns have the width of the
+// longest name, and only names are shown (i.e. no file information, line
+// numbers or git statuses). The grid scrolls by columns to keep the current
+// file visible. The layout of the last drawn grid is kept in the directory so
+// that moving across columns, mouse clicks and 'hop' labels use the same one.
+
+type gridView struct {
+	rows  int // number of rows
+	width int // width of the cells
+	beg   int // index of the first shown entry
+	end   int // index after the last shown entry
+}
+
+// This function reports whether the directory with the given role is shown
+// in a grid.
+func isGrid(role dirRole) bool {
+	return !gOpts.singlecolumn && role == Active
+}
+
+// This function returns the given name truncated to the given width with the
+// 'truncatechar' option at the position given by the 'truncatepct' option.
+func truncateName(name []rune, width int) []rune {
+	if runeSliceWidth(name) <= width {
+		return name
+	}
+
+	truncatePos := (width - 1) * gOpts.truncatepct / 100
+	lastPart := runeSliceWidthLastRange(name, width-truncatePos-1)
+	name = runeSliceWidthRange(name, 0, truncatePos)
+	name = append(name, []rune(gOpts.truncatechar)...)
+	return append(name, lastPart...)
+}
+
+// This function updates the layout of the grid for a window with the given
+// size and cells with the given width, so that the current file is shown
+// while scrolling as little as possible from the last layout.
+func (dir *dir) layoutGrid(width, height, cellWidth int) {
+	cols := max(width/cellWidth, 1)
+	n := len(dir.files)
+	rows := height
+	if n <= height*cols {
+		rows = (n + cols - 1) / cols
+	}
+	rows = max(rows, 1)
+
+	total := (n + rows - 1) / rows
+	curr := dir.ind / rows
+	first := 0
+	if dir.grid.rows > 0 {
+		first = dir.grid.beg / dir.grid.rows
+	}
+	first = min(first, curr)
+	first = max(first, curr-cols+1)
+	first = max(0, min(first, total-cols))
+
+	dir.grid = gridView{rows, cellWidth, first * rows, min(n, (first+cols)*rows)}
+}
+
+func (win *win) printGrid(ui *ui, dir *dir, context *dirContext, dirStyle *dirStyle) {
+	// leave extra space to separate windows if drawbox is not enabled
+	width := win.w
+	if !gOpts.drawbox {
+		width--
+	}
+
+	iconWidth := 0
+	if gOpts.icons {
+		for _, f := range dir.files {
+			iconWidth = max(iconWidth, runeSliceWidth([]rune(dirStyle.icons.get(f).icon))+1)
+		}
+	}
+
+	nameWidth := 0
+	for _, f := range dir.files {
+		nameWidth = max(nameWidth, runeSliceWidth([]rune(f.Name())))
+	}
+
+	// each cell has the select marker and the tag before the icon and the
+	// name, and a space at the end
+	cellWidth := min(2+iconWidth+nameWidth+1, width)
+	nameWidth = cellWidth - 2 - iconWidth - 1
+
+	dir.layoutGrid(width, win.h, cellWidth)
+
+	cursorFmt := optionToFmtstr(gOpts.cursoractivefmt)
+	visualSelections := dir.visualSelections()
+	for i, f := range dir.files[dir.grid.beg:dir.grid.end] {
+		st := dirStyle.colors.get(f)
+		x := i / dir.grid.rows * cellWidth
+		y := i % dir.grid.rows
+
+		path := filepath.Join(dir.path, f.Name())
+
+		if slices.Contains(visualSelections, path) {
+			win.print(ui.screen, x, y, parseEscapeSequence(gOpts.visualfmt), " ")
+		} else if _, ok := context.selections[path]; ok {
+			win.print(ui.screen, x, y, parseEscapeSequence(gOpts.selectfmt), " ")
+		} else if cp, ok := context.saves[path]; ok {
+			if cp {
+				win.print(ui.screen, x, y, parseEscapeSequence(gOpts.copyfmt), " ")
+			} else {
+				win.print(ui.screen, x, y, parseEscapeSequence(gOpts.cutfmt), " ")
+			}
+		}
+
+		tag := " "
+		if val, ok := context.tags[path]; ok && len(val) > 0 {
+			tag = val
+		} else if f.mountPoint && gOpts.mountmarker != "" {
+			tag = gOpts.mountmarker
+		}
+
+		var icon []rune
+		var iconDef iconDef
+		if gOpts.icons {
+			iconDef = dirStyle.icons.get(f)
+			icon = append(icon, []rune(iconDef.icon)...)
+			icon = append(icon, []rune(strings.Repeat(" ", iconWidth-runeSliceWidth(icon)))...)
+		}
+
+		filename := truncateName([]rune(f.Name()), nameWidth)
+		for j := runeSliceWidth(filename); j < nameWidth; j++ {
+			filename = append(filename, ' ')
+		}
+
+		if dir.grid.beg+i == dir.ind {
+			// print tag separately as it can contain color escape sequences
+			win.print(ui.screen, x+1, y, st, fmt.Sprintf(cursorFmt, tag))
+
+			line := append(icon, filename...)
+			line = append(line, ' ')
+			win.print(ui.screen, x+2, y, st, fmt.Sprintf(cursorFmt, string(line)))
+		} else {
+			if tag == " " {
+				win.print(ui.screen, x+1, y, st, " ")
+			} else {
+				tagStr := fmt.Sprintf(optionToFmtstr(gOpts.tagfmt), tag)
+				win.print(ui.screen, x+1, y, tcell.StyleDefault, tagStr)
+			}
+
+			if len(icon) > 0 {
+				iconStyle := st
+				if iconDef.hasStyle {
+					iconStyle = iconDef.style
+				}
+				win.print(ui.screen, x+2, y, iconStyle, string(icon))
+			}
+
+			win.print(ui.screen, x+2+iconWidth, y, st, string(filename))
+		}
+
+		// print the remaining characters of matching labels for 'hop'
+		if i < len(ui.hopLabels) {
+			typed := string(ui.cmdAccLeft)
+			if label, ok := strings.CutPrefix(ui.hopLabels[i], typed); ok {
+				win.print(ui.screen, x+2, y, tcell.StyleDefault, fmt.Sprintf(optionToFmtstr(gOpts.hopfmt), label))
+			}
+		}
+	}
+
+	// rows of the grid are shaded as a whole since they have several files
+	colors := ui.screen.Colors()
+	for y := range min(dir.grid.rows, win.h) {
+		if rowStyle, ok := dirStyle.rowStyle(y == (dir.ind-dir.grid.beg)%dir.grid.rows, y%2 == 1, colors); ok {
+			win.shade(ui.screen, 0, y, win.w, rowStyle)
+		}
+	}
+}
+
+// This function returns the index of the entry in the given cell of the last
+// drawn grid, or -1 when there is no entry in the cell.
+func (dir *dir) gridIndex(x, y int) int {
+	if dir.grid.rows == 0 || y >= dir.grid.rows {
+		return -1
+	}
+	ind := dir.grid.beg + x/dir.grid.width*dir.grid.rows + y
+	if ind >= dir.grid.end {
+		return -1
+	}
+	return ind
+}
+
+// This function moves the cursor to the same row in the column at the given
+// distance in the grid (i.e. to the left for negative distances). The cursor
+// is moved to the last file when the last column is shorter.
+func (nav *nav) gridMove(dist int) bool {
+	dir := nav.currDir()
+	rows := dir.grid.rows
+	if gOpts.singlecolumn || rows == 0 || len(dir.files) == 0 {
+		return false
+	}
+
+	total := (len(dir.files) + rows - 1) / rows
+	col := max(0, min(dir.ind/rows+dist, total-1))
+	return nav.move(min(col*rows+dir.ind%rows, len(dir.files)-1))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLayoutGrid(t *testing.T) {
+	dir := &dir{}
+	for i := range 25 {
+		dir.files = append(dir.files, &file{path: fmt.Sprintf("/tmp/%02d", i)})
+	}
+
+	// all files fit in 5 columns of 10 rows, so the rows are balanced
+	dir.layoutGrid(50, 10, 10)
+	if dir.grid != (gridView{5, 10, 0, 25}) {
+		t.Errorf("expected balanced layout but got '%v'", dir.grid)
+	}
+	if ind := dir.gridIndex(25, 3); ind != 13 {
+		t.Errorf("expected index '13' but got '%d'", ind)
+	}
+	if ind := dir.gridIndex(25, 7); ind != -1 {
+		t.Errorf("expected no index below the rows but got '%d'", ind)
+	}
+
+	// 2 columns of 4 rows are shown out of 7 columns
+	dir.grid = gridView{}
+	tests := []struct {
+		ind int
+		beg int
+		end int
+	}{
+		{0, 0, 8},
+		{7, 0, 8},
+		{8, 4, 12},
+		{5, 4, 12},
+		{2, 0, 8},
+		{24, 20, 25},
+	}
+	for _, test := range tests {
+		dir.ind = test.ind
+		dir.layoutGrid(20, 4, 10)
+		if dir.grid.beg != test.beg || dir.grid.end != test.end {
+			t.Errorf("at input '%d' expected '%d-%d' but got '%d-%d'", test.ind, test.beg, test.end, dir.grid.beg, dir.grid.end)
+		}
+	}
+}
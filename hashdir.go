@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Checksums of the files in a directory can be written to a 'SHA256SUMS'
+// manifest with the 'hashdir' command and verified later with 'hashdir
+// --check', e.g. before and after archiving data to cold storage. Manifests
+// are written in the format of 'sha256sum' with names relative to the
+// directory, so that they can also be verified with 'sha256sum -c' in the
+// directory. Only regular files are included and symbolic links are not
+// followed.
+
+const gHashManifest = "SHA256SUMS"
+
+// This function returns the checksum of the given file with the given hash.
+// Read bytes are sent to the given channel for progress.
+func hashFile(path string, h hash.Hash, nums chan<- int64) ([]byte, error) {
+	f, err := openPath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(NewProgressWriter(h, nums), f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// This function returns the names of regular files under the given paths
+// relative to the given directory in sorted order, and their total size. The
+// manifest of the directory itself is skipped.
+func hashList(dir string, paths []string) (names []string, total int64, err error) {
+	manifest := filepath.Join(dir, gHashManifest)
+	for _, root := range paths {
+		err := walkPath(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() || path == manifest {
+				return nil
+			}
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			if name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("outside of the current directory: %s", path)
+			}
+			names = append(names, filepath.ToSlash(name))
+			total += info.Size()
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	slices.Sort(names)
+	return slices.Compact(names), total, nil
+}
+
+// This function writes the manifest of the given files in the given directory,
+// replacing an existing manifest only when all files are read successfully.
+func writeManifest(dir string, names []string, nums chan<- int64) error {
+	sums := make([][]byte, len(names))
+	for i, name := range names {
+		sum, err := hashFile(filepath.Join(dir, filepath.FromSlash(name)), sha256.New(), nums)
+		if err != nil {
+			return err
+		}
+		sums[i] = sum
+	}
+
+	f, err := os.CreateTemp(dir, "."+gHashManifest+"-*")
+	if err != nil {
+		return err
+	}
+
+	err = fprintChecksums(f, names, sums)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(dir, gHashManifest))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
+
+// This function verifies the files in the given checksums of the manifest in
+// the given directory and adds the result for each file to the given report.
+func checkManifest(dir string, sums map[string]string, r *report, nums chan<- int64) {
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		sum, err := hashFile(path, sha256.New(), nums)
+		switch {
+		case err != nil:
+			r.add(path, []string{err.Error()})
+		case hex.EncodeToString(sum) != sums[name]:
+			r.add(path, []string{"checksum mismatch"})
+		default:
+			r.add(path, nil)
+		}
+	}
+}
+
+// This function returns the total size of the files in the given checksums.
+func manifestSize(dir string, sums map[string]string) int64 {
+	var total int64
+	for name := range sums {
+		if stat, err := lstatPath(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			total += stat.Size()
+		}
+	}
+	return total
+}
+
+func (app *app) hashDir(check, dryRun bool) {
+	dir := app.nav.currDir().path
+	manifest := filepath.Join(dir, gHashManifest)
+
+	paths := app.nav.currSelections()
+	if len(paths) == 0 {
+		paths = []string{dir}
+	}
+
+	if !check {
+		if err := checkWritableDir(dir); err != nil {
+			app.ui.echoerrf("hashdir: %s", err)
+			return
+		}
+
+		if dryRun {
+			var plan []string
+			for _, path := range paths {
+				line := fmt.Sprintf("write the checksums of '%s' to '%s'", path, manifest)
+				if _, err := os.Lstat(manifest); err == nil {
+					line += " (replacing the existing file)"
+				}
+				plan = append(plan, line)
+			}
+			app.showDryRun("hashdir", plan)
+			return
+		}
+	}
+
+	app.runQueued(append(slices.Clone(paths), manifest), func() {
+		start := time.Now()
+		op := "hash"
+		if check {
+			op = "verify"
+		}
+		r := newReport(op, dir)
+
+		var err error
+		if check {
+			var sums map[string]string
+			sums, err = readChecksums(manifest)
+			if err == nil {
+				total := manifestSize(dir, sums)
+				app.nav.copyTotalChan <- total
+				checkManifest(dir, sums, r, app.nav.copyBytesChan)
+				app.nav.copyTotalChan <- -total
+			}
+		} else {
+			var names []string
+			var total int64
+			names, total, err = hashList(dir, paths)
+			if err == nil {
+				app.nav.copyTotalChan <- total
+				err = writeManifest(dir, names, app.nav.copyBytesChan)
+				app.nav.copyTotalChan <- -total
+			}
+			if err == nil {
+				r.done = len(names)
+			}
+		}
+
+		errCount := len(r.failed)
+		if err != nil {
+			errCount++
+			r.add(manifest, []string{err.Error()})
+			app.ui.exprChan <- &callExpr{"echoerr", []string{"hashdir: " + err.Error()}, 1}
+		}
+
+		app.audit(op, paths, dir, start, errCount)
+		app.finishReport(r)
+		if !check {
+			app.reloadAfter("hashdir")
+		}
+
+		switch {
+		case err != nil:
+		case check && errCount > 0:
+			msg := fmt.Sprintf("hashdir: %d of %d files failed verification", errCount, errCount+r.done)
+			app.ui.exprChan <- &callExpr{"echoerr", []string{msg}, 1}
+		case check:
+			msg := fmt.Sprintf("\033[0;32mVerified %d files in %s\033[0m", r.done, gHashManifest)
+			app.ui.exprChan <- &callExpr{"echo", []string{msg}, 1}
+		default:
+			msg := fmt.Sprintf("\033[0;32mWrote checksums of %d files to %s\033[0m", r.done, gHashManifest)
+			app.ui.exprChan <- &callExpr{"echo", []string{msg}, 1}
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHashDir(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"a b.txt":     "foo",
+		"sub/c.txt":   "bar",
+		"sub/d/e.txt": "baz",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a b.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	names, total, err := hashList(dir, []string{dir})
+	if err != nil {
+		t.Fatalf("listing files: %s", err)
+	}
+	exp := []string{"a b.txt", "sub/c.txt", "sub/d/e.txt"}
+	if !slices.Equal(names, exp) || total != 9 {
+		t.Errorf("expected '%v' with size 9 but got '%v' with size %d", exp, names, total)
+	}
+
+	if _, _, err := hashList(filepath.Join(dir, "sub"), []string{dir}); err == nil {
+		t.Error("expected an error for files outside of the directory")
+	}
+
+	nums, wait := drainNums()
+	err = writeManifest(dir, names, nums)
+	if read := wait(); err != nil || read != total {
+		t.Fatalf("expected %d bytes read but got %d with error '%v'", total, read, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, gHashManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae  a b.txt"
+	if !strings.HasPrefix(string(data), line+"\n") {
+		t.Errorf("expected manifest starting with '%s' but got '%s'", line, data)
+	}
+
+	names, _, err = hashList(dir, []string{dir})
+	if err != nil || len(names) != 3 {
+		t.Errorf("expected the manifest to be skipped but got '%v'", names)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "sub", "c.txt"), []byte("qux"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "sub", "d", "e.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	sums, err := readChecksums(filepath.Join(dir, gHashManifest))
+	if err != nil {
+		t.Fatalf("reading manifest: %s", err)
+	}
+
+	r := newReport("verify", dir)
+	nums, wait = drainNums()
+	checkManifest(dir, sums, r, nums)
+	wait()
+
+	if r.done != 1 || len(r.failed) != 2 {
+		t.Fatalf("expected 1 verified and 2 failed but got '%s'", r.summary())
+	}
+	if got := r.failed[0].reasons[0]; got != "checksum mismatch" {
+		t.Errorf("expected 'checksum mismatch' but got '%s'", got)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Headless mode runs commands without the user interface, so that the command
+// language of lf can be used for scripting file operations. A simulation
+// screen is used in place of the terminal, directories are loaded and file
+// operations are run synchronously so that commands take effect in order, and
+// messages are printed to the standard output (and errors to the standard
+// error) instead of the message line. The progress of file operations is
+// printed as well in batch mode. Scans reporting their results through the
+// main loop (e.g. 'dirsize', 'stats' and 'cleanup') are run in place instead,
+// since there is no main loop in this mode, and their results are printed
+// once they are finished. Commands waiting for user input (e.g. 'rename' or
+// 'read') are not supported in this mode.
+
+// This function runs the given function in a goroutine, except in headless
+// mode where it is run synchronously.
+func runAsync(f func()) {
+	if gHeadless {
+		f()
+	} else {
+		go f()
+	}
+}
+
+// This function runs the given commands in headless mode and returns the
+// number of errors reported. The progress of file operations is written to
+// the given writer.
+func runHeadless(r io.Reader, w io.Writer) int {
+	if gLogPath != "" {
+		f, err := os.OpenFile(gLogPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			log.Fatalf("failed to open log file: %s", err)
+		}
+		defer f.Close()
+		log.SetOutput(f)
+	} else {
+		log.SetOutput(io.Discard)
+	}
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		log.Fatalf("initializing screen: %s", err)
+	}
+	screen.SetSize(80, 24)
+
+	ui := newUI(screen)
+	nav := newNav(ui.wins[0].h)
+	app := newApp(ui, nav)
+
+	app.progress = &headlessProgress{w: w, sync: make(chan chan struct{})}
+
+	go app.nav.previewLoop(app.ui)
+	go app.progress.loop(app.nav)
+
+	if err := nav.sync(); err != nil {
+		app.ui.echoerrf("sync: %s", err)
+	}
+
+	app.readConfig()
+
+	// previews are never shown so there is no need to load them
+	gOpts.preview = false
+
+	wd, err := os.Getwd()
+	if err != nil {
+		app.ui.echoerrf("getting current directory: %s", err)
+		return app.ui.errCount
+	}
+
+	app.nav.getDirs(wd)
+	app.nav.addJumpList()
+	app.nav.init = true
+
+	if gSelect != "" {
+		if lstat, err := os.Lstat(gSelect); err != nil {
+			app.ui.echoerr(err.Error())
+		} else if lstat.IsDir() {
+			(&callExpr{"cd", []string{gSelect}, 1}).eval(app, nil)
+		} else {
+			(&callExpr{"select", []string{gSelect}, 1}).eval(app, nil)
+		}
+	}
+
+	for _, cmd := range gCommands {
+		app.runHeadlessScript(strings.NewReader(cmd))
+	}
+
+	app.runHeadlessScript(r)
+
+	app.quit()
+	app.nav.previewChan <- ""
+	app.ui.screen.Fini()
+
+	app.writeExitFiles()
+
+	return app.ui.errCount
+}
+
+func (app *app) runHeadlessScript(r io.Reader) {
+	p := newParser(r)
+
+	for p.parse() {
+		p.expr.eval(app, nil)
+		app.flushHeadless()
+
+		// there is no user to answer prompts (e.g. 'delete' confirmation)
+		if app.ui.cmdPrefix != "" {
+			name := "command"
+			if e, ok := p.expr.(*callExpr); ok {
+				name = e.name
+			}
+			app.ui.echoerrf("%s: user input is not supported without the user interface", name)
+			normal(app)
+		}
+	}
+
+	if p.err != nil {
+		app.ui.echoerrf("%s", p.err)
+	}
+}
+
+// This function evaluates the expressions sent by file operations, which are
+// mostly messages to be printed, after their progress is printed.
+func (app *app) flushHeadless() {
+	app.progress.wait()
+
+	for {
+		select {
+		case e := <-app.ui.exprChan:
+			e.eval(app, nil)
+		default:
+			return
+		}
+	}
+}
+
+// The progress of file operations is otherwise read in the main loop to be
+// shown in the ruler. In headless mode it is read in a separate goroutine
+// instead, since file operations are run in the same goroutine as commands.
+// Totals are only accumulated until the operations of a command are finished,
+// as the order of the values received from different channels is not known.
+type headlessProgress struct {
+	w            io.Writer
+	sync         chan chan struct{}
+	copyBytes    int64
+	copyTotal    int64
+	copyUpdate   int
+	moveCount    int
+	moveTotal    int
+	moveUpdate   int
+	deleteCount  int
+	deleteTotal  int
+	deleteUpdate int
+}
+
+func (p *headlessProgress) loop(nav *nav) {
+	for {
+		select {
+		case n := <-nav.copyBytesChan:
+			p.copyBytes += n
+			// same rate as the ruler, roughly per 4MB copied
+			if p.copyUpdate++; p.copyUpdate >= 128 && p.copyTotal > 0 {
+				p.copyUpdate = 0
+				fmt.Fprintf(p.w, "copy: [%d%%]\n", min(p.copyBytes*100/p.copyTotal, 100))
+			}
+		case n := <-nav.copyTotalChan:
+			p.copyTotal += max(n, 0)
+		case n := <-nav.moveCountChan:
+			p.moveCount += n
+			if p.moveUpdate++; p.moveUpdate >= 1000 {
+				p.moveUpdate = 0
+				fmt.Fprintf(p.w, "move: [%d/%d]\n", p.moveCount, p.moveTotal)
+			}
+		case n := <-nav.moveTotalChan:
+			p.moveTotal += max(n, 0)
+		case n := <-nav.deleteCountChan:
+			p.deleteCount += n
+			if p.deleteUpdate++; p.deleteUpdate >= 1000 {
+				p.deleteUpdate = 0
+				fmt.Fprintf(p.w, "delete: [%d/%d]\n", p.deleteCount, p.deleteTotal)
+			}
+		case n := <-nav.deleteTotalChan:
+			p.deleteTotal += max(n, 0)
+		case done := <-p.sync:
+			p.finish(nav)
+			close(done)
+		}
+	}
+}
+
+// This function prints the final progress of the finished operations. The
+// remaining values are received first without waiting for new ones.
+func (p *headlessProgress) finish(nav *nav) {
+	for done := false; !done; {
+		select {
+		case n := <-nav.copyBytesChan:
+			p.copyBytes += n
+		case n := <-nav.copyTotalChan:
+			p.copyTotal += max(n, 0)
+		case n := <-nav.moveCountChan:
+			p.moveCount += n
+		case n := <-nav.moveTotalChan:
+			p.moveTotal += max(n, 0)
+		case n := <-nav.deleteCountChan:
+			p.deleteCount += n
+		case n := <-nav.deleteTotalChan:
+			p.deleteTotal += max(n, 0)
+		default:
+			done = true
+		}
+	}
+
+	if p.copyTotal > 0 {
+		fmt.Fprintf(p.w, "copy: %s\n", humanize(p.copyTotal))
+	}
+	if p.moveTotal > 0 {
+		fmt.Fprintf(p.w, "move: [%d/%d]\n", p.moveCount, p.moveTotal)
+	}
+	if p.deleteTotal > 0 {
+		fmt.Fprintf(p.w, "delete: [%d/%d]\n", p.deleteCount, p.deleteTotal)
+	}
+
+	*p = headlessProgress{w: p.w, sync: p.sync}
+}
+
+// This function waits until the progress of the finished operations is
+// printed. File operations are run synchronously in headless mode, so the
+// operations of a command are finished by the time it returns.
+func (p *headlessProgress) wait() {
+	done := make(chan struct{})
+	p.sync <- done
+	<-done
+}
+
+// This function prints a message in headless mode. Escape sequences (e.g. for
+// the error format) are stripped since the output is not a terminal screen.
+func printHeadless(w io.Writer, msg string) {
+	fmt.Fprintln(w, stripAnsi(msg))
+}
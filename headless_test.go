@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeadlessProgress(t *testing.T) {
+	var b strings.Builder
+	nav := newNav(10)
+	p := &headlessProgress{w: &b, sync: make(chan chan struct{})}
+	go p.loop(nav)
+
+	// values from different channels may be received in any order
+	nav.moveTotalChan <- 2
+	nav.moveCountChan <- 1
+	nav.moveCountChan <- 1
+	nav.moveTotalChan <- -2
+	nav.copyTotalChan <- 2048
+	nav.copyBytesChan <- 1024
+	nav.copyBytesChan <- 1024
+	nav.copyTotalChan <- -2048
+	p.wait()
+
+	nav.deleteTotalChan <- 3
+	nav.deleteCountChan <- 1
+	nav.deleteCountChan <- 1
+	nav.deleteCountChan <- 1
+	nav.deleteTotalChan <- -3
+	p.wait()
+
+	p.wait()
+
+	exp := "copy: 2.0K\nmove: [2/2]\ndelete: [3/3]\n"
+	if got := b.String(); got != exp {
+		t.Errorf("expected progress '%s' but got '%s'", exp, got)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// The help browser shows the embedded documentation in the internal pager.
+// The markdown source is used rather than the plain text version so that
+// headings can be recognized as topics and code spans naming other topics
+// can be rendered as links to follow.
+
+var (
+	reHelpTopic = regexp.MustCompile(`(?:^|, )([\w{}-]+)`)
+	reHelpCode  = regexp.MustCompile("`[^`]*`")
+	reHelpEmph  = regexp.MustCompile(`\*\*?([^*]+)\*\*?`)
+)
+
+type helpLink struct {
+	line  int
+	col   int
+	width int
+	topic string
+}
+
+type helpDoc struct {
+	lines  []string
+	links  []helpLink
+	topics map[string]int
+}
+
+// This function returns the topics of a heading, which are the names of the
+// commands, options or variables described in a section, or the title of a
+// top level section.
+func helpTopics(heading string) []string {
+	if strings.HasPrefix(heading, "# ") {
+		return []string{strings.ToLower(heading[2:])}
+	}
+
+	heading = strings.TrimPrefix(heading, "## ")
+	heading = reHelpCode.ReplaceAllString(heading, "")
+
+	var topics []string
+	for _, m := range reHelpTopic.FindAllStringSubmatch(heading, -1) {
+		topics = append(topics, m[1])
+	}
+	return topics
+}
+
+// This function returns the topics of the documentation for completion.
+func helpTopicWords() []string {
+	var words []string
+	for _, line := range strings.Split(genDocMarkdown, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			words = append(words, helpTopics(line)...)
+		}
+	}
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+// This function renders the markdown documentation as lines of the given
+// width. Paragraphs are wrapped, indented blocks are kept as they are, and
+// code spans matching a topic are underlined and recorded as links.
+func renderHelp(md string, width int) *helpDoc {
+	doc := &helpDoc{topics: make(map[string]int)}
+
+	// topics are collected first so that links to later sections are known
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(line, "#") {
+			for _, topic := range helpTopics(line) {
+				doc.topics[topic] = -1
+			}
+		}
+	}
+
+	var words []string
+	flush := func() {
+		if len(words) > 0 {
+			doc.wrap(words, width)
+			words = nil
+		}
+	}
+
+	for _, line := range strings.Split(md, "\n") {
+		switch {
+		case strings.HasPrefix(line, "#"):
+			flush()
+			heading := strings.TrimLeft(line, "# ")
+			heading = strings.ReplaceAll(heading, "`", "")
+			for _, topic := range helpTopics(line) {
+				if doc.topics[topic] == -1 {
+					doc.topics[topic] = len(doc.lines)
+				}
+			}
+			doc.lines = append(doc.lines, "\033[1m"+heading+"\033[0m")
+		case strings.HasPrefix(line, "\t"):
+			flush()
+			doc.lines = append(doc.lines, "    "+strings.ReplaceAll(line[1:], "\t", "    "))
+		case line == "":
+			flush()
+			doc.lines = append(doc.lines, "")
+		case strings.HasPrefix(line, "- "):
+			flush()
+			words = append(words, strings.Fields(line)...)
+		default:
+			// emphasis is only used outside of code spans (i.e. synopsis)
+			if !strings.Contains(line, "`") {
+				line = reHelpEmph.ReplaceAllString(line, "$1")
+			}
+			words = append(words, strings.Fields(line)...)
+		}
+	}
+	flush()
+
+	// collapse consecutive empty lines left by headings and blocks
+	var lines []string
+	shift := make([]int, len(doc.lines))
+	for i, line := range doc.lines {
+		if line == "" && len(lines) > 0 && lines[len(lines)-1] == "" {
+			shift[i] = -1
+			continue
+		}
+		shift[i] = len(lines)
+		lines = append(lines, line)
+	}
+	for topic, i := range doc.topics {
+		doc.topics[topic] = shift[i]
+	}
+	for i := range doc.links {
+		doc.links[i].line = shift[doc.links[i].line]
+	}
+	doc.lines = lines
+
+	return doc
+}
+
+// This function wraps the words of a paragraph, keeping code spans together
+// and adding links for code spans that name a topic.
+func (doc *helpDoc) wrap(words []string, width int) {
+	// join words belonging to the same code span
+	var toks []string
+	for i := 0; i < len(words); i++ {
+		tok := words[i]
+		for strings.Count(tok, "`")%2 == 1 && i+1 < len(words) {
+			i++
+			tok += " " + words[i]
+		}
+		toks = append(toks, tok)
+	}
+
+	var b strings.Builder
+	col := 0
+	newline := func() {
+		doc.lines = append(doc.lines, b.String())
+		b.Reset()
+		col = 0
+	}
+
+	for _, tok := range toks {
+		plain := strings.ReplaceAll(tok, "`", "")
+		w := runewidth.StringWidth(plain)
+
+		if col > 0 && col+1+w > width {
+			newline()
+		}
+		if col > 0 {
+			b.WriteByte(' ')
+			col++
+		}
+
+		rest := tok
+		for {
+			i := strings.IndexByte(rest, '`')
+			j := -1
+			if i >= 0 {
+				j = strings.IndexByte(rest[i+1:], '`')
+			}
+			if i < 0 || j < 0 {
+				rest = strings.ReplaceAll(rest, "`", "")
+				b.WriteString(rest)
+				col += runewidth.StringWidth(rest)
+				break
+			}
+
+			b.WriteString(rest[:i])
+			col += runewidth.StringWidth(rest[:i])
+
+			code := rest[i+1 : i+1+j]
+			cw := runewidth.StringWidth(code)
+			if _, ok := doc.topics[code]; ok {
+				doc.links = append(doc.links, helpLink{len(doc.lines), col, cw, code})
+				b.WriteString("\033[4m" + code + "\033[4:0m")
+			} else {
+				b.WriteString(code)
+			}
+			col += cw
+
+			rest = rest[i+1+j+1:]
+		}
+	}
+
+	newline()
+}
+
+// This function returns the line of the given topic. Topics are matched
+// exactly first, then case insensitively, and then by a unique prefix.
+func (doc *helpDoc) find(topic string) (int, bool) {
+	if line, ok := doc.topics[topic]; ok {
+		return line, true
+	}
+
+	matches := func(match func(t string) bool) (int, bool) {
+		line, found := 0, 0
+		for t, l := range doc.topics {
+			if match(strings.ToLower(t)) {
+				line = l
+				found++
+			}
+		}
+		if found != 1 {
+			return 0, false
+		}
+		return line, true
+	}
+
+	topic = strings.ToLower(topic)
+	if line, ok := matches(func(t string) bool { return t == topic }); ok {
+		return line, true
+	}
+	return matches(func(t string) bool { return strings.HasPrefix(t, topic) })
+}
+
+func newHelpPager(topic string, width int) (*pager, bool) {
+	doc := renderHelp(genDocMarkdown, max(20, width-1))
+
+	p := &pager{
+		title:  "help",
+		lines:  doc.lines,
+		links:  doc.links,
+		link:   -1,
+		topics: doc.topics,
+	}
+
+	if topic != "" {
+		line, ok := doc.find(topic)
+		if !ok {
+			return nil, false
+		}
+		p.title = "help: " + topic
+		p.pos = line
+	}
+
+	return p, true
+}
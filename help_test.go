@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHelpTopics(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp []string
+	}{
+		{"# PREVIEWING FILES", []string{"previewing files"}},
+		{"## quit (default `q`)", []string{"quit"}},
+		{"## top (default `gg` and `<home>`), bottom (default `G` and `<end>`)", []string{"top", "bottom"}},
+		{"## hidden (bool) (default false)", []string{"hidden"}},
+		{"## lf_width, lf_height", []string{"lf_width", "lf_height"}},
+		{"## lf_{option}", []string{"lf_{option}"}},
+	}
+
+	for _, test := range tests {
+		if got := helpTopics(test.s); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("at input '%s' expected '%v' but got '%v'", test.s, test.exp, got)
+		}
+	}
+}
+
+func TestRenderHelp(t *testing.T) {
+	md := "# COMMANDS\n" +
+		"\n" +
+		"## open (default `l`)\n" +
+		"\n" +
+		"Run the `opener` or `open` command.\n" +
+		"\n" +
+		"\tmap l open\n" +
+		"\n" +
+		"## opener (string)\n" +
+		"\n" +
+		"Program used by `open`.\n"
+
+	doc := renderHelp(md, 24)
+
+	expLines := []string{
+		"\033[1mCOMMANDS\033[0m",
+		"",
+		"\033[1mopen (default l)\033[0m",
+		"",
+		"Run the \033[4mopener\033[4:0m or \033[4mopen\033[4:0m",
+		"command.",
+		"",
+		"    map l open",
+		"",
+		"\033[1mopener (string)\033[0m",
+		"",
+		"Program used by \033[4mopen\033[4:0m.",
+		"",
+	}
+	if !reflect.DeepEqual(doc.lines, expLines) {
+		t.Errorf("expected lines %q but got %q", expLines, doc.lines)
+	}
+
+	expLinks := []helpLink{
+		{4, 8, 6, "opener"},
+		{4, 18, 4, "open"},
+		{11, 16, 4, "open"},
+	}
+	if !reflect.DeepEqual(doc.links, expLinks) {
+		t.Errorf("expected links %v but got %v", expLinks, doc.links)
+	}
+
+	tests := []struct {
+		topic string
+		exp   int
+		found bool
+	}{
+		{"open", 2, true},
+		{"opener", 9, true},
+		{"Commands", 0, true},
+		{"opene", 9, true},
+		{"ope", 0, false},
+		{"foo", 0, false},
+	}
+
+	for _, test := range tests {
+		if got, found := doc.find(test.topic); got != test.exp || found != test.found {
+			t.Errorf("at input '%s' expected %d (%t) but got %d (%t)", test.topic, test.exp, test.found, got, found)
+		}
+	}
+}
+
+func TestPagerLinks(t *testing.T) {
+	doc := renderHelp("## a\n\nSee `b` and `c`.\n\n## b\n\n## c\n", 80)
+	p := &pager{lines: doc.lines, links: doc.links, link: -1, topics: doc.topics}
+
+	p.selectLink(true, 3)
+	p.selectLink(true, 3)
+	if p.link != 1 {
+		t.Errorf("expected link 1 to be selected but got %d", p.link)
+	}
+
+	p.followLink()
+	if p.pos != doc.topics["c"] || p.link != -1 {
+		t.Errorf("expected position %d after following link but got %d", doc.topics["c"], p.pos)
+	}
+
+	p.goBack()
+	if p.pos != 0 {
+		t.Errorf("expected position 0 after going back but got %d", p.pos)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+)
+
+// The 'hop' command shows short labels on the visible entries in the current
+// directory, so that the cursor can be moved to an entry (or its selection can
+// be toggled) by typing its label instead of counting lines. Labels are made
+// of characters in the 'hopchars' option and they are shown until a label is
+// typed completely.
+
+// This function returns labels for the given number of entries. Labels are
+// single characters when there are enough characters, and otherwise the last
+// characters are used as prefixes for two character labels so that no label
+// is a prefix of another one. Entries are left without labels when there are
+// not enough labels even with two characters.
+func hopLabels(chars string, n int) []string {
+	var runes []rune
+	for _, r := range chars {
+		if !strings.ContainsRune(string(runes), r) {
+			runes = append(runes, r)
+		}
+	}
+
+	k := len(runes)
+	if k == 0 || n <= 0 {
+		return nil
+	}
+
+	// number of single character labels so that the rest fit in two
+	// characters, i.e. the largest 's' with 's + (k-s)*k >= n'
+	single := k
+	if n > k && k > 1 {
+		single = max(0, (k*k-n)/(k-1))
+	}
+
+	labels := make([]string, 0, n)
+	for _, r := range runes[:single] {
+		if len(labels) == n {
+			return labels
+		}
+		labels = append(labels, string(r))
+	}
+	for _, p := range runes[single:] {
+		for _, r := range runes {
+			if len(labels) == n {
+				return labels
+			}
+			labels = append(labels, string([]rune{p, r}))
+		}
+	}
+
+	return labels
+}
+
+// This function returns the range of the shown entries of the current
+// directory, which have the labels.
+func (nav *nav) hopRange() (int, int) {
+	dir := nav.currDir()
+	if !gOpts.singlecolumn {
+		return dir.grid.beg, min(dir.grid.end, len(dir.files))
+	}
+	beg := max(dir.ind-dir.pos, 0)
+	return beg, min(beg+nav.height, len(dir.files))
+}
+
+func (app *app) hop(toggle bool) {
+	beg, end := app.nav.hopRange()
+
+	labels := hopLabels(gOpts.hopchars, end-beg)
+	if len(labels) == 0 {
+		return
+	}
+
+	app.ui.hopLabels = labels
+	if toggle {
+		app.ui.cmdPrefix = "hop-toggle: "
+	} else {
+		app.ui.cmdPrefix = "hop: "
+	}
+}
+
+// This function is called for each character typed while labels are shown.
+// The cursor is moved to the entry (or its selection is toggled) when a label
+// is typed completely, and labels are hidden when no label starts with the
+// typed characters.
+func (app *app) hopInsert(arg string) {
+	typed := string(app.ui.cmdAccLeft) + arg
+	toggle := app.ui.cmdPrefix == "hop-toggle: "
+
+	ind := -1
+	prefix := false
+	for i, label := range app.ui.hopLabels {
+		if label == typed {
+			ind = i
+			break
+		}
+		if strings.HasPrefix(label, typed) {
+			prefix = true
+		}
+	}
+
+	if ind < 0 && prefix {
+		app.ui.cmdAccLeft = append(app.ui.cmdAccLeft, []rune(arg)...)
+		return
+	}
+
+	normal(app)
+
+	if ind < 0 {
+		app.ui.echoerrf("hop: no such label: %s", typed)
+		return
+	}
+
+	dir := app.nav.currDir()
+	beg, _ := app.nav.hopRange()
+	ind += beg
+	if ind >= len(dir.files) {
+		return
+	}
+
+	if toggle {
+		app.nav.toggleSelection(dir.files[ind].path)
+	} else if app.nav.move(ind) {
+		app.ui.loadFile(app, true)
+	}
+	app.ui.loadFileInfo(app.nav)
+}
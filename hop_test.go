@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHopLabels(t *testing.T) {
+	tests := []struct {
+		chars string
+		n     int
+		exp   []string
+	}{
+		{"", 3, nil},
+		{"abc", 0, nil},
+		{"abc", 2, []string{"a", "b"}},
+		{"abc", 3, []string{"a", "b", "c"}},
+		{"abca", 3, []string{"a", "b", "c"}},
+		{"abc", 4, []string{"a", "b", "ca", "cb"}},
+		{"abc", 5, []string{"a", "b", "ca", "cb", "cc"}},
+		{"abc", 6, []string{"a", "ba", "bb", "bc", "ca", "cb"}},
+		{"abc", 9, []string{"aa", "ab", "ac", "ba", "bb", "bc", "ca", "cb", "cc"}},
+		{"abc", 12, []string{"aa", "ab", "ac", "ba", "bb", "bc", "ca", "cb", "cc"}},
+		{"a", 3, []string{"a"}},
+	}
+
+	for _, test := range tests {
+		if got := hopLabels(test.chars, test.n); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("at input '%s' with '%d' entries expected '%v' but got '%v'", test.chars, test.n, test.exp, got)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -17,6 +18,7 @@ type iconDef struct {
 
 type iconMap struct {
 	icons         map[string]iconDef
+	origins       map[string]ruleOrigin
 	useLinkTarget bool
 }
 
@@ -31,6 +33,7 @@ func iconWithStyle(icon string, style tcell.Style) iconDef {
 func parseIcons() iconMap {
 	im := iconMap{
 		icons:         make(map[string]iconDef),
+		origins:       make(map[string]ruleOrigin),
 		useLinkTarget: false,
 	}
 
@@ -51,10 +54,10 @@ func parseIcons() iconMap {
 		"fi=-",
 	}
 
-	im.parseEnv(strings.Join(defaultIcons, ":"))
+	im.parseEnv(strings.Join(defaultIcons, ":"), "default")
 
 	if env := os.Getenv("LF_ICONS"); env != "" {
-		im.parseEnv(env)
+		im.parseEnv(env, "$LF_ICONS")
 	}
 
 	for _, path := range gIconsPaths {
@@ -76,18 +79,18 @@ func (im *iconMap) parseFile(path string) {
 	}
 	defer f.Close()
 
-	arrs, err := readArrays(f, 1, 3)
+	arrs, lines, err := readArrayLines(f, 1, 3)
 	if err != nil {
 		log.Printf("reading icons file: %s", err)
 		return
 	}
 
-	for _, arr := range arrs {
-		im.parseArray(arr)
+	for i, arr := range arrs {
+		im.parseArray(arr, fmt.Sprintf("%s:%d", path, lines[i]))
 	}
 }
 
-func (im *iconMap) parseEnv(env string) {
+func (im *iconMap) parseEnv(env, source string) {
 	for _, entry := range strings.Split(env, ":") {
 		if entry == "" {
 			continue
@@ -100,11 +103,11 @@ func (im *iconMap) parseEnv(env string) {
 			return
 		}
 
-		im.parseArray(pair)
+		im.parseArray(pair, source)
 	}
 }
 
-func (im *iconMap) parseArray(arr []string) {
+func (im *iconMap) parseArray(arr []string, source string) {
 	key := replaceTilde(arr[0])
 
 	if filepath.IsAbs(key) {
@@ -114,84 +117,28 @@ func (im *iconMap) parseArray(arr []string) {
 	switch len(arr) {
 	case 1:
 		delete(im.icons, key)
+		delete(im.origins, key)
 	case 2:
 		icon := arr[1]
 		if key == "ln" && icon == "target" {
 			im.useLinkTarget = true
 		} else {
 			im.icons[key] = iconWithoutStyle(icon)
+			im.origins[key] = ruleOrigin{source, icon}
 		}
 	case 3:
 		icon, color := arr[1], arr[2]
 		im.icons[key] = iconWithStyle(icon, applyAnsiCodes(color, tcell.StyleDefault))
+		im.origins[key] = ruleOrigin{source, icon + " " + color}
 	}
 }
 
 func (im iconMap) get(f *file) iconDef {
-	if val, ok := im.icons[f.path]; ok {
-		return val
-	}
-
-	if f.IsDir() {
-		if val, ok := im.icons[f.Name()+"/"]; ok {
+	for _, k := range ruleKeys(f, im.useLinkTarget) {
+		if val, ok := im.icons[k.key]; ok {
 			return val
 		}
 	}
 
-	var key string
-
-	switch {
-	case f.linkState == working && !im.useLinkTarget:
-		key = "ln"
-	case f.linkState == broken:
-		key = "or"
-	case f.IsDir() && f.Mode()&os.ModeSticky != 0 && f.Mode()&0o002 != 0:
-		key = "tw"
-	case f.IsDir() && f.Mode()&0o002 != 0:
-		key = "ow"
-	case f.IsDir() && f.Mode()&os.ModeSticky != 0:
-		key = "st"
-	case f.IsDir():
-		key = "di"
-	case f.Mode()&os.ModeNamedPipe != 0:
-		key = "pi"
-	case f.Mode()&os.ModeSocket != 0:
-		key = "so"
-	case f.Mode()&os.ModeCharDevice != 0:
-		key = "cd"
-	case f.Mode()&os.ModeDevice != 0:
-		key = "bd"
-	case f.Mode()&os.ModeSetuid != 0:
-		key = "su"
-	case f.Mode()&os.ModeSetgid != 0:
-		key = "sg"
-	case f.Mode()&0o111 != 0:
-		key = "ex"
-	}
-
-	if val, ok := im.icons[key]; ok {
-		return val
-	}
-
-	if val, ok := im.icons[f.Name()+"*"]; ok {
-		return val
-	}
-
-	if val, ok := im.icons["*"+f.Name()]; ok {
-		return val
-	}
-
-	if val, ok := im.icons[filepath.Base(f.Name())+".*"]; ok {
-		return val
-	}
-
-	if val, ok := im.icons["*"+strings.ToLower(f.ext)]; ok {
-		return val
-	}
-
-	if val, ok := im.icons["fi"]; ok {
-		return val
-	}
-
 	return iconWithoutStyle(" ")
 }
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Disk images (i.e. '.iso' and '.img' files) can be opened as read-only
+// virtual directories with the 'imagedirs' option, so that files inside them
+// can be previewed and copied out without root permissions or mounting them.
+// Files inside an image have paths under the path of the image (e.g.
+// '/foo/disk.iso/boot/grub'), and shell commands run in the directory of the
+// image while browsing it. Supported file systems are ISO9660 with Joliet and
+// Rock Ridge names, UDF, and FAT12/16/32 either for the whole image or in the
+// first FAT partition of an MBR partition table. File systems are detected
+// from the contents of the image instead of the extension. Archives are opened
+// in the same way with the 'archivedirs' option (see 'image_archive.go').
+
+var gImageExts = []string{".iso", ".img"}
+
+var (
+	errImageReadOnly   = errors.New("disk image is read-only")
+	errArchiveReadOnly = errors.New("archive is read-only")
+)
+
+// Directories larger than this are considered corrupted instead of loading
+// them to memory.
+const gMaxImageDirSize = 64 << 20
+
+func isImageName(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return slices.Contains(gImageExts, ext)
+}
+
+// This type describes where the contents of a file are stored in the image,
+// where extents with a negative offset are not recorded and read as zeros.
+type imageExtent struct {
+	off  int64
+	size int64
+}
+
+// This type is a file or directory in an image, which implements both
+// 'fs.FileInfo' and 'fs.DirEntry'. Contents are either read from the extents
+// in the image, from the data embedded in the file system metadata, or with
+// the read function for compressed contents. Children of directories are read
+// when they are first needed.
+type imageNode struct {
+	name     string
+	size     int64
+	mode     fs.FileMode
+	modTime  time.Time
+	extents  []imageExtent
+	data     []byte
+	read     func() (io.ReadCloser, error)
+	children []*imageNode
+	loaded   bool
+}
+
+func (n *imageNode) Name() string               { return n.name }
+func (n *imageNode) Size() int64                { return n.size }
+func (n *imageNode) Mode() fs.FileMode          { return n.mode }
+func (n *imageNode) ModTime() time.Time         { return n.modTime }
+func (n *imageNode) IsDir() bool                { return n.mode.IsDir() }
+func (n *imageNode) Sys() any                   { return nil }
+func (n *imageNode) Type() fs.FileMode          { return n.mode.Type() }
+func (n *imageNode) Info() (fs.FileInfo, error) { return n, nil }
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+func (n *imageNode) reader(r io.ReaderAt) io.Reader {
+	if n.data != nil {
+		return bytes.NewReader(n.data)
+	}
+
+	readers := make([]io.Reader, len(n.extents))
+	for i, e := range n.extents {
+		if e.off < 0 {
+			readers[i] = io.LimitReader(zeroReader{}, e.size)
+		} else {
+			readers[i] = io.NewSectionReader(r, e.off, e.size)
+		}
+	}
+	return io.LimitReader(io.MultiReader(readers...), n.size)
+}
+
+// This function reads the whole contents of the given directory.
+func readImageDir(r io.ReaderAt, n *imageNode) ([]byte, error) {
+	if n.size > gMaxImageDirSize {
+		return nil, fmt.Errorf("directory too large: %s", n.name)
+	}
+	return io.ReadAll(n.reader(r))
+}
+
+// This interface is implemented for each supported file system.
+type imageFS interface {
+	root() *imageNode
+	readDir(n *imageNode) ([]*imageNode, error)
+}
+
+// This type is an opened image, which implements 'fs.FS' for the file system
+// in the image.
+type diskImage struct {
+	file    *os.File
+	size    int64
+	modTime time.Time
+	fsys    imageFS
+	mutex   sync.Mutex
+}
+
+// This function detects the file system in the given image.
+func detectImage(r io.ReaderAt, size int64) (imageFS, error) {
+	if hasUDF(r) {
+		if fsys, err := newUDF(r); err == nil {
+			return fsys, nil
+		}
+	}
+
+	if hasISO(r) {
+		return newISO(r)
+	}
+
+	if fsys, err := newFAT(r, 0, size); err == nil {
+		return fsys, nil
+	}
+
+	if off, ok := mbrFATPartition(r); ok {
+		return newFAT(r, off, size-off)
+	}
+
+	return nil, errors.New("unsupported disk image")
+}
+
+func (img *diskImage) children(n *imageNode) ([]*imageNode, error) {
+	img.mutex.Lock()
+	defer img.mutex.Unlock()
+
+	if n.loaded {
+		return n.children, nil
+	}
+
+	children, err := img.fsys.readDir(n)
+	if err != nil {
+		return nil, err
+	}
+
+	// names should be unique and valid in 'fs.FS'
+	var valid []*imageNode
+	for _, c := range children {
+		if !fs.ValidPath(c.name) || strings.Contains(c.name, "/") || c.name == "." {
+			continue
+		}
+		if slices.ContainsFunc(valid, func(v *imageNode) bool { return v.name == c.name }) {
+			continue
+		}
+		valid = append(valid, c)
+	}
+	slices.SortFunc(valid, func(a, b *imageNode) int { return strings.Compare(a.name, b.name) })
+
+	n.children = valid
+	n.loaded = true
+
+	return valid, nil
+}
+
+func (img *diskImage) lookup(op, name string) (*imageNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	n := img.fsys.root()
+	if name == "." {
+		return n, nil
+	}
+
+	for _, elem := range strings.Split(name, "/") {
+		if !n.IsDir() {
+			return nil, &fs.PathError{Op: op, Path: name, Err: errors.New("not a directory")}
+		}
+		children, err := img.children(n)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		i := slices.IndexFunc(children, func(c *imageNode) bool { return c.name == elem })
+		if i < 0 {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		n = children[i]
+	}
+
+	return n, nil
+}
+
+func (img *diskImage) Open(name string) (fs.File, error) {
+	n, err := img.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f := &imageFile{img: img, node: n}
+	switch {
+	case n.IsDir():
+	case n.read != nil:
+		rc, err := n.read()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		f.r, f.c = rc, rc
+	default:
+		f.r = n.reader(img.file)
+	}
+	return f, nil
+}
+
+func (img *diskImage) Stat(name string) (fs.FileInfo, error) {
+	return img.lookup("stat", name)
+}
+
+func (img *diskImage) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := img.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	children, err := img.children(n)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, len(children))
+	for i, c := range children {
+		entries[i] = c
+	}
+	return entries, nil
+}
+
+type imageFile struct {
+	img  *diskImage
+	node *imageNode
+	r    io.Reader
+	c    io.Closer
+	pos  int
+}
+
+func (f *imageFile) Stat() (fs.FileInfo, error) { return f.node, nil }
+
+func (f *imageFile) Close() error {
+	if f.c != nil {
+		return f.c.Close()
+	}
+	return nil
+}
+
+func (f *imageFile) Read(b []byte) (int, error) {
+	if f.r == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.node.name, Err: errors.New("is a directory")}
+	}
+	return f.r.Read(b)
+}
+
+func (f *imageFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	children, err := f.img.children(f.node)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := children[f.pos:]
+	if count > 0 {
+		if len(rest) == 0 {
+			return nil, io.EOF
+		}
+		rest = rest[:min(count, len(rest))]
+	}
+	f.pos += len(rest)
+
+	entries := make([]fs.DirEntry, len(rest))
+	for i, c := range rest {
+		entries[i] = c
+	}
+	return entries, nil
+}
+
+// Opened images are kept until they are modified, since reading the file
+// system again for each directory would be slow.
+var gImages = struct {
+	mutex  sync.Mutex
+	images map[string]*diskImage
+}{images: make(map[string]*diskImage)}
+
+func openImage(path string) (*diskImage, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	gImages.mutex.Lock()
+	defer gImages.mutex.Unlock()
+
+	if img, ok := gImages.images[path]; ok && img.size == stat.Size() && img.modTime.Equal(stat.ModTime()) {
+		return img, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fsys imageFS
+	if isArchiveName(path) {
+		fsys, err = detectArchive(f, stat.Size(), stat.ModTime(), path)
+	} else {
+		fsys, err = detectImage(f, stat.Size())
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %s", filepath.Base(path), err)
+	}
+
+	img := &diskImage{file: f, size: stat.Size(), modTime: stat.ModTime(), fsys: fsys}
+	gImages.images[path] = img
+
+	return img, nil
+}
+
+// This function reports whether the given file is opened as a directory,
+// which depends on the 'imagedirs' and 'archivedirs' options.
+func isImageDirName(path string) bool {
+	return gOpts.imagedirs && isImageName(path) || gOpts.archivedirs && isArchiveName(path)
+}
+
+// This function splits the given path into the path of a disk image and the
+// path inside the image, which is '.' for the image itself. It returns false
+// when the path is not inside an image or archive opened as a directory.
+func imagePath(p string) (string, string, bool) {
+	if !gOpts.imagedirs && !gOpts.archivedirs {
+		return "", "", false
+	}
+
+	inner := "."
+	for {
+		stat, err := os.Stat(p)
+		if err == nil {
+			if !stat.Mode().IsRegular() || !isImageDirName(p) {
+				return "", "", false
+			}
+			return p, inner, true
+		}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", "", false
+		}
+		inner = path.Join(filepath.Base(p), inner)
+		p = parent
+	}
+}
+
+// This function reports whether the given path is a file inside a disk image.
+func inImage(path string) bool {
+	_, inner, ok := imagePath(path)
+	return ok && inner != "."
+}
+
+func imageStat(path string) (fs.FileInfo, error) {
+	image, inner, ok := imagePath(path)
+	if !ok || inner == "." {
+		return nil, fs.ErrNotExist
+	}
+
+	img, err := openImage(image)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.Stat(img, inner)
+}
+
+// This function returns information about the given file like 'os.Lstat',
+// including files inside disk images.
+func lstatPath(path string) (os.FileInfo, error) {
+	if inRemote(path) {
+		return remoteStat(path)
+	}
+
+	lstat, err := os.Lstat(path)
+	if err != nil {
+		if info, ierr := imageStat(path); ierr == nil {
+			return info, nil
+		}
+	}
+	return lstat, err
+}
+
+// This function opens the given file for reading like 'os.Open', including
+// files inside disk images.
+func openPath(path string) (io.ReadCloser, error) {
+	if c, inner, ok := remotePath(path); ok {
+		f, err := c.open(inner)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	f, err := os.Open(path)
+	if err == nil {
+		return f, nil
+	}
+
+	image, inner, ok := imagePath(path)
+	if !ok || inner == "." {
+		return nil, err
+	}
+
+	img, ierr := openImage(image)
+	if ierr != nil {
+		return nil, ierr
+	}
+
+	return img.Open(inner)
+}
+
+// This function walks the given file tree like 'filepath.Walk', including
+// trees inside disk images.
+func walkPath(root string, fn filepath.WalkFunc) error {
+	if c, inner, ok := remotePath(root); ok {
+		return walkRemote(c, root, inner, fn)
+	}
+
+	image, inner, ok := imagePath(root)
+	if !ok || inner == "." {
+		return filepath.Walk(root, fn)
+	}
+
+	img, err := openImage(image)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	return fs.WalkDir(img, inner, func(name string, d fs.DirEntry, err error) error {
+		path := filepath.Join(image, filepath.FromSlash(name))
+		if err != nil {
+			return fn(path, nil, err)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fn(path, nil, err)
+		}
+		return fn(path, info, nil)
+	})
+}
+
+// This function changes the working directory like 'os.Chdir'. Directories
+// inside disk images are checked to exist, and the working directory is
+// changed to the directory of the image instead. Remote directories are
+// handled in the same way with the local directory of the host.
+func chdir(path string) error {
+	if c, inner, ok := remotePath(path); ok {
+		info, err := c.stat(inner)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("not a directory: %s", path)
+		}
+		return os.Chdir(remoteMount(path))
+	}
+
+	image, inner, ok := imagePath(path)
+	if !ok {
+		return os.Chdir(path)
+	}
+
+	img, err := openImage(image)
+	if err != nil {
+		return err
+	}
+
+	info, err := fs.Stat(img, inner)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", path)
+	}
+
+	return os.Chdir(filepath.Dir(image))
+}
+
+// This function returns an error when any of the given paths is inside a disk
+// image or an archive, or on a remote host, since they can not be modified.
+func checkWritable(paths ...string) error {
+	for _, path := range paths {
+		if inRemote(path) {
+			return errRemoteReadOnly
+		}
+		if image, inner, ok := imagePath(path); ok && inner != "." {
+			return readOnlyError(image)
+		}
+	}
+	return nil
+}
+
+// This function returns an error when files can not be created in the given
+// directory, which includes the top directories of disk images and archives.
+func checkWritableDir(dir string) error {
+	if image, _, ok := imagePath(dir); ok {
+		return readOnlyError(image)
+	}
+	return nil
+}
+
+func readOnlyError(image string) error {
+	if isArchiveName(image) {
+		return errArchiveReadOnly
+	}
+	return errImageReadOnly
+}
+
+func newImageFile(path string, info fs.FileInfo) *file {
+	return &file{
+		FileInfo:   info,
+		linkState:  notLink,
+		path:       path,
+		dirCount:   -1,
+		dirSize:    -1,
+		accessTime: info.ModTime(),
+		birthTime:  info.ModTime(),
+		changeTime: info.ModTime(),
+		ext:        getFileExtension(info),
+	}
+}
+
+// This function reads the given directory, which is either the image itself or
+// a directory inside the image.
+func readImageFiles(dirPath, image, inner string) ([]*file, error) {
+	img, err := openImage(image)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(img, inner)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*file, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, newImageFile(filepath.Join(dirPath, e.Name()), info))
+	}
+
+	return files, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Archives (i.e. zip and tar files) are opened as virtual directories in the
+// same way as disk images with the 'archivedirs' option. The whole tree is read
+// when the archive is opened, from the central directory of zip files or by
+// reading through tar files. Entries of zip files are decompressed when they
+// are opened. Entries of tar files are found by reading the archive again from
+// the beginning, which is fast for uncompressed archives since the contents of
+// earlier entries are skipped with seeking.
+
+func isArchiveName(path string) bool {
+	_, ok := archiveBase(path)
+	return ok
+}
+
+// This type is the file system in an archive, where children of directories
+// are added while the archive is read, so they are only returned as they are.
+type archiveFS struct {
+	top   *imageNode
+	nodes map[string]*imageNode
+}
+
+func (a *archiveFS) root() *imageNode { return a.top }
+
+func (a *archiveFS) readDir(n *imageNode) ([]*imageNode, error) { return n.children, nil }
+
+func newArchiveFS(modTime time.Time) *archiveFS {
+	top := &imageNode{mode: fs.ModeDir | 0o755, modTime: modTime}
+	return &archiveFS{top: top, nodes: map[string]*imageNode{".": top}}
+}
+
+// This function returns the directory with the given cleaned name, adding it
+// and its parents when they are not listed in the archive.
+func (a *archiveFS) dir(name string) *imageNode {
+	if n, ok := a.nodes[name]; ok && n.IsDir() {
+		return n
+	}
+	n := &imageNode{name: path.Base(name), mode: fs.ModeDir | 0o755, modTime: a.top.modTime}
+	a.insert(name, n)
+	return n
+}
+
+func (a *archiveFS) insert(name string, n *imageNode) {
+	parent := a.dir(path.Dir(name))
+	if old, ok := a.nodes[name]; ok {
+		i := slices.Index(parent.children, old)
+		if i >= 0 {
+			parent.children = slices.Delete(parent.children, i, i+1)
+		}
+	}
+	parent.children = append(parent.children, n)
+	a.nodes[name] = n
+}
+
+// This function adds the given entry of the archive. Names are cleaned so
+// that entries can not be outside of the archive, and later entries replace
+// earlier ones with the same name as they do when the archive is extracted.
+func (a *archiveFS) add(name string, n *imageNode) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return
+	}
+
+	n.name = path.Base(name)
+
+	// directories may be listed after their contents
+	if old, ok := a.nodes[name]; ok && old.IsDir() && n.IsDir() {
+		old.mode, old.modTime = n.mode, n.modTime
+		return
+	}
+
+	a.insert(name, n)
+}
+
+func newZipFS(r io.ReaderAt, size int64, modTime time.Time) (imageFS, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	a := newArchiveFS(modTime)
+	for _, f := range zr.File {
+		info := f.FileInfo()
+		n := &imageNode{mode: info.Mode(), modTime: info.ModTime()}
+		if !info.IsDir() {
+			n.size = info.Size()
+			n.read = f.Open
+		}
+		a.add(f.Name, n)
+	}
+
+	return a, nil
+}
+
+// This function returns the uncompressed tar stream of the given archive,
+// which is decompressed according to the suffix of its name.
+func tarStream(r io.Reader, archive string) (io.Reader, error) {
+	switch lower := strings.ToLower(archive); {
+	case strings.HasSuffix(lower, ".gz"), strings.HasSuffix(lower, ".tgz"):
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return gr, nil
+	case strings.HasSuffix(lower, ".bz2"), strings.HasSuffix(lower, ".tbz"), strings.HasSuffix(lower, ".tbz2"):
+		return bzip2.NewReader(r), nil
+	}
+	return r, nil
+}
+
+// This function returns the contents of the tar entry at the given index.
+func openTarEntry(r io.ReaderAt, size int64, archive string, index int) (io.ReadCloser, error) {
+	s, err := tarStream(io.NewSectionReader(r, 0, size), archive)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(s)
+	for i := 0; ; i++ {
+		if _, err := tr.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if i == index {
+			return io.NopCloser(tr), nil
+		}
+	}
+}
+
+func newTarFS(r io.ReaderAt, size int64, modTime time.Time, archive string) (imageFS, error) {
+	s, err := tarStream(io.NewSectionReader(r, 0, size), archive)
+	if err != nil {
+		return nil, err
+	}
+
+	a := newArchiveFS(modTime)
+	tr := tar.NewReader(s)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		info := hdr.FileInfo()
+		n := &imageNode{mode: info.Mode(), modTime: info.ModTime()}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+		case tar.TypeReg:
+			index := i
+			n.size = hdr.Size
+			n.read = func() (io.ReadCloser, error) {
+				return openTarEntry(r, size, archive, index)
+			}
+		case tar.TypeSymlink:
+			n.size = int64(len(hdr.Linkname))
+			n.data = []byte(hdr.Linkname)
+		default:
+			// other entries such as devices and hard links are skipped
+			continue
+		}
+		a.add(hdr.Name, n)
+	}
+
+	return a, nil
+}
+
+// This function reads the file system in the given archive.
+func detectArchive(r io.ReaderAt, size int64, modTime time.Time, archive string) (imageFS, error) {
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		return newZipFS(r, size, modTime)
+	}
+	return newTarFS(r, size, modTime, archive)
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+// FAT file systems are read with long file names, where the type is decided
+// from the number of clusters as in the specification. The allocation table
+// is read to memory when it is first needed to follow cluster chains.
+
+// Partition types of FAT file systems in MBR partition tables.
+var gFATPartitionTypes = []byte{0x01, 0x04, 0x06, 0x0b, 0x0c, 0x0e}
+
+type fatFS struct {
+	r           io.ReaderAt
+	off         int64
+	bits        int
+	clusterSize int64
+	dataStart   int64
+	fatStart    int64
+	fatSize     int64
+	clusters    uint32
+	rootNode    *imageNode
+	once        sync.Once
+	table       []byte
+	tableErr    error
+}
+
+// This function returns the offset of the first FAT partition in the MBR
+// partition table of the given image.
+func mbrFATPartition(r io.ReaderAt) (int64, bool) {
+	b, err := readSector(r, 0, 512)
+	if err != nil || b[510] != 0x55 || b[511] != 0xaa {
+		return 0, false
+	}
+
+	for i := range 4 {
+		entry := b[446+16*i:]
+		for _, t := range gFATPartitionTypes {
+			if entry[4] == t {
+				return int64(binary.LittleEndian.Uint32(entry[8:])) * 512, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func newFAT(r io.ReaderAt, off, size int64) (*fatFS, error) {
+	b, err := readSector(r, off, 512)
+	if err != nil {
+		return nil, err
+	}
+
+	bps := int64(binary.LittleEndian.Uint16(b[11:]))
+	spc := int64(b[13])
+	reserved := int64(binary.LittleEndian.Uint16(b[14:]))
+	numFATs := int64(b[16])
+	rootEntries := int64(binary.LittleEndian.Uint16(b[17:]))
+
+	if (b[0] != 0xeb && b[0] != 0xe9) || b[510] != 0x55 || b[511] != 0xaa ||
+		(bps != 512 && bps != 1024 && bps != 2048 && bps != 4096) ||
+		spc == 0 || spc&(spc-1) != 0 || reserved == 0 || numFATs == 0 {
+		return nil, errors.New("invalid boot sector")
+	}
+
+	fatSize := int64(binary.LittleEndian.Uint16(b[22:]))
+	if fatSize == 0 {
+		fatSize = int64(binary.LittleEndian.Uint32(b[36:]))
+	}
+	total := int64(binary.LittleEndian.Uint16(b[19:]))
+	if total == 0 {
+		total = int64(binary.LittleEndian.Uint32(b[32:]))
+	}
+
+	rootSectors := (rootEntries*32 + bps - 1) / bps
+	dataSector := reserved + numFATs*fatSize + rootSectors
+	if fatSize == 0 || total <= dataSector || total*bps > size {
+		return nil, errors.New("invalid boot sector")
+	}
+
+	fsys := &fatFS{
+		r:           r,
+		off:         off,
+		clusterSize: bps * spc,
+		dataStart:   off + dataSector*bps,
+		fatStart:    off + reserved*bps,
+		fatSize:     fatSize * bps,
+		clusters:    uint32((total - dataSector) / spc),
+	}
+
+	switch {
+	case fsys.clusters < 4085:
+		fsys.bits = 12
+	case fsys.clusters < 65525:
+		fsys.bits = 16
+	default:
+		fsys.bits = 32
+	}
+
+	root := &imageNode{name: ".", mode: fs.ModeDir | 0o755, modTime: time.Unix(0, 0)}
+	if fsys.bits == 32 {
+		if root.extents, err = fsys.chain(binary.LittleEndian.Uint32(b[44:]), -1); err != nil {
+			return nil, err
+		}
+		for _, e := range root.extents {
+			root.size += e.size
+		}
+	} else {
+		root.size = rootSectors * bps
+		root.extents = []imageExtent{{off + (reserved+numFATs*fatSize)*bps, root.size}}
+	}
+	fsys.rootNode = root
+
+	return fsys, nil
+}
+
+func (fsys *fatFS) next(cluster uint32) (uint32, error) {
+	fsys.once.Do(func() {
+		fsys.table, fsys.tableErr = readSector(fsys.r, fsys.fatStart, int(fsys.fatSize))
+	})
+	if fsys.tableErr != nil {
+		return 0, fsys.tableErr
+	}
+
+	off, size := 4*int64(cluster), int64(4)
+	switch fsys.bits {
+	case 12:
+		off, size = int64(cluster)+int64(cluster)/2, 2
+	case 16:
+		off, size = 2*int64(cluster), 2
+	}
+	if off+size > int64(len(fsys.table)) {
+		return 0, errors.New("invalid cluster")
+	}
+
+	switch fsys.bits {
+	case 12:
+		v := uint32(binary.LittleEndian.Uint16(fsys.table[off:]))
+		if cluster%2 == 1 {
+			return v >> 4, nil
+		}
+		return v & 0xfff, nil
+	case 16:
+		return uint32(binary.LittleEndian.Uint16(fsys.table[off:])), nil
+	}
+	return binary.LittleEndian.Uint32(fsys.table[off:]) & 0x0fffffff, nil
+}
+
+// This function returns the extents of the cluster chain starting from the
+// given cluster, which are limited to the given size unless it is negative.
+func (fsys *fatFS) chain(cluster uint32, size int64) ([]imageExtent, error) {
+	end := uint32(0xff8)
+	switch fsys.bits {
+	case 16:
+		end = 0xfff8
+	case 32:
+		end = 0x0ffffff8
+	}
+
+	var extents []imageExtent
+	var total int64
+	for i := uint32(0); cluster >= 2 && cluster < end; i++ {
+		if cluster-2 >= fsys.clusters || i > fsys.clusters {
+			return nil, errors.New("invalid cluster chain")
+		}
+
+		off := fsys.dataStart + int64(cluster-2)*fsys.clusterSize
+		if n := len(extents); n > 0 && extents[n-1].off+extents[n-1].size == off {
+			extents[n-1].size += fsys.clusterSize
+		} else {
+			extents = append(extents, imageExtent{off, fsys.clusterSize})
+		}
+
+		total += fsys.clusterSize
+		if size >= 0 && total >= size {
+			break
+		}
+
+		next, err := fsys.next(cluster)
+		if err != nil {
+			return nil, err
+		}
+		cluster = next
+	}
+
+	return extents, nil
+}
+
+// This function returns the time in a directory entry, which is in local
+// time in the MS-DOS format with two second resolution.
+func fatTime(date, tm uint16) time.Time {
+	if date == 0 {
+		return time.Unix(0, 0)
+	}
+	return time.Date(1980+int(date>>9), time.Month(date>>5&0xf), int(date&0x1f),
+		int(tm>>11), int(tm>>5&0x3f), int(tm&0x1f)*2, 0, time.Local)
+}
+
+// This function returns the short name in a directory entry, where the base
+// name and the extension can be stored in lowercase with flags.
+func fatShortName(b []byte) string {
+	raw := slices.Clone(b[:11])
+	if raw[0] == 0x05 {
+		raw[0] = 0xe5
+	}
+
+	decode := func(b []byte, lower bool) string {
+		r := make([]rune, 0, len(b))
+		for _, c := range b {
+			r = append(r, rune(c))
+		}
+		s := strings.TrimRight(string(r), " ")
+		if lower {
+			s = strings.ToLower(s)
+		}
+		return s
+	}
+
+	name := decode(raw[:8], b[12]&0x08 != 0)
+	if ext := decode(raw[8:], b[12]&0x10 != 0); ext != "" {
+		name += "." + ext
+	}
+	return name
+}
+
+func fatChecksum(b []byte) byte {
+	var sum byte
+	for _, c := range b[:11] {
+		sum = (sum&1)<<7 + sum>>1 + c
+	}
+	return sum
+}
+
+func (fsys *fatFS) readDir(dir *imageNode) ([]*imageNode, error) {
+	b, err := readImageDir(fsys.r, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*imageNode
+	var long []uint16
+	var longSum byte
+	for pos := 0; pos+32 <= len(b); pos += 32 {
+		entry := b[pos : pos+32]
+		attr := entry[11]
+
+		switch {
+		case entry[0] == 0:
+			return nodes, nil
+		case entry[0] == 0xe5:
+			long = nil
+			continue
+		case attr&0x3f == 0x0f:
+			// long names are stored in reverse order before the short entry
+			seq := int(entry[0] & 0x1f)
+			if entry[0]&0x40 != 0 {
+				long = make([]uint16, 13*seq)
+				longSum = entry[13]
+			}
+			if seq == 0 || 13*seq > len(long) || entry[13] != longSum {
+				long = nil
+				continue
+			}
+			chars := long[13*(seq-1):]
+			for i, off := range []int{1, 3, 5, 7, 9, 14, 16, 18, 20, 22, 24, 28, 30} {
+				chars[i] = binary.LittleEndian.Uint16(entry[off:])
+			}
+			continue
+		case attr&0x08 != 0:
+			long = nil
+			continue
+		}
+
+		name := fatShortName(entry)
+		if long != nil && fatChecksum(entry) == longSum {
+			// names are terminated with zero and padded with 0xffff
+			if i := slices.Index(long, 0); i >= 0 {
+				long = long[:i]
+			}
+			name = string(utf16.Decode(long))
+		}
+		long = nil
+
+		if name == "." || name == ".." {
+			continue
+		}
+
+		n := &imageNode{
+			name:    name,
+			size:    int64(binary.LittleEndian.Uint32(entry[28:])),
+			modTime: fatTime(binary.LittleEndian.Uint16(entry[24:]), binary.LittleEndian.Uint16(entry[22:])),
+			mode:    0o644,
+		}
+		if attr&0x01 != 0 {
+			n.mode = 0o444
+		}
+
+		cluster := uint32(binary.LittleEndian.Uint16(entry[20:]))<<16 | uint32(binary.LittleEndian.Uint16(entry[26:]))
+		if fsys.bits != 32 {
+			cluster &= 0xffff
+		}
+
+		if attr&0x10 != 0 {
+			n.mode = fs.ModeDir | 0o755
+			if n.extents, err = fsys.chain(cluster, -1); err != nil {
+				return nodes, err
+			}
+			n.size = 0
+			for _, e := range n.extents {
+				n.size += e.size
+			}
+		} else if n.extents, err = fsys.chain(cluster, n.size); err != nil {
+			return nodes, err
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	return nodes, nil
+}
+
+func (fsys *fatFS) root() *imageNode {
+	return fsys.rootNode
+}
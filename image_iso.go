@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ISO9660 file systems are read from the primary volume descriptor with Rock
+// Ridge names when they are recorded, or otherwise from the Joliet volume
+// descriptor when there is one. Continuation areas of Rock Ridge entries and
+// relocated directories are not supported.
+
+const isoSectorSize = 2048
+
+// Volume descriptors start after the system area of 16 sectors.
+const isoSystemArea = 16 * isoSectorSize
+
+type isoFS struct {
+	r         io.ReaderAt
+	rootNode  *imageNode
+	joliet    bool
+	rockRidge bool
+}
+
+func readSector(r io.ReaderAt, off int64, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := r.ReadAt(b, off); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func hasISO(r io.ReaderAt) bool {
+	b, err := readSector(r, isoSystemArea, 6)
+	return err == nil && string(b[1:6]) == "CD001"
+}
+
+// This function returns the time in a directory record, which is in years
+// since 1900 with an offset from GMT in 15 minute intervals.
+func isoTime(b []byte) time.Time {
+	if b[0] == 0 && b[1] == 0 && b[2] == 0 {
+		return time.Unix(0, 0)
+	}
+	loc := time.FixedZone("", int(int8(b[6]))*15*60)
+	return time.Date(1900+int(b[0]), time.Month(b[1]), int(b[2]), int(b[3]), int(b[4]), int(b[5]), 0, loc)
+}
+
+func decodeUCS2(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// This function returns the name of a file in an ISO9660 directory without
+// the version number (e.g. ';1') and the dot of names without an extension.
+func isoName(b []byte, joliet bool) string {
+	var name string
+	if joliet {
+		name = decodeUCS2(b)
+	} else {
+		name = string(b)
+	}
+	if i := strings.LastIndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	if !joliet {
+		name = strings.TrimSuffix(name, ".")
+	}
+	return name
+}
+
+// This function returns the Rock Ridge name and mode in the given system use
+// area of a directory record, which are empty when they are not recorded.
+func rockRidge(b []byte) (name string, mode fs.FileMode, hasMode bool) {
+	var nm []byte
+	for len(b) >= 4 {
+		sig, size := string(b[:2]), int(b[2])
+		if size < 4 || size > len(b) {
+			break
+		}
+		data := b[4:size]
+		switch sig {
+		case "NM":
+			// current and parent directory flags are ignored
+			if len(data) > 0 && data[0]&0x06 == 0 {
+				nm = append(nm, data[1:]...)
+			}
+		case "PX":
+			if len(data) >= 8 {
+				m := binary.LittleEndian.Uint32(data)
+				mode, hasMode = fs.FileMode(m&0o777), true
+			}
+		case "ST":
+			return string(nm), mode, hasMode
+		}
+		b = b[size:]
+	}
+	return string(nm), mode, hasMode
+}
+
+func validRecord(b []byte) bool {
+	return len(b) >= 34 && int(b[0]) <= len(b) && int(b[0]) >= 33+int(b[32])
+}
+
+// This function reports whether the given record is for the current or the
+// parent directory, whose names are a single zero or one byte.
+func isDotRecord(b []byte) bool {
+	return b[32] == 1 && (b[33] == 0 || b[33] == 1)
+}
+
+// This function parses the directory record at the start of the given bytes,
+// and returns whether the record is continued in the next record for files
+// with multiple extents.
+func (fsys *isoFS) parseRecord(b []byte) (*imageNode, bool, error) {
+	if !validRecord(b) {
+		return nil, false, errors.New("invalid directory record")
+	}
+
+	nameLen := int(b[32])
+	rawName := b[33 : 33+nameLen]
+	flags := b[25]
+	n := &imageNode{
+		name:    isoName(rawName, fsys.joliet),
+		size:    int64(binary.LittleEndian.Uint32(b[10:])),
+		modTime: isoTime(b[18:25]),
+	}
+	n.extents = []imageExtent{{int64(binary.LittleEndian.Uint32(b[2:])) * isoSectorSize, n.size}}
+
+	mode := fs.FileMode(0o444)
+	if flags&0x02 != 0 {
+		mode = fs.ModeDir | 0o555
+	}
+
+	if fsys.rockRidge {
+		// system use area starts after padding for even name lengths
+		su := 33 + nameLen + (1 - nameLen%2)
+		if su < int(b[0]) {
+			name, m, ok := rockRidge(b[su:b[0]])
+			if name != "" {
+				n.name = name
+			}
+			if ok {
+				mode = mode&fs.ModeDir | m
+			}
+		}
+	}
+	n.mode = mode
+
+	return n, flags&0x80 != 0, nil
+}
+
+func (fsys *isoFS) readDir(dir *imageNode) ([]*imageNode, error) {
+	b, err := readImageDir(fsys.r, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*imageNode
+	var more bool
+	for pos := 0; pos < len(b); {
+		// records do not cross sector boundaries and the rest is zero
+		if b[pos] == 0 {
+			pos = (pos/isoSectorSize + 1) * isoSectorSize
+			continue
+		}
+
+		if !validRecord(b[pos:]) {
+			return nodes, errors.New("invalid directory record")
+		}
+		if isDotRecord(b[pos:]) {
+			pos += int(b[pos])
+			continue
+		}
+
+		n, next, err := fsys.parseRecord(b[pos:])
+		if err != nil {
+			return nodes, err
+		}
+		pos += int(b[pos])
+
+		// extents of large files are recorded in consecutive records
+		if more && len(nodes) > 0 && nodes[len(nodes)-1].name == n.name {
+			prev := nodes[len(nodes)-1]
+			prev.extents = append(prev.extents, n.extents...)
+			prev.size += n.size
+		} else {
+			nodes = append(nodes, n)
+		}
+		more = next
+	}
+
+	return nodes, nil
+}
+
+func (fsys *isoFS) root() *imageNode {
+	return fsys.rootNode
+}
+
+func newISO(r io.ReaderAt) (*isoFS, error) {
+	var primary, joliet []byte
+	for i := int64(0); i < 64; i++ {
+		b, err := readSector(r, isoSystemArea+i*isoSectorSize, isoSectorSize)
+		if err != nil {
+			return nil, err
+		}
+		if string(b[1:6]) != "CD001" {
+			return nil, errors.New("invalid volume descriptor")
+		}
+
+		switch b[0] {
+		case 1:
+			primary = b
+		case 2:
+			if esc := string(b[88:91]); esc == "%/@" || esc == "%/C" || esc == "%/E" {
+				joliet = b
+			}
+		}
+		if b[0] == 255 {
+			break
+		}
+	}
+
+	if primary == nil {
+		return nil, errors.New("primary volume descriptor not found")
+	}
+
+	fsys := &isoFS{r: r}
+	root, _, err := fsys.parseRecord(primary[156:190])
+	if err != nil {
+		return nil, fmt.Errorf("root directory: %s", err)
+	}
+
+	// Rock Ridge is detected from the 'SP' entry of the first record of the
+	// root directory, which should be preferred to Joliet names
+	if b, err := readSector(r, root.extents[0].off, 40); err == nil && int(b[0]) >= 34 {
+		su := 34
+		if su+2 <= int(b[0]) && string(b[su:su+2]) == "SP" {
+			fsys.rockRidge = true
+		}
+	}
+
+	if !fsys.rockRidge && joliet != nil {
+		fsys.joliet = true
+		if root, _, err = fsys.parseRecord(joliet[156:190]); err != nil {
+			return nil, fmt.Errorf("root directory: %s", err)
+		}
+	}
+
+	root.name = "."
+	root.mode = fs.ModeDir | 0o555
+	fsys.rootNode = root
+
+	return fsys, nil
+}
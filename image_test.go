@@ -0,0 +1,571 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+// This function returns an ISO9660 directory record, where the name is padded
+// to an even length and the record length is even.
+func isoRecord(name string, extent, size uint32, flags byte, su []byte) []byte {
+	n := len(name)
+	length := 33 + n + (1 - n%2) + len(su)
+	length += length % 2
+
+	b := make([]byte, length)
+	b[0] = byte(length)
+	binary.LittleEndian.PutUint32(b[2:], extent)
+	binary.BigEndian.PutUint32(b[6:], extent)
+	binary.LittleEndian.PutUint32(b[10:], size)
+	binary.BigEndian.PutUint32(b[14:], size)
+	copy(b[18:], []byte{124, 5, 17, 10, 30, 0, 0})
+	b[25] = flags
+	b[32] = byte(n)
+	copy(b[33:], name)
+	copy(b[33+n+(1-n%2):], su)
+	return b
+}
+
+func rrName(name string) []byte {
+	return append([]byte{'N', 'M', byte(5 + len(name)), 1, 0}, name...)
+}
+
+func buildISO(rockRidge bool) []byte {
+	img := make([]byte, 24*isoSectorSize)
+	sector := func(i int) []byte { return img[i*isoSectorSize : (i+1)*isoSectorSize] }
+
+	var rootSU, dirSU, helloSU, nestedSU []byte
+	if rockRidge {
+		rootSU = []byte{'S', 'P', 7, 1, 0xbe, 0xef, 0}
+		dirSU, helloSU, nestedSU = rrName("dir"), rrName("hello.txt"), rrName("nested file.txt")
+	}
+
+	pvd := sector(16)
+	pvd[0] = 1
+	copy(pvd[1:], "CD001")
+	copy(pvd[156:], isoRecord("\x00", 18, isoSectorSize, 0x02, nil))
+
+	term := sector(17)
+	term[0] = 255
+	copy(term[1:], "CD001")
+
+	var root []byte
+	root = append(root, isoRecord("\x00", 18, isoSectorSize, 0x02, rootSU)...)
+	root = append(root, isoRecord("\x01", 18, isoSectorSize, 0x02, nil)...)
+	root = append(root, isoRecord("DIR", 19, isoSectorSize, 0x02, dirSU)...)
+	root = append(root, isoRecord("HELLO.TXT;1", 20, 6, 0, helloSU)...)
+	copy(sector(18), root)
+
+	var dir []byte
+	dir = append(dir, isoRecord("\x00", 19, isoSectorSize, 0x02, nil)...)
+	dir = append(dir, isoRecord("\x01", 18, isoSectorSize, 0x02, nil)...)
+	dir = append(dir, isoRecord("NESTED.TXT;1", 21, 7, 0, nestedSU)...)
+	copy(sector(19), dir)
+
+	copy(sector(20), "hello\n")
+	copy(sector(21), "nested\n")
+
+	return img
+}
+
+func buildUDF() []byte {
+	const partStart = 40
+	img := make([]byte, 257*isoSectorSize)
+	sector := func(i int) []byte { return img[i*isoSectorSize : (i+1)*isoSectorSize] }
+	block := func(i int) []byte { return sector(partStart + i) }
+	tag := func(b []byte, id uint16) { binary.LittleEndian.PutUint16(b, id) }
+
+	for i, id := range []string{"BEA01", "NSR02", "TEA01"} {
+		copy(sector(16 + i)[1:], id)
+	}
+
+	anchor := sector(256)
+	tag(anchor, udfTagAnchor)
+	binary.LittleEndian.PutUint32(anchor[16:], 3*isoSectorSize)
+	binary.LittleEndian.PutUint32(anchor[20:], 32)
+
+	pd := sector(32)
+	tag(pd, udfTagPartition)
+	binary.LittleEndian.PutUint32(pd[188:], partStart)
+
+	lvd := sector(33)
+	tag(lvd, udfTagLogicalVolume)
+	binary.LittleEndian.PutUint32(lvd[212:], isoSectorSize)
+	binary.LittleEndian.PutUint32(lvd[252:], 0)
+	binary.LittleEndian.PutUint32(lvd[268:], 1)
+	lvd[440], lvd[441] = 1, 6
+
+	tag(sector(34), udfTagTerminating)
+
+	fsd := block(0)
+	tag(fsd, udfTagFileSet)
+	binary.LittleEndian.PutUint32(fsd[404:], 1)
+
+	fileEntry := func(b []byte, fileType byte, adType uint16, size int, ads []byte) {
+		tag(b, udfTagFileEntry)
+		b[27] = fileType
+		binary.LittleEndian.PutUint16(b[34:], adType)
+		// read and execute permissions for everyone
+		binary.LittleEndian.PutUint32(b[44:], 0x5|0x5<<5|0x5<<10)
+		binary.LittleEndian.PutUint64(b[56:], uint64(size))
+		binary.LittleEndian.PutUint32(b[172:], uint32(len(ads)))
+		copy(b[176:], ads)
+	}
+	shortAD := func(size, pos uint32) []byte {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint32(b, size)
+		binary.LittleEndian.PutUint32(b[4:], pos)
+		return b
+	}
+	fid := func(chars byte, icb uint32, name string) []byte {
+		var id []byte
+		if name != "" {
+			id = append([]byte{8}, name...)
+		}
+		b := make([]byte, (38+len(id)+3)&^3)
+		tag(b, udfTagFileID)
+		b[18] = chars
+		b[19] = byte(len(id))
+		binary.LittleEndian.PutUint32(b[24:], icb)
+		copy(b[38:], id)
+		return b
+	}
+
+	var dir []byte
+	dir = append(dir, fid(0x0a, 1, "")...)
+	dir = append(dir, fid(0, 3, "hello.txt")...)
+	dir = append(dir, fid(0, 4, "tiny.txt")...)
+	dir = append(dir, fid(0x04, 3, "deleted.txt")...)
+
+	fileEntry(block(1), 4, 0, len(dir), shortAD(uint32(len(dir)), 2))
+	copy(block(2), dir)
+	fileEntry(block(3), 5, 0, 6, shortAD(6, 5))
+	fileEntry(block(4), 5, 3, 5, []byte("tiny\n"))
+	copy(block(5), "hello\n")
+
+	return img
+}
+
+func buildFAT() []byte {
+	const bps = 512
+	img := make([]byte, 64*bps)
+	sector := func(i int) []byte { return img[i*bps : (i+1)*bps] }
+
+	boot := sector(0)
+	boot[0] = 0xeb
+	binary.LittleEndian.PutUint16(boot[11:], bps)
+	boot[13] = 1
+	binary.LittleEndian.PutUint16(boot[14:], 1)
+	boot[16] = 2
+	binary.LittleEndian.PutUint16(boot[17:], 16)
+	binary.LittleEndian.PutUint16(boot[19:], 64)
+	binary.LittleEndian.PutUint16(boot[22:], 1)
+	boot[510], boot[511] = 0x55, 0xaa
+
+	fat := sector(1)
+	set := func(c int, v uint16) {
+		off := c + c/2
+		if c%2 == 0 {
+			fat[off] = byte(v)
+			fat[off+1] = fat[off+1]&0xf0 | byte(v>>8)&0x0f
+		} else {
+			fat[off] = fat[off]&0x0f | byte(v<<4)
+			fat[off+1] = byte(v >> 4)
+		}
+	}
+	set(2, 3)
+	set(3, 0xfff)
+	set(4, 0xfff)
+	set(5, 0xfff)
+	set(6, 0xfff)
+	copy(sector(2), fat)
+
+	entry := func(name string, attr, lower byte, cluster uint16, size uint32) []byte {
+		b := make([]byte, 32)
+		copy(b, name)
+		b[11] = attr
+		b[12] = lower
+		binary.LittleEndian.PutUint16(b[22:], 10<<11|30<<5)
+		binary.LittleEndian.PutUint16(b[24:], 44<<9|5<<5|17)
+		binary.LittleEndian.PutUint16(b[26:], cluster)
+		binary.LittleEndian.PutUint32(b[28:], size)
+		return b
+	}
+	longEntries := func(name string, short []byte) []byte {
+		u := utf16.Encode([]rune(name))
+		u = append(u, 0)
+		for len(u)%13 != 0 {
+			u = append(u, 0xffff)
+		}
+		sum := fatChecksum(short)
+		count := len(u) / 13
+		var out []byte
+		for seq := count; seq >= 1; seq-- {
+			b := make([]byte, 32)
+			b[0] = byte(seq)
+			if seq == count {
+				b[0] |= 0x40
+			}
+			b[11] = 0x0f
+			b[13] = sum
+			chars := u[13*(seq-1):]
+			for i, off := range []int{1, 3, 5, 7, 9, 14, 16, 18, 20, 22, 24, 28, 30} {
+				binary.LittleEndian.PutUint16(b[off:], chars[i])
+			}
+			out = append(out, b...)
+		}
+		return out
+	}
+
+	long := entry("ALONGN~1TXT", 0, 0, 2, 700)
+
+	var root []byte
+	root = append(root, entry("DISK       ", 0x08, 0, 0, 0)...)
+	root = append(root, entry("README  TXT", 0x01, 0x18, 6, 7)...)
+	root = append(root, longEntries("a long name.txt", long)...)
+	root = append(root, long...)
+	root = append(root, entry("SUB        ", 0x10, 0, 4, 0)...)
+	deleted := entry("GONE    TXT", 0, 0, 0, 0)
+	deleted[0] = 0xe5
+	root = append(root, deleted...)
+	copy(sector(3), root)
+
+	var sub []byte
+	sub = append(sub, entry(".          ", 0x10, 0, 4, 0)...)
+	sub = append(sub, entry("..         ", 0x10, 0, 0, 0)...)
+	sub = append(sub, entry("INNER   TXT", 0, 0, 5, 6)...)
+	copy(sector(6), sub)
+
+	data := bytes.Repeat([]byte("x"), 700)
+	copy(sector(4), data[:bps])
+	copy(sector(5), data[bps:])
+	copy(sector(7), "inner\n")
+	copy(sector(8), "readme\n")
+
+	return img
+}
+
+func readImageFile(t *testing.T, fsys fs.FS, name string) string {
+	t.Helper()
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		t.Fatalf("reading '%s': %s", name, err)
+	}
+	return string(b)
+}
+
+func imageNames(t *testing.T, fsys fs.FS, name string) []string {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		t.Fatalf("reading directory '%s': %s", name, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func openTestImage(t *testing.T, b []byte) *diskImage {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	img, err := openImage(path)
+	if err != nil {
+		t.Fatalf("opening image: %s", err)
+	}
+	return img
+}
+
+func TestImageISO(t *testing.T) {
+	img := openTestImage(t, buildISO(false))
+	if _, ok := img.fsys.(*isoFS); !ok {
+		t.Fatalf("expected ISO9660 file system but got '%T'", img.fsys)
+	}
+
+	if got, exp := imageNames(t, img, "."), []string{"DIR", "HELLO.TXT"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected '%v' but got '%v'", exp, got)
+	}
+	if got := readImageFile(t, img, "HELLO.TXT"); got != "hello\n" {
+		t.Errorf("expected 'hello\\n' but got '%s'", got)
+	}
+	if got := readImageFile(t, img, "DIR/NESTED.TXT"); got != "nested\n" {
+		t.Errorf("expected 'nested\\n' but got '%s'", got)
+	}
+
+	info, err := fs.Stat(img, "HELLO.TXT")
+	if err != nil || info.Mode() != 0o444 || info.ModTime().Year() != 2024 {
+		t.Errorf("unexpected file information '%v' (%v)", info, err)
+	}
+}
+
+func TestImageRockRidge(t *testing.T) {
+	img := openTestImage(t, buildISO(true))
+
+	if got, exp := imageNames(t, img, "."), []string{"dir", "hello.txt"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected '%v' but got '%v'", exp, got)
+	}
+	if got := readImageFile(t, img, "dir/nested file.txt"); got != "nested\n" {
+		t.Errorf("expected 'nested\\n' but got '%s'", got)
+	}
+}
+
+func TestImageUDF(t *testing.T) {
+	img := openTestImage(t, buildUDF())
+	if _, ok := img.fsys.(*udfFS); !ok {
+		t.Fatalf("expected UDF file system but got '%T'", img.fsys)
+	}
+
+	if got, exp := imageNames(t, img, "."), []string{"hello.txt", "tiny.txt"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected '%v' but got '%v'", exp, got)
+	}
+	if got := readImageFile(t, img, "hello.txt"); got != "hello\n" {
+		t.Errorf("expected 'hello\\n' but got '%s'", got)
+	}
+	if got := readImageFile(t, img, "tiny.txt"); got != "tiny\n" {
+		t.Errorf("expected 'tiny\\n' but got '%s'", got)
+	}
+
+	if info, err := fs.Stat(img, "hello.txt"); err != nil || info.Mode() != 0o555 {
+		t.Errorf("unexpected file information '%v' (%v)", info, err)
+	}
+}
+
+func TestImageFAT(t *testing.T) {
+	img := openTestImage(t, buildFAT())
+	fat, ok := img.fsys.(*fatFS)
+	if !ok || fat.bits != 12 {
+		t.Fatalf("expected FAT12 file system but got '%T'", img.fsys)
+	}
+
+	if got, exp := imageNames(t, img, "."), []string{"SUB", "a long name.txt", "readme.txt"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected '%v' but got '%v'", exp, got)
+	}
+	if got := readImageFile(t, img, "a long name.txt"); got != string(bytes.Repeat([]byte("x"), 700)) {
+		t.Errorf("expected file spanning two clusters but got %d bytes", len(got))
+	}
+	if got := readImageFile(t, img, "SUB/INNER.TXT"); got != "inner\n" {
+		t.Errorf("expected 'inner\\n' but got '%s'", got)
+	}
+
+	info, err := fs.Stat(img, "readme.txt")
+	if err != nil || info.Mode() != 0o444 || info.ModTime().Year() != 2024 {
+		t.Errorf("unexpected file information '%v' (%v)", info, err)
+	}
+}
+
+func TestImageMBR(t *testing.T) {
+	fat := buildFAT()
+	img := make([]byte, 2048+len(fat))
+	img[446+4] = 0x01
+	binary.LittleEndian.PutUint32(img[446+8:], 4)
+	img[510], img[511] = 0x55, 0xaa
+	copy(img[2048:], fat)
+
+	if got, exp := imageNames(t, openTestImage(t, img), "SUB"), []string{"INNER.TXT"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected '%v' but got '%v'", exp, got)
+	}
+}
+
+func TestImagePaths(t *testing.T) {
+	old := gOpts.imagedirs
+	defer func() { gOpts.imagedirs = old }()
+
+	dir := t.TempDir()
+	image := filepath.Join(dir, "disk.iso")
+	if err := os.WriteFile(image, buildISO(true), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	gOpts.imagedirs = false
+	if _, _, ok := imagePath(filepath.Join(image, "dir")); ok {
+		t.Errorf("expected images to be ignored without 'imagedirs'")
+	}
+
+	gOpts.imagedirs = true
+
+	tests := []struct {
+		path  string
+		inner string
+		ok    bool
+	}{
+		{image, ".", true},
+		{filepath.Join(image, "dir"), "dir", true},
+		{filepath.Join(image, "dir", "nested file.txt"), "dir/nested file.txt", true},
+		{dir, "", false},
+		{filepath.Join(dir, "missing"), "", false},
+	}
+
+	for _, test := range tests {
+		if _, inner, ok := imagePath(test.path); inner != test.inner || ok != test.ok {
+			t.Errorf("at input '%s' expected '%s' and '%t' but got '%s' and '%t'", test.path, test.inner, test.ok, inner, ok)
+		}
+	}
+
+	files, err := readdir(image)
+	if err != nil || len(files) != 2 || files[0].path != filepath.Join(image, "dir") || !files[0].IsDir() {
+		t.Fatalf("unexpected files in image '%v' (%v)", files, err)
+	}
+
+	nested := filepath.Join(image, "dir", "nested file.txt")
+	if f := newFile(nested); f.err != nil || f.Size() != 7 {
+		t.Errorf("unexpected file information '%v' (%v)", f, f.err)
+	}
+
+	r, err := openPath(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(r)
+	r.Close()
+	if string(b) != "nested\n" {
+		t.Errorf("expected 'nested\\n' but got '%s'", b)
+	}
+
+	if err := checkWritable(nested); err != errImageReadOnly {
+		t.Errorf("expected read-only error but got '%v'", err)
+	}
+	if err := checkWritable(image, dir); err != nil {
+		t.Errorf("expected image file to be writable but got '%v'", err)
+	}
+
+	// files are copied out of images
+	dst := t.TempDir()
+	nums, errs := copyAll([]string{filepath.Join(image, "dir")}, dst, []string{"mode", "timestamps"}, nil)
+	go func() {
+		for range nums {
+		}
+	}()
+	for err := range errs {
+		t.Errorf("copy: %s", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "dir", "nested file.txt")); err != nil || string(b) != "nested\n" {
+		t.Errorf("expected copied file but got '%s' (%v)", b, err)
+	}
+}
+
+func buildZip(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, e := range []struct{ name, body string }{
+		{"hello.txt", "hello\n"},
+		{"dir/nested.txt", "nested\n"},
+		{"dir/", ""},
+		{"../outside.txt", "outside\n"},
+	} {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, e.body)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func buildTarGz(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	for _, e := range []struct {
+		hdr  tar.Header
+		body string
+	}{
+		{tar.Header{Name: "./dir/nested.txt", Mode: 0o600, Typeflag: tar.TypeReg}, "nested\n"},
+		{tar.Header{Name: "hello.txt", Mode: 0o644, Typeflag: tar.TypeReg}, "old\n"},
+		{tar.Header{Name: "hello.txt", Mode: 0o644, Typeflag: tar.TypeReg}, "hello\n"},
+		{tar.Header{Name: "link", Linkname: "hello.txt", Typeflag: tar.TypeSymlink}, ""},
+		{tar.Header{Name: "fifo", Typeflag: tar.TypeFifo}, ""},
+	} {
+		e.hdr.Size = int64(len(e.body))
+		if err := tw.WriteHeader(&e.hdr); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, e.body)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestImageArchive(t *testing.T) {
+	old := gOpts.archivedirs
+	defer func() { gOpts.archivedirs = old }()
+
+	dir := t.TempDir()
+
+	tests := []struct {
+		name  string
+		data  []byte
+		names []string
+	}{
+		{"test.zip", buildZip(t), []string{"dir", "hello.txt", "outside.txt"}},
+		{"test.tar.gz", buildTarGz(t), []string{"dir", "hello.txt", "link"}},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		if err := os.WriteFile(path, test.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := openImage(path)
+		if err != nil {
+			t.Fatalf("opening '%s': %s", test.name, err)
+		}
+		if _, ok := img.fsys.(*archiveFS); !ok {
+			t.Fatalf("expected archive file system but got '%T'", img.fsys)
+		}
+
+		if got := imageNames(t, img, "."); !reflect.DeepEqual(got, test.names) {
+			t.Errorf("at archive '%s' expected '%v' but got '%v'", test.name, test.names, got)
+		}
+		if got := readImageFile(t, img, "hello.txt"); got != "hello\n" {
+			t.Errorf("at archive '%s' expected 'hello\\n' but got '%s'", test.name, got)
+		}
+		if got := readImageFile(t, img, "dir/nested.txt"); got != "nested\n" {
+			t.Errorf("at archive '%s' expected 'nested\\n' but got '%s'", test.name, got)
+		}
+		if info, err := fs.Stat(img, "dir"); err != nil || !info.IsDir() {
+			t.Errorf("at archive '%s' unexpected directory information '%v' (%v)", test.name, info, err)
+		}
+
+		gOpts.archivedirs = false
+		if _, _, ok := imagePath(filepath.Join(path, "dir")); ok {
+			t.Errorf("expected archives to be ignored without 'archivedirs'")
+		}
+
+		gOpts.archivedirs = true
+		nested := filepath.Join(path, "dir", "nested.txt")
+		if _, inner, ok := imagePath(nested); !ok || inner != "dir/nested.txt" {
+			t.Errorf("at input '%s' expected 'dir/nested.txt' but got '%s'", nested, inner)
+		}
+		if err := checkWritable(nested); err != errArchiveReadOnly {
+			t.Errorf("expected read-only error but got '%v'", err)
+		}
+		if err := checkWritableDir(path); err != errArchiveReadOnly {
+			t.Errorf("expected read-only error but got '%v'", err)
+		}
+	}
+}
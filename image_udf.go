@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+	"unicode/utf16"
+)
+
+// UDF file systems are read with a single type 1 partition map, which is the
+// case for DVD and most installation images. Metadata partitions of UDF 2.50
+// and later (e.g. Blu-ray images) are not supported, and such images are read
+// from their ISO9660 file system when there is one.
+
+// Tag identifiers of UDF descriptors.
+const (
+	udfTagAnchor        = 2
+	udfTagPartition     = 5
+	udfTagLogicalVolume = 6
+	udfTagTerminating   = 8
+	udfTagFileSet       = 256
+	udfTagFileID        = 257
+	udfTagAllocExtent   = 258
+	udfTagFileEntry     = 261
+	udfTagExtFileEntry  = 266
+)
+
+type udfFS struct {
+	r         io.ReaderAt
+	blockSize int64
+	partStart int64
+	rootNode  *imageNode
+}
+
+// This function reports whether the volume recognition sequence after the
+// system area contains a UDF descriptor.
+func hasUDF(r io.ReaderAt) bool {
+	for i := int64(0); i < 64; i++ {
+		b, err := readSector(r, isoSystemArea+i*isoSectorSize, 6)
+		if err != nil {
+			return false
+		}
+		switch string(b[1:6]) {
+		case "NSR02", "NSR03":
+			return true
+		case "BEA01", "TEA01", "CD001", "BOOT2", "CDW02":
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+func udfTag(b []byte) uint16 {
+	if len(b) < 16 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+// This function returns the time in a UDF timestamp, where the timezone is
+// the offset from UTC in minutes in the lower 12 bits of the first field.
+func udfTime(b []byte) time.Time {
+	year := int(int16(binary.LittleEndian.Uint16(b[2:])))
+	if year == 0 {
+		return time.Unix(0, 0)
+	}
+
+	loc := time.UTC
+	tz := int16(binary.LittleEndian.Uint16(b)<<4) >> 4
+	if binary.LittleEndian.Uint16(b)>>12 == 1 && tz != -2047 {
+		loc = time.FixedZone("", int(tz)*60)
+	}
+
+	nsec := int(b[9])*10000000 + int(b[10])*100000 + int(b[11])*1000
+	return time.Date(year, time.Month(b[4]), int(b[5]), int(b[6]), int(b[7]), int(b[8]), nsec, loc)
+}
+
+// This function decodes an OSTA compressed unicode string, where the first
+// byte is the number of bits per character.
+func udfString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch b[0] {
+	case 8, 254:
+		r := make([]rune, len(b)-1)
+		for i, c := range b[1:] {
+			r[i] = rune(c)
+		}
+		return string(r)
+	case 16, 255:
+		u := make([]uint16, (len(b)-1)/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(b[1+2*i:])
+		}
+		return string(utf16.Decode(u))
+	}
+	return ""
+}
+
+// This function returns the file mode for the given UDF permissions, which
+// keep the bits of others, group, and owner in groups of five bits.
+func udfMode(perm uint32) fs.FileMode {
+	return fs.FileMode((perm>>10&7)<<6 | (perm>>5&7)<<3 | perm&7)
+}
+
+func (fsys *udfFS) readBlock(block int64) ([]byte, error) {
+	return readSector(fsys.r, (fsys.partStart+block)*fsys.blockSize, int(fsys.blockSize))
+}
+
+// This function appends the extents in the given allocation descriptors,
+// following the descriptors continued in other blocks.
+func (fsys *udfFS) allocExtents(ads []byte, adType uint16, extents []imageExtent, depth int) ([]imageExtent, error) {
+	adSize := 8
+	if adType == 1 {
+		adSize = 16
+	}
+
+	for len(ads) >= adSize {
+		length := binary.LittleEndian.Uint32(ads)
+		block := int64(binary.LittleEndian.Uint32(ads[4:]))
+		ads = ads[adSize:]
+
+		size := int64(length & 0x3fffffff)
+		if size == 0 {
+			break
+		}
+
+		switch length >> 30 {
+		case 0:
+			extents = append(extents, imageExtent{(fsys.partStart + block) * fsys.blockSize, size})
+		case 1, 2:
+			extents = append(extents, imageExtent{-1, size})
+		case 3:
+			if depth > 64 {
+				return nil, errors.New("too many allocation extents")
+			}
+			b, err := fsys.readBlock(block)
+			if err != nil {
+				return nil, err
+			}
+			if udfTag(b) != udfTagAllocExtent {
+				return nil, errors.New("invalid allocation extent")
+			}
+			n := int(binary.LittleEndian.Uint32(b[20:]))
+			if 24+n > len(b) {
+				return nil, errors.New("invalid allocation extent")
+			}
+			return fsys.allocExtents(b[24:24+n], adType, extents, depth+1)
+		}
+	}
+
+	return extents, nil
+}
+
+// This function reads the file entry in the given block and returns the node
+// for it, or nil for file types other than regular files and directories.
+func (fsys *udfFS) readEntry(block int64, name string) (*imageNode, error) {
+	b, err := fsys.readBlock(block)
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	var modTime time.Time
+	var adStart, adLen int
+	switch udfTag(b) {
+	case udfTagFileEntry:
+		size = int64(binary.LittleEndian.Uint64(b[56:]))
+		modTime = udfTime(b[84:96])
+		adStart = 176 + int(binary.LittleEndian.Uint32(b[168:]))
+		adLen = int(binary.LittleEndian.Uint32(b[172:]))
+	case udfTagExtFileEntry:
+		size = int64(binary.LittleEndian.Uint64(b[56:]))
+		modTime = udfTime(b[92:104])
+		adStart = 216 + int(binary.LittleEndian.Uint32(b[208:]))
+		adLen = int(binary.LittleEndian.Uint32(b[212:]))
+	default:
+		return nil, fmt.Errorf("invalid file entry: %s", name)
+	}
+
+	if adStart < 0 || adLen < 0 || adStart+adLen > len(b) {
+		return nil, fmt.Errorf("invalid file entry: %s", name)
+	}
+	ads := b[adStart : adStart+adLen]
+
+	n := &imageNode{name: name, size: size, modTime: modTime}
+
+	perm := udfMode(binary.LittleEndian.Uint32(b[44:]))
+	switch b[27] {
+	case 4:
+		n.mode = fs.ModeDir | perm
+	case 5:
+		n.mode = perm
+	default:
+		return nil, nil
+	}
+
+	switch adType := binary.LittleEndian.Uint16(b[34:]) & 7; adType {
+	case 0, 1:
+		if n.extents, err = fsys.allocExtents(ads, adType, nil, 0); err != nil {
+			return nil, err
+		}
+	case 3:
+		n.data = ads[:min(int64(len(ads)), size)]
+	default:
+		return nil, fmt.Errorf("unsupported allocation descriptors: %s", name)
+	}
+
+	return n, nil
+}
+
+func (fsys *udfFS) readDir(dir *imageNode) ([]*imageNode, error) {
+	b, err := readImageDir(fsys.r, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*imageNode
+	for pos := 0; pos+38 <= len(b); {
+		if udfTag(b[pos:]) != udfTagFileID {
+			return nodes, errors.New("invalid file identifier")
+		}
+
+		chars := b[pos+18]
+		idLen := int(b[pos+19])
+		block := int64(binary.LittleEndian.Uint32(b[pos+24:]))
+		iuLen := int(binary.LittleEndian.Uint16(b[pos+36:]))
+
+		end := pos + 38 + iuLen + idLen
+		if end > len(b) {
+			return nodes, errors.New("invalid file identifier")
+		}
+		name := udfString(b[pos+38+iuLen : end])
+
+		// descriptors are padded to four bytes
+		pos += (38 + iuLen + idLen + 3) &^ 3
+
+		// deleted files and the parent directory are skipped
+		if chars&0x04 != 0 || chars&0x08 != 0 {
+			continue
+		}
+
+		n, err := fsys.readEntry(block, name)
+		if err != nil {
+			return nodes, err
+		}
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+
+	return nodes, nil
+}
+
+func (fsys *udfFS) root() *imageNode {
+	return fsys.rootNode
+}
+
+func newUDF(r io.ReaderAt) (*udfFS, error) {
+	anchor, err := readSector(r, 256*isoSectorSize, isoSectorSize)
+	if err != nil {
+		return nil, err
+	}
+	if udfTag(anchor) != udfTagAnchor {
+		return nil, errors.New("anchor volume descriptor not found")
+	}
+
+	vdsLen := int64(binary.LittleEndian.Uint32(anchor[16:]))
+	vdsLoc := int64(binary.LittleEndian.Uint32(anchor[20:]))
+
+	var partNum uint16
+	var partStart int64 = -1
+	var blockSize int64
+	var fsdBlock int64
+	var mapNum uint16
+	var hasLVD bool
+
+	for i := int64(0); i < min(vdsLen/isoSectorSize, 256); i++ {
+		b, err := readSector(r, (vdsLoc+i)*isoSectorSize, isoSectorSize)
+		if err != nil {
+			return nil, err
+		}
+
+		tag := udfTag(b)
+		if tag == udfTagTerminating {
+			break
+		}
+
+		switch tag {
+		case udfTagPartition:
+			partNum = binary.LittleEndian.Uint16(b[22:])
+			partStart = int64(binary.LittleEndian.Uint32(b[188:]))
+		case udfTagLogicalVolume:
+			blockSize = int64(binary.LittleEndian.Uint32(b[212:]))
+			fsdBlock = int64(binary.LittleEndian.Uint32(b[252:]))
+			if binary.LittleEndian.Uint32(b[268:]) != 1 || b[440] != 1 {
+				return nil, errors.New("unsupported partition maps")
+			}
+			mapNum = binary.LittleEndian.Uint16(b[444:])
+			hasLVD = true
+		}
+	}
+
+	if !hasLVD || partStart < 0 || mapNum != partNum {
+		return nil, errors.New("volume descriptors not found")
+	}
+	if blockSize != isoSectorSize {
+		return nil, fmt.Errorf("unsupported block size: %d", blockSize)
+	}
+
+	fsys := &udfFS{r: r, blockSize: blockSize, partStart: partStart}
+
+	fsd, err := fsys.readBlock(fsdBlock)
+	if err != nil {
+		return nil, err
+	}
+	if udfTag(fsd) != udfTagFileSet {
+		return nil, errors.New("file set descriptor not found")
+	}
+
+	root, err := fsys.readEntry(int64(binary.LittleEndian.Uint32(fsd[404:])), ".")
+	if err != nil {
+		return nil, err
+	}
+	if root == nil || !root.IsDir() {
+		return nil, errors.New("root directory not found")
+	}
+	fsys.rootNode = root
+
+	return fsys, nil
+}
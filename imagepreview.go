@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Images can be previewed natively with the 'imagepreview' option, without a
+// previewer script, using one of the graphics protocols of terminals (i.e.
+// sixel, kitty and iTerm2 inline images). Images are decoded, scaled down to
+// fit the preview pane, and encoded in the background. Sixel images are shown
+// in the same way as sixel images printed by the previewer, and images of the
+// other protocols are shown with their size in pixels. Encoded images are kept
+// in a small cache so that moving back to a file does not decode it again.
+
+var gPreviewImageExts = []string{".png", ".jpg", ".jpeg", ".gif"}
+
+// Images larger than this are not decoded to avoid running out of memory.
+const gMaxPreviewImagePixels = 64 << 20
+
+// Images of the kitty protocol are shown with this identifier, so that the
+// last one can be deleted without affecting images of other programs.
+const gKittyImageID = 1729
+
+var gKittyDelete = fmt.Sprintf("\033_Ga=d,d=I,i=%d,q=2\033\\", gKittyImageID)
+
+func isPreviewImageName(path string) bool {
+	return slices.Contains(gPreviewImageExts, strings.ToLower(filepath.Ext(path)))
+}
+
+// This function returns the protocol used for native image previews, or an
+// empty string when they are disabled.
+func imagePreviewProtocol() string {
+	proto := gOpts.imagepreview
+	if proto == "auto" {
+		proto = guessGraphics(os.Getenv)
+	}
+	if proto == "none" {
+		return ""
+	}
+	return proto
+}
+
+// This function reports whether images may be shown in the preview pane,
+// which should be redrawn separately from the rest of the screen.
+func hasGraphics() bool {
+	return gOpts.sixel || imagePreviewProtocol() != ""
+}
+
+// This type is an encoded image of the kitty or iTerm2 protocols.
+type termImage struct {
+	seq   string
+	w     int
+	h     int
+	kitty bool
+}
+
+// This function returns the size of an image with the given size scaled down
+// to fit the given box while keeping its aspect ratio.
+func fitSize(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	if w*maxH > h*maxW {
+		return maxW, max(1, h*maxW/w)
+	}
+	return max(1, w*maxH/h), maxH
+}
+
+// This function scales the given image to the given size by averaging the
+// pixels covered by each pixel of the result.
+func scaleImage(src image.Image, w, h int) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/h)
+		for x := range w {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/w)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// This function encodes the given image as sixels with a fixed palette of 216
+// colors. Transparent pixels are left unpainted. The size of the image is
+// given in the raster attributes, which are used to reserve the cells.
+func encodeSixel(img *image.NRGBA) string {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s0;1q\"1;1;%d;%d", gSixelBegin, w, h)
+	for i := range 216 {
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+
+	level := func(v uint8) int { return (int(v)*5 + 127) / 255 }
+	index := make([]int, w*h)
+	for y := range h {
+		for x := range w {
+			c := img.NRGBAAt(x, y)
+			if c.A < 128 {
+				index[y*w+x] = -1
+				continue
+			}
+			index[y*w+x] = level(c.R)*36 + level(c.G)*6 + level(c.B)
+		}
+	}
+
+	bits := make([]byte, w)
+	for top := 0; top < h; top += 6 {
+		var colors []int
+		for y := top; y < min(top+6, h); y++ {
+			for x := range w {
+				if i := index[y*w+x]; i >= 0 && !slices.Contains(colors, i) {
+					colors = append(colors, i)
+				}
+			}
+		}
+
+		for k, c := range colors {
+			clear(bits)
+			for y := top; y < min(top+6, h); y++ {
+				for x := range w {
+					if index[y*w+x] == c {
+						bits[x] |= 1 << (y - top)
+					}
+				}
+			}
+
+			if k > 0 {
+				b.WriteByte('$')
+			}
+			fmt.Fprintf(&b, "#%d", c)
+			for x := 0; x < w; {
+				run := 1
+				for x+run < w && bits[x+run] == bits[x] {
+					run++
+				}
+				ch := byte('?' + bits[x])
+				if run > 3 {
+					fmt.Fprintf(&b, "!%d%c", run, ch)
+				} else {
+					b.WriteString(strings.Repeat(string(ch), run))
+				}
+				x += run
+			}
+		}
+		b.WriteByte('-')
+	}
+
+	b.WriteString("\033\\")
+	return b.String()
+}
+
+// This function encodes the given image for the kitty protocol, where the
+// image is sent as PNG in chunks and shown at the cursor without moving it.
+func encodeKitty(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(&buf, img); err != nil {
+		return "", err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var b strings.Builder
+	for first := true; first || len(data) > 0; first = false {
+		chunk := data[:min(4096, len(data))]
+		data = data[len(chunk):]
+
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+
+		if first {
+			fmt.Fprintf(&b, "\033_Ga=T,f=100,i=%d,C=1,q=2,m=%d;%s\033\\", gKittyImageID, more, chunk)
+		} else {
+			fmt.Fprintf(&b, "\033_Gm=%d;%s\033\\", more, chunk)
+		}
+	}
+	return b.String(), nil
+}
+
+// This function encodes the given image as an inline image of iTerm2.
+func encodeITerm2(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(&buf, img); err != nil {
+		return "", err
+	}
+	b := img.Bounds()
+	return fmt.Sprintf("\033]1337;File=inline=1;size=%d;width=%dpx;height=%dpx;preserveAspectRatio=0;doNotMoveCursor=1:%s\a",
+		buf.Len(), b.Dx(), b.Dy(), base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// This type is an image encoded for the preview, which is either a sixel
+// string or an image of the other protocols.
+type previewImage struct {
+	sixel *string
+	image *termImage
+}
+
+// Encoded images are cached for each file, modification time, size of the
+// preview pane and protocol, and the least recently used ones are removed.
+const gPreviewImageCacheSize = 32
+
+type previewImageCache struct {
+	mutex sync.Mutex
+	keys  []string
+	items map[string]previewImage
+}
+
+var gPreviewImages = previewImageCache{items: make(map[string]previewImage)}
+
+func (c *previewImageCache) get(key string) (previewImage, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	img, ok := c.items[key]
+	if ok {
+		i := slices.Index(c.keys, key)
+		c.keys = append(slices.Delete(c.keys, i, i+1), key)
+	}
+	return img, ok
+}
+
+func (c *previewImageCache) add(key string, img previewImage) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.items[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.items[key] = img
+	for len(c.keys) > gPreviewImageCacheSize {
+		delete(c.items, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+}
+
+// This function decodes the given image and encodes it to fit the given size
+// in pixels with the given protocol.
+func loadPreviewImage(path string, maxW, maxH int, proto string) (previewImage, error) {
+	f, err := openPath(path)
+	if err != nil {
+		return previewImage{}, err
+	}
+	defer f.Close()
+
+	data, err := readAllLimit(f)
+	if err != nil {
+		return previewImage{}, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return previewImage{}, err
+	}
+	if cfg.Width*cfg.Height > gMaxPreviewImagePixels {
+		return previewImage{}, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return previewImage{}, err
+	}
+
+	w, h := fitSize(src.Bounds().Dx(), src.Bounds().Dy(), maxW, maxH)
+	img := scaleImage(src, w, h)
+
+	switch proto {
+	case "sixel":
+		s := encodeSixel(img)
+		return previewImage{sixel: &s}, nil
+	case "kitty":
+		s, err := encodeKitty(img)
+		return previewImage{image: &termImage{s, w, h, true}}, err
+	case "iterm2":
+		s, err := encodeITerm2(img)
+		return previewImage{image: &termImage{s, w, h, false}}, err
+	}
+
+	return previewImage{}, fmt.Errorf("unknown protocol: %s", proto)
+}
+
+// Image files larger than this are not read for previews.
+const gMaxPreviewImageFile = 256 << 20
+
+func readAllLimit(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, gMaxPreviewImageFile+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > gMaxPreviewImageFile {
+		return nil, errors.New("file too large")
+	}
+	return data, nil
+}
+
+// This function sets the image preview of the given file when native image
+// previews are enabled and the file is a supported image. It returns false
+// when the file should be previewed as usual.
+func (nav *nav) previewImage(reg *reg, win *win, screen tcell.Screen) bool {
+	proto := imagePreviewProtocol()
+	if proto == "" || !isPreviewImageName(reg.path) {
+		return false
+	}
+
+	cw, ch, err := cellSize(screen)
+	if err != nil {
+		log.Printf("image preview: %s", err)
+		return false
+	}
+
+	stat, err := lstatPath(reg.path)
+	if err != nil {
+		return false
+	}
+
+	maxW, maxH := win.w*cw, win.h*ch
+	key := fmt.Sprintf("%s\x00%d\x00%d\x00%dx%d\x00%s", reg.path, stat.ModTime().UnixNano(), stat.Size(), maxW, maxH, proto)
+
+	img, ok := gPreviewImages.get(key)
+	if !ok {
+		img, err = loadPreviewImage(reg.path, maxW, maxH, proto)
+		if err != nil {
+			log.Printf("image preview: %s", err)
+			return false
+		}
+		gPreviewImages.add(key, img)
+	}
+
+	reg.sixel = img.sixel
+	reg.image = img.image
+	return true
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		w, h, maxW, maxH int
+		expW, expH       int
+	}{
+		{100, 50, 200, 200, 100, 50},
+		{400, 200, 200, 200, 200, 100},
+		{200, 400, 200, 200, 100, 200},
+		{1000, 1, 10, 10, 10, 1},
+	}
+
+	for _, test := range tests {
+		w, h := fitSize(test.w, test.h, test.maxW, test.maxH)
+		if w != test.expW || h != test.expH {
+			t.Errorf("at input '%dx%d' in '%dx%d' expected '%dx%d' but got '%dx%d'",
+				test.w, test.h, test.maxW, test.maxH, test.expW, test.expH, w, h)
+		}
+	}
+}
+
+func testImage(w, h int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestScaleImage(t *testing.T) {
+	src := testImage(40, 20, color.NRGBA{255, 0, 0, 255})
+	dst := scaleImage(src, 10, 5)
+
+	if b := dst.Bounds(); b.Dx() != 10 || b.Dy() != 5 {
+		t.Fatalf("expected '10x5' but got '%dx%d'", b.Dx(), b.Dy())
+	}
+	if c := dst.NRGBAAt(3, 3); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("expected red pixel but got '%v'", c)
+	}
+}
+
+func TestEncodeSixel(t *testing.T) {
+	img := testImage(8, 8, color.NRGBA{255, 255, 255, 255})
+	img.Set(0, 0, color.NRGBA{})
+
+	s := encodeSixel(img)
+
+	m := reSixelSize.FindStringSubmatch(s)
+	if m == nil || m[1] != "8" || m[2] != "8" {
+		t.Errorf("expected size '8x8' in raster attributes but got '%v'", m)
+	}
+	if !strings.HasPrefix(s, gSixelBegin) || !strings.HasSuffix(s, "\033\\") {
+		t.Errorf("expected sixel sequence but got '%q'", s)
+	}
+	// two bands of six rows, the first one without its transparent pixel
+	if !strings.HasSuffix(s, "#215}!7~-#215!8B-\033\\") {
+		t.Errorf("unexpected sixel data: %q", s[strings.LastIndex(s, "#215;"):])
+	}
+}
+
+func TestEncodeKitty(t *testing.T) {
+	// noise does not compress well, so it needs several chunks
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	x := uint32(1)
+	for i := range img.Pix {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		img.Pix[i] = byte(x)
+	}
+
+	s, err := encodeKitty(img)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	chunks := strings.Split(strings.TrimSuffix(s, "\033\\"), "\033\\")
+	if len(chunks) < 2 {
+		t.Fatalf("expected multiple chunks but got %d", len(chunks))
+	}
+	if exp := fmt.Sprintf("\033_Ga=T,f=100,i=%d,", gKittyImageID); !strings.HasPrefix(chunks[0], exp) {
+		t.Errorf("expected first chunk to start with '%q' but got '%q'", exp, chunks[0][:32])
+	}
+	for i, c := range chunks {
+		more := i < len(chunks)-1
+		if strings.Contains(c, "m=1;") != more {
+			t.Errorf("chunk %d has unexpected continuation flag", i)
+		}
+		_, data, _ := strings.Cut(c, ";")
+		if len(data) > 4096 {
+			t.Errorf("chunk %d exceeds 4096 bytes: %d", i, len(data))
+		}
+	}
+}
+
+func TestPreviewImageCache(t *testing.T) {
+	c := previewImageCache{items: make(map[string]previewImage)}
+
+	for i := range gPreviewImageCacheSize {
+		c.add(fmt.Sprint(i), previewImage{})
+	}
+	if _, ok := c.get("0"); !ok {
+		t.Fatalf("expected '0' to be cached")
+	}
+	c.add("new", previewImage{})
+
+	if _, ok := c.get("1"); ok {
+		t.Errorf("expected least recently used '1' to be removed")
+	}
+	if _, ok := c.get("0"); !ok {
+		t.Errorf("expected recently used '0' to be kept")
+	}
+	if len(c.items) != gPreviewImageCacheSize || len(c.keys) != gPreviewImageCacheSize {
+		t.Errorf("expected %d entries but got %d", gPreviewImageCacheSize, len(c.items))
+	}
+}
+
+func TestLoadPreviewImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(300, 100, color.NRGBA{0, 0, 255, 255})); err != nil {
+		t.Fatalf("encoding image: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "a.png")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("writing image: %s", err)
+	}
+
+	img, err := loadPreviewImage(path, 150, 150, "iterm2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if img.image == nil || img.image.w != 150 || img.image.h != 50 {
+		t.Fatalf("expected '150x50' image but got '%+v'", img.image)
+	}
+	if !strings.Contains(img.image.seq, "width=150px;height=50px") {
+		t.Errorf("unexpected iTerm2 sequence: %q", img.image.seq[:80])
+	}
+
+	img, err = loadPreviewImage(path, 150, 150, "sixel")
+	if err != nil || img.sixel == nil {
+		t.Fatalf("expected sixel image but got error: %v", err)
+	}
+	if m := reSixelSize.FindStringSubmatch(*img.sixel); m == nil || m[1] != "150" || m[2] != "50" {
+		t.Errorf("expected size '150x50' but got '%v'", m)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// Paste operations in the queue are listed with the 'jobs' command with their
+// progress, and they can be paused, resumed or cancelled individually. Jobs
+// are checked between files and between writes of each file, so that a paused
+// job stops within a single write, and a cancelled job removes the partially
+// written file like any other failed copy. Files that are not pasted yet when
+// a job is cancelled are skipped and reported. Delete operations are listed in
+// the same way, and they are checked between the entries they remove.
+
+var errJobCancelled = errors.New("cancelled")
+
+// The zero value of this type is a job that is waiting to be started.
+type jobControl struct {
+	files atomic.Int64
+	count atomic.Int64
+	bytes atomic.Int64
+	size  atomic.Int64
+
+	mutex     sync.Mutex
+	start     time.Time
+	resume    chan struct{}
+	cancelled bool
+	pausedAt  time.Time
+	pausedFor time.Duration
+}
+
+func (c *jobControl) begin() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.start = time.Now()
+	if c.resume != nil {
+		c.pausedAt = c.start
+	}
+}
+
+// This function blocks while the job is paused, and returns an error when the
+// job is cancelled. It can be called on a nil value for operations that are
+// not run as jobs.
+func (c *jobControl) wait() error {
+	if c == nil {
+		return nil
+	}
+
+	for {
+		c.mutex.Lock()
+		cancelled, resume := c.cancelled, c.resume
+		c.mutex.Unlock()
+
+		if cancelled {
+			return errJobCancelled
+		}
+		if resume == nil {
+			return nil
+		}
+		<-resume
+	}
+}
+
+func (c *jobControl) pause(paused bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if (c.resume != nil) == paused || c.cancelled {
+		return
+	}
+
+	now := time.Now()
+	if paused {
+		c.resume = make(chan struct{})
+		c.pausedAt = now
+		return
+	}
+
+	close(c.resume)
+	c.resume = nil
+	if !c.start.IsZero() {
+		c.pausedFor += now.Sub(c.pausedAt)
+	}
+}
+
+func (c *jobControl) cancel() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.cancelled = true
+	if c.resume != nil {
+		close(c.resume)
+		c.resume = nil
+	}
+}
+
+// This function returns the state of the job and the time it has been running
+// without the time it has been paused.
+func (c *jobControl) state(now time.Time) (string, time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var elapsed time.Duration
+	if !c.start.IsZero() {
+		elapsed = now.Sub(c.start) - c.pausedFor
+		if c.resume != nil {
+			elapsed -= now.Sub(c.pausedAt)
+		}
+	}
+
+	switch {
+	case c.cancelled:
+		return "cancelling", elapsed
+	case c.resume != nil:
+		return "paused", elapsed
+	case c.start.IsZero():
+		return "waiting", elapsed
+	}
+	return "running", elapsed
+}
+
+// This function returns the state and the progress of the job along with its
+// throughput and the estimated time left, which are empty when they are not
+// known yet.
+func (c *jobControl) progress(now time.Time) (state, progress, rate, eta string) {
+	state, elapsed := c.state(now)
+	done, size := c.bytes.Load(), c.size.Load()
+
+	progress = fmt.Sprintf("%d/%d", c.count.Load(), c.files.Load())
+	if size > 0 {
+		progress = fmt.Sprintf("%d%% %s", done*100/size, progress)
+	}
+
+	if done == 0 || elapsed <= 0 {
+		return state, progress, "", ""
+	}
+
+	bps := float64(done) / elapsed.Seconds()
+	rate = humanize(int64(bps)) + "/s"
+	if size > done {
+		left := time.Duration(float64(size-done) / bps * float64(time.Second))
+		eta = left.Round(time.Second).String()
+	}
+
+	return state, progress, rate, eta
+}
+
+// This type is used to check the job between writes of a file.
+type jobWriter struct {
+	writer io.Writer
+	ctl    *jobControl
+}
+
+func (w *jobWriter) Write(b []byte) (int, error) {
+	if err := w.ctl.wait(); err != nil {
+		return 0, err
+	}
+	return w.writer.Write(b)
+}
+
+func listJobs(labels []string, jobs []*pasteJob) string {
+	t := new(tabwriter.Writer)
+	b := new(bytes.Buffer)
+	now := time.Now()
+
+	t.Init(b, 0, gOpts.tabstop, 2, '\t', 0)
+	fmt.Fprintln(t, "key\tjob\tstate\tprogress\trate\teta\tdestination")
+	for i, job := range jobs {
+		op := "move"
+		switch {
+		case job.del:
+			op = "delete"
+		case job.cp:
+			op = "copy"
+		}
+		state, progress, rate, eta := job.ctl.progress(now)
+		fmt.Fprintf(t, "%s\t%d %s\t%s\t%s\t%s\t%s\t%s\n", labels[i], job.id, op, state, progress, rate, eta, job.dstDir)
+	}
+	t.Flush()
+
+	return b.String()
+}
+
+const gJobsPrompt = "jobs: [p]ause, [r]esume or [c]ancel? "
+
+func (app *app) showJobs() {
+	jobs := app.nav.pasteQueue.list()
+	if len(jobs) == 0 {
+		app.ui.echoerr("jobs: no jobs in progress")
+		return
+	}
+
+	app.ui.menu = listJobs(hopLabels(gRecentLabels, len(jobs)), jobs)
+	app.ui.cmdPrefix = gJobsPrompt
+}
+
+func (app *app) isJobsPrompt() bool {
+	switch app.ui.cmdPrefix {
+	case gJobsPrompt, "jobs: pause: ", "jobs: resume: ", "jobs: cancel: ":
+		return true
+	}
+	return false
+}
+
+// This function refreshes the 'jobs' menu while it is shown.
+func (app *app) updateJobs() {
+	if !app.isJobsPrompt() {
+		return
+	}
+	jobs := app.nav.pasteQueue.list()
+	app.ui.menu = listJobs(hopLabels(gRecentLabels, len(jobs)), jobs)
+}
+
+// This function handles the keys typed in the 'jobs' menu, which are an action
+// followed by the label of a job.
+func (app *app) jobsInsert(arg string) {
+	if app.ui.cmdPrefix == gJobsPrompt {
+		switch arg {
+		case "p":
+			app.ui.cmdPrefix = "jobs: pause: "
+		case "r":
+			app.ui.cmdPrefix = "jobs: resume: "
+		case "c":
+			app.ui.cmdPrefix = "jobs: cancel: "
+		default:
+			normal(app)
+			app.ui.echoerr("jobs: no such action")
+		}
+		return
+	}
+
+	action := app.ui.cmdPrefix
+	normal(app)
+
+	jobs := app.nav.pasteQueue.list()
+	labels := hopLabels(gRecentLabels, len(jobs))
+	for i, label := range labels {
+		if label != arg {
+			continue
+		}
+
+		job := jobs[i]
+		switch action {
+		case "jobs: pause: ":
+			job.ctl.pause(true)
+			app.ui.echo(fmt.Sprintf("jobs: paused job %d", job.id))
+		case "jobs: resume: ":
+			job.ctl.pause(false)
+			app.ui.echo(fmt.Sprintf("jobs: resumed job %d", job.id))
+		case "jobs: cancel: ":
+			job.ctl.cancel()
+			app.ui.echo(fmt.Sprintf("jobs: cancelled job %d", job.id))
+		}
+		return
+	}
+
+	app.ui.echoerr("jobs: no such job")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobControl(t *testing.T) {
+	var nilCtl *jobControl
+	if err := nilCtl.wait(); err != nil {
+		t.Errorf("expected no error without a job but got '%s'", err)
+	}
+
+	var ctl jobControl
+	ctl.files.Store(4)
+
+	now := time.Now()
+	if state, _, _, _ := ctl.progress(now); state != "waiting" {
+		t.Errorf("expected job to be waiting but got '%s'", state)
+	}
+
+	ctl.begin()
+	ctl.size.Store(1000)
+	ctl.bytes.Store(250)
+	ctl.count.Store(1)
+	ctl.start = now.Add(-time.Second)
+
+	state, progress, rate, eta := ctl.progress(now)
+	if state != "running" || progress != "25% 1/4" || rate != "250B/s" || eta != "3s" {
+		t.Errorf("unexpected progress '%s' '%s' '%s' '%s'", state, progress, rate, eta)
+	}
+
+	ctl.pause(true)
+	if state, _, _, _ := ctl.progress(now); state != "paused" {
+		t.Errorf("expected job to be paused but got '%s'", state)
+	}
+
+	done := make(chan error)
+	go func() { done <- ctl.wait() }()
+
+	select {
+	case <-done:
+		t.Fatalf("expected paused job to block")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	ctl.pause(false)
+	if err := <-done; err != nil {
+		t.Errorf("expected resumed job to continue but got '%s'", err)
+	}
+
+	ctl.pause(true)
+	go func() { done <- ctl.wait() }()
+	ctl.cancel()
+	if err := <-done; !errors.Is(err, errJobCancelled) {
+		t.Errorf("expected cancelled job to stop but got '%v'", err)
+	}
+
+	ctl.pause(false)
+	if err := ctl.wait(); !errors.Is(err, errJobCancelled) {
+		t.Errorf("expected cancelled job not to be resumed but got '%v'", err)
+	}
+}
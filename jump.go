@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+)
+
+// Visited directories are tracked in the directory database with a rank and
+// the time of the last visit, similar to 'z' and 'zoxide', so that frequently
+// and recently visited directories can be changed to with 'jump' by giving a
+// few keywords of their paths, or picked from the menu shown without keywords.
+// Each visit adds one to the rank of the directory, and ranks are aged by a
+// factor when their total grows too large, so that directories not visited
+// anymore are dropped eventually. Directories are ranked by the 'jumpranking'
+// option, and the database is kept in a data file with the rank, the time and
+// the path of a directory on each line, which can be changed with the
+// 'jumpfile' option. The database of zoxide can be imported with
+// 'jump-import'.
+
+// Ranks are aged when their total is above this value, like zoxide.
+const gJumpMaxRank = 10000
+
+type jumpEntry struct {
+	path string
+	rank float64
+	last time.Time
+}
+
+// This function returns the path of the directory database.
+func jumpFilePath() string {
+	if gOpts.jumpfile != "" {
+		return replaceTilde(gOpts.jumpfile)
+	}
+	return gJumpPath
+}
+
+func parseJumpEntry(line string) (jumpEntry, bool) {
+	toks := strings.SplitN(line, " ", 3)
+	if len(toks) != 3 || !filepath.IsAbs(toks[2]) {
+		return jumpEntry{}, false
+	}
+	rank, err := strconv.ParseFloat(toks[0], 64)
+	if err != nil || rank <= 0 || math.IsInf(rank, 0) {
+		return jumpEntry{}, false
+	}
+	sec, err := strconv.ParseInt(toks[1], 10, 64)
+	if err != nil {
+		return jumpEntry{}, false
+	}
+	return jumpEntry{toks[2], rank, time.Unix(sec, 0)}, true
+}
+
+// This function returns the entries of the directory database. It should be
+// called while holding the lock of the file.
+func readJumpEntries(path string) ([]jumpEntry, error) {
+	lines, err := readDataLines(path, func(line string) bool {
+		_, ok := parseJumpEntry(line)
+		return ok
+	})
+
+	entries := make([]jumpEntry, 0, len(lines))
+	for _, line := range lines {
+		e, _ := parseJumpEntry(line)
+		entries = append(entries, e)
+	}
+
+	return entries, err
+}
+
+// This function writes the given entries to the directory database. It should
+// be called while holding the lock of the file.
+func writeJumpEntries(path string, entries []jumpEntry) error {
+	var b bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s %d %s\n", strconv.FormatFloat(e.rank, 'f', -1, 64), e.last.Unix(), e.path)
+	}
+	return writeDataFile(path, b.Bytes())
+}
+
+// This function adds the given entries to the database entries, adding up
+// the ranks of the same directories and keeping the later visit times. Ranks
+// are aged afterwards when their total is too large, dropping the entries
+// with ranks falling below one.
+func mergeJumpEntries(entries, added []jumpEntry) []jumpEntry {
+	inds := make(map[string]int, len(entries))
+	for i, e := range entries {
+		inds[e.path] = i
+	}
+	for _, a := range added {
+		i, ok := inds[a.path]
+		if !ok {
+			inds[a.path] = len(entries)
+			entries = append(entries, a)
+			continue
+		}
+		entries[i].rank += a.rank
+		if a.last.After(entries[i].last) {
+			entries[i].last = a.last
+		}
+	}
+
+	total := 0.0
+	for _, e := range entries {
+		total += e.rank
+	}
+	if total > gJumpMaxRank {
+		factor := 0.9 * gJumpMaxRank / total
+		for i := range entries {
+			entries[i].rank *= factor
+		}
+		entries = slices.DeleteFunc(entries, func(e jumpEntry) bool { return e.rank < 1 })
+	}
+
+	slices.SortFunc(entries, func(a, b jumpEntry) int { return strings.Compare(a.path, b.path) })
+	return entries
+}
+
+// This function adds the given entries to the directory database.
+func addJumpEntries(added []jumpEntry) error {
+	path := jumpFilePath()
+	return withDataLock(path, func() error {
+		entries, err := readJumpEntries(path)
+		if err != nil {
+			log.Printf("directory database: %s", err)
+		}
+		if err := writeJumpEntries(path, mergeJumpEntries(entries, added)); err != nil {
+			return fmt.Errorf("writing directory database: %s", err)
+		}
+		return nil
+	})
+}
+
+// This function records a visit of the given directory in the background with
+// the 'jumptrack' option.
+func recordJump(path string) {
+	if !gOpts.jumptrack || gHeadless || strings.ContainsAny(path, "\r\n") {
+		return
+	}
+
+	go func() {
+		if err := addJumpEntries([]jumpEntry{{path, 1, time.Now()}}); err != nil {
+			log.Printf("recording visit: %s", err)
+		}
+	}()
+}
+
+// This function returns the score of the entry with the given ranking. The
+// 'frecency' ranking multiplies the rank by a factor decreasing with the time
+// since the last visit like zoxide.
+func (e jumpEntry) score(ranking string, now time.Time) float64 {
+	switch ranking {
+	case "frequency":
+		return e.rank
+	case "recency":
+		return float64(e.last.Unix())
+	}
+
+	switch age := now.Sub(e.last); {
+	case age < time.Hour:
+		return e.rank * 4
+	case age < gDay:
+		return e.rank * 2
+	case age < 7*gDay:
+		return e.rank / 2
+	default:
+		return e.rank / 4
+	}
+}
+
+// This function sorts the entries by their scores with the given ranking,
+// from the highest one.
+func rankJumpEntries(entries []jumpEntry, ranking string, now time.Time) {
+	slices.SortStableFunc(entries, func(a, b jumpEntry) int {
+		if c := cmp.Compare(b.score(ranking, now), a.score(ranking, now)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.path, b.path)
+	})
+}
+
+// This function reports whether the given path matches the keywords, which
+// should be found in the path in the given order, with the last one found in
+// the name of the directory. Keywords are matched case insensitively unless
+// they contain uppercase characters.
+func matchJump(path string, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	if !slices.ContainsFunc(keywords, func(kw string) bool { return strings.IndexFunc(kw, unicode.IsUpper) >= 0 }) {
+		path = strings.ToLower(path)
+	}
+
+	rest := path
+	for _, kw := range keywords {
+		i := strings.Index(rest, kw)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(kw):]
+	}
+
+	return strings.Contains(filepath.Base(path), keywords[len(keywords)-1])
+}
+
+// This function returns at most the given number of existing directories in
+// the database matching the given keywords, from the highest ranked one,
+// leaving out the given current directory.
+func findJumps(keywords []string, curr string, limit int) ([]string, error) {
+	var entries []jumpEntry
+	path := jumpFilePath()
+	err := withDataLock(path, func() (err error) {
+		entries, err = readJumpEntries(path)
+		return
+	})
+	if err != nil && !errors.Is(err, errDataCorrupt) {
+		return nil, err
+	}
+
+	rankJumpEntries(entries, gOpts.jumpranking, time.Now())
+
+	var dirs []string
+	for _, e := range entries {
+		if len(dirs) == limit {
+			break
+		}
+		if e.path == curr || !matchJump(e.path, keywords) {
+			continue
+		}
+		if stat, err := os.Stat(e.path); err != nil || !stat.IsDir() {
+			continue
+		}
+		dirs = append(dirs, e.path)
+	}
+
+	return dirs, err
+}
+
+func listJumpDirs(labels, dirs []string) string {
+	t := new(tabwriter.Writer)
+	b := new(bytes.Buffer)
+
+	t.Init(b, 0, gOpts.tabstop, 2, '\t', 0)
+	fmt.Fprintln(t, "key\tdirectory")
+	for i, label := range labels {
+		fmt.Fprintf(t, "%s\t%s\n", label, dirs[i])
+	}
+	t.Flush()
+
+	return b.String()
+}
+
+// This function changes the current directory to the highest ranked directory
+// matching the given keywords, or shows the highest ranked directories in a
+// menu when no keyword is given.
+func (app *app) jump(keywords []string) {
+	limit := len(gRecentLabels)
+	if len(keywords) > 0 {
+		limit = 1
+	}
+
+	dirs, err := findJumps(keywords, app.nav.currDir().path, limit)
+	if err != nil {
+		// corrupted files are still used after they are reported
+		app.ui.echoerrf("jump: directory database: %s", err)
+	}
+	if len(dirs) == 0 {
+		if err == nil {
+			app.ui.echoerr("jump: no matching directory")
+		}
+		return
+	}
+
+	if len(keywords) > 0 {
+		(&callExpr{"cd", []string{dirs[0]}, 1}).eval(app, nil)
+		return
+	}
+
+	app.jumpDirs = dirs
+	app.ui.menu = listJumpDirs(hopLabels(gRecentLabels, len(app.jumpDirs)), app.jumpDirs)
+	app.ui.cmdPrefix = "jump: "
+}
+
+// This function changes the current directory to the one with the given label
+// in the 'jump' menu.
+func (app *app) jumpOpen(arg string) {
+	normal(app)
+
+	labels := hopLabels(gRecentLabels, len(app.jumpDirs))
+	if i := slices.Index(labels, arg); i >= 0 {
+		(&callExpr{"cd", []string{app.jumpDirs[i]}, 1}).eval(app, nil)
+		return
+	}
+
+	app.ui.echoerr("jump: no such directory")
+}
+
+// This function parses the database of zoxide, which is a version number
+// followed by the list of directories with their paths, ranks and last
+// access times in the bincode format of Rust.
+func parseZoxideDB(data []byte) ([]jumpEntry, error) {
+	r := bytes.NewReader(data)
+
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("reading version: %s", err)
+	}
+	if version != 3 {
+		return nil, fmt.Errorf("unsupported version: %d", version)
+	}
+
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("reading number of directories: %s", err)
+	}
+
+	var entries []jumpEntry
+	for range n {
+		var size uint64
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, fmt.Errorf("reading directory: %s", err)
+		}
+		if size > uint64(r.Len()) {
+			return nil, errors.New("reading directory: unexpected end of file")
+		}
+		path := make([]byte, size)
+		r.Read(path)
+
+		var dir struct {
+			Rank float64
+			Last uint64
+		}
+		if err := binary.Read(r, binary.LittleEndian, &dir); err != nil {
+			return nil, fmt.Errorf("reading directory: %s", err)
+		}
+
+		e := jumpEntry{string(path), dir.Rank, time.Unix(int64(dir.Last), 0)}
+		if filepath.IsAbs(e.path) && e.rank > 0 && !strings.ContainsAny(e.path, "\r\n") {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+// This function adds the directories of the given database of zoxide to the
+// directory database, or the default one of zoxide when none is given.
+func (app *app) jumpImport(args []string) {
+	var path string
+	switch len(args) {
+	case 0:
+		path = gZoxidePath
+	case 1:
+		path = replaceTilde(args[0])
+	default:
+		app.ui.echoerr("jump-import: usage: jump-import [path]")
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		app.ui.echoerrf("jump-import: %s", err)
+		return
+	}
+
+	entries, err := parseZoxideDB(data)
+	if err != nil {
+		app.ui.echoerrf("jump-import: %s: %s", path, err)
+		return
+	}
+
+	if err := addJumpEntries(entries); err != nil {
+		app.ui.echoerrf("jump-import: %s", err)
+		return
+	}
+
+	app.ui.echo(fmt.Sprintf("jump-import: %d directories imported", len(entries)))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestMatchJump(t *testing.T) {
+	tests := []struct {
+		path     string
+		keywords []string
+		exp      bool
+	}{
+		{"/home/user/src/lf", []string{"lf"}, true},
+		{"/home/user/src/lf", []string{"src", "lf"}, true},
+		{"/home/user/src/lf", []string{"lf", "src"}, false},
+		{"/home/user/src/lf", []string{"src"}, false},
+		{"/home/user/Documents", []string{"doc"}, true},
+		{"/home/user/Documents", []string{"Doc"}, true},
+		{"/home/user/documents", []string{"Doc"}, false},
+		{"/home/user/src/lf", nil, true},
+	}
+
+	for _, test := range tests {
+		if got := matchJump(test.path, test.keywords); got != test.exp {
+			t.Errorf("at input '%s' with '%v' expected '%t' but got '%t'", test.path, test.keywords, test.exp, got)
+		}
+	}
+}
+
+func TestRankJumpEntries(t *testing.T) {
+	now := time.Now()
+	entries := []jumpEntry{
+		{"/old", 10, now.Add(-30 * gDay)},
+		{"/recent", 2, now.Add(-time.Minute)},
+		{"/daily", 3, now.Add(-2 * time.Hour)},
+	}
+
+	tests := []struct {
+		ranking string
+		exp     []string
+	}{
+		{"frecency", []string{"/recent", "/daily", "/old"}},
+		{"frequency", []string{"/old", "/daily", "/recent"}},
+		{"recency", []string{"/recent", "/daily", "/old"}},
+	}
+
+	for _, test := range tests {
+		rankJumpEntries(entries, test.ranking, now)
+		for i, e := range entries {
+			if e.path != test.exp[i] {
+				t.Errorf("at input '%s' expected '%v' but got '%v'", test.ranking, test.exp, entries)
+				break
+			}
+		}
+	}
+}
+
+func TestMergeJumpEntries(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	entries := mergeJumpEntries(nil, []jumpEntry{{"/b", 1, now}, {"/a", 2, now}})
+	entries = mergeJumpEntries(entries, []jumpEntry{{"/b", 1, now.Add(time.Hour)}})
+	if len(entries) != 2 || entries[0].path != "/a" || entries[1].rank != 2 || !entries[1].last.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected '/a' and '/b' visited twice but got '%v'", entries)
+	}
+
+	// ranks are aged and small ones are dropped when their total is too large
+	entries = mergeJumpEntries(entries, []jumpEntry{{"/c", 5 * gJumpMaxRank, now}})
+	if len(entries) != 1 || entries[0].path != "/c" || entries[0].rank >= gJumpMaxRank {
+		t.Errorf("expected only '/c' with an aged rank but got '%v'", entries)
+	}
+}
+
+func TestParseZoxideDB(t *testing.T) {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(3))
+	binary.Write(&b, binary.LittleEndian, uint64(2))
+	for _, dir := range []struct {
+		path string
+		rank float64
+		last uint64
+	}{
+		{"/home/user/src", 12.5, 1700000000},
+		{"relative", 1, 1700000000},
+	} {
+		binary.Write(&b, binary.LittleEndian, uint64(len(dir.path)))
+		b.WriteString(dir.path)
+		binary.Write(&b, binary.LittleEndian, dir.rank)
+		binary.Write(&b, binary.LittleEndian, dir.last)
+	}
+
+	entries, err := parseZoxideDB(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].path != "/home/user/src" || entries[0].rank != 12.5 || entries[0].last.Unix() != 1700000000 {
+		t.Errorf("expected '/home/user/src' with rank '12.5' but got '%v'", entries)
+	}
+
+	if _, err := parseZoxideDB(b.Bytes()[:b.Len()-4]); err == nil {
+		t.Errorf("expected an error for a truncated database")
+	}
+	if _, err := parseZoxideDB([]byte{2, 0, 0, 0}); err == nil {
+		t.Errorf("expected an error for an unsupported version")
+	}
+}
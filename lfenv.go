@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Directories can have a '.lfenv' file to change the behavior of lf in them,
+// similar to the '.envrc' files of direnv, which is loaded when the 'lfenv'
+// option is enabled and the current directory or one of its parents has it.
+// The file is written with the usual syntax, but only 'set', 'setlocal' and
+// 'cmd' commands are allowed, and they only apply to the directory of the
+// file and its subdirectories: options are set with 'setlocal', and commands
+// are defined with 'under' unless they have a scope. Since the file can run
+// shell commands, it is only loaded once it is allowed by the user, and allowed
+// files are remembered as trusted files.
+
+const gLfenvName = ".lfenv"
+
+// This function returns the '.lfenv' file in the given directory or in the
+// nearest parent directory that has one.
+func findLfenv(dir string) (string, bool) {
+	for {
+		path := filepath.Join(dir, gLfenvName)
+		if stat, err := os.Stat(path); err == nil && stat.Mode().IsRegular() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// Glob characters in directory names are escaped in scopes of commands, which
+// is not possible on Windows where the backslash is the path separator.
+var gScopeEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+
+// This function resolves the given path relative to the given directory,
+// keeping a trailing path separator. An error is returned for absolute paths
+// and paths outside of the directory (e.g. with '..'), so that the file can
+// not change the behavior of lf in other directories.
+func lfenvPath(dir, path string) (string, error) {
+	if filepath.IsAbs(replaceTilde(path)) {
+		return "", fmt.Errorf("path is not relative to the directory: %s", path)
+	}
+	sep := strings.HasSuffix(path, string(filepath.Separator))
+	joined := filepath.Join(dir, path)
+	if rel, err := filepath.Rel(dir, joined); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path is outside of the directory: %s", path)
+	}
+	if sep {
+		joined += string(filepath.Separator)
+	}
+	return joined, nil
+}
+
+// This function parses the contents of the given file and returns its
+// commands to be applied only to its directory and subdirectories. Nothing is
+// returned when any of the commands is not allowed.
+func parseLfenv(env *trustedFile) ([]expr, error) {
+	dir := filepath.Dir(env.path)
+
+	scopeDir := dir
+	if filepath.Separator == '/' {
+		scopeDir = gScopeEscaper.Replace(dir)
+	}
+
+	var exprs []expr
+	p := newParser(bytes.NewReader(env.data))
+	for p.parse() {
+		switch e := p.expr.(type) {
+		case *setExpr:
+			exprs = append(exprs, &setLocalExpr{dir + string(filepath.Separator), e.opt, e.val})
+		case *setLocalExpr:
+			path, err := lfenvPath(dir, e.path)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, &setLocalExpr{path, e.opt, e.val})
+		case *cmdExpr:
+			scope := filepath.Join(scopeDir, "**")
+			if e.scope != "" {
+				var err error
+				if scope, err = lfenvPath(scopeDir, e.scope); err != nil {
+					return nil, err
+				}
+			}
+			exprs = append(exprs, &cmdExpr{e.name, e.expr, scope})
+		default:
+			return nil, fmt.Errorf("only 'set', 'setlocal' and 'cmd' are allowed: %s", p.expr)
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return exprs, nil
+}
+
+func (app *app) loadLfenv(env *trustedFile) {
+	exprs, err := parseLfenv(env)
+	if err != nil {
+		app.ui.echoerrf("lfenv: %s: %s", env.path, err)
+		return
+	}
+
+	log.Printf("loading lfenv: %s", env.path)
+	for _, e := range exprs {
+		e.eval(app, nil)
+	}
+
+	app.ui.echo(fmt.Sprintf("lfenv: loaded '%s'", env.path))
+}
+
+// This function loads the '.lfenv' file of the current directory when it is
+// allowed, or asks the user otherwise. Each version of a file is only handled
+// once in a session, so the user is not asked again after declining.
+func (app *app) checkLfenv() {
+	if !gOpts.lfenv {
+		return
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	path, ok := findLfenv(wd)
+	if !ok {
+		return
+	}
+
+	env, err := readTrustedFile(path)
+	if err != nil {
+		app.ui.echoerrf("lfenv: %s", err)
+		return
+	}
+
+	if app.lfenvSeen[path] == env.hash {
+		return
+	}
+
+	if isTrusted(env) {
+		app.lfenvSeen[path] = env.hash
+		app.loadLfenv(env)
+		return
+	}
+
+	if gHeadless {
+		app.lfenvSeen[path] = env.hash
+		app.ui.echoerrf("lfenv: '%s' is not allowed, use 'lfenv-allow' to load it", path)
+		return
+	}
+
+	// the user is asked later when another prompt is active
+	if app.ui.cmdPrefix != "" {
+		return
+	}
+
+	app.lfenvSeen[path] = env.hash
+	app.lfenvPending = env
+	app.ui.cmdPrefix = fmt.Sprintf("lfenv: load '%s'? [y]es, [a]lways or [N]o ", path)
+}
+
+// This function handles the answer to the question asked by 'checkLfenv'.
+func (app *app) answerLfenv(answer string) {
+	env := app.lfenvPending
+	app.lfenvPending = nil
+	if env == nil {
+		return
+	}
+
+	switch answer {
+	case "a":
+		if err := setTrusted(env.path, env.hash); err != nil {
+			app.ui.echoerrf("lfenv: %s", err)
+			return
+		}
+		app.loadLfenv(env)
+	case "y":
+		app.loadLfenv(env)
+	}
+}
+
+// This function allows the '.lfenv' file of the current directory and loads
+// it, or removes it from the allowed files.
+func (app *app) allowLfenv(allow bool) {
+	name := "lfenv-deny"
+	if allow {
+		name = "lfenv-allow"
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		app.ui.echoerrf("%s: %s", name, err)
+		return
+	}
+	path, ok := findLfenv(wd)
+	if !ok {
+		app.ui.echoerrf("%s: no %s file found", name, gLfenvName)
+		return
+	}
+
+	if !allow {
+		if err := setTrusted(path, ""); err != nil {
+			app.ui.echoerrf("%s: %s", name, err)
+			return
+		}
+		app.ui.echo(fmt.Sprintf("lfenv: denied '%s'", path))
+		return
+	}
+
+	env, err := readTrustedFile(path)
+	if err != nil {
+		app.ui.echoerrf("%s: %s", name, err)
+		return
+	}
+	if err := setTrusted(path, env.hash); err != nil {
+		app.ui.echoerrf("%s: %s", name, err)
+		return
+	}
+	app.lfenvSeen[path] = env.hash
+	app.loadLfenv(env)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLfenv(t *testing.T) {
+	tests := []struct {
+		data string
+		exp  []string
+	}{
+		{
+			"set hidden\nsetlocal sub/ sortby time\nsetlocal sub/../other info size",
+			[]string{
+				"setlocal /work/ hidden ",
+				"setlocal /work/sub/ sortby time",
+				"setlocal /work/other info size",
+			},
+		},
+		{
+			"cmd deploy &{{make deploy}}\ncmd test &{{make test}} under src/**",
+			[]string{
+				"cmd deploy &{{ make deploy }} under /work/**",
+				"cmd test &{{ make test }} under /work/src/**",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		exprs, err := parseLfenv(&trustedFile{path: "/work/.lfenv", data: []byte(test.data)})
+		if err != nil {
+			t.Errorf("at input '%s' expected no error but got '%s'", test.data, err)
+			continue
+		}
+		if len(exprs) != len(test.exp) {
+			t.Errorf("at input '%s' expected %d commands but got %d", test.data, len(test.exp), len(exprs))
+			continue
+		}
+		for i, e := range exprs {
+			if e.String() != test.exp[i] {
+				t.Errorf("at input '%s' expected '%s' but got '%s'", test.data, test.exp[i], e)
+			}
+		}
+	}
+
+	for _, data := range []string{
+		"map x delete",
+		"set hidden\n$rm -rf ~",
+		"setlocal /abs info size",
+		"setlocal ~/ hidden",
+		"setlocal ../ hidden",
+		"setlocal sub/../../other hidden",
+		"cmd test &{{make test}} under /**",
+		"cmd test &{{make test}} under ../**",
+	} {
+		if _, err := parseLfenv(&trustedFile{path: "/work/.lfenv", data: []byte(data)}); err == nil {
+			t.Errorf("at input '%s' expected an error", data)
+		}
+	}
+}
+
+func TestFindLfenv(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, gLfenvName)
+	if err := os.WriteFile(path, []byte("set hidden\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, ok := findLfenv(filepath.Join(dir, "sub", "dir")); !ok || found != path {
+		t.Errorf("expected '%s' but got '%s'", path, found)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -20,6 +21,9 @@ import (
 //go:embed doc.txt
 var genDocString string
 
+//go:embed doc.md
+var genDocMarkdown string
+
 var (
 	envPath  = os.Getenv("PATH")
 	envLevel = os.Getenv("LF_LEVEL")
@@ -29,18 +33,24 @@ type arrayFlag []string
 
 var (
 	gSingleMode     bool
+	gHeadless       bool
 	gPrintLastDir   bool
 	gPrintSelection bool
 	gAutocd         bool
+	gAutocdMode     string
 	gClientID       int
 	gHostname       string
 	gLastDirPath    string
+	gLastDirFormat  string
 	gSelectionPath  string
 	gSocketProt     string
 	gSocketPath     string
 	gLogPath        string
 	gSelect         string
 	gConfigPath     string
+	gTutorDir       string
+	gDemoDir        string
+	gAttachSession  string
 	gCommands       arrayFlag
 	gVersion        string
 )
@@ -96,8 +106,8 @@ func fieldToString(field reflect.Value) string {
 	var value string
 
 	switch kind {
-	case reflect.Int:
-		value = strconv.Itoa(int(field.Int()))
+	case reflect.Int, reflect.Int64:
+		value = strconv.FormatInt(field.Int(), 10)
 	case reflect.Bool:
 		value = strconv.FormatBool(field.Bool())
 	case reflect.Slice:
@@ -260,6 +270,51 @@ func main() {
 		"",
 		"send remote command to server")
 
+	headlessCmds := flag.String(
+		"c",
+		"",
+		"run commands without the user interface and exit")
+
+	batchScript := flag.String(
+		"batch",
+		"",
+		"run the commands in the given script without the user interface and exit")
+
+	benchPath := flag.String(
+		"bench",
+		"",
+		"print a JSON report of the time spent loading, sorting, drawing and previewing the given directory (or 'synthetic[:files]' for a generated tree)")
+
+	printSchema := flag.Bool(
+		"print-schema",
+		false,
+		"print a JSON schema of the options, commands and keys for editors")
+
+	portableMode := flag.Bool(
+		"portable",
+		false,
+		"keep the config, data and socket files in the 'lf-portable' directory next to the binary")
+
+	tutorMode := flag.Bool(
+		"tutor",
+		false,
+		"start an interactive tutorial in a temporary sandbox directory")
+
+	demoShape := flag.String(
+		"demo",
+		"",
+		"start in a generated tree of fake files with the given shape (small, medium, large or e.g. 'files=5000,depth=3,width=4')")
+
+	shellInitFor := flag.String(
+		"init",
+		"",
+		"print an lfcd function for the given shell (bash, zsh, fish or powershell) to change directory on exit")
+
+	migrateFrom := flag.String(
+		"migrate-config",
+		"",
+		"print an lfrc converted from the config of another file manager (ranger, vifm or nnn)")
+
 	cpuprofile := flag.String(
 		"cpuprofile",
 		"",
@@ -273,7 +328,12 @@ func main() {
 	flag.StringVar(&gLastDirPath,
 		"last-dir-path",
 		"",
-		"path to the file to write the last dir on exit (to use for cd)")
+		"path to the file to write the last dir on exit (to use for cd), where %i is the client id and %p is $TMUX_PANE")
+
+	flag.StringVar(&gLastDirFormat,
+		"last-dir-format",
+		"raw",
+		"format of the last dir written on exit (raw, quoted or cd-command)")
 
 	flag.StringVar(&gSelectionPath,
 		"selection-path",
@@ -285,6 +345,11 @@ func main() {
 		"",
 		"path to the config file (instead of the usual paths)")
 
+	flag.StringVar(&gAttachSession,
+		"attach-session",
+		"",
+		"restore the state of a client detached with the given session name")
+
 	flag.Var(&gCommands,
 		"command",
 		"command to execute on client initialization")
@@ -294,6 +359,11 @@ func main() {
 		false,
 		"change to last directory using autocd on exit")
 
+	flag.StringVar(&gAutocdMode,
+		"autocd-mode",
+		"dir",
+		"directory to change to on exit (dir for the current directory or file-dir for the directory of the last selected file)")
+
 	flag.StringVar(&gLogPath,
 		"log",
 		"",
@@ -301,6 +371,13 @@ func main() {
 
 	flag.Parse()
 
+	if *portableMode {
+		if err := setupPortable(); err != nil {
+			fmt.Fprintf(os.Stderr, "portable: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
 	gSocketProt = gDefaultSocketProt
 	gSocketPath = gDefaultSocketPath
 
@@ -328,23 +405,138 @@ func main() {
 		fmt.Print(genDocString)
 	case *showVersion:
 		printVersion()
+	case *printSchema:
+		schema, err := genSchema()
+		if err != nil {
+			log.Fatalf("generating schema: %s", err)
+		}
+		fmt.Println(string(schema))
 	case *remoteCmd != "":
 		if err := remote(*remoteCmd); err != nil {
 			log.Fatalf("remote command: %s", err)
 		}
+	case *shellInitFor != "":
+		script, err := shellInit(*shellInitFor)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "init: %s\n", err)
+			os.Exit(2)
+		}
+		fmt.Print(script)
+	case *migrateFrom != "":
+		config, err := migrateConfig(*migrateFrom)
+		if err != nil {
+			log.Fatalf("migrating config: %s", err)
+		}
+		fmt.Print(config)
 	case *serverMode:
 		os.Chdir(gUser.HomeDir)
 		serve()
+	case *benchPath != "":
+		gHeadless = true
+		gSingleMode = true
+		if err := runBench(*benchPath, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "bench: %s\n", err)
+			os.Exit(2)
+		}
+	case *headlessCmds != "" || *batchScript != "":
+		gHeadless = true
+		gSingleMode = true
+		gPrintLastDir = *printLastDir
+		gClientID = os.Getpid()
+		gLastDirPath = expandLastDirPath(gLastDirPath, gClientID, os.Getenv("TMUX_PANE"))
+
+		switch flag.NArg() {
+		case 0:
+		case 1:
+			gSelect = flag.Arg(0)
+		default:
+			fmt.Fprintf(os.Stderr, "only single file or directory is allowed\n")
+			os.Exit(2)
+		}
+
+		exportEnvVars()
+
+		var errCount int
+		if *batchScript != "" {
+			f, err := os.Open(*batchScript)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "opening script: %s\n", err)
+				os.Exit(2)
+			}
+			errCount = runHeadless(f, os.Stdout)
+			f.Close()
+		} else {
+			errCount = runHeadless(strings.NewReader(*headlessCmds), io.Discard)
+		}
+
+		if errCount > 0 {
+			os.Exit(1)
+		}
+	case *tutorMode:
+		root, err := setupTutor()
+		if root != "" {
+			defer os.RemoveAll(root)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tutor: %s\n", err)
+			os.Exit(2)
+		}
+
+		gSingleMode = true
+		gAutocd = false
+		gClientID = os.Getpid()
+
+		exportEnvVars()
+
+		run()
+	case *demoShape != "":
+		root, err := setupDemo(*demoShape)
+		if err != nil {
+			if root != "" {
+				os.RemoveAll(root)
+			}
+			fmt.Fprintf(os.Stderr, "demo: %s\n", err)
+			os.Exit(2)
+		}
+		defer os.RemoveAll(root)
+
+		gSingleMode = true
+		gAutocd = false
+		gClientID = os.Getpid()
+
+		exportEnvVars()
+
+		run()
 	default:
 		gSingleMode = *singleMode
 		gPrintLastDir = *printLastDir
 		gPrintSelection = *printSelection
 
+		switch gLastDirFormat {
+		case "raw", "quoted", "cd-command":
+		default:
+			fmt.Fprintf(os.Stderr, "invalid last dir format: %s\n", gLastDirFormat)
+			os.Exit(2)
+		}
+
+		switch gAutocdMode {
+		case "dir", "file-dir":
+		default:
+			fmt.Fprintf(os.Stderr, "invalid autocd mode: %s\n", gAutocdMode)
+			os.Exit(2)
+		}
+
+		if gAttachSession != "" && gSingleMode {
+			fmt.Fprintf(os.Stderr, "attaching a session is not supported in single mode\n")
+			os.Exit(2)
+		}
+
 		if !gSingleMode {
 			checkServer()
 		}
 
 		gClientID = os.Getpid()
+		gLastDirPath = expandLastDirPath(gLastDirPath, gClientID, os.Getenv("TMUX_PANE"))
 
 		switch flag.NArg() {
 		case 0:
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Marks are exported as a JSON object mapping mark names to paths:
+//
+//	{
+//	  "a": "/home/user/documents",
+//	  "b": "/tmp"
+//	}
+//
+// Marks can be imported from the same format, or from bookmark files of other
+// file managers, namely 'ranger' (e.g. '~/.local/share/ranger/bookmarks') and
+// 'vifm' (e.g. '~/.config/vifm/vifminfo.json' or '~/.config/vifm/vifminfo').
+
+func exportMarks(marks map[string]string, path string) error {
+	out := make(map[string]string)
+	for k, v := range marks {
+		if !strings.Contains(gOpts.tempmarks, k) {
+			out[k] = v
+		}
+	}
+
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding marks: %s", err)
+	}
+
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing marks: %s", err)
+	}
+
+	return nil
+}
+
+func parseMarks(data []byte, from string) (map[string]string, error) {
+	var marks map[string]string
+	var err error
+	switch from {
+	case "lf":
+		if err := json.Unmarshal(data, &marks); err != nil {
+			return nil, fmt.Errorf("decoding marks: %s", err)
+		}
+	case "ranger":
+		marks, err = parseRangerMarks(data)
+	case "vifm":
+		if json.Valid(data) {
+			marks, err = parseVifmJSONMarks(data)
+		} else {
+			marks, err = parseVifmMarks(data)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format: %s", from)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkMarks(marks); err != nil {
+		return nil, err
+	}
+	return marks, nil
+}
+
+// This function checks that the given marks can be kept in the marks file,
+// which has a 'mark:path' line for each mark, so names should be a single
+// character other than ':', and paths should not be empty or have newlines.
+func checkMarks(marks map[string]string) error {
+	for _, mark := range slices.Sorted(maps.Keys(marks)) {
+		path := marks[mark]
+		switch {
+		case utf8.RuneCountInString(mark) != 1 || mark == ":":
+			return fmt.Errorf("invalid mark name: %q", mark)
+		case path == "" || strings.ContainsAny(path, "\r\n"):
+			return fmt.Errorf("invalid path of mark '%s': %q", mark, path)
+		}
+	}
+	return nil
+}
+
+// ranger bookmarks are stored as 'mark:path' lines like lf marks
+func parseRangerMarks(data []byte) (map[string]string, error) {
+	marks := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		mark, path, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			return nil, fmt.Errorf("invalid bookmark entry: %s", scanner.Text())
+		}
+		marks[mark] = path
+	}
+
+	return marks, scanner.Err()
+}
+
+// vifm stores marks in 'vifminfo.json' as objects with 'dir' and 'file' keys
+func parseVifmJSONMarks(data []byte) (map[string]string, error) {
+	var info struct {
+		Marks map[string]struct {
+			Dir string `json:"dir"`
+		} `json:"marks"`
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("decoding vifminfo: %s", err)
+	}
+
+	marks := make(map[string]string)
+	for mark, v := range info.Marks {
+		if v.Dir != "" {
+			marks[mark] = v.Dir
+		}
+	}
+
+	return marks, nil
+}
+
+// older versions of vifm store each mark in 'vifminfo' as a line with the mark
+// prefixed by a quote followed by tab-indented lines for the directory and file
+func parseVifmMarks(data []byte) (map[string]string, error) {
+	marks := make(map[string]string)
+
+	var mark string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "'") && len(line) > 1:
+			mark = line[1:]
+		case strings.HasPrefix(line, "\t") && mark != "":
+			marks[mark] = line[1:]
+			mark = ""
+		default:
+			mark = ""
+		}
+	}
+
+	return marks, scanner.Err()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMarks(t *testing.T) {
+	tests := []struct {
+		data string
+		from string
+		exp  map[string]string
+	}{
+		{
+			`{"a": "/home/user", "b": "/tmp"}`,
+			"lf",
+			map[string]string{"a": "/home/user", "b": "/tmp"},
+		},
+		{
+			"a:/home/user\n':/tmp\n\nc:/foo:bar\n",
+			"ranger",
+			map[string]string{"a": "/home/user", "'": "/tmp", "c": "/foo:bar"},
+		},
+		{
+			`{"marks": {"a": {"dir": "/home/user", "file": "..", "ts": 1}, "b": {"file": "x"}}}`,
+			"vifm",
+			map[string]string{"a": "/home/user"},
+		},
+		{
+			"# Marks:\n'a\n\t/home/user\n\tfoo.txt\n1700000000\n'b\n\t/tmp\n\t..\n",
+			"vifm",
+			map[string]string{"a": "/home/user", "b": "/tmp"},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := parseMarks([]byte(test.data), test.from)
+		if err != nil {
+			t.Errorf("at input %q with format '%s' expected no error but got: %s", test.data, test.from, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("at input %q with format '%s' expected '%v' but got '%v'", test.data, test.from, test.exp, got)
+		}
+	}
+
+	if _, err := parseMarks([]byte("a:/tmp"), "nnn"); err == nil {
+		t.Errorf("at unknown format expected error but got none")
+	}
+
+	for _, test := range []struct {
+		data string
+		from string
+	}{
+		{`{"ab": "/tmp"}`, "lf"},
+		{`{":": "/tmp"}`, "lf"},
+		{`{"": "/tmp"}`, "lf"},
+		{`{"a": "/tmp/x\ny"}`, "lf"},
+		{`{"a": ""}`, "lf"},
+		{"bookmark:/tmp\n", "ranger"},
+		{"'ab\n\t/tmp\n", "vifm"},
+	} {
+		if _, err := parseMarks([]byte(test.data), test.from); err == nil {
+			t.Errorf("at input %q with format '%s' expected error but got none", test.data, test.from)
+		}
+	}
+}
@@ -0,0 +1,826 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Configuration migration reads the configuration of another file manager and
+// writes an equivalent lfrc to the standard output. Only the parts with a
+// clear counterpart in lf are converted, and anything else is written as a
+// comment so that it can be converted by hand. Colorschemes are not converted
+// since colors are set in a separate colors file in lf, and ranger colorschemes
+// are Python code, so they are reported with a warning instead. The highlight
+// groups of vifm with a counterpart in lf are written as commented colors file
+// entries to be copied by hand.
+
+var (
+	reMigrateKey     = regexp.MustCompile(`<([^<>]+)>`)
+	reVifmOptAssign  = regexp.MustCompile(`^(\w+)[=:](.*)$`)
+	reVifmFiletype   = regexp.MustCompile(`^(\S+)\s+(.*)$`)
+	reVifmAltDesc    = regexp.MustCompile(`^\{[^}]*\}\s*`)
+	reVifmMacro      = regexp.MustCompile(`\s*%[imMsSnqpuUIa]`)
+	reRangerKeyValue = regexp.MustCompile(`^(\w+)=(.*)$`)
+)
+
+type migrator struct {
+	b       strings.Builder
+	openers []string
+	colors  []string
+}
+
+func (m *migrator) printf(format string, a ...any) {
+	fmt.Fprintf(&m.b, format, a...)
+}
+
+func (m *migrator) unsupported(line string) {
+	m.printf("# unsupported: %s\n", line)
+}
+
+func (m *migrator) warn(format string, a ...any) {
+	m.printf("# warning: %s\n", fmt.Sprintf(format, a...))
+}
+
+// This function writes the collected colors as comments, since they belong in
+// the colors file rather than the lfrc.
+func (m *migrator) writeColors() {
+	if len(m.colors) == 0 {
+		return
+	}
+
+	m.printf("\n# colors converted from highlight groups, to be copied to the colors file\n")
+	m.printf("# (see the COLORS section in 'lf -doc'):\n")
+	for _, entry := range m.colors {
+		m.printf("#   %s\n", entry)
+	}
+}
+
+// This function writes the collected opener rules as an 'open' command which
+// tries each rule in order and falls back to the default opener.
+func (m *migrator) writeOpen() {
+	if len(m.openers) == 0 {
+		return
+	}
+
+	m.printf("\ncmd open ${{\n")
+	m.printf("    set -f\n")
+	m.printf("    IFS='\n'\n")
+	m.printf("    set -- $fx\n")
+	m.printf("    mime=$(file --mime-type -Lb -- \"$1\")\n")
+	for _, rule := range m.openers {
+		m.printf("    %s\n", rule)
+	}
+	m.printf("    $OPENER \"$@\"\n")
+	m.printf("}}\n")
+}
+
+func migrateConfig(from string) (string, error) {
+	config := cmp.Or(os.Getenv("XDG_CONFIG_HOME"), filepath.Join(gUser.HomeDir, ".config"))
+
+	switch from {
+	case "ranger":
+		dir := cmp.Or(os.Getenv("RANGER_CONFIG_DIR"), filepath.Join(config, "ranger"))
+		rc, err := os.ReadFile(filepath.Join(dir, "rc.conf"))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		rifle, err := os.ReadFile(filepath.Join(dir, "rifle.conf"))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if rc == nil && rifle == nil {
+			return "", fmt.Errorf("no configuration found in %s", dir)
+		}
+		return migrateRanger(string(rc), string(rifle)), nil
+	case "vifm":
+		paths := []string{filepath.Join(config, "vifm", "vifmrc"), filepath.Join(gUser.HomeDir, ".vifm", "vifmrc")}
+		if dir := os.Getenv("VIFM"); dir != "" {
+			paths = []string{filepath.Join(dir, "vifmrc")}
+		}
+		for _, path := range paths {
+			rc, err := os.ReadFile(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return "", err
+			}
+			return migrateVifm(string(rc)), nil
+		}
+		return "", fmt.Errorf("no configuration found in %s", strings.Join(paths, ", "))
+	case "nnn":
+		return migrateNnn(os.Getenv), nil
+	default:
+		return "", fmt.Errorf("unknown file manager: %s (should be 'ranger', 'vifm' or 'nnn')", from)
+	}
+}
+
+// This function converts key names such as '<C-x>', '<CR>' or '<F1>' used by
+// ranger and vifm to the ones used by lf.
+func migrateKey(key string) string {
+	return reMigrateKey.ReplaceAllStringFunc(key, func(s string) string {
+		name := strings.ToLower(s[1 : len(s)-1])
+		switch {
+		case name == "cr" || name == "return":
+			return "<enter>"
+		case name == "bs":
+			return "<backspace>"
+		case name == "del":
+			return "<delete>"
+		case name == "pageup":
+			return "<pgup>"
+		case name == "pagedown":
+			return "<pgdn>"
+		case strings.HasPrefix(name, "m-"):
+			return "<a-" + name[2:] + ">"
+		case len(name) > 1 && name[0] == 'f' && strings.Trim(name[1:], "0123456789") == "":
+			return "<f-" + name[1:] + ">"
+		}
+		return "<" + name + ">"
+	})
+}
+
+func migrateRanger(rc, rifle string) string {
+	m := new(migrator)
+
+	m.printf("# generated by 'lf -migrate-config ranger'\n")
+
+	scanner := bufio.NewScanner(strings.NewReader(rc))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		word, rest := splitWord(line)
+		switch word {
+		case "set":
+			if opt, name := splitWord(rest); opt == "colorscheme" {
+				m.warn("ranger colorscheme '%s' is Python code and can not be converted, set colors in the colors file instead (see the COLORS section in 'lf -doc')", name)
+				m.unsupported(line)
+			} else if s, ok := migrateRangerSet(rest); ok {
+				m.printf("%s\n", s)
+			} else {
+				m.unsupported(line)
+			}
+		case "map":
+			key, cmd := splitWord(rest)
+			if s, ok := migrateRangerCmd(cmd); ok {
+				m.printf("map %s %s\n", migrateKey(key), s)
+			} else {
+				m.unsupported(line)
+			}
+		case "unmap":
+			m.printf("map %s\n", migrateKey(rest))
+		default:
+			m.unsupported(line)
+		}
+	}
+
+	scanner = bufio.NewScanner(strings.NewReader(rifle))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if rule, ok := migrateRifleRule(line); ok {
+			m.openers = append(m.openers, rule)
+		} else {
+			m.unsupported(line)
+		}
+	}
+
+	m.writeOpen()
+
+	return m.b.String()
+}
+
+func migrateRangerSet(s string) (string, bool) {
+	opt, val := splitWord(s)
+
+	boolOpt := func(name string) (string, bool) {
+		switch val {
+		case "true":
+			return "set " + name, true
+		case "false":
+			return "set no" + name, true
+		}
+		return "", false
+	}
+
+	switch opt {
+	case "show_hidden":
+		return boolOpt("hidden")
+	case "sort_reverse":
+		return boolOpt("reverse")
+	case "sort_directories_first":
+		return boolOpt("dirfirst")
+	case "sort_case_insensitive":
+		return boolOpt("ignorecase")
+	case "preview_files":
+		return boolOpt("preview")
+	case "wrap_scroll":
+		return boolOpt("wrapscroll")
+	case "mouse_enabled":
+		return boolOpt("mouse")
+	case "automatically_count_files":
+		return boolOpt("dircounts")
+	case "draw_borders":
+		if val == "none" || val == "false" {
+			return "set nodrawbox", true
+		}
+		return "set drawbox", true
+	case "column_ratios":
+		return "set ratios " + strings.ReplaceAll(val, ",", ":"), true
+	case "scroll_offset":
+		return "set scrolloff " + val, true
+	case "line_numbers":
+		switch val {
+		case "false":
+			return "set nonumber", true
+		case "absolute":
+			return "set number", true
+		case "relative":
+			return "set relativenumber", true
+		}
+	case "sort":
+		sorts := map[string]string{
+			"natural":   "natural",
+			"basename":  "name",
+			"size":      "size",
+			"mtime":     "time",
+			"atime":     "atime",
+			"ctime":     "ctime",
+			"extension": "ext",
+		}
+		if sort, ok := sorts[val]; ok {
+			return "set sortby " + sort, true
+		}
+	}
+
+	return "", false
+}
+
+func migrateRangerCmd(s string) (string, bool) {
+	if strings.HasPrefix(s, "chain ") {
+		var cmds []string
+		for _, c := range strings.Split(strings.TrimPrefix(s, "chain "), ";") {
+			cmd, ok := migrateRangerCmd(strings.TrimSpace(c))
+			if !ok {
+				return "", false
+			}
+			cmds = append(cmds, cmd)
+		}
+		return ":" + strings.Join(cmds, "; "), true
+	}
+
+	word, rest := splitWord(s)
+
+	args := make(map[string]string)
+	for _, arg := range strings.Fields(rest) {
+		if kv := reRangerKeyValue.FindStringSubmatch(arg); kv != nil {
+			args[kv[1]] = kv[2]
+		}
+	}
+
+	simple := map[string]string{
+		"quit":               "quit",
+		"quitall":            "quit",
+		"copy":               "copy",
+		"cut":                "cut",
+		"uncut":              "clear",
+		"paste":              "paste",
+		"delete":             "delete",
+		"rename_append":      "rename",
+		"reload_cwd":         "reload",
+		"redraw_window":      "redraw",
+		"open_with":          "open",
+		"toggle_visual_mode": "visual",
+		"filter":             "filter",
+		"mark_files":         "toggle",
+	}
+
+	switch word {
+	case "move":
+		pages := args["pages"] == "True" || args["pages"] == "true"
+		switch {
+		case args["to"] == "0":
+			return "top", true
+		case args["to"] == "-1":
+			return "bottom", true
+		case args["up"] == "1" && pages:
+			return "page-up", true
+		case args["down"] == "1" && pages:
+			return "page-down", true
+		case args["up"] == "0.5" && pages:
+			return "half-up", true
+		case args["down"] == "0.5" && pages:
+			return "half-down", true
+		case args["up"] == "1":
+			return "up", true
+		case args["down"] == "1":
+			return "down", true
+		case args["left"] == "1":
+			return "updir", true
+		case args["right"] == "1":
+			return "open", true
+		}
+	case "cd":
+		return "cd " + rest, true
+	case "search_next":
+		if args["forward"] == "False" {
+			return "search-prev", true
+		}
+		return "search-next", true
+	case "mark_files":
+		if args["all"] == "True" && args["toggle"] == "True" {
+			return "invert", true
+		}
+		if args["toggle"] == "True" {
+			return "toggle", true
+		}
+	case "toggle_option":
+		if s, ok := migrateRangerSet(rest + " true"); ok {
+			return s + "!", true
+		}
+	case "set":
+		return migrateRangerSet(rest)
+	case "console":
+		cmd := strings.ReplaceAll(rest, "%space", "<space>")
+		if strings.HasPrefix(cmd, "shell ") {
+			return "push $" + migrateRangerMacros(strings.TrimPrefix(cmd, "shell ")), true
+		}
+		return "push :" + cmd, true
+	case "shell":
+		prefix := "$"
+		cmd := rest
+		for {
+			flag, r := splitWord(cmd)
+			if !strings.HasPrefix(flag, "-") {
+				break
+			}
+			if strings.Contains(flag, "f") {
+				prefix = "&"
+			} else if strings.Contains(flag, "w") {
+				prefix = "!"
+			} else if strings.Contains(flag, "p") {
+				prefix = "%"
+			}
+			cmd = r
+		}
+		return prefix + migrateRangerMacros(cmd), true
+	default:
+		if cmd, ok := simple[word]; ok && rest == "" {
+			return cmd, true
+		}
+	}
+
+	return "", false
+}
+
+// This function terminates an opener command so that the rest of the rules are
+// skipped, optionally running the command in the background.
+func migrateExit(cmd string, fork bool) string {
+	if fork {
+		return cmd + " >/dev/null 2>&1 & exit"
+	}
+	return cmd + "; exit"
+}
+
+func migrateRangerMacros(s string) string {
+	r := strings.NewReplacer("%s", "$fx", "%f", `"$f"`, "%d", `"$PWD"`)
+	return r.Replace(s)
+}
+
+// rifle rules are written as a comma-separated list of conditions followed by
+// an equal sign and a command, and the first rule with all conditions met is
+// used
+func migrateRifleRule(line string) (string, bool) {
+	conds, cmd, found := strings.Cut(line, "=")
+	if !found {
+		return "", false
+	}
+	cmd = strings.TrimSpace(cmd)
+
+	var tests []string
+	fork := false
+	for _, cond := range strings.Split(conds, ",") {
+		cond = strings.TrimSpace(cond)
+		neg := strings.HasPrefix(cond, "!")
+		cond = strings.TrimPrefix(cond, "!")
+		word, arg := splitWord(cond)
+
+		var test string
+		switch word {
+		case "ext":
+			test = fmt.Sprintf(`printf '%%s\n' "$1" | grep -Eiq '\.(%s)$'`, arg)
+		case "mime":
+			test = fmt.Sprintf(`printf '%%s\n' "$mime" | grep -Eq '%s'`, arg)
+		case "name":
+			test = fmt.Sprintf(`printf '%%s\n' "${1##*/}" | grep -Eq '%s'`, arg)
+		case "match":
+			test = fmt.Sprintf(`printf '%%s\n' "$1" | grep -Eq '%s'`, arg)
+		case "has":
+			test = fmt.Sprintf(`command -v %s >/dev/null`, arg)
+		case "env":
+			test = fmt.Sprintf(`[ -n "$%s" ]`, arg)
+		case "directory":
+			test = `[ -d "$1" ]`
+		case "file":
+			test = `[ -f "$1" ]`
+		case "X":
+			test = `[ -n "$DISPLAY$WAYLAND_DISPLAY" ]`
+		case "flag":
+			fork = strings.Contains(arg, "f")
+			continue
+		case "label", "number", "terminal", "else":
+			continue
+		default:
+			return "", false
+		}
+
+		if neg {
+			test = "! " + test
+		}
+		tests = append(tests, test)
+	}
+
+	cmd = migrateExit(cmd, fork)
+
+	if len(tests) == 0 {
+		return cmd, true
+	}
+
+	return fmt.Sprintf("if %s; then %s; fi", strings.Join(tests, " && "), cmd), true
+}
+
+func migrateVifm(rc string) string {
+	m := new(migrator)
+
+	m.printf("# generated by 'lf -migrate-config vifm'\n")
+
+	scanner := bufio.NewScanner(strings.NewReader(rc))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, `"`) {
+			continue
+		}
+
+		word, rest := splitWord(line)
+		switch word {
+		case "set", "se":
+			ok := true
+			for _, opt := range strings.Fields(rest) {
+				if s, found := migrateVifmSet(opt); found {
+					m.printf("%s\n", s)
+				} else {
+					ok = false
+				}
+			}
+			if !ok {
+				m.unsupported(line)
+			}
+		case "nnoremap", "nmap", "nnoremap!", "map", "noremap", "nn", "nm":
+			key, rhs := splitWord(rest)
+			if s, ok := migrateVifmMap(rhs); ok {
+				m.printf("map %s %s\n", migrateKey(key), s)
+			} else {
+				m.unsupported(line)
+			}
+		case "filetype", "filextype", "filet", "filex":
+			if rule, ok := migrateVifmFiletype(rest); ok {
+				m.openers = append(m.openers, rule...)
+			} else {
+				m.unsupported(line)
+			}
+		case "colorscheme", "colo":
+			m.warn("vifm colorscheme '%s' is not converted, copy its highlight commands to vifmrc to convert them", rest)
+			m.unsupported(line)
+		case "highlight", "hi":
+			if entries, ok := migrateVifmHighlight(rest); ok {
+				m.colors = append(m.colors, entries...)
+			} else {
+				m.unsupported(line)
+			}
+		default:
+			m.unsupported(line)
+		}
+	}
+
+	m.writeOpen()
+	m.writeColors()
+
+	return m.b.String()
+}
+
+func migrateVifmSet(opt string) (string, bool) {
+	val := ""
+	if kv := reVifmOptAssign.FindStringSubmatch(opt); kv != nil {
+		opt, val = kv[1], kv[2]
+	}
+
+	bools := map[string]string{
+		"dotfiles":       "hidden",
+		"number":         "number",
+		"nu":             "number",
+		"relativenumber": "relativenumber",
+		"rnu":            "relativenumber",
+		"wrapscan":       "wrapscan",
+		"ws":             "wrapscan",
+		"ignorecase":     "ignorecase",
+		"ic":             "ignorecase",
+		"smartcase":      "smartcase",
+		"scs":            "smartcase",
+		"incsearch":      "incsearch",
+		"is":             "incsearch",
+	}
+
+	if val == "" {
+		if name, ok := bools[opt]; ok {
+			return "set " + name, true
+		}
+		if name, ok := bools[strings.TrimPrefix(opt, "no")]; ok {
+			return "set no" + name, true
+		}
+		return "", false
+	}
+
+	switch opt {
+	case "scrolloff", "so":
+		return "set scrolloff " + val, true
+	case "vicmd":
+		return fmt.Sprintf(`map e $%s "$f"`, strings.TrimSuffix(val, " &")), true
+	case "sort":
+		sort := strings.Split(val, ",")[0]
+		reverse := strings.HasPrefix(sort, "-")
+		sort = strings.TrimLeft(sort, "+-")
+		sorts := map[string]string{
+			"name":  "name",
+			"iname": "name",
+			"size":  "size",
+			"mtime": "time",
+			"atime": "atime",
+			"ctime": "ctime",
+			"ext":   "ext",
+		}
+		name, ok := sorts[sort]
+		if !ok {
+			return "", false
+		}
+		if reverse {
+			return fmt.Sprintf("set sortby %s; set reverse", name), true
+		}
+		return fmt.Sprintf("set sortby %s; set noreverse", name), true
+	}
+
+	return "", false
+}
+
+// This function converts a color of a vifm highlight command (i.e. a color
+// name or a number) to the code used in the colors file, with the given offset
+// for the foreground (30) or the background (40).
+func migrateVifmColor(val string, offset int) (string, bool) {
+	names := []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+	n := slices.Index(names, strings.ToLower(val))
+	if n < 0 {
+		var err error
+		if n, err = strconv.Atoi(val); err != nil || n < 0 || n > 255 {
+			return "", false
+		}
+	}
+
+	switch {
+	case n < 8:
+		return strconv.Itoa(offset + n), true
+	case n < 16:
+		return strconv.Itoa(offset + 60 + n - 8), true
+	}
+	return fmt.Sprintf("%d;5;%d", offset+8, n), true
+}
+
+// This function converts a vifm highlight command of a group with a
+// counterpart in lf (e.g. 'Directory cterm=bold ctermfg=blue') to entries of
+// the colors file (e.g. 'di 1;34').
+func migrateVifmHighlight(s string) ([]string, bool) {
+	groups := map[string][]string{
+		"Directory":  {"di"},
+		"Link":       {"ln"},
+		"BrokenLink": {"or"},
+		"Executable": {"ex"},
+		"Socket":     {"so"},
+		"Fifo":       {"pi"},
+		"Device":     {"bd", "cd"},
+	}
+	attrs := map[string]string{
+		"bold":      "1",
+		"italic":    "3",
+		"underline": "4",
+		"reverse":   "7",
+		"inverse":   "7",
+	}
+
+	group, rest := splitWord(s)
+	keys, ok := groups[group]
+	if !ok {
+		return nil, false
+	}
+
+	var codes []string
+	for _, arg := range strings.Fields(rest) {
+		key, val, _ := strings.Cut(arg, "=")
+		switch key {
+		case "cterm":
+			for _, attr := range strings.Split(val, ",") {
+				if code, ok := attrs[attr]; ok {
+					codes = append(codes, code)
+				} else if attr != "none" {
+					return nil, false
+				}
+			}
+		case "ctermfg", "ctermbg":
+			if val == "default" || val == "-1" {
+				continue
+			}
+			offset := 30
+			if key == "ctermbg" {
+				offset = 40
+			}
+			code, ok := migrateVifmColor(val, offset)
+			if !ok {
+				return nil, false
+			}
+			codes = append(codes, code)
+		case "gui", "guifg", "guibg":
+		default:
+			return nil, false
+		}
+	}
+	if len(codes) == 0 {
+		codes = []string{"0"}
+	}
+
+	var entries []string
+	for _, key := range keys {
+		entries = append(entries, key+" "+strings.Join(codes, ";"))
+	}
+	return entries, true
+}
+
+func migrateVifmMacros(s string) string {
+	s = reVifmMacro.ReplaceAllString(s, "")
+	r := strings.NewReplacer("%c", `"$f"`, "%f", "$fx", "%d", `"$PWD"`, "%%", "%")
+	return r.Replace(s)
+}
+
+func migrateVifmCmd(s string) (string, bool) {
+	switch {
+	case strings.HasPrefix(s, "!!"):
+		return "!" + migrateVifmMacros(s[2:]), true
+	case strings.HasPrefix(s, "!"):
+		cmd := migrateVifmMacros(s[1:])
+		if strings.HasSuffix(cmd, "&") {
+			return "&" + strings.TrimSpace(strings.TrimSuffix(cmd, "&")), true
+		}
+		return "$" + cmd, true
+	}
+
+	word, rest := splitWord(s)
+	switch word {
+	case "cd":
+		return "cd " + rest, true
+	case "q", "quit", "qa", "qall":
+		return "quit", true
+	case "file", "f":
+		return "open", true
+	case "delete", "d":
+		return "delete", true
+	case "yank", "y":
+		return "copy", true
+	case "put", "p":
+		return "paste", true
+	case "rename":
+		return "rename", true
+	}
+
+	return "", false
+}
+
+func migrateVifmMap(rhs string) (string, bool) {
+	if !strings.HasPrefix(rhs, ":") {
+		return "push " + rhs, true
+	}
+
+	var cmds []string
+	for _, c := range strings.Split(rhs, "<cr>") {
+		c = strings.TrimSpace(strings.TrimPrefix(c, ":"))
+		if c == "" {
+			continue
+		}
+		cmd, ok := migrateVifmCmd(c)
+		if !ok {
+			return "", false
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	switch len(cmds) {
+	case 0:
+		return "", false
+	case 1:
+		return cmds[0], true
+	}
+
+	return ":" + strings.Join(cmds, "; "), true
+}
+
+// vifm file types are written as a list of glob patterns in braces and mime
+// types in angle brackets followed by a list of alternative commands, and only
+// the first command is used
+func migrateVifmFiletype(s string) ([]string, bool) {
+	match := reVifmFiletype.FindStringSubmatch(s)
+	if match == nil {
+		return nil, false
+	}
+
+	cmd := strings.TrimSpace(strings.Split(match[2], ",")[0])
+	cmd = reVifmAltDesc.ReplaceAllString(cmd, "")
+	fork := strings.HasSuffix(cmd, "&")
+	cmd = strings.TrimSpace(strings.TrimSuffix(cmd, "&"))
+	if !strings.Contains(cmd, "%") {
+		cmd += " %f"
+	}
+	cmd = migrateExit(strings.ReplaceAll(migrateVifmMacros(cmd), "$fx", `"$@"`), fork)
+
+	var globs, mimes []string
+	for _, pat := range regexp.MustCompile(`\{[^}]*\}|<[^>]*>|[^,{}<>]+`).FindAllString(match[1], -1) {
+		switch {
+		case strings.HasPrefix(pat, "{"):
+			globs = append(globs, strings.Split(strings.Trim(pat, "{}"), ",")...)
+		case strings.HasPrefix(pat, "<"):
+			mimes = append(mimes, strings.Split(strings.Trim(pat, "<>"), ",")...)
+		default:
+			globs = append(globs, pat)
+		}
+	}
+
+	var rules []string
+	if len(globs) > 0 {
+		rules = append(rules, fmt.Sprintf(`case "${1##*/}" in %s) %s ;; esac`, strings.Join(globs, "|"), cmd))
+	}
+	if len(mimes) > 0 {
+		rules = append(rules, fmt.Sprintf(`case "$mime" in %s) %s ;; esac`, strings.Join(mimes, "|"), cmd))
+	}
+
+	return rules, len(rules) > 0
+}
+
+func migrateNnn(getenv func(string) string) string {
+	m := new(migrator)
+
+	m.printf("# generated by 'lf -migrate-config nnn'\n")
+
+	for _, opt := range getenv("NNN_OPTS") {
+		switch opt {
+		case 'H':
+			m.printf("set hidden\n")
+		case 'd':
+			m.printf("set info size:time\n")
+		case 'e':
+			m.printf("map l $$EDITOR \"$f\"\n")
+		case 'U':
+			m.printf("set info user:group\n")
+		default:
+			m.unsupported(fmt.Sprintf("NNN_OPTS flag '%c'", opt))
+		}
+	}
+
+	for _, bm := range strings.Split(getenv("NNN_BMS"), ";") {
+		key, path, found := strings.Cut(bm, ":")
+		if !found {
+			continue
+		}
+		m.printf("map b%s cd %s\n", key, path)
+	}
+
+	if opener := getenv("NNN_OPENER"); opener != "" {
+		m.openers = append(m.openers, fmt.Sprintf(`%s "$@"; exit`, opener))
+	}
+
+	for _, plug := range strings.Split(getenv("NNN_PLUG"), ";") {
+		if plug != "" {
+			m.unsupported("NNN_PLUG " + plug)
+		}
+	}
+
+	m.writeOpen()
+
+	return m.b.String()
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMigrateKey(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+	}{
+		{"j", "j"},
+		{"<C-f>", "<c-f>"},
+		{"<CR>", "<enter>"},
+		{"<F1>", "<f-1>"},
+		{"<F12>", "<f-12>"},
+		{"<M-x>", "<a-x>"},
+		{"g<space>", "g<space>"},
+		{"<PAGEDOWN>", "<pgdn>"},
+	}
+
+	for _, test := range tests {
+		if got := migrateKey(test.s); got != test.exp {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.s, test.exp, got)
+		}
+	}
+}
+
+func TestMigrateRangerCmd(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+		ok  bool
+	}{
+		{"move up=1", "up", true},
+		{"move down=0.5 pages=True", "half-down", true},
+		{"move to=-1", "bottom", true},
+		{"move left=1", "updir", true},
+		{"cd ~/Downloads", "cd ~/Downloads", true},
+		{"shell -f vlc %s", "&vlc $fx", true},
+		{"shell -w du -sh %f", `!du -sh "$f"`, true},
+		{"shell chmod +x %s", "$chmod +x $fx", true},
+		{"console mkdir%space", "push :mkdir<space>", true},
+		{"toggle_option show_hidden", "set hidden!", true},
+		{"set sort=mtime", "", false},
+		{"set sort mtime", "set sortby time", true},
+		{"chain set sort mtime; set sort_reverse true", ":set sortby time; set reverse", true},
+		{"search_next forward=False", "search-prev", true},
+		{"mark_files all=True toggle=True", "invert", true},
+		{"paste", "paste", true},
+		{"tab_new", "", false},
+	}
+
+	for _, test := range tests {
+		if got, ok := migrateRangerCmd(test.s); got != test.exp || ok != test.ok {
+			t.Errorf("at input '%s' expected '%s' (%t) but got '%s' (%t)", test.s, test.exp, test.ok, got, ok)
+		}
+	}
+}
+
+func TestMigrateRifleRule(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+		ok  bool
+	}{
+		{
+			`ext pdf, has zathura, X, flag f = zathura -- "$@"`,
+			`if printf '%s\n' "$1" | grep -Eiq '\.(pdf)$' && command -v zathura >/dev/null && [ -n "$DISPLAY$WAYLAND_DISPLAY" ]; then zathura -- "$@" >/dev/null 2>&1 & exit; fi`,
+			true,
+		},
+		{
+			`mime ^text, !directory = $EDITOR -- "$@"`,
+			`if printf '%s\n' "$mime" | grep -Eq '^text' && ! [ -d "$1" ]; then $EDITOR -- "$@"; exit; fi`,
+			true,
+		},
+		{
+			`label open, has xdg-open = xdg-open "$@"`,
+			`if command -v xdg-open >/dev/null; then xdg-open "$@"; exit; fi`,
+			true,
+		},
+		{`else = less "$@"`, `less "$@"; exit`, true},
+		{`path foo = bar`, "", false},
+		{`no equal sign`, "", false},
+	}
+
+	for _, test := range tests {
+		if got, ok := migrateRifleRule(test.s); got != test.exp || ok != test.ok {
+			t.Errorf("at input '%s' expected '%s' (%t) but got '%s' (%t)", test.s, test.exp, test.ok, got, ok)
+		}
+	}
+}
+
+func TestMigrateRanger(t *testing.T) {
+	rc := `# comment
+set show_hidden true
+set column_ratios 1,3,4
+map J move down=0.5 pages=True
+map <C-r> reload_cwd
+set colorscheme solarized
+tab_new
+`
+	rifle := `ext pdf = zathura "$@"`
+
+	exp := `# generated by 'lf -migrate-config ranger'
+set hidden
+set ratios 1:3:4
+map J half-down
+map <c-r> reload
+# warning: ranger colorscheme 'solarized' is Python code and can not be converted, set colors in the colors file instead (see the COLORS section in 'lf -doc')
+# unsupported: set colorscheme solarized
+# unsupported: tab_new
+
+cmd open ${{
+    set -f
+    IFS='
+'
+    set -- $fx
+    mime=$(file --mime-type -Lb -- "$1")
+    if printf '%s\n' "$1" | grep -Eiq '\.(pdf)$'; then zathura "$@"; exit; fi
+    $OPENER "$@"
+}}
+`
+
+	if got := migrateRanger(rc, rifle); got != exp {
+		t.Errorf("expected:\n%s\nbut got:\n%s", exp, got)
+	}
+}
+
+func TestMigrateVifmSet(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+		ok  bool
+	}{
+		{"dotfiles", "set hidden", true},
+		{"nowrapscan", "set nowrapscan", true},
+		{"scrolloff=4", "set scrolloff 4", true},
+		{"sort=-mtime", "set sortby time; set reverse", true},
+		{"vicmd=nvim", `map e $nvim "$f"`, true},
+		{"syscalls", "", false},
+	}
+
+	for _, test := range tests {
+		if got, ok := migrateVifmSet(test.s); got != test.exp || ok != test.ok {
+			t.Errorf("at input '%s' expected '%s' (%t) but got '%s' (%t)", test.s, test.exp, test.ok, got, ok)
+		}
+	}
+}
+
+func TestMigrateVifmMap(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+		ok  bool
+	}{
+		{":!git status<cr>", "$git status", true},
+		{":!!du -sh %c<cr>", `!du -sh "$f"`, true},
+		{":!mpv %f &<cr>", "&mpv $fx", true},
+		{":cd ~/src<cr>", "cd ~/src", true},
+		{"gg", "push gg", true},
+		{":tabnew<cr>", "", false},
+	}
+
+	for _, test := range tests {
+		if got, ok := migrateVifmMap(test.s); got != test.exp || ok != test.ok {
+			t.Errorf("at input '%s' expected '%s' (%t) but got '%s' (%t)", test.s, test.exp, test.ok, got, ok)
+		}
+	}
+}
+
+func TestMigrateVifmFiletype(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp []string
+	}{
+		{
+			"*.pdf {View in zathura} zathura %c %i &, evince",
+			[]string{`case "${1##*/}" in *.pdf) zathura "$f" >/dev/null 2>&1 & exit ;; esac`},
+		},
+		{
+			"{*.jpg,*.png},<image/*> sxiv",
+			[]string{
+				`case "${1##*/}" in *.jpg|*.png) sxiv "$@"; exit ;; esac`,
+				`case "$mime" in image/*) sxiv "$@"; exit ;; esac`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got, ok := migrateVifmFiletype(test.s)
+		if !ok || strings.Join(got, "\n") != strings.Join(test.exp, "\n") {
+			t.Errorf("at input '%s' expected '%q' but got '%q'", test.s, test.exp, got)
+		}
+	}
+}
+
+func TestMigrateVifmHighlight(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp []string
+		ok  bool
+	}{
+		{"Directory cterm=bold ctermfg=blue ctermbg=default", []string{"di 1;34"}, true},
+		{"Link ctermfg=14 guifg=#00ffff", []string{"ln 96"}, true},
+		{"Device cterm=none ctermfg=214 ctermbg=black", []string{"bd 38;5;214;40", "cd 38;5;214;40"}, true},
+		{"Executable cterm=none", []string{"ex 0"}, true},
+		{"Border ctermfg=red", nil, false},
+		{"Fifo ctermfg=orange", nil, false},
+	}
+
+	for _, test := range tests {
+		if got, ok := migrateVifmHighlight(test.s); !slices.Equal(got, test.exp) || ok != test.ok {
+			t.Errorf("at input '%s' expected '%v' (%t) but got '%v' (%t)", test.s, test.exp, test.ok, got, ok)
+		}
+	}
+
+	rc := `colorscheme Default
+highlight Directory cterm=bold ctermfg=blue
+`
+	exp := `# generated by 'lf -migrate-config vifm'
+# warning: vifm colorscheme 'Default' is not converted, copy its highlight commands to vifmrc to convert them
+# unsupported: colorscheme Default
+
+# colors converted from highlight groups, to be copied to the colors file
+# (see the COLORS section in 'lf -doc'):
+#   di 1;34
+`
+
+	if got := migrateVifm(rc); got != exp {
+		t.Errorf("expected:\n%s\nbut got:\n%s", exp, got)
+	}
+}
+
+func TestMigrateNnn(t *testing.T) {
+	env := map[string]string{
+		"NNN_OPTS": "Hd",
+		"NNN_BMS":  "d:~/Documents;D:~/Downloads",
+		"NNN_PLUG": "p:preview-tui",
+	}
+
+	exp := `# generated by 'lf -migrate-config nnn'
+set hidden
+set info size:time
+map bd cd ~/Documents
+map bD cd ~/Downloads
+# unsupported: NNN_PLUG p:preview-tui
+`
+
+	if got := migrateNnn(func(k string) string { return env[k] }); got != exp {
+		t.Errorf("expected:\n%s\nbut got:\n%s", exp, got)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"log"
+	"math"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -165,9 +168,17 @@ func splitWord(s string) (word, rest string) {
 // used to add a comment until the end of line. Leading and trailing space is
 // trimmed. Empty lines are skipped.
 func readArrays(r io.Reader, min_cols, max_cols int) ([][]string, error) {
+	arrays, _, err := readArrayLines(r, min_cols, max_cols)
+	return arrays, err
+}
+
+// This function is the same as 'readArrays' but it also returns the line
+// numbers of the arrays to be able to refer to them in messages.
+func readArrayLines(r io.Reader, min_cols, max_cols int) ([][]string, []int, error) {
 	var arrays [][]string
+	var lines []int
 	s := bufio.NewScanner(r)
-	for s.Scan() {
+	for n := 1; s.Scan(); n++ {
 		line := s.Text()
 
 		squote, dquote := false, false
@@ -202,9 +213,9 @@ func readArrays(r io.Reader, min_cols, max_cols int) ([][]string, error) {
 
 		if arrlen < min_cols || arrlen > max_cols {
 			if min_cols == max_cols {
-				return nil, fmt.Errorf("expected %d columns but found: %s", min_cols, s.Text())
+				return nil, nil, fmt.Errorf("expected %d columns but found: %s", min_cols, s.Text())
 			}
-			return nil, fmt.Errorf("expected %d~%d columns but found: %s", min_cols, max_cols, s.Text())
+			return nil, nil, fmt.Errorf("expected %d~%d columns but found: %s", min_cols, max_cols, s.Text())
 		}
 
 		for i := range arrlen {
@@ -225,9 +236,10 @@ func readArrays(r io.Reader, min_cols, max_cols int) ([][]string, error) {
 		}
 
 		arrays = append(arrays, arr)
+		lines = append(lines, n)
 	}
 
-	return arrays, nil
+	return arrays, lines, nil
 }
 
 func readPairs(r io.Reader) ([][]string, error) {
@@ -268,6 +280,99 @@ func humanize(size int64) string {
 	return ""
 }
 
+// This function parses the translation table of keys in the format of the
+// 'langmap' option of Vim. The table consists of parts separated with commas,
+// where each part either consists of pairs of characters (e.g. 'йqцw') or of
+// two equal length sequences separated with a semicolon (e.g. 'йц;qw').
+// Special characters can be escaped with backslashes.
+func parseKeyTranslate(s string) (map[rune]rune, error) {
+	table := make(map[rune]rune)
+
+	var from, to []rune
+	semicolon := false
+	flush := func() error {
+		switch {
+		case semicolon && len(from) != len(to):
+			return fmt.Errorf("unequal number of characters: %s;%s", string(from), string(to))
+		case semicolon:
+			for i := range from {
+				table[from[i]] = to[i]
+			}
+		case len(from)%2 != 0:
+			return fmt.Errorf("odd number of characters: %s", string(from))
+		default:
+			for i := 0; i < len(from); i += 2 {
+				table[from[i]] = from[i+1]
+			}
+		}
+		from, to, semicolon = nil, nil, false
+		return nil
+	}
+
+	escape := false
+	for _, r := range s {
+		switch {
+		case escape:
+			escape = false
+		case r == '\\':
+			escape = true
+			continue
+		case r == ';' && semicolon:
+			return nil, fmt.Errorf("more than one semicolon in a part")
+		case r == ';':
+			semicolon = true
+			continue
+		case r == ',':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if semicolon {
+			to = append(to, r)
+		} else {
+			from = append(from, r)
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// This function parses a size with an optional metric suffix as printed by
+// 'humanize' (e.g. '10G' or '1.5M') and returns it in bytes.
+func parseSize(s string) (int64, error) {
+	num := strings.TrimSuffix(strings.ToUpper(s), "B")
+
+	mult := 1.0
+	if num != "" {
+		if i := strings.IndexByte("KMGTPEZY", num[len(num)-1]); i >= 0 {
+			mult = math.Pow(1000, float64(i+1))
+			num = num[:len(num)-1]
+		}
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	return int64(f * mult), nil
+}
+
+func diskFree(wd string) string {
+	avail, err := diskAvail(wd)
+	if err != nil {
+		log.Printf("diskfree: %s", err)
+		return ""
+	}
+	return "df: " + humanize(avail)
+}
+
 // This function compares two strings for natural sorting which takes into
 // account values of numbers in strings. For example, '2' is less than '10',
 // and similarly 'foo2bar' is less than 'foo10bar', but 'bar2bar' is greater
@@ -415,6 +520,63 @@ func stripAnsi(s string) string {
 	return b.String()
 }
 
+// This function quotes a string for POSIX shells by surrounding it with single
+// quotes and escaping single quotes inside.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// This function formats the last directory according to the
+// '-last-dir-format' command line flag.
+func formatLastDir(path, format string) string {
+	switch format {
+	case "quoted":
+		return shellQuote(path)
+	case "cd-command":
+		return "cd " + shellQuote(path)
+	default:
+		return path
+	}
+}
+
+// This function expands the placeholders in the '-last-dir-path' command line
+// flag, so that concurrent instances (e.g. in different tmux panes) can write
+// to different files. '%i' is replaced with the client id, '%p' with the tmux
+// pane (i.e. '$TMUX_PANE'), and '%%' with a percent sign. Other placeholders
+// are kept as they are.
+func expandLastDirPath(path string, id int, pane string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] != '%' || i+1 == len(path) {
+			b.WriteByte(path[i])
+			continue
+		}
+		switch path[i+1] {
+		case 'i':
+			b.WriteString(strconv.Itoa(id))
+		case 'p':
+			b.WriteString(pane)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteString(path[i : i+2])
+		}
+		i++
+	}
+	return replaceTilde(b.String())
+}
+
+// This function returns an OSC 7 escape sequence to notify the terminal about
+// the current working directory.
+func osc7(hostname, path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Host: hostname, Path: path}
+	return "\033]7;" + u.String() + "\033\\"
+}
+
 // We don't need no generic code
 // We don't need no type control
 // No dark templates in compiler
@@ -274,6 +274,37 @@ func TestHumanize(t *testing.T) {
 	}
 }
 
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp int64
+		err bool
+	}{
+		{"0", 0, false},
+		{"999", 999, false},
+		{"999B", 999, false},
+		{"10K", 10000, false},
+		{"1.5M", 1500000, false},
+		{"10g", 10000000000, false},
+		{"2T", 2000000000000, false},
+		{"", 0, true},
+		{"B", 0, true},
+		{"G", 0, true},
+		{"-1", 0, true},
+		{"10X", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseSize(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("at input '%s' expected error '%t' but got '%v'", test.s, test.err, err)
+		}
+		if got != test.exp {
+			t.Errorf("at input '%s' expected '%d' but got '%d'", test.s, test.exp, got)
+		}
+	}
+}
+
 func TestNaturalLess(t *testing.T) {
 	tests := []struct {
 		s1  string
@@ -418,3 +449,90 @@ func TestStripAnsi(t *testing.T) {
 		}
 	}
 }
+
+func TestOsc7(t *testing.T) {
+	tests := []struct {
+		hostname string
+		path     string
+		exp      string
+	}{
+		{"host", "/home/user", "\033]7;file://host/home/user\033\\"},
+		{"host", "/tmp/foo bar", "\033]7;file://host/tmp/foo%20bar\033\\"},
+		{"", "/tmp/100%", "\033]7;file:///tmp/100%25\033\\"},
+	}
+
+	for _, test := range tests {
+		if got := osc7(test.hostname, test.path); got != test.exp {
+			t.Errorf("at input (%q, %q) expected %q but got %q", test.hostname, test.path, test.exp, got)
+		}
+	}
+}
+
+func TestFormatLastDir(t *testing.T) {
+	tests := []struct {
+		path   string
+		format string
+		exp    string
+	}{
+		{"/home/user", "raw", "/home/user"},
+		{"/home/user", "quoted", "'/home/user'"},
+		{"/home/user", "cd-command", "cd '/home/user'"},
+		{"/tmp/it's here", "raw", "/tmp/it's here"},
+		{"/tmp/it's here", "quoted", `'/tmp/it'\''s here'`},
+		{"/tmp/$(rm -rf)", "cd-command", "cd '/tmp/$(rm -rf)'"},
+	}
+
+	for _, test := range tests {
+		if got := formatLastDir(test.path, test.format); got != test.exp {
+			t.Errorf("at input (%q, %q) expected %q but got %q", test.path, test.format, test.exp, got)
+		}
+	}
+}
+
+func TestExpandLastDirPath(t *testing.T) {
+	tests := []struct {
+		path string
+		pane string
+		exp  string
+	}{
+		{"/tmp/lastdir", "%1", "/tmp/lastdir"},
+		{"/tmp/lastdir-%i", "", "/tmp/lastdir-42"},
+		{"/tmp/lastdir-%p", "%1", "/tmp/lastdir-%1"},
+		{"/tmp/lastdir-%p", "", "/tmp/lastdir-"},
+		{"/tmp/100%%-%i", "", "/tmp/100%-42"},
+		{"/tmp/%x-%", "", "/tmp/%x-%"},
+	}
+
+	for _, test := range tests {
+		if got := expandLastDirPath(test.path, 42, test.pane); got != test.exp {
+			t.Errorf("at input (%q, %q) expected %q but got %q", test.path, test.pane, test.exp, got)
+		}
+	}
+}
+
+func TestParseKeyTranslate(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp map[rune]rune
+		err bool
+	}{
+		{"", map[rune]rune{}, false},
+		{"йqцw", map[rune]rune{'й': 'q', 'ц': 'w'}, false},
+		{"йц;qw", map[rune]rune{'й': 'q', 'ц': 'w'}, false},
+		{"йц;qw,αa", map[rune]rune{'й': 'q', 'ц': 'w', 'α': 'a'}, false},
+		{`\;x,\,y,ж;\;`, map[rune]rune{';': 'x', ',': 'y', 'ж': ';'}, false},
+		{"йцу", nil, true},
+		{"йц;q", nil, true},
+		{"й;q;w", nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseKeyTranslate(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("at input '%s' expected error '%t' but got '%v'", test.s, test.err, err)
+		}
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("at input '%s' expected '%v' but got '%v'", test.s, test.exp, got)
+		}
+	}
+}
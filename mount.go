@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Mountpoints are directories on a different device than their parent
+// directories. They are marked with the 'mountmarker' option in the tag column,
+// and recursive operations (i.e. 'calcdirsize' and 'delete') do not descend
+// into them when the 'onefilesystem' option is enabled, similar to the '-x'
+// flag of 'du' and 'rm --one-file-system'.
+
+// This function reports whether the given file is a mountpoint. Symbolic links
+// are never considered as mountpoints since they are not followed by recursive
+// operations either.
+func isMountPoint(lstat os.FileInfo, path string) bool {
+	if !lstat.IsDir() || lstat.Mode()&os.ModeSymlink != 0 {
+		return false
+	}
+
+	dev, ok := deviceID(lstat)
+	if !ok {
+		return false
+	}
+
+	parent, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+
+	pdev, ok := deviceID(parent)
+	return ok && dev != pdev
+}
+
+// This function returns the total size of files in the given path. Other
+// filesystems are skipped when the 'onefilesystem' option is enabled.
+func dirSize(path string) (int64, error) {
+	return walkSize(path, nil, nil)
+}
+
+// This function is the same as 'dirSize' except that the walk is stopped when
+// the given channel is closed, and the given function is called with the total
+// size so far at intervals when it is not nil.
+func walkSize(path string, cancel <-chan struct{}, update func(int64)) (int64, error) {
+	root, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	dev, checkDev := deviceID(root)
+	checkDev = checkDev && gOpts.onefilesystem
+
+	var total int64
+	last := time.Now()
+	err = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		select {
+		case <-cancel:
+			return errDirSizeCancelled
+		default:
+		}
+		if err != nil {
+			return fmt.Errorf("walk: %s", err)
+		}
+		if checkDev && info.IsDir() {
+			if d, ok := deviceID(info); ok && d != dev {
+				return filepath.SkipDir
+			}
+		}
+		total += info.Size()
+		if update != nil && time.Since(last) >= gDirSizeInterval {
+			update(total)
+			last = time.Now()
+		}
+		return nil
+	})
+
+	return total, err
+}
+
+// This function calls the given function for each file under the given
+// directory other than directories, and returns the number of walked and
+// skipped subdirectories. Other filesystems are skipped when the
+// 'onefilesystem' option is enabled, and unreadable directories are skipped
+// and counted.
+func walkFiles(path string, cancel <-chan struct{}, fn func(string, os.FileInfo)) (dirs, skipped int, err error) {
+	root, err := os.Lstat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	dev, checkDev := deviceID(root)
+	checkDev = checkDev && gOpts.onefilesystem
+
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		select {
+		case <-cancel:
+			return errDirSizeCancelled
+		default:
+		}
+		if err != nil {
+			if p == path {
+				return err
+			}
+			skipped++
+			return nil
+		}
+		if !info.IsDir() {
+			fn(p, info)
+			return nil
+		}
+		if checkDev {
+			if d, ok := deviceID(info); ok && d != dev {
+				return filepath.SkipDir
+			}
+		}
+		if p != path {
+			dirs++
+		}
+		return nil
+	})
+
+	return dirs, skipped, err
+}
+
+// This function removes the given path with its contents. Directories on other
+// filesystems than the parent directory of the path are not removed when the
+// 'onefilesystem' option is enabled, in which case an error is returned after
+// removing the rest of the contents.
+func removeAll(path string) error {
+	return errors.Join(removeTree(path, nil, nil)...)
+}
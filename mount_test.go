@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOneFileSystem(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dir")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "sub/b"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("foo"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(onefilesystem bool) { gOpts.onefilesystem = onefilesystem }(gOpts.onefilesystem)
+	gOpts.onefilesystem = true
+
+	lstat, err := os.Lstat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isMountPoint(lstat, dir) {
+		t.Errorf("at input '%s' expected no mountpoint", dir)
+	}
+
+	total, err := dirSize(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := copySize([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != copied {
+		t.Errorf("at input '%s' expected '%d' but got '%d'", dir, copied, total)
+	}
+
+	if err := removeAll(dir); err != nil {
+		t.Errorf("at input '%s' expected no error but got '%s'", dir, err)
+	}
+	if _, err := os.Lstat(dir); !os.IsNotExist(err) {
+		t.Errorf("at input '%s' expected directory to be removed", dir)
+	}
+}
+
+func TestSearchOneFileSystem(t *testing.T) {
+	root := "/dev"
+	lstat, err := os.Lstat(root)
+	if err != nil {
+		t.Skip("no /dev directory")
+	}
+	dev, ok := deviceID(lstat)
+	if !ok {
+		t.Skip("no device ids")
+	}
+
+	mount := ""
+	entries, _ := os.ReadDir(root)
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if d, ok := deviceID(info); ok && d != dev {
+			mount = filepath.Join(root, e.Name())
+			break
+		}
+	}
+	if mount == "" {
+		t.Skip("no other filesystem under /dev")
+	}
+
+	defer func(onefilesystem bool) { gOpts.onefilesystem = onefilesystem }(gOpts.onefilesystem)
+
+	for _, onefilesystem := range []bool{false, true} {
+		gOpts.onefilesystem = onefilesystem
+		w := newSearchWalker(root)
+		w.hidden = true
+
+		found := false
+		w.walk(&searchResults{}, func(path string, _ fs.DirEntry) bool {
+			if path == mount {
+				found = true
+			}
+			return true
+		})
+		if found == onefilesystem {
+			t.Errorf("at input '%s' expected '%s' to be walked %t but got %t", root, mount, !onefilesystem, found)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -15,9 +16,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/djherbis/times"
+	"github.com/gdamore/tcell/v2"
 	"golang.org/x/text/collate"
 )
 
@@ -33,6 +36,7 @@ type file struct {
 	os.FileInfo
 	linkState  linkState
 	linkTarget string
+	mountPoint bool
 	path       string
 	dirCount   int
 	dirSize    int64
@@ -47,6 +51,12 @@ type file struct {
 func newFile(path string) *file {
 	lstat, err := os.Lstat(path)
 	if err != nil {
+		if info, err := remoteStat(path); err == nil {
+			return newImageFile(path, info)
+		}
+		if info, err := imageStat(path); err == nil {
+			return newImageFile(path, info)
+		}
 		log.Printf("getting file information: %s", err)
 		return &file{
 			FileInfo:   &fakeStat{name: filepath.Base(path)},
@@ -119,6 +129,7 @@ func newFile(path string) *file {
 		FileInfo:   lstat,
 		linkState:  linkState,
 		linkTarget: linkTarget,
+		mountPoint: linkState == notLink && isMountPoint(lstat, path),
 		path:       path,
 		dirCount:   dirCount,
 		dirSize:    -1,
@@ -153,6 +164,14 @@ func (fs *fakeStat) IsDir() bool        { return false }
 func (fs *fakeStat) Sys() any           { return nil }
 
 func readdir(path string) ([]*file, error) {
+	if c, inner, ok := remotePath(path); ok {
+		return readRemoteFiles(path, c, inner)
+	}
+
+	if image, inner, ok := imagePath(path); ok {
+		return readImageFiles(path, image, inner)
+	}
+
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -190,6 +209,8 @@ type dir struct {
 	ignoredia    bool       // ignoredia value from last sort
 	locale       string     // locale value from last sort
 	noPerm       bool       // whether lf has no permission to open the directory
+	lastUse      time.Time  // last use in the directory cache
+	grid         gridView   // layout of the last drawn grid without 'singlecolumn'
 }
 
 func newDir(path string) *dir {
@@ -220,23 +241,12 @@ func normalize(s1, s2 string, ignorecase, ignoredia bool) (string, string) {
 	return s1, s2
 }
 
-func (dir *dir) sort() {
-	dir.sortby = getSortBy(dir.path)
-	dir.dircounts = getDirCounts(dir.path)
-	dir.dirfirst = getDirFirst(dir.path)
-	dir.dironly = getDirOnly(dir.path)
-	dir.hidden = getHidden(dir.path)
-	dir.reverse = getReverse(dir.path)
-	dir.locale = getLocale(dir.path)
-	dir.hiddenfiles = gOpts.hiddenfiles
-	dir.ignorecase = gOpts.ignorecase
-	dir.ignoredia = gOpts.ignoredia
-
-	dir.files = dir.allFiles
-
+// This function sorts the files of the directory by the given sort key, which
+// is the last key of the sort method when the given flag is set.
+func (dir *dir) sortKey(key sortMethod, last bool) {
 	// reverse order cannot be applied after stable sorting, otherwise the order
 	// of equivalent elements will be reversed
-	switch dir.sortby {
+	switch key {
 	case naturalSort:
 		if collator, err := makeCollator(dir.locale, collate.Numeric); err == nil {
 			sort.SliceStable(dir.files, func(i, j int) bool {
@@ -347,10 +357,23 @@ func (dir *dir) sort() {
 				return dir.files[j].changeTime.Before(dir.files[i].changeTime)
 			}
 		})
+	case lastOpenedSort:
+		sort.SliceStable(dir.files, func(i, j int) bool {
+			t1, t2 := gOpenHistory.get(dir.files[i].path).last, gOpenHistory.get(dir.files[j].path).last
+			if !dir.reverse {
+				return t1.Before(t2)
+			} else {
+				return t2.Before(t1)
+			}
+		})
 	case extSort:
 		sort.SliceStable(dir.files, func(i, j int) bool {
 			ext1, ext2 := normalize(dir.files[i].ext, dir.files[j].ext, dir.ignorecase, dir.ignoredia)
 			name1, name2 := normalize(dir.files[i].Name(), dir.files[j].Name(), dir.ignorecase, dir.ignoredia)
+			if !last {
+				// files with the same extension are left in the order of the later keys
+				name1, name2 = "", ""
+			}
 			if !dir.reverse {
 				return ext1 < ext2 || ext1 == ext2 && name1 < name2
 			} else {
@@ -358,10 +381,32 @@ func (dir *dir) sort() {
 			}
 		})
 	}
+}
+
+func (dir *dir) sort() {
+	dir.sortby = getSortBy(dir.path)
+	dir.dircounts = getDirCounts(dir.path)
+	dir.dirfirst = getDirFirst(dir.path)
+	dir.dironly = getDirOnly(dir.path)
+	dir.hidden = getHidden(dir.path)
+	dir.reverse = getReverse(dir.path)
+	dir.locale = getLocale(dir.path)
+	dir.hiddenfiles = gOpts.hiddenfiles
+	dir.ignorecase = gOpts.ignorecase
+	dir.ignoredia = gOpts.ignoredia
+
+	dir.files = dir.allFiles
+
+	// files are sorted by the keys from the last one, so that the order of
+	// the later keys is kept for files equal in the earlier keys
+	keys := dir.sortby.keys()
+	for i := len(keys) - 1; i >= 0; i-- {
+		dir.sortKey(keys[i], i == len(keys)-1)
+	}
 
 	// when sorting by size while also showing dircounts, we always display files
 	// and directories separately to avoid mixing file sizes and file counts
-	if dir.dirfirst || (dir.sortby == sizeSort && dir.dircounts) {
+	if dir.dirfirst || (dir.sortby.has(sizeSort) && dir.dircounts) {
 		sort.SliceStable(dir.files, func(i, j int) bool {
 			if dir.files[i].IsDir() == dir.files[j].IsDir() {
 				return i < j
@@ -501,6 +546,56 @@ func (dir *dir) sel(name string, height int) {
 	dir.boundPos(height)
 }
 
+// This function sorts the directory again after a change of the sort options,
+// keeping the cursor on the same file rather than the same index, at the same
+// row on the screen if possible. When the file is not shown anymore (e.g. a
+// hidden file after disabling 'hidden'), the closest file shown after it is
+// used, or before it if there is none. The start of the Visual mode selection
+// is kept on the same file as well.
+func (dir *dir) resort(height int) {
+	old := slices.Clone(dir.files)
+	ind := dir.ind
+
+	var anchor *file
+	if dir.visualAnchor >= 0 && dir.visualAnchor < len(old) {
+		anchor = old[dir.visualAnchor]
+	}
+
+	dir.sort()
+
+	if len(old) == 0 || len(dir.files) == 0 {
+		dir.sel("", height)
+		return
+	}
+
+	inds := make(map[*file]int, len(dir.files))
+	for i, f := range dir.files {
+		inds[f] = i
+	}
+
+	ind = min(ind, len(old)-1)
+	candidates := slices.Clone(old[ind:])
+	for i := ind - 1; i >= 0; i-- {
+		candidates = append(candidates, old[i])
+	}
+	for _, f := range candidates {
+		if i, ok := inds[f]; ok {
+			dir.ind = i
+			break
+		}
+	}
+
+	if anchor != nil {
+		if i, ok := inds[anchor]; ok {
+			dir.visualAnchor = i
+		} else {
+			dir.visualAnchor = dir.ind
+		}
+	}
+
+	dir.boundPos(height)
+}
+
 func (dir *dir) boundPos(height int) {
 	if len(dir.files) <= height {
 		dir.pos = dir.ind
@@ -533,6 +628,14 @@ type nav struct {
 	deleteCount     int
 	deleteTotal     int
 	deleteUpdate    int
+	dirSizeCount    int
+	dirSizeTotal    int
+	dirSizeCancel   chan struct{}
+	hoverSizeCancel chan struct{}
+	hoverSizePath   string
+	hoverSizeID     int
+	statsCancel     chan struct{}
+	cleanupCancel   chan struct{}
 	copyBytesChan   chan int64
 	copyTotalChan   chan int64
 	moveCountChan   chan int
@@ -544,8 +647,14 @@ type nav struct {
 	regChan         chan *reg
 	fileChan        chan *file
 	delChan         chan string
+	dirSizeChan     chan dirSizeUpdate
+	hoverSizeChan   chan hoverSizeUpdate
+	segmentChan     chan segmentResult
+	columnChan      chan columnResult
+	gitStatusChan   chan gitResult
 	dirCache        map[string]*dir
 	regCache        map[string]*reg
+	regUsed         uint64
 	saves           map[string]bool
 	marks           map[string]string
 	renameOldPath   string
@@ -553,6 +662,7 @@ type nav struct {
 	selections      map[string]int
 	tags            map[string]string
 	selectionInd    int
+	openGroup       []string
 	height          int
 	find            string
 	findBack        bool
@@ -562,10 +672,36 @@ type nav struct {
 	searchPos       int
 	prevFilter      []string
 	volatilePreview bool
+	volatilePath    string
 	previewTimer    *time.Timer
 	previewLoading  bool
+	reloadTime      time.Time
+	reloadTimer     *time.Timer
 	jumpList        []string
 	jumpListInd     int
+	pasteQueue      pasteQueue
+	reports         reportLog
+	checksums       pagerLog
+	stats           pagerLog
+	cleanupScan     cleanupLog
+	cleanupPlan     []cleanupFile
+	trashed         trashLog
+	journal         journal
+	segments        promptSegments
+	columns         shellColumns
+	gitStatus       gitStatuses
+	pane            *dir
+	paneInd         int
+	linkedFocus     bool
+	boundHit        string
+	tabs            []*tab
+	tabInd          int
+	tabID           int
+	tabSeq          int
+	peerDirs        map[string]int
+	physical        bool
+	realPath        string
+	realLink        bool
 }
 
 func (nav *nav) loadDirInternal(path string) *dir {
@@ -591,14 +727,21 @@ func (nav *nav) loadDirInternal(path string) *dir {
 }
 
 func (nav *nav) loadDir(path string) *dir {
+	if gHeadless {
+		d := newDir(path)
+		d.sort()
+		return d
+	}
+
 	if gOpts.dircache {
 		d, ok := nav.dirCache[path]
 		if !ok {
 			d = nav.loadDirInternal(path)
-			nav.dirCache[path] = d
+			nav.cacheDir(d)
 			return d
 		}
 
+		d.lastUse = time.Now()
 		nav.checkDir(d)
 
 		return d
@@ -606,12 +749,100 @@ func (nav *nav) loadDir(path string) *dir {
 	return nav.loadDirInternal(path)
 }
 
+// This function adds the given directory to the cache and removes directories
+// from the cache when it is too large.
+func (nav *nav) cacheDir(d *dir) {
+	d.lastUse = time.Now()
+	nav.dirCache[d.path] = d
+	nav.trimDirCache()
+}
+
+// This function removes the directories not used for longer than the
+// 'dircachettl' option, and then the least recently used directories until
+// there are at most 'dircachesize' directories with at most 'dircachefiles'
+// files in total. Directories currently shown are always kept.
+func (nav *nav) trimDirCache() {
+	shown := make(map[string]bool)
+	for _, d := range nav.dirs {
+		shown[d.path] = true
+	}
+	if nav.pane != nil {
+		shown[nav.pane.path] = true
+	}
+
+	now := time.Now()
+	ttl := time.Duration(gOpts.dircachettl) * time.Second
+
+	files := 0
+	var list []*dir
+	for path, d := range nav.dirCache {
+		switch {
+		case shown[path]:
+		case ttl > 0 && now.Sub(d.lastUse) > ttl:
+			delete(nav.dirCache, path)
+			continue
+		default:
+			list = append(list, d)
+		}
+		files += len(d.allFiles)
+	}
+
+	if len(nav.dirCache) <= gOpts.dircachesize && files <= gOpts.dircachefiles {
+		return
+	}
+
+	slices.SortFunc(list, func(a, b *dir) int {
+		return a.lastUse.Compare(b.lastUse)
+	})
+
+	for _, d := range list {
+		if len(nav.dirCache) <= gOpts.dircachesize && files <= gOpts.dircachefiles {
+			break
+		}
+		delete(nav.dirCache, d.path)
+		files -= len(d.allFiles)
+	}
+}
+
+// This function makes sure that the given directory is read again when it is
+// used next time, since file operations may change directories without
+// changing their modification times within the precision of the file system.
+func (nav *nav) invalidateDir(path string) {
+	shown := false
+	for _, d := range nav.dirs {
+		if d.path == path {
+			d.loadTime = time.Time{}
+			shown = true
+		}
+	}
+	if nav.pane != nil && nav.pane.path == path {
+		nav.pane.loadTime = time.Time{}
+		shown = true
+	}
+
+	// shown directories are kept to keep their cursors
+	if d, ok := nav.dirCache[path]; ok && !d.loading {
+		if shown {
+			d.loadTime = time.Time{}
+		} else {
+			delete(nav.dirCache, path)
+		}
+	}
+}
+
 func (nav *nav) checkDir(dir *dir) {
-	if dir.loading {
+	// remote directories are only loaded again with 'reload'
+	if dir.loading || inRemote(dir.path) {
 		return
 	}
 
 	s, err := os.Stat(dir.path)
+	if err != nil {
+		// directories inside disk images are reloaded when the image changes
+		if image, _, ok := imagePath(dir.path); ok {
+			s, err = os.Stat(image)
+		}
+	}
 	if err != nil {
 		log.Printf("getting directory info: %s", err)
 		return
@@ -625,15 +856,9 @@ func (nav *nav) checkDir(dir *dir) {
 			return
 		}
 
-		dir.loading = true
-		go func() {
-			nav.dirChan <- newDir(dir.path)
-		}()
+		nav.reloadDir(dir)
 	case dir.dircounts != getDirCounts(dir.path):
-		dir.loading = true
-		go func() {
-			nav.dirChan <- newDir(dir.path)
-		}()
+		nav.reloadDir(dir)
 	// Although toggling dircounts can affect sorting, it is already handled by
 	// reloading the directory which should sort the files anyway, so it is not
 	// checked below.
@@ -656,6 +881,28 @@ func (nav *nav) checkDir(dir *dir) {
 	}
 }
 
+// This function returns the time to wait before another reload after the
+// given time of the last one, to limit reloads to 'reloadrate' per second.
+func reloadDelay(last time.Time) time.Duration {
+	if gOpts.reloadrate <= 0 {
+		return 0
+	}
+
+	return max(time.Until(last.Add(time.Second/time.Duration(gOpts.reloadrate))), 0)
+}
+
+// This function reloads the given directory in the background. The reload is
+// delayed when the directory is changing too frequently, and since it is not
+// checked again while loading, changes in the meantime are merged into it.
+func (nav *nav) reloadDir(dir *dir) {
+	dir.loading = true
+	delay := reloadDelay(dir.loadTime)
+	go func() {
+		time.Sleep(delay)
+		nav.dirChan <- newDir(dir.path)
+	}()
+}
+
 func (nav *nav) getDirs(wd string) {
 	var dirs []*dir
 
@@ -677,6 +924,9 @@ func (nav *nav) getDirs(wd string) {
 }
 
 func newNav(height int) *nav {
+	reloadTimer := time.NewTimer(0)
+	reloadTimer.Stop()
+
 	nav := &nav{
 		copyBytesChan:   make(chan int64, 1024),
 		copyTotalChan:   make(chan int64, 1024),
@@ -689,6 +939,11 @@ func newNav(height int) *nav {
 		regChan:         make(chan *reg),
 		fileChan:        make(chan *file),
 		delChan:         make(chan string),
+		dirSizeChan:     make(chan dirSizeUpdate, 1024),
+		hoverSizeChan:   make(chan hoverSizeUpdate, 1024),
+		segmentChan:     make(chan segmentResult, 1024),
+		columnChan:      make(chan columnResult, 1024),
+		gitStatusChan:   make(chan gitResult, 1024),
 		dirCache:        make(map[string]*dir),
 		regCache:        make(map[string]*reg),
 		saves:           make(map[string]bool),
@@ -698,6 +953,7 @@ func newNav(height int) *nav {
 		selectionInd:    0,
 		height:          height,
 		previewTimer:    time.NewTimer(0),
+		reloadTimer:     reloadTimer,
 		jumpList:        make([]string, 0),
 		jumpListInd:     -1,
 	}
@@ -735,10 +991,27 @@ func (nav *nav) cdJumpListNext() {
 }
 
 func (nav *nav) renew() {
-	for _, d := range nav.dirs {
-		nav.checkDir(d)
+	if gHeadless {
+		// directories are reloaded in place as there is no main loop
+		for _, d := range nav.dirs {
+			name := d.name()
+			*d = *nav.loadDir(d.path)
+			d.sel(name, nav.height)
+		}
+	} else {
+		for _, d := range nav.dirs {
+			nav.checkDir(d)
+		}
+		if nav.pane != nil {
+			nav.checkDir(nav.pane)
+		}
 	}
 
+	nav.pruneSelections()
+}
+
+// This function removes the selections of the files that no longer exist.
+func (nav *nav) pruneSelections() {
 	for m := range nav.selections {
 		if _, err := os.Lstat(m); os.IsNotExist(err) {
 			delete(nav.selections, m)
@@ -750,6 +1023,66 @@ func (nav *nav) renew() {
 	}
 }
 
+// This function updates the entry of the given file in the loaded directories
+// without reading the whole directory again, which is much faster for large
+// directories. The entry is added or removed when the file is created or
+// deleted, and directories are only sorted again when the order can change.
+// Directories are then considered up to date, so this assumes that the file
+// is the only change since they were loaded.
+func (nav *nav) reloadEntry(path string) {
+	path = filepath.Clean(path)
+
+	dirs := make(map[*dir]bool)
+	for _, d := range nav.dirs {
+		dirs[d] = true
+	}
+	for _, d := range nav.dirCache {
+		dirs[d] = true
+	}
+
+	_, err := os.Lstat(path)
+	exists := err == nil
+
+	deletePathRecursive(nav.regCache, path)
+	if !exists {
+		deletePathRecursive(nav.dirCache, path)
+	}
+
+	for d := range dirs {
+		if d.loading || d.path != filepath.Dir(path) {
+			continue
+		}
+
+		name := d.name()
+		ind := slices.IndexFunc(d.allFiles, func(f *file) bool { return f.path == path })
+
+		resort := true
+		switch {
+		case exists && ind >= 0:
+			old, f := d.allFiles[ind], newFile(path)
+			f.dirSize = old.dirSize
+			f.customInfo = old.customInfo
+			d.allFiles[ind] = f
+			// files are sorted by their names which have not changed
+			resort = old.IsDir() != f.IsDir() ||
+				!d.sortby.byName()
+		case exists:
+			d.allFiles = append(d.allFiles, newFile(path))
+		case ind >= 0:
+			d.allFiles = slices.Delete(d.allFiles, ind, ind+1)
+		default:
+			continue
+		}
+
+		if resort {
+			d.sort()
+			d.sel(name, nav.height)
+		}
+
+		d.loadTime = time.Now()
+	}
+}
+
 func (nav *nav) reload() error {
 	clear(nav.dirCache)
 	clear(nav.regCache)
@@ -786,8 +1119,16 @@ func (nav *nav) exportFiles() {
 		currFile = quoteString(curr.path)
 	}
 
+	// only files of the group are exported while opening with 'opengroups',
+	// with the first one as the current file for scripts checking its type
+	list := nav.currSelections()
+	if len(nav.openGroup) != 0 {
+		list = nav.openGroup
+		currFile = quoteString(list[0])
+	}
+
 	var selections []string
-	for _, selection := range nav.currSelections() {
+	for _, selection := range list {
 		selections = append(selections, quoteString(selection))
 	}
 	currSelections := strings.Join(selections, gOpts.filesep)
@@ -811,7 +1152,6 @@ func (nav *nav) exportFiles() {
 }
 
 func (nav *nav) previewLoop(ui *ui) {
-	var prev string
 	for path := range nav.previewChan {
 		clear := len(path) == 0
 	loop:
@@ -824,25 +1164,37 @@ func (nav *nav) previewLoop(ui *ui) {
 			}
 		}
 		win := ui.wins[len(ui.wins)-1]
-		if clear && len(gOpts.previewer) != 0 && len(gOpts.cleaner) != 0 && nav.volatilePreview {
-			cmd := exec.Command(gOpts.cleaner, prev,
-				strconv.Itoa(win.w),
-				strconv.Itoa(win.h),
-				strconv.Itoa(win.x),
-				strconv.Itoa(win.y),
-				path)
-			if err := cmd.Run(); err != nil {
-				log.Printf("cleaning preview: %s", err)
-			}
-			nav.volatilePreview = false
+		if clear {
+			nav.cleanPreview(win, path)
 		}
 		if len(path) != 0 {
-			nav.preview(path, win)
-			prev = path
+			nav.preview(path, win, ui.screen)
+			nav.volatilePath = path
 		}
 	}
 }
 
+func (nav *nav) cleanPreview(win *win, path string) {
+	if len(gOpts.previewer) == 0 || len(gOpts.cleaner) == 0 || !nav.volatilePreview {
+		return
+	}
+
+	cmd, err := previewCommand(gOpts.cleaner, nav.volatilePath,
+		strconv.Itoa(win.w),
+		strconv.Itoa(win.h),
+		strconv.Itoa(win.x),
+		strconv.Itoa(win.y),
+		path)
+	if err != nil {
+		log.Printf("cleaning preview: %s", err)
+		return
+	}
+	if err := cmd.Run(); err != nil {
+		log.Printf("cleaning preview: %s", err)
+	}
+	nav.volatilePreview = false
+}
+
 func matchPattern(pattern, name, path string) bool {
 	s := name
 
@@ -858,18 +1210,48 @@ func matchPattern(pattern, name, path string) bool {
 	return matched
 }
 
-func (nav *nav) preview(path string, win *win) {
-	reg := &reg{loadTime: time.Now(), path: path}
+func (nav *nav) preview(path string, win *win, screen tcell.Screen) {
+	reg := &reg{loadTime: time.Now(), path: path, width: win.w, height: win.h}
 	defer func() { nav.regChan <- reg }()
 
+	if s, err := os.Stat(path); err == nil {
+		reg.modTime = s.ModTime()
+		reg.size = s.Size()
+	}
+
+	// remote files are read over the network, so only small ones are previewed
+	if inRemote(path) {
+		s, err := lstatPath(path)
+		if err != nil {
+			log.Printf("previewing file: %s", err)
+			return
+		}
+		reg.modTime = s.ModTime()
+		reg.size = s.Size()
+		if s.Size() > gSftpPreviewMax {
+			reg.lines = []string{"\033[7mtoo large to preview\033[0m"}
+			return
+		}
+	}
+
+	if nav.previewImage(reg, win, screen) {
+		return
+	}
+
 	var reader *bufio.Reader
 
-	if len(gOpts.previewer) != 0 {
-		cmd := exec.Command(gOpts.previewer, path,
+	// files inside disk images and on remote hosts are not passed to the
+	// previewer as they do not exist in the file system
+	if len(gOpts.previewer) != 0 && !inImage(path) && !inRemote(path) {
+		cmd, err := previewCommand(gOpts.previewer, path,
 			strconv.Itoa(win.w),
 			strconv.Itoa(win.h),
 			strconv.Itoa(win.x),
 			strconv.Itoa(win.y))
+		if err != nil {
+			log.Printf("previewing file: %s", err)
+			return
+		}
 
 		out, err := cmd.StdoutPipe()
 		if err != nil {
@@ -898,7 +1280,7 @@ func (nav *nav) preview(path string, win *win) {
 		defer out.Close()
 		reader = bufio.NewReader(out)
 	} else {
-		f, err := os.Open(path)
+		f, err := openPath(path)
 		if err != nil {
 			log.Printf("opening file: %s", err)
 			return
@@ -947,22 +1329,47 @@ func (nav *nav) preview(path string, win *win) {
 	}
 }
 
-func (nav *nav) loadReg(path string, volatile bool) *reg {
+func (nav *nav) loadReg(path string, win *win, volatile bool) *reg {
 	r, ok := nav.regCache[path]
 	if !ok || (volatile && r.volatile) {
 		r := &reg{loading: true, loadTime: time.Now(), path: path, volatile: true}
-		nav.regCache[path] = r
+		nav.cacheReg(r)
 		nav.startPreview()
 		nav.previewChan <- path
 		return r
 	}
 
-	nav.checkReg(r)
+	nav.regUsed++
+	r.used = nav.regUsed
+	nav.checkReg(r, win)
 
 	return r
 }
 
-func (nav *nav) checkReg(reg *reg) {
+// This function adds the given preview to the cache, and removes the least
+// recently used previews when there are more than 'previewcachesize' of them.
+func (nav *nav) cacheReg(r *reg) {
+	nav.regUsed++
+	r.used = nav.regUsed
+	nav.regCache[r.path] = r
+	nav.trimRegCache()
+}
+
+func (nav *nav) trimRegCache() {
+	for len(nav.regCache) > gOpts.previewcachesize {
+		var oldest *reg
+		for _, r := range nav.regCache {
+			if oldest == nil || r.used < oldest.used {
+				oldest = r
+			}
+		}
+		delete(nav.regCache, oldest.path)
+	}
+}
+
+// This function reloads the given preview when the file is modified, or when
+// the size of the file or the preview pane is changed since it was loaded.
+func (nav *nav) checkReg(reg *reg, win *win) {
 	s, err := os.Stat(reg.path)
 	if err != nil {
 		return
@@ -976,7 +1383,10 @@ func (nav *nav) checkReg(reg *reg) {
 		return
 	}
 
-	if s.ModTime().After(reg.loadTime) {
+	changed := !reg.loading && (!s.ModTime().Equal(reg.modTime) || s.Size() != reg.size ||
+		reg.width != win.w || reg.height != win.h)
+
+	if changed || s.ModTime().After(reg.loadTime) {
 		reg.loadTime = now
 		nav.startPreview()
 		nav.previewChan <- reg.path
@@ -991,9 +1401,7 @@ func (nav *nav) startPreview() {
 
 func (nav *nav) sort() {
 	for _, d := range nav.dirs {
-		name := d.name()
-		d.sort()
-		d.sel(name, nav.height)
+		d.resort(nav.height)
 	}
 }
 
@@ -1012,9 +1420,7 @@ func (nav *nav) setFilter(filter []string) error {
 	dir.filter = newfilter
 
 	// Apply filter, by sorting current dir (see nav.sort())
-	name := dir.name()
-	dir.sort()
-	dir.sel(name, nav.height)
+	dir.resort(nav.height)
 	return nil
 }
 
@@ -1028,6 +1434,7 @@ func (nav *nav) up(dist int) bool {
 			nav.bottom()
 			dir.visualWrap -= 1
 		}
+		nav.boundHit = "up"
 		return old != dir.ind
 	}
 
@@ -1052,6 +1459,7 @@ func (nav *nav) down(dist int) bool {
 			nav.top()
 			dir.visualWrap += 1
 		}
+		nav.boundHit = "down"
 		return old != dir.ind
 	}
 
@@ -1131,14 +1539,17 @@ func (nav *nav) updir() error {
 
 	nav.dirs = nav.dirs[:len(nav.dirs)-1]
 
-	if err := os.Chdir(filepath.Dir(dir.path)); err != nil {
+	if err := chdir(filepath.Dir(dir.path)); err != nil {
 		return fmt.Errorf("updir: %s", err)
 	}
 
 	return nil
 }
 
-func (nav *nav) open() error {
+// This function opens the current directory. Symlinked directories are
+// opened as the target directory when 'follow' is set, so that parent
+// directories are those of the target instead of those of the link.
+func (nav *nav) open(follow bool) error {
 	curr, err := nav.currFile()
 	if err != nil {
 		return fmt.Errorf("open: %s", err)
@@ -1146,17 +1557,58 @@ func (nav *nav) open() error {
 
 	path := curr.path
 
+	if follow && curr.linkState != notLink {
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return fmt.Errorf("open: %s", err)
+		}
+		if err := os.Chdir(target); err != nil {
+			return fmt.Errorf("open: %s", err)
+		}
+		nav.getDirs(target)
+		nav.addJumpList()
+		return nil
+	}
+
 	dir := nav.loadDir(path)
 
 	nav.dirs = append(nav.dirs, dir)
 
-	if err := os.Chdir(path); err != nil {
+	if err := chdir(path); err != nil {
 		return fmt.Errorf("open: %s", err)
 	}
 
 	return nil
 }
 
+// This function reports whether the given symlinked directory should be
+// opened as its target directory without asking.
+func (nav *nav) followLink(f *file) bool {
+	return f.linkState != notLink && (nav.physical || gOpts.followsymlinkdirs == "always")
+}
+
+// This function returns the mode shown in the prompt line. The logical mode is
+// only shown inside symlinked trees where the physical mode would resolve
+// parent directories differently. The resolved path is cached since it is
+// needed on each redraw.
+func (nav *nav) cdMode() string {
+	if nav.physical {
+		return "physical"
+	}
+
+	path := nav.currDir().path
+	if path != nav.realPath {
+		real, err := filepath.EvalSymlinks(path)
+		nav.realPath = path
+		nav.realLink = err == nil && real != path
+	}
+
+	if nav.realLink {
+		return "logical"
+	}
+	return ""
+}
+
 func (nav *nav) top() bool {
 	dir := nav.currDir()
 
@@ -1331,6 +1783,7 @@ func (nav *nav) save(cp bool) error {
 	if err := saveFiles(list, cp); err != nil {
 		return err
 	}
+	recordClipboard(list, cp)
 
 	clear(nav.saves)
 	for _, f := range list {
@@ -1340,44 +1793,87 @@ func (nav *nav) save(cp bool) error {
 	return nil
 }
 
-func (nav *nav) copyAsync(app *app, srcs []string, dstDir string) {
+// This function copies the given files in the background, which is paused and
+// cancelled with the given job. The report of the operation is returned, which
+// is nil when nothing is copied.
+func (nav *nav) copyAsync(app *app, srcs []string, dstDir string, ctl *jobControl) *report {
 	echo := &callExpr{"echoerr", []string{""}, 1}
+	start := time.Now()
 
-	_, err := os.Stat(dstDir)
+	_, err := lstatPath(dstDir)
 	if os.IsNotExist(err) {
 		echo.args[0] = err.Error()
 		app.ui.exprChan <- echo
-		return
+		app.audit("copy", srcs, dstDir, start, 1)
+		return nil
 	}
 
 	total, err := copySize(srcs)
 	if err != nil {
 		echo.args[0] = err.Error()
 		app.ui.exprChan <- echo
-		return
+		app.audit("copy", srcs, dstDir, start, 1)
+		return nil
 	}
 
 	nav.copyTotalChan <- total
+	ctl.size.Store(total)
 
-	nums, errs := copyAll(srcs, dstDir, gOpts.preserve)
-
+	r := newReport("copy", dstDir)
+	j := &journalEntry{op: "copy"}
 	errCount := 0
-loop:
-	for {
-		select {
-		case n := <-nums:
-			nav.copyBytesChan <- n
-		case err, ok := <-errs:
-			if !ok {
-				break loop
+	cancelled := false
+
+	// sources are copied one by one to report errors for each of them
+	for _, src := range srcs {
+		if err := ctl.wait(); err != nil {
+			cancelled = true
+			r.skip(src, err.Error())
+			continue
+		}
+
+		srcStart := time.Now()
+		dst := copyDest(src, dstDir)
+		bytes := opLogSize(src)
+
+		nums, errs := copyAll([]string{src}, dstDir, gOpts.preserve, ctl)
+
+		var reasons []string
+	loop:
+		for {
+			select {
+			case n := <-nums:
+				nav.copyBytesChan <- n
+				ctl.bytes.Add(n)
+			case err, ok := <-errs:
+				if !ok {
+					break loop
+				}
+				if errors.Is(err, errJobCancelled) {
+					cancelled = true
+					reasons = append(reasons, err.Error())
+					continue
+				}
+				errCount++
+				echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
+				app.ui.exprChan <- echo
+				reasons = append(reasons, err.Error())
 			}
-			errCount++
-			echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
-			app.ui.exprChan <- echo
 		}
+
+		r.add(src, reasons)
+		app.opLog("copy", src, dst, bytes, srcStart, false, reasons)
+		if len(reasons) == 0 {
+			j.files = append(j.files, journalFile{src: src, dst: dst})
+		}
+		ctl.count.Add(1)
 	}
 
 	nav.copyTotalChan <- -total
+	nav.journal.record(j)
+
+	app.audit("copy", srcs, dstDir, start, errCount)
+	app.finishReport(r)
 
 	if gSingleMode {
 		nav.renew()
@@ -1390,32 +1886,55 @@ loop:
 		}
 	}
 
-	if errCount == 0 {
+	switch {
+	case cancelled:
+		app.ui.exprChan <- &callExpr{"echo", []string{"\033[0;33mCopy cancelled\033[0m"}, 1}
+	case errCount == 0:
 		app.ui.exprChan <- &callExpr{"echo", []string{"\033[0;32mCopied successfully\033[0m"}, 1}
 	}
+
+	return r
 }
 
-func (nav *nav) moveAsync(app *app, srcs []string, dstDir string) {
+// This function moves the given files in the background, which is paused and
+// cancelled with the given job. The report of the operation is returned, which
+// is nil when nothing is moved.
+func (nav *nav) moveAsync(app *app, srcs []string, dstDir string, ctl *jobControl) *report {
 	echo := &callExpr{"echoerr", []string{""}, 1}
+	start := time.Now()
 
 	_, err := os.Stat(dstDir)
 	if os.IsNotExist(err) {
 		echo.args[0] = err.Error()
 		app.ui.exprChan <- echo
-		return
+		app.audit("move", srcs, dstDir, start, 1)
+		return nil
 	}
 
 	nav.moveTotalChan <- len(srcs)
 
+	r := newReport("move", dstDir)
+	j := &journalEntry{op: "move"}
 	errCount := 0
+	cancelled := false
 	for _, src := range srcs {
 		nav.moveCountChan <- 1
+		ctl.count.Add(1)
+		srcStart := time.Now()
+
+		if err := ctl.wait(); err != nil {
+			cancelled = true
+			r.skip(src, err.Error())
+			continue
+		}
 
 		srcStat, err := os.Lstat(src)
 		if err != nil {
 			errCount++
 			echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
 			app.ui.exprChan <- echo
+			r.add(src, []string{err.Error()})
+			app.opLog("move", src, "", 0, srcStart, false, []string{err.Error()})
 			continue
 		}
 
@@ -1427,34 +1946,31 @@ func (nav *nav) moveAsync(app *app, srcs []string, dstDir string) {
 				errCount++
 				echo.args[0] = fmt.Sprintf("[%d] rename %s %s: source and destination are the same file", errCount, src, dst)
 				app.ui.exprChan <- echo
+				r.skip(src, "source and destination are the same file")
+				app.opLog("move", src, dst, 0, srcStart, true, []string{"source and destination are the same file"})
 				continue
 			}
-			ext := getFileExtension(dstStat)
-			basename := file[:len(file)-len(ext)]
-			var newPath string
-			for i := 1; !os.IsNotExist(err); i++ {
-				file = strings.ReplaceAll(gOpts.dupfilefmt, "%f", basename+ext)
-				file = strings.ReplaceAll(file, "%b", basename)
-				file = strings.ReplaceAll(file, "%e", ext)
-				file = strings.ReplaceAll(file, "%n", strconv.Itoa(i))
-				newPath = filepath.Join(dstDir, file)
-				_, err = os.Lstat(newPath)
-			}
-			dst = newPath
+			dst = dupPath(dstDir, dstStat)
 		}
 
+		bytes := opLogSize(src)
+
+		var reasons []string
 		if err := os.Rename(src, dst); err != nil {
 			if errCrossDevice(err) {
 				total, err := copySize([]string{src})
 				if err != nil {
 					echo.args[0] = err.Error()
 					app.ui.exprChan <- echo
+					r.add(src, []string{err.Error()})
+					app.opLog("move", src, dst, bytes, srcStart, false, []string{err.Error()})
 					continue
 				}
 
 				nav.copyTotalChan <- total
+				ctl.size.Add(total)
 
-				nums, errs := copyAll([]string{src}, dstDir, []string{"mode", "timestamps"})
+				nums, errs := copyAll([]string{src}, dstDir, []string{"mode", "timestamps"}, ctl)
 
 				oldCount := errCount
 			loop:
@@ -1462,34 +1978,53 @@ func (nav *nav) moveAsync(app *app, srcs []string, dstDir string) {
 					select {
 					case n := <-nums:
 						nav.copyBytesChan <- n
+						ctl.bytes.Add(n)
 					case err, ok := <-errs:
 						if !ok {
 							break loop
 						}
+						if errors.Is(err, errJobCancelled) {
+							cancelled = true
+							reasons = append(reasons, err.Error())
+							continue
+						}
 						errCount++
 						echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
 						app.ui.exprChan <- echo
+						reasons = append(reasons, err.Error())
 					}
 				}
 
 				nav.copyTotalChan <- -total
 
-				if errCount == oldCount {
+				if errCount == oldCount && len(reasons) == 0 {
 					if err := os.RemoveAll(src); err != nil {
 						errCount++
 						echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
 						app.ui.exprChan <- echo
+						reasons = append(reasons, err.Error())
 					}
 				}
 			} else {
 				errCount++
 				echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
 				app.ui.exprChan <- echo
+				reasons = append(reasons, err.Error())
 			}
 		}
+
+		r.add(src, reasons)
+		app.opLog("move", src, dst, bytes, srcStart, false, reasons)
+		if len(reasons) == 0 {
+			j.files = append(j.files, journalFile{src: src, dst: dst})
+		}
 	}
 
 	nav.moveTotalChan <- -len(srcs)
+	nav.journal.record(j)
+
+	app.audit("move", srcs, dstDir, start, errCount)
+	app.finishReport(r)
 
 	if gSingleMode {
 		nav.renew()
@@ -1502,10 +2037,15 @@ func (nav *nav) moveAsync(app *app, srcs []string, dstDir string) {
 		}
 	}
 
-	if errCount == 0 {
+	switch {
+	case cancelled:
+		app.ui.exprChan <- &callExpr{"echo", []string{"\033[0;33mMove cancelled\033[0m"}, 1}
+	case errCount == 0:
 		app.ui.exprChan <- &callExpr{"clear", nil, 1}
 		app.ui.exprChan <- &callExpr{"echo", []string{"\033[0;32mMoved successfully\033[0m"}, 1}
 	}
+
+	return r
 }
 
 func (nav *nav) paste(app *app) error {
@@ -1518,63 +2058,268 @@ func (nav *nav) paste(app *app) error {
 		return errors.New("no file in copy/cut buffer")
 	}
 
-	dstDir := nav.currDir().path
+	return nav.pasteAsync(app, srcs, nav.currDir().path, cp, false)
+}
 
-	if cp {
-		go nav.copyAsync(app, srcs, dstDir)
-	} else {
-		go nav.moveAsync(app, srcs, dstDir)
+// This function queues copying or moving the given files to the destination
+// directory, which are run in the background. Existing destination files are
+// overwritten when the given flag is set, which are staged until the operation
+// is finished, and otherwise new files are renamed using 'dupfilefmt'.
+func (nav *nav) pasteAsync(app *app, srcs []string, dstDir string, cp, overwrite bool) error {
+	if err := checkWritableDir(dstDir); err != nil {
+		return err
 	}
+	if !cp {
+		if err := checkWritable(append(srcs, dstDir)...); err != nil {
+			return err
+		}
+	}
+
+	job, added := nav.pasteQueue.add(srcs, dstDir, cp, overwrite, nav.tabID)
+	if len(added) == 0 {
+		return errors.New("files are already being pasted")
+	}
+
+	echo := &callExpr{"echo", []string{""}, 1}
+
+	if job == nil {
+		echo.args[0] = fmt.Sprintf("Added %d files to a queued paste", len(added))
+		runAsync(func() { app.ui.exprChan <- echo })
+		return nil
+	}
+
+	runAsync(func() {
+		if n := nav.pasteQueue.waiting(job); n > 0 {
+			echo.args[0] = fmt.Sprintf("Waiting for %d earlier paste operations", n)
+			app.ui.exprChan <- echo
+		}
+		nav.pasteQueue.run(job, func(srcs []string) {
+			var stg *staging
+			if overwrite {
+				var err error
+				if stg, err = stageOverwrites(srcs, dstDir); err != nil {
+					app.ui.exprChan <- &callExpr{"echoerr", []string{err.Error()}, 1}
+					return
+				}
+			}
+
+			var r *report
+			op := "move"
+			if cp {
+				op = "copy"
+				r = nav.copyAsync(app, srcs, dstDir, &job.ctl)
+			} else {
+				r = nav.moveAsync(app, srcs, dstDir, &job.ctl)
+			}
+
+			if stg != nil {
+				if err := stg.finish(r); err != nil {
+					app.ui.exprChan <- &callExpr{"echoerr", []string{err.Error()}, 1}
+				}
+			}
+
+			if r == nil || len(r.failed) > 0 {
+				app.ui.exprChan <- &callExpr{"tab-error", []string{strconv.Itoa(job.tab)}, 1}
+			}
+
+			args := append([]string{op, dstDir}, srcs...)
+			app.ui.exprChan <- &callExpr{"paste-complete", args, 1}
+		})
+	})
 
 	return nil
 }
 
+// This function returns a warning to confirm before pasting the files in the
+// copy buffer. Copied files are checked when their total size exceeds either
+// the free space at the destination or the 'warnsize' option. Since the size
+// is calculated by walking the files, it is not calculated here but by the
+// returned function, which is meant to be run in the background and returns
+// the warning. The function is nil when no check needs the size. Moved files
+// are not checked since they are usually renamed without being copied, and a
+// custom 'paste' command is run without this check.
+func (nav *nav) pasteWarning() (string, func() string, error) {
+	srcs, cp, err := loadFiles()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(srcs) == 0 {
+		return "", nil, nil
+	}
+
+	if !cp {
+		if n := nav.peersIn(srcs); n > 0 {
+			return fmt.Sprintf("moving files from a directory of %s", peersMsg(n)), nil, nil
+		}
+		return "", nil, nil
+	}
+
+	avail, err := diskAvail(nav.currDir().path)
+	if err != nil {
+		log.Printf("paste: %s", err)
+		avail = -1
+	}
+
+	warnsize := gOpts.warnsize
+	if avail < 0 && warnsize <= 0 {
+		return "", nil, nil
+	}
+
+	return "", func() string {
+		total := pasteSize(srcs)
+
+		if avail >= 0 && total > avail {
+			return fmt.Sprintf("not enough space (%s needed, %s free)", humanize(total), humanize(avail))
+		}
+
+		if warnsize > 0 && total > warnsize {
+			return fmt.Sprintf("copying %s", humanize(total))
+		}
+
+		return ""
+	}, nil
+}
+
+// This function returns the total size of the given files for the checks
+// before pasting. Unlike 'copySize', errors while walking the files are only
+// logged, since they are reported for the files that fail to be copied.
+func pasteSize(srcs []string) int64 {
+	var total int64
+
+	for _, src := range srcs {
+		err := walkPath(src, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				log.Printf("paste: walk: %s", err)
+				return nil
+			}
+			total += info.Size()
+			return nil
+		})
+		if err != nil {
+			log.Printf("paste: walk: %s", err)
+		}
+	}
+
+	return total
+}
+
 func (nav *nav) del(app *app) error {
 	list, err := nav.currFileOrSelections()
 	if err != nil {
 		return err
 	}
 
-	go func() {
-		echo := &callExpr{"echoerr", []string{""}, 1}
-		errCount := 0
+	if err := checkWritable(list...); err != nil {
+		return err
+	}
 
-		nav.deleteTotalChan <- len(list)
+	tab := nav.tabID
+	runAsync(func() { nav.deleteAsync(app, list, tab) })
 
-		for _, path := range list {
-			nav.deleteCountChan <- 1
+	return nil
+}
 
-			if err := os.RemoveAll(path); err != nil {
-				errCount++
-				echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
-				app.ui.exprChan <- echo
-			}
+func (nav *nav) deleteAsync(app *app, list []string, tab int) {
+	job := nav.pasteQueue.addDelete(list, tab)
+	if n := nav.pasteQueue.waiting(job); n > 0 {
+		app.ui.exprChan <- &callExpr{"echo", []string{fmt.Sprintf("Waiting for %d earlier paste operations", n)}, 1}
+	}
+	nav.pasteQueue.run(job, func([]string) {
+		if !nav.deleteFiles(app, list, &job.ctl) {
+			app.ui.exprChan <- &callExpr{"tab-error", []string{strconv.Itoa(tab)}, 1}
 		}
+	})
+}
 
-		nav.deleteTotalChan <- -len(list)
+// This function deletes the given paths and reports the entries removed inside
+// them as the progress, so that the progress of large trees is shown. It
+// reports whether the paths are deleted without errors.
+func (nav *nav) deleteFiles(app *app, list []string, ctl *jobControl) bool {
+	echo := &callExpr{"echoerr", []string{""}, 1}
+	errCount := 0
+	start := time.Now()
 
-		if gSingleMode {
-			nav.renew()
-			app.ui.loadFile(app, true)
-		} else {
-			if err := remote("send load"); err != nil {
-				errCount++
-				echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
-				app.ui.exprChan <- echo
+	var total atomic.Int64
+	progress := func(found, done int) {
+		if found > 0 {
+			total.Add(int64(found))
+			ctl.files.Add(int64(found))
+			nav.deleteTotalChan <- found
+		}
+		if done > 0 {
+			ctl.count.Add(int64(done))
+			nav.deleteCountChan <- done
+		}
+	}
+
+	r := newReport("delete", "")
+	j := &journalEntry{op: "delete"}
+	for _, path := range list {
+		if err := ctl.wait(); err != nil {
+			r.skip(path, err.Error())
+			continue
+		}
+
+		pathStart := time.Now()
+		bytes := opLogSize(path)
+
+		var reasons []string
+		for _, err := range removeTree(path, ctl, progress) {
+			reasons = append(reasons, err.Error())
+			if errors.Is(err, errJobCancelled) {
+				continue
 			}
+			errCount++
+			echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
+			app.ui.exprChan <- echo
 		}
-	}()
 
-	return nil
+		r.add(path, reasons)
+		app.opLog("delete", path, "", bytes, pathStart, false, reasons)
+		if len(reasons) == 0 {
+			j.files = append(j.files, journalFile{src: path})
+		}
+	}
+	nav.journal.record(j)
+
+	nav.deleteTotalChan <- -int(total.Load())
+
+	app.audit("delete", list, "", start, errCount)
+	app.finishReport(r)
+
+	if gSingleMode {
+		nav.renew()
+		app.ui.loadFile(app, true)
+	} else {
+		if err := remote("send load"); err != nil {
+			errCount++
+			echo.args[0] = fmt.Sprintf("[%d] %s", errCount, err)
+			app.ui.exprChan <- echo
+		}
+	}
+
+	return errCount == 0
 }
 
-func (nav *nav) rename() error {
+func (nav *nav) rename(app *app) error {
 	oldPath := nav.renameOldPath
 	newPath := nav.renameNewPath
 
+	if err := checkWritable(oldPath); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	bytes := opLogSize(oldPath)
 	if err := os.Rename(oldPath, newPath); err != nil {
+		app.audit("rename", []string{oldPath}, newPath, start, 1)
+		app.opLog("rename", oldPath, newPath, bytes, start, false, []string{err.Error()})
 		return err
 	}
+	app.audit("rename", []string{oldPath}, newPath, start, 0)
+	app.opLog("rename", oldPath, newPath, bytes, start, false, nil)
+	nav.journal.record(&journalEntry{"rename", []journalFile{{src: oldPath, dst: newPath}}})
 
 	lstat, err := os.Lstat(newPath)
 	if err != nil {
@@ -1587,6 +2332,8 @@ func (nav *nav) rename() error {
 	// this clears only the current instance of lf, and not any other instances.
 	deletePathRecursive(nav.regCache, newPath)
 	deletePathRecursive(nav.dirCache, newPath)
+	nav.reloadEntry(oldPath)
+	nav.reloadEntry(newPath)
 	dir := nav.loadDir(filepath.Dir(newPath))
 
 	if dir.loading {
@@ -1615,16 +2362,7 @@ func (nav *nav) sync() error {
 		nav.saves[f] = cp
 	}
 
-	tempmarks := make(map[string]string)
-	for _, ch := range gOpts.tempmarks {
-		k := string(ch)
-		if v, ok := nav.marks[k]; ok {
-			tempmarks[k] = v
-		}
-	}
 	errMarks := nav.readMarks()
-	maps.Copy(nav.marks, tempmarks)
-
 	err = nav.readTags()
 
 	if errMarks != nil {
@@ -1641,7 +2379,13 @@ func (nav *nav) cd(wd string) error {
 		wd = filepath.Join(nav.currDir().path, wd)
 	}
 
-	if err := os.Chdir(wd); err != nil {
+	if nav.physical {
+		if real, err := filepath.EvalSymlinks(wd); err == nil {
+			wd = real
+		}
+	}
+
+	if err := chdir(wd); err != nil {
 		return fmt.Errorf("cd: %s", err)
 	}
 
@@ -1654,7 +2398,7 @@ func (nav *nav) sel(path string) error {
 	path = replaceTilde(path)
 	path = filepath.Clean(path)
 
-	lstat, err := os.Lstat(path)
+	lstat, err := lstatPath(path)
 	if err != nil {
 		return fmt.Errorf("select: %s", err)
 	}
@@ -1759,6 +2503,7 @@ func (nav *nav) findNext() (bool, bool) {
 			return nav.down(i - dir.ind), true
 		}
 	}
+	nav.boundHit = "find"
 	if gOpts.wrapscan {
 		for i := range dir.ind {
 			if findMatch(dir.files[i].Name(), nav.find) {
@@ -1777,6 +2522,7 @@ func (nav *nav) findPrev() (bool, bool) {
 			return nav.up(dir.ind - i), true
 		}
 	}
+	nav.boundHit = "find"
 	if gOpts.wrapscan {
 		for i := len(dir.files) - 1; i > dir.ind; i-- {
 			if findMatch(dir.files[i].Name(), nav.find) {
@@ -1818,6 +2564,7 @@ func (nav *nav) searchNext() (bool, error) {
 			return nav.down(i - dir.ind), nil
 		}
 	}
+	nav.boundHit = "search"
 	if gOpts.wrapscan {
 		for i := range dir.ind {
 			if matched, err := searchMatch(dir.files[i].Name(), nav.search, gOpts.globsearch); err != nil {
@@ -1840,6 +2587,7 @@ func (nav *nav) searchPrev() (bool, error) {
 			return nav.up(dir.ind - i), nil
 		}
 	}
+	nav.boundHit = "search"
 	if gOpts.wrapscan {
 		for i := len(dir.files) - 1; i > dir.ind; i-- {
 			if matched, err := searchMatch(dir.files[i].Name(), nav.search, gOpts.globsearch); err != nil {
@@ -1877,123 +2625,149 @@ func (nav *nav) removeMark(mark string) error {
 	return fmt.Errorf("no such mark")
 }
 
-func (nav *nav) readMarks() error {
-	clear(nav.marks)
-	f, err := os.Open(gMarksPath)
-	if os.IsNotExist(err) {
-		return nil
-	}
-	if err != nil {
-		return fmt.Errorf("opening marks file: %s", err)
+// This function reads the marks from the marks file, keeping the temporary
+// marks of the client. It should be called while holding the lock of the file,
+// and the marks are read along with the error when the file is corrupted.
+func (nav *nav) loadMarks() error {
+	lines, err := readDataLines(gMarksPath, func(line string) bool {
+		mark, path, found := strings.Cut(line, ":")
+		return found && mark != "" && path != ""
+	})
+	if err != nil && !errors.Is(err, errDataCorrupt) {
+		return err
 	}
-	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		mark, path, found := strings.Cut(scanner.Text(), ":")
-		if !found {
-			return fmt.Errorf("invalid marks file entry: %s", scanner.Text())
-		}
+	maps.DeleteFunc(nav.marks, func(mark, _ string) bool {
+		return !strings.Contains(gOpts.tempmarks, mark)
+	})
+	for _, line := range lines {
+		mark, path, _ := strings.Cut(line, ":")
 		if _, ok := nav.marks[mark]; !ok {
 			nav.marks[mark] = path
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("reading marks file: %s", err)
-	}
+	return err
+}
 
+func (nav *nav) readMarks() error {
+	if err := withDataLock(gMarksPath, nav.loadMarks); err != nil {
+		return fmt.Errorf("marks file: %s", err)
+	}
 	return nil
 }
 
-func (nav *nav) writeMarks() error {
-	if err := os.MkdirAll(filepath.Dir(gMarksPath), os.ModePerm); err != nil {
-		return fmt.Errorf("creating data directory: %s", err)
-	}
+// This function applies the given change to the marks and writes them to the
+// marks file. The marks are read again before the change while holding the
+// lock of the file, so that marks saved by other clients in the meantime are
+// not overwritten.
+func (nav *nav) updateMarks(change func() error) error {
+	var errLoad error
+	err := withDataLock(gMarksPath, func() error {
+		if err := nav.loadMarks(); errors.Is(err, errDataCorrupt) {
+			errLoad = err
+		} else if err != nil {
+			return fmt.Errorf("marks file: %s", err)
+		}
 
-	f, err := os.Create(gMarksPath)
-	if err != nil {
-		return fmt.Errorf("creating marks file: %s", err)
-	}
-	defer f.Close()
+		if err := change(); err != nil {
+			return err
+		}
 
-	var keys []string
-	for k := range nav.marks {
-		if !strings.Contains(gOpts.tempmarks, k) {
-			keys = append(keys, k)
+		var keys []string
+		for k := range nav.marks {
+			if !strings.Contains(gOpts.tempmarks, k) {
+				keys = append(keys, k)
+			}
 		}
-	}
-	sort.Strings(keys)
+		sort.Strings(keys)
 
-	for _, k := range keys {
-		_, err = fmt.Fprintf(f, "%s:%s\n", k, nav.marks[k])
-		if err != nil {
+		var b bytes.Buffer
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s:%s\n", k, nav.marks[k])
+		}
+
+		if err := writeDataFile(gMarksPath, b.Bytes()); err != nil {
 			return fmt.Errorf("writing marks file: %s", err)
 		}
-	}
+		return nil
+	})
 
-	return nil
+	if err == nil && errLoad != nil {
+		return fmt.Errorf("marks file: %s", errLoad)
+	}
+	return err
 }
 
-func (nav *nav) readTags() error {
-	clear(nav.tags)
-	f, err := os.Open(gTagsPath)
-	if os.IsNotExist(err) {
-		return nil
-	}
-	if err != nil {
-		return fmt.Errorf("opening tags file: %s", err)
+// This function reads the tags from the tags file. It should be called while
+// holding the lock of the file, and the tags are read along with the error
+// when the file is corrupted.
+func (nav *nav) loadTags() error {
+	lines, err := readDataLines(gTagsPath, func(line string) bool {
+		ind := strings.LastIndex(line, ":")
+		return ind > 0 && ind < len(line)-1
+	})
+	if err != nil && !errors.Is(err, errDataCorrupt) {
+		return err
 	}
-	defer f.Close()
-
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		text := scanner.Text()
 
-		ind := strings.LastIndex(text, ":")
-		if ind == -1 {
-			return fmt.Errorf("invalid tags file entry: %s", text)
-		}
-
-		path := text[0:ind]
-		tag := text[ind+1:]
+	clear(nav.tags)
+	for _, line := range lines {
+		ind := strings.LastIndex(line, ":")
+		path := line[0:ind]
+		tag := line[ind+1:]
 		if _, ok := nav.tags[path]; !ok {
 			nav.tags[path] = tag
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("reading tags file: %s", err)
-	}
+	return err
+}
 
+func (nav *nav) readTags() error {
+	if err := withDataLock(gTagsPath, nav.loadTags); err != nil {
+		return fmt.Errorf("tags file: %s", err)
+	}
 	return nil
 }
 
-func (nav *nav) writeTags() error {
-	if err := os.MkdirAll(filepath.Dir(gTagsPath), os.ModePerm); err != nil {
-		return fmt.Errorf("creating data directory: %s", err)
-	}
+// This function applies the given change to the tags and writes them to the
+// tags file, reading them again before the change in the same way as
+// 'updateMarks'.
+func (nav *nav) updateTags(change func() error) error {
+	var errLoad error
+	err := withDataLock(gTagsPath, func() error {
+		if err := nav.loadTags(); errors.Is(err, errDataCorrupt) {
+			errLoad = err
+		} else if err != nil {
+			return fmt.Errorf("tags file: %s", err)
+		}
 
-	f, err := os.Create(gTagsPath)
-	if err != nil {
-		return fmt.Errorf("creating tags file: %s", err)
-	}
-	defer f.Close()
+		if err := change(); err != nil {
+			return err
+		}
 
-	var keys []string
-	for k := range nav.tags {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
+		var keys []string
+		for k := range nav.tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
 
-	for _, k := range keys {
-		_, err = fmt.Fprintf(f, "%s:%s\n", k, nav.tags[k])
-		if err != nil {
+		var b bytes.Buffer
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s:%s\n", k, nav.tags[k])
+		}
+
+		if err := writeDataFile(gTagsPath, b.Bytes()); err != nil {
 			return fmt.Errorf("writing tags file: %s", err)
 		}
-	}
+		return nil
+	})
 
-	return nil
+	if err == nil && errLoad != nil {
+		return fmt.Errorf("tags file: %s", errLoad)
+	}
+	return err
 }
 
 func (nav *nav) currDir() *dir {
@@ -2044,6 +2818,18 @@ func (nav *nav) currSelections() []string {
 	return paths
 }
 
+// This function returns the most recently selected file, or an empty string
+// when there is no selection.
+func (nav *nav) lastSelection() string {
+	last, lastInd := "", -1
+	for path, index := range nav.selections {
+		if index > lastInd {
+			last, lastInd = path, index
+		}
+	}
+	return last
+}
+
 func (nav *nav) currFileOrSelections() (list []string, err error) {
 	sel := nav.currSelections()
 
@@ -2060,7 +2846,7 @@ func (nav *nav) currFileOrSelections() (list []string, err error) {
 func (nav *nav) calcDirSize() error {
 	calc := func(f *file) error {
 		if f.IsDir() {
-			total, err := copySize([]string{f.path})
+			total, err := dirSize(f.path)
 			if err != nil {
 				return err
 			}
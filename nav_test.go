@@ -0,0 +1,422 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReloadDelay(t *testing.T) {
+	defer func(rate int) { gOpts.reloadrate = rate }(gOpts.reloadrate)
+
+	now := time.Now()
+
+	tests := []struct {
+		rate int
+		last time.Time
+		min  time.Duration
+		max  time.Duration
+	}{
+		{0, now, 0, 0},
+		{10, time.Time{}, 0, 0},
+		{10, now.Add(-time.Second), 0, 0},
+		{10, now, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, now, 500 * time.Millisecond, time.Second},
+	}
+
+	for _, test := range tests {
+		gOpts.reloadrate = test.rate
+		if got := reloadDelay(test.last); got < test.min || got > test.max {
+			t.Errorf("at input '%d' expected delay between '%s' and '%s' but got '%s'", test.rate, test.min, test.max, got)
+		}
+	}
+}
+
+func TestReloadEntry(t *testing.T) {
+	path := t.TempDir()
+
+	for _, name := range []string{"a", "c"} {
+		if err := os.WriteFile(filepath.Join(path, name), nil, 0o644); err != nil {
+			t.Fatalf("writing file: %s", err)
+		}
+	}
+
+	nav := newNav(10)
+	d := newDir(path)
+	d.sort()
+	nav.dirs = []*dir{d}
+
+	names := func() []string {
+		var names []string
+		for _, f := range d.files {
+			names = append(names, f.Name())
+		}
+		return names
+	}
+
+	b := filepath.Join(path, "b")
+	if err := os.WriteFile(b, []byte("foo"), 0o644); err != nil {
+		t.Fatalf("writing file: %s", err)
+	}
+	nav.reloadEntry(b)
+	if got, exp := names(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("at input '%s' expected '%v' but got '%v'", b, exp, got)
+	}
+
+	if err := os.WriteFile(b, []byte("foobar"), 0o644); err != nil {
+		t.Fatalf("writing file: %s", err)
+	}
+	nav.reloadEntry(b)
+	if got := d.files[1].Size(); got != 6 {
+		t.Errorf("at input '%s' expected size '6' but got '%d'", b, got)
+	}
+
+	if err := os.Remove(filepath.Join(path, "a")); err != nil {
+		t.Fatalf("removing file: %s", err)
+	}
+	nav.reloadEntry(filepath.Join(path, "a"))
+	if got, exp := names(), []string{"b", "c"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("at input '%s' expected '%v' but got '%v'", filepath.Join(path, "a"), exp, got)
+	}
+}
+
+func TestResort(t *testing.T) {
+	defer func(hidden, reverse bool) {
+		gOpts.hidden, gOpts.reverse = hidden, reverse
+	}(gOpts.hidden, gOpts.reverse)
+
+	path := t.TempDir()
+	for _, name := range []string{".a", "b", ".c", "d", "e"} {
+		if err := os.WriteFile(filepath.Join(path, name), nil, 0o644); err != nil {
+			t.Fatalf("writing file: %s", err)
+		}
+	}
+
+	gOpts.hidden = true
+	gOpts.reverse = false
+	d := newDir(path)
+	d.sort()
+
+	tests := []struct {
+		sel     string
+		anchor  string
+		hidden  bool
+		reverse bool
+		exp     string
+		expInd  int
+	}{
+		{"b", "", true, true, "b", 2},
+		{"d", "b", true, false, "d", 3},
+		{".c", "", false, false, "b", 0},
+		{"e", "b", true, true, "e", 0},
+	}
+
+	for _, test := range tests {
+		d.sel(test.sel, 10)
+		d.visualAnchor = -1
+		if test.anchor != "" {
+			d.visualAnchor = slices.IndexFunc(d.files, func(f *file) bool { return f.Name() == test.anchor })
+		}
+
+		gOpts.hidden = test.hidden
+		gOpts.reverse = test.reverse
+		d.resort(10)
+
+		if got := d.name(); got != test.exp || d.ind != test.expInd {
+			t.Errorf("at input '%s' expected '%s' at '%d' but got '%s' at '%d'", test.sel, test.exp, test.expInd, got, d.ind)
+		}
+		if test.anchor != "" && d.files[d.visualAnchor].Name() != test.anchor {
+			t.Errorf("at input '%s' expected anchor '%s' but got '%s'", test.sel, test.anchor, d.files[d.visualAnchor].Name())
+		}
+	}
+}
+
+func TestCdMode(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	real := filepath.Join(root, "real")
+	link := filepath.Join(root, "link")
+	if err := os.MkdirAll(filepath.Join(real, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("creating symlink: %s", err)
+	}
+
+	tests := []struct {
+		path     string
+		physical bool
+		exp      string
+	}{
+		{real, false, ""},
+		{link, false, "logical"},
+		{filepath.Join(link, "sub"), false, "logical"},
+		{filepath.Join(real, "sub"), true, "physical"},
+	}
+
+	nav := &nav{}
+	for _, test := range tests {
+		nav.dirs = []*dir{{path: test.path}}
+		nav.physical = test.physical
+		if got := nav.cdMode(); got != test.exp {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.path, test.exp, got)
+		}
+	}
+}
+
+func TestLastDir(t *testing.T) {
+	defer func(mode string) { gAutocdMode = mode }(gAutocdMode)
+
+	tests := []struct {
+		mode       string
+		selections map[string]int
+		out        []string
+		exp        string
+	}{
+		{"dir", nil, nil, "/cwd"},
+		{"dir", map[string]int{"/a/x": 0}, nil, "/cwd"},
+		{"dir", nil, []string{"/b/y"}, "/cwd"},
+		{"file-dir", nil, nil, "/cwd"},
+		{"file-dir", map[string]int{"/a/x": 1, "/c/z": 0}, nil, "/a"},
+		{"file-dir", map[string]int{"/a/x": 1, "/c/z": 2}, nil, "/c"},
+		{"file-dir", nil, []string{"/b/y"}, "/b"},
+		{"file-dir", map[string]int{"/a/x": 1}, []string{"/b/y"}, "/b"},
+	}
+
+	for _, test := range tests {
+		gAutocdMode = test.mode
+		app := &app{
+			nav:          &nav{dirs: []*dir{{path: "/cwd"}}, selections: test.selections},
+			selectionOut: test.out,
+		}
+		if got := app.lastDir(); got != filepath.FromSlash(test.exp) {
+			t.Errorf("at input '%s' with '%v' expected '%s' but got '%s'", test.mode, test.selections, test.exp, got)
+		}
+	}
+}
+
+func TestPreviewCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandbox arguments are only tested on linux")
+	}
+
+	oldSandbox := gOpts.previewsandbox
+	defer func() { gOpts.previewsandbox = oldSandbox }()
+
+	cmd, err := previewCommand("pv", "file", "80")
+	if err != nil || !slices.Equal(cmd.Args, []string{"pv", "file", "80"}) {
+		t.Errorf("expected command without sandbox but got '%v' (%v)", cmd, err)
+	}
+
+	gOpts.previewsandbox = true
+
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+
+	if _, err := previewCommand("pv", "file"); err == nil {
+		t.Errorf("expected an error without bwrap")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bwrap"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err = previewCommand("pv", "file", "80")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !slices.Contains(cmd.Args, "--unshare-all") || !slices.Contains(cmd.Args, filepath.Join(dir, "cache")) {
+		t.Errorf("expected sandbox arguments but got '%v'", cmd.Args)
+	}
+	if exp := []string{"sh", "pv", "file", "80"}; !slices.Equal(cmd.Args[len(cmd.Args)-4:], exp) {
+		t.Errorf("expected command to end with '%v' but got '%v'", exp, cmd.Args)
+	}
+	if tmp := filepath.Join(dir, "cache", "lf", "tmp"); !slices.Contains(cmd.Env, "TMPDIR="+tmp) {
+		t.Errorf("expected TMPDIR to be '%s'", tmp)
+	}
+}
+
+func TestRegCache(t *testing.T) {
+	defer func(size int) {
+		gOpts.previewcachesize = size
+	}(gOpts.previewcachesize)
+
+	gOpts.previewcachesize = 2
+	nav := &nav{regCache: make(map[string]*reg), previewChan: make(chan string, 10), previewTimer: time.NewTimer(0)}
+	pane := &win{w: 40, h: 20}
+
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("foo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nav.cacheReg(&reg{path: "/a"})
+	nav.cacheReg(&reg{path: "/b"})
+	nav.loadReg("/a", pane, false)
+	nav.cacheReg(&reg{path: "/c"})
+	if _, ok := nav.regCache["/b"]; ok || len(nav.regCache) != 2 {
+		t.Errorf("expected least recently used preview to be removed but got '%v'", slices.Sorted(maps.Keys(nav.regCache)))
+	}
+
+	r := &reg{path: path, loadTime: time.Now(), modTime: s.ModTime(), size: s.Size(), width: 40, height: 20}
+	nav.checkReg(r, pane)
+	if len(nav.previewChan) != 0 {
+		t.Errorf("expected unchanged preview to be reused")
+	}
+
+	nav.checkReg(r, &win{w: 60, h: 20})
+	if len(nav.previewChan) != 1 {
+		t.Errorf("expected preview to be loaded again for a different pane size")
+	}
+
+	r.size = 0
+	nav.checkReg(r, pane)
+	if len(nav.previewChan) != 2 {
+		t.Errorf("expected preview to be loaded again for a different file size")
+	}
+}
+
+func TestDirCache(t *testing.T) {
+	defer func(files, size, ttl int) {
+		gOpts.dircachefiles = files
+		gOpts.dircachesize = size
+		gOpts.dircachettl = ttl
+	}(gOpts.dircachefiles, gOpts.dircachesize, gOpts.dircachettl)
+
+	gOpts.dircachefiles = 100
+	gOpts.dircachesize = 3
+	gOpts.dircachettl = 0
+
+	now := time.Now()
+	newCached := func(path string, files int, age time.Duration) *dir {
+		return &dir{path: path, allFiles: make([]*file, files), lastUse: now.Add(-age)}
+	}
+
+	curr := newCached("/curr", 10, time.Hour)
+	nav := &nav{dirs: []*dir{curr}, dirCache: map[string]*dir{
+		"/curr": curr,
+		"/a":    newCached("/a", 10, time.Minute),
+		"/b":    newCached("/b", 10, time.Second),
+		"/c":    newCached("/c", 10, 2*time.Minute),
+	}}
+
+	nav.trimDirCache()
+	if exp, got := []string{"/a", "/b", "/curr"}, slices.Sorted(maps.Keys(nav.dirCache)); !slices.Equal(got, exp) {
+		t.Errorf("expected cached directories '%v' but got '%v'", exp, got)
+	}
+
+	gOpts.dircachefiles = 25
+	nav.trimDirCache()
+	if exp, got := []string{"/b", "/curr"}, slices.Sorted(maps.Keys(nav.dirCache)); !slices.Equal(got, exp) {
+		t.Errorf("expected cached directories '%v' but got '%v'", exp, got)
+	}
+
+	gOpts.dircachettl = 1
+	nav.dirCache["/b"].lastUse = now.Add(-time.Minute)
+	nav.trimDirCache()
+	if exp, got := []string{"/curr"}, slices.Sorted(maps.Keys(nav.dirCache)); !slices.Equal(got, exp) {
+		t.Errorf("expected cached directories '%v' but got '%v'", exp, got)
+	}
+
+	nav.dirCache["/a"] = newCached("/a", 1, 0)
+	nav.invalidateDir("/a")
+	nav.invalidateDir("/curr")
+	if _, ok := nav.dirCache["/a"]; ok || nav.dirCache["/curr"] != curr || !curr.loadTime.IsZero() {
+		t.Errorf("expected invalidated directories to be read again")
+	}
+}
+
+func TestBoundHit(t *testing.T) {
+	defer func(wrapscroll, wrapscan bool) {
+		gOpts.wrapscroll = wrapscroll
+		gOpts.wrapscan = wrapscan
+	}(gOpts.wrapscroll, gOpts.wrapscan)
+
+	gOpts.wrapscroll = false
+	gOpts.wrapscan = true
+
+	files := []*file{{path: "/a"}, {path: "/b"}, {path: "/c"}}
+	nav := &nav{dirs: []*dir{{path: "/", files: files, allFiles: files}}, height: 10}
+
+	if nav.down(1); nav.boundHit != "" {
+		t.Errorf("expected no boundary hit but got '%s'", nav.boundHit)
+	}
+	if nav.up(1); nav.boundHit != "" {
+		t.Errorf("expected no boundary hit but got '%s'", nav.boundHit)
+	}
+	if nav.up(1); nav.boundHit != "up" {
+		t.Errorf("expected boundary hit 'up' but got '%s'", nav.boundHit)
+	}
+
+	gOpts.wrapscroll = true
+	nav.boundHit = ""
+	if moved := nav.up(1); !moved || nav.boundHit != "up" || nav.currDir().ind != 2 {
+		t.Errorf("expected wrapping to hit 'up' boundary")
+	}
+	nav.boundHit = ""
+	if moved := nav.down(1); !moved || nav.boundHit != "down" || nav.currDir().ind != 0 {
+		t.Errorf("expected wrapping to hit 'down' boundary")
+	}
+}
+
+func TestCompoundSort(t *testing.T) {
+	defer func(sortby sortMethod, reverse bool) {
+		gOpts.sortby, gOpts.reverse = sortby, reverse
+	}(gOpts.sortby, gOpts.reverse)
+
+	path := t.TempDir()
+	for name, size := range map[string]int{"a.txt": 3, "b.go": 1, "c.txt": 1, "d.go": 3} {
+		if err := os.WriteFile(filepath.Join(path, name), make([]byte, size), 0o644); err != nil {
+			t.Fatalf("writing file: %s", err)
+		}
+	}
+	d := newDir(path)
+
+	tests := []struct {
+		sortby  sortMethod
+		reverse bool
+		exp     []string
+	}{
+		{"size,name", false, []string{"b.go", "c.txt", "a.txt", "d.go"}},
+		{"ext,size", false, []string{"b.go", "d.go", "c.txt", "a.txt"}},
+		{"ext,size", true, []string{"a.txt", "c.txt", "d.go", "b.go"}},
+		{"size,ext,name", true, []string{"a.txt", "d.go", "c.txt", "b.go"}},
+	}
+
+	for _, test := range tests {
+		gOpts.sortby, gOpts.reverse = test.sortby, test.reverse
+		d.sort()
+
+		var got []string
+		for _, f := range d.files {
+			got = append(got, f.Name())
+		}
+		if !slices.Equal(got, test.exp) {
+			t.Errorf("at input '%s' (reverse '%t') expected '%v' but got '%v'", test.sortby, test.reverse, test.exp, got)
+		}
+	}
+
+	for _, method := range []sortMethod{"name", "ext,name", "natural,size"} {
+		if !isValidSortMethod(method) {
+			t.Errorf("at input '%s' expected a valid sort method", method)
+		}
+	}
+	for _, method := range []sortMethod{"", "ext,", "name,foo"} {
+		if isValidSortMethod(method) {
+			t.Errorf("at input '%s' expected an invalid sort method", method)
+		}
+	}
+}
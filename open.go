@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// When the 'opengroups' option is enabled and multiple files are selected, the
+// 'open' command is run once for each group of files with the same MIME type
+// instead of only for the current file, so that an opener can be launched
+// once with all files of a group as arguments (e.g. an editor with all text
+// files). Files of the group are exported in '$fs' and '$fx' while the 'open'
+// command is running, and the MIME type is exported in '$lf_open_mime'.
+
+type openGroup struct {
+	mime  string
+	files []string
+}
+
+// This function groups the given files by their MIME types, keeping the order
+// of the first file in each group and the order of files within groups.
+func groupFiles(paths, mimes []string) []openGroup {
+	var groups []openGroup
+	index := make(map[string]int)
+	for i, path := range paths {
+		j, ok := index[mimes[i]]
+		if !ok {
+			j = len(groups)
+			index[mimes[i]] = j
+			groups = append(groups, openGroup{mime: mimes[i]})
+		}
+		groups[j].files = append(groups[j].files, path)
+	}
+	return groups
+}
+
+// This function returns the MIME types of the given files using the 'file'
+// command, which is also used in opener scripts. Types are detected from the
+// contents of files when the command is not available.
+func mimeTypes(paths []string) []string {
+	out, err := exec.Command("file", append([]string{"--mime-type", "-Lb", "--"}, paths...)...).Output()
+	if err == nil {
+		mimes := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		if len(mimes) == len(paths) {
+			return mimes
+		}
+	}
+	log.Printf("detecting mime types with file command: %v", err)
+
+	mimes := make([]string, len(paths))
+	for i, path := range paths {
+		mimes[i] = detectMime(path)
+	}
+	return mimes
+}
+
+func detectMime(path string) string {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	if stat.IsDir() {
+		return "inode/directory"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, f, 512); err != nil && err != io.EOF {
+		return "application/octet-stream"
+	}
+
+	mime, _, _ := strings.Cut(http.DetectContentType(buf.Bytes()), ";")
+	return mime
+}
+
+func (app *app) openGroups(cmd expr, args []string) {
+	list := app.nav.currSelections()
+	if !gOpts.opengroups || len(list) < 2 {
+		cmd.eval(app, args)
+		return
+	}
+
+	defer func() {
+		app.nav.openGroup = nil
+		os.Unsetenv("lf_open_mime")
+	}()
+
+	for _, group := range groupFiles(list, mimeTypes(list)) {
+		app.nav.openGroup = group.files
+		os.Setenv("lf_open_mime", group.mime)
+		cmd.eval(app, args)
+	}
+}
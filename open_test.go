@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGroupFiles(t *testing.T) {
+	paths := []string{"/a.txt", "/b.png", "/c.txt", "/d.png", "/e.pdf"}
+	mimes := []string{"text/plain", "image/png", "text/plain", "image/png", "application/pdf"}
+
+	exp := []openGroup{
+		{"text/plain", []string{"/a.txt", "/c.txt"}},
+		{"image/png", []string{"/b.png", "/d.png"}},
+		{"application/pdf", []string{"/e.pdf"}},
+	}
+
+	if got := groupFiles(paths, mimes); !reflect.DeepEqual(got, exp) {
+		t.Errorf("at input '%v' expected '%v' but got '%v'", paths, exp, got)
+	}
+}
+
+func TestDetectMime(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"text":  "hello world\n",
+		"png":   "\x89PNG\x0D\x0A\x1A\x0A",
+		"empty": "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		exp  string
+	}{
+		{"text", "text/plain"},
+		{"png", "image/png"},
+		{"empty", "text/plain"},
+		{"missing", "application/octet-stream"},
+		{".", "inode/directory"},
+	}
+
+	for _, test := range tests {
+		if got := detectMime(filepath.Join(dir, test.path)); got != test.exp {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.path, test.exp, got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Files opened with the 'open' command are counted along with the time of
+// their last opening. The history is tracked by the server so that it is
+// shared between clients while the server is running, and clients keep a copy
+// of it updated by the server with the 'open-history' command, which is also
+// sent for the whole history when a client connects. The history is kept by
+// the client itself in single mode. The number of earlier openings and the
+// time of the last one are exported to the opener in '$lf_open_count' and
+// '$lf_open_last' (e.g. to resume a video where it was left off), and files
+// can be sorted with 'sortby lastopened'.
+
+type openRecord struct {
+	count int
+	last  time.Time
+}
+
+type openHistory struct {
+	mutex   sync.Mutex
+	records map[string]openRecord
+}
+
+var gOpenHistory = openHistory{records: make(map[string]openRecord)}
+
+func (h *openHistory) get(path string) openRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.records[path]
+}
+
+func (h *openHistory) set(path string, r openRecord) {
+	h.mutex.Lock()
+	h.records[path] = r
+	h.mutex.Unlock()
+}
+
+// This function counts an opening of the given file at the given time and
+// returns the updated record.
+func (h *openHistory) add(path string, t time.Time) openRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	r := h.records[path]
+	r.count++
+	r.last = t
+	h.records[path] = r
+	return r
+}
+
+// This function returns the 'open-history' command to send the given record to
+// clients.
+func openHistoryCmd(path string, r openRecord) string {
+	return fmt.Sprintf("open-history %d %d %s", r.count, r.last.Unix(), escape(path))
+}
+
+func (h *openHistory) cmds() []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var cmds []string
+	for path, r := range h.records {
+		cmds = append(cmds, openHistoryCmd(path, r))
+	}
+	return cmds
+}
+
+// This function exports the history of the given file before it is opened.
+// The time of the last opening is exported as seconds since the Unix epoch,
+// and it is empty when the file is opened for the first time.
+func exportOpenHistory(path string) {
+	r := gOpenHistory.get(path)
+	os.Setenv("lf_open_count", strconv.Itoa(r.count))
+	if r.count == 0 {
+		os.Setenv("lf_open_last", "")
+	} else {
+		os.Setenv("lf_open_last", strconv.FormatInt(r.last.Unix(), 10))
+	}
+}
+
+// This function records the given files as opened for all clients.
+func (app *app) recordOpen(paths []string) {
+	if gSingleMode {
+		now := time.Now()
+		for _, path := range paths {
+			gOpenHistory.add(path, now)
+		}
+		app.sortLastOpened()
+		return
+	}
+
+	// paths are sent one per line as the rest of the line
+	var cmds []string
+	for _, path := range paths {
+		if !strings.ContainsAny(path, "\r\n") {
+			cmds = append(cmds, "record-open "+path)
+		}
+	}
+	if len(cmds) == 0 {
+		return
+	}
+	if err := remote(strings.Join(cmds, "\n")); err != nil {
+		log.Printf("recording open history: %s", err)
+	}
+}
+
+// This function applies the 'open-history' command sent by the server.
+func (app *app) setOpenHistory(args []string) {
+	if len(args) != 3 {
+		app.ui.echoerr("open-history: requires a count, a time and a path")
+		return
+	}
+	count, err := strconv.Atoi(args[0])
+	if err != nil {
+		app.ui.echoerrf("open-history: %s", err)
+		return
+	}
+	sec, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		app.ui.echoerrf("open-history: %s", err)
+		return
+	}
+	gOpenHistory.set(args[2], openRecord{count, time.Unix(sec, 0)})
+	app.sortLastOpened()
+}
+
+// This function sorts the loaded directories again when any of them is sorted
+// by the time of the last opening.
+func (app *app) sortLastOpened() {
+	if !app.nav.init {
+		return
+	}
+	for _, d := range app.nav.dirs {
+		if d.sortby.has(lastOpenedSort) {
+			app.nav.sort()
+			app.ui.sort()
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenHistory(t *testing.T) {
+	defer func(records map[string]openRecord, sortby sortMethod, dirfirst bool) {
+		gOpenHistory.records = records
+		gOpts.sortby, gOpts.dirfirst = sortby, dirfirst
+	}(gOpenHistory.records, gOpts.sortby, gOpts.dirfirst)
+	gOpenHistory.records = make(map[string]openRecord)
+	t.Setenv("lf_open_count", "")
+	t.Setenv("lf_open_last", "")
+
+	path := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(path, name), nil, 0o644); err != nil {
+			t.Fatalf("writing file: %s", err)
+		}
+	}
+
+	a, c := filepath.Join(path, "a"), filepath.Join(path, "c")
+
+	exportOpenHistory(a)
+	if count, last := os.Getenv("lf_open_count"), os.Getenv("lf_open_last"); count != "0" || last != "" {
+		t.Errorf("expected count '0' and empty time but got '%s' and '%s'", count, last)
+	}
+
+	now := time.Unix(1700000000, 0)
+	gOpenHistory.add(c, now.Add(-time.Hour))
+	gOpenHistory.add(a, now.Add(-time.Hour))
+	gOpenHistory.add(a, now)
+
+	exportOpenHistory(a)
+	if count, last := os.Getenv("lf_open_count"), os.Getenv("lf_open_last"); count != "2" || last != "1700000000" {
+		t.Errorf("expected count '2' and time '1700000000' but got '%s' and '%s'", count, last)
+	}
+
+	gOpts.sortby = lastOpenedSort
+	gOpts.dirfirst = false
+	d := newDir(path)
+	d.sort()
+
+	var got []string
+	for _, f := range d.files {
+		got = append(got, f.Name())
+	}
+	if exp := "b c a"; strings.Join(got, " ") != exp {
+		t.Errorf("expected '%s' but got '%s'", exp, strings.Join(got, " "))
+	}
+}
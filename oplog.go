@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// The operation log is a file set with the 'oplogjson' option, where each file
+// handled by a file operation (i.e. copy, move, delete and rename) is appended
+// as a single line of JSON after it is finished, so that other programs can
+// follow the activity of lf. Unlike the audit log, there is a line for each
+// file with its destination, size in bytes and result.
+
+type opLogEntry struct {
+	Time        string `json:"time"`
+	Client      int    `json:"client"`
+	Op          string `json:"op"`
+	Source      string `json:"source"`
+	Destination string `json:"destination,omitempty"`
+	Bytes       int64  `json:"bytes"`
+	DurationMs  int64  `json:"duration_ms"`
+	Result      string `json:"result"`
+	Error       string `json:"error,omitempty"`
+}
+
+// This function returns the entry for a finished file, where the result is
+// either 'ok', 'skipped' or 'failed' depending on the reasons.
+func newOpLogEntry(op, src, dst string, bytes int64, start time.Time, skipped bool, reasons []string) opLogEntry {
+	result := "ok"
+	switch {
+	case skipped:
+		result = "skipped"
+	case len(reasons) > 0:
+		result = "failed"
+	}
+
+	return opLogEntry{
+		Time:        start.Format(time.RFC3339),
+		Client:      gClientID,
+		Op:          op,
+		Source:      src,
+		Destination: dst,
+		Bytes:       bytes,
+		DurationMs:  time.Since(start).Milliseconds(),
+		Result:      result,
+		Error:       strings.Join(reasons, "; "),
+	}
+}
+
+// This function returns the size of the given file for the operation log,
+// which is not calculated when the log is disabled.
+func opLogSize(path string) int64 {
+	if gOpts.oplogjson == "" {
+		return 0
+	}
+	n, _ := copySize([]string{path})
+	return n
+}
+
+// This function records a finished file in the operation log if the
+// 'oplogjson' option is set. It can be called from the goroutines running
+// file operations, so errors are sent to be shown in the main loop.
+func (app *app) opLog(op, src, dst string, bytes int64, start time.Time, skipped bool, reasons []string) {
+	if gOpts.oplogjson == "" {
+		return
+	}
+
+	e := newOpLogEntry(op, src, dst, bytes, start, skipped, reasons)
+	if err := appendJSONLine(replaceTilde(gOpts.oplogjson), e, "operation log"); err != nil {
+		app.ui.exprChan <- &callExpr{"echoerr", []string{"oplogjson: " + err.Error()}, 1}
+	}
+}
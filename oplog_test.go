@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewOpLogEntry(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		skipped bool
+		reasons []string
+		result  string
+		err     string
+	}{
+		{false, nil, "ok", ""},
+		{true, []string{"source and destination are the same file"}, "skipped", "source and destination are the same file"},
+		{false, []string{"copy: permission denied", "chtimes: permission denied"}, "failed", "copy: permission denied; chtimes: permission denied"},
+	}
+
+	for _, test := range tests {
+		e := newOpLogEntry("copy", "/foo", "/bar/foo", 42, start, test.skipped, test.reasons)
+		if e.Result != test.result || e.Error != test.err {
+			t.Errorf("at input '%v' expected '%s' and '%s' but got '%s' and '%s'", test.reasons, test.result, test.err, e.Result, e.Error)
+		}
+		if e.Source != "/foo" || e.Destination != "/bar/foo" || e.Bytes != 42 {
+			t.Errorf("at input '%v' got unexpected entry '%+v'", test.reasons, e)
+		}
+	}
+}
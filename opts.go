@@ -3,6 +3,8 @@ package main
 import (
 	"maps"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -19,98 +21,185 @@ const (
 	ctimeSort   sortMethod = "ctime"
 	extSort     sortMethod = "ext"
 	customSort  sortMethod = "custom"
+
+	lastOpenedSort sortMethod = "lastopened"
 )
 
+func isValidSortKey(key sortMethod) bool {
+	return key == naturalSort ||
+		key == nameSort ||
+		key == sizeSort ||
+		key == timeSort ||
+		key == atimeSort ||
+		key == btimeSort ||
+		key == ctimeSort ||
+		key == extSort ||
+		key == customSort ||
+		key == lastOpenedSort
+}
+
+// Sort methods can consist of several keys separated with commas (e.g.
+// 'ext,name'), in which case files equal in a key are sorted by the next one.
 func isValidSortMethod(method sortMethod) bool {
-	return method == naturalSort ||
-		method == nameSort ||
-		method == sizeSort ||
-		method == timeSort ||
-		method == atimeSort ||
-		method == btimeSort ||
-		method == ctimeSort ||
-		method == extSort ||
-		method == customSort
+	for _, key := range method.keys() {
+		if !isValidSortKey(key) {
+			return false
+		}
+	}
+	return true
+}
+
+func (method sortMethod) keys() []sortMethod {
+	var keys []sortMethod
+	for _, key := range strings.Split(string(method), ",") {
+		keys = append(keys, sortMethod(key))
+	}
+	return keys
 }
 
-const invalidSortErrorMessage = `sortby: value should either be 'natural', 'name', 'size', 'time', 'atime', 'btime', 'ctime', 'ext' or 'custom'`
+func (method sortMethod) has(key sortMethod) bool {
+	return slices.Contains(method.keys(), key)
+}
+
+// This function reports whether files are only sorted by their names, in
+// which case they do not need to be sorted again when they are changed.
+func (method sortMethod) byName() bool {
+	for _, key := range method.keys() {
+		if key != naturalSort && key != nameSort && key != extSort {
+			return false
+		}
+	}
+	return true
+}
+
+const invalidSortErrorMessage = `sortby: value should either be 'natural', 'name', 'size', 'time', 'atime', 'btime', 'ctime', 'ext', 'custom' or 'lastopened', or several of them separated with commas`
 
 var gOpts struct {
-	anchorfind       bool
-	autoquit         bool
-	borderfmt        string
-	copyfmt          string
-	cursoractivefmt  string
-	cursorparentfmt  string
-	cursorpreviewfmt string
-	cutfmt           string
-	dircache         bool
-	dircounts        bool
-	dirfirst         bool
-	dironly          bool
-	dirpreviews      bool
-	drawbox          bool
-	dupfilefmt       string
-	globfilter       bool
-	globsearch       bool
-	hidden           bool
-	icons            bool
-	ignorecase       bool
-	ignoredia        bool
-	incfilter        bool
-	incsearch        bool
-	locale           string
-	mouse            bool
-	number           bool
-	preview          bool
-	relativenumber   bool
-	reverse          bool
-	roundbox         bool
-	selectfmt        string
-	visualfmt        string
-	showbinds        bool
-	sixel            bool
-	sortby           sortMethod
-	smartcase        bool
-	smartdia         bool
-	waitmsg          string
-	watch            bool
-	wrapscan         bool
-	wrapscroll       bool
-	findlen          int
-	period           int
-	scrolloff        int
-	tabstop          int
-	errorfmt         string
-	filesep          string
-	ifs              string
-	previewer        string
-	cleaner          string
-	promptfmt        string
-	selmode          string
-	shell            string
-	shellflag        string
-	statfmt          string
-	timefmt          string
-	infotimefmtnew   string
-	infotimefmtold   string
-	truncatechar     string
-	truncatepct      int
-	ratios           []int
-	hiddenfiles      []string
-	history          bool
-	info             []string
-	rulerfmt         string
-	preserve         []string
-	shellopts        []string
-	nkeys            map[string]expr
-	vkeys            map[string]expr
-	cmdkeys          map[string]expr
-	cmds             map[string]expr
-	user             map[string]string
-	tempmarks        string
-	numberfmt        string
-	tagfmt           string
+	anchorfind        bool
+	archivedirs       bool
+	autoquit          bool
+	autosavesession   bool
+	auditlog          bool
+	borderfmt         string
+	clipboardhistory  int
+	copyfmt           string
+	cursoractivefmt   string
+	cursorparentfmt   string
+	cursorpreviewfmt  string
+	cutfmt            string
+	dircache          bool
+	dircachefiles     int
+	dircachesize      int
+	dircachettl       int
+	dircounts         bool
+	dirfirst          bool
+	dironly           bool
+	dirpreviews       bool
+	drawbox           bool
+	gitstatus         bool
+	dryrun            bool
+	dualpane          bool
+	dupfilefmt        string
+	globfilter        bool
+	globsearch        bool
+	hidden            bool
+	icons             bool
+	ignorecase        bool
+	ignoredia         bool
+	imagedirs         bool
+	incfilter         bool
+	incfind           bool
+	incsearch         bool
+	jumptrack         bool
+	lfenv             bool
+	livecd            bool
+	locale            string
+	mouse             bool
+	number            bool
+	onefilesystem     bool
+	opengroups        bool
+	pastequote        bool
+	preview           bool
+	previewsandbox    bool
+	relativenumber    bool
+	reverse           bool
+	roundbox          bool
+	searchgitignore   bool
+	selectfmt         string
+	visualfmt         string
+	shellpager        bool
+	showbinds         bool
+	singlecolumn      bool
+	sixel             bool
+	sortby            sortMethod
+	smartcase         bool
+	smartdia          bool
+	trustsource       bool
+	waitmsg           string
+	watch             bool
+	wrapscan          bool
+	wrapscroll        bool
+	autosave          int
+	databackups       int
+	findlen           int
+	previewcachesize  int
+	fuzzydepth        int
+	period            int
+	reloadrate        int
+	scrolloff         int
+	tabstop           int
+	compresslevel     int
+	archiveformat     string
+	autoextract       string
+	imagepreview      string
+	errorfmt          string
+	filesep           string
+	followsymlinkdirs string
+	gitstatusfmt      string
+	hopchars          string
+	hopfmt            string
+	ifs               string
+	jumpfile          string
+	jumpranking       string
+	previewer         string
+	cleaner           string
+	promptfmt         string
+	selmode           string
+	shell             string
+	shellflag         string
+	statfmt           string
+	tabbar            string
+	timefmt           string
+	infotimefmtnew    string
+	infotimefmtold    string
+	truncatechar      string
+	truncatepct       int
+	warnsize          int64
+	ratios            []int
+	hiddenfiles       []string
+	history           bool
+	hoverdirsize      bool
+	hoverpreviewdir   bool
+	linkedpane        bool
+	info              []string
+	columns           []string
+	rulerfmt          string
+	preserve          []string
+	boundbell         []string
+	boundflash        []string
+	shellopts         []string
+	nkeys             map[string]expr
+	vkeys             map[string]expr
+	cmdkeys           map[string]expr
+	cmds              map[string]expr
+	user              map[string]string
+	tempmarks         string
+	terminalcmd       string
+	keytranslate      string
+	mountmarker       string
+	oplogjson         string
+	numberfmt         string
+	tagfmt            string
 }
 
 var gLocalOpts struct {
@@ -121,6 +210,7 @@ var gLocalOpts struct {
 	hidden    map[string]bool
 	reverse   map[string]bool
 	info      map[string][]string
+	preview   map[string]bool
 	locale    map[string]string
 }
 
@@ -132,87 +222,119 @@ func localOptPaths(path string) []string {
 	return list
 }
 
-func getDirCounts(path string) bool {
-	for _, key := range localOptPaths(path) {
-		if val, ok := gLocalOpts.dircounts[key]; ok {
-			return val
-		}
+// Local options can also be set for directories matching a glob pattern (e.g.
+// '~/src/*'), which is matched against the whole path of a directory. As with
+// paths, patterns with a trailing path separator also match subdirectories.
+// Options set for paths take precedence over patterns, and patterns set later
+// take precedence over earlier ones, so patterns are kept in the order they
+// are set.
+var gLocalPatterns []string
+
+func isLocalPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func addLocalPattern(pattern string) {
+	if !slices.Contains(gLocalPatterns, pattern) {
+		gLocalPatterns = append(gLocalPatterns, pattern)
 	}
-	return gOpts.dircounts
 }
 
-func getDirFirst(path string) bool {
-	for _, key := range localOptPaths(path) {
-		if val, ok := gLocalOpts.dirfirst[key]; ok {
-			return val
+func matchLocalPattern(pattern, path string) bool {
+	sep := string(filepath.Separator)
+	if !strings.HasSuffix(pattern, sep) {
+		ok, _ := filepath.Match(pattern, path)
+		return ok
+	}
+
+	pattern = strings.TrimSuffix(pattern, sep)
+	for curr := path; ; curr = filepath.Dir(curr) {
+		if ok, _ := filepath.Match(pattern, curr); ok {
+			return true
+		}
+		if isRoot(curr) {
+			return false
 		}
 	}
-	return gOpts.dirfirst
 }
 
-func getDirOnly(path string) bool {
+// This function returns the value of a local option for the given directory,
+// or the given global value when it is not set for the directory.
+func getLocalOpt[T any](opts map[string]T, path string, global T) T {
 	for _, key := range localOptPaths(path) {
-		if val, ok := gLocalOpts.dironly[key]; ok {
+		if val, ok := opts[key]; ok {
 			return val
 		}
 	}
-	return gOpts.dironly
-}
-
-func getHidden(path string) bool {
-	for _, key := range localOptPaths(path) {
-		if val, ok := gLocalOpts.hidden[key]; ok {
+	for _, pattern := range slices.Backward(gLocalPatterns) {
+		if val, ok := opts[pattern]; ok && matchLocalPattern(pattern, path) {
 			return val
 		}
 	}
-	return gOpts.hidden
+	return global
+}
+
+func getDirCounts(path string) bool {
+	return getLocalOpt(gLocalOpts.dircounts, path, gOpts.dircounts)
+}
+
+func getDirFirst(path string) bool {
+	return getLocalOpt(gLocalOpts.dirfirst, path, gOpts.dirfirst)
+}
+
+func getDirOnly(path string) bool {
+	return getLocalOpt(gLocalOpts.dironly, path, gOpts.dironly)
+}
+
+func getHidden(path string) bool {
+	return getLocalOpt(gLocalOpts.hidden, path, gOpts.hidden)
 }
 
 func getInfo(path string) []string {
-	for _, key := range localOptPaths(path) {
-		if val, ok := gLocalOpts.info[key]; ok {
-			return val
-		}
-	}
-	return gOpts.info
+	return getLocalOpt(gLocalOpts.info, path, gOpts.info)
+}
+
+// This function reports whether files are previewed in the given directory.
+// The preview pane is only shown with the global option, so the local option
+// can only disable previews.
+func getPreview(path string) bool {
+	return gOpts.preview && getLocalOpt(gLocalOpts.preview, path, gOpts.preview)
 }
 
 func getReverse(path string) bool {
-	for _, key := range localOptPaths(path) {
-		if val, ok := gLocalOpts.reverse[key]; ok {
-			return val
-		}
-	}
-	return gOpts.reverse
+	return getLocalOpt(gLocalOpts.reverse, path, gOpts.reverse)
 }
 
 func getSortBy(path string) sortMethod {
-	for _, key := range localOptPaths(path) {
-		if val, ok := gLocalOpts.sortby[key]; ok {
-			return val
-		}
-	}
-	return gOpts.sortby
+	return getLocalOpt(gLocalOpts.sortby, path, gOpts.sortby)
 }
 
 func getLocale(path string) string {
-	for _, key := range localOptPaths(path) {
-		if val, ok := gLocalOpts.locale[key]; ok {
-			return val
-		}
-	}
-	return gOpts.locale
+	return getLocalOpt(gLocalOpts.locale, path, gOpts.locale)
 }
 
 func init() {
 	gOpts.anchorfind = true
+	gOpts.archivedirs = false
 	gOpts.autoquit = true
+	gOpts.autosavesession = false
+	gOpts.auditlog = false
+	gOpts.autoextract = "never"
+	gOpts.imagepreview = "none"
+	gOpts.archiveformat = "tar.gz"
+	gOpts.compresslevel = 0
 	gOpts.dircache = true
+	gOpts.clipboardhistory = 10
+	gOpts.dircachefiles = 1000000
+	gOpts.dircachesize = 1000
+	gOpts.dircachettl = 0
 	gOpts.dircounts = false
 	gOpts.dirfirst = true
 	gOpts.dironly = false
 	gOpts.dirpreviews = false
 	gOpts.drawbox = false
+	gOpts.dryrun = false
+	gOpts.dualpane = false
 	gOpts.dupfilefmt = "%f.~%n~"
 	gOpts.borderfmt = "\033[0m"
 	gOpts.copyfmt = "\033[7;33m"
@@ -226,54 +348,92 @@ func init() {
 	gOpts.icons = false
 	gOpts.ignorecase = true
 	gOpts.ignoredia = true
+	gOpts.imagedirs = false
 	gOpts.incfilter = false
+	gOpts.incfind = false
 	gOpts.incsearch = false
+	gOpts.lfenv = false
+	gOpts.livecd = false
 	gOpts.locale = localeStrDisable
 	gOpts.mouse = false
 	gOpts.number = false
+	gOpts.onefilesystem = false
+	gOpts.opengroups = false
+	gOpts.pastequote = false
 	gOpts.preview = true
+	gOpts.previewsandbox = false
 	gOpts.relativenumber = false
 	gOpts.reverse = false
 	gOpts.roundbox = false
+	gOpts.searchgitignore = false
 	gOpts.selectfmt = "\033[7;35m"
 	gOpts.visualfmt = "\033[7;36m"
+	gOpts.shellpager = false
 	gOpts.showbinds = true
+	gOpts.singlecolumn = true
 	gOpts.sixel = false
 	gOpts.sortby = naturalSort
 	gOpts.smartcase = true
 	gOpts.smartdia = false
+	gOpts.trustsource = false
 	gOpts.waitmsg = "Press any key to continue"
 	gOpts.watch = false
 	gOpts.wrapscan = true
 	gOpts.wrapscroll = false
+	gOpts.autosave = 0
+	gOpts.databackups = 0
 	gOpts.findlen = 1
+	gOpts.previewcachesize = 1000
+	gOpts.fuzzydepth = 5
+	gOpts.followsymlinkdirs = "never"
+	gOpts.gitstatus = false
+	gOpts.gitstatusfmt = "modified=\033[33mM:staged=\033[32mS:untracked=\033[31m?:ignored=\033[90m!"
+	gGitStatusFmts, gGitStatusWidth, _ = parseGitStatusFmt(gOpts.gitstatusfmt)
 	gOpts.period = 0
+	gOpts.reloadrate = 10
 	gOpts.scrolloff = 0
 	gOpts.tabstop = 8
 	gOpts.errorfmt = "\033[7;31;47m"
 	gOpts.filesep = "\n"
+	gOpts.hopchars = "asdfghjklqwertyuiopzxcvbnm"
+	gOpts.hopfmt = "\033[1;7m"
 	gOpts.ifs = ""
+	gOpts.jumpfile = ""
+	gOpts.jumpranking = "frecency"
+	gOpts.jumptrack = true
 	gOpts.previewer = ""
 	gOpts.cleaner = ""
+	gOpts.terminalcmd = defaultTerminal()
 	gOpts.promptfmt = "\033[32;1m%u@%h\033[0m:\033[34;1m%d\033[0m\033[1m%f\033[0m"
 	gOpts.selmode = "all"
 	gOpts.shell = gDefaultShell
 	gOpts.shellflag = gDefaultShellFlag
 	gOpts.statfmt = "\033[36m%p\033[0m| %c| %u| %g| %S| %t| -> %l"
+	gOpts.tabbar = "row"
 	gOpts.timefmt = time.ANSIC
 	gOpts.infotimefmtnew = "Jan _2 15:04"
 	gOpts.infotimefmtold = "Jan _2  2006"
 	gOpts.truncatechar = "~"
 	gOpts.truncatepct = 100
+	gOpts.warnsize = 0
 	gOpts.ratios = []int{1, 2, 3}
 	gOpts.hiddenfiles = gDefaultHiddenFiles
 	gOpts.history = true
+	gOpts.hoverdirsize = false
+	gOpts.hoverpreviewdir = false
+	gOpts.linkedpane = false
 	gOpts.info = nil
+	gOpts.columns = nil
 	gOpts.rulerfmt = "  %a|  %p|  \033[7;31m %m \033[0m|  \033[7;33m %c \033[0m|  \033[7;35m %s \033[0m|  \033[7;36m %v \033[0m|  \033[7;34m %f \033[0m|  %i/%t"
 	gOpts.preserve = []string{"mode"}
+	gOpts.boundbell = nil
+	gOpts.boundflash = nil
 	gOpts.shellopts = nil
 	gOpts.tempmarks = "'"
+	gOpts.keytranslate = ""
 	gOpts.numberfmt = "\033[33m"
+	gOpts.mountmarker = "^"
+	gOpts.oplogjson = ""
 	gOpts.tagfmt = "\033[31m"
 
 	// Normal and Visual mode
@@ -315,7 +475,9 @@ func init() {
 		"c":          &callExpr{"clear", nil, 1},
 		"p":          &callExpr{"paste", nil, 1},
 		"<c-l>":      &callExpr{"redraw", nil, 1},
+		"<c-z>":      &callExpr{"suspend", nil, 1},
 		"<c-r>":      &callExpr{"reload", nil, 1},
+		"<tab>":      &callExpr{"pane-switch", nil, 1},
 		":":          &callExpr{"read", nil, 1},
 		"$":          &callExpr{"shell", nil, 1},
 		"%":          &callExpr{"shell-pipe", nil, 1},
@@ -350,6 +512,8 @@ func init() {
 		"sc": &listExpr{[]expr{&setExpr{"sortby", "ctime"}, &setExpr{"info", "ctime"}}, 1},
 		"se": &listExpr{[]expr{&setExpr{"sortby", "ext"}, &setExpr{"info", ""}}, 1},
 		"gh": &callExpr{"cd", []string{"~"}, 1},
+		"gt": &callExpr{"tab-next", nil, 1},
+		"gT": &callExpr{"tab-prev", nil, 1},
 	}
 
 	// insert bindings that apply to both Normal & Visual mode first
@@ -393,6 +557,7 @@ func init() {
 		"<c-w>":          &callExpr{"cmd-delete-unix-word", nil, 1},
 		"<c-y>":          &callExpr{"cmd-yank", nil, 1},
 		"<c-t>":          &callExpr{"cmd-transpose", nil, 1},
+		"<c-v>":          &callExpr{"cmd-literal", nil, 1},
 		"<c-c>":          &callExpr{"cmd-interrupt", nil, 1},
 		"<a-f>":          &callExpr{"cmd-word", nil, 1},
 		"<a-b>":          &callExpr{"cmd-word-back", nil, 1},
@@ -415,6 +580,7 @@ func init() {
 	gLocalOpts.hidden = make(map[string]bool)
 	gLocalOpts.reverse = make(map[string]bool)
 	gLocalOpts.info = make(map[string][]string)
+	gLocalOpts.preview = make(map[string]bool)
 	gLocalOpts.locale = make(map[string]string)
 
 	setDefaults()
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLocalOptPatterns(t *testing.T) {
+	defer func(hidden map[string]bool, patterns []string, global bool) {
+		gLocalOpts.hidden, gLocalPatterns, gOpts.hidden = hidden, patterns, global
+	}(gLocalOpts.hidden, gLocalPatterns, gOpts.hidden)
+
+	gOpts.hidden = false
+	gLocalPatterns = nil
+	gLocalOpts.hidden = map[string]bool{
+		"/src/*":        true,
+		"/src/*/vendor": false,
+		"/src/lf":       false,
+		"/data/*/":      true,
+	}
+	for _, pattern := range []string{"/src/*", "/src/*/vendor", "/data/*/"} {
+		addLocalPattern(pattern)
+	}
+
+	tests := []struct {
+		path string
+		exp  bool
+	}{
+		{"/src", false},
+		{"/src/foo", true},
+		{"/src/lf", false},
+		{"/src/foo/vendor", false},
+		{"/src/foo/bar", false},
+		{"/data", false},
+		{"/data/foo", true},
+		{"/data/foo/bar/baz", true},
+	}
+
+	for _, test := range tests {
+		if got := getHidden(test.path); got != test.exp {
+			t.Errorf("at input '%s' expected '%t' but got '%t'", test.path, test.exp, got)
+		}
+	}
+}
+
+func TestGetPreview(t *testing.T) {
+	defer func(preview map[string]bool, global bool) {
+		gLocalOpts.preview, gOpts.preview = preview, global
+	}(gLocalOpts.preview, gOpts.preview)
+
+	gLocalOpts.preview = map[string]bool{
+		"/media": false,
+		"/src":   true,
+	}
+
+	tests := []struct {
+		path   string
+		global bool
+		exp    bool
+	}{
+		{"/media", true, false},
+		{"/src", true, true},
+		{"/home", true, true},
+		{"/src", false, false},
+		{"/home", false, false},
+	}
+
+	for _, test := range tests {
+		gOpts.preview = test.global
+		if got := getPreview(test.path); got != test.exp {
+			t.Errorf("at input '%s' with global '%t' expected '%t' but got '%t'", test.path, test.global, test.exp, got)
+		}
+	}
+}
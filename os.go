@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"os/user"
 	"path/filepath"
 	"runtime"
@@ -34,14 +35,22 @@ var (
 )
 
 var (
-	gUser        *user.User
-	gConfigPaths []string
-	gColorsPaths []string
-	gIconsPaths  []string
-	gFilesPath   string
-	gMarksPath   string
-	gTagsPath    string
-	gHistoryPath string
+	gUser         *user.User
+	gConfigPaths  []string
+	gColorsPaths  []string
+	gIconsPaths   []string
+	gFilesPath    string
+	gMarksPath    string
+	gTagsPath     string
+	gHistoryPath  string
+	gAuditPath    string
+	gRecoveryPath string
+	gTrustPath    string
+	gSortsPath    string
+	gSessionPath  string
+	gJumpPath     string
+	gZoxidePath   string
+	gRemotePath   string
 )
 
 func init() {
@@ -84,6 +93,12 @@ func init() {
 	}
 	gUser = u
 
+	initPaths()
+}
+
+// This function sets the paths of the configuration and data files and the
+// socket, which can be changed with environment variables.
+func initPaths() {
 	config := cmp.Or(
 		os.Getenv("LF_CONFIG_HOME"),
 		os.Getenv("XDG_CONFIG_HOME"),
@@ -105,6 +120,8 @@ func init() {
 		filepath.Join(config, "lf", "icons"),
 	}
 
+	gRemotePath = filepath.Join(config, "lf", "remote")
+
 	data := cmp.Or(
 		os.Getenv("LF_DATA_HOME"),
 		os.Getenv("XDG_DATA_HOME"),
@@ -115,12 +132,40 @@ func init() {
 	gMarksPath = filepath.Join(data, "lf", "marks")
 	gTagsPath = filepath.Join(data, "lf", "tags")
 	gHistoryPath = filepath.Join(data, "lf", "history")
+	gAuditPath = filepath.Join(data, "lf", "audit")
+	gRecoveryPath = filepath.Join(data, "lf", "recovery")
+	gTrustPath = filepath.Join(data, "lf", "trust")
+	gSortsPath = filepath.Join(data, "lf", "sorts")
+	gSessionPath = filepath.Join(data, "lf", "session")
+	gJumpPath = filepath.Join(data, "lf", "dirs")
+
+	zoxide := cmp.Or(
+		os.Getenv("_ZO_DATA_DIR"),
+		filepath.Join(cmp.Or(os.Getenv("XDG_DATA_HOME"), filepath.Join(gUser.HomeDir, ".local", "share")), "zoxide"),
+	)
+	gZoxidePath = filepath.Join(zoxide, "db.zo")
 
-	runtime := cmp.Or(os.Getenv("XDG_RUNTIME_DIR"), os.TempDir())
+	runtime := cmp.Or(
+		os.Getenv("LF_RUNTIME_DIR"),
+		os.Getenv("XDG_RUNTIME_DIR"),
+		os.TempDir(),
+	)
 
 	gDefaultSocketPath = filepath.Join(runtime, fmt.Sprintf("lf.%s.sock", gUser.Username))
 }
 
+// This function returns the default value of the 'terminalcmd' option, which
+// is the terminal in '$TERMINAL' when it is set.
+func defaultTerminal() string {
+	if term := os.Getenv("TERMINAL"); term != "" {
+		return term
+	}
+	if runtime.GOOS == "darwin" {
+		return "open -a Terminal ."
+	}
+	return "x-terminal-emulator"
+}
+
 func detachedCommand(name string, arg ...string) *exec.Cmd {
 	cmd := exec.Command(name, arg...)
 	cmd.SysProcAttr = &unix.SysProcAttr{Setsid: true}
@@ -128,15 +173,103 @@ func detachedCommand(name string, arg ...string) *exec.Cmd {
 }
 
 func shellCommand(s string, args []string) *exec.Cmd {
-	if len(gOpts.ifs) != 0 {
-		s = fmt.Sprintf("IFS='%s'; %s", gOpts.ifs, s)
+	// non-POSIX shells do not use IFS and take positional arguments directly
+	p := getShellProfile(gOpts.shell)
+	if p.posix {
+		if len(gOpts.ifs) != 0 {
+			s = fmt.Sprintf("IFS='%s'; %s", gOpts.ifs, s)
+		}
+		args = append([]string{"--"}, args...)
 	}
 
-	args = append([]string{gOpts.shellflag, s, "--"}, args...)
+	s, args, env := wrapShellArgs(p, s, args)
+
+	args = append([]string{gOpts.shellflag, s}, args...)
 
 	args = append(gOpts.shellopts, args...)
 
-	return exec.Command(gOpts.shell, args...)
+	cmd := exec.Command(gOpts.shell, args...)
+	if env != "" {
+		cmd.Env = append(os.Environ(), env)
+	}
+	return cmd
+}
+
+// Previewer and cleaner scripts are run with these limits in the sandbox (i.e.
+// CPU time in seconds, and virtual memory and written file size in kilobytes),
+// which are set with 'ulimit' separately since some shells only take one limit
+// at a time.
+const gPreviewSandboxLimits = `ulimit -t 30 2>/dev/null; ulimit -v 4194304 2>/dev/null; ulimit -f 1048576 2>/dev/null; exec "$@"`
+
+// This function returns the program used for the 'previewsandbox' option,
+// which is bubblewrap on Linux and sandbox-exec on macOS.
+func previewSandbox() (string, error) {
+	var name string
+	switch runtime.GOOS {
+	case "linux":
+		name = "bwrap"
+	case "darwin":
+		name = "sandbox-exec"
+	default:
+		return "", fmt.Errorf("not supported on %s", runtime.GOOS)
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s is required: %s", name, err)
+	}
+
+	return path, nil
+}
+
+// This function returns the command to run the given previewer or cleaner
+// script, which is run in a sandbox when the 'previewsandbox' option is
+// enabled. The sandbox has no network access, and the file system is
+// read-only except for the cache directory, which is also used for temporary
+// files.
+func previewCommand(name string, arg ...string) (*exec.Cmd, error) {
+	if !gOpts.previewsandbox {
+		return exec.Command(name, arg...), nil
+	}
+
+	sandbox, err := previewSandbox()
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	tmp := filepath.Join(cache, "lf", "tmp")
+	if err := os.MkdirAll(tmp, 0o700); err != nil {
+		return nil, err
+	}
+
+	var args []string
+	if runtime.GOOS == "darwin" {
+		profile := fmt.Sprintf(`(version 1) (allow default) (deny network*) `+
+			`(deny file-write* (require-not (subpath %q))) (allow file-write* (literal "/dev/null"))`, cache)
+		args = []string{"-p", profile}
+	} else {
+		args = []string{
+			"--ro-bind", "/", "/",
+			"--dev", "/dev",
+			"--proc", "/proc",
+			"--bind", cache, cache,
+			"--unshare-all",
+			"--die-with-parent",
+			"--new-session",
+			"--",
+		}
+	}
+
+	args = append(args, "sh", "-c", gPreviewSandboxLimits, "sh", name)
+	args = append(args, arg...)
+
+	cmd := exec.Command(sandbox, args...)
+	cmd.Env = append(os.Environ(), "TMPDIR="+tmp)
+	return cmd, nil
 }
 
 func shellSetPG(cmd *exec.Cmd) {
@@ -155,24 +288,31 @@ func shellKill(cmd *exec.Cmd) error {
 	return cmd.Process.Kill()
 }
 
-func setDefaults() {
-	gOpts.cmds["open"] = &execExpr{"&", `$OPENER "$f"`}
-	gOpts.nkeys["e"] = &execExpr{"$", `$EDITOR "$f"`}
-	gOpts.vkeys["e"] = &execExpr{"$", `$EDITOR "$f"`}
-	gOpts.nkeys["i"] = &execExpr{"$", `$PAGER "$f"`}
-	gOpts.vkeys["i"] = &execExpr{"$", `$PAGER "$f"`}
-	gOpts.nkeys["w"] = &execExpr{"$", "$SHELL"}
-	gOpts.vkeys["w"] = &execExpr{"$", "$SHELL"}
+// This function replaces the process with the given shell, so that the exit
+// status of the shell is the exit status of the process.
+func execShell(shell string) error {
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		return err
+	}
+	return unix.Exec(path, []string{shell}, os.Environ())
+}
 
-	gOpts.cmds["doc"] = &execExpr{"$", `"$lf" -doc | $PAGER`}
-	gOpts.nkeys["<f-1>"] = &callExpr{"doc", nil, 1}
-	gOpts.vkeys["<f-1>"] = &callExpr{"doc", nil, 1}
+func notifyJobControl(c chan<- os.Signal) {
+	signal.Notify(c, unix.SIGTSTP, unix.SIGCONT)
+}
 
-	gOpts.cmds["maps"] = &execExpr{"$", `"$lf" -remote "query $id maps" | $PAGER`}
-	gOpts.cmds["nmaps"] = &execExpr{"$", `"$lf" -remote "query $id nmaps" | $PAGER`}
-	gOpts.cmds["vmaps"] = &execExpr{"$", `"$lf" -remote "query $id vmaps" | $PAGER`}
-	gOpts.cmds["cmaps"] = &execExpr{"$", `"$lf" -remote "query $id cmaps" | $PAGER`}
-	gOpts.cmds["cmds"] = &execExpr{"$", `"$lf" -remote "query $id cmds" | $PAGER`}
+func resetJobControl() {
+	signal.Reset(unix.SIGTSTP, unix.SIGCONT)
+}
+
+func isSuspendSignal(sig os.Signal) bool {
+	return sig == unix.SIGTSTP
+}
+
+// SIGSTOP is used instead of SIGTSTP since the latter is caught by lf itself.
+func suspendProcess() error {
+	return unix.Kill(os.Getpid(), unix.SIGSTOP)
 }
 
 func setUserUmask() {
@@ -222,6 +362,25 @@ func linkCount(f os.FileInfo) string {
 	return ""
 }
 
+func deviceID(f os.FileInfo) (uint64, bool) {
+	if stat, ok := f.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev), true
+	}
+	return 0, false
+}
+
+// Record locks are used rather than flock since they are supported on all
+// unix systems and also work on network filesystems such as NFS.
+func lockFile(f *os.File) error {
+	lk := unix.Flock_t{Type: unix.F_WRLCK}
+	return unix.FcntlFlock(f.Fd(), unix.F_SETLKW, &lk)
+}
+
+func unlockFile(f *os.File) error {
+	lk := unix.Flock_t{Type: unix.F_UNLCK}
+	return unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lk)
+}
+
 func errCrossDevice(err error) bool {
 	return err.(*os.LinkError).Err.(unix.Errno) == unix.EXDEV
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"cmp"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -32,14 +33,22 @@ var (
 )
 
 var (
-	gUser        *user.User
-	gConfigPaths []string
-	gColorsPaths []string
-	gIconsPaths  []string
-	gFilesPath   string
-	gTagsPath    string
-	gMarksPath   string
-	gHistoryPath string
+	gUser         *user.User
+	gConfigPaths  []string
+	gColorsPaths  []string
+	gIconsPaths   []string
+	gFilesPath    string
+	gTagsPath     string
+	gMarksPath    string
+	gHistoryPath  string
+	gAuditPath    string
+	gRecoveryPath string
+	gTrustPath    string
+	gSortsPath    string
+	gSessionPath  string
+	gJumpPath     string
+	gZoxidePath   string
+	gRemotePath   string
 )
 
 func init() {
@@ -74,6 +83,12 @@ func init() {
 		Username: username,
 	}
 
+	initPaths()
+}
+
+// This function sets the paths of the configuration and data files and the
+// socket, which can be changed with environment variables.
+func initPaths() {
 	config := cmp.Or(os.Getenv("LF_CONFIG_HOME"), os.Getenv("APPDATA"))
 
 	gConfigPaths = []string{
@@ -91,24 +106,41 @@ func init() {
 		filepath.Join(config, "lf", "icons"),
 	}
 
+	gRemotePath = filepath.Join(config, "lf", "remote")
+
 	data := cmp.Or(os.Getenv("LF_DATA_HOME"), os.Getenv("LOCALAPPDATA"))
 
 	gFilesPath = filepath.Join(data, "lf", "files")
 	gMarksPath = filepath.Join(data, "lf", "marks")
 	gTagsPath = filepath.Join(data, "lf", "tags")
 	gHistoryPath = filepath.Join(data, "lf", "history")
+	gAuditPath = filepath.Join(data, "lf", "audit")
+	gRecoveryPath = filepath.Join(data, "lf", "recovery")
+	gTrustPath = filepath.Join(data, "lf", "trust")
+	gSortsPath = filepath.Join(data, "lf", "sorts")
+	gSessionPath = filepath.Join(data, "lf", "session")
+	gJumpPath = filepath.Join(data, "lf", "dirs")
+
+	zoxide := cmp.Or(os.Getenv("_ZO_DATA_DIR"), filepath.Join(os.Getenv("LOCALAPPDATA"), "zoxide"))
+	gZoxidePath = filepath.Join(zoxide, "db.zo")
 
 	socket, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
 	if err != nil {
 		gDefaultSocketProt = "tcp"
 		gDefaultSocketPath = "127.0.0.1:12345"
 	} else {
-		runtime := os.TempDir()
+		runtime := cmp.Or(os.Getenv("LF_RUNTIME_DIR"), os.TempDir())
 		gDefaultSocketPath = filepath.Join(runtime, fmt.Sprintf("lf.%s.sock", gUser.Username))
 		syscall.Close(socket)
 	}
 }
 
+// This function returns the default value of the 'terminalcmd' option, which
+// opens a new console window with the default shell.
+func defaultTerminal() string {
+	return "start cmd"
+}
+
 func detachedCommand(name string, arg ...string) *exec.Cmd {
 	cmd := exec.Command(name, arg...)
 	cmd.SysProcAttr = &windows.SysProcAttr{CreationFlags: 8}
@@ -117,7 +149,7 @@ func detachedCommand(name string, arg ...string) *exec.Cmd {
 
 func shellCommand(s string, args []string) *exec.Cmd {
 	// Windows CMD requires special handling to deal with quoted arguments
-	if strings.ToLower(gOpts.shell) == "cmd" {
+	if shellProfileName(gOpts.shell) == "cmd" {
 		var builder strings.Builder
 		builder.WriteString(s)
 		for _, arg := range args {
@@ -131,9 +163,27 @@ func shellCommand(s string, args []string) *exec.Cmd {
 		return cmd
 	}
 
+	s, args, env := wrapShellArgs(getShellProfile(gOpts.shell), s, args)
+
 	args = append([]string{gOpts.shellflag, s}, args...)
 	args = append(gOpts.shellopts, args...)
-	return exec.Command(gOpts.shell, args...)
+
+	cmd := exec.Command(gOpts.shell, args...)
+	if env != "" {
+		cmd.Env = append(os.Environ(), env)
+	}
+	return cmd
+}
+
+func previewSandbox() (string, error) {
+	return "", errors.New("not supported on windows")
+}
+
+func previewCommand(name string, arg ...string) (*exec.Cmd, error) {
+	if gOpts.previewsandbox {
+		return nil, errors.New("previewsandbox: not supported on windows")
+	}
+	return exec.Command(name, arg...), nil
 }
 
 func shellSetPG(cmd *exec.Cmd) {
@@ -143,24 +193,35 @@ func shellKill(cmd *exec.Cmd) error {
 	return cmd.Process.Kill()
 }
 
-func setDefaults() {
-	gOpts.cmds["open"] = &execExpr{"&", "%OPENER% %f%"}
-	gOpts.nkeys["e"] = &execExpr{"$", "%EDITOR% %f%"}
-	gOpts.vkeys["e"] = &execExpr{"$", "%EDITOR% %f%"}
-	gOpts.nkeys["i"] = &execExpr{"!", "%PAGER% %f%"}
-	gOpts.vkeys["i"] = &execExpr{"!", "%PAGER% %f%"}
-	gOpts.nkeys["w"] = &execExpr{"$", "%SHELL%"}
-	gOpts.vkeys["w"] = &execExpr{"$", "%SHELL%"}
+// Processes can not be replaced on Windows, so the shell is run until it exits
+// and its exit status is used as the exit status of the process instead.
+func execShell(shell string) error {
+	cmd := exec.Command(shell)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
 
-	gOpts.cmds["doc"] = &execExpr{"!", "%lf% -doc | %PAGER%"}
-	gOpts.nkeys["<f-1>"] = &callExpr{"doc", nil, 1}
-	gOpts.vkeys["<f-1>"] = &callExpr{"doc", nil, 1}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		return err
+	}
+
+	os.Exit(0)
+	return nil
+}
 
-	gOpts.cmds["maps"] = &execExpr{"!", `%lf% -remote "query %id% maps" | %PAGER%`}
-	gOpts.cmds["nmaps"] = &execExpr{"!", `%lf% -remote "query %id% nmaps" | %PAGER%`}
-	gOpts.cmds["vmaps"] = &execExpr{"!", `%lf% -remote "query %id% vmaps" | %PAGER%`}
-	gOpts.cmds["cmaps"] = &execExpr{"!", `%lf% -remote "query %id% cmaps" | %PAGER%`}
-	gOpts.cmds["cmds"] = &execExpr{"!", `%lf% -remote "query %id% cmds" | %PAGER%`}
+func notifyJobControl(c chan<- os.Signal) {}
+
+func resetJobControl() {}
+
+func isSuspendSignal(sig os.Signal) bool {
+	return false
+}
+
+func suspendProcess() error {
+	return errors.New("job control is not supported on Windows")
 }
 
 func setUserUmask() {}
@@ -212,13 +273,25 @@ func linkCount(_ os.FileInfo) string {
 	return ""
 }
 
+func deviceID(_ os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func lockFile(f *os.File) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, new(windows.Overlapped))
+}
+
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, new(windows.Overlapped))
+}
+
 func errCrossDevice(err error) bool {
 	return err.(*os.LinkError).Err.(windows.Errno) == windows.ERROR_NOT_SAME_DEVICE
 }
 
 func quoteString(s string) string {
 	// Windows CMD requires special handling to deal with quoted arguments
-	if strings.ToLower(gOpts.shell) == "cmd" {
+	if shellProfileName(gOpts.shell) == "cmd" {
 		return fmt.Sprintf(`"%s"`, s)
 	}
 	return s
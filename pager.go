@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// The pager is used to display the output of shell-wait commands inside lf
+// when the 'shellpager' option is enabled, and the documentation with the
+// 'help' command. It takes over the whole screen until it is closed and
+// supports scrolling, searching and yanking lines, as well as following links
+// between help topics. Results of commands such as 'search-content' have a
+// path for each line, in which case a cursor is moved instead of scrolling
+// and the file of the line under the cursor is selected on enter.
+type pager struct {
+	title  string
+	lines  []string
+	pos    int
+	search string
+	msg    string
+	links  []helpLink
+	link   int
+	topics map[string]int
+	back   []int
+	paths  []string
+	cursor int
+}
+
+func newPager(title, s string) *pager {
+	s = strings.TrimRight(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	return &pager{
+		title: title,
+		lines: strings.Split(s, "\n"),
+		link:  -1,
+	}
+}
+
+// Outputs of commands running in the background such as 'checksum' and 'stats'
+// are shown in the pager when they are finished, and can be shown again later,
+// so the last output of each command is kept behind a lock.
+type pagerLog struct {
+	mutex sync.Mutex
+	title string
+	lines []string
+}
+
+func (l *pagerLog) set(title string, lines []string) {
+	l.mutex.Lock()
+	l.title, l.lines = title, lines
+	l.mutex.Unlock()
+}
+
+func (l *pagerLog) get() (string, []string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.title, l.lines
+}
+
+func (p *pager) scroll(n, height int) {
+	p.pos = max(0, min(p.pos+n, len(p.lines)-height))
+}
+
+// This function moves the cursor of results by the given distance, scrolling
+// the pager to keep the cursor visible.
+func (p *pager) moveCursor(n, height int) {
+	p.cursor = max(0, min(p.cursor+n, len(p.lines)-1))
+	if p.cursor < p.pos {
+		p.pos = p.cursor
+	} else if p.cursor >= p.pos+height {
+		p.pos = p.cursor - height + 1
+	}
+}
+
+// This function returns the index of the current line, which is the line
+// under the cursor for results and the first shown line otherwise.
+func (p *pager) current() int {
+	if p.paths != nil {
+		return p.cursor
+	}
+	return p.pos
+}
+
+// This function selects the next or previous link visible on the screen.
+func (p *pager) selectLink(forward bool, height int) {
+	var visible []int
+	for i, l := range p.links {
+		if l.line >= p.pos && l.line < p.pos+height {
+			visible = append(visible, i)
+		}
+	}
+	if len(visible) == 0 {
+		p.link = -1
+		return
+	}
+
+	ind := slices.Index(visible, p.link)
+	switch {
+	case ind == -1 && forward:
+		ind = 0
+	case ind == -1:
+		ind = len(visible) - 1
+	case forward:
+		ind = (ind + 1) % len(visible)
+	default:
+		ind = (ind - 1 + len(visible)) % len(visible)
+	}
+	p.link = visible[ind]
+}
+
+func (p *pager) followLink() {
+	line, ok := p.topics[p.links[p.link].topic]
+	if !ok {
+		return
+	}
+	p.back = append(p.back, p.pos)
+	p.pos = line
+	p.link = -1
+}
+
+func (p *pager) goBack() {
+	if len(p.back) == 0 {
+		return
+	}
+	p.pos = p.back[len(p.back)-1]
+	p.back = p.back[:len(p.back)-1]
+	p.link = -1
+}
+
+func (p *pager) find(forward bool) bool {
+	n := len(p.lines)
+	curr := p.current()
+	for i := 1; i < n; i++ {
+		ind := (curr - i + n) % n
+		if forward {
+			ind = (curr + i) % n
+		}
+		if !gOpts.wrapscan && (forward && ind < curr || !forward && ind > curr) {
+			return false
+		}
+		if matched, _ := searchMatch(stripAnsi(p.lines[ind]), p.search, false); matched {
+			p.pos = ind
+			if p.paths != nil {
+				p.cursor = ind
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (ui *ui) pagerHeight() int {
+	_, h := ui.screen.Size()
+	return max(1, h-1)
+}
+
+func (ui *ui) drawPager() {
+	st := tcell.StyleDefault
+	p := ui.pager
+	w, _ := ui.screen.Size()
+	win := newWin(w, ui.pagerHeight(), 0, 0)
+
+	for i := 0; i < win.h; i++ {
+		if p.pos+i < len(p.lines) {
+			win.print(ui.screen, 0, i, st, p.lines[p.pos+i])
+		}
+	}
+
+	if p.paths != nil && p.cursor >= p.pos && p.cursor < p.pos+win.h && p.cursor < len(p.lines) {
+		win.printLine(ui.screen, 0, p.cursor-p.pos, st.Reverse(true), stripAnsi(p.lines[p.cursor]))
+	}
+
+	if p.link >= 0 {
+		l := p.links[p.link]
+		if l.line >= p.pos && l.line < p.pos+win.h {
+			win.print(ui.screen, l.col, l.line-p.pos, st.Reverse(true), l.topic)
+		}
+	}
+
+	if ui.cmdPrefix != "" {
+		maxWidth := ui.msgWin.w - 1 // leave space for cursor at the end
+		prefix := runeSliceWidthRange([]rune(ui.cmdPrefix), 0, maxWidth)
+		left := runeSliceWidthLastRange(ui.cmdAccLeft, maxWidth-runeSliceWidth(prefix))
+		ui.msgWin.printLine(ui.screen, 0, 0, st, string(prefix)+string(left)+string(ui.cmdAccRight))
+		ui.screen.ShowCursor(ui.msgWin.x+runeSliceWidth(prefix)+runeSliceWidth(left), ui.msgWin.y)
+		return
+	}
+
+	end := min(p.pos+win.h, len(p.lines))
+	status := fmt.Sprintf(" %s  %d-%d/%d ", p.title, p.pos+1, end, len(p.lines))
+	if p.msg != "" {
+		status += " " + p.msg
+	}
+	ui.msgWin.printLine(ui.screen, 0, 0, st.Reverse(true), status)
+	ui.screen.HideCursor()
+}
+
+func (ui *ui) readPagerEvent(ev tcell.Event, nav *nav) expr {
+	draw := &callExpr{"draw", nil, 1}
+	p := ui.pager
+	height := ui.pagerHeight()
+
+	tev, ok := ev.(*tcell.EventKey)
+	if !ok {
+		if _, ok := ev.(*tcell.EventMouse); ok {
+			return nil
+		}
+		return ui.readNormalEvent(ev, nav)
+	}
+
+	p.msg = ""
+
+	// results are navigated with a cursor instead of scrolling
+	move := p.scroll
+	if p.paths != nil {
+		move = p.moveCursor
+	}
+
+	// the selected link is kept only until the screen is scrolled
+	pos := p.pos
+	defer func() {
+		if p.pos != pos {
+			p.link = -1
+		}
+	}()
+
+	switch tev.Key() {
+	case tcell.KeyEscape:
+		ui.pager = nil
+	case tcell.KeyTab:
+		p.selectLink(true, height)
+	case tcell.KeyBacktab:
+		p.selectLink(false, height)
+	case tcell.KeyEnter:
+		switch {
+		case p.paths != nil:
+			if p.cursor < len(p.paths) {
+				ui.pager = nil
+				return &callExpr{"select", []string{p.paths[p.cursor]}, 1}
+			}
+		case p.link >= 0:
+			p.followLink()
+			pos = p.pos
+		default:
+			p.scroll(1, height)
+		}
+	case tcell.KeyBackspace, tcell.KeyBackspace2, tcell.KeyCtrlO:
+		p.goBack()
+		pos = p.pos
+	case tcell.KeyDown, tcell.KeyCtrlN:
+		move(1, height)
+	case tcell.KeyUp, tcell.KeyCtrlP:
+		move(-1, height)
+	case tcell.KeyPgDn, tcell.KeyCtrlF:
+		move(height, height)
+	case tcell.KeyPgUp, tcell.KeyCtrlB:
+		move(-height, height)
+	case tcell.KeyCtrlD:
+		move(height/2, height)
+	case tcell.KeyCtrlU:
+		move(-height/2, height)
+	case tcell.KeyHome:
+		move(-len(p.lines), height)
+	case tcell.KeyEnd:
+		move(len(p.lines), height)
+	case tcell.KeyRune:
+		switch tev.Rune() {
+		case 'q':
+			ui.pager = nil
+		case 'j':
+			move(1, height)
+		case 'k':
+			move(-1, height)
+		case ' ':
+			move(height, height)
+		case 'g':
+			move(-len(p.lines), height)
+		case 'G':
+			move(len(p.lines), height)
+		case '/':
+			ui.cmdPrefix = "pager-search: "
+		case 'n', 'N':
+			if p.search != "" && !p.find(tev.Rune() == 'n') {
+				p.msg = "pattern not found: " + p.search
+			}
+		case 'y':
+			if p.current() < len(p.lines) {
+				ui.cmdYankBuf = []rune(stripAnsi(p.lines[p.current()]))
+			}
+		case 'Y':
+			ui.cmdYankBuf = []rune(stripAnsi(strings.Join(p.lines, "\n")))
+		}
+	}
+
+	return draw
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestPagerScroll(t *testing.T) {
+	p := newPager("test", "a\nb\nc\nd\ne\n")
+
+	tests := []struct {
+		n   int
+		exp int
+	}{
+		{1, 1},
+		{10, 2},
+		{-1, 1},
+		{-10, 0},
+	}
+
+	for _, test := range tests {
+		p.scroll(test.n, 3)
+		if p.pos != test.exp {
+			t.Errorf("at input %d expected %d but got %d", test.n, test.exp, p.pos)
+		}
+	}
+}
+
+func TestPagerFind(t *testing.T) {
+	gOpts.ignorecase = true
+	gOpts.smartcase = true
+	gOpts.wrapscan = true
+
+	p := newPager("test", "foo\nbar\n\033[31mbaz\033[0m\nFOO\n")
+
+	tests := []struct {
+		search  string
+		forward bool
+		exp     int
+		found   bool
+	}{
+		{"ba", true, 1, true},
+		{"ba", true, 2, true},
+		{"foo", true, 3, true},
+		{"foo", true, 0, true},
+		{"FOO", false, 3, true},
+		{"31m", true, 3, false},
+	}
+
+	for _, test := range tests {
+		p.search = test.search
+		found := p.find(test.forward)
+		if found != test.found || p.pos != test.exp {
+			t.Errorf("at input '%s' expected (%d, %t) but got (%d, %t)", test.search, test.exp, test.found, p.pos, found)
+		}
+	}
+}
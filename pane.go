@@ -0,0 +1,140 @@
+package main
+
+// When the 'dualpane' option is enabled, the screen is split into two panes
+// showing two directories side by side, as in orthodox file managers. Only
+// the focused pane is navigated, and it is always the current directory, so
+// that all commands work on it as usual. The directory of the other pane is
+// kept along with its cursor, and the 'pane-switch' command changes the
+// current directory to it while keeping the previous one in the other pane.
+// Files can be copied or moved to the directory of the other pane with the
+// 'copy-to-other' and 'move-to-other' commands.
+//
+// When the 'linkedpane' option is enabled instead, the preview pane always
+// shows the hovered directory as a list with its cursor, and 'pane-switch'
+// moves the focus into it, which changes the current directory to it so that
+// it can be navigated as usual while the other panes keep showing its
+// parents. Switching again moves the focus back to the parent directory with
+// the directory hovered, so that the cursor of the linked pane is kept.
+
+// This function returns the directory shown in the other pane, which is the
+// current directory until the focus is switched.
+func (nav *nav) otherDir() *dir {
+	if nav.pane == nil {
+		return nav.currDir()
+	}
+	return nav.pane
+}
+
+// This function returns the directory shown in the given pane.
+func (nav *nav) paneDir(ind int) *dir {
+	if ind == nav.paneInd {
+		return nav.currDir()
+	}
+	return nav.otherDir()
+}
+
+// This function reports whether the focus is in the linked pane, in which case
+// the current directory is shown in the last pane instead of the preview.
+func (nav *nav) linkedFocused() bool {
+	return nav.linkedFocus && gOpts.linkedpane && gOpts.preview && !gOpts.dualpane
+}
+
+func (app *app) paneSwitch() {
+	if gOpts.linkedpane && !gOpts.dualpane {
+		app.linkedSwitch()
+		return
+	}
+
+	if !gOpts.dualpane {
+		app.ui.echoerr("pane-switch: 'dualpane' option is not enabled")
+		return
+	}
+
+	curr := app.nav.currDir()
+	other := app.nav.otherDir()
+
+	if other.path != curr.path {
+		resetIncCmd(app)
+		preChdir(app)
+
+		if err := app.nav.cd(other.path); err != nil {
+			app.ui.echoerrf("pane-switch: %s", err)
+			return
+		}
+
+		restartIncCmd(app)
+		onChdir(app)
+	}
+
+	app.nav.pane = curr
+	app.nav.paneInd = 1 - app.nav.paneInd
+
+	app.ui.loadFile(app, true)
+	app.ui.loadFileInfo(app.nav)
+}
+
+// This function moves the focus into the hovered directory in the linked pane,
+// or back to the parent directory when the linked pane is focused.
+func (app *app) linkedSwitch() {
+	if !gOpts.preview {
+		app.ui.echoerr("pane-switch: 'preview' option is not enabled")
+		return
+	}
+
+	if app.nav.linkedFocused() {
+		app.nav.linkedFocus = false
+		(&callExpr{"updir", nil, 1}).eval(app, nil)
+		return
+	}
+
+	curr, err := app.nav.currFile()
+	if err != nil {
+		app.ui.echoerrf("pane-switch: %s", err)
+		return
+	}
+	if !curr.IsDir() {
+		app.ui.echoerr("pane-switch: current file is not a directory")
+		return
+	}
+
+	path := app.nav.currDir().path
+	openDir(app, false)
+	if app.nav.currDir().path != path {
+		app.nav.linkedFocus = true
+	}
+}
+
+// This function copies or moves the current file or selected files to the
+// directory of the other pane.
+func (app *app) pasteToOther(name string, cp, dryRun bool) {
+	if !gOpts.dualpane {
+		app.ui.echoerrf("%s: 'dualpane' option is not enabled", name)
+		return
+	}
+
+	dstDir := app.nav.otherDir().path
+	if dstDir == app.nav.currDir().path {
+		app.ui.echoerrf("%s: both panes show the same directory", name)
+		return
+	}
+
+	list, err := app.nav.currFileOrSelections()
+	if err != nil {
+		app.ui.echoerrf("%s: %s", name, err)
+		return
+	}
+
+	if dryRun {
+		app.showDryRun(name, copyPlan(list, dstDir, cp, false))
+		return
+	}
+
+	if err := app.nav.pasteAsync(app, list, dstDir, cp, false); err != nil {
+		app.ui.echoerrf("%s: %s", name, err)
+		return
+	}
+
+	app.nav.unselect()
+	app.ui.loadFile(app, true)
+	app.ui.loadFileInfo(app.nav)
+}
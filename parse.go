@@ -96,11 +96,17 @@ type cmapExpr struct {
 func (e *cmapExpr) String() string { return fmt.Sprintf("cmap %s %s", e.key, e.expr) }
 
 type cmdExpr struct {
-	name string
-	expr expr
+	name  string
+	expr  expr
+	scope string
 }
 
-func (e *cmdExpr) String() string { return fmt.Sprintf("cmd %s %s", e.name, e.expr) }
+func (e *cmdExpr) String() string {
+	if e.scope != "" {
+		return fmt.Sprintf("cmd %s %s under %s", e.name, e.expr, e.scope)
+	}
+	return fmt.Sprintf("cmd %s %s", e.name, e.expr)
+}
 
 type callExpr struct {
 	name  string
@@ -294,13 +300,21 @@ func (p *parser) parseExpr() expr {
 			name := s.tok
 
 			s.scan()
-			if s.typ != tokenSemicolon {
+			if s.typ != tokenSemicolon && !isUnder(s) {
 				expr = p.parseExpr()
-			} else {
+			} else if s.typ == tokenSemicolon {
+				s.scan()
+			}
+
+			var scope string
+			if isUnder(s) {
+				s.scan()
+				scope = s.tok
+				s.scan()
 				s.scan()
 			}
 
-			result = &cmdExpr{name, expr}
+			result = &cmdExpr{name, expr, scope}
 		default:
 			name := s.tok
 
@@ -330,6 +344,9 @@ func (p *parser) parseExpr() expr {
 				}
 			}
 			s.scan()
+			if isUnder(s) {
+				return &listExpr{exprs, 1}
+			}
 		} else {
 			for {
 				e := p.parseExpr()
@@ -356,11 +373,15 @@ func (p *parser) parseExpr() expr {
 			s.scan()
 			expr = s.tok
 			s.scan()
+			s.scan()
+			if isUnder(s) {
+				return &execExpr{prefix, expr}
+			}
 		} else {
 			expr = s.tok
+			s.scan()
 		}
 
-		s.scan()
 		s.scan()
 
 		result = &execExpr{prefix, expr}
@@ -371,6 +392,12 @@ func (p *parser) parseExpr() expr {
 	return result
 }
 
+// The scope of a command is given after the closing braces of its body, or
+// after its name to remove the scoped command.
+func isUnder(s *scanner) bool {
+	return s.typ == tokenIdent && s.tok == "under"
+}
+
 func (p *parser) parse() bool {
 	p.expr = p.parseExpr()
 	return p.expr != nil
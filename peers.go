@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Clients report their current directory to the server on each directory
+// change with the 'cwd' command, and the server sends each client the
+// directories of the other clients with the 'peer-dirs' command, so that
+// clients know when other clients (e.g. of other users on a shared machine
+// or in other terminals) are in the same directory. The number of other
+// clients in the current directory is shown at the end of the prompt line,
+// and the confirmation of 'delete' and the warning of 'paste' for moved files
+// mention the other clients in the directories of the files. This is not
+// available in single mode since there is no server.
+
+type clientDirs struct {
+	mutex sync.Mutex
+	dirs  map[int]string
+}
+
+var gClientDirs = clientDirs{dirs: make(map[int]string)}
+
+func (c *clientDirs) set(id int, path string) {
+	c.mutex.Lock()
+	c.dirs[id] = path
+	c.mutex.Unlock()
+}
+
+func (c *clientDirs) remove(id int) {
+	c.mutex.Lock()
+	delete(c.dirs, id)
+	c.mutex.Unlock()
+}
+
+// This function returns the 'peer-dirs' command to send to the client with
+// the given id, which has the directories of the other clients.
+func (c *clientDirs) cmd(id int) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	args := []string{"peer-dirs"}
+	for _, id2 := range slices.Sorted(maps.Keys(c.dirs)) {
+		if id2 != id {
+			args = append(args, escape(c.dirs[id2]))
+		}
+	}
+
+	return strings.Join(args, " ")
+}
+
+// This function sends the directories of the other clients to all clients.
+func broadcastPeerDirs() {
+	for id, c := range gConnList {
+		fmt.Fprintln(c, gClientDirs.cmd(id))
+	}
+}
+
+// This function reports the current directory to the server.
+func (app *app) reportDir() {
+	if gSingleMode {
+		return
+	}
+
+	path := app.nav.currDir().path
+	if strings.ContainsAny(path, "\r\n") {
+		return
+	}
+
+	if err := remote(fmt.Sprintf("cwd %d %s", gClientID, path)); err != nil {
+		log.Printf("reporting directory: %s", err)
+	}
+}
+
+// This function applies the 'peer-dirs' command sent by the server.
+func (app *app) setPeerDirs(args []string) {
+	dirs := make(map[string]int)
+	for _, path := range args {
+		dirs[path]++
+	}
+	app.nav.peerDirs = dirs
+}
+
+// This function returns the number of other clients in the directories of the
+// given files or inside the given files when they are directories.
+func (nav *nav) peersIn(paths []string) int {
+	count := 0
+	for dir, n := range nav.peerDirs {
+		if slices.ContainsFunc(paths, func(path string) bool {
+			return filepath.Dir(path) == dir || dir == path || strings.HasPrefix(dir, path+string(filepath.Separator))
+		}) {
+			count += n
+		}
+	}
+	return count
+}
+
+// This function returns the description of the given number of other clients.
+func peersMsg(n int) string {
+	if n == 1 {
+		return "1 other client"
+	}
+	return fmt.Sprintf("%d other clients", n)
+}
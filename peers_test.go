@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestClientDirs(t *testing.T) {
+	c := clientDirs{dirs: make(map[int]string)}
+	c.set(1, "/home/user")
+	c.set(2, "/tmp/a b")
+	c.set(3, "/home/user")
+
+	if exp, got := `peer-dirs /tmp/a\ b /home/user`, c.cmd(1); got != exp {
+		t.Errorf("expected '%s' but got '%s'", exp, got)
+	}
+
+	c.remove(2)
+	if exp, got := "peer-dirs /home/user", c.cmd(1); got != exp {
+		t.Errorf("expected '%s' but got '%s'", exp, got)
+	}
+}
+
+func TestPeersIn(t *testing.T) {
+	nav := &nav{peerDirs: map[string]int{"/a": 2, "/a/b/c": 1, "/d": 1}}
+
+	tests := []struct {
+		paths []string
+		exp   int
+	}{
+		{[]string{"/a/x"}, 2},
+		{[]string{"/a/b"}, 3},
+		{[]string{"/a/bb"}, 2},
+		{[]string{"/d/x", "/e/x"}, 1},
+		{[]string{"/e/x"}, 0},
+	}
+
+	for _, test := range tests {
+		if got := nav.peersIn(test.paths); got != test.exp {
+			t.Errorf("at input '%v' expected '%d' but got '%d'", test.paths, test.exp, got)
+		}
+	}
+}
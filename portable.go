@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// In portable mode, the configuration, data and socket files are all kept in
+// the 'lf-portable' directory next to the binary instead of the usual
+// locations, so that lf can be carried around (e.g. on a USB stick) with its
+// settings. The locations are exported as environment variables so that
+// processes started by lf (e.g. the server or 'lf -remote' in shell commands)
+// use them as well.
+
+// This function returns the portable directory next to the binary.
+func portableDir() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("getting path to lf binary: %s", err)
+	}
+
+	if path, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = path
+	}
+
+	return filepath.Join(filepath.Dir(exe), "lf-portable"), nil
+}
+
+func setupPortable() error {
+	root, err := portableDir()
+	if err != nil {
+		return err
+	}
+
+	dirs := []struct {
+		key  string
+		path string
+	}{
+		{"LF_CONFIG_HOME", filepath.Join(root, "config")},
+		{"LF_DATA_HOME", filepath.Join(root, "data")},
+		{"LF_RUNTIME_DIR", filepath.Join(root, "run")},
+	}
+
+	for _, d := range dirs {
+		if err := os.MkdirAll(d.path, os.ModePerm); err != nil {
+			return fmt.Errorf("creating portable directory: %s", err)
+		}
+		if err := os.Setenv(d.key, d.path); err != nil {
+			return err
+		}
+	}
+
+	initPaths()
+
+	return nil
+}
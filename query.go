@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"maps"
+	"reflect"
+)
+
+// The 'query' command can return the state of a client as JSON with the
+// 'json' keyword before the type of information (e.g. 'query 1234 json
+// selection'), which is easier to use in external tools and editor
+// integrations than the text output meant for users. The values are kept
+// along with the text ones, which are updated before running shell commands,
+// and each value is written in a single line.
+
+type jsonTab struct {
+	Path    string `json:"path"`
+	Current bool   `json:"current"`
+}
+
+type jsonJumps struct {
+	List  []string `json:"list"`
+	Index int      `json:"index"`
+}
+
+type jsonHistory struct {
+	Prefix string `json:"prefix"`
+	Value  string `json:"value"`
+}
+
+// This function returns the options as an object with their values in the
+// types of the options, where user options are in the 'user' object.
+func jsonOpts() map[string]any {
+	opts := make(map[string]any)
+	v := reflect.ValueOf(gOpts)
+	t := v.Type()
+
+	for i := range v.NumField() {
+		name := t.Field(i).Name
+		field := v.Field(i)
+
+		switch field.Kind() {
+		case reflect.Int, reflect.Int64:
+			opts[name] = field.Int()
+		case reflect.Bool:
+			opts[name] = field.Bool()
+		case reflect.String:
+			opts[name] = field.String()
+		case reflect.Slice:
+			list := []string{}
+			for j := range field.Len() {
+				list = append(list, fieldToString(field.Index(j)))
+			}
+			opts[name] = list
+		}
+	}
+
+	user := maps.Clone(gOpts.user)
+	if user == nil {
+		user = make(map[string]string)
+	}
+	opts["user"] = user
+
+	return opts
+}
+
+// This function returns the tabs of the client, which is a single tab in the
+// current directory when tabs are not used.
+func (nav *nav) jsonTabs() []jsonTab {
+	if len(nav.tabs) == 0 {
+		return []jsonTab{{nav.currDir().path, true}}
+	}
+
+	tabs := make([]jsonTab, len(nav.tabs))
+	for i, t := range nav.tabs {
+		tabs[i] = jsonTab{t.path, i == nav.tabInd}
+		if i == nav.tabInd {
+			tabs[i].Path = nav.currDir().path
+		}
+	}
+	return tabs
+}
+
+func (app *app) jsonFiles() []string {
+	files := []string{}
+	dir := app.nav.currDir()
+	if !app.nav.init || dir.loading {
+		return files
+	}
+	for _, f := range dir.files {
+		files = append(files, f.path)
+	}
+	return files
+}
+
+func marshalLine(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("encoding query: %s", err)
+		return ""
+	}
+	return string(b) + "\n"
+}
+
+// This function returns the JSON values of the state of the client for the
+// 'query' command, keyed with the 'json' keyword as in the command.
+func (app *app) jsonState() map[string]string {
+	selections := app.nav.currSelections()
+	if selections == nil {
+		selections = []string{}
+	}
+
+	history := make([]jsonHistory, len(app.cmdHistory))
+	for i, cmd := range app.cmdHistory {
+		history[i] = jsonHistory{cmd.prefix, cmd.value}
+	}
+
+	jumps := jsonJumps{List: app.nav.jumpList, Index: app.nav.jumpListInd}
+	if jumps.List == nil {
+		jumps.List = []string{}
+	}
+
+	marks := maps.Clone(app.nav.marks)
+	if marks == nil {
+		marks = make(map[string]string)
+	}
+
+	state := map[string]any{
+		"dir":       app.nav.currDir().path,
+		"selection": selections,
+		"files":     app.jsonFiles(),
+		"options":   jsonOpts(),
+		"tabs":      app.nav.jsonTabs(),
+		"marks":     marks,
+		"jumps":     jumps,
+		"history":   history,
+	}
+
+	data := make(map[string]string, len(state))
+	for key, val := range state {
+		data["json "+key] = marshalLine(val)
+	}
+	return data
+}
+
+// This function returns the JSON value of the current directory, which is
+// updated on each directory change as the text one.
+func jsonDir(path string) string {
+	return marshalLine(path)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONOpts(t *testing.T) {
+	var opts map[string]any
+	if err := json.Unmarshal([]byte(marshalLine(jsonOpts())), &opts); err != nil {
+		t.Fatalf("decoding options: %s", err)
+	}
+
+	if got, ok := opts["hidden"].(bool); !ok || got != gOpts.hidden {
+		t.Errorf("expected boolean 'hidden' option but got '%v'", opts["hidden"])
+	}
+	if got, ok := opts["scrolloff"].(float64); !ok || int(got) != gOpts.scrolloff {
+		t.Errorf("expected number 'scrolloff' option but got '%v'", opts["scrolloff"])
+	}
+	if _, ok := opts["ratios"].([]any); !ok {
+		t.Errorf("expected list 'ratios' option but got '%v'", opts["ratios"])
+	}
+	if _, ok := opts["nkeys"]; ok {
+		t.Errorf("expected no mappings in options")
+	}
+}
+
+func TestJSONTabs(t *testing.T) {
+	nav := &nav{dirs: []*dir{{path: "/b/src"}}}
+	if exp, got := []jsonTab{{"/b/src", true}}, nav.jsonTabs(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected '%v' but got '%v'", exp, got)
+	}
+
+	nav.tabs = []*tab{newTab("/a"), newTab("/b")}
+	nav.tabInd = 1
+	if exp, got := []jsonTab{{"/a", false}, {"/b/src", true}}, nav.jsonTabs(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected '%v' but got '%v'", exp, got)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Paste operations run in the background, so another paste can be started
+// before the previous one is finished. Running them concurrently is only safe
+// when they do not touch the same paths, since otherwise they could write to
+// the same partial files or move sources away while they are being copied.
+// Paste operations are therefore kept in a queue, where each operation waits
+// for earlier operations with overlapping paths before it starts. Sources that
+// are already being pasted to the same directory with the same operation are
+// dropped, and the remaining ones are merged into an operation that is still
+// waiting for the same directory when possible. Other long running operations
+// on files (e.g. 'split-file') are queued in the same way with the paths they
+// touch, so that they do not run concurrently with overlapping paste operations.
+
+type pasteJob struct {
+	id        int
+	srcs      []string
+	dstDir    string
+	cp        bool
+	overwrite bool
+	del       bool
+	tab       int
+	paths     []string
+	wait      []*pasteJob
+	started   bool
+	done      chan struct{}
+	ctl       jobControl
+}
+
+type pasteQueue struct {
+	mutex sync.Mutex
+	next  int
+	jobs  []*pasteJob
+}
+
+// This function reports whether one of the given paths is the same as or
+// inside the other one.
+func pathsOverlap(a, b string) bool {
+	if a == b {
+		return true
+	}
+	sep := string(filepath.Separator)
+	return strings.HasPrefix(a, strings.TrimSuffix(b, sep)+sep) ||
+		strings.HasPrefix(b, strings.TrimSuffix(a, sep)+sep)
+}
+
+// This function returns the paths touched when pasting the given sources, which
+// are the sources themselves and their targets in the destination directory.
+func pastePaths(srcs []string, dstDir string) []string {
+	paths := make([]string, 0, 2*len(srcs))
+	for _, src := range srcs {
+		paths = append(paths, src, filepath.Join(dstDir, filepath.Base(src)))
+	}
+	return paths
+}
+
+func (job *pasteJob) overlaps(paths []string) bool {
+	touched := job.paths
+	if touched == nil {
+		touched = pastePaths(job.srcs, job.dstDir)
+	}
+	for _, p := range touched {
+		for _, q := range paths {
+			if pathsOverlap(p, q) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// This function adds the given paste operation to the queue and returns the
+// sources which are not already queued. A new job is returned to be run with
+// 'run' unless there are no new sources or they are merged into a waiting job
+// with the same flags. The job is shown in the tab bar for the tab with the
+// given id.
+func (q *pasteQueue) add(srcs []string, dstDir string, cp, overwrite bool, tab int) (*pasteJob, []string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var added []string
+	for _, src := range srcs {
+		queued := slices.ContainsFunc(q.jobs, func(job *pasteJob) bool {
+			return job.cp == cp && job.dstDir == dstDir && slices.Contains(job.srcs, src)
+		})
+		if !queued && !slices.Contains(added, src) {
+			added = append(added, src)
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	paths := pastePaths(added, dstDir)
+	var wait []*pasteJob
+	for _, job := range q.jobs {
+		if job.overlaps(paths) {
+			wait = append(wait, job)
+		}
+	}
+
+	// merging is only safe when the waiting job already waits for all jobs
+	// that the new sources would need to wait for
+	for _, job := range q.jobs {
+		if job.started || job.paths != nil || job.cp != cp || job.overwrite != overwrite || job.dstDir != dstDir {
+			continue
+		}
+		if slices.Contains(wait, job) {
+			continue
+		}
+		if !slices.ContainsFunc(wait, func(w *pasteJob) bool { return !slices.Contains(job.wait, w) }) {
+			job.srcs = append(job.srcs, added...)
+			job.ctl.files.Add(int64(len(added)))
+			return nil, added
+		}
+	}
+
+	q.next++
+	job := &pasteJob{
+		id:        q.next,
+		srcs:      added,
+		dstDir:    dstDir,
+		cp:        cp,
+		overwrite: overwrite,
+		tab:       tab,
+		wait:      wait,
+		done:      make(chan struct{}),
+	}
+	job.ctl.files.Store(int64(len(added)))
+	q.jobs = append(q.jobs, job)
+
+	return job, added
+}
+
+// This function adds an operation other than paste to the queue, which touches
+// the given paths. Such jobs are never merged and should be run with 'run'.
+func (q *pasteQueue) addPaths(paths []string) *pasteJob {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var wait []*pasteJob
+	for _, job := range q.jobs {
+		if job.overlaps(paths) {
+			wait = append(wait, job)
+		}
+	}
+
+	job := &pasteJob{
+		paths: paths,
+		wait:  wait,
+		done:  make(chan struct{}),
+	}
+	q.jobs = append(q.jobs, job)
+
+	return job
+}
+
+// This function adds a delete operation of the given paths to the queue. Unlike
+// other operations added with 'addPaths', it is listed with the 'jobs' command
+// and shown in the tab bar for the tab with the given id.
+func (q *pasteQueue) addDelete(paths []string, tab int) *pasteJob {
+	job := q.addPaths(paths)
+
+	q.mutex.Lock()
+	q.next++
+	job.id = q.next
+	job.del = true
+	job.tab = tab
+	q.mutex.Unlock()
+
+	return job
+}
+
+// This function waits for the overlapping jobs queued before the given job
+// and then runs the given function with the sources of the job, including the
+// ones merged while waiting. The job is removed from the queue afterwards.
+func (q *pasteQueue) run(job *pasteJob, f func(srcs []string)) {
+	for _, w := range job.wait {
+		<-w.done
+	}
+
+	q.mutex.Lock()
+	job.started = true
+	srcs := slices.Clone(job.srcs)
+	q.mutex.Unlock()
+
+	job.ctl.begin()
+	f(srcs)
+
+	q.mutex.Lock()
+	q.jobs = slices.DeleteFunc(q.jobs, func(j *pasteJob) bool { return j == job })
+	q.mutex.Unlock()
+
+	close(job.done)
+}
+
+func (q *pasteQueue) waiting(job *pasteJob) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	n := 0
+	for _, w := range job.wait {
+		if slices.Contains(q.jobs, w) {
+			n++
+		}
+	}
+	return n
+}
+
+// This function returns the paste and delete operations in the queue, which
+// are shown with the 'jobs' command.
+func (q *pasteQueue) list() []*pasteJob {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var jobs []*pasteJob
+	for _, job := range q.jobs {
+		if job.paths == nil || job.del {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// This function returns the number of paste and delete operations in the
+// queue for each tab id.
+func (q *pasteQueue) tabJobs() map[int]int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	jobs := make(map[int]int)
+	for _, job := range q.jobs {
+		if job.paths == nil || job.del {
+			jobs[job.tab]++
+		}
+	}
+	return jobs
+}
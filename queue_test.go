@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPathsOverlap(t *testing.T) {
+	tests := []struct {
+		a   string
+		b   string
+		exp bool
+	}{
+		{"/foo", "/foo", true},
+		{"/foo", "/foo/bar", true},
+		{"/foo/bar", "/foo", true},
+		{"/foo", "/foobar", false},
+		{"/foo/bar", "/foo/baz", false},
+		{"/", "/foo", true},
+	}
+
+	for _, test := range tests {
+		if got := pathsOverlap(test.a, test.b); got != test.exp {
+			t.Errorf("at input '%s' and '%s' expected '%t' but got '%t'", test.a, test.b, test.exp, got)
+		}
+	}
+}
+
+func TestPasteQueue(t *testing.T) {
+	var q pasteQueue
+
+	// first job runs immediately
+	a, added := q.add([]string{"/src/a", "/src/b"}, "/dst", true, false, 0)
+	if a == nil || len(a.wait) != 0 || !reflect.DeepEqual(added, []string{"/src/a", "/src/b"}) {
+		t.Fatalf("expected a new job without waiting but got '%v' with '%v'", a, added)
+	}
+
+	// sources already being copied to the same directory are dropped
+	if job, added := q.add([]string{"/src/a"}, "/dst", true, false, 0); job != nil || len(added) != 0 {
+		t.Errorf("expected duplicate sources to be dropped but got '%v' with '%v'", job, added)
+	}
+
+	// moving a source that is being copied waits for the copy
+	b, _ := q.add([]string{"/src/b"}, "/other", false, false, 0)
+	if b == nil || !reflect.DeepEqual(b.wait, []*pasteJob{a}) {
+		t.Fatalf("expected a new job waiting for the copy but got '%v'", b)
+	}
+
+	// unrelated paths do not wait
+	c, _ := q.add([]string{"/elsewhere/c"}, "/third", true, false, 0)
+	if c == nil || len(c.wait) != 0 {
+		t.Errorf("expected a new job without waiting but got '%v'", c)
+	}
+
+	// new sources are merged into the waiting job for the same directory
+	if job, added := q.add([]string{"/src/d"}, "/other", false, false, 0); job != nil || !reflect.DeepEqual(added, []string{"/src/d"}) {
+		t.Errorf("expected sources to be merged but got '%v' with '%v'", job, added)
+	}
+	if !reflect.DeepEqual(b.srcs, []string{"/src/b", "/src/d"}) {
+		t.Errorf("expected merged sources but got '%v'", b.srcs)
+	}
+
+	var ran []string
+	q.run(a, func(srcs []string) { ran = append(ran, srcs...) })
+	q.run(b, func(srcs []string) { ran = append(ran, srcs...) })
+	q.run(c, func(srcs []string) { ran = append(ran, srcs...) })
+
+	exp := []string{"/src/a", "/src/b", "/src/b", "/src/d", "/elsewhere/c"}
+	if !reflect.DeepEqual(ran, exp) {
+		t.Errorf("expected '%v' but got '%v'", exp, ran)
+	}
+	if len(q.jobs) != 0 {
+		t.Errorf("expected empty queue but got '%v'", q.jobs)
+	}
+}
+
+func TestPasteQueuePaths(t *testing.T) {
+	var q pasteQueue
+
+	a, _ := q.add([]string{"/src/a"}, "/dst", true, false, 0)
+
+	// other jobs wait for paste operations touching the same paths
+	b := q.addPaths([]string{"/dst/a", "/dst/a.001"})
+	if len(b.wait) != 1 || b.wait[0] != a {
+		t.Errorf("expected job to wait for the paste but got '%v'", b.wait)
+	}
+
+	// paste operations wait for other jobs in turn and are not merged into them
+	c, _ := q.add([]string{"/src/a.001"}, "/dst", false, false, 0)
+	if c == nil || len(c.wait) != 1 || c.wait[0] != b {
+		t.Errorf("expected paste to wait for the job but got '%v'", c)
+	}
+
+	if d := q.addPaths([]string{"/other"}); len(d.wait) != 0 {
+		t.Errorf("expected job without waiting but got '%v'", d.wait)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Recently opened files are kept in memory with the most recent one first, and
+// they are shared between clients of a server by sending the 'recent-add'
+// command to all clients whenever files are opened, similar to the 'sync'
+// command for the copy/cut buffer. The list is shown with the 'recent-files'
+// command to open one of them again, and the most recent one is exported in
+// '$lf_last_opened'.
+
+const gRecentMax = 30
+
+// Files are labelled with single characters in the 'recent-files' menu.
+const gRecentLabels = "123456789abcdefghijklmnopqrstuvwxyz"
+
+// This function moves the given path to the front of the list, and drops the
+// oldest ones when the list grows too long.
+func addRecent(list []string, path string) []string {
+	recent := []string{path}
+	for _, p := range list {
+		if p != path && len(recent) < gRecentMax {
+			recent = append(recent, p)
+		}
+	}
+	return recent
+}
+
+func listRecent(labels, files []string) string {
+	t := new(tabwriter.Writer)
+	b := new(bytes.Buffer)
+
+	t.Init(b, 0, gOpts.tabstop, 2, '\t', 0)
+	fmt.Fprintln(t, "key\tpath")
+	for i, label := range labels {
+		fmt.Fprintf(t, "%s\t%s\n", label, files[i])
+	}
+	t.Flush()
+
+	return b.String()
+}
+
+// This function records the given files as opened for all clients, where the
+// last one is the most recent.
+func (app *app) recordRecent(paths []string) {
+	if gSingleMode {
+		(&callExpr{"recent-add", paths, 1}).eval(app, nil)
+		return
+	}
+
+	escaped := make([]string, len(paths))
+	for i, p := range paths {
+		escaped[i] = escape(p)
+	}
+	if err := remote("send recent-add " + strings.Join(escaped, " ")); err != nil {
+		log.Printf("recording recent files: %s", err)
+	}
+}
+
+func (app *app) addRecentFiles(paths []string) {
+	for _, p := range paths {
+		app.recent = addRecent(app.recent, p)
+	}
+	if len(app.recent) != 0 {
+		os.Setenv("lf_last_opened", quoteString(app.recent[0]))
+	}
+}
+
+func (app *app) recentFiles() {
+	if len(app.recent) == 0 {
+		app.ui.echoerr("recent-files: no recent files")
+		return
+	}
+
+	labels := hopLabels(gRecentLabels, len(app.recent))
+	app.ui.menu = listRecent(labels, app.recent)
+	app.ui.cmdPrefix = "recent-files: "
+}
+
+// This function opens the file with the given label in the 'recent-files' menu
+// again, by selecting it and calling the 'open' command.
+func (app *app) recentOpen(arg string) {
+	normal(app)
+
+	labels := hopLabels(gRecentLabels, len(app.recent))
+	for i, label := range labels {
+		if label != arg {
+			continue
+		}
+
+		path := app.recent[i]
+		if _, err := os.Stat(path); err != nil {
+			app.ui.echoerrf("recent-files: %s", err)
+			return
+		}
+
+		(&callExpr{"select", []string{path}, 1}).eval(app, nil)
+		(&callExpr{"open", nil, 1}).eval(app, nil)
+		return
+	}
+
+	app.ui.echoerr("recent-files: no such file")
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAddRecent(t *testing.T) {
+	tests := []struct {
+		list []string
+		path string
+		exp  []string
+	}{
+		{nil, "/a", []string{"/a"}},
+		{[]string{"/a", "/b"}, "/c", []string{"/c", "/a", "/b"}},
+		{[]string{"/a", "/b", "/c"}, "/b", []string{"/b", "/a", "/c"}},
+		{[]string{"/a"}, "/a", []string{"/a"}},
+	}
+
+	for _, test := range tests {
+		if got := addRecent(test.list, test.path); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("at input '%v' with '%s' expected '%v' but got '%v'", test.list, test.path, test.exp, got)
+		}
+	}
+
+	var list []string
+	for i := range gRecentMax + 5 {
+		list = addRecent(list, fmt.Sprintf("/%d", i))
+	}
+	if len(list) != gRecentMax || list[0] != fmt.Sprintf("/%d", gRecentMax+4) {
+		t.Errorf("at input '%d' paths expected '%d' paths but got '%d'", gRecentMax+5, gRecentMax, len(list))
+	}
+}
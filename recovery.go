@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The recovery file keeps snapshots of the selection and the copy/cut buffer,
+// which are written periodically with the 'autosave' option so that they can
+// be restored with the 'restore-selection' command after being lost (e.g. with
+// an accidental 'clear' or 'unselect'). Each client keeps its own snapshot, and
+// the snapshots of the last few clients are kept so that clients do not
+// overwrite each other. An empty selection or buffer never replaces a
+// non-empty one in the snapshot, so that the last non-empty ones are kept for
+// recovery. Each snapshot starts with a 'client' line with the id of the
+// client, which is followed by a 'select' line with the selected paths, and a
+// 'copy' or 'move' line with the paths in the buffer if it is not empty. The
+// most recent snapshot comes first.
+
+const gRecoveryMax = 10
+
+type recovery struct {
+	client     int
+	selections []string
+	files      []string
+	cp         bool
+}
+
+func readRecovery(path string) (rs []recovery, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// paths are absolute, so keywords can not be confused with them
+	inFiles := false
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if id, ok := strings.CutPrefix(line, "client "); ok {
+			n, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, fmt.Errorf("reading recovery file: invalid client: %s", id)
+			}
+			rs = append(rs, recovery{client: n})
+			inFiles = false
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		// files written before snapshots were kept per client have no
+		// 'client' line
+		if len(rs) == 0 {
+			rs = append(rs, recovery{})
+		}
+		r := &rs[len(rs)-1]
+
+		switch line {
+		case "select":
+			inFiles = false
+		case "copy", "move":
+			r.cp = line == "copy"
+			inFiles = true
+		default:
+			if inFiles {
+				r.files = append(r.files, line)
+			} else {
+				r.selections = append(r.selections, line)
+			}
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading recovery file: %s", err)
+	}
+
+	return rs, nil
+}
+
+func writeRecovery(path string, rs []recovery) error {
+	var b bytes.Buffer
+
+	for _, r := range rs {
+		fmt.Fprintf(&b, "client %d\n", r.client)
+
+		fmt.Fprintln(&b, "select")
+		for _, p := range r.selections {
+			fmt.Fprintln(&b, p)
+		}
+
+		if len(r.files) != 0 {
+			if r.cp {
+				fmt.Fprintln(&b, "copy")
+			} else {
+				fmt.Fprintln(&b, "move")
+			}
+			for _, p := range r.files {
+				fmt.Fprintln(&b, p)
+			}
+		}
+	}
+
+	if err := writeDataFile(path, b.Bytes()); err != nil {
+		return fmt.Errorf("writing recovery file: %s", err)
+	}
+	return nil
+}
+
+// This function merges the given snapshot of a client into the given
+// snapshots. The selection and the buffer of the previous snapshot of the
+// client are kept when they are empty in the given snapshot, and the merged
+// snapshot is moved to the front, dropping the oldest snapshots beyond
+// 'gRecoveryMax'.
+func mergeRecovery(rs []recovery, r recovery) []recovery {
+	if i := slices.IndexFunc(rs, func(old recovery) bool { return old.client == r.client }); i >= 0 {
+		old := rs[i]
+		if len(r.selections) == 0 {
+			r.selections = old.selections
+		}
+		if len(r.files) == 0 {
+			r.files, r.cp = old.files, old.cp
+		}
+		rs = slices.Delete(slices.Clone(rs), i, i+1)
+	}
+
+	if len(r.selections) == 0 && len(r.files) == 0 {
+		return rs
+	}
+
+	rs = append([]recovery{r}, rs...)
+	return rs[:min(len(rs), gRecoveryMax)]
+}
+
+func (app *app) writeRecovery() error {
+	r := recovery{client: gClientID}
+
+	indices := make([]int, 0, len(app.nav.selections))
+	for path, index := range app.nav.selections {
+		r.selections = append(r.selections, path)
+		indices = append(indices, index)
+	}
+	sort.Sort(indexedSelections{paths: r.selections, indices: indices})
+
+	files, cp, err := loadFiles()
+	if err != nil {
+		return err
+	}
+	r.files, r.cp = files, cp
+
+	return withDataLock(gRecoveryPath, func() error {
+		rs, err := readRecovery(gRecoveryPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return writeRecovery(gRecoveryPath, mergeRecovery(rs, r))
+	})
+}
+
+// This function restores the selection and the copy/cut buffer from the
+// snapshot of the client in the recovery file. Restored paths are added to the current selection, whereas
+// the buffer is only restored when it is empty so that it does not replace
+// files copied or cut afterwards. Paths that no longer exist are skipped.
+func (app *app) restoreSelection() {
+	var rs []recovery
+	err := withDataLock(gRecoveryPath, func() (err error) {
+		rs, err = readRecovery(gRecoveryPath)
+		return
+	})
+	if os.IsNotExist(err) || err == nil && len(rs) == 0 {
+		app.ui.echoerr("restore-selection: nothing to restore")
+		return
+	}
+	if err != nil {
+		app.ui.echoerrf("restore-selection: %s", err)
+		return
+	}
+
+	// the snapshot of this client is preferred, and otherwise the most recent
+	// one is restored (e.g. after restarting the client)
+	r := rs[0]
+	if i := slices.IndexFunc(rs, func(r recovery) bool { return r.client == gClientID }); i >= 0 {
+		r = rs[i]
+	}
+
+	selected := 0
+	for _, path := range r.selections {
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		if _, ok := app.nav.selections[path]; !ok {
+			app.nav.selections[path] = app.nav.selectionInd
+			app.nav.selectionInd++
+		}
+		selected++
+	}
+
+	var buffered []string
+	if list, _, err := loadFiles(); err == nil && len(list) == 0 {
+		for _, path := range r.files {
+			if _, err := os.Lstat(path); err == nil {
+				buffered = append(buffered, path)
+			}
+		}
+	}
+
+	if len(buffered) != 0 {
+		if err := saveFiles(buffered, r.cp); err != nil {
+			app.ui.echoerrf("restore-selection: %s", err)
+			return
+		}
+		if gSingleMode {
+			if err := app.nav.sync(); err != nil {
+				app.ui.echoerrf("restore-selection: %s", err)
+				return
+			}
+		} else {
+			if err := remote("send sync"); err != nil {
+				app.ui.echoerrf("restore-selection: %s", err)
+				return
+			}
+		}
+	}
+
+	app.ui.loadFileInfo(app.nav)
+
+	op := "cut"
+	if r.cp {
+		op = "copied"
+	}
+	app.ui.echomsg(fmt.Sprintf("restore-selection: restored %d selected and %d %s files", selected, len(buffered), op))
+}
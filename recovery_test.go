@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recovery")
+
+	tests := [][]recovery{
+		{{1, []string{"/a", "/b c"}, nil, false}},
+		{{1, nil, []string{"/d"}, true}},
+		{{2, []string{"/a"}, []string{"/d", "/e"}, false}, {1, []string{"/f"}, nil, false}},
+	}
+
+	for _, test := range tests {
+		if err := writeRecovery(path, test); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := readRecovery(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, test) {
+			t.Errorf("at input '%v' expected '%v' but got '%v'", test, test, got)
+		}
+	}
+
+	// files without 'client' lines are read as a single snapshot
+	if err := os.WriteFile(path, []byte("select\n/a\ncopy\n/b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readRecovery(path)
+	if exp := []recovery{{0, []string{"/a"}, []string{"/b"}, true}}; err != nil || !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected '%v' but got '%v' (%v)", exp, got, err)
+	}
+}
+
+func TestMergeRecovery(t *testing.T) {
+	rs := []recovery{
+		{1, []string{"/a"}, []string{"/b"}, true},
+		{2, []string{"/c"}, nil, false},
+	}
+
+	tests := []struct {
+		r   recovery
+		exp []recovery
+	}{
+		// empty parts do not replace non-empty ones
+		{recovery{1, nil, nil, false}, rs},
+		{recovery{1, []string{"/d"}, nil, false}, []recovery{
+			{1, []string{"/d"}, []string{"/b"}, true},
+			{2, []string{"/c"}, nil, false},
+		}},
+		{recovery{2, nil, []string{"/e"}, false}, []recovery{
+			{2, []string{"/c"}, []string{"/e"}, false},
+			{1, []string{"/a"}, []string{"/b"}, true},
+		}},
+		{recovery{3, []string{"/f"}, nil, false}, []recovery{
+			{3, []string{"/f"}, nil, false},
+			{1, []string{"/a"}, []string{"/b"}, true},
+			{2, []string{"/c"}, nil, false},
+		}},
+	}
+
+	for _, test := range tests {
+		if got := mergeRecovery(rs, test.r); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("at input '%v' expected '%v' but got '%v'", test.r, test.exp, got)
+		}
+	}
+
+	// only the most recent snapshots are kept
+	var got []recovery
+	for i := range gRecoveryMax + 2 {
+		got = mergeRecovery(got, recovery{client: i, selections: []string{"/a"}})
+	}
+	if len(got) != gRecoveryMax || got[0].client != gRecoveryMax+1 {
+		t.Errorf("expected %d snapshots starting with the latest but got '%v'", gRecoveryMax, got)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Directories can be refreshed in different ways for different paths with the
+// 'refreshrule' command (e.g. 'refreshrule /mnt/nas/** poll:30s'), so that
+// network mounts are checked gently while local directories are watched. The
+// strategy of a rule is either 'watch' to watch the directories for changes
+// as with the 'watch' option, 'poll:<duration>' to check the directories for
+// changes at the given interval, or 'manual' to only reload the directories
+// with the 'reload' command. Patterns are matched in the same way as scoped
+// commands, and the last matching rule is used. Directories without a
+// matching rule are refreshed with the 'watch' and 'period' options.
+
+type refreshRule struct {
+	pattern  string
+	strategy string
+	interval time.Duration
+}
+
+var gRefreshRules []refreshRule
+
+// This function parses the given strategy of a refresh rule.
+func parseRefreshRule(pattern, strategy string) (refreshRule, error) {
+	rule := refreshRule{pattern: pattern, strategy: strategy}
+
+	switch {
+	case strategy == "watch", strategy == "manual":
+	case strings.HasPrefix(strategy, "poll:"):
+		d, err := time.ParseDuration(strings.TrimPrefix(strategy, "poll:"))
+		if err != nil {
+			return rule, fmt.Errorf("invalid interval: %s", err)
+		}
+		if d < time.Second {
+			return rule, errors.New("interval should be at least a second")
+		}
+		rule.strategy = "poll"
+		rule.interval = d
+	default:
+		return rule, fmt.Errorf("strategy should either be 'watch', 'poll:<duration>' or 'manual': %s", strategy)
+	}
+
+	return rule, nil
+}
+
+// This function adds the given rule, replacing an earlier rule with the same
+// pattern, or removes the rule of the pattern when the strategy is empty.
+func setRefreshRule(rule refreshRule) {
+	for i, r := range gRefreshRules {
+		if r.pattern == rule.pattern {
+			gRefreshRules = append(gRefreshRules[:i], gRefreshRules[i+1:]...)
+			break
+		}
+	}
+	if rule.strategy != "" {
+		gRefreshRules = append(gRefreshRules, rule)
+	}
+}
+
+// This function returns the last refresh rule matching the given directory.
+func findRefreshRule(path string) (refreshRule, bool) {
+	for i := len(gRefreshRules) - 1; i >= 0; i-- {
+		if matchScope(gRefreshRules[i].pattern, path) {
+			return gRefreshRules[i], true
+		}
+	}
+	return refreshRule{}, false
+}
+
+func (app *app) refreshRule(args []string) {
+	var rule refreshRule
+	switch len(args) {
+	case 1:
+		rule.pattern = args[0]
+	case 2:
+		var err error
+		if rule, err = parseRefreshRule(args[0], args[1]); err != nil {
+			app.ui.echoerrf("refreshrule: %s", err)
+			return
+		}
+	default:
+		app.ui.echoerr("refreshrule: requires a pattern and an optional strategy")
+		return
+	}
+
+	setRefreshRule(rule)
+
+	if rule.strategy == "watch" {
+		app.watch.start()
+	}
+	for _, dir := range app.nav.dirs {
+		app.watchDir(dir)
+	}
+
+	if rule.strategy == "poll" && app.refreshTicker.C == nil {
+		app.refreshTicker = time.NewTicker(time.Second)
+	}
+}
+
+func hasWatchRules() bool {
+	return slices.ContainsFunc(gRefreshRules, func(r refreshRule) bool {
+		return r.strategy == "watch"
+	})
+}
+
+// This function reports whether the given directory is watched for changes.
+func isWatched(path string) bool {
+	if inRemote(path) {
+		return false
+	}
+	if rule, ok := findRefreshRule(path); ok {
+		return rule.strategy == "watch"
+	}
+	return gOpts.watch
+}
+
+// This function checks the loaded directories for changes, which are the
+// directories without a refresh rule on the ticks of the 'period' option, or
+// the directories with a 'poll' rule when their interval has passed since
+// they were last checked.
+func (app *app) pollDirs(period bool) {
+	now := time.Now()
+
+	dirs := app.nav.dirs
+	if app.nav.pane != nil {
+		dirs = append(dirs[:len(dirs):len(dirs)], app.nav.pane)
+	}
+
+	for _, d := range dirs {
+		rule, ok := findRefreshRule(d.path)
+		if period {
+			if ok {
+				continue
+			}
+		} else {
+			if !ok || rule.strategy != "poll" || now.Sub(app.polled[d.path]) < rule.interval {
+				continue
+			}
+			app.polled[d.path] = now
+		}
+		app.nav.checkDir(d)
+	}
+
+	app.nav.pruneSelections()
+}
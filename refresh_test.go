@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshRules(t *testing.T) {
+	defer func(rules []refreshRule, watch bool) {
+		gRefreshRules = rules
+		gOpts.watch = watch
+	}(gRefreshRules, gOpts.watch)
+	gRefreshRules = nil
+	gOpts.watch = true
+
+	for _, strategy := range []string{"poll", "poll:100ms", "poll:x", "never"} {
+		if _, err := parseRefreshRule("/mnt/**", strategy); err == nil {
+			t.Errorf("expected error for strategy '%s'", strategy)
+		}
+	}
+
+	for _, r := range [][2]string{{"/mnt/**", "poll:30s"}, {"/mnt/nas/tmp", "manual"}, {"/mnt/local/**", "watch"}} {
+		rule, err := parseRefreshRule(r[0], r[1])
+		if err != nil {
+			t.Fatalf("parsing rule '%s %s': %s", r[0], r[1], err)
+		}
+		setRefreshRule(rule)
+	}
+
+	tests := []struct {
+		path     string
+		strategy string
+		watched  bool
+	}{
+		{"/mnt", "poll", false},
+		{"/mnt/nas", "poll", false},
+		{"/mnt/nas/tmp", "manual", false},
+		{"/mnt/local/src", "watch", true},
+		{"/home", "", true},
+	}
+
+	for _, test := range tests {
+		rule, _ := findRefreshRule(test.path)
+		if rule.strategy != test.strategy {
+			t.Errorf("at input '%s' expected strategy '%s' but got '%s'", test.path, test.strategy, rule.strategy)
+		}
+		if got := isWatched(test.path); got != test.watched {
+			t.Errorf("at input '%s' expected watched '%t' but got '%t'", test.path, test.watched, got)
+		}
+	}
+
+	if rule, _ := findRefreshRule("/mnt/nas"); rule.interval != 30*time.Second {
+		t.Errorf("expected interval of 30s but got '%s'", rule.interval)
+	}
+
+	setRefreshRule(refreshRule{pattern: "/mnt/**"})
+	if _, ok := findRefreshRule("/mnt/nas"); ok {
+		t.Errorf("expected no rule after removing it")
+	}
+}
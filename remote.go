@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Remote hosts can be browsed over SFTP with the 'connect' command (e.g.
+// 'connect sftp://user@host/path'), which starts the 'ssh' command in the
+// background and changes to the given path on the host. Each connected host
+// is mounted on an empty local directory in the temporary directory (e.g.
+// '/tmp/lf-sftp-1234/user@host'), which corresponds to the root directory of
+// the host, so that remote files have paths under this directory (e.g.
+// '/tmp/lf-sftp-1234/user@host/home/user') and most commands work unchanged.
+// Shell commands run in the local directory of the host while browsing it.
+// Remote files can be previewed when they are small and copied to local
+// directories, and local files can be copied to remote directories, whereas
+// other changes (e.g. moving, renaming and deleting) are not supported.
+// Remote directories are not watched for changes, so they are only loaded
+// again with the 'reload' command or after copying files into them.
+
+const gSftpPreviewMax = 1 << 20
+
+var errRemoteReadOnly = errors.New("remote files can only be copied")
+
+var gRemotes = struct {
+	mutex sync.Mutex
+	dir   string
+	conns map[string]*sftpClient
+}{conns: make(map[string]*sftpClient)}
+
+// This function splits the given path into the client of a connected host and
+// the path on the host. It returns false when the path is not on a connected
+// host.
+func remotePath(p string) (*sftpClient, string, bool) {
+	gRemotes.mutex.Lock()
+	defer gRemotes.mutex.Unlock()
+
+	if len(gRemotes.conns) == 0 {
+		return nil, "", false
+	}
+
+	for mount, c := range gRemotes.conns {
+		if p == mount {
+			return c, "/", true
+		}
+		if rel, ok := strings.CutPrefix(p, mount+string(filepath.Separator)); ok {
+			return c, path.Clean("/" + filepath.ToSlash(rel)), true
+		}
+	}
+
+	return nil, "", false
+}
+
+// This function returns the local directory of the host of the given remote
+// path.
+func remoteMount(p string) string {
+	gRemotes.mutex.Lock()
+	defer gRemotes.mutex.Unlock()
+
+	for mount := range gRemotes.conns {
+		if p == mount || strings.HasPrefix(p, mount+string(filepath.Separator)) {
+			return mount
+		}
+	}
+
+	return ""
+}
+
+// This function reports whether the given path is on a connected host.
+func inRemote(p string) bool {
+	_, _, ok := remotePath(p)
+	return ok
+}
+
+func remoteStat(p string) (fs.FileInfo, error) {
+	c, inner, ok := remotePath(p)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	info, err := c.lstat(inner)
+	if err != nil {
+		return nil, err
+	}
+	info.name = filepath.Base(p)
+	return info, nil
+}
+
+// This function reads the given remote directory. Symbolic links are followed
+// to show their targets as in local directories.
+func readRemoteFiles(dirPath string, c *sftpClient, inner string) ([]*file, error) {
+	entries, err := c.readDir(inner)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*file, 0, len(entries))
+	for _, e := range entries {
+		f := newImageFile(filepath.Join(dirPath, e.name), e)
+		if e.mode&fs.ModeSymlink != 0 {
+			p := path.Join(inner, e.name)
+			if stat, err := c.stat(p); err == nil {
+				stat.name = e.name
+				f.FileInfo = stat
+				f.linkState = working
+			} else {
+				f.linkState = broken
+			}
+			f.linkTarget, _ = c.readlink(p)
+		}
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// This function walks the given remote tree like 'filepath.Walk'.
+func walkRemote(c *sftpClient, root, inner string, fn filepath.WalkFunc) error {
+	info, err := c.lstat(inner)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	info.name = filepath.Base(root)
+
+	err = walkRemoteDir(c, root, inner, info, fn)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkRemoteDir(c *sftpClient, p, inner string, info *sftpInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(p, info, nil)
+	}
+
+	entries, err := c.readDir(inner)
+	err1 := fn(p, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	slices.SortFunc(entries, func(a, b *sftpInfo) int { return strings.Compare(a.name, b.name) })
+	for _, e := range entries {
+		err := walkRemoteDir(c, filepath.Join(p, e.name), path.Join(inner, e.name), e, fn)
+		if err != nil && (err != filepath.SkipDir || e.IsDir()) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// This function creates the given file for writing, either on a connected host
+// or in the local file system.
+func createPath(p string, mode fs.FileMode) (io.WriteCloser, error) {
+	if c, inner, ok := remotePath(p); ok {
+		f, err := c.create(inner, mode)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+}
+
+// This function creates the given directory like 'os.MkdirAll', either on a
+// connected host or in the local file system.
+func mkdirAllPath(p string, mode fs.FileMode) error {
+	c, inner, ok := remotePath(p)
+	if !ok {
+		return os.MkdirAll(p, mode)
+	}
+
+	if info, err := c.stat(inner); err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return &fs.PathError{Op: "mkdir", Path: p, Err: errors.New("not a directory")}
+	}
+	if parent := filepath.Dir(p); parent != p {
+		if err := mkdirAllPath(parent, mode); err != nil {
+			return err
+		}
+	}
+	return c.mkdir(inner, mode)
+}
+
+func symlinkPath(target, p string) error {
+	if c, inner, ok := remotePath(p); ok {
+		return c.symlink(target, inner)
+	}
+	return os.Symlink(target, p)
+}
+
+func readlinkPath(p string) (string, error) {
+	if c, inner, ok := remotePath(p); ok {
+		return c.readlink(inner)
+	}
+	return os.Readlink(p)
+}
+
+func removePath(p string) error {
+	if c, inner, ok := remotePath(p); ok {
+		return c.remove(inner)
+	}
+	return os.Remove(p)
+}
+
+func chtimesPath(p string, atime, mtime time.Time) error {
+	if c, inner, ok := remotePath(p); ok {
+		return c.chtimes(inner, atime, mtime)
+	}
+	return os.Chtimes(p, atime, mtime)
+}
+
+// This function returns the local directory of the given host, connecting to
+// the host unless it is already connected.
+func connectRemote(u sftpURL) (string, error) {
+	gRemotes.mutex.Lock()
+	if gRemotes.dir == "" {
+		gRemotes.dir = filepath.Join(os.TempDir(), fmt.Sprintf("lf-sftp-%d", os.Getpid()))
+	}
+	mount := filepath.Join(gRemotes.dir, u.name())
+	c, ok := gRemotes.conns[mount]
+	gRemotes.mutex.Unlock()
+
+	if ok && c.alive() {
+		return mount, nil
+	}
+
+	c, err := dialSftp(u.host, u.port)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(mount, 0o700); err != nil {
+		c.quit()
+		return "", err
+	}
+
+	gRemotes.mutex.Lock()
+	if old, ok := gRemotes.conns[mount]; ok {
+		go old.quit()
+	}
+	gRemotes.conns[mount] = c
+	gRemotes.mutex.Unlock()
+
+	return mount, nil
+}
+
+// This function disconnects from all hosts and removes their local
+// directories, which are only removed when they are empty.
+func closeRemotes() {
+	gRemotes.mutex.Lock()
+	defer gRemotes.mutex.Unlock()
+
+	for mount, c := range gRemotes.conns {
+		c.quit()
+		delete(gRemotes.conns, mount)
+		if err := os.Remove(mount); err != nil {
+			log.Printf("removing remote directory: %s", err)
+		}
+	}
+	if gRemotes.dir != "" {
+		os.Remove(gRemotes.dir)
+	}
+}
+
+func (app *app) connect(addr string) {
+	u, err := parseSftpURL(addr)
+	if err != nil {
+		app.ui.echoerrf("connect: %s", err)
+		return
+	}
+
+	app.ui.echo(fmt.Sprintf("Connecting to %s...", u.host))
+
+	go func() {
+		mount, err := connectRemote(u)
+		if err != nil {
+			app.ui.exprChan <- &callExpr{"echoerr", []string{fmt.Sprintf("connect: %s", err)}, 1}
+			return
+		}
+
+		c, _, _ := remotePath(mount)
+		dir := u.path
+		if dir == "" {
+			if dir, err = c.realpath("."); err != nil {
+				app.ui.exprChan <- &callExpr{"echoerr", []string{fmt.Sprintf("connect: %s", err)}, 1}
+				return
+			}
+		}
+
+		p := filepath.Join(mount, filepath.FromSlash(strings.TrimPrefix(dir, "/")))
+		app.ui.exprChan <- &callExpr{"cd", []string{p}, 1}
+		app.ui.exprChan <- &callExpr{"echo", []string{fmt.Sprintf("Connected to %s", u.host)}, 1}
+	}()
+}
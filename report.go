@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// A report is kept for the last file operation (e.g. 'paste' and 'delete')
+// with the number of files handled successfully, skipped, or failed along
+// with their reasons. Errors are still shown one by one while the operation is
+// running, and the report is shown in the pager afterwards when any file is
+// skipped or failed, so that errors are not only visible in the log file. The
+// report can be shown again later with the 'last-report' command.
+
+type reportItem struct {
+	path    string
+	reasons []string
+}
+
+type report struct {
+	op      string
+	dstDir  string
+	detail  string
+	done    int
+	skipped []reportItem
+	failed  []reportItem
+}
+
+func newReport(op, dstDir string) *report {
+	return &report{op: op, dstDir: dstDir}
+}
+
+// This function records the result for the given file, which is failed when
+// there are any reasons.
+func (r *report) add(path string, reasons []string) {
+	if len(reasons) == 0 {
+		r.done++
+		return
+	}
+	r.failed = append(r.failed, reportItem{path, reasons})
+}
+
+func (r *report) skip(path, reason string) {
+	r.skipped = append(r.skipped, reportItem{path, []string{reason}})
+}
+
+// This function reports whether the given file is failed or skipped in the
+// report.
+func (r *report) unfinished(path string) bool {
+	match := func(item reportItem) bool { return item.path == path }
+	return slices.ContainsFunc(r.failed, match) || slices.ContainsFunc(r.skipped, match)
+}
+
+func (r *report) ok() bool {
+	return len(r.skipped) == 0 && len(r.failed) == 0
+}
+
+func (r *report) summary() string {
+	var verb string
+	switch r.op {
+	case "copy":
+		verb = "copied"
+	case "move":
+		verb = "moved"
+	case "delete":
+		verb = "deleted"
+	case "archive":
+		verb = "archived"
+	case "split":
+		verb = "split"
+	case "join":
+		verb = "joined"
+	case "hash":
+		verb = "hashed"
+	case "verify":
+		verb = "verified"
+	case "trash":
+		verb = "trashed"
+	case "restore":
+		verb = "restored"
+	}
+	s := fmt.Sprintf("%s: %d %s, %d skipped, %d failed", r.op, r.done, verb, len(r.skipped), len(r.failed))
+	if r.detail != "" {
+		s += " (" + r.detail + ")"
+	}
+	return s
+}
+
+func (r *report) String() string {
+	var b strings.Builder
+
+	b.WriteString(r.summary())
+
+	section := func(title string, items []reportItem) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n\n%s:", title)
+		for _, item := range items {
+			fmt.Fprintf(&b, "\n%s", item.path)
+			for _, reason := range item.reasons {
+				fmt.Fprintf(&b, "\n    %s", reason)
+			}
+		}
+	}
+	section("skipped", r.skipped)
+	section("failed", r.failed)
+
+	return b.String()
+}
+
+// Reports are written by operations running in the background and read by the
+// 'last-report' command, so the last one is kept behind a lock.
+type reportLog struct {
+	mutex sync.Mutex
+	last  *report
+}
+
+func (l *reportLog) set(r *report) {
+	l.mutex.Lock()
+	l.last = r
+	l.mutex.Unlock()
+}
+
+func (l *reportLog) get() *report {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.last
+}
+
+// This function keeps the report of a finished operation, and shows it when
+// any file is skipped or failed.
+func (app *app) finishReport(r *report) {
+	app.nav.reports.set(r)
+	if !r.ok() {
+		app.ui.exprChan <- &callExpr{"last-report", nil, 1}
+	}
+}
+
+func (app *app) lastReport() {
+	r := app.nav.reports.get()
+	if r == nil {
+		app.ui.echoerr("last-report: no file operation yet")
+		return
+	}
+
+	if gHeadless {
+		for _, line := range strings.Split(r.String(), "\n") {
+			if line != "" {
+				app.ui.echomsg(line)
+			}
+		}
+		return
+	}
+
+	app.ui.pager = newPager("last-report: "+r.summary(), r.String())
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	r := newReport("copy", "/dst")
+	r.add("/foo", nil)
+	r.add("/bar", []string{"copy: permission denied", "copy: no space left on device"})
+	r.skip("/baz", "source and destination are the same file")
+	r.add("/qux", nil)
+
+	if r.ok() {
+		t.Errorf("expected report with failures")
+	}
+
+	exp := "copy: 2 copied, 1 skipped, 1 failed"
+	if got := r.summary(); got != exp {
+		t.Errorf("expected '%s' but got '%s'", exp, got)
+	}
+
+	exp = `copy: 2 copied, 1 skipped, 1 failed
+
+skipped:
+/baz
+    source and destination are the same file
+
+failed:
+/bar
+    copy: permission denied
+    copy: no space left on device`
+	if got := r.String(); got != exp {
+		t.Errorf("expected '%s' but got '%s'", exp, got)
+	}
+
+	r = newReport("delete", "")
+	r.add("/foo", nil)
+	if !r.ok() || r.String() != "delete: 1 deleted, 0 skipped, 0 failed" {
+		t.Errorf("expected successful report but got '%s'", r.String())
+	}
+}
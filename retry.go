@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Failed files in the report of the last file operation can be selected with
+// the 'select-failed' command and run again with the 'retry-failed' command,
+// which only retries the failed files without touching the rest of the
+// operation. When any failed file is selected, only the selected ones are
+// retried. Files can be retried with a different strategy, either by
+// overwriting existing destination files ('overwrite') instead of renaming new
+// files with 'dupfilefmt', or by running the operation with 'sudo' in the
+// shell.
+
+// This function returns the failed files of the given report to retry, which
+// are the selected ones when any of them are selected.
+func retryFiles(r *report, selections map[string]int) []string {
+	var all, selected []string
+	for _, item := range r.failed {
+		all = append(all, item.path)
+		if _, ok := selections[item.path]; ok {
+			selected = append(selected, item.path)
+		}
+	}
+
+	if len(selected) != 0 {
+		return selected
+	}
+	return all
+}
+
+// This function returns the shell command to run the operation of the given
+// report for the given files with 'sudo'.
+func sudoCommand(r *report, paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	args := strings.Join(quoted, " ")
+
+	switch r.op {
+	case "copy":
+		return fmt.Sprintf("sudo cp -R -- %s %s", args, shellQuote(r.dstDir))
+	case "move":
+		return fmt.Sprintf("sudo mv -- %s %s", args, shellQuote(r.dstDir))
+	default:
+		return fmt.Sprintf("sudo rm -rf -- %s", args)
+	}
+}
+
+func (app *app) failedReport() (*report, error) {
+	r := app.nav.reports.get()
+	if r == nil || len(r.failed) == 0 {
+		return nil, errors.New("no failed files in the last report")
+	}
+	return r, nil
+}
+
+func (app *app) selectFailed() {
+	r, err := app.failedReport()
+	if err != nil {
+		app.ui.echoerrf("select-failed: %s", err)
+		return
+	}
+
+	for _, item := range r.failed {
+		if _, err := os.Lstat(item.path); err != nil {
+			continue
+		}
+		if _, ok := app.nav.selections[item.path]; !ok {
+			app.nav.selections[item.path] = app.nav.selectionInd
+			app.nav.selectionInd++
+		}
+	}
+
+	app.ui.loadFileInfo(app.nav)
+}
+
+func (app *app) retryFailed(strategy string, dryRun bool) {
+	r, err := app.failedReport()
+	if err != nil {
+		app.ui.echoerrf("retry-failed: %s", err)
+		return
+	}
+
+	if r.op != "copy" && r.op != "move" && r.op != "delete" {
+		app.ui.echoerrf("retry-failed: retrying is not supported for %s", r.op)
+		return
+	}
+
+	list := retryFiles(r, app.nav.selections)
+
+	overwrite := false
+	switch strategy {
+	case "", "rename":
+	case "overwrite":
+		overwrite = r.op != "delete"
+	case "sudo":
+		if runtime.GOOS == "windows" {
+			app.ui.echoerr("retry-failed: sudo is not supported on windows")
+			return
+		}
+		if dryRun {
+			app.showDryRun("retry-failed", []string{fmt.Sprintf("run '%s'", sudoCommand(r, list))})
+			return
+		}
+		for _, path := range list {
+			delete(app.nav.selections, path)
+		}
+		(&execExpr{"$", sudoCommand(r, list)}).eval(app, nil)
+		return
+	default:
+		app.ui.echoerr("retry-failed: strategy should either be 'rename', 'overwrite' or 'sudo'")
+		return
+	}
+
+	if dryRun {
+		plan := deletePlan(list)
+		if r.op != "delete" {
+			plan = copyPlan(list, r.dstDir, r.op == "copy", overwrite)
+		}
+		app.showDryRun("retry-failed", plan)
+		return
+	}
+
+	for _, path := range list {
+		delete(app.nav.selections, path)
+	}
+
+	switch r.op {
+	case "copy", "move":
+		if err := app.nav.pasteAsync(app, list, r.dstDir, r.op == "copy", overwrite); err != nil {
+			app.ui.echoerrf("retry-failed: %s", err)
+			return
+		}
+	case "delete":
+		tab := app.nav.tabID
+		runAsync(func() { app.nav.deleteAsync(app, list, tab) })
+	}
+
+	app.ui.loadFileInfo(app.nav)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRetryFiles(t *testing.T) {
+	r := newReport("copy", "/dst")
+	r.add("/foo", []string{"copy: permission denied"})
+	r.add("/bar", nil)
+	r.add("/baz", []string{"copy: permission denied"})
+
+	tests := []struct {
+		selections map[string]int
+		exp        []string
+	}{
+		{nil, []string{"/foo", "/baz"}},
+		{map[string]int{"/bar": 0}, []string{"/foo", "/baz"}},
+		{map[string]int{"/bar": 0, "/baz": 1}, []string{"/baz"}},
+	}
+
+	for _, test := range tests {
+		if got := retryFiles(r, test.selections); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("at input '%v' expected '%v' but got '%v'", test.selections, test.exp, got)
+		}
+	}
+}
+
+func TestSudoCommand(t *testing.T) {
+	tests := []struct {
+		op    string
+		paths []string
+		exp   string
+	}{
+		{"copy", []string{"/foo", "/it's"}, `sudo cp -R -- '/foo' '/it'\''s' '/dst'`},
+		{"move", []string{"/foo"}, `sudo mv -- '/foo' '/dst'`},
+		{"delete", []string{"/foo"}, `sudo rm -rf -- '/foo'`},
+	}
+
+	for _, test := range tests {
+		if got := sudoCommand(newReport(test.op, "/dst"), test.paths); got != test.exp {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.op, test.exp, got)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// The schema describes the options, commands and keys of the configuration
+// file in JSON Schema format, for editor plugins to provide completion and
+// validation of lfrc files. The configuration file is described as an object
+// with a property for each kind of statement (e.g. 'set' for options and 'map'
+// for key bindings). It is generated from the same tables used for evaluation
+// and completion, and descriptions are taken from the documentation, so that
+// it does not need to be updated separately.
+
+type schemaDoc map[string]any
+
+type schemaTopic struct {
+	kind string
+	name string
+}
+
+// kinds of topics described in each section of the documentation
+var schemaKinds = map[string]string{
+	"COMMANDS":              "command",
+	"COMMAND LINE COMMANDS": "command",
+	"OPTIONS":               "option",
+}
+
+// This function returns the first sentence of the documentation of each
+// command and option, keyed by kind and name, since some names (e.g. 'shell')
+// are used for both an option and a command. The kind is taken from the
+// section of the documentation.
+func schemaDescriptions() map[schemaTopic]string {
+	desc := make(map[schemaTopic]string)
+
+	var kind string
+	var topics []string
+	for _, line := range strings.Split(genDocMarkdown, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# "):
+			kind = schemaKinds[strings.TrimPrefix(line, "# ")]
+			topics = nil
+		case strings.HasPrefix(line, "## "):
+			if kind != "" {
+				topics = helpTopics(line)
+			}
+		case line == "" || strings.HasPrefix(line, "\t"):
+		default:
+			for _, topic := range topics {
+				key := schemaTopic{kind, topic}
+				if _, ok := desc[key]; !ok {
+					desc[key] = strings.ReplaceAll(line, "`", "'")
+				}
+			}
+			topics = nil
+		}
+	}
+
+	return desc
+}
+
+func schemaOption(field reflect.StructField, value reflect.Value, desc map[schemaTopic]string) schemaDoc {
+	opt := schemaDoc{}
+
+	switch field.Type.Kind() {
+	case reflect.Bool:
+		opt["type"] = "boolean"
+		opt["default"] = value.Bool()
+	case reflect.Int, reflect.Int64:
+		opt["type"] = "integer"
+		opt["default"] = value.Int()
+	default:
+		// lists are given as strings separated with ':'
+		opt["type"] = "string"
+		opt["default"] = fieldToString(value)
+	}
+
+	if d, ok := desc[schemaTopic{"option", field.Name}]; ok {
+		opt["description"] = d
+	}
+
+	return opt
+}
+
+func genSchema() ([]byte, error) {
+	desc := schemaDescriptions()
+
+	opts := schemaDoc{}
+	v := reflect.ValueOf(gOpts)
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if field.Type.Kind() == reflect.Map {
+			continue
+		}
+		opts[field.Name] = schemaOption(field, v.Field(i), desc)
+	}
+
+	localOpts := schemaDoc{}
+	for i := range reflect.TypeOf(gLocalOpts).NumField() {
+		field := reflect.TypeOf(gLocalOpts).Field(i)
+		if opt, ok := opts[field.Name]; ok {
+			localOpts[field.Name] = opt
+		}
+	}
+
+	var keys []string
+	for _, key := range gKeyVal {
+		keys = append(keys, key)
+	}
+	keys = append(keys, "<lt>", "<gt>", "<space>")
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	cmds := slices.Clone(gCmdWords)
+	slices.Sort(cmds)
+
+	cmdDescs := schemaDoc{}
+	for _, cmd := range cmds {
+		if d, ok := desc[schemaTopic{"command", cmd}]; ok {
+			cmdDescs[cmd] = d
+		}
+	}
+
+	keyMap := schemaDoc{
+		"type":          "object",
+		"propertyNames": schemaDoc{"$ref": "#/$defs/key"},
+		"additionalProperties": schemaDoc{
+			"type":        "string",
+			"description": "A command, or an empty string to remove the binding",
+		},
+	}
+
+	schema := schemaDoc{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "lfrc",
+		"description": "Configuration file of lf. Boolean options can also be given as 'no<option>' to disable and '<option>!' to toggle them.",
+		"type":        "object",
+		"properties": schemaDoc{
+			"set": schemaDoc{
+				"type":                 "object",
+				"properties":           opts,
+				"patternProperties":    schemaDoc{"^user_": schemaDoc{"type": "string"}},
+				"additionalProperties": false,
+			},
+			"setlocal": schemaDoc{
+				"type": "object",
+				"additionalProperties": schemaDoc{
+					"type":                 "object",
+					"description":          "Options local to a directory",
+					"properties":           localOpts,
+					"additionalProperties": false,
+				},
+			},
+			"map":  keyMap,
+			"nmap": keyMap,
+			"vmap": keyMap,
+			"cmap": keyMap,
+			"cmd": schemaDoc{
+				"type":                 "object",
+				"description":          "Custom commands, which override builtin commands with the same name",
+				"additionalProperties": schemaDoc{"type": "string"},
+			},
+		},
+		"$defs": schemaDoc{
+			"command": schemaDoc{
+				"enum":                cmds,
+				"x-lf-descriptions":   cmdDescs,
+				"x-lf-shell-prefixes": []string{"$", "%", "!", "&"},
+			},
+			"key": schemaDoc{
+				"type":              "string",
+				"description":       "A sequence of characters and special keys in angle brackets, which can be prefixed with 'c-', 's-' or 'a-' for modifiers (e.g. '<c-a>' or '<a-enter>')",
+				"pattern":           `^(<(c-|s-|a-)?[^<>]+>|[^<>])+$`,
+				"x-lf-special-keys": keys,
+			},
+		},
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenSchema(t *testing.T) {
+	b, err := genSchema()
+	if err != nil {
+		t.Fatalf("generating schema: %s", err)
+	}
+
+	var schema struct {
+		Properties struct {
+			Set struct {
+				Properties map[string]struct {
+					Type        string `json:"type"`
+					Default     any    `json:"default"`
+					Description string `json:"description"`
+				} `json:"properties"`
+			} `json:"set"`
+		} `json:"properties"`
+		Defs struct {
+			Command struct {
+				Enum         []string          `json:"enum"`
+				Descriptions map[string]string `json:"x-lf-descriptions"`
+			} `json:"command"`
+			Key struct {
+				Pattern string `json:"pattern"`
+			} `json:"key"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatalf("parsing schema: %s", err)
+	}
+
+	opts := schema.Properties.Set.Properties
+	tests := []struct {
+		name string
+		typ  string
+		def  any
+	}{
+		{"hidden", "boolean", false},
+		{"scrolloff", "integer", float64(0)},
+		{"ratios", "string", "1:2:3"},
+		{"sortby", "string", "natural"},
+	}
+
+	for _, test := range tests {
+		opt, ok := opts[test.name]
+		if !ok {
+			t.Errorf("at input '%s' expected an option but got none", test.name)
+			continue
+		}
+		if opt.Type != test.typ || opt.Default != test.def {
+			t.Errorf("at input '%s' expected '%s' '%v' but got '%s' '%v'", test.name, test.typ, test.def, opt.Type, opt.Default)
+		}
+	}
+
+	// 'shell' is both an option and a command with different descriptions
+	if desc := opts["shell"].Description; !strings.HasPrefix(desc, "Shell executable") {
+		t.Errorf("at input 'shell' expected the option description but got '%s'", desc)
+	}
+	if desc := schema.Defs.Command.Descriptions["shell"]; !strings.HasPrefix(desc, "Read a shell command") {
+		t.Errorf("at input 'shell' expected the command description but got '%s'", desc)
+	}
+
+	if _, ok := opts["nkeys"]; ok {
+		t.Errorf("at input 'nkeys' expected no option but got one")
+	}
+
+	if len(schema.Defs.Command.Enum) != len(gCmdWords) {
+		t.Errorf("expected '%d' commands but got '%d'", len(gCmdWords), len(schema.Defs.Command.Enum))
+	}
+
+	re := regexp.MustCompile(schema.Defs.Key.Pattern)
+	for _, key := range []string{"j", "gg", "<c-a>", "<a-enter>", "<lt>", "<f-1>"} {
+		if !re.MatchString(key) {
+			t.Errorf("at input '%s' expected a valid key but got invalid", key)
+		}
+	}
+	for _, key := range []string{"", "<c-a", "a>b"} {
+		if re.MatchString(key) {
+			t.Errorf("at input '%s' expected an invalid key but got valid", key)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Commands can be defined only for some directories by adding 'under' and a
+// pattern after their bodies (e.g. 'cmd deploy &{{ ... }} under ~/work/**'),
+// so that project specific commands do not need to be defined globally. A
+// scoped command is active when the current directory matches its pattern,
+// in which case it takes precedence over a global command with the same name.
+// Patterns are matched against whole paths, where '**' matches any number of
+// directories, including none, and other wildcards are matched within a path
+// component. Inactive scoped commands are left out of completion and the menu
+// shown for key prefixes.
+
+type scopedCmd struct {
+	scope string
+	expr  expr
+}
+
+// Scoped commands are kept in the order of their definitions for each name,
+// and the last one matching the current directory is used.
+var gScopedCmds = make(map[string][]scopedCmd)
+
+// This function defines a scoped command, replacing an earlier definition
+// with the same scope, or removes it when the given expression is nil.
+func setScopedCmd(name, scope string, e expr) {
+	cmds := gScopedCmds[name]
+	for i, c := range cmds {
+		if c.scope == scope {
+			cmds = append(cmds[:i], cmds[i+1:]...)
+			break
+		}
+	}
+	if e != nil {
+		cmds = append(cmds, scopedCmd{scope, e})
+	}
+	if len(cmds) == 0 {
+		delete(gScopedCmds, name)
+	} else {
+		gScopedCmds[name] = cmds
+	}
+}
+
+// This function reports whether the given path matches the given scope
+// pattern.
+func matchScope(scope, dir string) bool {
+	pats := strings.Split(filepath.ToSlash(filepath.Clean(replaceTilde(scope))), "/")
+	names := strings.Split(filepath.ToSlash(filepath.Clean(dir)), "/")
+
+	var match func(pats, names []string) bool
+	match = func(pats, names []string) bool {
+		for len(pats) != 0 {
+			if pats[0] == "**" {
+				for i := range len(names) + 1 {
+					if match(pats[1:], names[i:]) {
+						return true
+					}
+				}
+				return false
+			}
+			if len(names) == 0 {
+				return false
+			}
+			if ok, err := path.Match(pats[0], names[0]); err != nil || !ok {
+				return false
+			}
+			pats, names = pats[1:], names[1:]
+		}
+		return len(names) == 0
+	}
+
+	return match(pats, names)
+}
+
+// This function returns the scoped command with the given name that is active
+// in the current directory.
+func findScopedCmd(name string) (expr, bool) {
+	cmds, ok := gScopedCmds[name]
+	if !ok {
+		return nil, false
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, false
+	}
+	for i := len(cmds) - 1; i >= 0; i-- {
+		if matchScope(cmds[i].scope, wd) {
+			return cmds[i].expr, true
+		}
+	}
+	return nil, false
+}
+
+// This function returns the user defined command with the given name, which is
+// the active scoped command when there is one, or the global command otherwise.
+func findCmd(name string) (expr, bool) {
+	if cmd, ok := findScopedCmd(name); ok {
+		return cmd, true
+	}
+	cmd, ok := gOpts.cmds[name]
+	return cmd, ok
+}
+
+// This function returns the user defined commands in the current directory,
+// where active scoped commands replace global commands with the same name.
+func activeCmds() map[string]expr {
+	cmds := make(map[string]expr, len(gOpts.cmds))
+	maps.Copy(cmds, gOpts.cmds)
+	for name := range gScopedCmds {
+		if cmd, ok := findScopedCmd(name); ok {
+			cmds[name] = cmd
+		}
+	}
+	return cmds
+}
+
+// This function reports whether the given expression can be run in the
+// current directory, which is not the case when it calls a scoped command that
+// is not active and there is no other command with the same name.
+func isCmdActive(e expr) bool {
+	call, ok := e.(*callExpr)
+	if !ok {
+		return true
+	}
+	if _, ok := gScopedCmds[call.name]; !ok {
+		return true
+	}
+	if _, ok := findCmd(call.name); ok {
+		return true
+	}
+	for _, w := range gCmdWords {
+		if w == call.name {
+			return true
+		}
+	}
+	return false
+}
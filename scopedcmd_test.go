@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		dir   string
+		exp   bool
+	}{
+		{"/work/**", "/work", true},
+		{"/work/**", "/work/foo/bar", true},
+		{"/work/**", "/workspace", false},
+		{"/work/*", "/work/foo", true},
+		{"/work/*", "/work/foo/bar", false},
+		{"/work/*", "/work", false},
+		{"/work", "/work", true},
+		{"/work/", "/work", true},
+		{"/work/**/src", "/work/src", true},
+		{"/work/**/src", "/work/foo/bar/src", true},
+		{"/work/**/src", "/work/foo/src/bar", false},
+		{"/work/**/src/**", "/work/foo/src/bar", true},
+		{"/**/.git", "/work/foo/.git", true},
+		{"/work/proj-?", "/work/proj-1", true},
+		{"/work/[", "/work/[", false},
+	}
+
+	for _, test := range tests {
+		if got := matchScope(test.scope, test.dir); got != test.exp {
+			t.Errorf("at scope '%s' and directory '%s' expected '%t' but got '%t'", test.scope, test.dir, test.exp, got)
+		}
+	}
+}
+
+func TestFindCmd(t *testing.T) {
+	oldCmds, oldScoped := gOpts.cmds, gScopedCmds
+	defer func() { gOpts.cmds, gScopedCmds = oldCmds, oldScoped }()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	global := &execExpr{"$", "global"}
+	inside := &execExpr{"$", "inside"}
+	outside := &execExpr{"$", "outside"}
+
+	gOpts.cmds = map[string]expr{"deploy": global}
+	gScopedCmds = make(map[string][]scopedCmd)
+
+	setScopedCmd("deploy", dir+"/**", inside)
+	setScopedCmd("deploy", "/nonexistent/**", outside)
+	setScopedCmd("build", "/nonexistent/**", outside)
+
+	if cmd, _ := findCmd("deploy"); cmd != inside {
+		t.Errorf("expected '%s' but got '%s'", inside, cmd)
+	}
+	if _, ok := findCmd("build"); ok {
+		t.Errorf("expected 'build' to be inactive")
+	}
+	if isCmdActive(&callExpr{"build", nil, 1}) {
+		t.Errorf("expected a call of 'build' to be inactive")
+	}
+	if cmds := activeCmds(); cmds["deploy"] != inside || len(cmds) != 1 {
+		t.Errorf("expected only the scoped 'deploy' but got '%v'", cmds)
+	}
+
+	setScopedCmd("deploy", dir+"/**", nil)
+	if cmd, _ := findCmd("deploy"); cmd != global {
+		t.Errorf("expected '%s' but got '%s'", global, cmd)
+	}
+
+	setScopedCmd("deploy", "/nonexistent/**", nil)
+	if _, ok := gScopedCmds["deploy"]; ok {
+		t.Errorf("expected 'deploy' scopes to be removed")
+	}
+}
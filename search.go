@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// The 'search-name' and 'search-content' commands search the tree of the
+// current directory in the background for files with names matching a glob
+// pattern or with lines matching a regular expression. The tree is walked in
+// a goroutine and files are searched for lines by a pool of workers, while the
+// results are streamed into a pager with a line for each match, which is
+// updated periodically until the search is finished. The cursor of the pager
+// is moved like in a directory, and the file of a result is selected on
+// enter, which also closes the pager. The last results can be shown again
+// with 'search-results'. Hidden files are skipped unless the 'hidden' option
+// is set, and files ignored by git are skipped with the 'searchgitignore'
+// option, using the '.gitignore' files in the tree and its parents up to the
+// top of the repository. Symbolic links to directories are not followed,
+// other filesystems are skipped when the 'onefilesystem' option is enabled,
+// and binary files are not searched for lines.
+
+const (
+	gSearchMaxResults = 10000
+	gSearchMaxLine    = 1024 * 1024
+	gSearchLineWidth  = 512
+	gSearchUpdate     = 200 * time.Millisecond
+)
+
+type searchResults struct {
+	title   string
+	mutex   sync.Mutex
+	lines   []string
+	paths   []string
+	done    bool
+	stopped atomic.Bool
+	changed atomic.Bool
+}
+
+// This function adds the given result lines with the paths of their files.
+// It reports false when the maximum number of results is reached.
+func (res *searchResults) add(lines, paths []string) bool {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	n := min(len(lines), gSearchMaxResults-len(res.lines))
+	res.lines = append(res.lines, lines[:n]...)
+	res.paths = append(res.paths, paths[:n]...)
+	res.changed.Store(true)
+
+	return len(res.lines) < gSearchMaxResults
+}
+
+func (res *searchResults) finish() {
+	res.mutex.Lock()
+	res.done = true
+	res.mutex.Unlock()
+	res.changed.Store(true)
+}
+
+// This function returns the title of the pager with the status of the search
+// and the results found so far.
+func (res *searchResults) get() (string, []string, []string) {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	status := "searching"
+	switch {
+	case len(res.lines) == gSearchMaxResults:
+		status = "stopped at"
+	case res.done:
+		status = "finished"
+	}
+	title := fmt.Sprintf("%s (%s %d results)", res.title, status, len(res.lines))
+
+	return title, slices.Clip(res.lines), slices.Clip(res.paths)
+}
+
+type ignoreRule struct {
+	base     string // directory of the '.gitignore' file
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// This function parses the rules of the given '.gitignore' file in the given
+// directory. Patterns with a slash other than a trailing one are matched
+// relative to the directory, and the others are matched with names at any
+// depth.
+func parseGitignore(data []byte, base string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		r := ignoreRule{base: base}
+		if line[0] == '!' {
+			r.negate = true
+			line = line[1:]
+		} else if line[0] == '\\' {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		r.pattern = line
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// This function reports whether the given pattern segments match the given
+// path segments, where a '**' segment matches any number of segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			for i := range len(name) + 1 {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func (r ignoreRule) match(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, filepath.Base(p))
+		return ok
+	}
+	rel, err := filepath.Rel(r.base, p)
+	if err != nil {
+		return false
+	}
+	return matchSegments(strings.Split(r.pattern, "/"), strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+// This function reports whether the given path is ignored by the given rules,
+// where the last matching rule decides.
+func isIgnored(rules []ignoreRule, p string, isDir bool) bool {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].match(p, isDir) {
+			return !rules[i].negate
+		}
+	}
+	return false
+}
+
+// This function returns the rules of the given directory, which are appended
+// to the given rules of its parent.
+func readGitignore(dir string, rules []ignoreRule) []ignoreRule {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return rules
+	}
+	return append(slices.Clip(rules), parseGitignore(data, dir)...)
+}
+
+// This function returns the rules of the '.gitignore' files in the parents of
+// the given directory up to the top of its repository, or nil when it is not
+// in a repository.
+func parentGitignores(dir string) []ignoreRule {
+	var parents []string
+	for p := dir; ; {
+		if _, err := os.Lstat(filepath.Join(p, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return nil
+		}
+		p = parent
+		parents = append(parents, p)
+	}
+
+	var rules []ignoreRule
+	for _, p := range slices.Backward(parents) {
+		rules = readGitignore(p, rules)
+	}
+	return rules
+}
+
+type searchWalker struct {
+	root          string
+	hidden        bool
+	hiddenfiles   []string
+	gitignore     bool
+	onefilesystem bool
+}
+
+func newSearchWalker(root string) *searchWalker {
+	return &searchWalker{
+		root:          root,
+		hidden:        gOpts.hidden,
+		hiddenfiles:   slices.Clone(gOpts.hiddenfiles),
+		gitignore:     gOpts.searchgitignore,
+		onefilesystem: gOpts.onefilesystem,
+	}
+}
+
+// This function walks the tree and calls the given function for each entry
+// which is not hidden or ignored, until the search is stopped or the function
+// returns false.
+func (w *searchWalker) walk(res *searchResults, fn func(path string, d fs.DirEntry) bool) {
+	rules := make(map[string][]ignoreRule)
+	if w.gitignore {
+		rules[w.root] = readGitignore(w.root, parentGitignores(w.root))
+	}
+
+	var dev uint64
+	checkDev := false
+	if w.onefilesystem {
+		if root, err := os.Lstat(w.root); err == nil {
+			dev, checkDev = deviceID(root)
+		}
+	}
+
+	err := filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if res.stopped.Load() {
+			return filepath.SkipAll
+		}
+		if err != nil {
+			log.Printf("search: %s", err)
+			if d != nil && d.IsDir() && path != w.root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == w.root {
+			return nil
+		}
+
+		skip := func() error {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if checkDev && d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				if id, ok := deviceID(info); ok && id != dev {
+					return filepath.SkipDir
+				}
+			}
+		}
+
+		if !w.hidden {
+			if info, err := d.Info(); err == nil && isHidden(info, filepath.Dir(path), w.hiddenfiles) {
+				return skip()
+			}
+		}
+
+		if w.gitignore {
+			parent := rules[filepath.Dir(path)]
+			if d.Name() == ".git" || isIgnored(parent, path, d.IsDir()) {
+				return skip()
+			}
+			if d.IsDir() {
+				rules[path] = readGitignore(path, parent)
+			}
+		}
+
+		if !fn(path, d) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("search: %s", err)
+	}
+}
+
+// This function returns the lines of the given file matching the given
+// regular expression in the format of 'grep -n' with the given name. Binary
+// files with a null byte at the beginning are skipped.
+func grepFile(path, name string, re *regexp.Regexp) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	if head, _ := r.Peek(8000); bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+
+	var lines []string
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, gSearchMaxLine)
+	for n := 1; s.Scan(); n++ {
+		if re.Match(s.Bytes()) {
+			lines = append(lines, fmt.Sprintf("%s:%d: %s", name, n, printableLine(s.Text())))
+		}
+	}
+
+	return lines, s.Err()
+}
+
+// This function returns the given line without control characters (e.g.
+// escape sequences), truncated to be shown in the pager.
+func printableLine(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > gSearchLineWidth {
+		s = strings.ToValidUTF8(s[:gSearchLineWidth], "")
+	}
+	return s
+}
+
+// This function returns the given pattern for a regular expression matching
+// case insensitively with the 'ignorecase' and 'smartcase' options as in
+// searches.
+func searchRegexp(pattern string) (*regexp.Regexp, error) {
+	if gOpts.ignorecase && (!gOpts.smartcase || strings.ToLower(pattern) == pattern) {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// This function searches the names of the entries in the tree of the walker
+// with the given glob pattern.
+func searchNames(w *searchWalker, res *searchResults, pattern string) {
+	w.walk(res, func(path string, d fs.DirEntry) bool {
+		if ok, _ := searchMatch(d.Name(), pattern, true); !ok {
+			return true
+		}
+		rel, _ := filepath.Rel(w.root, path)
+		if d.IsDir() {
+			rel += string(filepath.Separator)
+		}
+		return res.add([]string{rel}, []string{path})
+	})
+}
+
+// This function searches the lines of the regular files in the tree of the
+// walker with the given regular expression, using a pool of workers.
+func searchContents(w *searchWalker, res *searchResults, re *regexp.Regexp) {
+	paths := make(chan string)
+
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if res.stopped.Load() {
+					continue
+				}
+				rel, _ := filepath.Rel(w.root, path)
+				lines, err := grepFile(path, rel, re)
+				if err != nil {
+					log.Printf("search: %s", err)
+				}
+				if len(lines) > 0 && !res.add(lines, slices.Repeat([]string{path}, len(lines))) {
+					res.stopped.Store(true)
+				}
+			}
+		}()
+	}
+
+	w.walk(res, func(path string, d fs.DirEntry) bool {
+		if d.Type().IsRegular() {
+			paths <- path
+		}
+		return true
+	})
+	close(paths)
+	wg.Wait()
+}
+
+// This function starts the given search of the current directory in the
+// background, stopping the previous one, and shows its results in the pager.
+func (app *app) startSearch(title string, search func(*searchWalker, *searchResults)) {
+	app.stopSearch()
+
+	w := newSearchWalker(app.nav.currDir().path)
+	res := &searchResults{title: title}
+	app.searchRes = res
+	app.searchPager = &pager{link: -1}
+	app.showSearch()
+
+	go func() {
+		search(w, res)
+		res.finish()
+	}()
+
+	// results are shown periodically instead of after each match, as files
+	// with many matches would otherwise flood the interface with updates
+	go func() {
+		ticker := time.NewTicker(gSearchUpdate)
+		defer ticker.Stop()
+		for range ticker.C {
+			if res.changed.Swap(false) {
+				app.ui.exprChan <- &callExpr{"search-update", nil, 1}
+			}
+			res.mutex.Lock()
+			done := res.done
+			res.mutex.Unlock()
+			if done && !res.changed.Load() {
+				return
+			}
+		}
+	}()
+}
+
+func (app *app) stopSearch() {
+	if app.searchRes != nil {
+		app.searchRes.stopped.Store(true)
+	}
+}
+
+// This function updates the results in the pager when it is shown.
+func (app *app) updateSearch() {
+	p := app.searchPager
+	if p == nil || app.ui.pager != p {
+		return
+	}
+
+	p.title, p.lines, p.paths = app.searchRes.get()
+
+	// paths are kept non-nil to show the cursor before the first result
+	if p.paths == nil {
+		p.paths = []string{}
+	}
+}
+
+// This function shows the results of the last search again.
+func (app *app) showSearch() {
+	if app.searchPager == nil {
+		app.ui.echoerr("search-results: no search yet")
+		return
+	}
+	app.ui.pager = app.searchPager
+	app.updateSearch()
+}
+
+func (app *app) searchName(args []string) {
+	if len(args) == 0 {
+		app.ui.echoerr("search-name: usage: search-name <glob>")
+		return
+	}
+
+	pattern := strings.Join(args, " ")
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		app.ui.echoerrf("search-name: %s", err)
+		return
+	}
+
+	app.startSearch("search-name: "+pattern, func(w *searchWalker, res *searchResults) {
+		searchNames(w, res, pattern)
+	})
+}
+
+func (app *app) searchContent(args []string) {
+	if len(args) == 0 {
+		app.ui.echoerr("search-content: usage: search-content <regexp>")
+		return
+	}
+
+	pattern := strings.Join(args, " ")
+	re, err := searchRegexp(pattern)
+	if err != nil {
+		app.ui.echoerrf("search-content: %s", err)
+		return
+	}
+
+	app.startSearch("search-content: "+pattern, func(w *searchWalker, res *searchResults) {
+		searchContents(w, res, re)
+	})
+}
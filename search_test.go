@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestIsIgnored(t *testing.T) {
+	base := filepath.FromSlash("/repo")
+	rules := parseGitignore([]byte("# comment\n*.log\n!keep.log\nbuild/\n/top\ndocs/**/*.tmp\n\\#hash\n"), base)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		exp   bool
+	}{
+		{"/repo/a.log", false, true},
+		{"/repo/sub/b.log", false, true},
+		{"/repo/sub/keep.log", false, false},
+		{"/repo/build", true, true},
+		{"/repo/sub/build", true, true},
+		{"/repo/build", false, false},
+		{"/repo/top", false, true},
+		{"/repo/sub/top", false, false},
+		{"/repo/docs/a.tmp", false, true},
+		{"/repo/docs/x/y/a.tmp", false, true},
+		{"/repo/src/docs/a.tmp", false, false},
+		{"/repo/#hash", false, true},
+		{"/repo/main.go", false, false},
+	}
+
+	for _, test := range tests {
+		if got := isIgnored(rules, filepath.FromSlash(test.path), test.isDir); got != test.exp {
+			t.Errorf("at input '%s' expected '%t' but got '%t'", test.path, test.exp, got)
+		}
+	}
+}
+
+func TestPrintableLine(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+	}{
+		{"foo", "foo"},
+		{"\tfoo\r", " foo"},
+		{"\033[31mred\033[0m", "[31mred[0m"},
+	}
+
+	for _, test := range tests {
+		if got := printableLine(test.s); got != test.exp {
+			t.Errorf("at input '%q' expected '%q' but got '%q'", test.s, test.exp, got)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	gOpts.ignorecase = true
+	gOpts.smartcase = true
+
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":      "*.out\nvendor/\n",
+		"main.go":         "package main\n\nfunc main() {}\n",
+		"sub/util.go":     "package sub\n// Main helper\n",
+		"sub/data.out":    "func main\n",
+		"vendor/lib.go":   "func main() {}\n",
+		".hidden/main.go": "func main() {}\n",
+		"binary.bin":      "func main\x00\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &searchWalker{root: root, hiddenfiles: []string{".*"}, gitignore: true}
+
+	res := &searchResults{}
+	searchContents(w, res, regexp.MustCompile("(?i)main"))
+	slices.Sort(res.lines)
+	exp := []string{
+		"main.go:1: package main",
+		"main.go:3: func main() {}",
+		filepath.FromSlash("sub/util.go") + ":2: // Main helper",
+	}
+	if !slices.Equal(res.lines, exp) {
+		t.Errorf("expected content results '%v' but got '%v'", exp, res.lines)
+	}
+
+	res = &searchResults{}
+	searchNames(w, res, "*.go")
+	slices.Sort(res.lines)
+	exp = []string{"main.go", filepath.FromSlash("sub/util.go")}
+	if !slices.Equal(res.lines, exp) {
+		t.Errorf("expected name results '%v' but got '%v'", exp, res.lines)
+	}
+
+	w.gitignore = false
+	res = &searchResults{}
+	searchNames(w, res, "*")
+	if !slices.Contains(res.lines, filepath.FromSlash("vendor/lib.go")) || slices.Contains(res.lines, ".gitignore") {
+		t.Errorf("expected ignored files but no hidden files in '%v'", res.lines)
+	}
+	if i := slices.Index(res.lines, "sub"+string(filepath.Separator)); i < 0 || res.paths[i] != filepath.Join(root, "sub") {
+		t.Errorf("expected directory 'sub' with its path in '%v'", res.lines)
+	}
+}
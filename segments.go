@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prompt segments are values shown in the prompt line with '%{name}' in the
+// 'promptfmt' option, which are the outputs of shell commands defined with the
+// 'prompt-segment' command (e.g. the git branch of the current directory).
+// Commands are run in the background in the current directory, so that slow
+// commands never block drawing, and their outputs are cached for each
+// directory. Outputs are refreshed when the cached value is older than the
+// interval of the segment, which is checked after changing directories and
+// periodically. Commands running longer than the timeout of the segment are
+// killed, and the last value is kept when a command fails.
+
+const (
+	gSegmentInterval = 10 * time.Second
+	gSegmentTimeout  = 2 * time.Second
+	gSegmentCacheMax = 256
+)
+
+var reSegment = regexp.MustCompile(`%\{[^}]*\}`)
+
+type promptSegment struct {
+	name     string
+	cmd      string
+	interval time.Duration
+	timeout  time.Duration
+}
+
+type segmentValue struct {
+	text    string
+	time    time.Time
+	running bool
+}
+
+type segmentResult struct {
+	name string
+	dir  string
+	text string
+	err  error
+}
+
+type promptSegments struct {
+	defs  []*promptSegment
+	cache map[string]*segmentValue
+}
+
+func segmentKey(name, dir string) string {
+	return name + "\x00" + dir
+}
+
+// This function returns the first line of the output of the given segment,
+// which is run in the given directory.
+func runSegment(seg *promptSegment, dir string) (string, error) {
+	var out bytes.Buffer
+
+	cmd := shellCommand(seg.cmd, nil)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.WaitDelay = seg.timeout
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	timer := time.AfterFunc(seg.timeout, func() { cmd.Process.Kill() })
+	err := cmd.Wait()
+	if !timer.Stop() {
+		return "", fmt.Errorf("timed out after %s", seg.timeout)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	text, _, _ := strings.Cut(out.String(), "\n")
+	return strings.TrimRight(text, "\r"), nil
+}
+
+// This function defines a segment, or removes it when the command is empty.
+func (s *promptSegments) define(seg *promptSegment) {
+	for i, d := range s.defs {
+		if d.name == seg.name {
+			s.defs = append(s.defs[:i], s.defs[i+1:]...)
+			break
+		}
+	}
+	for key := range s.cache {
+		if strings.HasPrefix(key, seg.name+"\x00") {
+			delete(s.cache, key)
+		}
+	}
+	if seg.cmd != "" {
+		s.defs = append(s.defs, seg)
+	}
+}
+
+// This function returns the cached value of the given segment in the given
+// directory.
+func (s *promptSegments) value(name, dir string) string {
+	if v, ok := s.cache[segmentKey(name, dir)]; ok {
+		return v.text
+	}
+	return ""
+}
+
+// This function replaces the segments in the given prompt with their cached
+// values in the given directory. Segments which are not defined are removed.
+func (s *promptSegments) expand(prompt, dir string) string {
+	return reSegment.ReplaceAllStringFunc(prompt, func(m string) string {
+		return s.value(m[2:len(m)-1], dir)
+	})
+}
+
+// This function starts the commands of the segments whose cached values in
+// the given directory are missing or older than their intervals.
+func (s *promptSegments) refresh(dir string, ch chan<- segmentResult) {
+	if len(s.defs) == 0 {
+		return
+	}
+
+	if s.cache == nil {
+		s.cache = make(map[string]*segmentValue)
+	}
+
+	now := time.Now()
+	for _, seg := range s.defs {
+		key := segmentKey(seg.name, dir)
+		v, ok := s.cache[key]
+		if !ok {
+			v = &segmentValue{}
+			s.cache[key] = v
+		}
+		if v.running || (!v.time.IsZero() && now.Sub(v.time) < seg.interval) {
+			continue
+		}
+
+		v.running = true
+		go func(seg *promptSegment) {
+			text, err := runSegment(seg, dir)
+			ch <- segmentResult{seg.name, dir, text, err}
+		}(seg)
+	}
+
+	// values of other directories are dropped when they are too old to be
+	// shown again without refreshing them first
+	if len(s.cache) > gSegmentCacheMax {
+		for key, v := range s.cache {
+			if !v.running && now.Sub(v.time) > gSegmentInterval {
+				delete(s.cache, key)
+			}
+		}
+	}
+}
+
+// This function stores the output of a segment, and reports whether it
+// changed.
+func (s *promptSegments) update(r segmentResult) bool {
+	v, ok := s.cache[segmentKey(r.name, r.dir)]
+	if !ok {
+		return false
+	}
+
+	v.running = false
+	v.time = time.Now()
+	if r.err != nil {
+		log.Printf("prompt-segment %s: %s", r.name, r.err)
+		return false
+	}
+
+	changed := v.text != r.text
+	v.text = r.text
+	return changed
+}
+
+// This function parses the arguments of the 'prompt-segment' command, which
+// are optional flags followed by the name and the command of the segment.
+func parseSegment(args []string) (*promptSegment, error) {
+	seg := &promptSegment{interval: gSegmentInterval, timeout: gSegmentTimeout}
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s: requires a value", args[0])
+		}
+		secs, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || secs <= 0 {
+			return nil, fmt.Errorf("%s: invalid number of seconds: %s", args[0], args[1])
+		}
+		d := time.Duration(secs * float64(time.Second))
+		switch args[0] {
+		case "--interval":
+			seg.interval = d
+		case "--timeout":
+			seg.timeout = d
+		default:
+			return nil, fmt.Errorf("unknown flag: %s", args[0])
+		}
+		args = args[2:]
+	}
+
+	if len(args) == 0 || len(args) > 2 {
+		return nil, errors.New("requires a name and an optional command")
+	}
+	if args[0] == "" || strings.ContainsAny(args[0], "{}") {
+		return nil, fmt.Errorf("invalid name: %q", args[0])
+	}
+
+	seg.name = args[0]
+	if len(args) == 2 {
+		seg.cmd = args[1]
+	}
+
+	return seg, nil
+}
+
+func (app *app) promptSegment(args []string) {
+	seg, err := parseSegment(args)
+	if err != nil {
+		app.ui.echoerrf("prompt-segment: %s", err)
+		return
+	}
+
+	app.nav.segments.define(seg)
+	if len(app.nav.segments.defs) > 0 && app.segmentTicker.C == nil {
+		app.segmentTicker = time.NewTicker(time.Second)
+	}
+
+	app.refreshSegments()
+}
+
+func (app *app) refreshSegments() {
+	if !app.nav.init {
+		return
+	}
+	app.nav.segments.refresh(app.nav.currDir().path, app.nav.segmentChan)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseSegment(t *testing.T) {
+	tests := []struct {
+		args     []string
+		name     string
+		cmd      string
+		interval time.Duration
+		timeout  time.Duration
+		err      bool
+	}{
+		{[]string{"git", "git branch --show-current"}, "git", "git branch --show-current", gSegmentInterval, gSegmentTimeout, false},
+		{[]string{"--interval", "60", "--timeout", "0.5", "du", "du -sh ."}, "du", "du -sh .", time.Minute, 500 * time.Millisecond, false},
+		{[]string{"git"}, "git", "", gSegmentInterval, gSegmentTimeout, false},
+		{[]string{"--interval", "0", "git", "true"}, "", "", 0, 0, true},
+		{[]string{"--interval"}, "", "", 0, 0, true},
+		{[]string{"--foo", "1", "git", "true"}, "", "", 0, 0, true},
+		{[]string{"a}b", "true"}, "", "", 0, 0, true},
+		{[]string{}, "", "", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		seg, err := parseSegment(test.args)
+		if test.err {
+			if err == nil {
+				t.Errorf("at input '%q' expected an error", test.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("at input '%q' got error '%s'", test.args, err)
+			continue
+		}
+		if seg.name != test.name || seg.cmd != test.cmd || seg.interval != test.interval || seg.timeout != test.timeout {
+			t.Errorf("at input '%q' got unexpected segment '%+v'", test.args, seg)
+		}
+	}
+}
+
+func TestPromptSegments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("segments are tested with a POSIX shell")
+	}
+
+	var s promptSegments
+	ch := make(chan segmentResult, 10)
+	dir := t.TempDir()
+
+	s.define(&promptSegment{"pwd", "pwd; echo foo", time.Hour, time.Second})
+	s.define(&promptSegment{"slow", "sleep 5", time.Hour, 50 * time.Millisecond})
+
+	s.refresh(dir, ch)
+	s.refresh(dir, ch)
+
+	for range 2 {
+		r := <-ch
+		changed := s.update(r)
+		switch r.name {
+		case "pwd":
+			if !changed || r.err != nil {
+				t.Errorf("expected segment to change but got error '%v'", r.err)
+			}
+		case "slow":
+			if changed || r.err == nil {
+				t.Errorf("expected segment to time out")
+			}
+		}
+	}
+
+	if got := s.expand("[%{pwd}|%{slow}|%{none}]", dir); got != "["+dir+"||]" {
+		t.Errorf("unexpected prompt '%s'", got)
+	}
+
+	// cached values are not refreshed before their intervals
+	s.refresh(dir, ch)
+	select {
+	case r := <-ch:
+		t.Errorf("expected cached value to be used but got '%+v'", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	s.define(&promptSegment{name: "pwd"})
+	if got := s.expand("[%{pwd}]", dir); got != "[]" {
+		t.Errorf("expected removed segment to be empty but got '%s'", got)
+	}
+}
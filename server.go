@@ -2,11 +2,16 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"path"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var (
@@ -68,6 +73,112 @@ func echoerrf(c net.Conn, format string, a ...any) {
 	echoerr(c, fmt.Sprintf(format, a...))
 }
 
+// This function sends a query to the given client and returns the lines of
+// the response, which is terminated with an empty line.
+func queryConn(c net.Conn, query string) []string {
+	fmt.Fprintln(c, "query "+query)
+	var lines []string
+	s := bufio.NewScanner(c)
+	for s.Scan() && s.Text() != "" {
+		lines = append(lines, s.Text())
+	}
+	return lines
+}
+
+// This function returns the allowed command patterns for remote clients, which
+// are given one per line with '#' to start comments. Nil is returned when
+// there is no allowlist.
+func readRemoteAllowlist() ([]string, error) {
+	f, err := os.Open(gRemotePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := []string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+
+	return patterns, s.Err()
+}
+
+// This function returns the names of the commands in the given expression,
+// where shell commands are named with their prefix (e.g. '$').
+func exprNames(e expr) []string {
+	switch e := e.(type) {
+	case *setExpr:
+		return []string{"set"}
+	case *setLocalExpr:
+		return []string{"setlocal"}
+	case *mapExpr:
+		return []string{"map"}
+	case *nmapExpr:
+		return []string{"nmap"}
+	case *vmapExpr:
+		return []string{"vmap"}
+	case *cmapExpr:
+		return []string{"cmap"}
+	case *cmdExpr:
+		return []string{"cmd"}
+	case *callExpr:
+		return []string{e.name}
+	case *execExpr:
+		return []string{e.prefix}
+	case *listExpr:
+		var names []string
+		for _, e := range e.exprs {
+			names = append(names, exprNames(e)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// This function checks the commands sent to clients by a remote client
+// against the allowlist of the server, which can have glob patterns (e.g.
+// 'cmd-*'). All commands are allowed when there is no allowlist.
+func checkRemote(cmd string) error {
+	patterns, err := readRemoteAllowlist()
+	if err != nil {
+		return fmt.Errorf("reading allowlist: %s", err)
+	}
+	if patterns == nil {
+		return nil
+	}
+
+	p := newParser(strings.NewReader(cmd))
+	for p.parse() {
+		for _, name := range exprNames(p.expr) {
+			if !slices.ContainsFunc(patterns, func(pattern string) bool {
+				matched, _ := path.Match(pattern, name)
+				return matched
+			}) {
+				return fmt.Errorf("command not allowed: %s", name)
+			}
+		}
+	}
+
+	return p.err
+}
+
+// This function checks the commands of a session against the allowlist.
+func checkSession(cmds []string) error {
+	for _, cmd := range cmds {
+		if err := checkRemote(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func handleConn(c net.Conn) {
 	s := bufio.NewScanner(c)
 
@@ -86,6 +197,10 @@ Loop:
 					// lifetime of the connection is managed by the server and
 					// will be cleaned up via the `drop` command
 					gConnList[id] = c
+					for _, cmd := range gOpenHistory.cmds() {
+						fmt.Fprintln(c, cmd)
+					}
+					fmt.Fprintln(c, gClientDirs.cmd(id))
 					return
 				}
 			} else {
@@ -102,6 +217,8 @@ Loop:
 						c2.Close()
 					}
 					delete(gConnList, id)
+					gClientDirs.remove(id)
+					broadcastPeerDirs()
 				}
 			} else {
 				echoerr(c, "listen: drop: requires a client id")
@@ -110,9 +227,17 @@ Loop:
 			if rest != "" {
 				word2, rest2 := splitWord(rest)
 				id, err := strconv.Atoi(word2)
-				if err != nil {
+				broadcast := err != nil
+				if broadcast {
+					rest2 = rest
+				}
+				if err := checkRemote(rest2); err != nil {
+					echoerrf(c, "listen: send: %s", err)
+					break
+				}
+				if broadcast {
 					for _, c := range gConnList {
-						fmt.Fprintln(c, rest)
+						fmt.Fprintln(c, rest2)
 					}
 				} else {
 					if c2, ok := gConnList[id]; ok {
@@ -138,13 +263,136 @@ Loop:
 				echoerr(c, "listen: query: no such client id is connected")
 				break
 			}
-			fmt.Fprintln(c2, "query "+rest2)
-			s2 := bufio.NewScanner(c2)
-			for s2.Scan() && s2.Text() != "" {
-				fmt.Fprintln(c, s2.Text())
+			for _, line := range queryConn(c2, rest2) {
+				fmt.Fprintln(c, line)
+			}
+		case "sync-cwd":
+			if rest == "" {
+				echoerr(c, "listen: sync-cwd: requires a client id")
+				break
+			}
+			word2, rest2 := splitWord(rest)
+			id, err := strconv.Atoi(word2)
+			if err != nil {
+				echoerr(c, "listen: sync-cwd: client id should be a number")
+				break
+			}
+			c2, ok := gConnList[id]
+			if !ok {
+				echoerr(c, "listen: sync-cwd: no such client id is connected")
+				break
+			}
+			lines := queryConn(c2, "dir")
+			if len(lines) == 0 {
+				echoerr(c, "listen: sync-cwd: current directory is not known")
+				break
+			}
+			cmd := "cd " + escape(lines[0])
+			if rest2 == "" {
+				for id3, c3 := range gConnList {
+					if id3 != id {
+						fmt.Fprintln(c3, cmd)
+					}
+				}
+				break
+			}
+			word3, _ := splitWord(rest2)
+			id3, err := strconv.Atoi(word3)
+			if err != nil {
+				echoerr(c, "listen: sync-cwd: client id should be a number")
+				break
+			}
+			if c3, ok := gConnList[id3]; ok {
+				fmt.Fprintln(c3, cmd)
+			} else {
+				echoerr(c, "listen: sync-cwd: no such client id is connected")
+			}
+		case "cwd":
+			word2, rest2 := splitWord(rest)
+			id, err := strconv.Atoi(word2)
+			if err != nil {
+				echoerr(c, "listen: cwd: client id should be a number")
+				break
+			}
+			if rest2 == "" {
+				echoerr(c, "listen: cwd: requires a path")
+				break
+			}
+			gClientDirs.set(id, rest2)
+			broadcastPeerDirs()
+		case "record-open":
+			if rest == "" {
+				echoerr(c, "listen: record-open: requires a path")
+				break
+			}
+			cmd := openHistoryCmd(rest, gOpenHistory.add(rest, time.Now()))
+			for _, c2 := range gConnList {
+				fmt.Fprintln(c2, cmd)
+			}
+		case "detach":
+			if rest == "" {
+				echoerr(c, "listen: detach: requires a session name")
+				break
+			}
+			name, _ := splitWord(rest)
+			var cmds []string
+			for s.Scan() && s.Text() != "" {
+				cmds = append(cmds, s.Text())
+			}
+			// sessions are run on attaching clients like commands sent with
+			// 'send', so they are checked against the allowlist as well
+			if err := checkSession(cmds); err != nil {
+				echoerrf(c, "listen: detach: %s", err)
+				break
+			}
+			gSessions.save(name, cmds)
+		case "attach":
+			if rest == "" {
+				echoerr(c, "listen: attach: requires a session name")
+				break
+			}
+			name, _ := splitWord(rest)
+			cmds, ok := gSessions.take(name)
+			if !ok {
+				msg := "no such session: " + name
+				log.Printf("listen: attach: %s", msg)
+				fmt.Fprintln(c, "echoerr "+escape("attach: "+msg))
+				break
+			}
+			// the allowlist may be changed after the session is saved
+			if err := checkSession(cmds); err != nil {
+				log.Printf("listen: attach: %s", err)
+				fmt.Fprintln(c, "echoerr "+escape("attach: "+err.Error()))
+				break
+			}
+			for _, cmd := range cmds {
+				fmt.Fprintln(c, cmd)
+			}
+		case "clipboard-push":
+			size, err := strconv.Atoi(rest)
+			if err != nil || size < 1 {
+				echoerr(c, "listen: clipboard-push: size should be a positive number")
+				break
+			}
+			if !s.Scan() {
+				break
+			}
+			e, err := readClipboardEntry(s.Text(), s)
+			if err != nil {
+				echoerrf(c, "listen: clipboard-push: %s", err)
+				break
+			}
+			gClipboard.push(e, size)
+		case "clipboard":
+			for _, e := range gClipboard.list() {
+				writeClipboardEntry(c, e)
+			}
+		case "sessions":
+			for _, name := range gSessions.names() {
+				fmt.Fprintln(c, name)
 			}
 		case "quit":
-			if len(gConnList) == 0 {
+			if len(gConnList) == 0 && len(gSessions.names()) == 0 {
 				gQuitChan <- struct{}{}
 				gListener.Close()
 				break Loop
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// A client can be detached with the 'detach' command, which quits the client
+// and leaves its state on the server under a name, and the state can be
+// restored later with 'lf -attach-session <name>' from any terminal, similar
+// to sessions in terminal multiplexers. The state is kept on the server as a
+// list of commands to run on the attaching client, so that it is restored in
+// the same way as commands sent with 'lf -remote'. The state is the same as
+// the one saved with 'session-save', which is restored with an internal
+// 'session-attach' command.
+// Sessions are only kept in memory while the server is running, and the
+// server does not quit with the 'autoquit' option while any session is kept.
+
+type sessionStore struct {
+	mutex    sync.Mutex
+	sessions map[string][]string
+}
+
+var gSessions = sessionStore{sessions: make(map[string][]string)}
+
+func (s *sessionStore) save(name string, cmds []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sessions[name] = cmds
+}
+
+// This function returns the commands of the given session, which is removed
+// so that it can only be attached once.
+func (s *sessionStore) take(name string) ([]string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cmds, ok := s.sessions[name]
+	delete(s.sessions, name)
+	return cmds, ok
+}
+
+func (s *sessionStore) names() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Sorted(maps.Keys(s.sessions))
+}
+
+// This function returns the commands to restore the state of the client,
+// which is a single 'session-attach' command with the same state as the one
+// saved with 'session-save' (i.e. the tabs with their filters, selections,
+// jump lists and sort options, and the temporary marks). The state is encoded
+// as JSON so that paths with newlines are kept in a single line, as the
+// commands are sent to the server line by line.
+func (app *app) sessionCmds() ([]string, error) {
+	b, err := json.Marshal(app.sessionState())
+	if err != nil {
+		return nil, err
+	}
+	return []string{"session-attach " + escape(string(b))}, nil
+}
+
+// This function restores the state of a detached client sent with the
+// 'session-attach' command.
+func (app *app) sessionAttach(args []string) {
+	if len(args) != 1 {
+		app.ui.echoerr("session-attach: usage: session-attach <state>")
+		return
+	}
+
+	var s sessionState
+	if err := json.Unmarshal([]byte(args[0]), &s); err != nil {
+		app.ui.echoerrf("session-attach: %s", err)
+		return
+	}
+
+	app.restoreSession(&s, "attach")
+}
+
+func (app *app) detach(args []string) {
+	if gSingleMode {
+		app.ui.echoerr("detach: not supported in single mode")
+		return
+	}
+
+	name := "default"
+	switch len(args) {
+	case 0:
+	case 1:
+		name = args[0]
+	default:
+		app.ui.echoerr("detach: only a single session name is allowed")
+		return
+	}
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		app.ui.echoerrf("detach: invalid session name: %q", name)
+		return
+	}
+
+	if app.nav.copyTotal > 0 || app.nav.moveTotal > 0 || app.nav.deleteTotal > 0 {
+		app.ui.echoerr("detach: file operation in progress")
+		return
+	}
+
+	cmds, err := app.sessionCmds()
+	if err != nil {
+		app.ui.echoerrf("detach: %s", err)
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "detach %s\n", name)
+	for _, cmd := range cmds {
+		fmt.Fprintln(&b, cmd)
+	}
+	b.WriteString("\n")
+
+	if err := remote(b.String()); err != nil {
+		app.ui.echoerrf("detach: %s", err)
+		return
+	}
+
+	app.quitChan <- struct{}{}
+}
+
+// This function returns the commands of the given session from the server,
+// which sends an 'echoerr' command instead when there is no such session.
+func readSession(name string) ([]string, error) {
+	c, err := net.Dial(gSocketProt, gSocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("dialing to attach session: %s", err)
+	}
+	defer c.Close()
+
+	fmt.Fprintf(c, "attach %s\n", name)
+	if v, ok := c.(interface {
+		CloseWrite() error
+	}); ok {
+		v.CloseWrite()
+	}
+
+	var cmds []string
+	s := bufio.NewScanner(c)
+	for s.Scan() {
+		cmds = append(cmds, s.Text())
+	}
+	if err := s.Err(); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return cmds, nil
+}
+
+// This function restores the given session on the client by running its
+// commands.
+func (app *app) attach(name string) {
+	cmds, err := readSession(name)
+	if err != nil {
+		app.ui.exprChan <- &callExpr{"echoerr", []string{"attach: " + err.Error()}, 1}
+		return
+	}
+
+	for _, cmd := range cmds {
+		log.Printf("attach: %s", cmd)
+		p := newParser(strings.NewReader(cmd))
+		for p.parse() {
+			app.ui.exprChan <- p.expr
+		}
+	}
+}
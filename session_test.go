@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestSessions(t *testing.T) {
+	defer func(sessions map[string][]string) {
+		gSessions.sessions = sessions
+	}(gSessions.sessions)
+	gSessions.sessions = make(map[string][]string)
+
+	c1, c2 := net.Pipe()
+	go handleConn(c2)
+
+	fmt.Fprintln(c1, "detach work")
+	fmt.Fprintln(c1, "cd /tmp")
+	fmt.Fprintln(c1, "select /tmp/foo")
+	fmt.Fprintln(c1, "")
+	fmt.Fprintln(c1, "sessions")
+
+	s := bufio.NewScanner(c1)
+	if !s.Scan() || s.Text() != "work" {
+		t.Fatalf("expected session 'work' to be listed but got '%s'", s.Text())
+	}
+
+	fmt.Fprintln(c1, "attach work")
+
+	var cmds []string
+	for range 2 {
+		if !s.Scan() {
+			t.Fatalf("reading session commands: %v", s.Err())
+		}
+		cmds = append(cmds, s.Text())
+	}
+	if exp := []string{"cd /tmp", "select /tmp/foo"}; !slices.Equal(cmds, exp) {
+		t.Errorf("expected session commands %q but got %q", exp, cmds)
+	}
+
+	fmt.Fprintln(c1, "attach work")
+	if !s.Scan() || s.Text() != `echoerr attach:\ no\ such\ session:\ work` {
+		t.Errorf("expected error for attaching a session twice but got '%s'", s.Text())
+	}
+
+	c1.Close()
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// The state of a client can be saved to the session file with 'session-save'
+// and restored with 'session-load', which is also done automatically on quit
+// and on start with the 'autosavesession' option, so that restarting lf puts
+// the user back where they were. Unlike the sessions of 'detach', the file is
+// kept across restarts of the server, and it is shared between clients so the
+// last saved state wins. The state has the tabs (or the current directory
+// when there are no tabs) with their current files, filters, selections, jump
+// lists and sort options, and the temporary marks, as the other marks are
+// already kept in the marks file. It is written as a single line of JSON.
+
+type sessionTab struct {
+	Path        string   `json:"path"`
+	File        string   `json:"file,omitempty"`
+	Filter      []string `json:"filter,omitempty"`
+	Selections  []string `json:"selections,omitempty"`
+	JumpList    []string `json:"jumplist,omitempty"`
+	JumpListInd int      `json:"jumplistind"`
+	SortBy      string   `json:"sortby"`
+	DirFirst    bool     `json:"dirfirst"`
+	DirOnly     bool     `json:"dironly"`
+	Hidden      bool     `json:"hidden"`
+	Reverse     bool     `json:"reverse"`
+}
+
+type sessionState struct {
+	Tabs    []sessionTab      `json:"tabs"`
+	Current int               `json:"current"`
+	Marks   map[string]string `json:"marks,omitempty"`
+}
+
+func newSessionTab(t *tab) sessionTab {
+	selections := slices.SortedFunc(maps.Keys(t.selections), func(a, b string) int {
+		return t.selections[a] - t.selections[b]
+	})
+
+	return sessionTab{
+		Path:        t.path,
+		File:        t.file,
+		Filter:      t.filter,
+		Selections:  selections,
+		JumpList:    t.jumpList,
+		JumpListInd: t.jumpListInd,
+		SortBy:      string(t.sortby),
+		DirFirst:    t.dirfirst,
+		DirOnly:     t.dironly,
+		Hidden:      t.hidden,
+		Reverse:     t.reverse,
+	}
+}
+
+// This function returns the tab of the saved state. Selected files removed
+// since the state is saved are left out, and the sort options of the given
+// default tab are used when the saved sort method is not valid.
+func (st sessionTab) tab(def *tab) *tab {
+	t := newTab(st.Path)
+	t.file = st.File
+	t.filter = st.Filter
+	t.jumpList = st.JumpList
+	t.jumpListInd = max(-1, min(st.JumpListInd, len(st.JumpList)-1))
+
+	for _, path := range st.Selections {
+		if _, err := os.Lstat(path); err == nil {
+			t.selections[path] = t.selectionInd
+			t.selectionInd++
+		}
+	}
+
+	if isValidSortMethod(sortMethod(st.SortBy)) {
+		t.sortby = sortMethod(st.SortBy)
+		t.dirfirst = st.DirFirst
+		t.dironly = st.DirOnly
+		t.hidden = st.Hidden
+		t.reverse = st.Reverse
+	} else {
+		t.sortby = def.sortby
+		t.dirfirst = def.dirfirst
+		t.dironly = def.dironly
+		t.hidden = def.hidden
+		t.reverse = def.reverse
+	}
+
+	return t
+}
+
+// This function returns the tabs of the saved state with the index of the
+// current tab, leaving out the tabs with directories removed since the state
+// is saved. The number of left out tabs is also returned.
+func (s *sessionState) tabs(def *tab) ([]*tab, int, int) {
+	var tabs []*tab
+	ind, skipped := 0, 0
+	for i, st := range s.Tabs {
+		if stat, err := os.Stat(st.Path); err != nil || !stat.IsDir() {
+			skipped++
+			continue
+		}
+		if i == s.Current {
+			ind = len(tabs)
+		}
+		tabs = append(tabs, st.tab(def))
+	}
+	return tabs, ind, skipped
+}
+
+// This function returns the state of the client to be saved.
+func (app *app) sessionState() *sessionState {
+	tabs, ind := slices.Clone(app.nav.tabs), app.nav.tabInd
+	if len(tabs) == 0 {
+		tabs, ind = make([]*tab, 1), 0
+	}
+	tabs[ind] = app.nav.saveTab()
+
+	s := &sessionState{Current: ind, Marks: make(map[string]string)}
+	for _, t := range tabs {
+		s.Tabs = append(s.Tabs, newSessionTab(t))
+	}
+
+	for k, v := range app.nav.marks {
+		if strings.Contains(gOpts.tempmarks, k) {
+			s.Marks[k] = v
+		}
+	}
+
+	return s
+}
+
+func writeSessionFile(s *sessionState) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return withDataLock(gSessionPath, func() error {
+		if err := writeDataFile(gSessionPath, append(b, '\n')); err != nil {
+			return fmt.Errorf("writing session file: %s", err)
+		}
+		return nil
+	})
+}
+
+// This function returns the saved state, or nil when no state is saved yet.
+func readSessionFile() (*sessionState, error) {
+	var lines []string
+	err := withDataLock(gSessionPath, func() (err error) {
+		lines, err = readDataLines(gSessionPath, func(line string) bool {
+			return json.Unmarshal([]byte(line), &sessionState{}) == nil
+		})
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("session file: %s", err)
+	}
+	if len(lines) == 0 {
+		return nil, err
+	}
+
+	var s sessionState
+	json.Unmarshal([]byte(lines[len(lines)-1]), &s)
+	return &s, err
+}
+
+func (app *app) saveSession(args []string) {
+	if len(args) != 0 {
+		app.ui.echoerr("session-save: usage: session-save")
+		return
+	}
+
+	s := app.sessionState()
+	if err := writeSessionFile(s); err != nil {
+		app.ui.echoerrf("session-save: %s", err)
+		return
+	}
+
+	app.ui.echo(fmt.Sprintf("session-save: %d tabs saved", len(s.Tabs)))
+}
+
+// This function restores the saved state of the client, replacing the
+// current tabs. Nothing is reported when no state is saved yet and the state
+// is restored automatically on start.
+func (app *app) loadSession(args []string, auto bool) {
+	if len(args) != 0 {
+		app.ui.echoerr("session-load: usage: session-load")
+		return
+	}
+
+	s, err := readSessionFile()
+	if err != nil {
+		app.ui.echoerrf("session-load: %s", err)
+	}
+	if s == nil {
+		if !auto && err == nil {
+			app.ui.echoerr("session-load: no session saved yet")
+		}
+		return
+	}
+
+	app.restoreSession(s, "session-load")
+}
+
+// This function replaces the current tabs with the tabs of the given state,
+// which is also used to attach sessions kept with 'detach'. Errors are
+// reported with the given name of the command.
+func (app *app) restoreSession(s *sessionState, name string) {
+	tabs, ind, skipped := s.tabs(app.nav.saveTab())
+	if len(tabs) == 0 {
+		app.ui.echoerrf("%s: directories of the saved session do not exist anymore", name)
+		return
+	}
+
+	for k, v := range s.Marks {
+		if strings.Contains(gOpts.tempmarks, k) {
+			app.nav.marks[k] = v
+		}
+	}
+
+	for _, t := range tabs {
+		app.nav.tabSeq++
+		t.id = app.nav.tabSeq
+	}
+
+	app.nav.tabs = tabs
+	err := app.loadTab(ind, false)
+	if len(tabs) == 1 {
+		// a single tab is kept as the state of the client without a tab list
+		app.nav.tabs = nil
+		app.updateTabRow()
+	}
+	if err != nil {
+		app.ui.echoerrf("%s: %s", name, err)
+		return
+	}
+
+	if skipped > 0 {
+		app.ui.echoerrf("%s: %d tabs with removed directories are left out", name, skipped)
+	}
+}
+
+// This function saves the state of the client on quit with the
+// 'autosavesession' option.
+func (app *app) autoSaveSession() {
+	if !gOpts.autosavesession || !app.nav.init || gHeadless {
+		return
+	}
+	if err := writeSessionFile(app.sessionState()); err != nil {
+		log.Printf("session: %s", err)
+	}
+}
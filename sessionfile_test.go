@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSessionFile(t *testing.T) {
+	oldPath := gSessionPath
+	defer func() { gSessionPath = oldPath }()
+
+	dir := t.TempDir()
+	gSessionPath = filepath.Join(dir, "data", "session")
+
+	if s, err := readSessionFile(); s != nil || err != nil {
+		t.Fatalf("expected no session but got '%v' and '%v'", s, err)
+	}
+
+	src := filepath.Join(dir, "src")
+	gone := filepath.Join(dir, "gone")
+	for _, path := range []string{src, gone} {
+		if err := os.Mkdir(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kept := filepath.Join(src, "kept")
+	if err := os.WriteFile(kept, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := &tab{
+		path:        gone,
+		selections:  map[string]int{},
+		jumpListInd: -1,
+		sortby:      "name",
+	}
+	second := &tab{
+		path:         src,
+		file:         "kept",
+		filter:       []string{"*.go"},
+		selections:   map[string]int{filepath.Join(src, "removed"): 0, kept: 1},
+		selectionInd: 2,
+		jumpList:     []string{dir, src},
+		jumpListInd:  1,
+		sortby:       "time,name",
+		reverse:      true,
+	}
+
+	s := &sessionState{
+		Tabs:    []sessionTab{newSessionTab(first), newSessionTab(second)},
+		Current: 1,
+		Marks:   map[string]string{"'": dir},
+	}
+	if err := writeSessionFile(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := readSessionFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Marks["'"] != dir {
+		t.Errorf("expected mark ''' to be '%s' but got '%s'", dir, s.Marks["'"])
+	}
+
+	tabs, ind, skipped := s.tabs(newTab(dir))
+	if len(tabs) != 1 || ind != 0 || skipped != 1 {
+		t.Fatalf("expected a single tab with one left out but got %d tabs at %d with %d left out", len(tabs), ind, skipped)
+	}
+
+	got := tabs[0]
+	if got.path != src || got.file != "kept" || !slices.Equal(got.filter, second.filter) {
+		t.Errorf("expected tab in '%s' on 'kept' but got '%s' on '%s' with filter '%v'", src, got.path, got.file, got.filter)
+	}
+	if len(got.selections) != 1 || got.selectionInd != 1 {
+		t.Errorf("expected only '%s' to be selected but got '%v'", kept, got.selections)
+	}
+	if !slices.Equal(got.jumpList, second.jumpList) || got.jumpListInd != 1 {
+		t.Errorf("expected jump list '%v' at 1 but got '%v' at %d", second.jumpList, got.jumpList, got.jumpListInd)
+	}
+	if got.sortby != "time,name" || !got.reverse {
+		t.Errorf("expected sort 'time,name' reversed but got '%s' with reverse '%t'", got.sortby, got.reverse)
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The setup wizard asks a few questions in the command line and writes a
+// commented starter configuration file, along with a previewer script when
+// previews are enabled. It is offered on the first launch when there is no
+// configuration file and no data directory, and it can be started again
+// later with the 'setup' command.
+
+type setupAnswers struct {
+	editor   string
+	opener   string
+	preview  bool
+	graphics string
+	icons    bool
+}
+
+type setupStep struct {
+	prompt string
+	def    string
+	skip   func(a *setupAnswers) bool
+	apply  func(a *setupAnswers, s string) error
+}
+
+type setup struct {
+	steps   []setupStep
+	ind     int
+	answers setupAnswers
+}
+
+func parseSetupBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid answer: %s (should be 'y' or 'n')", s)
+}
+
+// This function guesses the image protocol supported by the terminal from the
+// environment variables set by common terminals.
+func guessGraphics(getenv func(string) string) string {
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || strings.Contains(getenv("TERM"), "kitty"):
+		return "kitty"
+	case strings.HasPrefix(getenv("TERM"), "foot"):
+		return "sixel"
+	}
+
+	switch getenv("TERM_PROGRAM") {
+	case "WezTerm", "iTerm.app", "mlterm":
+		return "sixel"
+	}
+
+	return "none"
+}
+
+func newSetup(path string) *setup {
+	bools := func(field func(a *setupAnswers) *bool) func(a *setupAnswers, s string) error {
+		return func(a *setupAnswers, s string) error {
+			b, err := parseSetupBool(s)
+			*field(a) = b
+			return err
+		}
+	}
+
+	s := &setup{}
+
+	s.steps = []setupStep{
+		{
+			prompt: fmt.Sprintf("create a starter config at '%s'? [Y/n] ", path),
+			def:    "y",
+			apply: func(a *setupAnswers, s string) error {
+				ok, err := parseSetupBool(s)
+				if err == nil && !ok {
+					return errSetupCancelled
+				}
+				return err
+			},
+		},
+		{
+			prompt: fmt.Sprintf("editor [%s]: ", envEditor),
+			def:    envEditor,
+			apply:  func(a *setupAnswers, s string) error { a.editor = s; return nil },
+		},
+		{
+			prompt: fmt.Sprintf("opener [%s]: ", envOpener),
+			def:    envOpener,
+			apply:  func(a *setupAnswers, s string) error { a.opener = s; return nil },
+		},
+		{
+			prompt: "show file previews? [Y/n] ",
+			def:    "y",
+			apply:  bools(func(a *setupAnswers) *bool { return &a.preview }),
+		},
+		{
+			prompt: fmt.Sprintf("image previews (none, sixel or kitty) [%s]: ", guessGraphics(os.Getenv)),
+			def:    guessGraphics(os.Getenv),
+			skip:   func(a *setupAnswers) bool { return !a.preview },
+			apply: func(a *setupAnswers, s string) error {
+				switch s {
+				case "none", "sixel", "kitty":
+					a.graphics = s
+					return nil
+				}
+				return fmt.Errorf("invalid answer: %s (should be 'none', 'sixel' or 'kitty')", s)
+			},
+		},
+		{
+			prompt: "show icons (requires a patched font)? [y/N] ",
+			def:    "n",
+			apply:  bools(func(a *setupAnswers) *bool { return &a.icons }),
+		},
+	}
+
+	return s
+}
+
+var errSetupCancelled = errors.New("cancelled")
+
+func (s *setup) prompt() string {
+	return "setup: " + s.steps[s.ind].prompt
+}
+
+// This function applies the answer to the current question and moves to the
+// next one. It returns true when all the questions are answered.
+func (s *setup) answer(ans string) (bool, error) {
+	step := s.steps[s.ind]
+	if err := step.apply(&s.answers, cmp.Or(strings.TrimSpace(ans), step.def)); err != nil {
+		return false, err
+	}
+
+	s.ind++
+	for s.ind < len(s.steps) && s.steps[s.ind].skip != nil && s.steps[s.ind].skip(&s.answers) {
+		s.ind++
+	}
+
+	return s.ind == len(s.steps), nil
+}
+
+// This function returns the path of the user configuration file.
+func setupConfigPath() string {
+	return gConfigPaths[len(gConfigPaths)-1]
+}
+
+// This function reports whether this is the first launch of lf, in which
+// case the setup wizard is offered.
+func isFirstRun() bool {
+	if gConfigPath != "" || gTutorDir != "" || gDemoDir != "" {
+		return false
+	}
+
+	for _, path := range gConfigPaths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			return false
+		}
+	}
+
+	_, err := os.Stat(filepath.Dir(gHistoryPath))
+	return os.IsNotExist(err)
+}
+
+func (app *app) startSetup() {
+	path := setupConfigPath()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		app.ui.echoerrf("setup: config file already exists: %s", path)
+		return
+	}
+
+	// the data directory marks that the wizard is offered only once
+	if err := os.MkdirAll(filepath.Dir(gHistoryPath), os.ModePerm); err != nil {
+		app.ui.echoerrf("setup: creating data directory: %s", err)
+	}
+
+	normal(app)
+	app.setup = newSetup(path)
+	app.ui.cmdPrefix = app.setup.prompt()
+}
+
+func (app *app) answerSetup(ans string) {
+	done, err := app.setup.answer(ans)
+	if err == errSetupCancelled {
+		normal(app)
+		app.setup = nil
+		app.ui.echomsg("setup: cancelled, run 'setup' to start it again")
+		return
+	}
+	if err != nil {
+		app.ui.echoerrf("setup: %s", err)
+		return
+	}
+	if !done {
+		app.ui.cmdPrefix = app.setup.prompt()
+		return
+	}
+
+	normal(app)
+	answers := app.setup.answers
+	app.setup = nil
+
+	path := setupConfigPath()
+	if err := writeSetupFiles(filepath.Dir(path), answers, getShellProfile(gOpts.shell)); err != nil {
+		app.ui.echoerrf("setup: %s", err)
+		return
+	}
+
+	app.readFile(path)
+	app.ui.echomsg("setup: config written to " + path)
+}
+
+func writeSetupFiles(dir string, a setupAnswers, p *shellProfile) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("creating config directory: %s", err)
+	}
+
+	// previewer scripts are only written for POSIX shells
+	scripts := a.preview && p.posix
+
+	lfrc := genSetupConfig(dir, a, p, scripts)
+	if err := os.WriteFile(filepath.Join(dir, "lfrc"), []byte(lfrc), 0o644); err != nil {
+		return fmt.Errorf("writing config file: %s", err)
+	}
+
+	if !scripts {
+		return nil
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "previewer"), []byte(genSetupPreviewer(a.graphics)), 0o755); err != nil {
+		return fmt.Errorf("writing previewer: %s", err)
+	}
+
+	if a.graphics == "kitty" {
+		if err := os.WriteFile(filepath.Join(dir, "cleaner"), []byte(genSetupCleaner()), 0o755); err != nil {
+			return fmt.Errorf("writing cleaner: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// This function replaces the environment variable used in a default command
+// of the shell profile with the given program.
+func setupCommand(p *shellProfile, e *execExpr, name, prog string) string {
+	var ref string
+	switch p.name {
+	case "cmd":
+		ref = "%" + name + "%"
+	case "powershell":
+		ref = "$Env:" + name + ".Trim('\"', ' ')"
+		if !strings.Contains(e.value, ref) {
+			ref = "$Env:" + name
+		}
+	case "nushell":
+		ref = "$env." + name
+	default:
+		ref = "$" + name
+	}
+	return e.prefix + strings.Replace(e.value, ref, prog, 1)
+}
+
+func genSetupConfig(dir string, a setupAnswers, p *shellProfile, scripts bool) string {
+	var b strings.Builder
+
+	b.WriteString("# lf configuration file generated by the setup wizard\n")
+	b.WriteString("#\n")
+	b.WriteString("# See 'lf -doc' or press <f-1> for the documentation of the options and\n")
+	b.WriteString("# commands used below.\n")
+
+	b.WriteString("\n# Programs used to edit ('e') and open ('l' or <enter>) files\n")
+	if a.editor != envEditor {
+		fmt.Fprintf(&b, "map e %s\n", setupCommand(p, p.keys["e"], "EDITOR", a.editor))
+	} else {
+		fmt.Fprintf(&b, "# map e %s\n", setupCommand(p, p.keys["e"], "EDITOR", a.editor))
+	}
+	if a.opener != envOpener {
+		fmt.Fprintf(&b, "cmd open %s\n", setupCommand(p, p.cmds["open"], "OPENER", a.opener))
+	} else {
+		fmt.Fprintf(&b, "# cmd open %s\n", setupCommand(p, p.cmds["open"], "OPENER", a.opener))
+	}
+
+	b.WriteString("\n# Show hidden files (toggled with 'zh')\n")
+	b.WriteString("# set hidden\n")
+
+	b.WriteString("\n# Previews of the current file in the right pane\n")
+	if !a.preview {
+		b.WriteString("set nopreview\n")
+	} else {
+		b.WriteString("set preview\n")
+		if scripts {
+			fmt.Fprintf(&b, "set previewer '%s'\n", filepath.Join(dir, "previewer"))
+		}
+		switch a.graphics {
+		case "sixel":
+			b.WriteString("set sixel\n")
+		case "kitty":
+			if scripts {
+				fmt.Fprintf(&b, "set cleaner '%s'\n", filepath.Join(dir, "cleaner"))
+			}
+		}
+	}
+
+	b.WriteString("\n# Icons next to file names (requires a patched font such as a Nerd Font)\n")
+	if a.icons {
+		b.WriteString("set icons\n")
+	} else {
+		b.WriteString("# set icons\n")
+	}
+
+	return b.String()
+}
+
+func genSetupPreviewer(graphics string) string {
+	var image string
+	switch graphics {
+	case "sixel":
+		image = `chafa -f sixel -s "$2x$3" --animate off --polite on -- "$1"; exit 1`
+	case "kitty":
+		image = `kitty +kitten icat --stdin no --transfer-mode memory --place "$2x$3@$4x$5" -- "$1" </dev/null >/dev/tty; exit 1`
+	default:
+		image = `file -Lb -- "$1"`
+	}
+
+	return `#!/bin/sh
+# lf previewer generated by the setup wizard
+#
+# The file is given as the first argument, followed by the width, height and
+# position of the preview pane. Exit with a non-zero code to disable caching.
+
+case "$(file --mime-type -Lb -- "$1")" in
+    text/*|application/json|application/javascript)
+        cat -- "$1" ;;
+    image/*)
+        ` + image + ` ;;
+    application/zip)
+        unzip -l -- "$1" ;;
+    application/x-tar|application/gzip|application/x-xz|application/x-bzip2)
+        tar -tf "$1" ;;
+    application/pdf)
+        pdftotext -l 10 -nopgbrk -q -- "$1" - ;;
+    *)
+        file -Lb -- "$1" ;;
+esac
+`
+}
+
+func genSetupCleaner() string {
+	return `#!/bin/sh
+# lf cleaner generated by the setup wizard to clear kitty image previews
+
+kitty +kitten icat --clear --stdin no --silent --transfer-mode file </dev/null >/dev/tty
+`
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGuessGraphics(t *testing.T) {
+	tests := []struct {
+		env map[string]string
+		exp string
+	}{
+		{map[string]string{"TERM": "xterm-kitty"}, "kitty"},
+		{map[string]string{"TERM": "xterm-256color", "KITTY_WINDOW_ID": "1"}, "kitty"},
+		{map[string]string{"TERM": "foot"}, "sixel"},
+		{map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "WezTerm"}, "sixel"},
+		{map[string]string{"TERM": "xterm-256color"}, "none"},
+	}
+
+	for _, test := range tests {
+		if got := guessGraphics(func(k string) string { return test.env[k] }); got != test.exp {
+			t.Errorf("at input '%v' expected '%s' but got '%s'", test.env, test.exp, got)
+		}
+	}
+}
+
+func TestSetupAnswer(t *testing.T) {
+	s := newSetup("lfrc")
+
+	for _, ans := range []string{"", "nvim", "", "n", "maybe", "y"} {
+		if _, err := s.answer(ans); err != nil && ans != "maybe" {
+			t.Errorf("at input '%s' unexpected error: %s", ans, err)
+		}
+	}
+
+	if s.ind != len(s.steps) {
+		t.Errorf("expected all questions to be answered but stopped at '%s'", s.prompt())
+	}
+
+	exp := setupAnswers{editor: "nvim", opener: envOpener, preview: false, icons: true}
+	if s.answers != exp {
+		t.Errorf("expected answers '%+v' but got '%+v'", exp, s.answers)
+	}
+
+	if _, err := newSetup("lfrc").answer("n"); err != errSetupCancelled {
+		t.Errorf("expected declining to cancel the setup but got '%v'", err)
+	}
+}
+
+func TestGenSetupConfig(t *testing.T) {
+	p := gShellProfiles["posix"]
+	a := setupAnswers{editor: "nvim", opener: envOpener, preview: true, graphics: "kitty"}
+
+	got := genSetupConfig("/cfg", a, p, true)
+
+	for _, line := range []string{
+		`map e $nvim "$f"`,
+		`# cmd open &` + envOpener + ` "$f"`,
+		"set previewer '/cfg/previewer'",
+		"set cleaner '/cfg/cleaner'",
+		"# set icons",
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("expected config to contain '%s' but got:\n%s", line, got)
+		}
+	}
+
+	if got := setupCommand(gShellProfiles["powershell"], gShellProfiles["powershell"].cmds["open"], "OPENER", "start"); got != `&&start "$Env:f"` {
+		t.Errorf("expected powershell opener '%s' but got '%s'", `&&start "$Env:f"`, got)
+	}
+}
@@ -0,0 +1,637 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file implements a minimal client of version 3 of the SFTP protocol,
+// which is spoken over the 'sftp' subsystem of the 'ssh' command so that
+// authentication and host keys are handled by the usual ssh configuration.
+// Requests are sent one at a time, which is enough for browsing directories
+// and copying files, since operations of the interface are serialized anyway.
+
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpLstat    = 7
+	sftpSetstat  = 9
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpReadlink = 19
+	sftpSymlink  = 20
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+)
+
+const (
+	sftpOK           = 0
+	sftpEOF          = 1
+	sftpNoSuchFile   = 2
+	sftpPermDenied   = 3
+	sftpAttrSize     = 0x1
+	sftpAttrUIDGID   = 0x2
+	sftpAttrPerms    = 0x4
+	sftpAttrTimes    = 0x8
+	sftpAttrExtended = 0x80000000
+	sftpFlagRead     = 0x1
+	sftpFlagWrite    = 0x2
+	sftpFlagCreate   = 0x8
+	sftpFlagTrunc    = 0x10
+)
+
+const (
+	gSftpChunk     = 32 << 10
+	gSftpMaxPacket = 256 << 10
+)
+
+var errSftpClosed = errors.New("connection is closed")
+
+type sftpStatusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpStatusError) Error() string {
+	if e.msg != "" {
+		return e.msg
+	}
+	return fmt.Sprintf("sftp status %d", e.code)
+}
+
+// This type is a file on the remote host, which implements 'fs.FileInfo'.
+type sftpInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *sftpInfo) Name() string       { return i.name }
+func (i *sftpInfo) Size() int64        { return i.size }
+func (i *sftpInfo) Mode() fs.FileMode  { return i.mode }
+func (i *sftpInfo) ModTime() time.Time { return i.modTime }
+func (i *sftpInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *sftpInfo) Sys() any           { return nil }
+
+type sftpPacket struct {
+	b []byte
+}
+
+func (p *sftpPacket) u32(v uint32) *sftpPacket {
+	p.b = binary.BigEndian.AppendUint32(p.b, v)
+	return p
+}
+
+func (p *sftpPacket) u64(v uint64) *sftpPacket {
+	p.b = binary.BigEndian.AppendUint64(p.b, v)
+	return p
+}
+
+func (p *sftpPacket) str(s string) *sftpPacket {
+	p.u32(uint32(len(s)))
+	p.b = append(p.b, s...)
+	return p
+}
+
+type sftpReader struct {
+	b   []byte
+	err error
+}
+
+func (r *sftpReader) u32() uint32 {
+	if len(r.b) < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sftpReader) u64() uint64 {
+	if len(r.b) < 8 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+func (r *sftpReader) str() string {
+	n := r.u32()
+	if uint32(len(r.b)) < n {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+// This function converts the given unix file mode to a file mode.
+func sftpFileMode(m uint32) fs.FileMode {
+	mode := fs.FileMode(m & 0o777)
+	switch m & 0o170000 {
+	case 0o040000:
+		mode |= fs.ModeDir
+	case 0o120000:
+		mode |= fs.ModeSymlink
+	case 0o060000:
+		mode |= fs.ModeDevice
+	case 0o020000:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case 0o010000:
+		mode |= fs.ModeNamedPipe
+	case 0o140000:
+		mode |= fs.ModeSocket
+	}
+	if m&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if m&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if m&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+func (r *sftpReader) attrs(name string) *sftpInfo {
+	info := &sftpInfo{name: name}
+
+	flags := r.u32()
+	if flags&sftpAttrSize != 0 {
+		info.size = int64(r.u64())
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		r.u32()
+		r.u32()
+	}
+	if flags&sftpAttrPerms != 0 {
+		info.mode = sftpFileMode(r.u32())
+	}
+	if flags&sftpAttrTimes != 0 {
+		r.u32()
+		info.modTime = time.Unix(int64(r.u32()), 0)
+	}
+	if flags&sftpAttrExtended != 0 {
+		for range r.u32() {
+			r.str()
+			r.str()
+		}
+	}
+
+	return info
+}
+
+type sftpClient struct {
+	mutex  sync.Mutex
+	cmd    *exec.Cmd
+	w      io.WriteCloser
+	r      *bufio.Reader
+	nextID uint32
+	err    error
+}
+
+// This function starts a session over the given streams, which are connected
+// to an SFTP server.
+func newSftpClient(r io.Reader, w io.WriteCloser) (*sftpClient, error) {
+	c := &sftpClient{r: bufio.NewReader(r), w: w}
+
+	if err := c.send(sftpInit, (&sftpPacket{}).u32(3).b); err != nil {
+		return nil, err
+	}
+	typ, _, err := c.recv()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpVersion {
+		return nil, fmt.Errorf("unexpected packet type %d", typ)
+	}
+
+	return c, nil
+}
+
+// This function connects to the given host with the 'ssh' command. The host
+// is given as '[user@]host' with an optional port.
+func dialSftp(host, port string) (*sftpClient, error) {
+	args := []string{"-o", "BatchMode=yes"}
+	if port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, "-s", "--", host, "sftp")
+
+	cmd := exec.Command("ssh", args...)
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c, err := newSftpClient(r, w)
+	if err != nil {
+		w.Close()
+		cmd.Wait()
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	c.cmd = cmd
+
+	return c, nil
+}
+
+func (c *sftpClient) send(typ byte, payload []byte) error {
+	b := make([]byte, 0, 5+len(payload))
+	b = binary.BigEndian.AppendUint32(b, uint32(1+len(payload)))
+	b = append(b, typ)
+	b = append(b, payload...)
+	_, err := c.w.Write(b)
+	return err
+}
+
+func (c *sftpClient) recv() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n == 0 || n > gSftpMaxPacket {
+		return 0, nil, fmt.Errorf("invalid packet length %d", n)
+	}
+	b := make([]byte, n-1)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return 0, nil, err
+	}
+	return hdr[4], b, nil
+}
+
+// This function sends a request with the given type and payload, and returns
+// the response without its id. Status responses other than success are
+// returned as errors.
+func (c *sftpClient) request(typ byte, payload []byte) (byte, *sftpReader, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err != nil {
+		return 0, nil, c.err
+	}
+
+	c.nextID++
+	id := c.nextID
+
+	p := (&sftpPacket{}).u32(id)
+	p.b = append(p.b, payload...)
+
+	rtyp, b, err := func() (byte, []byte, error) {
+		if err := c.send(typ, p.b); err != nil {
+			return 0, nil, err
+		}
+		return c.recv()
+	}()
+	if err != nil {
+		// the session can not be recovered after a broken packet
+		c.err = fmt.Errorf("%w: %s", errSftpClosed, err)
+		return 0, nil, c.err
+	}
+
+	r := &sftpReader{b: b}
+	if rid := r.u32(); rid != id {
+		c.err = fmt.Errorf("%w: unexpected response id %d", errSftpClosed, rid)
+		return 0, nil, c.err
+	}
+
+	if rtyp == sftpStatus {
+		code := r.u32()
+		if code == sftpOK {
+			return rtyp, r, nil
+		}
+		return rtyp, r, &sftpStatusError{code, r.str()}
+	}
+
+	return rtyp, r, nil
+}
+
+// This function returns the error of the given operation on the given path,
+// where missing files and denied permissions are reported as in 'os'.
+func sftpPathError(op, p string, err error) error {
+	var serr *sftpStatusError
+	if errors.As(err, &serr) {
+		switch serr.code {
+		case sftpNoSuchFile:
+			err = fs.ErrNotExist
+		case sftpPermDenied:
+			err = fs.ErrPermission
+		}
+	}
+	return &fs.PathError{Op: op, Path: p, Err: err}
+}
+
+func sftpExpect(op, p string, want byte, typ byte, r *sftpReader, err error) (*sftpReader, error) {
+	if err != nil {
+		return nil, sftpPathError(op, p, err)
+	}
+	if typ != want {
+		return nil, sftpPathError(op, p, fmt.Errorf("unexpected packet type %d", typ))
+	}
+	return r, nil
+}
+
+func (c *sftpClient) realpath(p string) (string, error) {
+	typ, r, err := c.request(sftpRealpath, (&sftpPacket{}).str(p).b)
+	if r, err = sftpExpect("realpath", p, sftpName, typ, r, err); err != nil {
+		return "", err
+	}
+	if r.u32() == 0 {
+		return "", sftpPathError("realpath", p, errors.New("empty response"))
+	}
+	name := r.str()
+	return name, r.err
+}
+
+func (c *sftpClient) statType(op string, typ byte, p string) (*sftpInfo, error) {
+	rtyp, r, err := c.request(typ, (&sftpPacket{}).str(p).b)
+	if r, err = sftpExpect(op, p, sftpAttrs, rtyp, r, err); err != nil {
+		return nil, err
+	}
+	info := r.attrs(path.Base(p))
+	return info, r.err
+}
+
+func (c *sftpClient) lstat(p string) (*sftpInfo, error) {
+	return c.statType("lstat", sftpLstat, p)
+}
+
+func (c *sftpClient) stat(p string) (*sftpInfo, error) {
+	return c.statType("stat", sftpStat, p)
+}
+
+func (c *sftpClient) readlink(p string) (string, error) {
+	typ, r, err := c.request(sftpReadlink, (&sftpPacket{}).str(p).b)
+	if r, err = sftpExpect("readlink", p, sftpName, typ, r, err); err != nil {
+		return "", err
+	}
+	if r.u32() == 0 {
+		return "", sftpPathError("readlink", p, errors.New("empty response"))
+	}
+	target := r.str()
+	return target, r.err
+}
+
+func (c *sftpClient) handle(op, p string, typ byte, payload []byte) (string, error) {
+	rtyp, r, err := c.request(typ, payload)
+	if r, err = sftpExpect(op, p, sftpHandle, rtyp, r, err); err != nil {
+		return "", err
+	}
+	h := r.str()
+	return h, r.err
+}
+
+func (c *sftpClient) close(h string) error {
+	_, _, err := c.request(sftpClose, (&sftpPacket{}).str(h).b)
+	return err
+}
+
+// This function returns the entries of the given directory without '.' and
+// '..', which are not sorted.
+func (c *sftpClient) readDir(p string) ([]*sftpInfo, error) {
+	h, err := c.handle("opendir", p, sftpOpendir, (&sftpPacket{}).str(p).b)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close(h)
+
+	var list []*sftpInfo
+	for {
+		typ, r, err := c.request(sftpReaddir, (&sftpPacket{}).str(h).b)
+		var serr *sftpStatusError
+		if errors.As(err, &serr) && serr.code == sftpEOF {
+			return list, nil
+		}
+		if r, err = sftpExpect("readdir", p, sftpName, typ, r, err); err != nil {
+			return list, err
+		}
+
+		for range r.u32() {
+			name := r.str()
+			r.str() // long name
+			info := r.attrs(name)
+			if r.err != nil {
+				return list, sftpPathError("readdir", p, r.err)
+			}
+			if name != "." && name != ".." {
+				list = append(list, info)
+			}
+		}
+	}
+}
+
+func (c *sftpClient) mkdir(p string, mode fs.FileMode) error {
+	payload := (&sftpPacket{}).str(p).u32(sftpAttrPerms).u32(uint32(mode.Perm())).b
+	if _, _, err := c.request(sftpMkdir, payload); err != nil {
+		return sftpPathError("mkdir", p, err)
+	}
+	return nil
+}
+
+func (c *sftpClient) remove(p string) error {
+	if _, _, err := c.request(sftpRemove, (&sftpPacket{}).str(p).b); err != nil {
+		return sftpPathError("remove", p, err)
+	}
+	return nil
+}
+
+// This function creates a symbolic link at the given path. The arguments are
+// sent in the reverse order of the specification, since this is how the
+// OpenSSH server expects them.
+func (c *sftpClient) symlink(target, p string) error {
+	if _, _, err := c.request(sftpSymlink, (&sftpPacket{}).str(target).str(p).b); err != nil {
+		return sftpPathError("symlink", p, err)
+	}
+	return nil
+}
+
+func (c *sftpClient) chtimes(p string, atime, mtime time.Time) error {
+	payload := (&sftpPacket{}).str(p).u32(sftpAttrTimes).u32(uint32(atime.Unix())).u32(uint32(mtime.Unix())).b
+	if _, _, err := c.request(sftpSetstat, payload); err != nil {
+		return sftpPathError("chtimes", p, err)
+	}
+	return nil
+}
+
+type sftpFile struct {
+	c   *sftpClient
+	p   string
+	h   string
+	off uint64
+}
+
+func (c *sftpClient) open(p string) (*sftpFile, error) {
+	payload := (&sftpPacket{}).str(p).u32(sftpFlagRead).u32(0).b
+	h, err := c.handle("open", p, sftpOpen, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpFile{c: c, p: p, h: h}, nil
+}
+
+func (c *sftpClient) create(p string, mode fs.FileMode) (*sftpFile, error) {
+	payload := (&sftpPacket{}).str(p).u32(sftpFlagWrite | sftpFlagCreate | sftpFlagTrunc).u32(sftpAttrPerms).u32(uint32(mode.Perm())).b
+	h, err := c.handle("create", p, sftpOpen, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpFile{c: c, p: p, h: h}, nil
+}
+
+func (f *sftpFile) Read(b []byte) (int, error) {
+	n := min(len(b), gSftpChunk)
+	payload := (&sftpPacket{}).str(f.h).u64(f.off).u32(uint32(n)).b
+
+	typ, r, err := f.c.request(sftpRead, payload)
+	var serr *sftpStatusError
+	if errors.As(err, &serr) && serr.code == sftpEOF {
+		return 0, io.EOF
+	}
+	if r, err = sftpExpect("read", f.p, sftpData, typ, r, err); err != nil {
+		return 0, err
+	}
+
+	data := r.str()
+	if r.err != nil {
+		return 0, sftpPathError("read", f.p, r.err)
+	}
+	n = copy(b, data)
+	f.off += uint64(n)
+	return n, nil
+}
+
+func (f *sftpFile) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		n := min(len(b), gSftpChunk)
+		payload := (&sftpPacket{}).str(f.h).u64(f.off).str(string(b[:n])).b
+		if _, _, err := f.c.request(sftpWrite, payload); err != nil {
+			return written, sftpPathError("write", f.p, err)
+		}
+		f.off += uint64(n)
+		written += n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+func (f *sftpFile) Close() error {
+	if err := f.c.close(f.h); err != nil {
+		return sftpPathError("close", f.p, err)
+	}
+	return nil
+}
+
+// This function ends the session and waits for the 'ssh' command to exit.
+func (c *sftpClient) quit() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err == nil {
+		c.err = errSftpClosed
+	}
+	c.w.Close()
+	if c.cmd != nil {
+		done := make(chan struct{})
+		go func() {
+			c.cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			c.cmd.Process.Kill()
+		}
+	}
+}
+
+func (c *sftpClient) alive() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err == nil
+}
+
+// This type is a location on a remote host given as
+// 'sftp://[user@]host[:port][/path]'.
+type sftpURL struct {
+	host string
+	port string
+	path string
+}
+
+func parseSftpURL(s string) (sftpURL, error) {
+	var u sftpURL
+
+	rest, ok := strings.CutPrefix(s, "sftp://")
+	if !ok {
+		return u, fmt.Errorf("address should start with 'sftp://': %s", s)
+	}
+
+	host, p, _ := strings.Cut(rest, "/")
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		u.port = host[i+1:]
+		host = host[:i]
+		if _, err := strconv.ParseUint(u.port, 10, 16); err != nil {
+			return u, fmt.Errorf("invalid port: %s", u.port)
+		}
+	}
+	if host == "" || strings.HasPrefix(host, "-") || strings.HasSuffix(host, "@") {
+		return u, fmt.Errorf("invalid host: %s", host)
+	}
+
+	u.host = host
+	if p != "" {
+		u.path = path.Clean("/" + p)
+	}
+
+	return u, nil
+}
+
+// This function returns the name of the local directory of the given host.
+func (u sftpURL) name() string {
+	name := strings.NewReplacer("[", "", "]", "", ":", "_").Replace(u.host)
+	if u.port != "" {
+		name += "_" + u.port
+	}
+	return name
+}
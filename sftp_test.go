@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// This function serves the requests of the given streams from the given local
+// directory, which is used as the root directory of the host.
+func serveSftp(t *testing.T, root string, r io.Reader, w io.Writer) {
+	br := bufio.NewReader(r)
+	handles := make(map[string]*os.File)
+	listed := make(map[string]bool)
+
+	send := func(typ byte, p *sftpPacket) {
+		b := binary.BigEndian.AppendUint32(nil, uint32(1+len(p.b)))
+		b = append(b, typ)
+		w.Write(append(b, p.b...))
+	}
+	attrs := func(p *sftpPacket, info os.FileInfo) {
+		mode := uint32(info.Mode().Perm())
+		switch {
+		case info.IsDir():
+			mode |= 0o040000
+		case info.Mode()&os.ModeSymlink != 0:
+			mode |= 0o120000
+		default:
+			mode |= 0o100000
+		}
+		p.u32(sftpAttrSize | sftpAttrPerms | sftpAttrTimes).u64(uint64(info.Size())).u32(mode)
+		p.u32(uint32(info.ModTime().Unix())).u32(uint32(info.ModTime().Unix()))
+	}
+
+	for {
+		var hdr [5]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return
+		}
+		b := make([]byte, binary.BigEndian.Uint32(hdr[:4])-1)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return
+		}
+
+		if hdr[4] == sftpInit {
+			send(sftpVersion, (&sftpPacket{}).u32(3))
+			continue
+		}
+
+		req := &sftpReader{b: b}
+		id := req.u32()
+		resp := (&sftpPacket{}).u32(id)
+		status := func(code uint32) {
+			send(sftpStatus, resp.u32(code).str("").str(""))
+		}
+		local := func(p string) string {
+			return filepath.Join(root, filepath.FromSlash(p))
+		}
+
+		switch hdr[4] {
+		case sftpRealpath:
+			req.str()
+			send(sftpName, resp.u32(1).str("/home").str("").u32(0))
+		case sftpLstat, sftpStat:
+			stat := os.Lstat
+			if hdr[4] == sftpStat {
+				stat = os.Stat
+			}
+			info, err := stat(local(req.str()))
+			if err != nil {
+				status(sftpNoSuchFile)
+				continue
+			}
+			attrs(resp, info)
+			send(sftpAttrs, resp)
+		case sftpOpendir, sftpOpen:
+			p := local(req.str())
+			var f *os.File
+			var err error
+			if hdr[4] == sftpOpen && req.u32()&sftpFlagWrite != 0 {
+				f, err = os.Create(p)
+			} else {
+				f, err = os.Open(p)
+			}
+			if err != nil {
+				status(sftpNoSuchFile)
+				continue
+			}
+			h := f.Name()
+			handles[h] = f
+			send(sftpHandle, resp.str(h))
+		case sftpReaddir:
+			h := req.str()
+			if listed[h] {
+				status(sftpEOF)
+				continue
+			}
+			listed[h] = true
+			entries, _ := handles[h].Readdir(-1)
+			resp.u32(uint32(len(entries)))
+			for _, e := range entries {
+				resp.str(e.Name()).str("")
+				attrs(resp, e)
+			}
+			send(sftpName, resp)
+		case sftpRead:
+			h := req.str()
+			off := req.u64()
+			buf := make([]byte, req.u32())
+			n, err := handles[h].ReadAt(buf, int64(off))
+			if n == 0 && err == io.EOF {
+				status(sftpEOF)
+				continue
+			}
+			send(sftpData, resp.str(string(buf[:n])))
+		case sftpWrite:
+			h := req.str()
+			off := req.u64()
+			handles[h].WriteAt([]byte(req.str()), int64(off))
+			status(sftpOK)
+		case sftpClose:
+			h := req.str()
+			handles[h].Close()
+			delete(handles, h)
+			delete(listed, h)
+			status(sftpOK)
+		case sftpMkdir:
+			if err := os.Mkdir(local(req.str()), 0o755); err != nil {
+				status(sftpNoSuchFile)
+				continue
+			}
+			status(sftpOK)
+		case sftpSetstat:
+			status(sftpOK)
+		default:
+			t.Errorf("unexpected request type %d", hdr[4])
+			status(4)
+		}
+	}
+}
+
+func TestParseSftpURL(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp sftpURL
+		err bool
+	}{
+		{"sftp://host", sftpURL{host: "host"}, false},
+		{"sftp://user@host/var/www/", sftpURL{host: "user@host", path: "/var/www"}, false},
+		{"sftp://user@host:2222/tmp", sftpURL{host: "user@host", port: "2222", path: "/tmp"}, false},
+		{"sftp://host:port", sftpURL{}, true},
+		{"sftp://-oProxyCommand=x", sftpURL{}, true},
+		{"ssh://host", sftpURL{}, true},
+		{"sftp:///path", sftpURL{}, true},
+	}
+
+	for _, test := range tests {
+		u, err := parseSftpURL(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("at input '%s' expected error '%t' but got '%v'", test.s, test.err, err)
+			continue
+		}
+		if !test.err && u != test.exp {
+			t.Errorf("at input '%s' expected '%+v' but got '%+v'", test.s, test.exp, u)
+		}
+	}
+}
+
+func TestRemote(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "home", "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "home", "docs", "a.txt"), []byte("remote file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	go serveSftp(t, root, sr, sw)
+
+	c, err := newSftpClient(cr, cw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mount := filepath.Join(t.TempDir(), "user@host")
+	gRemotes.mutex.Lock()
+	gRemotes.conns[mount] = c
+	gRemotes.mutex.Unlock()
+	defer func() {
+		gRemotes.mutex.Lock()
+		delete(gRemotes.conns, mount)
+		gRemotes.mutex.Unlock()
+		c.quit()
+	}()
+
+	if home, err := c.realpath("."); err != nil || home != "/home" {
+		t.Errorf("expected home directory '/home' but got '%s' (%v)", home, err)
+	}
+
+	home := filepath.Join(mount, "home")
+	files, err := readdir(home)
+	if err != nil || len(files) != 1 || files[0].Name() != "docs" || !files[0].IsDir() {
+		t.Fatalf("unexpected entries of remote directory: %v (%v)", files, err)
+	}
+
+	if _, err := lstatPath(filepath.Join(home, "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected missing remote file to not exist but got '%v'", err)
+	}
+	if err := checkWritable(filepath.Join(home, "docs")); err != errRemoteReadOnly {
+		t.Errorf("expected remote files to be read-only but got '%v'", err)
+	}
+
+	var walked []string
+	walkPath(home, func(path string, info os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(home, path)
+		walked = append(walked, filepath.ToSlash(rel))
+		return nil
+	})
+	if !slices.Equal(walked, []string{".", "docs", "docs/a.txt"}) {
+		t.Errorf("unexpected walk of remote directory: %v", walked)
+	}
+
+	// remote to local
+	local := t.TempDir()
+	_, errs := copyAll([]string{filepath.Join(home, "docs")}, local, nil, nil)
+	for err := range errs {
+		t.Errorf("copying from remote: %s", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(local, "docs", "a.txt")); err != nil || string(b) != "remote file" {
+		t.Errorf("expected copied contents 'remote file' but got '%s' (%v)", b, err)
+	}
+
+	// local to remote
+	if err := os.WriteFile(filepath.Join(local, "docs", "b.txt"), []byte("local file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, errs = copyAll([]string{filepath.Join(local, "docs")}, home, []string{"timestamps"}, nil)
+	for err := range errs {
+		t.Errorf("copying to remote: %s", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(root, "home", "docs.~1~", "b.txt")); err != nil || string(b) != "local file" {
+		t.Errorf("expected copied contents 'local file' but got '%s' (%v)", b, err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Shell profiles describe how commands are passed to a given family of
+// shells. The profile is detected from the 'shell' option and is used to
+// decide whether positional arguments need a leading '--', whether the 'ifs'
+// option can be applied, and which templates to use for default commands.
+//
+// Positional arguments are passed in the 'lf_args' environment variable as a
+// JSON list for shells with an 'argsWrap' template, which binds them to
+// '$args' around the command. PowerShell joins the arguments after the command
+// into the command itself, which would run file names as code, and nushell
+// does not accept arguments after the command.
+type shellProfile struct {
+	name     string
+	flag     string
+	posix    bool
+	argsWrap string
+	cmds     map[string]*execExpr
+	keys     map[string]*execExpr
+}
+
+var gShellProfiles = map[string]*shellProfile{
+	"posix": {
+		name:  "posix",
+		flag:  "-c",
+		posix: true,
+		cmds: map[string]*execExpr{
+			"open":  {"&", `$OPENER "$f"`},
+			"doc":   {"$", `"$lf" -doc | $PAGER`},
+			"maps":  {"$", `"$lf" -remote "query $id maps" | $PAGER`},
+			"nmaps": {"$", `"$lf" -remote "query $id nmaps" | $PAGER`},
+			"vmaps": {"$", `"$lf" -remote "query $id vmaps" | $PAGER`},
+			"cmaps": {"$", `"$lf" -remote "query $id cmaps" | $PAGER`},
+			"cmds":  {"$", `"$lf" -remote "query $id cmds" | $PAGER`},
+		},
+		keys: map[string]*execExpr{
+			"e": {"$", `$EDITOR "$f"`},
+			"i": {"$", `$PAGER "$f"`},
+			"w": {"$", "$SHELL"},
+		},
+	},
+	"cmd": {
+		name: "cmd",
+		flag: "/c",
+		cmds: map[string]*execExpr{
+			"open":  {"&", "%OPENER% %f%"},
+			"doc":   {"!", "%lf% -doc | %PAGER%"},
+			"maps":  {"!", `%lf% -remote "query %id% maps" | %PAGER%`},
+			"nmaps": {"!", `%lf% -remote "query %id% nmaps" | %PAGER%`},
+			"vmaps": {"!", `%lf% -remote "query %id% vmaps" | %PAGER%`},
+			"cmaps": {"!", `%lf% -remote "query %id% cmaps" | %PAGER%`},
+			"cmds":  {"!", `%lf% -remote "query %id% cmds" | %PAGER%`},
+		},
+		keys: map[string]*execExpr{
+			"e": {"$", "%EDITOR% %f%"},
+			"i": {"!", "%PAGER% %f%"},
+			"w": {"$", "%SHELL%"},
+		},
+	},
+	"powershell": {
+		name:     "powershell",
+		flag:     "-Command",
+		argsWrap: "$lf_args = @(ConvertFrom-Json $Env:lf_args)\n& {\n%s\n} @lf_args",
+		cmds: map[string]*execExpr{
+			"open":  {"&", `&$Env:OPENER.Trim('"', ' ') "$Env:f"`},
+			"doc":   {"!", "&$Env:lf -doc | &$Env:PAGER"},
+			"maps":  {"!", `&$Env:lf -remote "query $Env:id maps" | &$Env:PAGER`},
+			"nmaps": {"!", `&$Env:lf -remote "query $Env:id nmaps" | &$Env:PAGER`},
+			"vmaps": {"!", `&$Env:lf -remote "query $Env:id vmaps" | &$Env:PAGER`},
+			"cmaps": {"!", `&$Env:lf -remote "query $Env:id cmaps" | &$Env:PAGER`},
+			"cmds":  {"!", `&$Env:lf -remote "query $Env:id cmds" | &$Env:PAGER`},
+		},
+		keys: map[string]*execExpr{
+			"e": {"$", `&$Env:EDITOR "$Env:f"`},
+			"i": {"!", `&$Env:PAGER "$Env:f"`},
+			"w": {"$", "&$Env:SHELL"},
+		},
+	},
+	"fish": {
+		name: "fish",
+		flag: "-c",
+		cmds: map[string]*execExpr{
+			"open":  {"&", "$OPENER $f"},
+			"doc":   {"$", "$lf -doc | $PAGER"},
+			"maps":  {"$", `$lf -remote "query $id maps" | $PAGER`},
+			"nmaps": {"$", `$lf -remote "query $id nmaps" | $PAGER`},
+			"vmaps": {"$", `$lf -remote "query $id vmaps" | $PAGER`},
+			"cmaps": {"$", `$lf -remote "query $id cmaps" | $PAGER`},
+			"cmds":  {"$", `$lf -remote "query $id cmds" | $PAGER`},
+		},
+		keys: map[string]*execExpr{
+			"e": {"$", "$EDITOR $f"},
+			"i": {"$", "$PAGER $f"},
+			"w": {"$", "$SHELL"},
+		},
+	},
+	"nushell": {
+		name:     "nushell",
+		flag:     "-c",
+		argsWrap: "let args = ($env.lf_args | from json)\n%s",
+		cmds: map[string]*execExpr{
+			"open":  {"&", "^$env.OPENER $env.f"},
+			"doc":   {"$", "^$env.lf -doc | ^$env.PAGER"},
+			"maps":  {"$", `^$env.lf -remote $"query ($env.id) maps" | ^$env.PAGER`},
+			"nmaps": {"$", `^$env.lf -remote $"query ($env.id) nmaps" | ^$env.PAGER`},
+			"vmaps": {"$", `^$env.lf -remote $"query ($env.id) vmaps" | ^$env.PAGER`},
+			"cmaps": {"$", `^$env.lf -remote $"query ($env.id) cmaps" | ^$env.PAGER`},
+			"cmds":  {"$", `^$env.lf -remote $"query ($env.id) cmds" | ^$env.PAGER`},
+		},
+		keys: map[string]*execExpr{
+			"e": {"$", "^$env.EDITOR $env.f"},
+			"i": {"$", "^$env.PAGER $env.f"},
+			"w": {"$", "^$env.SHELL"},
+		},
+	},
+}
+
+func shellProfileName(shell string) string {
+	name := strings.ToLower(filepath.Base(shell))
+	name = strings.TrimSuffix(name, ".exe")
+
+	switch name {
+	case "cmd":
+		return "cmd"
+	case "pwsh", "powershell":
+		return "powershell"
+	case "fish":
+		return "fish"
+	case "nu":
+		return "nushell"
+	default:
+		return "posix"
+	}
+}
+
+func getShellProfile(shell string) *shellProfile {
+	return gShellProfiles[shellProfileName(shell)]
+}
+
+// This function returns the command wrapped with the 'argsWrap' template of
+// the given profile, and the environment variable holding the arguments, or
+// the command and the arguments as they are for other shells.
+func wrapShellArgs(p *shellProfile, s string, args []string) (string, []string, string) {
+	if p.argsWrap == "" {
+		return s, args, ""
+	}
+
+	if args == nil {
+		args = []string{}
+	}
+	b, _ := json.Marshal(args)
+
+	return fmt.Sprintf(p.argsWrap, s), nil, "lf_args=" + string(b)
+}
+
+func isShellDefault(e expr, def *execExpr) bool {
+	ee, ok := e.(*execExpr)
+	return ok && ee.prefix == def.prefix && ee.value == def.value
+}
+
+func setShellDefaults(p *shellProfile) {
+	for name, e := range p.cmds {
+		gOpts.cmds[name] = &execExpr{e.prefix, e.value}
+	}
+	for key, e := range p.keys {
+		gOpts.nkeys[key] = &execExpr{e.prefix, e.value}
+		gOpts.vkeys[key] = &execExpr{e.prefix, e.value}
+	}
+}
+
+func setDefaults() {
+	setShellDefaults(getShellProfile(gDefaultShell))
+	gOpts.nkeys["<f-1>"] = &callExpr{"help", nil, 1}
+	gOpts.vkeys["<f-1>"] = &callExpr{"help", nil, 1}
+}
+
+// updateShellDefaults replaces the default commands and keybindings written
+// for the previous shell profile with the templates of the new one. Anything
+// the user has redefined is left untouched.
+func updateShellDefaults(prev, curr *shellProfile) {
+	if prev == curr {
+		return
+	}
+
+	for name, e := range prev.cmds {
+		if isShellDefault(gOpts.cmds[name], e) {
+			n := curr.cmds[name]
+			gOpts.cmds[name] = &execExpr{n.prefix, n.value}
+		}
+	}
+
+	for key, e := range prev.keys {
+		n := curr.keys[key]
+		if isShellDefault(gOpts.nkeys[key], e) {
+			gOpts.nkeys[key] = &execExpr{n.prefix, n.value}
+		}
+		if isShellDefault(gOpts.vkeys[key], e) {
+			gOpts.vkeys[key] = &execExpr{n.prefix, n.value}
+		}
+	}
+
+	if gOpts.shellflag == prev.flag {
+		gOpts.shellflag = curr.flag
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestShellProfileName(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+	}{
+		{"sh", "posix"},
+		{"bash", "posix"},
+		{"/usr/bin/zsh", "posix"},
+		{"cmd", "cmd"},
+		{"CMD.EXE", "cmd"},
+		{"pwsh", "powershell"},
+		{"powershell.exe", "powershell"},
+		{"/usr/local/bin/fish", "fish"},
+		{"nu", "nushell"},
+		{"nushell", "posix"},
+	}
+
+	for _, test := range tests {
+		if got := shellProfileName(test.s); got != test.exp {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.s, test.exp, got)
+		}
+	}
+}
+
+func TestShellProfileTemplates(t *testing.T) {
+	posix := gShellProfiles["posix"]
+	for name, p := range gShellProfiles {
+		if p.name != name {
+			t.Errorf("at profile '%s' expected name '%s' but got '%s'", name, name, p.name)
+		}
+		for cmd := range posix.cmds {
+			if _, ok := p.cmds[cmd]; !ok {
+				t.Errorf("at profile '%s' expected command '%s' but got none", name, cmd)
+			}
+		}
+		for key := range posix.keys {
+			if _, ok := p.keys[key]; !ok {
+				t.Errorf("at profile '%s' expected key '%s' but got none", name, key)
+			}
+		}
+	}
+}
+
+func TestWrapShellArgs(t *testing.T) {
+	args := []string{"a b", "c'; Remove-Item -Recurse ~; '"}
+
+	s, got, env := wrapShellArgs(gShellProfiles["posix"], "echo", args)
+	if s != "echo" || !slices.Equal(got, args) || env != "" {
+		t.Errorf("at profile 'posix' expected arguments to be passed as they are but got '%s' '%v' '%s'", s, got, env)
+	}
+
+	for _, name := range []string{"powershell", "nushell"} {
+		s, got, env := wrapShellArgs(gShellProfiles[name], "echo", args)
+		if !strings.Contains(s, "echo") || strings.Contains(s, args[1]) || got != nil {
+			t.Errorf("at profile '%s' expected arguments not to be passed in the command but got '%s' '%v'", name, s, got)
+		}
+
+		var list []string
+		val, ok := strings.CutPrefix(env, "lf_args=")
+		if !ok || json.Unmarshal([]byte(val), &list) != nil || !slices.Equal(list, args) {
+			t.Errorf("at profile '%s' expected arguments '%v' in the environment but got '%s'", name, args, env)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Shell integration prints an 'lfcd' wrapper function for the given shell,
+// which can be evaluated in the shell rc file instead of copying one of the
+// example scripts in the 'etc' directory. The wrapper changes the directory
+// of the shell itself using '-print-last-dir', since '-autocd' starts a new
+// shell on exit which would be nested in the current one for each use.
+
+// The same function is used for bash and zsh.
+const gShellInitPOSIX = `lfcd () {
+    # 'command' is needed in case 'lfcd' is aliased to 'lf'
+    local dir
+    dir="$(command lf -print-last-dir "$@")"
+    if [ -n "$dir" ] && [ -d "$dir" ] && [ "$dir" != "$PWD" ]; then
+        cd -- "$dir"
+    fi
+}
+`
+
+var gShellInitScripts = map[string]string{
+	"bash": gShellInitPOSIX,
+	"zsh":  gShellInitPOSIX,
+	"fish": `function lfcd --wraps=lf --description="lf - Terminal file manager (changing directory on exit)"
+    # 'string collect' keeps the output as a single argument
+    set -l dir (command lf -print-last-dir $argv | string collect)
+    if test -n "$dir"; and test -d "$dir"; and test "$dir" != "$PWD"
+        cd -- "$dir"
+    end
+end
+`,
+	"powershell": `function lfcd {
+    # the application is called explicitly in case 'lf' is an alias
+    $lf = Get-Command -Name lf -CommandType Application | Select-Object -First 1
+    $dir = & $lf -print-last-dir @args | Out-String
+    $dir = $dir.TrimEnd("` + "`r`n" + `")
+    if ($dir -and (Test-Path -LiteralPath $dir -PathType Container)) {
+        Set-Location -LiteralPath $dir
+    }
+}
+`,
+}
+
+func shellInit(shell string) (string, error) {
+	script, ok := gShellInitScripts[strings.ToLower(shell)]
+	if !ok {
+		shells := make([]string, 0, len(gShellInitScripts))
+		for name := range gShellInitScripts {
+			shells = append(shells, name)
+		}
+		slices.Sort(shells)
+		return "", fmt.Errorf("unsupported shell: %s (expected %s)", shell, strings.Join(shells, ", "))
+	}
+	return script, nil
+}
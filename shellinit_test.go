@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShellInit(t *testing.T) {
+	tests := []struct {
+		shell string
+		exp   string
+		ok    bool
+	}{
+		{"bash", `cd -- "$dir"`, true},
+		{"zsh", `cd -- "$dir"`, true},
+		{"Bash", `cd -- "$dir"`, true},
+		{"fish", `cd -- "$dir"`, true},
+		{"powershell", `Set-Location -LiteralPath $dir`, true},
+		{"tcsh", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		got, err := shellInit(test.shell)
+		if (err == nil) != test.ok {
+			t.Errorf("at input '%s' expected ok '%t' but got error '%v'", test.shell, test.ok, err)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		if !strings.Contains(got, test.exp) {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.shell, test.exp, got)
+		}
+		if !strings.Contains(got, "-print-last-dir") || strings.Contains(got, "-autocd") {
+			t.Errorf("at input '%s' expected a function using '-print-last-dir' but got '%s'", test.shell, got)
+		}
+	}
+}
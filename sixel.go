@@ -15,12 +15,18 @@ const gSixelBegin = "\033P"
 type sixelScreen struct {
 	lastFile   string
 	lastWin    win
+	lastKitty  bool
 	forceClear bool
 }
 
 func (sxs *sixelScreen) clearSixel(win *win, screen tcell.Screen, filePath string) {
 	if sxs.lastFile != "" && (filePath != sxs.lastFile || *win != sxs.lastWin || sxs.forceClear) {
 		screen.LockRegion(sxs.lastWin.x, sxs.lastWin.y, sxs.lastWin.w, sxs.lastWin.h, false)
+		if sxs.lastKitty {
+			// kitty images are not removed when cells are redrawn
+			fmt.Fprint(os.Stderr, gKittyDelete)
+			sxs.lastKitty = false
+		}
 	}
 }
 
@@ -29,7 +35,7 @@ func (sxs *sixelScreen) printSixel(win *win, screen tcell.Screen, reg *reg) {
 		return
 	}
 
-	if reg.sixel == nil {
+	if reg.sixel == nil && reg.image == nil {
 		sxs.lastFile = ""
 		return
 	}
@@ -40,29 +46,37 @@ func (sxs *sixelScreen) printSixel(win *win, screen tcell.Screen, reg *reg) {
 		return
 	}
 
-	matches := reSixelSize.FindStringSubmatch(*reg.sixel)
-	if matches == nil {
-		log.Printf("sixel: failed to get image size")
-		return
-	}
-	iw, _ := strconv.Atoi(matches[1])
-	ih, _ := strconv.Atoi(matches[2])
-
-	if os.Getenv("TMUX") != "" {
-		// tmux rounds the image height up to a multiple of 6, so we
-		// need to do the same to avoid overwriting the image, as tmux
-		// would remove the image if we touched it.
-		ih = (ih + 5) / 6 * 6
+	var iw, ih int
+	var seq string
+	if reg.image != nil {
+		iw, ih, seq = reg.image.w, reg.image.h, reg.image.seq
+	} else {
+		matches := reSixelSize.FindStringSubmatch(*reg.sixel)
+		if matches == nil {
+			log.Printf("sixel: failed to get image size")
+			return
+		}
+		iw, _ = strconv.Atoi(matches[1])
+		ih, _ = strconv.Atoi(matches[2])
+		seq = *reg.sixel
+
+		if os.Getenv("TMUX") != "" {
+			// tmux rounds the image height up to a multiple of 6, so we
+			// need to do the same to avoid overwriting the image, as tmux
+			// would remove the image if we touched it.
+			ih = (ih + 5) / 6 * 6
+		}
 	}
 
 	screen.LockRegion(win.x, win.y, (iw+cw-1)/cw, (ih+ch-1)/ch, true)
 	fmt.Fprint(os.Stderr, "\0337")                          // Save cursor position
 	fmt.Fprintf(os.Stderr, "\033[%d;%dH", win.y+1, win.x+1) // Move cursor to position
-	fmt.Fprint(os.Stderr, *reg.sixel)                       // Print sixel
+	fmt.Fprint(os.Stderr, seq)                              // Print image
 	fmt.Fprint(os.Stderr, "\0338")                          // Restore cursor position
 
 	sxs.lastFile = reg.path
 	sxs.lastWin = *win
+	sxs.lastKitty = reg.image != nil && reg.image.kitty
 	sxs.forceClear = false
 }
 
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// The 'sortby' and 'reverse' options set for a directory with 'setlocal' only
+// last for the session, while the ones saved with the 'sortlocal' command are
+// kept in a data file and set again with 'setlocal' in later sessions after
+// the configuration files are read, e.g. to sort downloads by time and source
+// directories by name. Only the directory itself is affected and not its
+// subdirectories. Saved directories are kept one per line with the sort
+// method, 'reverse' or 'noreverse', and the path separated with spaces.
+
+type savedSort struct {
+	sortby  sortMethod
+	reverse bool
+}
+
+func parseSavedSort(line string) (string, savedSort, bool) {
+	toks := strings.SplitN(line, " ", 3)
+	if len(toks) != 3 || !isValidSortMethod(sortMethod(toks[0])) || !filepath.IsAbs(toks[2]) {
+		return "", savedSort{}, false
+	}
+	switch toks[1] {
+	case "reverse":
+		return toks[2], savedSort{sortMethod(toks[0]), true}, true
+	case "noreverse":
+		return toks[2], savedSort{sortMethod(toks[0]), false}, true
+	}
+	return "", savedSort{}, false
+}
+
+// This function returns the saved sorts by directory. It should be called
+// while holding the lock of the file.
+func readSavedSorts() (map[string]savedSort, error) {
+	lines, err := readDataLines(gSortsPath, func(line string) bool {
+		_, _, ok := parseSavedSort(line)
+		return ok
+	})
+
+	sorts := make(map[string]savedSort, len(lines))
+	for _, line := range lines {
+		path, s, _ := parseSavedSort(line)
+		sorts[path] = s
+	}
+
+	return sorts, err
+}
+
+// This function saves the given sort for the given directory, or removes the
+// saved sort of the directory when it is nil.
+func writeSavedSort(path string, s *savedSort) error {
+	if strings.ContainsAny(path, "\r\n") {
+		return fmt.Errorf("file name contains a newline: %q", path)
+	}
+
+	return withDataLock(gSortsPath, func() error {
+		sorts, err := readSavedSorts()
+		if err != nil {
+			return fmt.Errorf("sorts file: %s", err)
+		}
+
+		if s == nil {
+			delete(sorts, path)
+		} else {
+			sorts[path] = *s
+		}
+
+		var b bytes.Buffer
+		for _, path := range slices.Sorted(maps.Keys(sorts)) {
+			reverse := "noreverse"
+			if sorts[path].reverse {
+				reverse = "reverse"
+			}
+			fmt.Fprintf(&b, "%s %s %s\n", sorts[path].sortby, reverse, path)
+		}
+
+		if err := writeDataFile(gSortsPath, b.Bytes()); err != nil {
+			return fmt.Errorf("writing sorts file: %s", err)
+		}
+
+		return nil
+	})
+}
+
+// This function sets the saved sorts as local options.
+func (app *app) readSortLocal() {
+	var sorts map[string]savedSort
+	err := withDataLock(gSortsPath, func() (err error) {
+		sorts, err = readSavedSorts()
+		return
+	})
+
+	for path, s := range sorts {
+		gLocalOpts.sortby[path] = s.sortby
+		gLocalOpts.reverse[path] = s.reverse
+	}
+
+	if err != nil {
+		app.ui.echoerrf("sorts file: %s", err)
+	}
+}
+
+// This function saves the sort of the current directory, after setting the
+// given sort method for the directory when it is not empty. The saved sort is
+// removed with '--remove', in which case the directory is sorted with the
+// global options again.
+func (app *app) sortLocal(args []string) {
+	path := app.nav.currDir().path
+
+	var s *savedSort
+	switch {
+	case len(args) == 1 && args[0] == "--remove":
+		delete(gLocalOpts.sortby, path)
+		delete(gLocalOpts.reverse, path)
+	case len(args) <= 1:
+		if len(args) == 1 {
+			method := sortMethod(args[0])
+			if !isValidSortMethod(method) {
+				app.ui.echoerr(invalidSortErrorMessage)
+				return
+			}
+			gLocalOpts.sortby[path] = method
+		}
+		s = &savedSort{getSortBy(path), getReverse(path)}
+		gLocalOpts.sortby[path] = s.sortby
+		gLocalOpts.reverse[path] = s.reverse
+	default:
+		app.ui.echoerr("sortlocal: usage: sortlocal [method|--remove]")
+		return
+	}
+
+	if err := writeSavedSort(path, s); err != nil {
+		app.ui.echoerrf("sortlocal: %s", err)
+		return
+	}
+
+	app.nav.sort()
+	app.ui.sort()
+	app.ui.loadFileInfo(app.nav)
+	app.showSort()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSavedSorts(t *testing.T) {
+	oldPath := gSortsPath
+	defer func() { gSortsPath = oldPath }()
+
+	dir := t.TempDir()
+	gSortsPath = filepath.Join(dir, "data", "sorts")
+
+	downloads := filepath.Join(dir, "my downloads")
+	src := filepath.Join(dir, "src")
+	if err := writeSavedSort(downloads, &savedSort{"time", true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSavedSort(src, &savedSort{"ext,name", false}); err != nil {
+		t.Fatal(err)
+	}
+
+	sorts, err := readSavedSorts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := sorts[downloads]; s.sortby != "time" || !s.reverse {
+		t.Errorf("at input '%s' expected 'time' reversed but got '%v'", downloads, s)
+	}
+	if s := sorts[src]; s.sortby != "ext,name" || s.reverse {
+		t.Errorf("at input '%s' expected 'ext,name' but got '%v'", src, s)
+	}
+
+	if err := writeSavedSort(downloads, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(gSortsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "ext,name noreverse " + src + "\n"; string(data) != exp {
+		t.Errorf("expected '%s' but got '%s'", exp, data)
+	}
+
+	for _, line := range []string{"time reverse", "foo reverse /tmp", "time maybe /tmp", "time reverse tmp"} {
+		if _, _, ok := parseSavedSort(line); ok {
+			t.Errorf("at input '%s' expected an invalid line", line)
+		}
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Large files can be split into parts with the 'split-file' command, e.g. to
+// move them to a FAT32 drive or to upload them with a size limit, and joined
+// back with the 'join-files' command. Parts are named after the file with a
+// numbered suffix (e.g. 'foo.001', 'foo.002'), and the SHA-256 checksums of
+// the parts and the whole file are written to a checksum file (e.g.
+// 'foo.sha256') in the format of 'sha256sum', so that the parts can also be
+// verified with 'sha256sum -c'. Checksums are verified when the parts are
+// joined if the checksum file exists next to them. Both commands run in the
+// background in the same queue as paste operations and existing files are
+// never overwritten.
+
+const gMaxParts = 999
+
+var gPartRegex = regexp.MustCompile(`^(.+)\.(\d{3})$`)
+
+func partName(path string, i int) string {
+	return fmt.Sprintf("%s.%03d", path, i)
+}
+
+// This function returns the path of the split file for the given part, or
+// false if the file is not named as a part.
+func partBase(path string) (string, bool) {
+	m := gPartRegex.FindStringSubmatch(path)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+func checksumPath(path string) string {
+	return path + ".sha256"
+}
+
+// This function returns the parts of the split file with the given path, which
+// are the numbered files starting from the first one until one is missing.
+func splitParts(path string) []string {
+	var parts []string
+	for i := 1; i <= gMaxParts; i++ {
+		part := partName(path, i)
+		if _, err := os.Lstat(part); err != nil {
+			break
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// This function returns the number of parts needed to split a file of the
+// given size into parts of the given size.
+func partCount(total, size int64) (int, error) {
+	if size <= 0 {
+		return 0, errors.New("part size should be positive")
+	}
+	if total <= size {
+		return 0, fmt.Errorf("file is not larger than %s", humanize(size))
+	}
+	n := (total + size - 1) / size
+	if n > gMaxParts {
+		return 0, fmt.Errorf("too many parts (%d), maximum is %d", n, gMaxParts)
+	}
+	return int(n), nil
+}
+
+func writeChecksums(path string, names []string, sums [][]byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return err
+	}
+
+	if err := fprintChecksums(f, names, sums); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
+
+// This function writes the given checksums in the format of 'sha256sum'.
+func fprintChecksums(f io.Writer, names []string, sums [][]byte) error {
+	w := bufio.NewWriter(f)
+	for i, name := range names {
+		fmt.Fprintf(w, "%x  %s\n", sums[i], name)
+	}
+	return w.Flush()
+}
+
+// This function reads a checksum file in the format of 'sha256sum' and returns
+// the checksums by file name.
+func readChecksums(path string) (map[string]string, error) {
+	f, err := openPath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sums := make(map[string]string)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid checksum line: %s", line)
+		}
+		// binary mode is marked with '*' before the name
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		sums[name] = strings.ToLower(sum)
+	}
+
+	return sums, s.Err()
+}
+
+func checkSum(h hash.Hash, sums map[string]string, name string) error {
+	want, ok := sums[name]
+	if !ok {
+		return nil
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch: %s", name)
+	}
+	return nil
+}
+
+// This function splits the given file into parts of the given size next to
+// it and writes the checksum file. Written bytes are sent to the given channel
+// for progress. Created files are removed when there is an error.
+func splitFile(path string, size int64, nums chan<- int64) (parts []string, err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("not a regular file: %s", path)
+	}
+
+	n, err := partCount(stat.Size(), size)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			for _, part := range parts {
+				os.Remove(part)
+			}
+			parts = nil
+		}
+	}()
+
+	whole := sha256.New()
+	r := io.TeeReader(src, whole)
+
+	names := make([]string, 0, n+1)
+	sums := make([][]byte, 0, n+1)
+
+	for i := 1; i <= n; i++ {
+		part := partName(path, i)
+		f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stat.Mode().Perm())
+		if err != nil {
+			return parts, err
+		}
+		parts = append(parts, part)
+
+		h := sha256.New()
+		_, err = io.CopyN(NewProgressWriter(io.MultiWriter(f, h), nums), r, size)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil && !(errors.Is(err, io.EOF) && i == n) {
+			return parts, err
+		}
+
+		names = append(names, filepath.Base(part))
+		sums = append(sums, h.Sum(nil))
+	}
+
+	names = append(names, filepath.Base(path))
+	sums = append(sums, whole.Sum(nil))
+
+	sumPath := checksumPath(path)
+	if err := writeChecksums(sumPath, names, sums); err != nil {
+		return parts, err
+	}
+
+	return append(parts, sumPath), nil
+}
+
+// This function joins the given parts into the output file and verifies their
+// checksums when the checksum file of the parts exists. Written bytes are sent
+// to the given channel for progress. It returns whether the checksums are
+// verified, and the output file is removed when there is an error.
+func joinFiles(parts []string, output string, nums chan<- int64) (verified bool, err error) {
+	var sums map[string]string
+	var name string
+	if base, ok := partBase(parts[0]); ok {
+		name = filepath.Base(base)
+		sums, err = readChecksums(checksumPath(base))
+		if os.IsNotExist(err) {
+			sums, err = nil, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	out, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(output)
+		}
+	}()
+
+	whole := sha256.New()
+	for _, part := range parts {
+		f, err := os.Open(part)
+		if err != nil {
+			return false, err
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(NewProgressWriter(io.MultiWriter(out, h, whole), nums), f)
+		f.Close()
+		if err != nil {
+			return false, err
+		}
+
+		if err := checkSum(h, sums, filepath.Base(part)); err != nil {
+			return false, err
+		}
+	}
+
+	// a missing part is only detected with the checksum of the whole file
+	if err := checkSum(whole, sums, name); err != nil {
+		return false, err
+	}
+
+	_, verified = sums[name]
+	return verified, nil
+}
+
+// This function returns the total size of the given files.
+func filesSize(paths []string) int64 {
+	var total int64
+	for _, path := range paths {
+		if stat, err := os.Stat(path); err == nil {
+			total += stat.Size()
+		}
+	}
+	return total
+}
+
+// This function runs the given function in the background after paste
+// operations and other jobs touching the given paths are finished.
+func (app *app) runQueued(paths []string, f func()) {
+	job := app.nav.pasteQueue.addPaths(paths)
+
+	runAsync(func() {
+		if n := app.nav.pasteQueue.waiting(job); n > 0 {
+			msg := fmt.Sprintf("Waiting for %d earlier operations", n)
+			app.ui.exprChan <- &callExpr{"echo", []string{msg}, 1}
+		}
+		app.nav.pasteQueue.run(job, func([]string) { f() })
+	})
+}
+
+func (app *app) reloadAfter(op string) {
+	if gSingleMode {
+		app.nav.renew()
+		app.ui.loadFile(app, true)
+	} else if err := remote("send load"); err != nil {
+		app.ui.exprChan <- &callExpr{"echoerr", []string{op + ": " + err.Error()}, 1}
+	}
+}
+
+func (app *app) splitFile(arg string, dryRun bool) {
+	size, err := parseSize(arg)
+	if err != nil {
+		app.ui.echoerrf("split-file: %s", err)
+		return
+	}
+
+	curr, err := app.nav.currFile()
+	if err != nil {
+		app.ui.echoerr("split-file: no file selected")
+		return
+	}
+
+	path := curr.path
+	n, err := partCount(curr.Size(), size)
+	if err == nil && !curr.Mode().IsRegular() {
+		err = fmt.Errorf("not a regular file: %s", curr.Name())
+	}
+	if err != nil {
+		app.ui.echoerrf("split-file: %s", err)
+		return
+	}
+
+	paths := []string{path, checksumPath(path)}
+	for i := 1; i <= n; i++ {
+		paths = append(paths, partName(path, i))
+	}
+
+	if dryRun {
+		var plan []string
+		for i := 1; i <= n; i++ {
+			plan = append(plan, fmt.Sprintf("split '%s' into '%s' (part %d of %d)", path, partName(path, i), i, n))
+		}
+		plan = append(plan, fmt.Sprintf("write the checksums of '%s' to '%s'", path, checksumPath(path)))
+		app.showDryRun("split-file", plan)
+		return
+	}
+
+	app.runQueued(paths, func() {
+		start := time.Now()
+		r := newReport("split", filepath.Dir(path))
+
+		total := curr.Size()
+		app.nav.copyTotalChan <- total
+		parts, err := splitFile(path, size, app.nav.copyBytesChan)
+		app.nav.copyTotalChan <- -total
+
+		errCount := 0
+		var reasons []string
+		if err != nil {
+			errCount++
+			reasons = append(reasons, err.Error())
+			app.ui.exprChan <- &callExpr{"echoerr", []string{"split-file: " + err.Error()}, 1}
+		} else {
+			r.detail = fmt.Sprintf("%d parts of %s", len(parts)-1, humanize(size))
+		}
+		r.add(path, reasons)
+
+		app.audit("split", []string{path}, filepath.Dir(path), start, errCount)
+		app.finishReport(r)
+		app.reloadAfter("split-file")
+
+		if errCount == 0 {
+			msg := fmt.Sprintf("\033[0;32mSplit '%s' into %s\033[0m", curr.Name(), r.detail)
+			app.ui.exprChan <- &callExpr{"echo", []string{msg}, 1}
+		}
+	})
+}
+
+// This function returns the parts to join, which are the selected files in
+// natural order or the parts of the split file of the current part.
+func (app *app) joinList() ([]string, error) {
+	if sel := app.nav.currSelections(); len(sel) != 0 {
+		sel = slices.Clone(sel)
+		slices.SortFunc(sel, func(a, b string) int {
+			switch {
+			case naturalLess(a, b):
+				return -1
+			case naturalLess(b, a):
+				return 1
+			}
+			return 0
+		})
+		return sel, nil
+	}
+
+	curr, err := app.nav.currFile()
+	if err != nil {
+		return nil, errors.New("no file selected")
+	}
+
+	base, ok := partBase(curr.path)
+	if !ok {
+		return nil, fmt.Errorf("not a part of a split file: %s", curr.Name())
+	}
+
+	parts := splitParts(base)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("first part is missing: %s", filepath.Base(partName(base, 1)))
+	}
+	return parts, nil
+}
+
+func (app *app) joinFiles(output string, dryRun bool) {
+	parts, err := app.joinList()
+	if err != nil {
+		app.ui.echoerrf("join-files: %s", err)
+		return
+	}
+
+	dir := app.nav.currDir().path
+	if output == "" {
+		base, ok := partBase(parts[0])
+		if !ok {
+			app.ui.echoerr("join-files: output file is required")
+			return
+		}
+		output = filepath.Base(base)
+	}
+	output = replaceTilde(output)
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+
+	if _, err := os.Lstat(output); err == nil {
+		app.ui.echoerrf("join-files: file already exists: %s", output)
+		return
+	}
+
+	paths := append(slices.Clone(parts), output)
+	if base, ok := partBase(parts[0]); ok {
+		paths = append(paths, checksumPath(base))
+	}
+
+	if dryRun {
+		var plan []string
+		for _, part := range parts {
+			plan = append(plan, fmt.Sprintf("join '%s' into '%s'", part, output))
+		}
+		app.showDryRun("join-files", plan)
+		return
+	}
+
+	for _, part := range parts {
+		delete(app.nav.selections, part)
+	}
+	app.ui.loadFileInfo(app.nav)
+
+	app.runQueued(paths, func() {
+		start := time.Now()
+		r := newReport("join", filepath.Dir(output))
+
+		total := filesSize(parts)
+		app.nav.copyTotalChan <- total
+		verified, err := joinFiles(parts, output, app.nav.copyBytesChan)
+		app.nav.copyTotalChan <- -total
+
+		errCount := 0
+		for _, part := range parts {
+			var reasons []string
+			if err != nil {
+				reasons = append(reasons, err.Error())
+			}
+			r.add(part, reasons)
+		}
+		if err != nil {
+			errCount++
+			app.ui.exprChan <- &callExpr{"echoerr", []string{"join-files: " + err.Error()}, 1}
+		} else if verified {
+			r.detail = "checksums verified"
+		} else {
+			r.detail = "no checksums"
+		}
+
+		app.audit("join", parts, output, start, errCount)
+		app.finishReport(r)
+		app.reloadAfter("join-files")
+
+		if errCount == 0 {
+			msg := fmt.Sprintf("\033[0;32mJoined %d parts into '%s' (%s)\033[0m", len(parts), filepath.Base(output), r.detail)
+			app.ui.exprChan <- &callExpr{"echo", []string{msg}, 1}
+		}
+	})
+}
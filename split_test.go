@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPartCount(t *testing.T) {
+	tests := []struct {
+		total int64
+		size  int64
+		exp   int
+		err   bool
+	}{
+		{10, 3, 4, false},
+		{9, 3, 3, false},
+		{4, 3, 2, false},
+		{3, 3, 0, true},
+		{10, 0, 0, true},
+		{1000, 1, 0, true},
+		{999, 1, 999, false},
+	}
+
+	for _, test := range tests {
+		got, err := partCount(test.total, test.size)
+		if (err != nil) != test.err || got != test.exp {
+			t.Errorf("at input '%d' and '%d' expected '%d' but got '%d' (%v)", test.total, test.size, test.exp, got, err)
+		}
+	}
+}
+
+func TestPartBase(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp string
+		ok  bool
+	}{
+		{"/foo/bar.001", "/foo/bar", true},
+		{"/foo/bar.tar.gz.012", "/foo/bar.tar.gz", true},
+		{"/foo/bar.01", "", false},
+		{"/foo/bar.0001", "", false},
+		{"/foo/.001", "/foo/", true},
+		{"/foo/bar", "", false},
+	}
+
+	for _, test := range tests {
+		if got, ok := partBase(test.s); got != test.exp || ok != test.ok {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.s, test.exp, got)
+		}
+	}
+}
+
+func drainNums() (chan int64, func() int64) {
+	nums := make(chan int64)
+	done := make(chan int64)
+	go func() {
+		var total int64
+		for n := range nums {
+			total += n
+		}
+		done <- total
+	}()
+	return nums, func() int64 {
+		close(nums)
+		return <-done
+	}
+}
+
+func TestSplitJoin(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "foo")
+	data := bytes.Repeat([]byte("0123456789"), 25)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	nums, wait := drainNums()
+	parts, err := splitFile(path, 100, nums)
+	if total := wait(); err != nil || total != int64(len(data)) {
+		t.Fatalf("split: %v (%d bytes written)", err, total)
+	}
+
+	exp := []string{path + ".001", path + ".002", path + ".003", path + ".sha256"}
+	if !reflect.DeepEqual(parts, exp) {
+		t.Errorf("expected parts '%v' but got '%v'", exp, parts)
+	}
+	if got := splitParts(path); !reflect.DeepEqual(got, exp[:3]) {
+		t.Errorf("expected parts '%v' but got '%v'", exp[:3], got)
+	}
+
+	sums, err := readChecksums(path + ".sha256")
+	if err != nil || len(sums) != 4 {
+		t.Fatalf("expected 4 checksums but got '%v' (%v)", sums, err)
+	}
+
+	// existing parts are not overwritten
+	if _, err := splitFile(path, 100, nil); err == nil {
+		t.Errorf("expected error for existing parts")
+	}
+
+	output := filepath.Join(dir, "joined")
+	nums, wait = drainNums()
+	verified, err := joinFiles(exp[:3], output, nums)
+	wait()
+	if err != nil || !verified {
+		t.Fatalf("join: %v (verified: %t)", err, verified)
+	}
+	if got, _ := os.ReadFile(output); !bytes.Equal(got, data) {
+		t.Errorf("joined file differs from the original file")
+	}
+
+	// a missing part is detected with the checksum of the whole file
+	nums, wait = drainNums()
+	_, err = joinFiles(exp[:2], filepath.Join(dir, "missing"), nums)
+	wait()
+	if err == nil {
+		t.Errorf("expected checksum error for a missing part")
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected output to be removed after an error")
+	}
+
+	// a corrupted part is detected with its own checksum
+	if err := os.WriteFile(exp[1], bytes.Repeat([]byte("x"), 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	nums, wait = drainNums()
+	_, err = joinFiles(exp[:3], filepath.Join(dir, "corrupt"), nums)
+	wait()
+	if err == nil || err.Error() != "checksum mismatch: foo.002" {
+		t.Errorf("expected checksum mismatch but got '%v'", err)
+	}
+
+	// parts are joined without verification when there are no checksums
+	os.Remove(path + ".sha256")
+	nums, wait = drainNums()
+	verified, err = joinFiles(exp[:3], filepath.Join(dir, "unverified"), nums)
+	wait()
+	if err != nil || verified {
+		t.Errorf("expected join without verification but got '%t' (%v)", verified, err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Existing destination files overwritten by pasting (e.g. with the 'overwrite'
+// strategy of 'retry-failed') are not removed before the operation starts.
+// Instead, they are moved into a staging directory created for the operation
+// in the destination directory, which is named with the '.lf-overwrite-'
+// prefix. When the operation finishes, the staged originals of the files that
+// are pasted successfully are removed, and the originals of the files that
+// failed or were cancelled are moved back in place of partial files. The
+// staging directory is then removed, unless lf is interrupted during the
+// operation, in which case the originals are left in the staging directory.
+// This way, either the old or the new file is always kept.
+
+const gStagingPrefix = ".lf-overwrite-"
+
+type stagedFile struct {
+	src    string
+	dst    string
+	staged string
+}
+
+type staging struct {
+	dir   string
+	files []stagedFile
+}
+
+// This function moves the existing destination files of the given sources
+// into a new staging directory in the destination directory. No directory is
+// created when no destination file exists. When a file can not be staged,
+// the files staged so far are moved back and an error is returned.
+func stageOverwrites(srcs []string, dstDir string) (*staging, error) {
+	stg := &staging{}
+
+	for _, src := range srcs {
+		dst := filepath.Join(dstDir, filepath.Base(src))
+		if dst == src {
+			continue
+		}
+
+		dstStat, err := os.Lstat(dst)
+		if err != nil {
+			continue
+		}
+		if srcStat, err := os.Lstat(src); err == nil && os.SameFile(srcStat, dstStat) {
+			continue
+		}
+
+		if stg.dir == "" {
+			dir, err := os.MkdirTemp(dstDir, gStagingPrefix)
+			if err != nil {
+				return nil, fmt.Errorf("creating staging directory: %s", err)
+			}
+			stg.dir = dir
+		}
+
+		staged := filepath.Join(stg.dir, filepath.Base(dst))
+		if err := os.Rename(dst, staged); err != nil {
+			stg.restore()
+			return nil, fmt.Errorf("staging: %s", err)
+		}
+		stg.files = append(stg.files, stagedFile{src, dst, staged})
+	}
+
+	return stg, nil
+}
+
+// This function moves all staged files back to their destinations and removes
+// the staging directory.
+func (stg *staging) restore() {
+	for _, f := range stg.files {
+		os.Rename(f.staged, f.dst)
+	}
+	if stg.dir != "" {
+		os.Remove(stg.dir)
+	}
+}
+
+// This function removes the staged originals of the files pasted successfully,
+// which are the ones not failed or skipped in the given report, and moves the
+// other originals back to their destinations. The report is nil when the
+// operation did not run at all. The staging directory is kept when any
+// original can not be removed or moved back.
+func (stg *staging) finish(r *report) error {
+	if stg.dir == "" {
+		return nil
+	}
+
+	var errs []error
+	for _, f := range stg.files {
+		if r != nil && !r.unfinished(f.src) {
+			if err := removeAll(f.staged); err != nil {
+				errs = append(errs, fmt.Errorf("removing staged file: %s", err))
+			}
+			continue
+		}
+
+		if _, err := os.Lstat(f.dst); err == nil {
+			if err := removeAll(f.dst); err != nil {
+				errs = append(errs, fmt.Errorf("removing partial file: %s", err))
+				continue
+			}
+		}
+		if err := os.Rename(f.staged, f.dst); err != nil {
+			errs = append(errs, fmt.Errorf("restoring staged file: %s", err))
+		}
+	}
+
+	if len(errs) != 0 {
+		errs = append(errs, fmt.Errorf("originals are kept in %s", stg.dir))
+		return errors.Join(errs...)
+	}
+
+	return os.Remove(stg.dir)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaging(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	write := func(path, data string) {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(path string) string {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		write(filepath.Join(srcDir, name), "new "+name)
+	}
+	write(filepath.Join(dstDir, "a"), "old a")
+	write(filepath.Join(dstDir, "b"), "old b")
+
+	srcs := []string{filepath.Join(srcDir, "a"), filepath.Join(srcDir, "b"), filepath.Join(srcDir, "c")}
+
+	stg, err := stageOverwrites(srcs, dstDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stg.files) != 2 {
+		t.Fatalf("expected 2 staged files but got %d", len(stg.files))
+	}
+	if _, err := os.Lstat(filepath.Join(dstDir, "a")); !os.IsNotExist(err) {
+		t.Errorf("expected destination to be moved into the staging directory")
+	}
+	if got := read(filepath.Join(stg.dir, "a")); got != "old a" {
+		t.Errorf("expected staged original 'old a' but got '%s'", got)
+	}
+
+	// 'a' is pasted, whereas 'b' fails after writing a partial file
+	write(filepath.Join(dstDir, "a"), "new a")
+	write(filepath.Join(dstDir, "b"), "partial")
+	r := newReport("copy", dstDir)
+	r.add(srcs[0], nil)
+	r.add(srcs[1], []string{"copy: no space left on device"})
+
+	if err := stg.finish(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := read(filepath.Join(dstDir, "a")); got != "new a" {
+		t.Errorf("expected pasted file 'new a' but got '%s'", got)
+	}
+	if got := read(filepath.Join(dstDir, "b")); got != "old b" {
+		t.Errorf("expected restored original 'old b' but got '%s'", got)
+	}
+	if _, err := os.Lstat(stg.dir); !os.IsNotExist(err) {
+		t.Errorf("expected staging directory to be removed")
+	}
+
+	stg, err = stageOverwrites(srcs, dstDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stg.finish(nil); err != nil {
+		t.Fatal(err)
+	}
+	if read(filepath.Join(dstDir, "a")) != "new a" || read(filepath.Join(dstDir, "b")) != "old b" {
+		t.Errorf("expected originals to be restored when nothing is pasted")
+	}
+
+	stg, err = stageOverwrites([]string{filepath.Join(srcDir, "c")}, dstDir)
+	if err != nil || stg.dir != "" {
+		t.Errorf("expected no staging directory without existing destinations")
+	}
+}
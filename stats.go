@@ -0,0 +1,188 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Statistics of the current directory are computed in the background with the
+// 'stats' command and shown in the pager when the walk is finished, to help
+// deciding what to clean up. Files are grouped by their extensions, while
+// symbolic links and other special files are grouped by their types, and each
+// group is shown with the number of files, their total size and a bar of its
+// share of the total size, largest groups first. The directory is walked
+// recursively as in 'dirsize', skipping directories on other filesystems when
+// the 'onefilesystem' option is enabled, and unreadable directories are
+// counted and skipped. Starting another walk or 'stats --cancel' stops the
+// earlier walk.
+
+const gStatsBarWidth = 30
+
+type statsGroup struct {
+	name  string
+	count int
+	size  int64
+}
+
+type dirStats struct {
+	groups  []statsGroup
+	files   int
+	dirs    int
+	size    int64
+	skipped int
+}
+
+// This function returns the name of the group of the given file, which is the
+// lowercase extension for regular files. Leading dots are not considered as
+// extensions so that hidden files such as '.bashrc' have no extension.
+func statsGroupName(info os.FileInfo) string {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return "(symlink)"
+	case !info.Mode().IsRegular():
+		return "(other)"
+	}
+
+	ext := filepath.Ext(strings.TrimLeft(info.Name(), "."))
+	if ext == "" {
+		return "(none)"
+	}
+	return strings.ToLower(ext)
+}
+
+// This function walks the given directory recursively and returns its
+// statistics, with groups sorted by their total sizes.
+func walkStats(path string, cancel <-chan struct{}) (*dirStats, error) {
+	st := &dirStats{}
+	groups := make(map[string]*statsGroup)
+
+	var err error
+	st.dirs, st.skipped, err = walkFiles(path, cancel, func(_ string, info os.FileInfo) {
+		name := statsGroupName(info)
+		g, ok := groups[name]
+		if !ok {
+			g = &statsGroup{name: name}
+			groups[name] = g
+		}
+		g.count++
+		g.size += info.Size()
+		st.files++
+		st.size += info.Size()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range groups {
+		st.groups = append(st.groups, *g)
+	}
+	slices.SortFunc(st.groups, func(a, b statsGroup) int {
+		if c := cmp.Compare(b.size, a.size); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.count, a.count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.name, b.name)
+	})
+
+	return st, nil
+}
+
+// This function returns the title of the given statistics of the given
+// directory.
+func (st *dirStats) title(path string) string {
+	title := fmt.Sprintf("stats: %s (%d files in %d directories, %s)", path, st.files, st.dirs, humanize(st.size))
+	if st.skipped > 0 {
+		title += fmt.Sprintf(", %d unreadable", st.skipped)
+	}
+	return title
+}
+
+// This function returns the lines shown for the given statistics, which are a
+// header followed by a line for each group.
+func (st *dirStats) lines() []string {
+	width := len("extension")
+	for _, g := range st.groups {
+		width = max(width, runeSliceWidth([]rune(g.name)))
+	}
+
+	lines := []string{fmt.Sprintf("%-*s %8s %8s %6s", width, "extension", "files", "size", "share")}
+	for _, g := range st.groups {
+		var share float64
+		if st.size > 0 {
+			share = float64(g.size) / float64(st.size)
+		}
+		bar := strings.Repeat("█", int(share*gStatsBarWidth+0.5))
+		lines = append(lines, fmt.Sprintf("%-*s %8d %8s %5.1f%% %s", width, g.name, g.count, humanize(g.size), share*100, bar))
+	}
+	return lines
+}
+
+func (app *app) stats() {
+	path := app.nav.currDir().path
+
+	if gHeadless {
+		st, err := walkStats(path, nil)
+		if err != nil {
+			app.ui.echoerrf("stats: %s", err)
+			return
+		}
+		app.ui.echomsg(st.title(path))
+		for _, line := range st.lines() {
+			app.ui.echomsg(line)
+		}
+		return
+	}
+
+	if app.nav.statsCancel != nil {
+		close(app.nav.statsCancel)
+	}
+	cancel := make(chan struct{})
+	app.nav.statsCancel = cancel
+
+	go func() {
+		st, err := walkStats(path, cancel)
+		if err == errDirSizeCancelled {
+			return
+		}
+		if err != nil {
+			app.ui.exprChan <- &callExpr{"echoerr", []string{"stats: " + err.Error()}, 1}
+			return
+		}
+		app.nav.stats.set(st.title(path), st.lines())
+		app.ui.exprChan <- &callExpr{"stats-results", nil, 1}
+	}()
+
+	app.ui.echomsg("stats: walking " + path)
+}
+
+func (app *app) cancelStats() {
+	if app.nav.statsCancel == nil {
+		app.ui.echoerr("stats: no statistics are being computed")
+		return
+	}
+	close(app.nav.statsCancel)
+	app.nav.statsCancel = nil
+}
+
+func (app *app) statsResults() {
+	title, lines := app.nav.stats.get()
+	if lines == nil {
+		app.ui.echoerr("stats-results: no statistics computed yet")
+		return
+	}
+
+	if gHeadless {
+		for _, line := range lines {
+			app.ui.echomsg(line)
+		}
+		return
+	}
+
+	app.ui.pager = newPager(title, strings.Join(lines, "\n"))
+}
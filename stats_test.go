@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkStats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{
+		"a.txt":         10,
+		"sub/b.TXT":     20,
+		"sub/c.go":      100,
+		"sub/deep/.bar": 5,
+		"Makefile":      5,
+	}
+	for name, size := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := walkStats(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.files != 6 || st.dirs != 2 {
+		t.Errorf("expected '6' files in '2' directories but got '%d' in '%d'", st.files, st.dirs)
+	}
+
+	exp := []statsGroup{{".go", 1, 100}, {".txt", 2, 30}, {"(none)", 2, 10}, {"(symlink)", 1, 5}}
+	if len(st.groups) != len(exp) {
+		t.Fatalf("expected groups '%v' but got '%v'", exp, st.groups)
+	}
+	for i, g := range exp {
+		if st.groups[i] != g {
+			t.Errorf("at index %d expected group '%v' but got '%v'", i, g, st.groups[i])
+		}
+	}
+
+	lines := st.lines()
+	if len(lines) != 1+len(exp) {
+		t.Fatalf("expected %d lines but got '%v'", 1+len(exp), lines)
+	}
+	if !strings.HasPrefix(lines[1], ".go") || !strings.Contains(lines[1], "69.0%") || strings.Count(lines[1], "█") != 21 {
+		t.Errorf("expected '.go' with a share of '69.0%%' but got '%s'", lines[1])
+	}
+
+	cancel := make(chan struct{})
+	close(cancel)
+	if _, err := walkStats(dir, cancel); err != errDirSizeCancelled {
+		t.Errorf("expected cancellation but got '%v'", err)
+	}
+}
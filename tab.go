@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Tabs keep separate states of navigation in a single client, which are the
+// current directory with its filter and the current file, the selected files,
+// the jump list used by 'jump-prev' and 'jump-next', and the sort options.
+// Only the state of the current tab is used by the rest of the code, and it
+// is saved in the list of tabs when switching to another tab, so that there
+// is no extra cost when tabs are not used. The list of tabs is empty until
+// the first 'tab-new' command, and there is always a single tab otherwise.
+// The tab bar is shown in a dedicated row below the prompt line when there
+// is more than a single tab, or at the start of the ruler with the 'tabbar'
+// option set to 'status'. The labels of the tabs have indicators for the
+// number of running jobs (i.e. pastes and deletes) started from the tab ('&'),
+// the number of jobs with errors which are finished while another tab is
+// shown ('!'), and the number of selected files ('*'). Tabs are identified by
+// ids for the jobs, since the state of the current tab is saved as a new value
+// when switching to another tab.
+
+type tab struct {
+	id           int
+	errors       int
+	path         string
+	file         string
+	filter       []string
+	selections   map[string]int
+	selectionInd int
+	jumpList     []string
+	jumpListInd  int
+	sortby       sortMethod
+	dirfirst     bool
+	dironly      bool
+	hidden       bool
+	reverse      bool
+}
+
+// This function returns the state of the current tab.
+func (nav *nav) saveTab() *tab {
+	dir := nav.currDir()
+
+	t := &tab{
+		id:           nav.tabID,
+		path:         dir.path,
+		filter:       dir.filter,
+		selections:   nav.selections,
+		selectionInd: nav.selectionInd,
+		jumpList:     nav.jumpList,
+		jumpListInd:  nav.jumpListInd,
+		sortby:       gOpts.sortby,
+		dirfirst:     gOpts.dirfirst,
+		dironly:      gOpts.dironly,
+		hidden:       gOpts.hidden,
+		reverse:      gOpts.reverse,
+	}
+
+	if curr, err := nav.currFile(); err == nil {
+		t.file = curr.Name()
+	}
+
+	return t
+}
+
+// This function returns a new tab in the given directory with the sort
+// options of the current tab and an empty selection and jump list.
+func newTab(path string) *tab {
+	return &tab{
+		path:        path,
+		selections:  make(map[string]int),
+		jumpListInd: -1,
+		sortby:      gOpts.sortby,
+		dirfirst:    gOpts.dirfirst,
+		dironly:     gOpts.dironly,
+		hidden:      gOpts.hidden,
+		reverse:     gOpts.reverse,
+	}
+}
+
+// This function returns the labels of the tabs in the tab bar, which are the
+// numbers of the tabs with the names of their directories, followed by the
+// indicators of the tabs that are not zero.
+func (nav *nav) tabLabels() []string {
+	jobs := nav.pasteQueue.tabJobs()
+
+	labels := make([]string, len(nav.tabs))
+	for i, t := range nav.tabs {
+		path, id, selections := t.path, t.id, len(t.selections)
+		if i == nav.tabInd {
+			path, id, selections = nav.currDir().path, nav.tabID, len(nav.selections)
+		}
+		name := filepath.Base(path)
+		if path == gUser.HomeDir {
+			name = "~"
+		}
+
+		label := fmt.Sprintf("%d:%s", i+1, name)
+		for _, ind := range []struct {
+			sym string
+			n   int
+		}{{"&", jobs[id]}, {"!", t.errors}, {"*", selections}} {
+			if ind.n > 0 {
+				label += fmt.Sprintf(" %s%d", ind.sym, ind.n)
+			}
+		}
+		labels[i] = label
+	}
+	return labels
+}
+
+// This function records a job with errors started from the tab with the given
+// id, which is shown in the tab bar until the tab is shown. Errors of the
+// current tab are not recorded as they are already shown.
+func (app *app) tabError(args []string) {
+	if len(args) != 1 {
+		return
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id == app.nav.tabID {
+		return
+	}
+	for _, t := range app.nav.tabs {
+		if t.id == id {
+			t.errors++
+		}
+	}
+}
+
+// This function returns the tab bar with the current tab in reverse video.
+func (nav *nav) tabBar() string {
+	var b strings.Builder
+	for i, label := range nav.tabLabels() {
+		if i == nav.tabInd {
+			fmt.Fprintf(&b, "\033[7m %s \033[0m", label)
+		} else {
+			fmt.Fprintf(&b, " %s ", label)
+		}
+	}
+	return b.String()
+}
+
+// This function reports whether the tab bar is shown in a dedicated row.
+func (nav *nav) tabRow() bool {
+	return gOpts.tabbar == "row" && len(nav.tabs) > 1
+}
+
+// This function updates the windows when the tab bar is shown or hidden.
+func (app *app) updateTabRow() {
+	if app.ui.tabRow == app.nav.tabRow() {
+		return
+	}
+	app.ui.tabRow = app.nav.tabRow()
+	app.ui.renew()
+	if app.nav.height != app.ui.wins[0].h {
+		app.nav.height = app.ui.wins[0].h
+		clear(app.nav.regCache)
+	}
+	for _, dir := range app.nav.dirs {
+		dir.boundPos(app.nav.height)
+	}
+	if hasGraphics() {
+		app.ui.sxScreen.forceClear = true
+	}
+}
+
+// This function switches to the tab with the given index, and the current
+// tab is saved first unless it is closed.
+func (app *app) loadTab(ind int, save bool) error {
+	if save {
+		app.nav.tabs[app.nav.tabInd] = app.nav.saveTab()
+	}
+
+	t := app.nav.tabs[ind]
+	t.errors = 0
+	app.nav.tabInd = ind
+	app.nav.tabID = t.id
+
+	gOpts.sortby = t.sortby
+	gOpts.dirfirst = t.dirfirst
+	gOpts.dironly = t.dironly
+	gOpts.hidden = t.hidden
+	gOpts.reverse = t.reverse
+
+	app.nav.selections = t.selections
+	app.nav.selectionInd = t.selectionInd
+	app.nav.jumpList = t.jumpList
+	app.nav.jumpListInd = t.jumpListInd
+
+	resetIncCmd(app)
+	preChdir(app)
+
+	err := app.nav.cd(t.path)
+
+	dir := app.nav.currDir()
+	dir.filter = slices.Clone(t.filter)
+	app.nav.sort()
+	app.ui.sort()
+	if t.file != "" {
+		dir.sel(t.file, app.nav.height)
+	}
+
+	restartIncCmd(app)
+	onChdir(app)
+
+	app.updateTabRow()
+	app.ui.loadFile(app, true)
+	app.ui.loadFileInfo(app.nav)
+
+	return err
+}
+
+func (app *app) tabNew(args []string) {
+	path := app.nav.currDir().path
+	switch len(args) {
+	case 0:
+	case 1:
+		path = replaceTilde(args[0])
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(app.nav.currDir().path, path)
+		}
+	default:
+		app.ui.echoerr("tab-new: only a single directory is allowed")
+		return
+	}
+
+	path = filepath.Clean(path)
+	if s, err := os.Stat(path); err != nil {
+		app.ui.echoerrf("tab-new: %s", err)
+		return
+	} else if !s.IsDir() {
+		app.ui.echoerrf("tab-new: not a directory: %s", path)
+		return
+	}
+
+	if len(app.nav.tabs) == 0 {
+		app.nav.tabs = []*tab{app.nav.saveTab()}
+		app.nav.tabInd = 0
+	}
+
+	t := newTab(path)
+	app.nav.tabSeq++
+	t.id = app.nav.tabSeq
+
+	ind := app.nav.tabInd + 1
+	app.nav.tabs = slices.Insert(app.nav.tabs, ind, t)
+
+	if err := app.loadTab(ind, true); err != nil {
+		app.ui.echoerrf("tab-new: %s", err)
+	}
+}
+
+func (app *app) tabClose() {
+	if len(app.nav.tabs) <= 1 {
+		app.ui.echoerr("tab-close: cannot close the last tab")
+		return
+	}
+
+	app.nav.tabs = slices.Delete(app.nav.tabs, app.nav.tabInd, app.nav.tabInd+1)
+	ind := min(app.nav.tabInd, len(app.nav.tabs)-1)
+
+	if err := app.loadTab(ind, false); err != nil {
+		app.ui.echoerrf("tab-close: %s", err)
+	}
+}
+
+// This function switches to the next tab (or the previous one for negative
+// distances), wrapping around at the ends of the list.
+func (app *app) tabNext(name string, dist int) {
+	n := len(app.nav.tabs)
+	if n <= 1 {
+		return
+	}
+
+	ind := ((app.nav.tabInd+dist)%n + n) % n
+	if err := app.loadTab(ind, true); err != nil {
+		app.ui.echoerrf("%s: %s", name, err)
+	}
+}
+
+// This function moves the current tab to the given position, which is either
+// a tab number, or a relative position with a sign (e.g. '+1' or '-1').
+func (app *app) tabMove(args []string) {
+	if len(args) != 1 {
+		app.ui.echoerr("tab-move: requires a tab number")
+		return
+	}
+
+	n := len(app.nav.tabs)
+	if n <= 1 {
+		return
+	}
+
+	num, err := strconv.Atoi(args[0])
+	if err != nil {
+		app.ui.echoerrf("tab-move: %s", err)
+		return
+	}
+
+	ind := num - 1
+	if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+		ind = app.nav.tabInd + num
+	}
+	ind = max(0, min(ind, n-1))
+
+	t := app.nav.tabs[app.nav.tabInd]
+	app.nav.tabs = slices.Delete(app.nav.tabs, app.nav.tabInd, app.nav.tabInd+1)
+	app.nav.tabs = slices.Insert(app.nav.tabs, ind, t)
+	app.nav.tabInd = ind
+}
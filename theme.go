@@ -0,0 +1,112 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+)
+
+// Besides the entries matched against files, colors can be given for a few
+// theme classes shading parts of the directory windows: 'altrow' for every
+// other row, 'cursorline' for the whole row of the cursor in the active window,
+// and 'cursorcolumn' for the 'info' field of the first sort key of the
+// directory (e.g. 'size' when sorted by size). They are applied underneath the
+// shown text, so that only the colors missing from the text (usually the
+// background) are filled in and the attributes are added, while cells shown in
+// reverse video (e.g. the cursor with the default 'cursoractivefmt') are left
+// as they are. Colors not supported by the terminal are dropped from these
+// classes instead of being approximated by the nearest basic color, since a
+// light shade approximated by white would make the text unreadable on
+// terminals with 16 colors.
+
+// This function reports whether the given color can be shown on a terminal
+// with the given number of colors.
+func supportsColor(c tcell.Color, colors int) bool {
+	switch {
+	case !c.Valid():
+		return true
+	case c.IsRGB():
+		return colors >= 256
+	default:
+		return int(c-tcell.ColorValid) < colors
+	}
+}
+
+// This function returns the style of the given theme class without the colors
+// not supported by a terminal with the given number of colors. It reports
+// false when the class is not defined or nothing is left of its style.
+func (sm styleMap) theme(class string, colors int) (tcell.Style, bool) {
+	st, ok := sm.styles[class]
+	if !ok {
+		return tcell.StyleDefault, false
+	}
+
+	fg, bg, _ := st.Decompose()
+	if !supportsColor(fg, colors) {
+		st = st.Foreground(tcell.ColorDefault)
+	}
+	if !supportsColor(bg, colors) {
+		st = st.Background(tcell.ColorDefault)
+	}
+
+	return st, st != tcell.StyleDefault
+}
+
+// This function returns the style of a cell with the given theme style
+// applied underneath, filling in the missing colors and adding the
+// attributes. Cells shown in reverse video are returned unchanged.
+func shadeStyle(cell, st tcell.Style) tcell.Style {
+	fg, bg, attr := cell.Decompose()
+	if attr&tcell.AttrReverse != 0 {
+		return cell
+	}
+
+	stfg, stbg, stattr := st.Decompose()
+	if fg == tcell.ColorDefault {
+		cell = cell.Foreground(stfg)
+	}
+	if bg == tcell.ColorDefault {
+		cell = cell.Background(stbg)
+	}
+
+	// underlines are kept separately from the other attributes
+	cell = cell.Attributes(attr | stattr&^tcell.AttrUnderline)
+	if stattr&tcell.AttrUnderline != 0 && attr&tcell.AttrUnderline == 0 {
+		cell = cell.Underline(true)
+	}
+	return cell
+}
+
+// This function applies the given theme style underneath the cells already
+// printed in the given range of a row of the window.
+func (win *win) shade(screen tcell.Screen, x, y, w int, st tcell.Style) {
+	for end := min(x+w, win.w); x < end; {
+		mainc, combc, cell, width := screen.GetContent(win.x+x, win.y+y)
+		screen.SetContent(win.x+x, win.y+y, mainc, combc, shadeStyle(cell, st))
+		x += max(width, 1)
+	}
+}
+
+// This function returns the theme style of a row with the given properties,
+// where the 'cursorline' class takes precedence over the 'altrow' class. It
+// reports false when the row is not shaded.
+func (dirStyle *dirStyle) rowStyle(cursor, odd bool, colors int) (tcell.Style, bool) {
+	if cursor && dirStyle.role == Active {
+		if st, ok := dirStyle.colors.theme("cursorline", colors); ok {
+			return st, true
+		}
+	}
+	if odd {
+		return dirStyle.colors.theme("altrow", colors)
+	}
+	return tcell.StyleDefault, false
+}
+
+// This function returns the 'info' field of the first sort key of the
+// directory, which is highlighted with the 'cursorcolumn' class, or an empty
+// string when the key has no field (e.g. 'natural').
+func activeInfoField(dir *dir) string {
+	switch key := dir.sortby.keys()[0]; key {
+	case sizeSort, timeSort, atimeSort, btimeSort, ctimeSort, customSort:
+		return string(key)
+	}
+	return ""
+}
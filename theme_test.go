@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestTheme(t *testing.T) {
+	var sm styleMap
+	sm.styles = make(map[string]tcell.Style)
+	sm.origins = make(map[string]ruleOrigin)
+	sm.parseGNU("altrow=48;5;236:cursorline=1;48;2;40;40;40:cursorcolumn=44", "test")
+
+	none := tcell.StyleDefault
+
+	tests := []struct {
+		class  string
+		colors int
+		exp    tcell.Style
+		ok     bool
+	}{
+		{"altrow", 256, none.Background(tcell.PaletteColor(236)), true},
+		{"altrow", 16, none, false},
+		{"cursorline", 1 << 24, none.Background(tcell.NewRGBColor(40, 40, 40)).Bold(true), true},
+		{"cursorline", 16, none.Bold(true), true},
+		{"cursorcolumn", 8, none.Background(tcell.ColorNavy), true},
+		{"cursorcolumn", 0, none, false},
+		{"undefined", 256, none, false},
+	}
+
+	for _, test := range tests {
+		if st, ok := sm.theme(test.class, test.colors); st != test.exp || ok != test.ok {
+			t.Errorf("at input '%s' with %d colors expected '%v' and '%t' but got '%v' and '%t'",
+				test.class, test.colors, test.exp, test.ok, st, ok)
+		}
+	}
+}
+
+func TestShadeStyle(t *testing.T) {
+	none := tcell.StyleDefault
+	shade := none.Background(tcell.ColorGray).Foreground(tcell.ColorWhite).Italic(true)
+
+	tests := []struct {
+		cell tcell.Style
+		exp  tcell.Style
+	}{
+		{none, shade},
+		{none.Foreground(tcell.ColorBlue).Bold(true), none.Foreground(tcell.ColorBlue).Background(tcell.ColorGray).Bold(true).Italic(true)},
+		{none.Background(tcell.ColorRed), none.Background(tcell.ColorRed).Foreground(tcell.ColorWhite).Italic(true)},
+		{none.Reverse(true), none.Reverse(true)},
+	}
+
+	for _, test := range tests {
+		if got := shadeStyle(test.cell, shade); got != test.exp {
+			t.Errorf("at input '%v' expected '%v' but got '%v'", test.cell, test.exp, got)
+		}
+	}
+
+	if got := shadeStyle(none, none.Underline(true)); got != none.Underline(true) {
+		t.Errorf("expected an underline but got '%v'", got)
+	}
+}
+
+func TestActiveInfoField(t *testing.T) {
+	tests := []struct {
+		sortby sortMethod
+		exp    string
+	}{
+		{"natural", ""},
+		{"size", "size"},
+		{"time,name", "time"},
+		{"ext,size", ""},
+		{"custom", "custom"},
+	}
+
+	for _, test := range tests {
+		if got := activeInfoField(&dir{sortby: test.sortby}); got != test.exp {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.sortby, test.exp, got)
+		}
+	}
+}
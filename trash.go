@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Files can be moved to the trash with the 'trash' command instead of being
+// deleted permanently. The trash of the FreeDesktop.org trash specification
+// is used on Unix, and the Recycle Bin on Windows, so that trashed files can
+// also be restored with other file managers. Trashed files are listed with
+// the 'trash-list' command and moved back to their original paths with the
+// 'restore' command, which restores the files trashed by the last 'trash'
+// command when no path is given.
+
+var errTrashNotFound = errors.New("trash directory not found")
+
+// A trashed file is kept with the metadata file recording its original path
+// and the time of deletion.
+type trashItem struct {
+	path    string
+	deleted time.Time
+	file    string
+	info    string
+}
+
+// This function moves the trashed file back to its original path, creating
+// the parent directories when they are missing. Existing files are never
+// overwritten.
+func (item *trashItem) restore() error {
+	if _, err := os.Lstat(item.path); err == nil {
+		return fmt.Errorf("file exists: %s", item.path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(item.path), os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(item.file, item.path); err != nil {
+		return err
+	}
+
+	return os.Remove(item.info)
+}
+
+// This function returns the trashed files from the most recently deleted. Errors
+// of unreadable trash directories are returned along with the files found in
+// others.
+func trashItems() ([]*trashItem, error) {
+	items, err := listTrash()
+	slices.SortStableFunc(items, func(a, b *trashItem) int {
+		return b.deleted.Compare(a.deleted)
+	})
+	return items, err
+}
+
+// This function returns the most recently trashed file with the given original
+// path.
+func findTrash(items []*trashItem, path string) *trashItem {
+	for _, item := range items {
+		if item.path == path {
+			return item
+		}
+	}
+	return nil
+}
+
+// The files trashed by the last 'trash' command are kept to be restored with
+// the 'restore' command without arguments. They are written by the trash
+// operation running in the background, so they are kept behind a lock.
+type trashLog struct {
+	mutex sync.Mutex
+	last  []*trashItem
+}
+
+func (l *trashLog) set(items []*trashItem) {
+	l.mutex.Lock()
+	l.last = items
+	l.mutex.Unlock()
+}
+
+func (l *trashLog) get() []*trashItem {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.last
+}
+
+func (app *app) trash() {
+	list, err := app.nav.currFileOrSelections()
+	if err != nil {
+		app.ui.echoerrf("trash: %s", err)
+		return
+	}
+
+	if err := checkWritable(list...); err != nil {
+		app.ui.echoerrf("trash: %s", err)
+		return
+	}
+
+	app.nav.unselect()
+	app.trashFiles(list)
+}
+
+// This function moves the given files to the trash in the background after
+// earlier operations touching them are finished.
+func (app *app) trashFiles(list []string) {
+	app.runQueued(list, func() {
+		echo := &callExpr{"echoerr", []string{""}, 1}
+		start := time.Now()
+		r := newReport("trash", "")
+
+		var trashed []*trashItem
+		for _, path := range list {
+			pathStart := time.Now()
+			bytes := opLogSize(path)
+
+			var reasons []string
+			item, err := trashFile(path)
+			if err != nil {
+				echo.args[0] = fmt.Sprintf("[%d] trash: %s", len(r.failed)+1, err)
+				app.ui.exprChan <- echo
+				reasons = append(reasons, err.Error())
+			} else {
+				trashed = append(trashed, item)
+			}
+
+			r.add(path, reasons)
+			dst := ""
+			if item != nil {
+				dst = item.file
+			}
+			app.opLog("trash", path, dst, bytes, pathStart, false, reasons)
+		}
+
+		if len(trashed) > 0 {
+			app.nav.trashed.set(trashed)
+		}
+
+		j := &journalEntry{op: "trash"}
+		for _, item := range trashed {
+			j.files = append(j.files, journalFile{src: item.path, item: item})
+		}
+		app.nav.journal.record(j)
+
+		app.audit("trash", list, "", start, len(r.failed))
+		app.finishReport(r)
+		app.reloadAfter("trash")
+	})
+}
+
+// This function returns the trashed files to restore for the given paths, or
+// the files trashed by the last 'trash' command when no path is given.
+func (app *app) restoreItems(args []string) ([]*trashItem, error) {
+	if len(args) == 0 {
+		list := app.nav.trashed.get()
+		if len(list) == 0 {
+			return nil, errors.New("no files trashed yet, a path is required")
+		}
+		return list, nil
+	}
+
+	items, err := trashItems()
+	if len(items) == 0 && err != nil {
+		return nil, err
+	}
+
+	var list []*trashItem
+	for _, arg := range args {
+		path := replaceTilde(arg)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(app.nav.currDir().path, path)
+		}
+		item := findTrash(items, filepath.Clean(path))
+		if item == nil {
+			return nil, fmt.Errorf("not found in trash: %s", path)
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func (app *app) restore(args []string) {
+	list, err := app.restoreItems(args)
+	if err != nil {
+		app.ui.echoerrf("restore: %s", err)
+		return
+	}
+
+	var paths []string
+	for _, item := range list {
+		paths = append(paths, item.path)
+	}
+
+	app.runQueued(paths, func() {
+		echo := &callExpr{"echoerr", []string{""}, 1}
+		start := time.Now()
+		r := newReport("restore", "")
+
+		for _, item := range list {
+			itemStart := time.Now()
+			var reasons []string
+			if err := item.restore(); err != nil {
+				echo.args[0] = fmt.Sprintf("[%d] restore: %s", len(r.failed)+1, err)
+				app.ui.exprChan <- echo
+				reasons = append(reasons, err.Error())
+			}
+			r.add(item.path, reasons)
+			app.opLog("restore", item.file, item.path, opLogSize(item.file), itemStart, false, reasons)
+		}
+
+		if len(args) == 0 && len(r.failed) == 0 {
+			app.nav.trashed.set(nil)
+		}
+
+		app.audit("restore", paths, "", start, len(r.failed))
+		app.finishReport(r)
+		app.reloadAfter("restore")
+
+		if len(r.failed) == 0 {
+			msg := fmt.Sprintf("\033[0;32mRestored %d files\033[0m", r.done)
+			app.ui.exprChan <- &callExpr{"echo", []string{msg}, 1}
+		}
+	})
+}
+
+func (app *app) trashList() {
+	items, err := trashItems()
+	if err != nil {
+		if len(items) == 0 {
+			app.ui.echoerrf("trash-list: %s", err)
+			return
+		}
+		log.Printf("trash-list: %s", err)
+	}
+
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, item.deleted.Format(time.DateTime)+"  "+item.path)
+	}
+
+	if gHeadless {
+		for _, line := range lines {
+			app.ui.echomsg(line)
+		}
+		return
+	}
+
+	if len(lines) == 0 {
+		app.ui.echo("trash-list: trash is empty")
+		return
+	}
+
+	app.ui.pager = newPager(fmt.Sprintf("trash-list: %d files", len(lines)), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "a b%.txt")
+	if err := os.WriteFile(path, []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Truncate(time.Second)
+	item, err := trashFile(path)
+	if err != nil {
+		t.Fatalf("trashing file: %s", err)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		t.Errorf("expected '%s' to be moved to the trash", path)
+	}
+
+	data, err := os.ReadFile(item.info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Path="+filepath.ToSlash(dir)+"/a%20b%25.txt\n") {
+		t.Errorf("expected escaped path in info file but got '%s'", data)
+	}
+
+	items, err := trashItems()
+	if err != nil {
+		t.Fatalf("listing trash: %s", err)
+	}
+	got := findTrash(items, path)
+	if got == nil {
+		t.Fatalf("expected '%s' in trash but got '%v'", path, items)
+	}
+	if got.deleted.Before(start) || got.deleted.After(time.Now()) {
+		t.Errorf("expected deletion time after '%s' but got '%s'", start, got.deleted)
+	}
+
+	// duplicate names are numbered
+	if err := os.WriteFile(path, []byte("bar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dup, err := trashFile(path)
+	if err != nil {
+		t.Fatalf("trashing duplicate file: %s", err)
+	}
+	if filepath.Base(dup.file) != "a b%.txt.2" {
+		t.Errorf("expected 'a b%%.txt.2' but got '%s'", filepath.Base(dup.file))
+	}
+
+	if err := got.restore(); err != nil {
+		t.Fatalf("restoring file: %s", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "foo" {
+		t.Errorf("expected restored file with 'foo' but got '%s' with error '%v'", data, err)
+	}
+	if _, err := os.Lstat(got.info); err == nil {
+		t.Errorf("expected info file '%s' to be removed", got.info)
+	}
+
+	if err := dup.restore(); err == nil {
+		t.Error("expected an error for restoring over an existing file")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "foo" {
+		t.Errorf("expected existing file to be kept but got '%s'", data)
+	}
+}
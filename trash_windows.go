@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+// The Recycle Bin is used with the layout of Windows Vista and later, where
+// each volume has a '$Recycle.Bin\<SID>' directory for each user. A trashed
+// file is renamed to '$R<id><ext>' and its metadata is written to
+// '$I<id><ext>' in the same directory, which is also read by Explorer. The
+// shell API is not used since its structures are not portable across the
+// supported architectures.
+
+// This function returns the Recycle Bin of the current user in the volume of
+// the given path, creating it when it is missing.
+func recycleBin(path string) (string, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return "", err
+	}
+	vol := filepath.VolumeName(path)
+	if vol == "" {
+		return "", errTrashNotFound
+	}
+	dir := filepath.Join(vol+`\`, "$Recycle.Bin", user.User.Sid.String())
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("%s: %s", errTrashNotFound, err)
+	}
+	return dir, nil
+}
+
+func recycleID() (string, error) {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = chars[int(b[i])%len(chars)]
+	}
+	return string(b), nil
+}
+
+// This function returns the contents of a metadata file in version 2, which
+// consists of the version, the size, the deletion time as a FILETIME, and the
+// length of the original path including the null character followed by the
+// path in UTF-16.
+func recycleInfo(path string, size int64, deleted time.Time) []byte {
+	ft := windows.NsecToFiletime(deleted.UnixNano())
+	name := append(utf16.Encode([]rune(path)), 0)
+
+	b := binary.LittleEndian.AppendUint64(nil, 2)
+	b = binary.LittleEndian.AppendUint64(b, uint64(size))
+	b = binary.LittleEndian.AppendUint32(b, ft.LowDateTime)
+	b = binary.LittleEndian.AppendUint32(b, ft.HighDateTime)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(name)))
+	for _, c := range name {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return b
+}
+
+// This function parses the contents of a metadata file. Version 1 is written
+// by Windows Vista to 8.1 with the path in a fixed buffer of 260 characters.
+func parseRecycleInfo(b []byte) (string, time.Time, error) {
+	if len(b) < 24 {
+		return "", time.Time{}, errors.New("invalid recycle bin info")
+	}
+	version := binary.LittleEndian.Uint64(b)
+	ft := windows.Filetime{
+		LowDateTime:  binary.LittleEndian.Uint32(b[16:]),
+		HighDateTime: binary.LittleEndian.Uint32(b[20:]),
+	}
+
+	var raw []byte
+	switch version {
+	case 1:
+		raw = b[24:]
+	case 2:
+		if len(b) < 28 {
+			return "", time.Time{}, errors.New("invalid recycle bin info")
+		}
+		n := int(binary.LittleEndian.Uint32(b[24:]))
+		raw = b[28:]
+		if len(raw) > 2*n {
+			raw = raw[:2*n]
+		}
+	default:
+		return "", time.Time{}, fmt.Errorf("unknown recycle bin info version: %d", version)
+	}
+
+	name := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		c := binary.LittleEndian.Uint16(raw[i:])
+		if c == 0 {
+			break
+		}
+		name = append(name, c)
+	}
+
+	return string(utf16.Decode(name)), time.Unix(0, ft.Nanoseconds()), nil
+}
+
+func trashFile(path string) (*trashItem, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lstat, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := recycleBin(path)
+	if err != nil {
+		return nil, err
+	}
+
+	size := lstat.Size()
+	if lstat.IsDir() {
+		size, _ = dirSize(path)
+	}
+
+	item := &trashItem{path: path, deleted: time.Now()}
+	ext := filepath.Ext(path)
+	for {
+		id, err := recycleID()
+		if err != nil {
+			return nil, err
+		}
+		item.file = filepath.Join(dir, "$R"+id+ext)
+		item.info = filepath.Join(dir, "$I"+id+ext)
+		f, err := os.OpenFile(item.info, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.Write(recycleInfo(path, size, item.deleted))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(path, item.file)
+		}
+		if err != nil {
+			os.Remove(item.info)
+			return nil, err
+		}
+		return item, nil
+	}
+}
+
+func listTrash() ([]*trashItem, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	sid := user.User.Sid.String()
+
+	drives, err := windows.GetLogicalDrives()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*trashItem
+	var errs []error
+	for i := 0; i < 26; i++ {
+		if drives&(1<<i) == 0 {
+			continue
+		}
+		dir := filepath.Join(string(rune('A'+i))+`:\`, "$Recycle.Bin", sid)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			id, ok := strings.CutPrefix(entry.Name(), "$I")
+			if !ok {
+				continue
+			}
+			file := filepath.Join(dir, "$R"+id)
+			if _, err := os.Lstat(file); err != nil {
+				continue
+			}
+			info := filepath.Join(dir, entry.Name())
+			b, err := os.ReadFile(info)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			path, deleted, err := parseRecycleInfo(b)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s", info, err))
+				continue
+			}
+			items = append(items, &trashItem{path: path, deleted: deleted, file: file, info: info})
+		}
+	}
+
+	return items, errors.Join(errs...)
+}
@@ -0,0 +1,345 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The trash of the FreeDesktop.org trash specification is used, where the home
+// trash is used for files on the same device as the home trash, and the trash
+// in the top directory of the mount point otherwise (i.e. '$topdir/.Trash/$uid'
+// when it is created by the administrator, or '$topdir/.Trash-$uid'). Files
+// are never copied across devices. Deletion dates are in local time without a
+// timezone as in the specification.
+
+const gTrashTimeFormat = "2006-01-02T15:04:05"
+
+func homeTrash() string {
+	data := cmp.Or(os.Getenv("XDG_DATA_HOME"), filepath.Join(gUser.HomeDir, ".local", "share"))
+	return filepath.Join(data, "Trash")
+}
+
+// This function returns the top directory of the mount point containing the
+// given path, which is the last parent directory on the same device.
+func topDir(path string, dev uint64) string {
+	dir := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		stat, err := os.Stat(parent)
+		if err != nil {
+			return dir
+		}
+		if d, ok := deviceID(stat); !ok || d != dev {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// This function returns the trash directories of the given top directory, of
+// which the first one is preferred when it exists.
+func topTrashes(top string) []string {
+	uid := strconv.Itoa(os.Getuid())
+	return []string{
+		filepath.Join(top, ".Trash", uid),
+		filepath.Join(top, ".Trash-"+uid),
+	}
+}
+
+// This function checks that the shared '.Trash' directory is created by the
+// administrator as in the specification, i.e. it is not a symbolic link and
+// the sticky bit is set.
+func sharedTrashValid(top string) bool {
+	lstat, err := os.Lstat(filepath.Join(top, ".Trash"))
+	return err == nil && lstat.IsDir() && lstat.Mode()&os.ModeSticky != 0
+}
+
+func makeTrash(dir string) error {
+	for _, sub := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return err
+		}
+	}
+	lstat, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !lstat.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+	return nil
+}
+
+// This function returns the trash directory for the given file, and the top
+// directory which the original paths are relative to, which is empty for the
+// home trash.
+func trashDir(path string, lstat os.FileInfo) (dir, top string, err error) {
+	dev, ok := deviceID(lstat)
+
+	home := homeTrash()
+	if err := makeTrash(home); err != nil && !ok {
+		return "", "", err
+	}
+	if stat, err := os.Stat(home); err == nil {
+		if d, hok := deviceID(stat); !ok || (hok && d == dev) {
+			return home, "", nil
+		}
+	}
+
+	top = topDir(path, dev)
+	trashes := topTrashes(top)
+	if sharedTrashValid(top) {
+		if err := makeTrash(trashes[0]); err == nil {
+			return trashes[0], top, nil
+		}
+	}
+	if err := makeTrash(trashes[1]); err != nil {
+		return "", "", fmt.Errorf("%s: %s", errTrashNotFound, err)
+	}
+	return trashes[1], top, nil
+}
+
+// This function reserves a name in the given trash directory by creating its
+// info file exclusively, adding a number to the name for duplicates.
+func reserveTrashName(dir, name string) (string, *os.File, error) {
+	for i := 1; ; i++ {
+		n := name
+		if i > 1 {
+			n = fmt.Sprintf("%s.%d", name, i)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "files", n)); err == nil {
+			continue
+		}
+		f, err := os.OpenFile(filepath.Join(dir, "info", n+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		return n, f, err
+	}
+}
+
+func trashFile(path string) (*trashItem, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lstat, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, top, err := trashDir(path, lstat)
+	if err != nil {
+		return nil, err
+	}
+
+	// original paths are relative to the top directory in its trash
+	rel := path
+	if top != "" {
+		if rel, err = filepath.Rel(top, path); err != nil {
+			return nil, err
+		}
+	}
+
+	name, f, err := reserveTrashName(dir, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	item := &trashItem{
+		path:    path,
+		deleted: time.Now().Truncate(time.Second),
+		file:    filepath.Join(dir, "files", name),
+		info:    f.Name(),
+	}
+
+	u := url.URL{Path: filepath.ToSlash(rel)}
+	_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", u.EscapedPath(), item.deleted.Format(gTrashTimeFormat))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(path, item.file)
+	}
+	if err != nil {
+		os.Remove(item.info)
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// This function parses the given info file of a trashed file, where relative
+// paths are relative to the given top directory.
+func readTrashInfo(path, top string) (string, time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer f.Close()
+
+	var orig string
+	var deleted time.Time
+	header := false
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "[") {
+			header = line == "[Trash Info]"
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !header || !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			if orig, err = url.PathUnescape(val); err != nil {
+				return "", time.Time{}, fmt.Errorf("invalid path: %s", val)
+			}
+		case "DeletionDate":
+			// dates with a timezone are not in the specification but used by
+			// some implementations
+			deleted, err = time.ParseInLocation(gTrashTimeFormat, val, time.Local)
+			if err != nil {
+				deleted, _ = time.Parse(time.RFC3339, val)
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", time.Time{}, err
+	}
+
+	if orig == "" {
+		return "", time.Time{}, fmt.Errorf("missing path: %s", path)
+	}
+	orig = filepath.FromSlash(orig)
+	if !filepath.IsAbs(orig) {
+		orig = filepath.Join(top, orig)
+	}
+
+	return filepath.Clean(orig), deleted, nil
+}
+
+// This function returns the files in the given trash directory. Info files
+// without a trashed file are skipped.
+func readTrash(dir, top string) ([]*trashItem, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, "info"))
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*trashItem
+	var errs []error
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".trashinfo")
+		if !ok {
+			continue
+		}
+
+		file := filepath.Join(dir, "files", name)
+		if _, err := os.Lstat(file); err != nil {
+			continue
+		}
+
+		info := filepath.Join(dir, "info", entry.Name())
+		path, deleted, err := readTrashInfo(info, top)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		items = append(items, &trashItem{path: path, deleted: deleted, file: file, info: info})
+	}
+
+	return items, errors.Join(errs...)
+}
+
+// This function returns the mount points in '/proc/self/mounts', which is
+// only available on Linux. Spaces and other characters are escaped in octal.
+func mountPoints() []string {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var mounts []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		mounts = append(mounts, unescapeOctal(fields[1]))
+	}
+	return mounts
+}
+
+func unescapeOctal(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func listTrash() ([]*trashItem, error) {
+	var items []*trashItem
+	var errs []error
+
+	home := homeTrash()
+	if list, err := readTrash(home, ""); err == nil || errors.Is(err, os.ErrNotExist) {
+		items = append(items, list...)
+	} else {
+		items = append(items, list...)
+		errs = append(errs, err)
+	}
+
+	tops := mountPoints()
+	if wd, err := os.Getwd(); err == nil {
+		if lstat, err := os.Lstat(wd); err == nil {
+			if dev, ok := deviceID(lstat); ok {
+				tops = append(tops, topDir(filepath.Join(wd, "."), dev))
+			}
+		}
+	}
+
+	seen := map[string]bool{home: true}
+	for _, top := range tops {
+		for _, dir := range topTrashes(top) {
+			if seen[dir] {
+				continue
+			}
+			seen[dir] = true
+			list, err := readTrash(dir, top)
+			items = append(items, list...)
+			if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, os.ErrPermission) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return items, errors.Join(errs...)
+}
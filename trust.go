@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Files that can run commands without being written by the user, such as
+// '.lfenv' files and files read with 'source' when the 'trustsource' option is
+// enabled, are only loaded once they are trusted by the user. Trusted files
+// are remembered with a hash of their path and contents in a data file, similar
+// to 'direnv allow', so that they need to be trusted again after they are
+// changed.
+
+type trustedFile struct {
+	path string
+	hash string
+	data []byte
+}
+
+func readTrustedFile(path string) (*trustedFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(data)
+	return &trustedFile{path, hex.EncodeToString(h.Sum(nil)), data}, nil
+}
+
+// This function returns the hashes of the trusted files, which are kept one
+// per line with the hash followed by the path.
+func readTrusted() (map[string]string, error) {
+	trusted := make(map[string]string)
+
+	f, err := os.Open(gTrustPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return trusted, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		hash, path, ok := strings.Cut(s.Text(), " ")
+		if !ok {
+			continue
+		}
+		trusted[path] = hash
+	}
+
+	return trusted, s.Err()
+}
+
+func isTrusted(f *trustedFile) bool {
+	trusted, err := readTrusted()
+	if err != nil {
+		log.Printf("reading trust file: %s", err)
+		return false
+	}
+	return trusted[f.path] == f.hash
+}
+
+// This function trusts the given file, or removes it from the trusted files
+// when the hash is empty.
+func setTrusted(path, hash string) error {
+	if strings.ContainsAny(path, "\r\n") {
+		return fmt.Errorf("file name contains a newline: %q", path)
+	}
+
+	return withDataLock(gTrustPath, func() error {
+		trusted, err := readTrusted()
+		if err != nil {
+			return fmt.Errorf("reading trust file: %s", err)
+		}
+
+		if hash == "" {
+			delete(trusted, path)
+		} else {
+			trusted[path] = hash
+		}
+
+		var b bytes.Buffer
+		for _, path := range slices.Sorted(maps.Keys(trusted)) {
+			fmt.Fprintf(&b, "%s %s\n", trusted[path], path)
+		}
+
+		if err := writeDataFile(gTrustPath, b.Bytes()); err != nil {
+			return fmt.Errorf("writing trust file: %s", err)
+		}
+
+		return nil
+	})
+}
+
+func (app *app) sourceTrusted(f *trustedFile) {
+	log.Printf("reading file: %s", f.path)
+
+	p := newParser(bytes.NewReader(f.data))
+	for p.parse() {
+		p.expr.eval(app, nil)
+	}
+
+	if p.err != nil {
+		app.ui.echoerrf("%s", p.err)
+	}
+
+	app.ui.loadFileInfo(app.nav)
+}
+
+// This function reads the given file for the 'source' command. When the
+// 'trustsource' option is enabled, files are only read when they are trusted,
+// and the user is asked otherwise.
+func (app *app) source(path string) {
+	if !gOpts.trustsource {
+		app.readFile(path)
+		app.ui.loadFileInfo(app.nav)
+		return
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		app.ui.echoerrf("source: %s", err)
+		return
+	}
+
+	f, err := readTrustedFile(path)
+	if err != nil {
+		app.ui.echoerrf("source: %s", err)
+		return
+	}
+
+	if isTrusted(f) {
+		app.sourceTrusted(f)
+		return
+	}
+
+	// the user can not be asked when another prompt is active
+	if gHeadless || app.ui.cmdPrefix != "" {
+		app.ui.echoerrf("source: '%s' is not trusted, use 'trust' to read it", path)
+		return
+	}
+
+	app.sourcePending = f
+	app.ui.cmdPrefix = fmt.Sprintf("source: read '%s'? [y]es, [a]lways or [N]o ", path)
+}
+
+// This function handles the answer to the question asked by 'source'.
+func (app *app) answerSource(answer string) {
+	f := app.sourcePending
+	app.sourcePending = nil
+	if f == nil {
+		return
+	}
+
+	switch answer {
+	case "a":
+		if err := setTrusted(f.path, f.hash); err != nil {
+			app.ui.echoerrf("source: %s", err)
+			return
+		}
+		app.sourceTrusted(f)
+	case "y":
+		app.sourceTrusted(f)
+	}
+}
+
+// This function trusts the given files, or removes them from the trusted
+// files. Files are trusted with their current contents.
+func (app *app) trust(name string, paths []string, trust bool) {
+	if len(paths) == 0 {
+		app.ui.echoerrf("%s: requires at least one file", name)
+		return
+	}
+
+	for _, path := range paths {
+		path, err := filepath.Abs(replaceTilde(path))
+		if err != nil {
+			app.ui.echoerrf("%s: %s", name, err)
+			return
+		}
+
+		hash := ""
+		if trust {
+			f, err := readTrustedFile(path)
+			if err != nil {
+				app.ui.echoerrf("%s: %s", name, err)
+				return
+			}
+			hash = f.hash
+		}
+
+		if err := setTrusted(path, hash); err != nil {
+			app.ui.echoerrf("%s: %s", name, err)
+			return
+		}
+	}
+
+	if trust {
+		app.ui.echo(fmt.Sprintf("%s: trusted %d file(s)", name, len(paths)))
+	} else {
+		app.ui.echo(fmt.Sprintf("%s: removed %d file(s)", name, len(paths)))
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrusted(t *testing.T) {
+	oldPath := gTrustPath
+	defer func() { gTrustPath = oldPath }()
+
+	dir := t.TempDir()
+	gTrustPath = filepath.Join(dir, "data", "trust")
+
+	path := filepath.Join(dir, "lfrc")
+	if err := os.WriteFile(path, []byte("set hidden\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := readTrustedFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isTrusted(f) {
+		t.Errorf("expected '%s' not to be trusted", path)
+	}
+
+	if err := setTrusted(path, f.hash); err != nil {
+		t.Fatal(err)
+	}
+	if !isTrusted(f) {
+		t.Errorf("expected '%s' to be trusted", path)
+	}
+
+	if err := os.WriteFile(path, []byte("set nohidden\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := readTrustedFile(path); err != nil || isTrusted(changed) {
+		t.Errorf("expected changed '%s' not to be trusted", path)
+	}
+
+	if err := setTrusted(path, ""); err != nil {
+		t.Fatal(err)
+	}
+	if isTrusted(f) {
+		t.Errorf("expected '%s' to be removed", path)
+	}
+}
+
+func TestCheckRemote(t *testing.T) {
+	oldPath := gRemotePath
+	defer func() { gRemotePath = oldPath }()
+
+	gRemotePath = filepath.Join(t.TempDir(), "remote")
+
+	if err := checkRemote("$rm -rf ~"); err != nil {
+		t.Errorf("expected all commands to be allowed without allowlist but got '%s'", err)
+	}
+
+	allowlist := "# navigation only\ncd\nselect\nmark-*\n"
+	if err := os.WriteFile(gRemotePath, []byte(allowlist), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cmd     string
+		allowed bool
+	}{
+		{"cd /tmp", true},
+		{"mark-load", true},
+		{":cd /tmp; select foo", true},
+		{"$rm -rf ~", false},
+		{"push $rm<enter>", false},
+		{":cd /tmp; set hidden", false},
+		{"cmd cd $rm -rf ~", false},
+	}
+
+	for _, test := range tests {
+		if err := checkRemote(test.cmd); (err == nil) != test.allowed {
+			t.Errorf("at input '%s' expected allowed to be %t but got error '%v'", test.cmd, test.allowed, err)
+		}
+	}
+
+	if err := checkSession([]string{"cd /tmp", "$touch pwned"}); err == nil {
+		t.Errorf("expected a session with a shell command to be rejected")
+	}
+}
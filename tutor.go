@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The tutorial walks through the basic features step by step inside a
+// sandbox directory created with sample files, similar to vimtutor. The
+// default settings are used regardless of the configuration files, and the
+// data files (e.g. marks and history) are kept in the temporary directory so
+// that nothing outside of it is modified. Each lesson is shown at the bottom
+// of the screen and is completed when its check succeeds after a command.
+
+type tutorLesson struct {
+	title string
+	text  []string
+	check func(app *app, t *tutor) bool
+}
+
+type tutor struct {
+	ind     int
+	lessons []tutorLesson
+	left    bool
+}
+
+var gTutorFiles = map[string]string{
+	"documents/notes.txt":           "Things to remember.\n",
+	"documents/todo.txt":            "- finish the lf tutorial\n",
+	"documents/report-2023.md":      "# Annual report 2023\n",
+	"documents/report-2024.md":      "# Annual report 2024\n",
+	"documents/letter.txt":          "Dear reader,\n",
+	"pictures/beach.jpg":            "",
+	"pictures/birthday-party.jpg":   "",
+	"pictures/cat.jpg":              "",
+	"pictures/city-at-night.jpg":    "",
+	"pictures/garden.jpg":           "",
+	"pictures/holiday-mountain.jpg": "",
+	"pictures/holiday-sea.jpg":      "",
+	"pictures/mountains.jpg":        "",
+	"pictures/sunset.jpg":           "",
+	"projects/website/index.html":   "<h1>Hello</h1>\n",
+	"projects/lf-tips.txt":          "Press <f-1> or type ':help' for the documentation.\n",
+	"backup/.keep":                  "",
+	"zebra.txt":                     "The last file in the list.\n",
+	"README.txt":                    "This directory is a sandbox for the lf tutorial.\nIt is deleted when lf exits.\n",
+}
+
+// This function creates the tutorial sandbox in a temporary directory and
+// redirects the data files into it. It returns the temporary directory, which
+// should be removed on exit, and changes the working directory to the sandbox.
+func setupTutor() (string, error) {
+	root, err := os.MkdirTemp("", "lf-tutor-")
+	if err != nil {
+		return "", fmt.Errorf("creating temporary directory: %s", err)
+	}
+
+	// paths are compared with the working directory in lessons
+	if path, err := filepath.EvalSymlinks(root); err == nil {
+		root = path
+	}
+
+	sandbox := filepath.Join(root, "sandbox")
+	for name, content := range gTutorFiles {
+		path := filepath.Join(sandbox, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return root, fmt.Errorf("creating sandbox: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return root, fmt.Errorf("creating sandbox: %s", err)
+		}
+	}
+
+	data := filepath.Join(root, "data")
+	gFilesPath = filepath.Join(data, "files")
+	gMarksPath = filepath.Join(data, "marks")
+	gTagsPath = filepath.Join(data, "tags")
+	gHistoryPath = filepath.Join(data, "history")
+	gAuditPath = filepath.Join(data, "audit")
+	gRecoveryPath = filepath.Join(data, "recovery")
+	gTrustPath = filepath.Join(data, "trust")
+	gSortsPath = filepath.Join(data, "sorts")
+	gSessionPath = filepath.Join(data, "session")
+	gJumpPath = filepath.Join(data, "dirs")
+
+	if err := os.Chdir(sandbox); err != nil {
+		return root, fmt.Errorf("changing directory: %s", err)
+	}
+
+	gTutorDir = sandbox
+
+	return root, nil
+}
+
+func newTutor(root string) *tutor {
+	path := func(name string) string {
+		return filepath.Join(root, filepath.FromSlash(name))
+	}
+	cwd := func(app *app) string {
+		return app.nav.currDir().path
+	}
+	curr := func(app *app) string {
+		if f, err := app.nav.currFile(); err == nil {
+			return f.Name()
+		}
+		return ""
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(path(name))
+		return err == nil
+	}
+
+	return &tutor{
+		lessons: []tutorLesson{
+			{
+				title: "Moving around",
+				text: []string{
+					"Move the cursor down with 'j' and up with 'k' (or the arrow keys).",
+					"Enter the directory 'documents' by pressing 'l' on it.",
+				},
+				check: func(app *app, t *tutor) bool {
+					return cwd(app) == path("documents")
+				},
+			},
+			{
+				title: "Going back",
+				text: []string{
+					"The left pane shows the parent directory and the right pane a preview.",
+					"Press 'h' to go back to the parent directory.",
+				},
+				check: func(app *app, t *tutor) bool {
+					return cwd(app) == root
+				},
+			},
+			{
+				title: "Jumping",
+				text: []string{
+					"Press 'G' to jump to the last file and 'gg' to jump to the first one.",
+					"Jump to the last file 'zebra.txt'.",
+				},
+				check: func(app *app, t *tutor) bool {
+					return cwd(app) == root && curr(app) == "zebra.txt"
+				},
+			},
+			{
+				title: "Selecting files",
+				text: []string{
+					"Press <space> to select the current file (and move down), again to unselect.",
+					"Enter 'documents' and select both 'notes.txt' and 'todo.txt'.",
+				},
+				check: func(app *app, t *tutor) bool {
+					_, notes := app.nav.selections[path("documents/notes.txt")]
+					_, todo := app.nav.selections[path("documents/todo.txt")]
+					return notes && todo
+				},
+			},
+			{
+				title: "Copying and pasting",
+				text: []string{
+					"Press 'y' to copy the selected files ('d' would cut them instead).",
+					"Then go to the directory 'backup' and press 'p' to paste them there.",
+				},
+				check: func(app *app, t *tutor) bool {
+					return exists("backup/notes.txt") && exists("backup/todo.txt")
+				},
+			},
+			{
+				title: "Searching",
+				text: []string{
+					"Go to 'pictures', press '/', type 'holiday' and press <enter> to search.",
+					"Press 'n' and 'N' to move to the next and previous matches.",
+				},
+				check: func(app *app, t *tutor) bool {
+					return cwd(app) == path("pictures") && strings.HasPrefix(curr(app), "holiday")
+				},
+			},
+			{
+				title: "Marks",
+				text: []string{
+					"Press 'm' and then 'a' to save the current directory as mark 'a'.",
+					"Go to another directory, then press ' and then 'a' to jump back.",
+				},
+				check: func(app *app, t *tutor) bool {
+					mark, ok := app.nav.marks["a"]
+					if !ok {
+						return false
+					}
+					if cwd(app) != mark {
+						t.left = true
+						return false
+					}
+					return t.left
+				},
+			},
+			{
+				title: "Commands",
+				text: []string{
+					"Press ':' to type a command, e.g. 'help' followed by <enter>.",
+					"Open the documentation this way, and press 'q' to close it again.",
+				},
+				check: func(app *app, t *tutor) bool {
+					return app.ui.pager != nil
+				},
+			},
+			{
+				title: "Done",
+				text: []string{
+					"Congratulations, you have completed the tutorial!",
+					"Press 'q' to quit. The sandbox directory is deleted when lf exits.",
+				},
+			},
+		},
+	}
+}
+
+// This function returns the text of the current lesson to show on the screen
+// in the same format as menus, with the title as the header line.
+func (t *tutor) menu() string {
+	l := t.lessons[t.ind]
+	var b strings.Builder
+	fmt.Fprintf(&b, "tutor %d/%d: %s\n", t.ind+1, len(t.lessons), l.title)
+	for _, line := range l.text {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// This function advances to the next lesson when the check of the current one
+// succeeds, and updates the text shown on the screen.
+func (app *app) updateTutor() {
+	t := app.tutor
+	if t == nil || !app.nav.init {
+		return
+	}
+
+	for t.lessons[t.ind].check != nil && t.lessons[t.ind].check(app, t) {
+		t.ind++
+	}
+
+	app.ui.tutor = t.menu()
+}
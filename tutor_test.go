@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestTutorMenu(t *testing.T) {
+	tut := newTutor("/tmp/sandbox")
+
+	exp := "tutor 1/9: Moving around\n" +
+		"Move the cursor down with 'j' and up with 'k' (or the arrow keys).\n" +
+		"Enter the directory 'documents' by pressing 'l' on it.\n"
+	if got := tut.menu(); got != exp {
+		t.Errorf("expected menu %q but got %q", exp, got)
+	}
+
+	if last := tut.lessons[len(tut.lessons)-1]; last.check != nil {
+		t.Errorf("expected the last lesson to have no check")
+	}
+}
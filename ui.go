@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
@@ -22,6 +23,10 @@ import (
 
 const gEscapeCode = 27
 
+// Characters typed in Normal and Visual modes are translated with this table
+// before looking up mappings, which is set with the 'keytranslate' option.
+var gKeyTranslate map[rune]rune
+
 var gKeyVal = map[tcell.Key]string{
 	tcell.KeyEnter:          "<enter>",
 	tcell.KeyBackspace:      "<backspace>",
@@ -290,12 +295,20 @@ func infotimefmt(t time.Time) string {
 	return t.Format(gOpts.infotimefmtold)
 }
 
-func fileInfo(f *file, d *dir, userWidth int, groupWidth int, customWidth int) (string, string, int) {
+// This function also returns the span of the given active field in the info
+// (i.e. its start and end widths without the separating space) to highlight
+// the field.
+func fileInfo(f *file, d *dir, userWidth int, groupWidth int, customWidth int, active string) (string, string, int, [2]int) {
 	var info strings.Builder
 	var custom string
 	var off int
+	var span [2]int
 
 	for _, s := range getInfo(d.path) {
+		if s == active {
+			span[0] = runeSliceWidth([]rune(info.String())) + 1
+		}
+
 		switch s {
 		case "size":
 			if f.IsDir() && getDirCounts(d.path) {
@@ -342,15 +355,21 @@ func fileInfo(f *file, d *dir, userWidth int, groupWidth int, customWidth int) (
 		default:
 			log.Printf("unknown info type: %s", s)
 		}
+
+		if s == active {
+			span[1] = runeSliceWidth([]rune(info.String()))
+		}
 	}
 
-	return info.String(), custom, off
+	return info.String(), custom, off, span
 }
 
 type dirContext struct {
 	selections map[string]int
 	saves      map[string]bool
 	tags       map[string]string
+	git        *gitStatuses
+	columns    *shellColumns
 }
 
 type dirRole byte
@@ -389,6 +408,11 @@ func (win *win) printDir(ui *ui, dir *dir, context *dirContext, dirStyle *dirSty
 		return
 	}
 
+	if isGrid(dirStyle.role) {
+		win.printGrid(ui, dir, context, dirStyle)
+		return
+	}
+
 	beg := max(dir.ind-dir.pos, 0)
 	end := min(beg+win.h, fileslen)
 
@@ -428,6 +452,24 @@ func (win *win) printDir(ui *ui, dir *dir, context *dirContext, dirStyle *dirSty
 		}
 	}
 
+	// reserve a column for git statuses only in repositories
+	var gitWidth int
+	if gOpts.gitstatus && gGitStatusWidth > 0 && context.git != nil && context.git.rootOf(dir.path) != "" {
+		gitWidth = gGitStatusWidth + 1
+	}
+
+	var colWidths []int
+	if len(gColumns) > 0 {
+		colWidths = columnWidths(dir, beg, end, context)
+	}
+
+	colors := ui.screen.Colors()
+	var active string
+	columnStyle, hasColumn := dirStyle.colors.theme("cursorcolumn", colors)
+	if hasColumn {
+		active = activeInfoField(dir)
+	}
+
 	visualSelections := dir.visualSelections()
 	for i, f := range dir.files[beg:end] {
 		st := dirStyle.colors.get(f)
@@ -477,6 +519,8 @@ func (win *win) printDir(ui *ui, dir *dir, context *dirContext, dirStyle *dirSty
 		tag := " "
 		if val, ok := context.tags[path]; ok && len(val) > 0 {
 			tag = val
+		} else if f.mountPoint && gOpts.mountmarker != "" {
+			tag = gOpts.mountmarker
 		}
 
 		var icon []rune
@@ -487,31 +531,34 @@ func (win *win) printDir(ui *ui, dir *dir, context *dirContext, dirStyle *dirSty
 			icon = append(icon, ' ')
 		}
 
-		// subtract space for tag and icon
-		maxFilenameWidth := maxWidth - 1 - runeSliceWidth(icon)
+		// subtract space for tag, icon and git status
+		maxFilenameWidth := maxWidth - 1 - runeSliceWidth(icon) - gitWidth
 
-		info, custom, off := fileInfo(f, dir, userWidth, groupWidth, customWidth)
-		infolen := len(info)
+		var gitInd string
+		if gitWidth > 0 {
+			gitInd = gGitStatusFmts[context.git.get(dir.path, f.Name())]
+		}
+
+		info, custom, off, span := fileInfo(f, dir, userWidth, groupWidth, customWidth, active)
+		if colWidths != nil {
+			info += fileColumns(f, dir, colWidths, context)
+		}
+		infolen := runeSliceWidth([]rune(info))
 		showInfo := infolen > 0 && 2*infolen < maxWidth
 		if showInfo {
 			maxFilenameWidth -= infolen
 		}
 
-		filename := []rune(f.Name())
-		if runeSliceWidth(filename) > maxFilenameWidth {
-			truncatePos := (maxFilenameWidth - 1) * gOpts.truncatepct / 100
-			lastPart := runeSliceWidthLastRange(filename, maxFilenameWidth-truncatePos-1)
-			filename = runeSliceWidthRange(filename, 0, truncatePos)
-			filename = append(filename, []rune(gOpts.truncatechar)...)
-			filename = append(filename, lastPart...)
-		}
-		for j := runeSliceWidth(filename); j < maxFilenameWidth; j++ {
+		filename := truncateName([]rune(f.Name()), maxFilenameWidth)
+		for j := runeSliceWidth(filename); j < maxFilenameWidth+gitWidth; j++ {
 			filename = append(filename, ' ')
 		}
+		gitOff := lnwidth + 2 + runeSliceWidth(icon) + maxFilenameWidth + 1
 
+		infoOff := lnwidth + 2 + runeSliceWidth(icon) + maxFilenameWidth + gitWidth
 		if showInfo {
 			filename = append(filename, []rune(info)...)
-			off += lnwidth + 2 + runeSliceWidth(icon) + maxFilenameWidth
+			off += infoOff
 		}
 
 		if i == dir.pos {
@@ -536,6 +583,10 @@ func (win *win) printDir(ui *ui, dir *dir, context *dirContext, dirStyle *dirSty
 			if showInfo && custom != "" {
 				win.print(ui.screen, off, i, st, fmt.Sprintf(cursorFmt, stripAnsi(custom)))
 			}
+
+			if gitInd != "" {
+				win.print(ui.screen, gitOff, i, st, fmt.Sprintf(cursorFmt, stripAnsi(gitInd)))
+			}
 		} else {
 			if tag == " " {
 				win.print(ui.screen, lnwidth+1, i, st, " ")
@@ -559,6 +610,26 @@ func (win *win) printDir(ui *ui, dir *dir, context *dirContext, dirStyle *dirSty
 			if showInfo && custom != "" {
 				win.print(ui.screen, off, i, st, custom)
 			}
+
+			if gitInd != "" {
+				win.print(ui.screen, gitOff, i, st, gitInd)
+			}
+		}
+
+		// print the remaining characters of matching labels for 'hop'
+		if dirStyle.role == Active && i < len(ui.hopLabels) {
+			typed := string(ui.cmdAccLeft)
+			if label, ok := strings.CutPrefix(ui.hopLabels[i], typed); ok {
+				win.print(ui.screen, lnwidth+2, i, tcell.StyleDefault, fmt.Sprintf(optionToFmtstr(gOpts.hopfmt), label))
+			}
+		}
+
+		// shade the active info field before the row so that it stands out
+		if showInfo && span[1] > span[0] {
+			win.shade(ui.screen, infoOff+span[0], i, span[1]-span[0], columnStyle)
+		}
+		if rowStyle, ok := dirStyle.rowStyle(i == dir.pos, (beg+i)%2 == 1, colors); ok {
+			win.shade(ui.screen, 0, i, win.w, rowStyle)
 		}
 	}
 }
@@ -594,12 +665,17 @@ func getCustomWidth(dir *dir, beg int, end int) int {
 }
 
 func getWidths(wtot int) []int {
+	ratios := gOpts.ratios
+	if gOpts.dualpane {
+		ratios = []int{1, 1}
+	}
+
 	rsum := 0
-	for _, r := range gOpts.ratios {
+	for _, r := range ratios {
 		rsum += r
 	}
 
-	wlen := len(gOpts.ratios)
+	wlen := len(ratios)
 	widths := make([]int, wlen)
 
 	if gOpts.drawbox {
@@ -608,7 +684,7 @@ func getWidths(wtot int) []int {
 
 	wsum := 0
 	for i := range wlen - 1 {
-		widths[i] = gOpts.ratios[i] * wtot / rsum
+		widths[i] = ratios[i] * wtot / rsum
 		wsum += widths[i]
 	}
 	widths[wlen-1] = wtot - wsum
@@ -616,9 +692,15 @@ func getWidths(wtot int) []int {
 	return widths
 }
 
-func getWins(screen tcell.Screen) []*win {
+func getWins(screen tcell.Screen, tabRow bool) []*win {
 	wtot, htot := screen.Size()
 
+	// the tab bar takes a row below the prompt line
+	top := 0
+	if tabRow {
+		top = 1
+	}
+
 	widths := getWidths(wtot)
 
 	wacc := 0
@@ -627,9 +709,9 @@ func getWins(screen tcell.Screen) []*win {
 	for i := range wlen {
 		if gOpts.drawbox {
 			wacc++
-			wins = append(wins, newWin(widths[i], htot-4, wacc, 2))
+			wins = append(wins, newWin(widths[i], htot-4-top, wacc, 2+top))
 		} else {
-			wins = append(wins, newWin(widths[i], htot-2, wacc, 1))
+			wins = append(wins, newWin(widths[i], htot-2-top, wacc, 1+top))
 		}
 		wacc += widths[i]
 	}
@@ -638,46 +720,67 @@ func getWins(screen tcell.Screen) []*win {
 }
 
 type ui struct {
-	screen      tcell.Screen
-	sxScreen    sixelScreen
-	polling     bool
-	wins        []*win
-	promptWin   *win
-	msgWin      *win
-	menuWin     *win
-	msg         string
-	msgIsStat   bool
-	regPrev     *reg
-	dirPrev     *dir
-	exprChan    chan expr
-	keyChan     chan string
-	tevChan     chan tcell.Event
-	evChan      chan tcell.Event
-	menu        string
-	cmdPrefix   string
-	cmdAccLeft  []rune
-	cmdAccRight []rune
-	cmdYankBuf  []rune
-	cmdTmp      []rune
-	keyAcc      []rune
-	keyCount    []rune
-	styles      styleMap
-	icons       iconMap
-	currentFile string
-	pasteEvent  bool
+	screen        tcell.Screen
+	sxScreen      sixelScreen
+	polling       bool
+	wins          []*win
+	promptWin     *win
+	tabWin        *win
+	msgWin        *win
+	menuWin       *win
+	msg           string
+	msgIsStat     bool
+	regPrev       *reg
+	dirPrev       *dir
+	hoverPath     string
+	hoverTimer    *time.Timer
+	flashTimer    *time.Timer
+	exprChan      chan expr
+	keyChan       chan string
+	tevChan       chan tcell.Event
+	evChan        chan tcell.Event
+	menu          string
+	cmdPrefix     string
+	cmdAccLeft    []rune
+	cmdAccRight   []rune
+	cmdYankBuf    []rune
+	cmdTmp        []rune
+	keyAcc        []rune
+	keyCount      []rune
+	styles        styleMap
+	icons         iconMap
+	currentFile   string
+	pasteEvent    bool
+	pasteBuf      []rune
+	cmdPending    string
+	cmdPendingAcc []rune
+	hopLabels     []string
+	pager         *pager
+	tutor         string
+	errCount      int
+	tabRow        bool
 }
 
 func newUI(screen tcell.Screen) *ui {
 	wtot, htot := screen.Size()
 
+	hoverTimer := time.NewTimer(0)
+	hoverTimer.Stop()
+
+	flashTimer := time.NewTimer(0)
+	flashTimer.Stop()
+
 	ui := &ui{
 		screen:      screen,
 		polling:     true,
-		wins:        getWins(screen),
+		wins:        getWins(screen, false),
 		promptWin:   newWin(wtot, 1, 0, 0),
+		tabWin:      newWin(wtot, 1, 0, 1),
 		msgWin:      newWin(wtot, 1, 0, htot-1),
 		menuWin:     newWin(wtot, 1, 0, htot-2),
 		msgIsStat:   true,
+		hoverTimer:  hoverTimer,
+		flashTimer:  flashTimer,
 		exprChan:    make(chan expr, 1000),
 		keyChan:     make(chan string, 1000),
 		tevChan:     make(chan tcell.Event, 1000),
@@ -716,10 +819,11 @@ func (ui *ui) pollEvents() {
 }
 
 func (ui *ui) renew() {
-	ui.wins = getWins(ui.screen)
+	ui.wins = getWins(ui.screen, ui.tabRow)
 
 	wtot, htot := ui.screen.Size()
 	ui.promptWin.renew(wtot, 1, 0, 0)
+	ui.tabWin.renew(wtot, 1, 0, 1)
 	ui.msgWin.renew(wtot, 1, 0, htot-1)
 	ui.menuWin.renew(wtot, 1, 0, htot-2)
 }
@@ -728,14 +832,16 @@ func (ui *ui) sort() {
 	if ui.dirPrev == nil {
 		return
 	}
-	name := ui.dirPrev.name()
-	ui.dirPrev.sort()
-	ui.dirPrev.sel(name, ui.wins[0].h)
+	ui.dirPrev.resort(ui.wins[0].h)
 }
 
 func (ui *ui) echo(msg string) {
 	ui.msg = msg
 	ui.msgIsStat = false
+
+	if gHeadless && msg != "" {
+		printHeadless(os.Stdout, msg)
+	}
 }
 
 func (ui *ui) echomsg(msg string) {
@@ -752,14 +858,27 @@ func optionToFmtstr(optstr string) string {
 }
 
 func (ui *ui) echoerr(msg string) {
-	ui.echo(fmt.Sprintf(optionToFmtstr(gOpts.errorfmt), msg))
 	log.Printf("error: %s", msg)
+
+	if gHeadless {
+		ui.errCount++
+		printHeadless(os.Stderr, msg)
+		return
+	}
+
+	ui.echo(fmt.Sprintf(optionToFmtstr(gOpts.errorfmt), msg))
 }
 
 func (ui *ui) echoerrf(format string, a ...any) {
 	ui.echoerr(fmt.Sprintf(format, a...))
 }
 
+// This function reports whether the preview of the given file is generated
+// by the previewer script rather than listing the contents of a directory.
+func isRegPreview(f *file) bool {
+	return f.Mode().IsRegular() || (f.IsDir() && gOpts.dirpreviews && !gOpts.hoverpreviewdir && !gOpts.linkedpane)
+}
+
 // This represents the preview for a regular file.
 // This can also be used to represent the preview of a directory if
 // `dirpreviews` is enabled.
@@ -767,9 +886,15 @@ type reg struct {
 	loading  bool
 	volatile bool
 	loadTime time.Time
+	modTime  time.Time
+	size     int64
+	width    int
+	height   int
+	used     uint64
 	path     string
 	lines    []string
 	sixel    *string
+	image    *termImage
 }
 
 func (ui *ui) loadFile(app *app, volatile bool) {
@@ -784,6 +909,7 @@ func (ui *ui) loadFile(app *app, volatile bool) {
 
 	if curr.path != ui.currentFile {
 		ui.currentFile = curr.path
+		app.hoverDirSize(curr)
 		onSelect(app)
 	}
 
@@ -791,14 +917,35 @@ func (ui *ui) loadFile(app *app, volatile bool) {
 		app.nav.previewChan <- ""
 	}
 
-	if !gOpts.preview {
+	if !gOpts.preview || gOpts.dualpane || app.nav.linkedFocused() {
 		return
 	}
 
-	if curr.Mode().IsRegular() || (curr.IsDir() && gOpts.dirpreviews) {
-		ui.regPrev = app.nav.loadReg(curr.path, volatile)
+	// the preview pane is kept empty in directories with previews disabled
+	if !getPreview(app.nav.currDir().path) {
+		ui.regPrev = nil
+		ui.dirPrev = nil
+		return
+	}
+
+	if isRegPreview(curr) {
+		ui.regPrev = app.nav.loadReg(curr.path, ui.wins[len(ui.wins)-1], volatile)
 	} else if curr.IsDir() {
-		ui.dirPrev = app.nav.loadDir(curr.path)
+		ui.hoverTimer.Stop()
+		_, cached := app.nav.dirCache[curr.path]
+		if !gOpts.hoverpreviewdir || cached || gHeadless {
+			ui.dirPrev = app.nav.loadDir(curr.path)
+			app.refreshColumns()
+			return
+		}
+
+		// directories are loaded after the cursor stays on them for a while,
+		// so that scrolling over many directories does not load all of them
+		if ui.dirPrev == nil || ui.dirPrev.path != curr.path {
+			ui.dirPrev = nil
+			ui.hoverPath = curr.path
+			ui.hoverTimer.Reset(100 * time.Millisecond)
+		}
 	}
 }
 
@@ -876,6 +1023,7 @@ func (ui *ui) drawPromptLine(nav *nav) {
 	prompt = strings.ReplaceAll(gOpts.promptfmt, "%u", gUser.Username)
 	prompt = strings.ReplaceAll(prompt, "%h", gHostname)
 	prompt = strings.ReplaceAll(prompt, "%f", fname)
+	prompt = nav.segments.expand(prompt, dir.path)
 
 	if printLength(strings.ReplaceAll(strings.ReplaceAll(prompt, "%w", pwd), "%d", pwd)) > ui.promptWin.w {
 		names := strings.Split(pwd, sep)
@@ -904,6 +1052,14 @@ func (ui *ui) drawPromptLine(nav *nav) {
 		prompt = strings.ReplaceAll(prompt, "%F", "")
 	}
 
+	if mode := nav.cdMode(); mode != "" {
+		prompt += " [" + mode + "]"
+	}
+
+	if n := nav.peerDirs[dir.path]; n > 0 {
+		prompt += " [" + peersMsg(n) + "]"
+	}
+
 	// spacer
 	avail := ui.promptWin.w - printLength(prompt) + 2
 	if avail > 0 {
@@ -978,6 +1134,10 @@ func (ui *ui) drawRuler(nav *nav) {
 		progress = append(progress, fmt.Sprintf("[%d/%d]", nav.deleteCount, nav.deleteTotal))
 	}
 
+	if nav.dirSizeTotal > 0 {
+		progress = append(progress, fmt.Sprintf("[%d/%d]", nav.dirSizeCount, nav.dirSizeTotal))
+	}
+
 	opts := getOptsMap()
 
 	rulerfmt := strings.ReplaceAll(gOpts.rulerfmt, "|", "\x1f")
@@ -1025,6 +1185,10 @@ func (ui *ui) drawRuler(nav *nav) {
 			ruler.WriteString(section)
 		}
 	}
+	if gOpts.tabbar == "status" && len(nav.tabs) > 1 {
+		ui.msgWin.printRight(ui.screen, 0, st, nav.tabBar()+" "+ruler.String())
+		return
+	}
 	ui.msgWin.printRight(ui.screen, 0, st, ruler.String())
 }
 
@@ -1033,24 +1197,27 @@ func (ui *ui) drawBox() {
 
 	w, h := ui.screen.Size()
 
+	// the box starts below the tab bar when it is shown
+	top := ui.wins[0].y - 1
+
 	for i := 1; i < w-1; i++ {
-		ui.screen.SetContent(i, 1, tcell.RuneHLine, nil, st)
+		ui.screen.SetContent(i, top, tcell.RuneHLine, nil, st)
 		ui.screen.SetContent(i, h-2, tcell.RuneHLine, nil, st)
 	}
 
-	for i := 2; i < h-2; i++ {
+	for i := top + 1; i < h-2; i++ {
 		ui.screen.SetContent(0, i, tcell.RuneVLine, nil, st)
 		ui.screen.SetContent(w-1, i, tcell.RuneVLine, nil, st)
 	}
 
 	if gOpts.roundbox {
-		ui.screen.SetContent(0, 1, '╭', nil, st)
-		ui.screen.SetContent(w-1, 1, '╮', nil, st)
+		ui.screen.SetContent(0, top, '╭', nil, st)
+		ui.screen.SetContent(w-1, top, '╮', nil, st)
 		ui.screen.SetContent(0, h-2, '╰', nil, st)
 		ui.screen.SetContent(w-1, h-2, '╯', nil, st)
 	} else {
-		ui.screen.SetContent(0, 1, tcell.RuneULCorner, nil, st)
-		ui.screen.SetContent(w-1, 1, tcell.RuneURCorner, nil, st)
+		ui.screen.SetContent(0, top, tcell.RuneULCorner, nil, st)
+		ui.screen.SetContent(w-1, top, tcell.RuneURCorner, nil, st)
 		ui.screen.SetContent(0, h-2, tcell.RuneLLCorner, nil, st)
 		ui.screen.SetContent(w-1, h-2, tcell.RuneLRCorner, nil, st)
 	}
@@ -1058,8 +1225,8 @@ func (ui *ui) drawBox() {
 	wacc := 0
 	for wind := range len(ui.wins) - 1 {
 		wacc += ui.wins[wind].w + 1
-		ui.screen.SetContent(wacc, 1, tcell.RuneTTee, nil, st)
-		for i := 2; i < h-2; i++ {
+		ui.screen.SetContent(wacc, top, tcell.RuneTTee, nil, st)
+		for i := top + 1; i < h-2; i++ {
 			ui.screen.SetContent(wacc, i, tcell.RuneVLine, nil, st)
 		}
 		ui.screen.SetContent(wacc, h-2, tcell.RuneBTee, nil, st)
@@ -1067,8 +1234,12 @@ func (ui *ui) drawBox() {
 }
 
 func (ui *ui) dirOfWin(nav *nav, wind int) *dir {
+	if gOpts.dualpane {
+		return nav.paneDir(wind)
+	}
+
 	wins := len(ui.wins)
-	if gOpts.preview {
+	if gOpts.preview && !nav.linkedFocused() {
 		wins--
 	}
 	ind := len(nav.dirs) - wins + wind
@@ -1080,19 +1251,29 @@ func (ui *ui) dirOfWin(nav *nav, wind int) *dir {
 
 func (ui *ui) draw(nav *nav) {
 	st := tcell.StyleDefault
-	context := dirContext{selections: nav.selections, saves: nav.saves, tags: nav.tags}
+	context := dirContext{selections: nav.selections, saves: nav.saves, tags: nav.tags, git: &nav.gitStatus, columns: &nav.columns}
 
 	ui.screen.Clear()
 
+	if ui.pager != nil {
+		ui.drawPager()
+		ui.screen.Show()
+		return
+	}
+
 	ui.drawPromptLine(nav)
 
+	if ui.tabRow {
+		ui.tabWin.print(ui.screen, 0, 0, st, nav.tabBar())
+	}
+
 	wins := len(ui.wins)
-	if gOpts.preview {
+	if gOpts.preview && !gOpts.dualpane && !nav.linkedFocused() {
 		wins--
 	}
 	for i := range wins {
 		role := Parent
-		if i == wins-1 {
+		if gOpts.dualpane && i == nav.paneInd || !gOpts.dualpane && i == wins-1 {
 			role = Active
 		}
 		if dir := ui.dirOfWin(nav, i); dir != nil {
@@ -1116,7 +1297,7 @@ func (ui *ui) draw(nav *nav) {
 		maxWidth := ui.msgWin.w - 1 // leave space for cursor at the end
 		prefix := runeSliceWidthRange([]rune(ui.cmdPrefix), 0, maxWidth)
 		left := runeSliceWidthLastRange(ui.cmdAccLeft, maxWidth-runeSliceWidth(prefix))
-		ui.msgWin.printLine(ui.screen, 0, 0, st, string(prefix)+string(left)+string(ui.cmdAccRight))
+		ui.msgWin.printLine(ui.screen, 0, 0, st, string(prefix)+string(left)+ui.cmdPendingHint()+string(ui.cmdAccRight))
 		ui.screen.ShowCursor(ui.msgWin.x+runeSliceWidth(prefix)+runeSliceWidth(left), ui.msgWin.y)
 	}
 
@@ -1124,8 +1305,8 @@ func (ui *ui) draw(nav *nav) {
 	if err == nil {
 		preview := ui.wins[len(ui.wins)-1]
 		ui.sxScreen.clearSixel(preview, ui.screen, curr.path)
-		if gOpts.preview {
-			if curr.Mode().IsRegular() || (curr.IsDir() && gOpts.dirpreviews) {
+		if !gOpts.dualpane && !nav.linkedFocused() && getPreview(nav.currDir().path) {
+			if isRegPreview(curr) {
 				preview.printReg(ui.screen, ui.regPrev, nav.previewLoading, &ui.sxScreen)
 			} else if curr.IsDir() {
 				ui.sxScreen.lastFile = ""
@@ -1139,8 +1320,13 @@ func (ui *ui) draw(nav *nav) {
 		ui.drawBox()
 	}
 
-	if ui.menu != "" {
-		lines := strings.Split(ui.menu, "\n")
+	menu := ui.menu
+	if menu == "" {
+		menu = ui.tutor
+	}
+
+	if menu != "" {
+		lines := strings.Split(menu, "\n")
 
 		lines = lines[:len(lines)-1]
 
@@ -1399,25 +1585,26 @@ func (ui *ui) readNormalEvent(ev tcell.Event, nav *nav) expr {
 
 	switch tev := ev.(type) {
 	case *tcell.EventKey:
-		if ui.pasteEvent {
-			return nil
-		}
-
 		// KeyRune is a regular character
 		if tev.Key() == tcell.KeyRune {
+			r := tev.Rune()
+			if t, ok := gKeyTranslate[r]; ok {
+				r = t
+			}
+
 			switch {
-			case tev.Rune() == '<':
+			case r == '<':
 				ui.keyAcc = append(ui.keyAcc, []rune("<lt>")...)
-			case tev.Rune() == '>':
+			case r == '>':
 				ui.keyAcc = append(ui.keyAcc, []rune("<gt>")...)
-			case tev.Rune() == ' ':
+			case r == ' ':
 				ui.keyAcc = append(ui.keyAcc, []rune("<space>")...)
 			case tev.Modifiers() == tcell.ModAlt:
-				ui.keyAcc = append(ui.keyAcc, '<', 'a', '-', tev.Rune(), '>')
-			case unicode.IsDigit(tev.Rune()) && len(ui.keyAcc) == 0:
-				ui.keyCount = append(ui.keyCount, tev.Rune())
+				ui.keyAcc = append(ui.keyAcc, '<', 'a', '-', r, '>')
+			case unicode.IsDigit(r) && len(ui.keyAcc) == 0:
+				ui.keyCount = append(ui.keyCount, r)
 			default:
-				ui.keyAcc = append(ui.keyAcc, tev.Rune())
+				ui.keyAcc = append(ui.keyAcc, r)
 			}
 		} else {
 			val := gKeyVal[tev.Key()]
@@ -1439,6 +1626,15 @@ func (ui *ui) readNormalEvent(ev tcell.Event, nav *nav) expr {
 
 		switch len(binds) {
 		case 0:
+			// unmapped characters start filtering with 'incfind', using the
+			// typed character rather than its translation
+			if gOpts.incfind && mode == "n" && len(ui.keyAcc) == 1 && tev.Key() == tcell.KeyRune && unicode.IsPrint(tev.Rune()) {
+				expr := &callExpr{"filter", []string{string(tev.Rune())}, 1}
+				ui.keyAcc = nil
+				ui.keyCount = nil
+				ui.menu = ""
+				return expr
+			}
 			ui.echoerrf("unknown mapping: %s", string(ui.keyAcc))
 			ui.keyAcc = nil
 			ui.keyCount = nil
@@ -1470,6 +1666,10 @@ func (ui *ui) readNormalEvent(ev tcell.Event, nav *nav) expr {
 				return expr
 			}
 			if gOpts.showbinds {
+				// bindings of scoped commands are only shown where they are active
+				maps.DeleteFunc(binds, func(key string, e expr) bool {
+					return !isCmdActive(e)
+				})
 				ui.menu = listBinds(map[string]map[string]expr{
 					mode: binds,
 				})
@@ -1531,18 +1731,26 @@ func (ui *ui) readNormalEvent(ev tcell.Event, nav *nav) expr {
 			return nil
 		}
 
+		// clicking the other pane moves the focus to it
+		if gOpts.dualpane && wind != nav.paneInd {
+			return &callExpr{"pane-switch", nil, 1}
+		}
+
 		var dir *dir
-		if gOpts.preview && wind == len(ui.wins)-1 {
+		if gOpts.preview && !gOpts.dualpane && !nav.linkedFocused() && wind == len(ui.wins)-1 {
 			curr, err := nav.currFile()
 			if err != nil {
 				return nil
-			} else if !curr.IsDir() || gOpts.dirpreviews {
+			} else if !curr.IsDir() || isRegPreview(curr) {
 				if tev.Buttons() != tcell.Button2 {
 					return nil
 				}
 				return &callExpr{"open", nil, 1}
 			}
 			dir = ui.dirPrev
+			if dir == nil {
+				return nil
+			}
 		} else {
 			dir = ui.dirOfWin(nav, wind)
 			if dir == nil {
@@ -1552,7 +1760,10 @@ func (ui *ui) readNormalEvent(ev tcell.Event, nav *nav) expr {
 
 		var file *file
 		ind := dir.ind - dir.pos + y - w.y
-		if ind < len(dir.files) {
+		if !gOpts.singlecolumn && dir == nav.currDir() {
+			ind = dir.gridIndex(x-w.x, y-w.y)
+		}
+		if ind >= 0 && ind < len(dir.files) {
 			file = dir.files[ind]
 		}
 
@@ -1582,19 +1793,77 @@ func (ui *ui) readNormalEvent(ev tcell.Event, nav *nav) expr {
 		} else {
 			return &callExpr{"on-focus-lost", nil, 1}
 		}
+	}
+	return nil
+}
+
+// This function converts the text pasted in the prompt to a single line,
+// since pasted text is inserted literally without interpreting newlines as
+// keys. Each line is taken as a separate word (e.g. one path per line) and
+// lines are joined with spaces. When the 'pastequote' option is enabled, words
+// are also quoted for the command line so that pasted paths with spaces are
+// kept as single arguments.
+func pasteText(prefix string, text []rune) string {
+	var words []string
+	for _, line := range strings.FieldsFunc(string(text), func(r rune) bool { return r == '\n' || r == '\r' }) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if gOpts.pastequote {
+			switch prefix {
+			case ":":
+				line = escape(line)
+			case "$", "%", "!", "&":
+				line = shellQuote(line)
+			}
+		}
+		words = append(words, line)
+	}
+	return strings.Join(words, " ")
+}
+
+// This function is used to read events during a bracketed paste. Keys pasted
+// in the prompt are collected and inserted together at the end of the paste,
+// except for programs waiting for input, and keys pasted elsewhere are ignored
+// so that they do not trigger bindings.
+func (ui *ui) readPasteEvent(ev tcell.Event) expr {
+	switch tev := ev.(type) {
 	case *tcell.EventPaste:
-		if tev.Start() {
-			ui.pasteEvent = true
-		} else if tev.End() {
-			ui.pasteEvent = false
+		ui.pasteEvent = tev.Start()
+		text := ui.pasteBuf
+		ui.pasteBuf = nil
+		if tev.End() && ui.cmdPrefix != "" {
+			if s := pasteText(ui.cmdPrefix, text); s != "" {
+				return &callExpr{"cmd-insert", []string{s}, 1}
+			}
+		}
+	case *tcell.EventKey:
+		switch ui.cmdPrefix {
+		case "":
+			return nil
+		case ">":
+			// programs waiting for input receive pasted lines as typed
+			return ui.readCmdEvent(ev)
+		}
+		switch tev.Key() {
+		case tcell.KeyRune:
+			ui.pasteBuf = append(ui.pasteBuf, tev.Rune())
+		case tcell.KeyEnter, tcell.KeyLF:
+			ui.pasteBuf = append(ui.pasteBuf, '\n')
+		case tcell.KeyTab:
+			ui.pasteBuf = append(ui.pasteBuf, '\t')
 		}
 	}
 	return nil
 }
 
-func readCmdEvent(ev tcell.Event) expr {
+func (ui *ui) readCmdEvent(ev tcell.Event) expr {
 	switch tev := ev.(type) {
 	case *tcell.EventKey:
+		if ui.cmdPending != "" {
+			return ui.readPendingEvent(tev)
+		}
+
 		if tev.Key() == tcell.KeyRune {
 			if tev.Modifiers() == tcell.ModMask(tcell.ModAlt) {
 				val := string([]rune{'<', 'a', '-', tev.Rune(), '>'})
@@ -1608,6 +1877,12 @@ func readCmdEvent(ev tcell.Event) expr {
 			val := gKeyVal[tev.Key()]
 			val = addSpecialKeyModifier(val, tev.Modifiers())
 			if expr, ok := gOpts.cmdkeys[val]; ok {
+				// '<c-k>' inserts digraphs in 'rename' and 'filter' prompts as in
+				// Vim, unless it is mapped to something other than the default
+				if e, ok := expr.(*callExpr); ok && val == "<c-k>" && e.name == "cmd-delete-end" &&
+					(ui.cmdPrefix == "rename: " || ui.cmdPrefix == "filter: ") {
+					return &callExpr{"cmd-digraph", nil, 1}
+				}
 				return expr
 			}
 		}
@@ -1615,13 +1890,115 @@ func readCmdEvent(ev tcell.Event) expr {
 	return nil
 }
 
+// This function returns the pending input of 'cmd-digraph' and 'cmd-literal'
+// commands shown at the cursor, with '?' and '^' shown before any input as in
+// Vim.
+func (ui *ui) cmdPendingHint() string {
+	switch {
+	case ui.cmdPending == "":
+		return ""
+	case len(ui.cmdPendingAcc) != 0:
+		return string(ui.cmdPendingAcc)
+	case ui.cmdPending == "digraph":
+		return "?"
+	default:
+		return "^"
+	}
+}
+
+// This function is used to read keys for a pending 'cmd-digraph' or
+// 'cmd-literal' command. Keys are collected until the character to insert is
+// known, and the pending input is shown at the cursor in the meantime.
+func (ui *ui) readPendingEvent(tev *tcell.EventKey) expr {
+	draw := &callExpr{"draw", nil, 1}
+
+	if tev.Key() == tcell.KeyEscape || tev.Key() == tcell.KeyCtrlC {
+		ui.cmdPending = ""
+		return draw
+	}
+
+	switch ui.cmdPending {
+	case "digraph":
+		if tev.Key() != tcell.KeyRune {
+			ui.cmdPending = ""
+			return draw
+		}
+		if len(ui.cmdPendingAcc) == 0 {
+			ui.cmdPendingAcc = []rune{tev.Rune()}
+			return draw
+		}
+		ui.cmdPending = ""
+		// the second character is inserted when there is no such digraph
+		r, ok := digraph(ui.cmdPendingAcc[0], tev.Rune())
+		if !ok {
+			r = tev.Rune()
+		}
+		return &callExpr{"cmd-insert", []string{string(r)}, 1}
+	case "literal":
+		if len(ui.cmdPendingAcc) == 0 {
+			switch {
+			case tev.Key() == tcell.KeyRune && codepointDigits(tev.Rune()) != 0:
+				ui.cmdPendingAcc = []rune{tev.Rune()}
+				return draw
+			case tev.Key() == tcell.KeyRune:
+				ui.cmdPending = ""
+				return &callExpr{"cmd-insert", []string{string(tev.Rune())}, 1}
+			case tev.Key() > tcell.KeyNUL && tev.Key() <= tcell.KeyUS:
+				// control keys are inserted as control characters
+				ui.cmdPending = ""
+				return &callExpr{"cmd-insert", []string{string(rune(tev.Key()))}, 1}
+			default:
+				ui.cmdPending = ""
+				return draw
+			}
+		}
+
+		if tev.Key() == tcell.KeyRune && strings.ContainsRune("0123456789abcdefABCDEF", tev.Rune()) {
+			ui.cmdPendingAcc = append(ui.cmdPendingAcc, tev.Rune())
+			if len(ui.cmdPendingAcc) <= codepointDigits(ui.cmdPendingAcc[0]) {
+				return draw
+			}
+		}
+
+		// the codepoint ends with the last digit or any other key, which is
+		// then read as usual (e.g. '<enter>' after a short codepoint)
+		ui.cmdPending = ""
+		insert := &callExpr{"cmd-insert", []string{string(ui.cmdPendingAcc[0])}, 1}
+		if r, ok := codepoint(string(ui.cmdPendingAcc)); ok {
+			insert.args[0] = string(r)
+		}
+		if len(ui.cmdPendingAcc) > codepointDigits(ui.cmdPendingAcc[0]) {
+			return insert
+		}
+		if e := ui.readCmdEvent(tev); e != nil {
+			return &listExpr{[]expr{insert, e}, 1}
+		}
+		return insert
+	}
+
+	return nil
+}
+
 func (ui *ui) readEvent(ev tcell.Event, nav *nav) expr {
 	if ev == nil {
 		return nil
 	}
 
+	switch ev.(type) {
+	case *tcell.EventPaste:
+		return ui.readPasteEvent(ev)
+	case *tcell.EventKey:
+		if ui.pasteEvent {
+			return ui.readPasteEvent(ev)
+		}
+	}
+
 	if _, ok := ev.(*tcell.EventKey); ok && ui.cmdPrefix != "" {
-		return readCmdEvent(ev)
+		return ui.readCmdEvent(ev)
+	}
+
+	if ui.pager != nil {
+		return ui.readPagerEvent(ev, nav)
 	}
 
 	return ui.readNormalEvent(ev, nav)
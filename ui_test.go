@@ -0,0 +1,242 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestPasteText(t *testing.T) {
+	tests := []struct {
+		prefix string
+		text   string
+		quote  bool
+		exp    string
+	}{
+		{":", "", false, ""},
+		{":", "foo bar", false, "foo bar"},
+		{":", "foo\nbar\n", false, "foo bar"},
+		{":", "foo\r\n\r\nbar", false, "foo bar"},
+		{":", "foo bar\nbaz", true, `foo\ bar baz`},
+		{"$", "it's here\nbaz", true, `'it'\''s here' 'baz'`},
+		{"rename: ", "foo bar\n", true, "foo bar"},
+	}
+
+	defer func(quote bool) { gOpts.pastequote = quote }(gOpts.pastequote)
+
+	for _, test := range tests {
+		gOpts.pastequote = test.quote
+		if got := pasteText(test.prefix, []rune(test.text)); got != test.exp {
+			t.Errorf("at input '%s' expected '%s' but got '%s'", test.text, test.exp, got)
+		}
+	}
+}
+
+func TestReadPasteEvent(t *testing.T) {
+	events := []tcell.Event{
+		tcell.NewEventPaste(true),
+		tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModNone),
+		tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone),
+		tcell.NewEventKey(tcell.KeyRune, 'b', tcell.ModNone),
+		tcell.NewEventPaste(false),
+	}
+
+	tests := []struct {
+		prefix string
+		exp    expr
+	}{
+		{":", &callExpr{"cmd-insert", []string{"a b"}, 1}},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		ui := &ui{cmdPrefix: test.prefix}
+
+		var got expr
+		for _, ev := range events {
+			if e := ui.readEvent(ev, nil); e != nil {
+				if got != nil {
+					t.Errorf("at prefix '%s' expected a single expression but got '%v' and '%v'", test.prefix, got, e)
+				}
+				got = e
+			}
+		}
+
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("at prefix '%s' expected '%v' but got '%v'", test.prefix, test.exp, got)
+		}
+		if ui.pasteEvent || ui.pasteBuf != nil {
+			t.Errorf("at prefix '%s' expected paste state to be reset", test.prefix)
+		}
+	}
+}
+
+func TestReadPendingEvent(t *testing.T) {
+	key := func(k tcell.Key) tcell.Event { return tcell.NewEventKey(k, 0, tcell.ModNone) }
+	runes := func(s string) []tcell.Event {
+		var events []tcell.Event
+		for _, r := range s {
+			events = append(events, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+		}
+		return events
+	}
+
+	tests := []struct {
+		prefix string
+		events []tcell.Event
+		exp    []string
+	}{
+		{"rename: ", append([]tcell.Event{key(tcell.KeyCtrlK)}, runes("e'")...), []string{"é"}},
+		{"rename: ", append([]tcell.Event{key(tcell.KeyCtrlK)}, runes("'e")...), []string{"é"}},
+		{"filter: ", append([]tcell.Event{key(tcell.KeyCtrlK)}, runes("->")...), []string{"→"}},
+		{"rename: ", append([]tcell.Event{key(tcell.KeyCtrlK)}, runes("qq")...), []string{"q"}},
+		{":", append([]tcell.Event{key(tcell.KeyCtrlK)}, runes("e'")...), []string{"e", "'"}},
+		{":", append([]tcell.Event{key(tcell.KeyCtrlV)}, runes("u2192a")...), []string{"→", "a"}},
+		{":", append([]tcell.Event{key(tcell.KeyCtrlV)}, runes("U0001f600")...), []string{"😀"}},
+		{":", append([]tcell.Event{key(tcell.KeyCtrlV)}, runes("xe9")...), []string{"é"}},
+		{":", append([]tcell.Event{key(tcell.KeyCtrlV)}, runes("e9")...), []string{"e", "9"}},
+		{":", append(append([]tcell.Event{key(tcell.KeyCtrlV)}, runes("u41")...), key(tcell.KeyLeft)), []string{"A"}},
+		{":", []tcell.Event{key(tcell.KeyCtrlV), key(tcell.KeyTab)}, []string{"\t"}},
+		{":", append([]tcell.Event{key(tcell.KeyCtrlV), key(tcell.KeyEscape)}, runes("a")...), []string{"a"}},
+	}
+
+	for _, test := range tests {
+		ui := &ui{cmdPrefix: test.prefix}
+
+		var got []string
+		var collect func(e expr)
+		collect = func(e expr) {
+			switch e := e.(type) {
+			case *callExpr:
+				switch e.name {
+				case "cmd-insert":
+					got = append(got, e.args[0])
+				case "cmd-digraph":
+					ui.cmdPending, ui.cmdPendingAcc = "digraph", nil
+				case "cmd-literal":
+					ui.cmdPending, ui.cmdPendingAcc = "literal", nil
+				}
+			case *listExpr:
+				for _, e := range e.exprs {
+					collect(e)
+				}
+			}
+		}
+		for _, ev := range test.events {
+			collect(ui.readEvent(ev, nil))
+		}
+
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("at prefix '%s' expected '%q' but got '%q'", test.prefix, test.exp, got)
+		}
+		if ui.cmdPending != "" {
+			t.Errorf("at prefix '%s' expected no pending input but got '%s'", test.prefix, ui.cmdPending)
+		}
+	}
+}
+
+func TestDualPane(t *testing.T) {
+	defer func(dualpane, drawbox bool) {
+		gOpts.dualpane = dualpane
+		gOpts.drawbox = drawbox
+	}(gOpts.dualpane, gOpts.drawbox)
+
+	gOpts.drawbox = false
+	gOpts.dualpane = true
+	if got := getWidths(81); !reflect.DeepEqual(got, []int{40, 41}) {
+		t.Errorf("expected two panes of half width but got '%v'", got)
+	}
+
+	a, b := &dir{path: "/a"}, &dir{path: "/b"}
+	nav := &nav{dirs: []*dir{a}}
+
+	if nav.paneDir(0) != a || nav.paneDir(1) != a {
+		t.Errorf("expected both panes to show the current directory")
+	}
+
+	nav.dirs = []*dir{b}
+	nav.pane = a
+	nav.paneInd = 1
+	if nav.paneDir(0) != a || nav.paneDir(1) != b {
+		t.Errorf("expected focused pane to show the current directory")
+	}
+}
+
+func TestLinkedPane(t *testing.T) {
+	defer func(linkedpane, preview, dualpane bool) {
+		gOpts.linkedpane = linkedpane
+		gOpts.preview = preview
+		gOpts.dualpane = dualpane
+	}(gOpts.linkedpane, gOpts.preview, gOpts.dualpane)
+
+	gOpts.linkedpane = true
+	gOpts.preview = true
+	gOpts.dualpane = false
+
+	a, b, c := &dir{path: "/"}, &dir{path: "/a"}, &dir{path: "/a/b"}
+	nav := &nav{dirs: []*dir{a, b}}
+	ui := &ui{wins: make([]*win, 3)}
+
+	if ui.dirOfWin(nav, 0) != a || ui.dirOfWin(nav, 1) != b {
+		t.Errorf("expected the current directory before the preview pane")
+	}
+
+	nav.dirs = []*dir{a, b, c}
+	nav.linkedFocus = true
+	if ui.dirOfWin(nav, 1) != b || ui.dirOfWin(nav, 2) != c {
+		t.Errorf("expected the current directory in the linked pane")
+	}
+
+	gOpts.linkedpane = false
+	if nav.linkedFocused() {
+		t.Errorf("expected no focus in the linked pane without the option")
+	}
+}
+
+func TestTabs(t *testing.T) {
+	defer func(tabbar string) {
+		gOpts.tabbar = tabbar
+	}(gOpts.tabbar)
+
+	gOpts.tabbar = "row"
+	nav := &nav{dirs: []*dir{{path: "/b/src"}}}
+	if nav.tabRow() {
+		t.Errorf("expected no tab bar without tabs")
+	}
+
+	nav.tabs = []*tab{newTab("/a/docs"), newTab("/b")}
+	nav.tabInd = 1
+	if exp, got := []string{"1:docs", "2:src"}, nav.tabLabels(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected tab labels '%q' but got '%q'", exp, got)
+	}
+	if exp, got := " 1:docs \033[7m 2:src \033[0m", nav.tabBar(); got != exp {
+		t.Errorf("expected tab bar '%q' but got '%q'", exp, got)
+	}
+	if !nav.tabRow() {
+		t.Errorf("expected tab bar in a dedicated row")
+	}
+
+	gOpts.tabbar = "status"
+	if nav.tabRow() {
+		t.Errorf("expected tab bar in the status line")
+	}
+
+	// indicators of jobs, errors and selections
+	nav.tabs[0].id = 1
+	nav.tabs[0].errors = 2
+	nav.tabs[0].selections["/a/docs/x"] = 0
+	nav.selections = map[string]int{"/b/src/y": 0, "/b/src/z": 1}
+	nav.pasteQueue.add([]string{"/a/docs/x"}, "/c", true, false, 1)
+	nav.pasteQueue.addDelete([]string{"/a/docs/w"}, 1)
+	if exp, got := []string{"1:docs &2 !2 *1", "2:src *2"}, nav.tabLabels(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected tab labels '%q' but got '%q'", exp, got)
+	}
+
+	app := &app{nav: nav}
+	app.tabError([]string{"0"})
+	app.tabError([]string{"1"})
+	if nav.tabs[0].errors != 3 || nav.tabs[1].errors != 0 {
+		t.Errorf("expected errors only for other tabs but got '%d' and '%d'", nav.tabs[0].errors, nav.tabs[1].errors)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// File operations of the session are recorded in a journal to be reversed
+// with the 'undo' command and done again with the 'redo' command. Each command
+// (i.e. 'paste', 'rename', 'trash' and 'delete') is a single entry with the
+// files it handled successfully. Copies are undone by moving the copied files
+// to the trash, so that they can be restored again to redo the copy, moves and
+// renames are undone by moving files back, and trashed files are restored.
+// Files deleted permanently can not be restored, so such entries are only
+// reported and kept in the journal when they are undone. Files are never
+// overwritten when an entry is reversed, and files that can not be reversed are
+// reported and dropped from the entry.
+
+type journalFile struct {
+	src  string
+	dst  string
+	item *trashItem
+}
+
+type journalEntry struct {
+	op    string
+	files []journalFile
+}
+
+// The journal is written by file operations running in the background, so it
+// is kept behind a lock.
+type journal struct {
+	mutex sync.Mutex
+	undo  []*journalEntry
+	redo  []*journalEntry
+}
+
+// This function records the given entry, which clears the entries to redo
+// since they may no longer apply after the new operation.
+func (j *journal) record(e *journalEntry) {
+	if len(e.files) == 0 {
+		return
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.undo = append(j.undo, e)
+	j.redo = nil
+}
+
+// This function removes and returns the last entry to undo, or to redo when
+// redo is set. Entries of permanent deletes are returned without being removed,
+// since they can not be reversed and should still be reported later.
+func (j *journal) pop(redo bool) *journalEntry {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	stack := &j.undo
+	if redo {
+		stack = &j.redo
+	}
+	if len(*stack) == 0 {
+		return nil
+	}
+
+	e := (*stack)[len(*stack)-1]
+	if e.op != "delete" {
+		*stack = (*stack)[:len(*stack)-1]
+	}
+	return e
+}
+
+// This function returns the last entry to undo, or to redo when redo is set,
+// without removing it.
+func (j *journal) last(redo bool) *journalEntry {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	stack := j.undo
+	if redo {
+		stack = j.redo
+	}
+	if len(stack) == 0 {
+		return nil
+	}
+	return stack[len(stack)-1]
+}
+
+func (j *journal) push(e *journalEntry, redo bool) {
+	if len(e.files) == 0 {
+		return
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if redo {
+		j.redo = append(j.redo, e)
+	} else {
+		j.undo = append(j.undo, e)
+	}
+}
+
+// This function moves the given file without overwriting the destination.
+func moveBack(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("file exists: %s", dst)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if errCrossDevice(err) {
+			return fmt.Errorf("not reversible across file systems: %s", src)
+		}
+		return err
+	}
+	return nil
+}
+
+// This function reverses the given file of the given operation, or does it
+// again when redo is set.
+func (f *journalFile) apply(op string, redo bool) error {
+	switch op {
+	case "copy":
+		if redo {
+			if err := f.item.restore(); err != nil {
+				return err
+			}
+			f.item = nil
+			return nil
+		}
+		item, err := trashFile(f.dst)
+		if err != nil {
+			return err
+		}
+		f.item = item
+	case "move", "rename":
+		if redo {
+			return moveBack(f.src, f.dst)
+		}
+		return moveBack(f.dst, f.src)
+	case "trash":
+		if redo {
+			item, err := trashFile(f.src)
+			if err != nil {
+				return err
+			}
+			f.item = item
+			return nil
+		}
+		return f.item.restore()
+	}
+	return nil
+}
+
+// This function reverses the last operation, or does the last reversed
+// operation again when redo is set. The entry is left in the journal for a dry
+// run.
+func (app *app) undo(redo, dryRun bool) {
+	name := "undo"
+	if redo {
+		name = "redo"
+	}
+
+	var e *journalEntry
+	if dryRun {
+		e = app.nav.journal.last(redo)
+	} else {
+		e = app.nav.journal.pop(redo)
+	}
+	if e == nil {
+		app.ui.echoerrf("%s: nothing to %s", name, name)
+		return
+	}
+
+	if e.op == "delete" {
+		app.ui.echoerrf("%s: delete of %d file(s) is not reversible", name, len(e.files))
+		return
+	}
+
+	if dryRun {
+		app.showDryRun(name, undoPlan(e, redo))
+		return
+	}
+
+	var done []journalFile
+	var errs []string
+	for i := len(e.files) - 1; i >= 0; i-- {
+		f := e.files[i]
+		if err := f.apply(e.op, redo); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		done = append([]journalFile{f}, done...)
+	}
+
+	e.files = done
+	app.nav.journal.push(e, !redo)
+
+	app.reloadAfter(name)
+
+	if len(errs) > 0 {
+		app.ui.echoerrf("%s: %s of %d file(s) failed: %s", name, e.op, len(errs), strings.Join(errs, "; "))
+		return
+	}
+
+	app.ui.echo(fmt.Sprintf("%s: %s of %d file(s)", name, e.op, len(done)))
+}
@@ -0,0 +1,140 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	var j journal
+
+	j.record(&journalEntry{op: "copy"})
+	if j.pop(false) != nil {
+		t.Errorf("expected empty entries not to be recorded")
+	}
+
+	a := &journalEntry{"rename", []journalFile{{src: "/a", dst: "/b"}}}
+	b := &journalEntry{"move", []journalFile{{src: "/c", dst: "/d/c"}}}
+	j.record(a)
+	j.record(b)
+
+	if e := j.pop(false); e != b {
+		t.Fatalf("expected last entry to be undone first")
+	}
+	j.push(b, true)
+
+	j.record(&journalEntry{"delete", []journalFile{{src: "/e"}}})
+	if j.pop(true) != nil {
+		t.Errorf("expected entries to redo to be cleared after a new operation")
+	}
+}
+
+func TestJournalApply(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := t.TempDir()
+
+	exists := func(path string) bool {
+		_, err := os.Lstat(path)
+		return err == nil
+	}
+
+	src := filepath.Join(dir, "a")
+	dst := filepath.Join(dir, "b")
+	if err := os.WriteFile(src, []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// rename
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	f := journalFile{src: src, dst: dst}
+	if err := f.apply("rename", false); err != nil || !exists(src) || exists(dst) {
+		t.Errorf("expected rename to be undone but got error '%v'", err)
+	}
+	if err := f.apply("rename", true); err != nil || exists(src) || !exists(dst) {
+		t.Errorf("expected rename to be redone but got error '%v'", err)
+	}
+
+	// files are not overwritten
+	if err := os.WriteFile(src, []byte("bar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.apply("rename", false); err == nil {
+		t.Errorf("expected an error when the original path exists")
+	}
+	if err := os.Remove(src); err != nil {
+		t.Fatal(err)
+	}
+
+	// copy
+	f = journalFile{src: src, dst: dst}
+	if err := f.apply("copy", false); err != nil || exists(dst) || f.item == nil {
+		t.Fatalf("expected copy to be moved to the trash but got error '%v'", err)
+	}
+	if err := f.apply("copy", true); err != nil || !exists(dst) {
+		t.Errorf("expected copy to be restored but got error '%v'", err)
+	}
+
+	// trash
+	item, err := trashFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f = journalFile{src: dst, item: item}
+	if err := f.apply("trash", false); err != nil || !exists(dst) {
+		t.Errorf("expected trashed file to be restored but got error '%v'", err)
+	}
+	if err := f.apply("trash", true); err != nil || exists(dst) || f.item == item {
+		t.Errorf("expected file to be trashed again but got error '%v'", err)
+	}
+}
+
+func TestUndoDelete(t *testing.T) {
+	app := &app{ui: &ui{}, nav: &nav{}}
+
+	a := &journalEntry{"rename", []journalFile{{src: "/a", dst: "/b"}}}
+	d := &journalEntry{"delete", []journalFile{{src: "/c"}}}
+	app.nav.journal.record(a)
+	app.nav.journal.record(d)
+
+	for range 2 {
+		app.undo(false, false)
+		if len(app.nav.journal.undo) != 2 || app.nav.journal.undo[1] != d || len(app.nav.journal.redo) != 0 {
+			t.Errorf("expected delete to be kept but got '%v' and '%v'", app.nav.journal.undo, app.nav.journal.redo)
+		}
+	}
+}
+
+func TestUndoDryRun(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a")
+	dst := filepath.Join(dir, "b")
+	if err := os.WriteFile(dst, []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &app{ui: &ui{}, nav: &nav{}}
+	e := &journalEntry{"rename", []journalFile{{src: src, dst: dst}}}
+	app.nav.journal.record(e)
+
+	app.undo(false, true)
+	if len(app.nav.journal.undo) != 1 || app.nav.journal.undo[0] != e || len(app.nav.journal.redo) != 0 {
+		t.Errorf("expected entry to be kept but got '%v' and '%v'", app.nav.journal.undo, app.nav.journal.redo)
+	}
+	if _, err := os.Lstat(dst); err != nil {
+		t.Errorf("expected '%s' to be left in place but got '%s'", dst, err)
+	}
+
+	exp := "move '" + dst + "' to '" + src + "'"
+	if plan := undoPlan(e, false); len(plan) != 1 || plan[0] != exp {
+		t.Errorf("expected plan '%s' but got '%v'", exp, plan)
+	}
+	exp = "move '" + src + "' to '" + dst + "' (exists, not moved)"
+	if plan := undoPlan(e, true); len(plan) != 1 || plan[0] != exp {
+		t.Errorf("expected plan '%s' but got '%v'", exp, plan)
+	}
+}
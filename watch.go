@@ -15,8 +15,10 @@ type watch struct {
 	quit        chan struct{}
 	loads       map[string]bool
 	loadTimer   *time.Timer
+	loadTime    time.Time
 	updates     map[string]bool
 	updateTimer *time.Timer
+	updateTime  time.Time
 	dirChan     chan<- *dir
 	fileChan    chan<- *file
 	delChan     chan<- string
@@ -119,6 +121,7 @@ func (watch *watch) loop() {
 				watch.dirChan <- dir
 			}
 			clear(watch.loads)
+			watch.loadTime = time.Now()
 		case <-watch.updateTimer.C:
 			for path := range watch.updates {
 				if _, err := os.Lstat(path); err != nil {
@@ -127,16 +130,20 @@ func (watch *watch) loop() {
 				watch.fileChan <- newFile(path)
 			}
 			clear(watch.updates)
+			watch.updateTime = time.Now()
 		case <-watch.quit:
 			return
 		}
 	}
 }
 
+// Events are collected for a short time before they are sent together, and
+// further delayed to limit them to 'reloadrate' per second, so that storms of
+// events (e.g. from extracting an archive) do not cause constant reloads.
 func (watch *watch) addLoad(path string) {
 	if len(watch.loads) == 0 {
 		watch.loadTimer.Stop()
-		watch.loadTimer.Reset(10 * time.Millisecond)
+		watch.loadTimer.Reset(max(10*time.Millisecond, reloadDelay(watch.loadTime)))
 	}
 	watch.loads[path] = true
 }
@@ -144,7 +151,7 @@ func (watch *watch) addLoad(path string) {
 func (watch *watch) addUpdate(path string) {
 	if len(watch.updates) == 0 {
 		watch.updateTimer.Stop()
-		watch.updateTimer.Reset(10 * time.Millisecond)
+		watch.updateTimer.Reset(max(10*time.Millisecond, reloadDelay(watch.updateTime)))
 	}
 	watch.updates[path] = true
 }